@@ -3,8 +3,10 @@ package command
 import (
 	"context"
 	"fmt"
+	"time"
 
 	accountssvc "github.com/owncloud/ocis/protogen/gen/ocis/services/accounts/v0"
+	settingssvc "github.com/owncloud/ocis/protogen/gen/ocis/services/settings/v0"
 
 	glauthcfg "github.com/glauth/glauth/v2/pkg/config"
 	"github.com/oklog/run"
@@ -16,6 +18,7 @@ import (
 	"github.com/owncloud/ocis/extensions/glauth/pkg/server/glauth"
 	"github.com/owncloud/ocis/extensions/glauth/pkg/tracing"
 	pkgcrypto "github.com/owncloud/ocis/ocis-pkg/crypto"
+	"github.com/owncloud/ocis/ocis-pkg/roles"
 	"github.com/owncloud/ocis/ocis-pkg/service/grpc"
 	"github.com/owncloud/ocis/ocis-pkg/version"
 	"github.com/urfave/cli/v2"
@@ -98,6 +101,12 @@ func Server(cfg *config.Config) *cli.Command {
 				}
 
 				as, gs := getAccountsServices()
+				rm := roles.NewManager(
+					roles.CacheSize(1024),
+					roles.CacheTTL(time.Hour),
+					roles.Logger(logger),
+					roles.RoleService(settingssvc.NewRoleService("com.owncloud.api.settings", grpc.DefaultClient)),
+				)
 				server, err := glauth.Server(
 					glauth.AccountsService(as),
 					glauth.GroupsService(gs),
@@ -107,6 +116,7 @@ func Server(cfg *config.Config) *cli.Command {
 					glauth.Backend(&bcfg),
 					glauth.Fallback(&fcfg),
 					glauth.RoleBundleUUID(cfg.RoleBundleUUID),
+					glauth.RoleManager(&rm),
 				)
 
 				if err != nil {
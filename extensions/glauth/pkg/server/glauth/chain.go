@@ -86,19 +86,19 @@ func (h chainHandler) Close(boundDN string, conn net.Conn) error {
 	return nil
 }
 
-// Add is not yet supported for the chain backend
+// Add is passed to the primary backend, the fallback backend is read-only
 func (h chainHandler) Add(boundDN string, req ldap.AddRequest, conn net.Conn) (result ldap.LDAPResultCode, err error) {
-	return ldap.LDAPResultInsufficientAccessRights, nil
+	return h.b.Add(boundDN, req, conn)
 }
 
-// Modify is not yet supported for the chain backend
+// Modify is passed to the primary backend, the fallback backend is read-only
 func (h chainHandler) Modify(boundDN string, req ldap.ModifyRequest, conn net.Conn) (result ldap.LDAPResultCode, err error) {
-	return ldap.LDAPResultInsufficientAccessRights, nil
+	return h.b.Modify(boundDN, req, conn)
 }
 
-// Delete is not yet supported for the chain backend
+// Delete is passed to the primary backend, the fallback backend is read-only
 func (h chainHandler) Delete(boundDN string, deleteDN string, conn net.Conn) (result ldap.LDAPResultCode, err error) {
-	return ldap.LDAPResultInsufficientAccessRights, nil
+	return h.b.Delete(boundDN, deleteDN, conn)
 }
 
 // FindUser with the given username. Called by the ldap backend to authenticate the bind. Optional
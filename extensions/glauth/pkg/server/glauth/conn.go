@@ -0,0 +1,100 @@
+package glauth
+
+import (
+	"net"
+	"sync"
+	"time"
+)
+
+// boundRolesTTL is the time the roles of the bound user are cached on a connection.
+const boundRolesTTL = time.Minute
+
+// ldapConn is a client connection of the LDAP server, it keeps the state of the handlers per connection.
+type ldapConn struct {
+	net.Conn
+
+	mu         sync.Mutex
+	boundDN    string
+	roleIDs    []string
+	rolesUntil time.Time
+	// filter is the filter of the search request being handled, the server passes a lossy copy of it to the handlers
+	filter    *filter
+	filterErr error
+	// entry is the entry of the add request being handled, the library doesn't export the values of add requests
+	entry *addEntry
+}
+
+// addEntry is the decoded entry of an add request, the attribute names are lower case.
+type addEntry struct {
+	dn         string
+	attributes map[string][]string
+}
+
+// setSearchFilter sets the filter of the search request handled on the connection.
+func (c *ldapConn) setSearchFilter(f *filter, err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.filter, c.filterErr = f, err
+}
+
+// searchFilter returns the filter of the search request handled on the connection. It returns no filter and no error
+// for connections which are not served by an ldapServer.
+func searchFilter(conn net.Conn) (*filter, error) {
+	c, ok := conn.(*ldapConn)
+	if !ok {
+		return nil, nil
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	f, err := c.filter, c.filterErr
+	c.filter, c.filterErr = nil, nil
+	return f, err
+}
+
+// setAddEntry sets the entry of the add request handled on the connection.
+func (c *ldapConn) setAddEntry(e *addEntry) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entry = e
+}
+
+// addRequestEntry returns the entry of the add request handled on the connection. It returns nil for connections which
+// are not served by an ldapServer.
+func addRequestEntry(conn net.Conn) *addEntry {
+	c, ok := conn.(*ldapConn)
+	if !ok {
+		return nil
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e := c.entry
+	c.entry = nil
+	return e
+}
+
+// cachedRoles returns the roles cached for the bound user.
+func cachedRoles(conn net.Conn, boundDN string) ([]string, bool) {
+	c, ok := conn.(*ldapConn)
+	if !ok {
+		return nil, false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.boundDN != boundDN || time.Now().After(c.rolesUntil) {
+		return nil, false
+	}
+	return c.roleIDs, true
+}
+
+// cacheRoles caches the roles of the bound user on the connection.
+func cacheRoles(conn net.Conn, boundDN string, roleIDs []string) {
+	c, ok := conn.(*ldapConn)
+	if !ok {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.boundDN = boundDN
+	c.roleIDs = roleIDs
+	c.rolesUntil = time.Now().Add(boundRolesTTL)
+}
@@ -0,0 +1,506 @@
+package glauth
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	ber "github.com/nmcclain/asn1-ber"
+	"github.com/nmcclain/ldap"
+)
+
+// errUnsupportedFilter is returned for valid filters which can't be evaluated, e.g. extensible matches.
+var errUnsupportedFilter = errors.New("unsupported filter")
+
+// filter is a parsed LDAP search filter, see RFC 4515. The tag is one of the ldap.Filter* constants.
+type filter struct {
+	tag       uint8
+	children  []*filter
+	attribute string // lower case
+	value     string
+	// the components of a substrings filter
+	initial string
+	any     []string
+	final   string
+}
+
+// objectClasses maps the object classes of the entries to the type of the entries.
+var objectClasses = map[string]queryType{
+	"posixaccount":         usersQuery,
+	"shadowaccount":        usersQuery,
+	"inetorgperson":        usersQuery,
+	"organizationalperson": usersQuery,
+	"person":               usersQuery,
+	"users":                usersQuery,
+	"posixgroup":           groupsQuery,
+	"groupofnames":         groupsQuery,
+	"groups":               groupsQuery,
+}
+
+// indexedAttributes maps the attributes of the entries to the properties of the accounts index which can be queried.
+var indexedAttributes = map[queryType]map[string]string{
+	usersQuery: {
+		"ownclouduuid": "id",
+		// on_premises_sam_account_name is indexed using the lowercase analyzer in ocis-accounts
+		"cn":          "on_premises_sam_account_name",
+		"uid":         "on_premises_sam_account_name",
+		"mail":        "mail",
+		"displayname": "display_name",
+		"uidnumber":   "uid_number",
+	},
+	groupsQuery: {
+		"cn":          "on_premises_sam_account_name",
+		"displayname": "display_name",
+		"gidnumber":   "gid_number",
+	},
+}
+
+// numericAttributes are compared as integers.
+var numericAttributes = map[string]bool{
+	"uidnumber": true,
+	"gidnumber": true,
+}
+
+// dnAttributes are compared as DNs.
+var dnAttributes = map[string]bool{
+	"member":   true,
+	"memberof": true,
+}
+
+// parseFilter parses the string representation of a filter.
+func parseFilter(s string) (*filter, error) {
+	p := &filterParser{s: s}
+	f, err := p.parse()
+	if err != nil {
+		return nil, err
+	}
+	if p.pos != len(s) {
+		return nil, fmt.Errorf("unexpected %q after the filter", s[p.pos:])
+	}
+	return f, nil
+}
+
+type filterParser struct {
+	s   string
+	pos int
+}
+
+func (p *filterParser) parse() (*filter, error) {
+	if p.pos >= len(p.s) || p.s[p.pos] != '(' {
+		return nil, fmt.Errorf("expected ( at position %d", p.pos)
+	}
+	p.pos++
+	if p.pos >= len(p.s) {
+		return nil, errors.New("unexpected end of filter")
+	}
+
+	var f *filter
+	var err error
+	switch p.s[p.pos] {
+	case '&', '|':
+		f = &filter{tag: ldap.FilterAnd}
+		if p.s[p.pos] == '|' {
+			f.tag = ldap.FilterOr
+		}
+		p.pos++
+		for p.pos < len(p.s) && p.s[p.pos] == '(' {
+			child, err := p.parse()
+			if err != nil {
+				return nil, err
+			}
+			f.children = append(f.children, child)
+		}
+	case '!':
+		p.pos++
+		child, err := p.parse()
+		if err != nil {
+			return nil, err
+		}
+		f = &filter{tag: ldap.FilterNot, children: []*filter{child}}
+	default:
+		if f, err = p.parseItem(); err != nil {
+			return nil, err
+		}
+	}
+
+	if p.pos >= len(p.s) || p.s[p.pos] != ')' {
+		return nil, fmt.Errorf("expected ) at position %d", p.pos)
+	}
+	p.pos++
+	return f, nil
+}
+
+func (p *filterParser) parseItem() (*filter, error) {
+	end := strings.IndexByte(p.s[p.pos:], ')')
+	if end < 0 {
+		return nil, errors.New("unexpected end of filter")
+	}
+	item := p.s[p.pos : p.pos+end]
+	p.pos += end
+
+	i := strings.IndexByte(item, '=')
+	if i < 1 {
+		return nil, fmt.Errorf("invalid filter item %q", item)
+	}
+	attribute, value := item[:i], item[i+1:]
+	if strings.ContainsAny(value, "(") {
+		return nil, fmt.Errorf("invalid filter value %q", value)
+	}
+
+	f := &filter{tag: ldap.FilterEqualityMatch}
+	switch attribute[len(attribute)-1] {
+	case '>':
+		f.tag = ldap.FilterGreaterOrEqual
+		attribute = attribute[:len(attribute)-1]
+	case '<':
+		f.tag = ldap.FilterLessOrEqual
+		attribute = attribute[:len(attribute)-1]
+	case '~':
+		f.tag = ldap.FilterApproxMatch
+		attribute = attribute[:len(attribute)-1]
+	case ':':
+		return nil, fmt.Errorf("%w: extensible match %q", errUnsupportedFilter, item)
+	}
+	if attribute == "" || strings.ContainsAny(attribute, ":*\\ ") {
+		return nil, fmt.Errorf("invalid attribute description %q", attribute)
+	}
+	f.attribute = strings.ToLower(attribute)
+
+	if f.tag != ldap.FilterEqualityMatch || !strings.Contains(value, "*") {
+		v, err := unescapeFilterValue(value)
+		if err != nil {
+			return nil, err
+		}
+		f.value = v
+		return f, nil
+	}
+	if value == "*" {
+		f.tag = ldap.FilterPresent
+		return f, nil
+	}
+
+	f.tag = ldap.FilterSubstrings
+	parts := strings.Split(value, "*")
+	for i, part := range parts {
+		v, err := unescapeFilterValue(part)
+		if err != nil {
+			return nil, err
+		}
+		switch {
+		case i == 0:
+			f.initial = v
+		case i == len(parts)-1:
+			f.final = v
+		case v != "":
+			f.any = append(f.any, v)
+		}
+	}
+	return f, nil
+}
+
+// unescapeFilterValue replaces the \XX escapes of a filter value by the escaped bytes.
+func unescapeFilterValue(value string) (string, error) {
+	if !strings.Contains(value, "\\") {
+		return value, nil
+	}
+	var b strings.Builder
+	for i := 0; i < len(value); i++ {
+		if value[i] != '\\' {
+			b.WriteByte(value[i])
+			continue
+		}
+		if i+3 > len(value) {
+			return "", fmt.Errorf("invalid escape in filter value %q", value)
+		}
+		c, err := hex.DecodeString(value[i+1 : i+3])
+		if err != nil {
+			return "", fmt.Errorf("invalid escape in filter value %q", value)
+		}
+		b.Write(c)
+		i += 2
+	}
+	return b.String(), nil
+}
+
+// decodeFilter decodes a filter of a search request, see RFC 4511 section 4.5.1.7.
+func decodeFilter(p *ber.Packet) (*filter, error) {
+	f := &filter{tag: p.Tag}
+	switch p.Tag {
+	case ldap.FilterAnd, ldap.FilterOr, ldap.FilterNot:
+		for _, c := range p.Children {
+			child, err := decodeFilter(c)
+			if err != nil {
+				return nil, err
+			}
+			f.children = append(f.children, child)
+		}
+		if p.Tag == ldap.FilterNot && len(f.children) != 1 {
+			return nil, errors.New("not filter must have exactly one child")
+		}
+	case ldap.FilterEqualityMatch, ldap.FilterGreaterOrEqual, ldap.FilterLessOrEqual, ldap.FilterApproxMatch:
+		if len(p.Children) != 2 {
+			return nil, fmt.Errorf("%s filter must have exactly two children", ldap.FilterMap[p.Tag])
+		}
+		f.attribute = strings.ToLower(p.Children[0].Data.String())
+		f.value = p.Children[1].Data.String()
+	case ldap.FilterSubstrings:
+		if len(p.Children) != 2 {
+			return nil, errors.New("substrings filter must have exactly two children")
+		}
+		f.attribute = strings.ToLower(p.Children[0].Data.String())
+		for _, c := range p.Children[1].Children {
+			switch c.Tag {
+			case ldap.FilterSubstringsInitial:
+				f.initial = c.Data.String()
+			case ldap.FilterSubstringsAny:
+				f.any = append(f.any, c.Data.String())
+			case ldap.FilterSubstringsFinal:
+				f.final = c.Data.String()
+			}
+		}
+	case ldap.FilterPresent:
+		f.attribute = strings.ToLower(p.Data.String())
+	default:
+		return nil, fmt.Errorf("%w: %s", errUnsupportedFilter, ldap.FilterMap[p.Tag])
+	}
+	return f, nil
+}
+
+// matchesType returns false if the filter can't match any entry of the given type.
+func (f *filter) matchesType(t queryType) bool {
+	switch f.tag {
+	case ldap.FilterAnd:
+		for _, c := range f.children {
+			if !c.matchesType(t) {
+				return false
+			}
+		}
+		return true
+	case ldap.FilterOr:
+		for _, c := range f.children {
+			if c.matchesType(t) {
+				return true
+			}
+		}
+		return false
+	case ldap.FilterEqualityMatch, ldap.FilterApproxMatch:
+		if f.attribute == "objectclass" {
+			v := strings.ToLower(f.value)
+			return v == "top" || objectClasses[v] == t
+		}
+	}
+	return true
+}
+
+// indexQuery is a query of the accounts index. The index only supports `eq`, `or` and `startswith` on a few
+// properties, so the query selects a superset of the entries matching a filter, which are then matched one by one.
+type indexQuery struct {
+	// all is set if every entry may match the filter
+	all bool
+	// query selects the entries, if all isn't set. An empty query selects no entry.
+	query string
+}
+
+var (
+	allEntries = indexQuery{all: true}
+	noEntries  = indexQuery{}
+)
+
+// compileFilter returns the query for the entries of type t which may match the filter. memberOf filters are
+// resolved to the IDs of the members of the group.
+func (h ocisHandler) compileFilter(ctx context.Context, f *filter, t queryType) (indexQuery, error) {
+	switch f.tag {
+	case ldap.FilterAnd:
+		// the index can't intersect, any restricting child selects a superset
+		q := allEntries
+		for _, c := range f.children {
+			cq, err := h.compileFilter(ctx, c, t)
+			if err != nil {
+				return noEntries, err
+			}
+			if !cq.all && cq.query == "" {
+				return noEntries, nil
+			}
+			if q.all && !cq.all {
+				q = cq
+			}
+		}
+		return q, nil
+	case ldap.FilterOr:
+		queries := []string{}
+		for _, c := range f.children {
+			cq, err := h.compileFilter(ctx, c, t)
+			if err != nil {
+				return noEntries, err
+			}
+			if cq.all {
+				return allEntries, nil
+			}
+			if cq.query != "" {
+				queries = append(queries, cq.query)
+			}
+		}
+		return indexQuery{query: strings.Join(queries, " or ")}, nil
+	case ldap.FilterEqualityMatch, ldap.FilterApproxMatch:
+		if !f.matchesType(t) {
+			return noEntries, nil
+		}
+		if f.attribute == "memberof" {
+			return h.compileMemberOf(ctx, f.value, t)
+		}
+		property, ok := indexedAttributes[t][f.attribute]
+		if !ok {
+			return allEntries, nil
+		}
+		if numericAttributes[f.attribute] {
+			i, err := strconv.ParseUint(f.value, 10, 64)
+			if err != nil {
+				return noEntries, nil
+			}
+			return indexQuery{query: fmt.Sprintf("%s eq %d", property, i)}, nil
+		}
+		return indexQuery{query: fmt.Sprintf("%s eq '%s'", property, escapeValue(f.value))}, nil
+	case ldap.FilterSubstrings:
+		property, ok := indexedAttributes[t][f.attribute]
+		if !ok || f.initial == "" || numericAttributes[f.attribute] {
+			return allEntries, nil
+		}
+		return indexQuery{query: fmt.Sprintf("startswith(%s,'%s')", property, escapeValue(f.initial))}, nil
+	}
+	// presence, ordering and negation can't be expressed
+	return allEntries, nil
+}
+
+// compileMemberOf returns the query for the members of the group with the given DN.
+func (h ocisHandler) compileMemberOf(ctx context.Context, groupDN string, t queryType) (indexQuery, error) {
+	if t != usersQuery {
+		return noEntries, nil
+	}
+	name, qtype, code := h.parseDN(groupDN)
+	if code != ldap.LDAPResultSuccess || qtype != groupsQuery {
+		return noEntries, nil
+	}
+	g, code := h.getGroup(ctx, name)
+	switch code {
+	case ldap.LDAPResultSuccess:
+	case ldap.LDAPResultNoSuchObject:
+		return noEntries, nil
+	default:
+		return noEntries, ldap.NewError(code, fmt.Errorf("could not get group %s", name))
+	}
+	queries := make([]string, 0, len(g.Members))
+	for _, m := range g.Members {
+		queries = append(queries, fmt.Sprintf("id eq '%s'", escapeValue(m.Id)))
+	}
+	return indexQuery{query: strings.Join(queries, " or ")}, nil
+}
+
+// matchEntry evaluates the filter for an entry. The attribute values are compared case insensitive.
+func matchEntry(f *filter, e *ldap.Entry) bool {
+	switch f.tag {
+	case ldap.FilterAnd:
+		for _, c := range f.children {
+			if !matchEntry(c, e) {
+				return false
+			}
+		}
+		return true
+	case ldap.FilterOr:
+		for _, c := range f.children {
+			if matchEntry(c, e) {
+				return true
+			}
+		}
+		return false
+	case ldap.FilterNot:
+		return !matchEntry(f.children[0], e)
+	case ldap.FilterPresent:
+		return len(entryValues(e, f.attribute)) > 0
+	}
+
+	for _, v := range entryValues(e, f.attribute) {
+		switch f.tag {
+		case ldap.FilterEqualityMatch, ldap.FilterApproxMatch:
+			if compareValues(f.attribute, v, f.value) == 0 {
+				return true
+			}
+		case ldap.FilterGreaterOrEqual:
+			if compareValues(f.attribute, v, f.value) >= 0 {
+				return true
+			}
+		case ldap.FilterLessOrEqual:
+			if compareValues(f.attribute, v, f.value) <= 0 {
+				return true
+			}
+		case ldap.FilterSubstrings:
+			if matchSubstrings(f, v) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func entryValues(e *ldap.Entry, attribute string) []string {
+	for _, a := range e.Attributes {
+		if strings.EqualFold(a.Name, attribute) {
+			return a.Values
+		}
+	}
+	return nil
+}
+
+// compareValues compares an attribute value with an assertion value according to the syntax of the attribute.
+func compareValues(attribute, value, assertion string) int {
+	if numericAttributes[attribute] {
+		v, err1 := strconv.ParseInt(value, 10, 64)
+		a, err2 := strconv.ParseInt(assertion, 10, 64)
+		if err1 == nil && err2 == nil {
+			switch {
+			case v < a:
+				return -1
+			case v > a:
+				return 1
+			}
+			return 0
+		}
+	}
+	if dnAttributes[attribute] {
+		return strings.Compare(normalizeDN(value), normalizeDN(assertion))
+	}
+	return strings.Compare(strings.ToLower(value), strings.ToLower(assertion))
+}
+
+func matchSubstrings(f *filter, value string) bool {
+	value = strings.ToLower(value)
+	initial := strings.ToLower(f.initial)
+	if !strings.HasPrefix(value, initial) {
+		return false
+	}
+	value = value[len(initial):]
+	for _, a := range f.any {
+		a = strings.ToLower(a)
+		i := strings.Index(value, a)
+		if i < 0 {
+			return false
+		}
+		value = value[i+len(a):]
+	}
+	return strings.HasSuffix(value, strings.ToLower(f.final))
+}
+
+// normalizeDN returns the lower case DN without spaces around the separators.
+func normalizeDN(dn string) string {
+	rdns := strings.Split(dn, ",")
+	for i, rdn := range rdns {
+		parts := strings.SplitN(rdn, "=", 2)
+		for j := range parts {
+			parts[j] = strings.TrimSpace(parts[j])
+		}
+		rdns[i] = strings.ToLower(strings.Join(parts, "="))
+	}
+	return strings.Join(rdns, ",")
+}
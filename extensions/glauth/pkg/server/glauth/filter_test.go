@@ -0,0 +1,141 @@
+package glauth
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/nmcclain/ldap"
+	"github.com/owncloud/ocis/ocis-pkg/log"
+	accountsmsg "github.com/owncloud/ocis/protogen/gen/ocis/messages/accounts/v0"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseFilter(t *testing.T) {
+	for s, want := range map[string]*filter{
+		"(cn=einstein)":   {tag: ldap.FilterEqualityMatch, attribute: "cn", value: "einstein"},
+		"(UID=*)":         {tag: ldap.FilterPresent, attribute: "uid"},
+		"(cn=a*b*c*d)":    {tag: ldap.FilterSubstrings, attribute: "cn", initial: "a", any: []string{"b", "c"}, final: "d"},
+		"(cn=*in)":        {tag: ldap.FilterSubstrings, attribute: "cn", final: "in"},
+		`(cn=\28x\29)`:    {tag: ldap.FilterEqualityMatch, attribute: "cn", value: "(x)"},
+		"(uidNumber>=10)": {tag: ldap.FilterGreaterOrEqual, attribute: "uidnumber", value: "10"},
+		"(!(cn~=a))": {tag: ldap.FilterNot, children: []*filter{
+			{tag: ldap.FilterApproxMatch, attribute: "cn", value: "a"},
+		}},
+		"(&(objectClass=posixAccount)(|(cn=a)(mail=b)))": {tag: ldap.FilterAnd, children: []*filter{
+			{tag: ldap.FilterEqualityMatch, attribute: "objectclass", value: "posixAccount"},
+			{tag: ldap.FilterOr, children: []*filter{
+				{tag: ldap.FilterEqualityMatch, attribute: "cn", value: "a"},
+				{tag: ldap.FilterEqualityMatch, attribute: "mail", value: "b"},
+			}},
+		}},
+		"(&)": {tag: ldap.FilterAnd},
+	} {
+		f, err := parseFilter(s)
+		require.NoError(t, err, s)
+		assert.Equal(t, want, f, s)
+	}
+
+	for _, s := range []string{"", "cn=a", "(cn=a", "(cn=a))", "(=a)", `(cn=\2)`, `(cn=\zz)`, "(&(cn=a)", "(c n=a)"} {
+		_, err := parseFilter(s)
+		assert.Error(t, err, s)
+	}
+
+	_, err := parseFilter("(cn:dn:=einstein)")
+	assert.True(t, errors.Is(err, errUnsupportedFilter))
+}
+
+func TestCompileFilter(t *testing.T) {
+	d := newDirectory()
+	d.groups["physicists-id"] = &accountsmsg.Group{
+		Id:                       "physicists-id",
+		OnPremisesSamAccountName: "physicists",
+		Members:                  []*accountsmsg.Account{d.accounts["einstein-id"], d.accounts["marie-id"]},
+	}
+	h := ocisHandler{
+		gs:          groupsService{d: d},
+		log:         log.NewLogger(log.Level("error")),
+		basedn:      testBaseDN,
+		nameFormat:  "cn",
+		groupFormat: "ou",
+	}
+
+	for s, want := range map[string]struct {
+		users  indexQuery
+		groups indexQuery
+	}{
+		// reva
+		"(&(objectclass=posixAccount)(|(ownclouduuid=1234)(cn=1234)))": {
+			users:  indexQuery{query: "id eq '1234' or on_premises_sam_account_name eq '1234'"},
+			groups: noEntries,
+		},
+		// nextcloud
+		"(&(|(objectclass=inetOrgPerson))(|(uid=ein*)(mail=ein*)(displayname=ein*)))": {
+			users:  indexQuery{query: "startswith(on_premises_sam_account_name,'ein') or startswith(mail,'ein') or startswith(display_name,'ein')"},
+			groups: noEntries,
+		},
+		// sssd
+		"(&(uidNumber=20000)(objectclass=posixAccount)(uid=*)(&(uidNumber=*)(!(uidNumber=0))))": {
+			users:  indexQuery{query: "uid_number eq 20000"},
+			groups: noEntries,
+		},
+		// thunderbird address book
+		"(|(cn=*ein*)(mail=*ein*))": {users: allEntries, groups: allEntries},
+		"(&(objectClass=posixGroup)(gidNumber=30000))": {
+			users:  noEntries,
+			groups: indexQuery{query: "gid_number eq 30000"},
+		},
+		"(&(objectClass=posixAccount)(memberOf=cn=physicists,ou=groups,dc=ocis,dc=test))": {
+			users:  indexQuery{query: "id eq 'einstein-id' or id eq 'marie-id'"},
+			groups: noEntries,
+		},
+		"(memberOf=cn=unknown,ou=groups,dc=ocis,dc=test)": {users: noEntries, groups: noEntries},
+		"(uidNumber=abc)": {users: noEntries, groups: allEntries},
+		`(cn=o\27hara)`:   {users: indexQuery{query: "on_premises_sam_account_name eq 'o''hara'"}, groups: indexQuery{query: "on_premises_sam_account_name eq 'o''hara'"}},
+		"(&)":             {users: allEntries, groups: allEntries},
+		"(|)":             {users: noEntries, groups: noEntries},
+	} {
+		f, err := parseFilter(s)
+		require.NoError(t, err, s)
+		users, err := h.compileFilter(context.Background(), f, usersQuery)
+		require.NoError(t, err, s)
+		assert.Equal(t, want.users, users, s)
+		groups, err := h.compileFilter(context.Background(), f, groupsQuery)
+		require.NoError(t, err, s)
+		assert.Equal(t, want.groups, groups, s)
+	}
+}
+
+func TestMatchEntry(t *testing.T) {
+	e := &ldap.Entry{
+		DN: "cn=einstein,ou=users,dc=ocis,dc=test",
+		Attributes: []*ldap.EntryAttribute{
+			attribute("objectClass", "posixAccount", "inetOrgPerson"),
+			attribute("cn", "einstein"),
+			attribute("mail", "Einstein@example.org"),
+			attribute("uidnumber", "20000"),
+			attribute("memberOf", "cn=physicists,ou=groups,dc=ocis,dc=test"),
+		},
+	}
+	for s, want := range map[string]bool{
+		"(objectClass=inetorgperson)": true,
+		"(objectClass=posixGroup)":    false,
+		"(mail=einstein@EXAMPLE.org)": true,
+		"(cn=ein*st*n)":               true,
+		"(cn=ein*in*n)":               false,
+		"(cn=*stein)":                 true,
+		"(uidNumber>=9000)":           true,
+		"(uidNumber<=9000)":           false,
+		"(&(uid=*)(cn=einstein))":     false,
+		"(|(uid=*)(cn=einstein))":     true,
+		"(!(mail=*))":                 false,
+		"(memberOf=CN=physicists, OU=groups,DC=ocis,DC=test)": true,
+		"(&)": true,
+		"(|)": false,
+	} {
+		f, err := parseFilter(s)
+		require.NoError(t, err, s)
+		assert.Equal(t, want, matchEntry(f, e), s)
+	}
+}
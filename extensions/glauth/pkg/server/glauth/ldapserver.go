@@ -0,0 +1,416 @@
+package glauth
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+
+	"github.com/go-logr/logr"
+	ber "github.com/nmcclain/asn1-ber"
+	"github.com/nmcclain/ldap"
+)
+
+// noticeOfDisconnectionOID is the name of the notice of disconnection, see RFC 4511 section 4.4.1
+const noticeOfDisconnectionOID = "1.3.6.1.4.1.1466.20036"
+
+// ldapServer serves the handlers registered on an ldap.Server. It is a fork of the connection handling of
+// github.com/nmcclain/ldap, which always reports successful searches without controls and only passes a lossy copy
+// of the search filter to the handlers. The result code and the controls returned by the search handlers are sent
+// with the search result done message, the decoded filter is kept on the connection. The entry of add requests, which
+// the library doesn't expose, is decoded and kept on the connection as well. All other operations are handled by the
+// functions of the library. Statistics are not collected.
+type ldapServer struct {
+	*ldap.Server
+	log logr.Logger
+}
+
+// Serve accepts connections on the listener until a value is sent to the quit channel of the server.
+func (s ldapServer) Serve(ln net.Listener) error {
+	newConn := make(chan net.Conn)
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				if !errors.Is(err, net.ErrClosed) {
+					s.log.Error(err, "Error accepting network connection")
+				}
+				break
+			}
+			newConn <- conn
+		}
+	}()
+
+	for {
+		select {
+		case c := <-newConn:
+			go s.handleConnection(&ldapConn{Conn: c})
+		case <-s.Quit:
+			return ln.Close()
+		}
+	}
+}
+
+func (s ldapServer) handleConnection(conn *ldapConn) {
+	boundDN := "" // "" == anonymous
+
+handler:
+	for {
+		packet, err := ber.ReadPacket(conn)
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			s.log.V(3).Info("could not read packet", "error", err.Error())
+			break
+		}
+
+		if len(packet.Children) < 2 {
+			s.log.V(3).Info("malformed packet")
+			break
+		}
+		messageID, ok := packet.Children[0].Value.(uint64)
+		if !ok {
+			s.log.V(3).Info("malformed message id")
+			break
+		}
+		req := packet.Children[1]
+		if req.ClassType != ber.ClassApplication {
+			s.log.V(3).Info("malformed request")
+			break
+		}
+		controls := []ldap.Control{}
+		if len(packet.Children) > 2 {
+			for _, child := range packet.Children[2].Children {
+				controls = append(controls, ldap.DecodeControl(child))
+			}
+		}
+
+		var response *ber.Packet
+		switch req.Tag {
+		default:
+			// the operation is unknown, so there is no response to send. The client is told why it is disconnected.
+			s.log.V(3).Info("Unhandled operation", "tag", req.Tag)
+			_ = s.send(conn, encodeNoticeOfDisconnection(ldap.LDAPResultUnwillingToPerform, "Unsupported operation"))
+			break handler
+		case ldap.ApplicationBindRequest:
+			code := ldap.HandleBindRequest(req, s.BindFns, conn)
+			if code == ldap.LDAPResultSuccess {
+				if boundDN, ok = req.Children[1].Value.(string); !ok {
+					s.log.V(3).Info("malformed bind DN")
+					break handler
+				}
+			}
+			response = encodeLDAPResponse(messageID, ldap.ApplicationBindResponse, code, "")
+		case ldap.ApplicationSearchRequest:
+			res, err := s.handleSearchRequest(req, controls, boundDN, conn)
+			if err != nil {
+				s.log.V(3).Info("search failed", "error", err.Error())
+				_ = s.send(conn, encodeSearchDone(messageID, res.ResultCode, nil))
+				break handler
+			}
+			for _, e := range res.Entries {
+				if err := s.send(conn, encodeSearchEntry(messageID, e)); err != nil {
+					break handler
+				}
+			}
+			response = encodeSearchDone(messageID, res.ResultCode, res.Controls)
+		case ldap.ApplicationUnbindRequest:
+			break handler
+		case ldap.ApplicationExtendedRequest:
+			code := ldap.HandleExtendedRequest(req, boundDN, s.ExtendedFns, conn)
+			response = encodeLDAPResponse(messageID, ldap.ApplicationExtendedResponse, code, ldap.LDAPResultCodeMap[code])
+		case ldap.ApplicationAbandonRequest:
+			_ = ldap.HandleAbandonRequest(req, boundDN, s.AbandonFns, conn)
+			break handler
+		case ldap.ApplicationAddRequest:
+			entry, code, err := parseAddRequest(req)
+			if err == nil {
+				conn.setAddEntry(entry)
+				code = ldap.HandleAddRequest(req, boundDN, s.AddFns, conn)
+			}
+			response = encodeLDAPResponse(messageID, ldap.ApplicationAddResponse, code, ldap.LDAPResultCodeMap[code])
+		case ldap.ApplicationModifyRequest:
+			code := ldap.HandleModifyRequest(req, boundDN, s.ModifyFns, conn)
+			response = encodeLDAPResponse(messageID, ldap.ApplicationModifyResponse, code, ldap.LDAPResultCodeMap[code])
+		case ldap.ApplicationDelRequest:
+			code := ldap.HandleDeleteRequest(req, boundDN, s.DeleteFns, conn)
+			response = encodeLDAPResponse(messageID, ldap.ApplicationDelResponse, code, ldap.LDAPResultCodeMap[code])
+		case ldap.ApplicationModifyDNRequest:
+			code := ldap.HandleModifyDNRequest(req, boundDN, s.ModifyDNFns, conn)
+			response = encodeLDAPResponse(messageID, ldap.ApplicationModifyDNResponse, code, ldap.LDAPResultCodeMap[code])
+		case ldap.ApplicationCompareRequest:
+			code := ldap.HandleCompareRequest(req, boundDN, s.CompareFns, conn)
+			response = encodeLDAPResponse(messageID, ldap.ApplicationCompareResponse, code, ldap.LDAPResultCodeMap[code])
+		}
+		if err := s.send(conn, response); err != nil {
+			break
+		}
+	}
+
+	for _, c := range s.CloseFns {
+		_ = c.Close(boundDN, conn)
+	}
+	conn.Close()
+}
+
+// handleSearchRequest passes a search request to the handler of its base DN and returns the entries to send. Like
+// the library it applies the filter, the scope, the requested attributes and the size limit to the returned entries
+// if the server enforces LDAP. An error is returned with the result code of the failed search.
+func (s ldapServer) handleSearchRequest(req *ber.Packet, controls []ldap.Control, boundDN string, conn *ldapConn) (res ldap.ServerSearchResult, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			res, err = ldap.ServerSearchResult{ResultCode: ldap.LDAPResultOperationsError}, fmt.Errorf("search function panic: %s", r)
+		}
+	}()
+
+	searchReq, code, err := parseSearchRequest(req, controls)
+	if err != nil {
+		return ldap.ServerSearchResult{ResultCode: code}, err
+	}
+	conn.setSearchFilter(decodeFilter(req.Children[6]))
+
+	filterPacket, err := ldap.CompileFilter(searchReq.Filter)
+	if err != nil {
+		return ldap.ServerSearchResult{ResultCode: ldap.LDAPResultOperationsError}, err
+	}
+
+	fnNames := make([]string, 0, len(s.SearchFns))
+	for k := range s.SearchFns {
+		fnNames = append(fnNames, k)
+	}
+	res, err = s.SearchFns[routeFunc(searchReq.BaseDN, fnNames)].Search(boundDN, searchReq, conn)
+	if err != nil {
+		return res, err
+	}
+	if !s.EnforceLDAP {
+		return res, nil
+	}
+
+	entries := make([]*ldap.Entry, 0, len(res.Entries))
+	baseDN := strings.ToLower(searchReq.BaseDN)
+	for _, entry := range res.Entries {
+		keep, code := ldap.ServerApplyFilter(filterPacket, entry)
+		if code != ldap.LDAPResultSuccess {
+			return ldap.ServerSearchResult{ResultCode: code}, errors.New("ServerApplyFilter error")
+		}
+		if !keep || !inScope(strings.ToLower(entry.DN), baseDN, searchReq.Scope) {
+			continue
+		}
+		if searchReq.SizeLimit > 0 && len(entries) >= searchReq.SizeLimit {
+			break
+		}
+		entries = append(entries, filterAttributes(entry, searchReq.Attributes))
+	}
+	res.Entries = entries
+	return res, nil
+}
+
+func (s ldapServer) send(conn net.Conn, packet *ber.Packet) error {
+	if _, err := conn.Write(packet.Bytes()); err != nil {
+		s.log.V(3).Info("could not send packet", "error", err.Error())
+		return err
+	}
+	return nil
+}
+
+// parseAddRequest decodes the entry of an add request, the library only passes it to the handlers in unexported fields.
+func parseAddRequest(req *ber.Packet) (*addEntry, ldap.LDAPResultCode, error) {
+	errBadRequest := errors.New("bad add request")
+	if len(req.Children) != 2 {
+		return nil, ldap.LDAPResultProtocolError, errBadRequest
+	}
+	dn, ok := req.Children[0].Value.(string)
+	if !ok {
+		return nil, ldap.LDAPResultProtocolError, errBadRequest
+	}
+	entry := &addEntry{dn: dn, attributes: map[string][]string{}}
+	for _, attr := range req.Children[1].Children {
+		if len(attr.Children) != 2 {
+			return nil, ldap.LDAPResultProtocolError, errBadRequest
+		}
+		name, ok := attr.Children[0].Value.(string)
+		if !ok {
+			return nil, ldap.LDAPResultProtocolError, errBadRequest
+		}
+		name = strings.ToLower(name)
+		for _, val := range attr.Children[1].Children {
+			v, ok := val.Value.(string)
+			if !ok {
+				return nil, ldap.LDAPResultProtocolError, errBadRequest
+			}
+			entry.attributes[name] = append(entry.attributes[name], v)
+		}
+	}
+	return entry, ldap.LDAPResultSuccess, nil
+}
+
+func parseSearchRequest(req *ber.Packet, controls []ldap.Control) (ldap.SearchRequest, ldap.LDAPResultCode, error) {
+	errBadRequest := errors.New("bad search request")
+	if len(req.Children) != 8 {
+		return ldap.SearchRequest{}, ldap.LDAPResultOperationsError, errBadRequest
+	}
+	baseDN, ok := req.Children[0].Value.(string)
+	if !ok {
+		return ldap.SearchRequest{}, ldap.LDAPResultProtocolError, errBadRequest
+	}
+	var values [4]int
+	for i := range values {
+		v, ok := req.Children[i+1].Value.(uint64)
+		if !ok {
+			return ldap.SearchRequest{}, ldap.LDAPResultProtocolError, errBadRequest
+		}
+		values[i] = int(v)
+	}
+	typesOnly := false
+	if req.Children[5].Value != nil {
+		if typesOnly, ok = req.Children[5].Value.(bool); !ok {
+			return ldap.SearchRequest{}, ldap.LDAPResultProtocolError, errBadRequest
+		}
+	}
+	filter, err := ldap.DecompileFilter(req.Children[6])
+	if err != nil {
+		return ldap.SearchRequest{}, ldap.LDAPResultOperationsError, err
+	}
+	attributes := []string{}
+	for _, attr := range req.Children[7].Children {
+		a, ok := attr.Value.(string)
+		if !ok {
+			return ldap.SearchRequest{}, ldap.LDAPResultProtocolError, errBadRequest
+		}
+		attributes = append(attributes, a)
+	}
+	return ldap.SearchRequest{
+		BaseDN:       baseDN,
+		Scope:        values[0],
+		DerefAliases: values[1],
+		SizeLimit:    values[2],
+		TimeLimit:    values[3],
+		TypesOnly:    typesOnly,
+		Filter:       filter,
+		Attributes:   attributes,
+		Controls:     controls,
+	}, ldap.LDAPResultSuccess, nil
+}
+
+// inScope returns whether the lower case DN is in the scope of a search with the lower case base DN.
+func inScope(dn, baseDN string, scope int) bool {
+	switch scope {
+	case ldap.ScopeBaseObject:
+		return dn == baseDN
+	case ldap.ScopeSingleLevel:
+		parts := strings.SplitN(dn, ",", 2)
+		return len(parts) == 2 && parts[1] == baseDN
+	default:
+		return true
+	}
+}
+
+// filterAttributes only keeps the requested attributes of an entry. Operational attributes, which are prefixed with
+// a "+", are only returned if they are requested.
+func filterAttributes(entry *ldap.Entry, attributes []string) *ldap.Entry {
+	kept := []*ldap.EntryAttribute{}
+	if len(attributes) > 1 || (len(attributes) == 1 && len(attributes[0]) > 0) {
+		for _, attr := range entry.Attributes {
+			name := strings.ToLower(attr.Name)
+			for _, requested := range attributes {
+				requested = strings.ToLower(requested)
+				if strings.HasPrefix(name, "+") {
+					if requested == "+" || name == "+"+requested {
+						kept = append(kept, &ldap.EntryAttribute{Name: attr.Name[1:], Values: attr.Values})
+						break
+					}
+				} else if requested == "*" || name == requested {
+					kept = append(kept, attr)
+					break
+				}
+			}
+		}
+	} else {
+		for _, attr := range entry.Attributes {
+			if !strings.HasPrefix(attr.Name, "+") {
+				kept = append(kept, attr)
+			}
+		}
+	}
+	entry.Attributes = kept
+	return entry
+}
+
+// routeFunc returns the handler base DN with most components which is a suffix of the DN.
+func routeFunc(dn string, funcNames []string) string {
+	bestPick := ""
+	bestPickWeight := 0
+	dnMatch := "," + strings.ToLower(dn)
+	for _, fn := range funcNames {
+		if !strings.HasSuffix(dnMatch, ","+fn) {
+			continue
+		}
+		weight := 0
+		if fn != "" {
+			weight = strings.Count(fn, ",") + 1
+		}
+		if weight > bestPickWeight {
+			bestPick = fn
+			bestPickWeight = weight
+		}
+	}
+	return bestPick
+}
+
+func encodeLDAPResponse(messageID uint64, responseType uint8, code ldap.LDAPResultCode, message string) *ber.Packet {
+	p := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "LDAP Response")
+	p.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagInteger, messageID, "Message ID"))
+	response := ber.Encode(ber.ClassApplication, ber.TypeConstructed, responseType, nil, ldap.ApplicationMap[responseType])
+	response.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagEnumerated, uint64(code), "resultCode: "))
+	response.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, "", "matchedDN: "))
+	response.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, message, "errorMessage: "))
+	p.AppendChild(response)
+	return p
+}
+
+// encodeNoticeOfDisconnection returns the unsolicited notification sent before the server closes a connection.
+func encodeNoticeOfDisconnection(code ldap.LDAPResultCode, message string) *ber.Packet {
+	p := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "LDAP Response")
+	p.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagInteger, uint64(0), "Message ID"))
+	response := ber.Encode(ber.ClassApplication, ber.TypeConstructed, ldap.ApplicationExtendedResponse, nil, "Notice of Disconnection")
+	response.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagEnumerated, uint64(code), "resultCode: "))
+	response.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, "", "matchedDN: "))
+	response.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, message, "errorMessage: "))
+	response.AppendChild(ber.NewString(ber.ClassContext, ber.TypePrimitive, 10, noticeOfDisconnectionOID, "responseName: "))
+	p.AppendChild(response)
+	return p
+}
+
+func encodeSearchEntry(messageID uint64, e *ldap.Entry) *ber.Packet {
+	p := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "LDAP Response")
+	p.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagInteger, messageID, "Message ID"))
+	entry := ber.Encode(ber.ClassApplication, ber.TypeConstructed, ldap.ApplicationSearchResultEntry, nil, "Search Result Entry")
+	entry.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, e.DN, "Object Name"))
+	attrs := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "Attributes:")
+	for _, a := range e.Attributes {
+		attr := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "Attribute")
+		attr.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, a.Name, "Attribute Name"))
+		values := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSet, nil, "Attribute Values")
+		for _, v := range a.Values {
+			values.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, v, "Attribute Value"))
+		}
+		attr.AppendChild(values)
+		attrs.AppendChild(attr)
+	}
+	entry.AppendChild(attrs)
+	p.AppendChild(entry)
+	return p
+}
+
+func encodeSearchDone(messageID uint64, code ldap.LDAPResultCode, controls []ldap.Control) *ber.Packet {
+	p := encodeLDAPResponse(messageID, ldap.ApplicationSearchResultDone, code, "")
+	if len(controls) > 0 {
+		c := ber.Encode(ber.ClassContext, ber.TypeConstructed, 0, nil, "Controls")
+		for _, control := range controls {
+			c.AppendChild(control.Encode())
+		}
+		p.AppendChild(c)
+	}
+	return p
+}
@@ -3,10 +3,12 @@ package glauth
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net"
 	"strconv"
 	"strings"
+	"time"
 
 	accountsmsg "github.com/owncloud/ocis/protogen/gen/ocis/messages/accounts/v0"
 	accountssvc "github.com/owncloud/ocis/protogen/gen/ocis/services/accounts/v0"
@@ -14,10 +16,10 @@ import (
 	"github.com/glauth/glauth/v2/pkg/config"
 	"github.com/glauth/glauth/v2/pkg/handler"
 	"github.com/glauth/glauth/v2/pkg/stats"
-	ber "github.com/nmcclain/asn1-ber"
 	"github.com/nmcclain/ldap"
 	"github.com/owncloud/ocis/ocis-pkg/log"
 	"github.com/owncloud/ocis/ocis-pkg/middleware"
+	"github.com/owncloud/ocis/ocis-pkg/roles"
 	"go-micro.dev/v4/metadata"
 )
 
@@ -36,6 +38,7 @@ type ocisHandler struct {
 	nameFormat  string
 	groupFormat string
 	rbid        string
+	rm          *roles.Manager
 }
 
 func (h ocisHandler) Bind(bindDN, bindSimplePw string, conn net.Conn) (ldap.LDAPResultCode, error) {
@@ -145,113 +148,155 @@ func (h ocisHandler) Search(bindDN string, searchReq ldap.SearchRequest, conn ne
 		}, fmt.Errorf("search error: search BaseDN %s is not in our BaseDN %s", searchBaseDN, h.basedn)
 	}
 
-	var qtype queryType = ""
-	query := ""
-	var code ldap.LDAPResultCode
-	var err error
-	if searchReq.Filter == "(&)" { // see Absolute True and False Filters in https://tools.ietf.org/html/rfc4526#section-2
-		query = ""
-	} else {
-		var cf *ber.Packet
-		cf, err = ldap.CompileFilter(searchReq.Filter)
-		if err != nil {
-			h.log.Error().
-				Err(err).
-				Str("handler", "ocis").
-				Str("binddn", bindDN).
-				Str("basedn", h.basedn).
-				Str("filter", searchReq.Filter).
-				Interface("src", conn.RemoteAddr()).
-				Msg("could not compile filter")
-			return ldap.ServerSearchResult{
-				ResultCode: ldap.LDAPResultOperationsError,
-			}, fmt.Errorf("Search Error: error compiling filter: %s, error: %s", searchReq.Filter, err.Error())
-		}
-		qtype, query, code, err = parseFilter(cf)
-		if err != nil {
-			return ldap.ServerSearchResult{
-				ResultCode: code,
-			}, fmt.Errorf("Search Error: error parsing filter: %s, error: %s", searchReq.Filter, err.Error())
+	// the request holds a lossy copy of the filter, prefer the one decoded by the server
+	f, err := searchFilter(conn)
+	if f == nil && err == nil {
+		f, err = parseFilter(searchReq.Filter)
+	}
+	if err != nil {
+		h.log.Error().
+			Err(err).
+			Str("handler", "ocis").
+			Str("binddn", bindDN).
+			Str("basedn", h.basedn).
+			Str("filter", searchReq.Filter).
+			Interface("src", conn.RemoteAddr()).
+			Msg("could not parse filter")
+		code := ldap.LDAPResultCode(ldap.LDAPResultOperationsError)
+		if errors.Is(err, errUnsupportedFilter) {
+			code = ldap.LDAPResultUnwillingToPerform
 		}
+		return ldap.ServerSearchResult{
+			ResultCode: code,
+		}, fmt.Errorf("Search Error: error parsing filter: %s, error: %s", searchReq.Filter, err.Error())
+	}
 
-		// check if the searchBaseDN already has a username and add it to the query
-		parts := strings.Split(strings.TrimSuffix(searchBaseDN, baseDN), ",")
-		if len(parts) > 0 && strings.HasPrefix(parts[0], "cn=") {
-			if len(query) > 0 {
-				query += " AND "
-			}
-			query += fmt.Sprintf("on_premises_sam_account_name eq '%s'", escapeValue(strings.TrimPrefix(parts[0], "cn=")))
+	// check if the searchBaseDN already names an entry and add it to the filter
+	types, name := h.searchBase(searchBaseDN)
+	if name != "" {
+		f = &filter{
+			tag:      ldap.FilterAnd,
+			children: []*filter{f, {tag: ldap.FilterEqualityMatch, attribute: h.nameFormat, value: name}},
 		}
 	}
 
-	// TODO make glauth context aware
-	ctx := context.Background()
+	paging, code := newPagedResults(bindDN, searchReq)
+	if code != ldap.LDAPResultSuccess {
+		return ldap.ServerSearchResult{
+			ResultCode: code,
+		}, fmt.Errorf("search error: invalid paged results control")
+	}
 
-	// use a session with the bound user?
-	roleIDs, err := json.Marshal([]string{h.rbid})
-	if err != nil {
-		h.log.Error().
-			Err(err).
-			Str("handler", "ocis").
-			Msg("could not marshal roleid json")
+	// evaluate the search with the roles of the bound user
+	roleIDs, code := h.boundRoles(bindDN, conn)
+	if code != ldap.LDAPResultSuccess {
 		return ldap.ServerSearchResult{
-			ResultCode: ldap.LDAPResultOperationsError,
+			ResultCode: code,
+		}, fmt.Errorf("search error: could not get the roles of %s", bindDN)
+	}
+	ctx, code := h.roleContext(roleIDs)
+	if code != ldap.LDAPResultSuccess {
+		return ldap.ServerSearchResult{
+			ResultCode: code,
 		}, nil
 	}
-	ctx = metadata.Set(ctx, middleware.RoleIDs, string(roleIDs))
+	if searchReq.TimeLimit > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, time.Duration(searchReq.TimeLimit)*time.Second)
+		defer cancel()
+	}
 
 	entries := []*ldap.Entry{}
-	h.log.Debug().
-		Str("handler", "ocis").
-		Str("binddn", bindDN).
-		Str("basedn", h.basedn).
-		Str("filter", searchReq.Filter).
-		Str("qtype", string(qtype)).
-		Str("query", query).
-		Msg("parsed query")
-	switch qtype {
-	case usersQuery:
-		accounts, err := h.as.ListAccounts(ctx, &accountssvc.ListAccountsRequest{
-			Query: query,
-		})
-		if err != nil {
-			h.log.Error().
-				Err(err).
-				Str("handler", "ocis").
-				Str("binddn", bindDN).
-				Str("basedn", h.basedn).
-				Str("filter", searchReq.Filter).
-				Str("query", query).
-				Interface("src", conn.RemoteAddr()).
-				Msg("Could not list accounts")
-
-			return ldap.ServerSearchResult{
-				ResultCode: ldap.LDAPResultOperationsError,
-			}, fmt.Errorf("search error: error listing users")
+	for _, qtype := range types {
+		if !f.matchesType(qtype) {
+			continue
 		}
-		entries = append(entries, h.mapAccounts(accounts.Accounts)...)
-	case groupsQuery:
-		groups, err := h.gs.ListGroups(ctx, &accountssvc.ListGroupsRequest{
-			Query: query,
-		})
+		q, err := h.compileFilter(ctx, f, qtype)
 		if err != nil {
 			h.log.Error().
 				Err(err).
 				Str("handler", "ocis").
 				Str("binddn", bindDN).
-				Str("basedn", h.basedn).
 				Str("filter", searchReq.Filter).
-				Str("query", query).
 				Interface("src", conn.RemoteAddr()).
-				Msg("Could not list groups")
-
-			return ldap.ServerSearchResult{
-				ResultCode: ldap.LDAPResultOperationsError,
-			}, fmt.Errorf("search error: error listing groups")
+				Msg("could not compile filter")
+			return h.searchError(conn, err, "search error: error compiling filter")
+		}
+		if !q.all && q.query == "" {
+			continue
+		}
+		query := q.query
+		h.log.Debug().
+			Str("handler", "ocis").
+			Str("binddn", bindDN).
+			Str("basedn", h.basedn).
+			Str("filter", searchReq.Filter).
+			Str("qtype", string(qtype)).
+			Str("query", query).
+			Msg("parsed query")
+
+		var candidates []*ldap.Entry
+		switch qtype {
+		case usersQuery:
+			accounts, err := h.as.ListAccounts(ctx, &accountssvc.ListAccountsRequest{
+				Query: query,
+			})
+			if err != nil {
+				h.log.Error().
+					Err(err).
+					Str("handler", "ocis").
+					Str("binddn", bindDN).
+					Str("basedn", h.basedn).
+					Str("filter", searchReq.Filter).
+					Str("query", query).
+					Interface("src", conn.RemoteAddr()).
+					Msg("Could not list accounts")
+
+				return h.searchError(conn, err, "search error: error listing users")
+			}
+			candidates = h.mapAccounts(accounts.Accounts)
+		case groupsQuery:
+			groups, err := h.gs.ListGroups(ctx, &accountssvc.ListGroupsRequest{
+				Query: query,
+			})
+			if err != nil {
+				h.log.Error().
+					Err(err).
+					Str("handler", "ocis").
+					Str("binddn", bindDN).
+					Str("basedn", h.basedn).
+					Str("filter", searchReq.Filter).
+					Str("query", query).
+					Interface("src", conn.RemoteAddr()).
+					Msg("Could not list groups")
+
+				return h.searchError(conn, err, "search error: error listing groups")
+			}
+			candidates = h.mapGroups(groups.Groups)
+		}
+		// the query selects a superset of the matching entries
+		for _, e := range candidates {
+			if matchEntry(f, e) {
+				entries = append(entries, e)
+			}
 		}
-		entries = append(entries, h.mapGroups(groups.Groups)...)
 	}
 
+	code = ldap.LDAPResultSuccess
+	if searchReq.SizeLimit > 0 && len(entries) > searchReq.SizeLimit {
+		entries = entries[:searchReq.SizeLimit]
+		code = ldap.LDAPResultSizeLimitExceeded
+	}
+	controls := []ldap.Control{}
+	if paging != nil {
+		var control *ldap.ControlPaging
+		entries, control = paging.page(entries)
+		if len(control.Cookie) > 0 {
+			// the size limit is only exceeded by the last page
+			code = ldap.LDAPResultSuccess
+		}
+		controls = append(controls, control)
+	}
 	stats.Frontend.Add("search_successes", 1)
 	h.log.Debug().
 		Str("handler", "ocis").
@@ -265,11 +310,50 @@ func (h ocisHandler) Search(bindDN string, searchReq ldap.SearchRequest, conn ne
 	return ldap.ServerSearchResult{
 		Entries:    entries,
 		Referrals:  []string{},
-		Controls:   []ldap.Control{},
-		ResultCode: ldap.LDAPResultSuccess,
+		Controls:   controls,
+		ResultCode: code,
 	}, nil
 }
 
+// searchError returns the result of a failed search. An exceeded time limit is reported with the search result done
+// message, other errors end the connection.
+func (h ocisHandler) searchError(conn net.Conn, err error, msg string) (ldap.ServerSearchResult, error) {
+	code := accountsResultCode(err)
+	var lerr *ldap.Error
+	if errors.As(err, &lerr) {
+		code = lerr.ResultCode
+	}
+	if code == ldap.LDAPResultTimeLimitExceeded {
+		return ldap.ServerSearchResult{
+			Entries:    []*ldap.Entry{},
+			Referrals:  []string{},
+			Controls:   []ldap.Control{},
+			ResultCode: code,
+		}, nil
+	}
+	return ldap.ServerSearchResult{
+		ResultCode: code,
+	}, errors.New(msg)
+}
+
+// searchBase returns the types of the entries below the search base DN. If the search base DN names an entry, its
+// name is returned, too.
+func (h ocisHandler) searchBase(searchBaseDN string) ([]queryType, string) {
+	rdns := normalizeDN(strings.TrimSuffix(strings.ToLower(searchBaseDN), strings.ToLower(h.basedn)))
+	switch strings.TrimSuffix(rdns, ",") {
+	case "":
+		return []queryType{usersQuery, groupsQuery}, ""
+	case h.groupFormat + "=users":
+		return []queryType{usersQuery}, ""
+	case h.groupFormat + "=groups":
+		return []queryType{groupsQuery}, ""
+	}
+	if name, qtype, code := h.parseDN(searchBaseDN); code == ldap.LDAPResultSuccess {
+		return []queryType{qtype}, name
+	}
+	return nil, ""
+}
+
 func attribute(name string, values ...string) *ldap.EntryAttribute {
 	return &ldap.EntryAttribute{
 		Name:   name,
@@ -303,15 +387,17 @@ func (h ocisHandler) mapAccounts(accounts []*accountsmsg.Account) []*ldap.Entry
 		if accounts[i].Description != "" {
 			attrs = append(attrs, attribute("description", accounts[i].Description))
 		}
+		memberOf := make([]string, 0, len(accounts[i].MemberOf))
+		for _, g := range accounts[i].MemberOf {
+			if g.OnPremisesSamAccountName != "" {
+				memberOf = append(memberOf, h.entryDN(g.OnPremisesSamAccountName, groupsQuery))
+			}
+		}
+		if len(memberOf) > 0 {
+			attrs = append(attrs, attribute("memberOf", memberOf...))
+		}
 
-		dn := fmt.Sprintf("%s=%s,%s=%s,%s",
-			h.nameFormat,
-			accounts[i].PreferredName,
-			h.groupFormat,
-			"users",
-			h.basedn,
-		)
-		entries = append(entries, &ldap.Entry{DN: dn, Attributes: attrs})
+		entries = append(entries, &ldap.Entry{DN: h.entryDN(accounts[i].PreferredName, usersQuery), Attributes: attrs})
 	}
 	return entries
 }
@@ -334,173 +420,24 @@ func (h ocisHandler) mapGroups(groups []*accountsmsg.Group) []*ldap.Entry {
 			attrs = append(attrs, attribute("description", groups[i].Description))
 		}
 
-		dn := fmt.Sprintf("%s=%s,%s=%s,%s",
-			h.nameFormat,
-			groups[i].OnPremisesSamAccountName,
-			h.groupFormat,
-			"groups",
-			h.basedn,
-		)
-
 		memberUids := make([]string, len(groups[i].Members))
+		members := make([]string, len(groups[i].Members))
 		for j := range groups[i].Members {
 			memberUids[j] = groups[i].Members[j].PreferredName
+			members[j] = h.entryDN(groups[i].Members[j].PreferredName, usersQuery)
 		}
 		attrs = append(attrs, attribute("memberuid", memberUids...))
-		entries = append(entries, &ldap.Entry{DN: dn, Attributes: attrs})
+		if len(members) > 0 {
+			attrs = append(attrs, attribute("member", members...))
+		}
+		entries = append(entries, &ldap.Entry{DN: h.entryDN(groups[i].OnPremisesSamAccountName, groupsQuery), Attributes: attrs})
 	}
 	return entries
 }
 
-// LDAP filters might ask for groups and users at the same time, eg.
-// (|
-//   (&(objectClass=posixaccount)(cn=einstein))
-//   (&(objectClass=posixgroup)(cn=users))
-// )
-
-// (&(objectClass=posixaccount)(objectClass=posixgroup))
-// qtype is one of
-// "" not determined
-// "users"
-// "groups"
-func parseFilter(f *ber.Packet) (queryType, string, ldap.LDAPResultCode, error) {
-	var qtype queryType
-	var q string
-	var code ldap.LDAPResultCode
-	var err error
-	switch ldap.FilterMap[f.Tag] {
-	case "Present":
-		if len(f.Children) != 0 {
-			return "", "", ldap.LDAPResultOperationsError, fmt.Errorf("equality match must have no children, got %+v", f)
-		}
-		attribute := strings.ToLower(f.Data.String())
-
-		if attribute == "objectclass" {
-			// TODO implement proper present odata query, for now fall back to listing users
-			return "users", q, code, err
-		}
-		return qtype, q, ldap.LDAPResultUnwillingToPerform, fmt.Errorf("%s filter match for %s not implemented", ldap.FilterMap[f.Tag], attribute)
-	case "Equality Match":
-		if len(f.Children) != 2 {
-			return "", "", ldap.LDAPResultOperationsError, fmt.Errorf("equality match must have exactly two children")
-		}
-		attribute := strings.ToLower(f.Children[0].Value.(string))
-		value := f.Children[1].Value.(string)
-
-		// replace attributes
-		switch attribute {
-		case "objectclass":
-			switch strings.ToLower(value) {
-			case "posixaccount", "shadowaccount", "users", "person", "inetorgperson", "organizationalperson":
-				qtype = usersQuery
-			case "posixgroup", "groups":
-				qtype = groupsQuery
-			case "*":
-				// TODO not implemented yet
-				qtype = usersQuery
-			default:
-				qtype = ""
-			}
-		case "ownclouduuid":
-			q = fmt.Sprintf("id eq '%s'", escapeValue(value))
-		case "cn", "uid":
-			// on_premises_sam_account_name is indexed using the lowercase analyzer in ocis-accounts
-			// TODO use "tolower(on_premises_sam_account_name) eq '%s'" to be clear about the case insensitive comparison
-			q = fmt.Sprintf("on_premises_sam_account_name eq '%s'", escapeValue(value))
-		case "mail":
-			q = fmt.Sprintf("mail eq '%s'", escapeValue(value))
-		case "displayname":
-			q = fmt.Sprintf("display_name eq '%s'", escapeValue(value))
-		case "uidnumber":
-			if i, err := strconv.ParseUint(value, 10, 64); err != nil {
-				code = ldap.LDAPResultInvalidAttributeSyntax
-			} else {
-				q = fmt.Sprintf("uid_number eq %d", i)
-			}
-		case "gidnumber":
-			if i, err := strconv.ParseUint(value, 10, 64); err != nil {
-				code = ldap.LDAPResultInvalidAttributeSyntax
-			} else {
-				q = fmt.Sprintf("gid_number eq %d", i)
-			}
-		case "description":
-			q = fmt.Sprintf("description eq '%s'", escapeValue(value))
-		default:
-			code = ldap.LDAPResultUndefinedAttributeType
-			err = fmt.Errorf("unrecognized assertion type '%s' in filter item", attribute)
-		}
-		return qtype, q, code, err
-	case "Substrings":
-		if len(f.Children) != 2 {
-			return "", "", ldap.LDAPResultOperationsError, fmt.Errorf("substrings filter must have exactly two children")
-		}
-		attribute := strings.ToLower(f.Children[0].Value.(string))
-		if len(f.Children[1].Children) != 1 {
-			return "", "", ldap.LDAPResultUnwillingToPerform, fmt.Errorf("substrings filter only supports prefix match")
-		}
-		value := f.Children[1].Children[0].Value.(string)
-
-		// replace attributes
-		switch attribute {
-		case "objectclass":
-			switch strings.ToLower(value) {
-			case "posixaccount", "shadowaccount", "users", "person", "inetorgperson", "organizationalperson":
-				qtype = usersQuery
-			case "posixgroup", "groups":
-				qtype = groupsQuery
-			default:
-				qtype = ""
-			}
-		case "ownclouduuid":
-			q = fmt.Sprintf("startswith(id,'%s')", escapeValue(value))
-		case "cn", "uid":
-			// on_premises_sam_account_name is indexed using the lowercase analyzer in ocis-accounts
-			// TODO use "tolower(on_premises_sam_account_name) eq '%s'" to be clear about the case insensitive comparison
-			q = fmt.Sprintf("startswith(on_premises_sam_account_name,'%s')", escapeValue(value))
-		case "mail":
-			q = fmt.Sprintf("startswith(mail,'%s')", escapeValue(value))
-		case "displayname":
-			q = fmt.Sprintf("startswith(display_name,'%s')", escapeValue(value))
-		case "description":
-			q = fmt.Sprintf("startswith(description,'%s')", escapeValue(value))
-		default:
-			code = ldap.LDAPResultUndefinedAttributeType
-			err = fmt.Errorf("unrecognized assertion type '%s' in filter item", attribute)
-		}
-		return qtype, q, code, err
-	case "And", "Or":
-		subQueries := []string{}
-		for i := range f.Children {
-			var subQuery string
-			var qt queryType
-			qt, subQuery, code, err = parseFilter(f.Children[i])
-			if err != nil {
-				return "", "", code, err
-			}
-			if qtype == "" {
-				qtype = qt
-			} else if qt != "" && qt != qtype {
-				return "", "", ldap.LDAPResultUnwillingToPerform, fmt.Errorf("mixing user and group filters not supported")
-			}
-			if subQuery != "" {
-				subQueries = append(subQueries, subQuery)
-			}
-		}
-		return qtype, strings.Join(subQueries, " "+strings.ToLower(ldap.FilterMap[f.Tag])+" "), ldap.LDAPResultSuccess, nil
-	case "Not":
-		if len(f.Children) != 1 {
-			return "", "", ldap.LDAPResultOperationsError, fmt.Errorf("not filter match must have exactly one child")
-		}
-		qtype, subQuery, code, err := parseFilter(f.Children[0])
-		if err != nil {
-			return "", "", code, err
-		}
-		if subQuery != "" {
-			q = fmt.Sprintf("not %s", subQuery)
-		}
-		return qtype, q, code, nil
-	}
-	return qtype, q, ldap.LDAPResultUnwillingToPerform, fmt.Errorf("%s filter not implemented", ldap.FilterMap[f.Tag])
+// entryDN returns the DN of the user or group with the given name.
+func (h ocisHandler) entryDN(name string, t queryType) string {
+	return fmt.Sprintf("%s=%s,%s=%s,%s", h.nameFormat, name, h.groupFormat, t, h.basedn)
 }
 
 // escapeValue escapes all special characters in the value
@@ -513,21 +450,6 @@ func (h ocisHandler) Close(boundDN string, conn net.Conn) error {
 	return nil
 }
 
-// Add is not yet supported for the ocis backend
-func (h ocisHandler) Add(boundDN string, req ldap.AddRequest, conn net.Conn) (result ldap.LDAPResultCode, err error) {
-	return ldap.LDAPResultInsufficientAccessRights, nil
-}
-
-// Modify is not yet supported for the ocis backend
-func (h ocisHandler) Modify(boundDN string, req ldap.ModifyRequest, conn net.Conn) (result ldap.LDAPResultCode, err error) {
-	return ldap.LDAPResultInsufficientAccessRights, nil
-}
-
-// Delete is not yet supported for the ocis backend
-func (h ocisHandler) Delete(boundDN string, deleteDN string, conn net.Conn) (result ldap.LDAPResultCode, err error) {
-	return ldap.LDAPResultInsufficientAccessRights, nil
-}
-
 // FindUser with the given username
 func (h ocisHandler) FindUser(userName string, searchByUPN bool) (found bool, user config.User, err error) {
 	return false, config.User{}, nil
@@ -550,6 +472,7 @@ func NewOCISHandler(opts ...Option) handler.Handler {
 		nameFormat:  options.NameFormat,
 		groupFormat: options.GroupFormat,
 		rbid:        options.RoleBundleUUID,
+		rm:          options.RoleManager,
 	}
 	return handler
 }
@@ -0,0 +1,91 @@
+package glauth
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"strings"
+
+	"github.com/nmcclain/ldap"
+	accounts "github.com/owncloud/ocis/extensions/accounts/pkg/service/v0"
+	"github.com/owncloud/ocis/ocis-pkg/middleware"
+	"go-micro.dev/v4/metadata"
+)
+
+// boundRoles returns the roles of the bound user. They are cached on the connection, so that a client sending many
+// requests doesn't look them up for every request.
+func (h ocisHandler) boundRoles(boundDN string, conn net.Conn) ([]string, ldap.LDAPResultCode) {
+	boundDN = strings.ToLower(boundDN)
+	if roleIDs, ok := cachedRoles(conn, boundDN); ok {
+		return roleIDs, ldap.LDAPResultSuccess
+	}
+	if h.rm == nil {
+		h.log.Error().Str("handler", "ocis").Msg("no role manager configured")
+		return nil, ldap.LDAPResultInsufficientAccessRights
+	}
+
+	baseDN := strings.ToLower("," + h.basedn)
+	parts := strings.Split(strings.TrimSuffix(boundDN, baseDN), ",")
+	if boundDN == "" || !strings.HasSuffix(boundDN, baseDN) || len(parts) > 2 {
+		return nil, ldap.LDAPResultInsufficientAccessRights
+	}
+	ctx, code := h.serviceContext()
+	if code != ldap.LDAPResultSuccess {
+		return nil, code
+	}
+	a, code := h.getAccount(ctx, strings.TrimPrefix(parts[0], h.nameFormat+"="))
+	if code != ldap.LDAPResultSuccess {
+		return nil, ldap.LDAPResultInsufficientAccessRights
+	}
+
+	roleIDs, err := h.rm.FindRoleIDsForUser(ctx, a.Id)
+	if err != nil {
+		h.log.Error().
+			Err(err).
+			Str("handler", "ocis").
+			Str("binddn", boundDN).
+			Msg("could not get the roles of the bound user")
+		return nil, ldap.LDAPResultOperationsError
+	}
+	cacheRoles(conn, boundDN, roleIDs)
+	return roleIDs, ldap.LDAPResultSuccess
+}
+
+// adminContext returns a context with the roles of the bound user, if the user is allowed to manage accounts.
+func (h ocisHandler) adminContext(boundDN string, conn net.Conn) (context.Context, ldap.LDAPResultCode) {
+	roleIDs, code := h.boundRoles(boundDN, conn)
+	if code != ldap.LDAPResultSuccess {
+		return nil, code
+	}
+	ctx, code := h.serviceContext()
+	if code != ldap.LDAPResultSuccess {
+		return nil, code
+	}
+	if h.rm.FindPermissionByID(ctx, roleIDs, accounts.AccountManagementPermissionID) == nil {
+		h.log.Info().
+			Str("handler", "ocis").
+			Str("binddn", boundDN).
+			Msg("bound user is not allowed to manage accounts")
+		return nil, ldap.LDAPResultInsufficientAccessRights
+	}
+	return h.roleContext(roleIDs)
+}
+
+// serviceContext returns a context with the role glauth uses for its own requests to the accounts service.
+func (h ocisHandler) serviceContext() (context.Context, ldap.LDAPResultCode) {
+	return h.roleContext([]string{h.rbid})
+}
+
+// roleContext returns a context with the given roles for the requests to the accounts service.
+func (h ocisHandler) roleContext(roleIDs []string) (context.Context, ldap.LDAPResultCode) {
+	ids, err := json.Marshal(roleIDs)
+	if err != nil {
+		h.log.Error().
+			Err(err).
+			Str("handler", "ocis").
+			Msg("could not marshal roleid json")
+		return nil, ldap.LDAPResultOperationsError
+	}
+	// TODO make glauth context aware
+	return metadata.Set(context.Background(), middleware.RoleIDs, string(ids)), ldap.LDAPResultSuccess
+}
@@ -0,0 +1,148 @@
+package glauth
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/go-ldap/ldap/v3"
+	accountsmsg "github.com/owncloud/ocis/protogen/gen/ocis/messages/accounts/v0"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func accountsSearch(controls ...ldap.Control) *ldap.SearchRequest {
+	return ldap.NewSearchRequest(
+		testBaseDN, ldap.ScopeWholeSubtree, ldap.NeverDerefAliases, 0, 0, false,
+		"(objectClass=posixAccount)", []string{"cn"}, controls,
+	)
+}
+
+func names(entries []*ldap.Entry) []string {
+	n := make([]string, 0, len(entries))
+	for _, e := range entries {
+		n = append(n, e.GetAttributeValue("cn"))
+	}
+	return n
+}
+
+func TestSearchWithBoundRoles(t *testing.T) {
+	d := newDirectory()
+	c := connect(t, startServer(t, d), "admin")
+
+	res, err := c.Search(accountsSearch())
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{"admin", "einstein", "marie"}, names(res.Entries))
+	assert.Equal(t, `["admin-role"]`, d.listRoles)
+
+	c = connect(t, startServer(t, d), "einstein")
+	_, err = c.Search(accountsSearch())
+	assert.Equal(t, uint16(ldap.LDAPResultInsufficientAccessRights), resultCode(err))
+}
+
+func TestPagedSearch(t *testing.T) {
+	d := newDirectory()
+	for i := 0; i < 4; i++ {
+		id := fmt.Sprintf("user%d", i)
+		d.accounts[id] = &accountsmsg.Account{Id: id, PreferredName: id, OnPremisesSamAccountName: id}
+	}
+	addr := startServer(t, d)
+	c := connect(t, addr, "admin")
+
+	res, err := c.SearchWithPaging(accountsSearch(), 3)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"admin", "einstein", "marie", "user0", "user1", "user2", "user3"}, names(res.Entries))
+
+	paging := ldap.NewControlPaging(3)
+	res, err = c.Search(accountsSearch(paging))
+	require.NoError(t, err)
+	assert.Equal(t, []string{"admin", "einstein", "marie"}, names(res.Entries))
+	control, ok := ldap.FindControl(res.Controls, ldap.ControlTypePaging).(*ldap.ControlPaging)
+	require.True(t, ok)
+	assert.Equal(t, uint32(7), control.PagingSize)
+	require.NotEmpty(t, control.Cookie)
+
+	// a page size of 0 abandons the search
+	paging = ldap.NewControlPaging(0)
+	paging.SetCookie(control.Cookie)
+	res, err = c.Search(accountsSearch(paging))
+	require.NoError(t, err)
+	assert.Empty(t, res.Entries)
+	control = ldap.FindControl(res.Controls, ldap.ControlTypePaging).(*ldap.ControlPaging)
+	assert.Empty(t, control.Cookie)
+
+	// the cookie is only valid for the search it was returned for
+	paging = ldap.NewControlPaging(3)
+	paging.SetCookie([]byte("3:invalid"))
+	_, err = c.Search(accountsSearch(paging))
+	assert.Equal(t, uint16(ldap.LDAPResultUnwillingToPerform), resultCode(err))
+}
+
+func TestSearchSizeLimit(t *testing.T) {
+	d := newDirectory()
+	c := connect(t, startServer(t, d), "admin")
+
+	req := accountsSearch()
+	req.SizeLimit = 2
+	res, err := c.Search(req)
+	assert.Equal(t, uint16(ldap.LDAPResultSizeLimitExceeded), resultCode(err))
+	require.NotNil(t, res)
+	assert.Len(t, res.Entries, 2)
+
+	req.SizeLimit = 3
+	res, err = c.Search(req)
+	require.NoError(t, err)
+	assert.Len(t, res.Entries, 3)
+}
+
+func TestSearchTimeLimit(t *testing.T) {
+	d := newDirectory()
+	c := connect(t, startServer(t, d), "admin")
+	// the roles of the bound user are cached on the connection by the first search
+	_, err := c.Search(accountsSearch())
+	require.NoError(t, err)
+	d.mu.Lock()
+	d.listHook = func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	}
+	d.mu.Unlock()
+
+	req := accountsSearch()
+	req.TimeLimit = 1
+	_, err = c.Search(req)
+	assert.Equal(t, uint16(ldap.LDAPResultTimeLimitExceeded), resultCode(err))
+}
+
+func TestSearchFilters(t *testing.T) {
+	d := newDirectory()
+	d.groups["physicists-id"] = &accountsmsg.Group{
+		Id:                       "physicists-id",
+		OnPremisesSamAccountName: "physicists",
+		Members:                  []*accountsmsg.Account{d.accounts["einstein-id"]},
+	}
+	// the accounts service expands the groups of the listed accounts
+	d.accounts["einstein-id"].MemberOf = []*accountsmsg.Group{d.groups["physicists-id"]}
+	c := connect(t, startServer(t, d), "admin")
+
+	for _, tc := range []struct {
+		baseDN string
+		filter string
+		want   []string
+	}{
+		{testBaseDN, "(&(objectClass=posixAccount)(cn=ei*st*n))", []string{"einstein"}},
+		{testBaseDN, "(|(cn=einstein)(cn=marie))", []string{"einstein", "marie"}},
+		{testBaseDN, "(&(objectClass=posixAccount)(!(cn=admin)))", []string{"einstein", "marie"}},
+		{testBaseDN, "(&(objectClass=posixAccount)(memberOf=cn=physicists,ou=groups," + testBaseDN + "))", []string{"einstein"}},
+		{testBaseDN, "(member=cn=einstein,ou=users," + testBaseDN + ")", []string{"physicists"}},
+		{"ou=groups," + testBaseDN, "(objectClass=*)", []string{"physicists"}},
+		{"cn=marie,ou=users," + testBaseDN, "(objectClass=*)", []string{"marie"}},
+	} {
+		res, err := c.Search(ldap.NewSearchRequest(
+			tc.baseDN, ldap.ScopeWholeSubtree, ldap.NeverDerefAliases, 0, 0, false,
+			tc.filter, []string{"cn"}, nil,
+		))
+		require.NoError(t, err, tc.filter)
+		assert.ElementsMatch(t, tc.want, names(res.Entries), tc.filter)
+	}
+}
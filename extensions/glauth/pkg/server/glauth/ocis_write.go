@@ -0,0 +1,488 @@
+package glauth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/glauth/glauth/v2/pkg/stats"
+	"github.com/nmcclain/ldap"
+	accountsmsg "github.com/owncloud/ocis/protogen/gen/ocis/messages/accounts/v0"
+	accountssvc "github.com/owncloud/ocis/protogen/gen/ocis/services/accounts/v0"
+	merrors "go-micro.dev/v4/errors"
+	"google.golang.org/protobuf/types/known/fieldmaskpb"
+)
+
+// Add creates a user or a group, depending on the container of the DN. Only users with the account management
+// permission are allowed to write.
+func (h ocisHandler) Add(boundDN string, req ldap.AddRequest, conn net.Conn) (ldap.LDAPResultCode, error) {
+	entry := addRequestEntry(conn)
+	if entry == nil {
+		return ldap.LDAPResultOperationsError, errors.New("the add request was not decoded by the server")
+	}
+	dn, attrs := entry.dn, entry.attributes
+	h.log.Debug().
+		Str("handler", "ocis").
+		Str("binddn", boundDN).
+		Str("dn", dn).
+		Interface("src", conn.RemoteAddr()).
+		Msg("Add request")
+	stats.Frontend.Add("add_reqs", 1)
+
+	ctx, code := h.adminContext(boundDN, conn)
+	if code != ldap.LDAPResultSuccess {
+		return code, nil
+	}
+	name, qtype, code := h.parseDN(dn)
+	if code != ldap.LDAPResultSuccess {
+		return code, nil
+	}
+
+	switch qtype {
+	case usersQuery:
+		code = h.addAccount(ctx, name, attrs)
+	case groupsQuery:
+		code = h.addGroup(ctx, name, attrs)
+	}
+	if code == ldap.LDAPResultSuccess {
+		stats.Frontend.Add("add_successes", 1)
+	}
+	return code, nil
+}
+
+// Modify changes the attributes of a user, or the members of a group.
+func (h ocisHandler) Modify(boundDN string, req ldap.ModifyRequest, conn net.Conn) (ldap.LDAPResultCode, error) {
+	h.log.Debug().
+		Str("handler", "ocis").
+		Str("binddn", boundDN).
+		Str("dn", req.Dn).
+		Interface("src", conn.RemoteAddr()).
+		Msg("Modify request")
+	stats.Frontend.Add("modify_reqs", 1)
+
+	ctx, code := h.adminContext(boundDN, conn)
+	if code != ldap.LDAPResultSuccess {
+		return code, nil
+	}
+	name, qtype, code := h.parseDN(req.Dn)
+	if code != ldap.LDAPResultSuccess {
+		return code, nil
+	}
+
+	switch qtype {
+	case usersQuery:
+		code = h.modifyAccount(ctx, name, req)
+	case groupsQuery:
+		code = h.modifyGroup(ctx, name, req)
+	}
+	if code == ldap.LDAPResultSuccess {
+		stats.Frontend.Add("modify_successes", 1)
+	}
+	return code, nil
+}
+
+// Delete deletes a user or a group.
+func (h ocisHandler) Delete(boundDN string, deleteDN string, conn net.Conn) (ldap.LDAPResultCode, error) {
+	h.log.Debug().
+		Str("handler", "ocis").
+		Str("binddn", boundDN).
+		Str("dn", deleteDN).
+		Interface("src", conn.RemoteAddr()).
+		Msg("Delete request")
+	stats.Frontend.Add("delete_reqs", 1)
+
+	ctx, code := h.adminContext(boundDN, conn)
+	if code != ldap.LDAPResultSuccess {
+		return code, nil
+	}
+	name, qtype, code := h.parseDN(deleteDN)
+	if code != ldap.LDAPResultSuccess {
+		return code, nil
+	}
+
+	switch qtype {
+	case usersQuery:
+		var a *accountsmsg.Account
+		if a, code = h.getAccount(ctx, name); code == ldap.LDAPResultSuccess {
+			_, err := h.as.DeleteAccount(ctx, &accountssvc.DeleteAccountRequest{Id: a.Id})
+			code = h.resultCode(err, "could not delete account")
+		}
+	case groupsQuery:
+		var g *accountsmsg.Group
+		if g, code = h.getGroup(ctx, name); code == ldap.LDAPResultSuccess {
+			_, err := h.gs.DeleteGroup(ctx, &accountssvc.DeleteGroupRequest{Id: g.Id})
+			code = h.resultCode(err, "could not delete group")
+		}
+	}
+	if code == ldap.LDAPResultSuccess {
+		stats.Frontend.Add("delete_successes", 1)
+	}
+	return code, nil
+}
+
+func (h ocisHandler) addAccount(ctx context.Context, name string, attrs map[string][]string) ldap.LDAPResultCode {
+	a := &accountsmsg.Account{
+		AccountEnabled:           true,
+		PreferredName:            name,
+		OnPremisesSamAccountName: name,
+		DisplayName:              name,
+	}
+	for attr, values := range attrs {
+		if len(values) == 0 {
+			continue
+		}
+		switch attr {
+		case "cn", "uid":
+			// the naming attributes are set from the DN, they may only repeat the name
+			if len(values) > 1 || !strings.EqualFold(values[0], name) {
+				return ldap.LDAPResultConstraintViolation
+			}
+			continue
+		case "objectclass", "sn":
+			continue
+		}
+		if code := setAccountAttribute(a, attr, values); code != ldap.LDAPResultSuccess && code != ldap.LDAPResultUnwillingToPerform {
+			return code
+		}
+	}
+	_, err := h.as.CreateAccount(ctx, &accountssvc.CreateAccountRequest{Account: a})
+	return h.resultCode(err, "could not create account")
+}
+
+func (h ocisHandler) addGroup(ctx context.Context, name string, attrs map[string][]string) ldap.LDAPResultCode {
+	// resolve the members first, so that no group with missing members is created
+	var memberIDs []string
+	for _, member := range h.memberNames(attrs["memberuid"], attrs["member"]) {
+		a, code := h.getAccount(ctx, member)
+		if code != ldap.LDAPResultSuccess {
+			if code == ldap.LDAPResultNoSuchObject {
+				return ldap.LDAPResultConstraintViolation
+			}
+			return code
+		}
+		memberIDs = append(memberIDs, a.Id)
+	}
+
+	g := &accountsmsg.Group{
+		OnPremisesSamAccountName: name,
+		DisplayName:              name,
+	}
+	if v := attrs["displayname"]; len(v) > 0 {
+		g.DisplayName = v[0]
+	}
+	if v := attrs["description"]; len(v) > 0 {
+		g.Description = v[0]
+	}
+	if v := attrs["gidnumber"]; len(v) > 0 {
+		i, err := strconv.ParseInt(v[0], 10, 64)
+		if err != nil {
+			return ldap.LDAPResultInvalidAttributeSyntax
+		}
+		g.GidNumber = i
+	}
+	created, err := h.gs.CreateGroup(ctx, &accountssvc.CreateGroupRequest{Group: g})
+	if code := h.resultCode(err, "could not create group"); code != ldap.LDAPResultSuccess {
+		return code
+	}
+	for _, id := range memberIDs {
+		_, err := h.gs.AddMember(ctx, &accountssvc.AddMemberRequest{GroupId: created.Id, AccountId: id})
+		if code := h.resultCode(err, "could not add group member"); code != ldap.LDAPResultSuccess {
+			return code
+		}
+	}
+	return ldap.LDAPResultSuccess
+}
+
+func (h ocisHandler) modifyAccount(ctx context.Context, name string, req ldap.ModifyRequest) ldap.LDAPResultCode {
+	a, code := h.getAccount(ctx, name)
+	if code != ldap.LDAPResultSuccess {
+		return code
+	}
+	update := &accountsmsg.Account{Id: a.Id}
+	paths := map[string]bool{}
+	apply := func(changes []ldap.PartialAttribute, deleting bool) ldap.LDAPResultCode {
+		for _, c := range changes {
+			values := c.AttrVals
+			if deleting {
+				// single valued attributes are removed regardless of the values of the request
+				values = nil
+			}
+			attr := strings.ToLower(c.AttrType)
+			if code := setAccountAttribute(update, attr, values); code != ldap.LDAPResultSuccess {
+				h.log.Debug().Str("handler", "ocis").Str("attribute", attr).Msg("attribute can't be modified")
+				return code
+			}
+			paths[accountAttributePaths[attr]] = true
+		}
+		return ldap.LDAPResultSuccess
+	}
+	for _, code := range []ldap.LDAPResultCode{
+		apply(req.AddAttributes, false),
+		apply(req.ReplaceAttributes, false),
+		apply(req.DeleteAttributes, true),
+	} {
+		if code != ldap.LDAPResultSuccess {
+			return code
+		}
+	}
+	if len(paths) == 0 {
+		return ldap.LDAPResultSuccess
+	}
+
+	mask := &fieldmaskpb.FieldMask{}
+	for p := range paths {
+		mask.Paths = append(mask.Paths, p)
+	}
+	_, err := h.as.UpdateAccount(ctx, &accountssvc.UpdateAccountRequest{Account: update, UpdateMask: mask})
+	return h.resultCode(err, "could not update account")
+}
+
+func (h ocisHandler) modifyGroup(ctx context.Context, name string, req ldap.ModifyRequest) ldap.LDAPResultCode {
+	g, code := h.getGroup(ctx, name)
+	if code != ldap.LDAPResultSuccess {
+		return code
+	}
+	current := map[string]string{}
+	for _, m := range g.Members {
+		current[strings.ToLower(m.PreferredName)] = m.Id
+	}
+
+	add := map[string]bool{}
+	remove := map[string]bool{}
+	for _, changes := range [][]ldap.PartialAttribute{req.AddAttributes, req.ReplaceAttributes, req.DeleteAttributes} {
+		for _, c := range changes {
+			attr := strings.ToLower(c.AttrType)
+			if attr != "memberuid" && attr != "member" {
+				// the accounts service can't update groups, only their members
+				return ldap.LDAPResultUnwillingToPerform
+			}
+		}
+	}
+	members := func(changes []ldap.PartialAttribute) []string {
+		var names []string
+		for _, c := range changes {
+			if strings.ToLower(c.AttrType) == "member" {
+				names = append(names, h.memberNames(nil, c.AttrVals)...)
+			} else {
+				names = append(names, h.memberNames(c.AttrVals, nil)...)
+			}
+		}
+		return names
+	}
+	for _, m := range members(req.AddAttributes) {
+		add[m] = true
+	}
+	if len(req.ReplaceAttributes) > 0 {
+		replaced := map[string]bool{}
+		for _, m := range members(req.ReplaceAttributes) {
+			replaced[m] = true
+			add[m] = true
+		}
+		for m := range current {
+			if !replaced[m] {
+				remove[m] = true
+			}
+		}
+	}
+	for _, c := range req.DeleteAttributes {
+		if len(c.AttrVals) == 0 {
+			// deleting the attribute removes all members
+			for m := range current {
+				remove[m] = true
+			}
+		}
+	}
+	for _, m := range members(req.DeleteAttributes) {
+		remove[m] = true
+	}
+
+	for m := range remove {
+		id, ok := current[m]
+		if !ok {
+			if len(req.DeleteAttributes) > 0 && !add[m] {
+				return ldap.LDAPResultNoSuchAttribute
+			}
+			continue
+		}
+		_, err := h.gs.RemoveMember(ctx, &accountssvc.RemoveMemberRequest{GroupId: g.Id, AccountId: id})
+		if code := h.resultCode(err, "could not remove group member"); code != ldap.LDAPResultSuccess {
+			return code
+		}
+	}
+	for m := range add {
+		if _, ok := current[m]; ok {
+			continue
+		}
+		a, code := h.getAccount(ctx, m)
+		if code != ldap.LDAPResultSuccess {
+			if code == ldap.LDAPResultNoSuchObject {
+				return ldap.LDAPResultConstraintViolation
+			}
+			return code
+		}
+		_, err := h.gs.AddMember(ctx, &accountssvc.AddMemberRequest{GroupId: g.Id, AccountId: a.Id})
+		if code := h.resultCode(err, "could not add group member"); code != ldap.LDAPResultSuccess {
+			return code
+		}
+	}
+	return ldap.LDAPResultSuccess
+}
+
+// accountAttributePaths maps the writable LDAP attributes of users to the paths of the account properties.
+var accountAttributePaths = map[string]string{
+	"displayname":  "DisplayName",
+	"mail":         "Mail",
+	"description":  "Description",
+	"uidnumber":    "UidNumber",
+	"gidnumber":    "GidNumber",
+	"userpassword": "PasswordProfile.Password",
+}
+
+// setAccountAttribute sets the account property of an LDAP attribute. All writable attributes are single valued, no
+// values clear the property.
+func setAccountAttribute(a *accountsmsg.Account, attr string, values []string) ldap.LDAPResultCode {
+	if _, ok := accountAttributePaths[attr]; !ok {
+		switch attr {
+		case "cn", "uid":
+			return ldap.LDAPResultNotAllowedOnRDN
+		default:
+			return ldap.LDAPResultUnwillingToPerform
+		}
+	}
+	if len(values) > 1 {
+		return ldap.LDAPResultConstraintViolation
+	}
+	value := ""
+	if len(values) == 1 {
+		value = values[0]
+	}
+	switch attr {
+	case "displayname":
+		a.DisplayName = value
+	case "mail":
+		a.Mail = value
+	case "description":
+		a.Description = value
+	case "uidnumber", "gidnumber":
+		var i int64
+		if value != "" {
+			var err error
+			if i, err = strconv.ParseInt(value, 10, 64); err != nil {
+				return ldap.LDAPResultInvalidAttributeSyntax
+			}
+		}
+		if attr == "uidnumber" {
+			a.UidNumber = i
+		} else {
+			a.GidNumber = i
+		}
+	case "userpassword":
+		if value == "" {
+			return ldap.LDAPResultUnwillingToPerform
+		}
+		a.PasswordProfile = &accountsmsg.PasswordProfile{Password: value}
+	}
+	return ldap.LDAPResultSuccess
+}
+
+// memberNames returns the lower case names of the members given by their names and by their DNs.
+func (h ocisHandler) memberNames(uids []string, dns []string) []string {
+	names := make([]string, 0, len(uids)+len(dns))
+	for _, uid := range uids {
+		names = append(names, strings.ToLower(uid))
+	}
+	for _, dn := range dns {
+		if name, qtype, code := h.parseDN(dn); code == ldap.LDAPResultSuccess && qtype == usersQuery {
+			names = append(names, strings.ToLower(name))
+		} else {
+			// keep unknown DNs, so that they are rejected when the members are resolved
+			names = append(names, strings.ToLower(dn))
+		}
+	}
+	return names
+}
+
+// parseDN returns the name of the user or group with the given DN, e.g. "einstein" and usersQuery for
+// "cn=einstein,ou=users,dc=ocis,dc=test".
+func (h ocisHandler) parseDN(dn string) (string, queryType, ldap.LDAPResultCode) {
+	baseDN := strings.ToLower("," + h.basedn)
+	if !strings.HasSuffix(strings.ToLower(dn), baseDN) {
+		return "", "", ldap.LDAPResultNoSuchObject
+	}
+	parts := strings.Split(dn[:len(dn)-len(baseDN)], ",")
+	if len(parts) != 2 {
+		return "", "", ldap.LDAPResultNoSuchObject
+	}
+	rdn := strings.SplitN(strings.TrimSpace(parts[0]), "=", 2)
+	if len(rdn) != 2 || !strings.EqualFold(rdn[0], h.nameFormat) || rdn[1] == "" {
+		return "", "", ldap.LDAPResultNamingViolation
+	}
+	switch strings.ToLower(strings.TrimSpace(parts[1])) {
+	case h.groupFormat + "=users":
+		return rdn[1], usersQuery, ldap.LDAPResultSuccess
+	case h.groupFormat + "=groups":
+		return rdn[1], groupsQuery, ldap.LDAPResultSuccess
+	}
+	return "", "", ldap.LDAPResultNoSuchObject
+}
+
+// getAccount returns the account with the given name.
+func (h ocisHandler) getAccount(ctx context.Context, name string) (*accountsmsg.Account, ldap.LDAPResultCode) {
+	res, err := h.as.ListAccounts(ctx, &accountssvc.ListAccountsRequest{
+		Query: fmt.Sprintf("on_premises_sam_account_name eq '%s'", escapeValue(name)),
+	})
+	if code := h.resultCode(err, "could not list accounts"); code != ldap.LDAPResultSuccess {
+		return nil, code
+	}
+	if len(res.Accounts) != 1 {
+		return nil, ldap.LDAPResultNoSuchObject
+	}
+	return res.Accounts[0], ldap.LDAPResultSuccess
+}
+
+// getGroup returns the group with the given name.
+func (h ocisHandler) getGroup(ctx context.Context, name string) (*accountsmsg.Group, ldap.LDAPResultCode) {
+	res, err := h.gs.ListGroups(ctx, &accountssvc.ListGroupsRequest{
+		Query: fmt.Sprintf("on_premises_sam_account_name eq '%s'", escapeValue(name)),
+	})
+	if code := h.resultCode(err, "could not list groups"); code != ldap.LDAPResultSuccess {
+		return nil, code
+	}
+	if len(res.Groups) != 1 {
+		return nil, ldap.LDAPResultNoSuchObject
+	}
+	return res.Groups[0], ldap.LDAPResultSuccess
+}
+
+// resultCode maps the errors of the accounts service to LDAP result codes.
+func (h ocisHandler) resultCode(err error, msg string) ldap.LDAPResultCode {
+	if err == nil {
+		return ldap.LDAPResultSuccess
+	}
+	h.log.Error().Err(err).Str("handler", "ocis").Msg(msg)
+	return accountsResultCode(err)
+}
+
+// accountsResultCode returns the LDAP result code for an error of the accounts service.
+func accountsResultCode(err error) ldap.LDAPResultCode {
+	if errors.Is(err, context.DeadlineExceeded) {
+		return ldap.LDAPResultTimeLimitExceeded
+	}
+	switch merrors.FromError(err).Code {
+	case http.StatusBadRequest:
+		return ldap.LDAPResultConstraintViolation
+	case http.StatusUnauthorized, http.StatusForbidden:
+		return ldap.LDAPResultInsufficientAccessRights
+	case http.StatusNotFound:
+		return ldap.LDAPResultNoSuchObject
+	case http.StatusConflict:
+		return ldap.LDAPResultEntryAlreadyExists
+	case http.StatusRequestTimeout:
+		return ldap.LDAPResultTimeLimitExceeded
+	}
+	return ldap.LDAPResultOperationsError
+}
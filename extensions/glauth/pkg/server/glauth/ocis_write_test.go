@@ -0,0 +1,565 @@
+package glauth
+
+import (
+	"context"
+	"net"
+	"regexp"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/go-ldap/ldap/v3"
+	"github.com/go-logr/logr"
+	"github.com/gofrs/uuid"
+	ber "github.com/nmcclain/asn1-ber"
+	nldap "github.com/nmcclain/ldap"
+	accounts "github.com/owncloud/ocis/extensions/accounts/pkg/service/v0"
+	"github.com/owncloud/ocis/ocis-pkg/log"
+	"github.com/owncloud/ocis/ocis-pkg/middleware"
+	"github.com/owncloud/ocis/ocis-pkg/roles"
+	accountsmsg "github.com/owncloud/ocis/protogen/gen/ocis/messages/accounts/v0"
+	settingsmsg "github.com/owncloud/ocis/protogen/gen/ocis/messages/settings/v0"
+	accountssvc "github.com/owncloud/ocis/protogen/gen/ocis/services/accounts/v0"
+	settingssvc "github.com/owncloud/ocis/protogen/gen/ocis/services/settings/v0"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go-micro.dev/v4/client"
+	merrors "go-micro.dev/v4/errors"
+	"go-micro.dev/v4/metadata"
+	"google.golang.org/protobuf/types/known/emptypb"
+)
+
+const (
+	testBaseDN    = "dc=ocis,dc=test"
+	adminRoleID   = "admin-role"
+	adminPassword = "admin"
+)
+
+var queryTerm = regexp.MustCompile(`^(?:(id|on_premises_sam_account_name|login|display_name) eq '([^']*)'|startswith\((on_premises_sam_account_name|display_name),'([^']*)'\))$`)
+
+var passwordQuery = regexp.MustCompile(` and password eq '.*'$`)
+
+// matchQuery evaluates the subset of the accounts index queries used by the ocis handler.
+func matchQuery(query, id, name, displayName string) bool {
+	if query == "" {
+		return true
+	}
+	// every password is accepted
+	query = passwordQuery.ReplaceAllString(query, "")
+	properties := map[string]string{"id": id, "on_premises_sam_account_name": name, "login": name, "display_name": displayName}
+	for _, term := range strings.Split(query, " or ") {
+		m := queryTerm.FindStringSubmatch(term)
+		switch {
+		case m == nil:
+		case m[1] != "" && strings.EqualFold(properties[m[1]], m[2]):
+			return true
+		case m[3] != "" && strings.HasPrefix(strings.ToLower(properties[m[3]]), strings.ToLower(m[4])):
+			return true
+		}
+	}
+	return false
+}
+
+// directory is an in-memory accounts and groups service.
+type directory struct {
+	mu       sync.Mutex
+	accounts map[string]*accountsmsg.Account
+	groups   map[string]*accountsmsg.Group
+	// listRoles are the roles of the last list request
+	listRoles string
+	// listHook is called by every list request, if set
+	listHook func(ctx context.Context) error
+}
+
+func newDirectory() *directory {
+	d := &directory{
+		accounts: map[string]*accountsmsg.Account{},
+		groups:   map[string]*accountsmsg.Group{},
+	}
+	for _, name := range []string{"admin", "einstein", "marie"} {
+		d.accounts[name+"-id"] = &accountsmsg.Account{
+			Id:                       name + "-id",
+			PreferredName:            name,
+			OnPremisesSamAccountName: name,
+			DisplayName:              name,
+			AccountEnabled:           true,
+		}
+	}
+	return d
+}
+
+func (d *directory) account(name string) *accountsmsg.Account {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for _, a := range d.accounts {
+		if a.OnPremisesSamAccountName == name {
+			return a
+		}
+	}
+	return nil
+}
+
+func (d *directory) group(name string) *accountsmsg.Group {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for _, g := range d.groups {
+		if g.OnPremisesSamAccountName == name {
+			return g
+		}
+	}
+	return nil
+}
+
+func (d *directory) accountsService() accountssvc.AccountsService {
+	return accountssvc.MockAccountsService{
+		ListFunc: func(ctx context.Context, in *accountssvc.ListAccountsRequest, opts ...client.CallOption) (*accountssvc.ListAccountsResponse, error) {
+			res := &accountssvc.ListAccountsResponse{}
+			roleIDs, _ := metadata.Get(ctx, middleware.RoleIDs)
+			d.mu.Lock()
+			d.listRoles = roleIDs
+			hook := d.listHook
+			d.mu.Unlock()
+			if roleIDs == "[]" {
+				return nil, merrors.Forbidden("accounts", "no permission for ListAccounts")
+			}
+			if hook != nil {
+				if err := hook(ctx); err != nil {
+					return nil, err
+				}
+			}
+			d.mu.Lock()
+			defer d.mu.Unlock()
+			for _, a := range d.accounts {
+				if matchQuery(in.Query, a.Id, a.OnPremisesSamAccountName, a.DisplayName) {
+					res.Accounts = append(res.Accounts, a)
+				}
+			}
+			return res, nil
+		},
+		CreateFunc: func(ctx context.Context, in *accountssvc.CreateAccountRequest, opts ...client.CallOption) (*accountsmsg.Account, error) {
+			if d.account(in.Account.OnPremisesSamAccountName) != nil {
+				return nil, merrors.Conflict("accounts", "account already exists")
+			}
+			d.mu.Lock()
+			defer d.mu.Unlock()
+			in.Account.Id = uuid.Must(uuid.NewV4()).String()
+			d.accounts[in.Account.Id] = in.Account
+			return in.Account, nil
+		},
+		UpdateFunc: func(ctx context.Context, in *accountssvc.UpdateAccountRequest, opts ...client.CallOption) (*accountsmsg.Account, error) {
+			d.mu.Lock()
+			defer d.mu.Unlock()
+			a, ok := d.accounts[in.Account.Id]
+			if !ok {
+				return nil, merrors.NotFound("accounts", "account not found")
+			}
+			for _, p := range in.UpdateMask.Paths {
+				switch p {
+				case "DisplayName":
+					a.DisplayName = in.Account.DisplayName
+				case "Mail":
+					a.Mail = in.Account.Mail
+				case "Description":
+					a.Description = in.Account.Description
+				case "UidNumber":
+					a.UidNumber = in.Account.UidNumber
+				case "GidNumber":
+					a.GidNumber = in.Account.GidNumber
+				case "PasswordProfile.Password":
+					a.PasswordProfile = in.Account.PasswordProfile
+				default:
+					return nil, merrors.BadRequest("accounts", "unknown path %s", p)
+				}
+			}
+			return a, nil
+		},
+		DeleteFunc: func(ctx context.Context, in *accountssvc.DeleteAccountRequest, opts ...client.CallOption) (*emptypb.Empty, error) {
+			d.mu.Lock()
+			defer d.mu.Unlock()
+			if _, ok := d.accounts[in.Id]; !ok {
+				return nil, merrors.NotFound("accounts", "account not found")
+			}
+			delete(d.accounts, in.Id)
+			return &emptypb.Empty{}, nil
+		},
+	}
+}
+
+// groupsService implements the accountssvc.GroupsService interface with a directory.
+type groupsService struct {
+	d *directory
+}
+
+func (s groupsService) ListGroups(ctx context.Context, in *accountssvc.ListGroupsRequest, opts ...client.CallOption) (*accountssvc.ListGroupsResponse, error) {
+	res := &accountssvc.ListGroupsResponse{}
+	s.d.mu.Lock()
+	defer s.d.mu.Unlock()
+	for _, g := range s.d.groups {
+		if matchQuery(in.Query, g.Id, g.OnPremisesSamAccountName, g.DisplayName) {
+			res.Groups = append(res.Groups, g)
+		}
+	}
+	return res, nil
+}
+
+func (s groupsService) GetGroup(ctx context.Context, in *accountssvc.GetGroupRequest, opts ...client.CallOption) (*accountsmsg.Group, error) {
+	panic("not implemented")
+}
+
+func (s groupsService) CreateGroup(ctx context.Context, in *accountssvc.CreateGroupRequest, opts ...client.CallOption) (*accountsmsg.Group, error) {
+	if s.d.group(in.Group.OnPremisesSamAccountName) != nil {
+		return nil, merrors.Conflict("accounts", "group already exists")
+	}
+	s.d.mu.Lock()
+	defer s.d.mu.Unlock()
+	in.Group.Id = uuid.Must(uuid.NewV4()).String()
+	s.d.groups[in.Group.Id] = in.Group
+	return in.Group, nil
+}
+
+func (s groupsService) UpdateGroup(ctx context.Context, in *accountssvc.UpdateGroupRequest, opts ...client.CallOption) (*accountsmsg.Group, error) {
+	return nil, merrors.InternalServerError("accounts", "not implemented")
+}
+
+func (s groupsService) DeleteGroup(ctx context.Context, in *accountssvc.DeleteGroupRequest, opts ...client.CallOption) (*emptypb.Empty, error) {
+	s.d.mu.Lock()
+	defer s.d.mu.Unlock()
+	if _, ok := s.d.groups[in.Id]; !ok {
+		return nil, merrors.NotFound("accounts", "group not found")
+	}
+	delete(s.d.groups, in.Id)
+	return &emptypb.Empty{}, nil
+}
+
+func (s groupsService) AddMember(ctx context.Context, in *accountssvc.AddMemberRequest, opts ...client.CallOption) (*accountsmsg.Group, error) {
+	s.d.mu.Lock()
+	defer s.d.mu.Unlock()
+	g, a := s.d.groups[in.GroupId], s.d.accounts[in.AccountId]
+	if g == nil || a == nil {
+		return nil, merrors.NotFound("accounts", "group or account not found")
+	}
+	g.Members = append(g.Members, a)
+	return g, nil
+}
+
+func (s groupsService) RemoveMember(ctx context.Context, in *accountssvc.RemoveMemberRequest, opts ...client.CallOption) (*accountsmsg.Group, error) {
+	s.d.mu.Lock()
+	defer s.d.mu.Unlock()
+	g := s.d.groups[in.GroupId]
+	if g == nil {
+		return nil, merrors.NotFound("accounts", "group not found")
+	}
+	members := g.Members[:0]
+	for _, m := range g.Members {
+		if m.Id != in.AccountId {
+			members = append(members, m)
+		}
+	}
+	g.Members = members
+	return g, nil
+}
+
+func (s groupsService) ListMembers(ctx context.Context, in *accountssvc.ListMembersRequest, opts ...client.CallOption) (*accountssvc.ListMembersResponse, error) {
+	panic("not implemented")
+}
+
+// startServer serves the ocis handler of the directory on a random port and returns the address of the server.
+func startServer(t *testing.T, d *directory) string {
+	rm := roles.NewManager(
+		roles.Logger(log.NewLogger(log.Level("error"))),
+		roles.RoleService(settingssvc.MockRoleService{
+			ListRoleAssignmentsFunc: func(ctx context.Context, req *settingssvc.ListRoleAssignmentsRequest, opts ...client.CallOption) (*settingssvc.ListRoleAssignmentsResponse, error) {
+				res := &settingssvc.ListRoleAssignmentsResponse{}
+				if req.AccountUuid == "admin-id" {
+					res.Assignments = append(res.Assignments, &settingsmsg.UserRoleAssignment{AccountUuid: req.AccountUuid, RoleId: adminRoleID})
+				}
+				return res, nil
+			},
+			ListRolesFunc: func(ctx context.Context, req *settingssvc.ListBundlesRequest, opts ...client.CallOption) (*settingssvc.ListBundlesResponse, error) {
+				return &settingssvc.ListBundlesResponse{Bundles: []*settingsmsg.Bundle{{
+					Id:       adminRoleID,
+					Settings: []*settingsmsg.Setting{{Id: accounts.AccountManagementPermissionID}},
+				}}}, nil
+			},
+		}),
+	)
+	h := NewOCISHandler(
+		AccountsService(d.accountsService()),
+		GroupsService(groupsService{d: d}),
+		Logger(log.NewLogger(log.Level("error"))),
+		BaseDN(testBaseDN),
+		NameFormat("cn"),
+		GroupFormat("ou"),
+		RoleBundleUUID("service-role"),
+		RoleManager(&rm),
+	)
+
+	s := nldap.NewServer()
+	s.EnforceLDAP = true
+	s.BindFunc("", h)
+	s.SearchFunc("", h)
+	s.AddFunc("", h)
+	s.ModifyFunc("", h)
+	s.DeleteFunc("", h)
+	s.CloseFunc("", h)
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	go func() {
+		_ = ldapServer{Server: s, log: logr.Discard()}.Serve(ln)
+	}()
+	t.Cleanup(func() {
+		s.Quit <- true
+	})
+	return ln.Addr().String()
+}
+
+func connect(t *testing.T, addr, user string) *ldap.Conn {
+	c, err := ldap.Dial("tcp", addr)
+	require.NoError(t, err)
+	t.Cleanup(c.Close)
+	require.NoError(t, c.Bind("cn="+user+",ou=users,"+testBaseDN, adminPassword))
+	return c
+}
+
+func resultCode(err error) uint16 {
+	if err == nil {
+		return ldap.LDAPResultSuccess
+	}
+	if e, ok := err.(*ldap.Error); ok {
+		return e.ResultCode
+	}
+	return ldap.ErrorNetwork
+}
+
+func TestAddAccount(t *testing.T) {
+	d := newDirectory()
+	c := connect(t, startServer(t, d), "admin")
+
+	add := ldap.NewAddRequest("cn=moss,ou=users,"+testBaseDN, nil)
+	add.Attribute("objectClass", []string{"posixAccount", "inetOrgPerson"})
+	add.Attribute("displayName", []string{"Maurice Moss"})
+	add.Attribute("mail", []string{"moss@example.org"})
+	add.Attribute("uidNumber", []string{"30000"})
+	add.Attribute("userPassword", []string{"secret"})
+	require.NoError(t, c.Add(add))
+
+	a := d.account("moss")
+	require.NotNil(t, a)
+	assert.Equal(t, "Maurice Moss", a.DisplayName)
+	assert.Equal(t, "moss@example.org", a.Mail)
+	assert.Equal(t, int64(30000), a.UidNumber)
+	assert.Equal(t, "secret", a.PasswordProfile.Password)
+	assert.True(t, a.AccountEnabled)
+
+	assert.Equal(t, uint16(ldap.LDAPResultEntryAlreadyExists), resultCode(c.Add(add)))
+
+	add = ldap.NewAddRequest("cn=roy,ou=users,"+testBaseDN, nil)
+	add.Attribute("uidNumber", []string{"thirty"})
+	assert.Equal(t, uint16(ldap.LDAPResultInvalidAttributeSyntax), resultCode(c.Add(add)))
+
+	add = ldap.NewAddRequest("cn=roy,ou=people,"+testBaseDN, nil)
+	assert.Equal(t, uint16(ldap.LDAPResultNoSuchObject), resultCode(c.Add(add)))
+}
+
+func TestAddInetOrgPerson(t *testing.T) {
+	d := newDirectory()
+	c := connect(t, startServer(t, d), "admin")
+
+	add := ldap.NewAddRequest("cn=moss,ou=users,"+testBaseDN, nil)
+	add.Attribute("objectClass", []string{"top", "person", "organizationalPerson", "inetOrgPerson"})
+	add.Attribute("cn", []string{"Moss"})
+	add.Attribute("sn", []string{"Moss"})
+	add.Attribute("uid", []string{"moss"})
+	add.Attribute("mail", []string{"moss@example.org"})
+	add.Attribute("displayName", []string{"Maurice Moss"})
+	add.Attribute("userPassword", []string{"secret"})
+	require.NoError(t, c.Add(add))
+
+	a := d.account("moss")
+	require.NotNil(t, a)
+	assert.Equal(t, "moss", a.OnPremisesSamAccountName)
+	assert.Equal(t, "Maurice Moss", a.DisplayName)
+	assert.Equal(t, "moss@example.org", a.Mail)
+	assert.Equal(t, "secret", a.PasswordProfile.Password)
+
+	for attr, values := range map[string][]string{
+		"cn":  {"roy"},
+		"uid": {"jen", "jen.barber"},
+	} {
+		add = ldap.NewAddRequest("cn=jen,ou=users,"+testBaseDN, nil)
+		add.Attribute(attr, values)
+		assert.Equal(t, uint16(ldap.LDAPResultConstraintViolation), resultCode(c.Add(add)), attr)
+	}
+	assert.Nil(t, d.account("jen"))
+}
+
+func TestModifyAccount(t *testing.T) {
+	d := newDirectory()
+	c := connect(t, startServer(t, d), "admin")
+
+	mod := ldap.NewModifyRequest("cn=einstein,ou=users,"+testBaseDN, nil)
+	mod.Replace("displayName", []string{"Albert Einstein"})
+	mod.Add("mail", []string{"einstein@example.org"})
+	mod.Replace("gidNumber", []string{"30000"})
+	require.NoError(t, c.Modify(mod))
+
+	a := d.account("einstein")
+	assert.Equal(t, "Albert Einstein", a.DisplayName)
+	assert.Equal(t, "einstein@example.org", a.Mail)
+	assert.Equal(t, int64(30000), a.GidNumber)
+
+	mod = ldap.NewModifyRequest("cn=einstein,ou=users,"+testBaseDN, nil)
+	mod.Delete("mail", nil)
+	require.NoError(t, c.Modify(mod))
+	assert.Equal(t, "", d.account("einstein").Mail)
+
+	for attr, code := range map[string]uint16{
+		"cn":             ldap.LDAPResultNotAllowedOnRDN,
+		"ownCloudUUID":   ldap.LDAPResultUnwillingToPerform,
+		"accountEnabled": ldap.LDAPResultUnwillingToPerform,
+	} {
+		mod = ldap.NewModifyRequest("cn=einstein,ou=users,"+testBaseDN, nil)
+		mod.Replace(attr, []string{"value"})
+		assert.Equal(t, code, resultCode(c.Modify(mod)), attr)
+	}
+
+	mod = ldap.NewModifyRequest("cn=einstein,ou=users,"+testBaseDN, nil)
+	mod.Replace("mail", []string{"a@example.org", "b@example.org"})
+	assert.Equal(t, uint16(ldap.LDAPResultConstraintViolation), resultCode(c.Modify(mod)))
+
+	mod = ldap.NewModifyRequest("cn=unknown,ou=users,"+testBaseDN, nil)
+	mod.Replace("mail", []string{"unknown@example.org"})
+	assert.Equal(t, uint16(ldap.LDAPResultNoSuchObject), resultCode(c.Modify(mod)))
+}
+
+func TestGroupMembers(t *testing.T) {
+	d := newDirectory()
+	c := connect(t, startServer(t, d), "admin")
+
+	add := ldap.NewAddRequest("cn=physicists,ou=groups,"+testBaseDN, nil)
+	add.Attribute("objectClass", []string{"posixGroup"})
+	add.Attribute("description", []string{"Physics"})
+	add.Attribute("memberUid", []string{"einstein"})
+	require.NoError(t, c.Add(add))
+
+	members := func() []string {
+		var names []string
+		for _, m := range d.group("physicists").Members {
+			names = append(names, m.OnPremisesSamAccountName)
+		}
+		return names
+	}
+	g := d.group("physicists")
+	require.NotNil(t, g)
+	assert.Equal(t, "Physics", g.Description)
+	assert.Equal(t, []string{"einstein"}, members())
+
+	mod := ldap.NewModifyRequest("cn=physicists,ou=groups,"+testBaseDN, nil)
+	mod.Add("member", []string{"cn=marie,ou=users," + testBaseDN})
+	require.NoError(t, c.Modify(mod))
+	assert.ElementsMatch(t, []string{"einstein", "marie"}, members())
+
+	mod = ldap.NewModifyRequest("cn=physicists,ou=groups,"+testBaseDN, nil)
+	mod.Delete("memberUid", []string{"einstein"})
+	require.NoError(t, c.Modify(mod))
+	assert.Equal(t, []string{"marie"}, members())
+
+	mod = ldap.NewModifyRequest("cn=physicists,ou=groups,"+testBaseDN, nil)
+	mod.Replace("memberUid", []string{"admin", "einstein"})
+	require.NoError(t, c.Modify(mod))
+	assert.ElementsMatch(t, []string{"admin", "einstein"}, members())
+
+	mod = ldap.NewModifyRequest("cn=physicists,ou=groups,"+testBaseDN, nil)
+	mod.Add("memberUid", []string{"unknown"})
+	assert.Equal(t, uint16(ldap.LDAPResultConstraintViolation), resultCode(c.Modify(mod)))
+
+	mod = ldap.NewModifyRequest("cn=physicists,ou=groups,"+testBaseDN, nil)
+	mod.Replace("description", []string{"Physicists"})
+	assert.Equal(t, uint16(ldap.LDAPResultUnwillingToPerform), resultCode(c.Modify(mod)))
+
+	add = ldap.NewAddRequest("cn=chemists,ou=groups,"+testBaseDN, nil)
+	add.Attribute("memberUid", []string{"unknown"})
+	assert.Equal(t, uint16(ldap.LDAPResultConstraintViolation), resultCode(c.Add(add)))
+	assert.Nil(t, d.group("chemists"))
+}
+
+func TestDelete(t *testing.T) {
+	d := newDirectory()
+	c := connect(t, startServer(t, d), "admin")
+
+	add := ldap.NewAddRequest("cn=physicists,ou=groups,"+testBaseDN, nil)
+	require.NoError(t, c.Add(add))
+
+	require.NoError(t, c.Del(ldap.NewDelRequest("cn=physicists,ou=groups,"+testBaseDN, nil)))
+	assert.Nil(t, d.group("physicists"))
+	require.NoError(t, c.Del(ldap.NewDelRequest("cn=einstein,ou=users,"+testBaseDN, nil)))
+	assert.Nil(t, d.account("einstein"))
+
+	err := c.Del(ldap.NewDelRequest("cn=einstein,ou=users,"+testBaseDN, nil))
+	assert.Equal(t, uint16(ldap.LDAPResultNoSuchObject), resultCode(err))
+}
+
+func TestWriteRequiresAccountManagementPermission(t *testing.T) {
+	d := newDirectory()
+	c := connect(t, startServer(t, d), "einstein")
+
+	add := ldap.NewAddRequest("cn=moss,ou=users,"+testBaseDN, nil)
+	assert.Equal(t, uint16(ldap.LDAPResultInsufficientAccessRights), resultCode(c.Add(add)))
+	assert.Nil(t, d.account("moss"))
+
+	mod := ldap.NewModifyRequest("cn=marie,ou=users,"+testBaseDN, nil)
+	mod.Replace("mail", []string{"marie@example.org"})
+	assert.Equal(t, uint16(ldap.LDAPResultInsufficientAccessRights), resultCode(c.Modify(mod)))
+
+	err := c.Del(ldap.NewDelRequest("cn=marie,ou=users,"+testBaseDN, nil))
+	assert.Equal(t, uint16(ldap.LDAPResultInsufficientAccessRights), resultCode(err))
+	assert.NotNil(t, d.account("marie"))
+}
+
+func TestParseDN(t *testing.T) {
+	h := ocisHandler{basedn: testBaseDN, nameFormat: "cn", groupFormat: "ou"}
+	for dn, want := range map[string]struct {
+		name  string
+		qtype queryType
+		code  nldap.LDAPResultCode
+	}{
+		"cn=einstein,ou=users,dc=ocis,dc=test":      {"einstein", usersQuery, nldap.LDAPResultSuccess},
+		"CN=Einstein, OU=Users,DC=ocis,DC=test":     {"Einstein", usersQuery, nldap.LDAPResultSuccess},
+		"cn=physicists,ou=groups,dc=ocis,dc=test":   {"physicists", groupsQuery, nldap.LDAPResultSuccess},
+		"uid=einstein,ou=users,dc=ocis,dc=test":     {"", "", nldap.LDAPResultNamingViolation},
+		"cn=einstein,ou=people,dc=ocis,dc=test":     {"", "", nldap.LDAPResultNoSuchObject},
+		"cn=einstein,ou=users,dc=example,dc=org":    {"", "", nldap.LDAPResultNoSuchObject},
+		"cn=a,cn=einstein,ou=users,dc=ocis,dc=test": {"", "", nldap.LDAPResultNoSuchObject},
+	} {
+		name, qtype, code := h.parseDN(dn)
+		assert.Equal(t, want.name, name, dn)
+		assert.Equal(t, want.qtype, qtype, dn)
+		assert.Equal(t, want.code, code, dn)
+	}
+}
+
+func TestUnknownOperation(t *testing.T) {
+	conn, err := net.Dial("tcp", startServer(t, newDirectory()))
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+
+	p := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "LDAP Request")
+	p.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagInteger, uint64(1), "Message ID"))
+	p.AppendChild(ber.Encode(ber.ClassApplication, ber.TypeConstructed, 30, nil, "Unknown Request"))
+	_, err = conn.Write(p.Bytes())
+	require.NoError(t, err)
+
+	res, err := ber.ReadPacket(conn)
+	require.NoError(t, err)
+	require.Len(t, res.Children, 2)
+	assert.Equal(t, uint64(0), res.Children[0].Value)
+	notice := res.Children[1]
+	assert.Equal(t, uint8(nldap.ApplicationExtendedResponse), notice.Tag)
+	require.Len(t, notice.Children, 4)
+	assert.Equal(t, uint64(nldap.LDAPResultUnwillingToPerform), notice.Children[0].Value)
+	assert.Equal(t, noticeOfDisconnectionOID, notice.Children[3].Data.String())
+
+	_, err = ber.ReadPacket(conn)
+	assert.Error(t, err)
+}
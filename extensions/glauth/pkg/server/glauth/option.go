@@ -7,6 +7,7 @@ import (
 
 	"github.com/glauth/glauth/v2/pkg/config"
 	"github.com/owncloud/ocis/ocis-pkg/log"
+	"github.com/owncloud/ocis/ocis-pkg/roles"
 )
 
 // Option defines a single option function.
@@ -26,6 +27,7 @@ type Options struct {
 	RoleBundleUUID  string
 	AccountsService accountssvc.AccountsService
 	GroupsService   accountssvc.GroupsService
+	RoleManager     *roles.Manager
 }
 
 // newOptions initializes the available default options.
@@ -122,3 +124,10 @@ func RoleBundleUUID(val string) Option {
 		o.RoleBundleUUID = val
 	}
 }
+
+// RoleManager provides a roles.Manager to look up the roles of the bound users.
+func RoleManager(val *roles.Manager) Option {
+	return func(o *Options) {
+		o.RoleManager = val
+	}
+}
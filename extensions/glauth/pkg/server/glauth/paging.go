@@ -0,0 +1,91 @@
+package glauth
+
+import (
+	"fmt"
+	"hash/fnv"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/nmcclain/ldap"
+)
+
+// pagedResults implements the Simple Paged Results control of RFC 2696. The accounts service can't page, so every
+// page is cut from the complete result, which is read from the accounts service and sorted again for every page. The
+// cookie holds the offset of the next page and a fingerprint of the search, a cookie is only accepted for the search
+// it was returned for.
+type pagedResults struct {
+	size        int
+	offset      int
+	fingerprint string
+	abandon     bool
+}
+
+// newPagedResults returns the paging of a search request, or nil if the request doesn't ask for paged results.
+func newPagedResults(bindDN string, req ldap.SearchRequest) (*pagedResults, ldap.LDAPResultCode) {
+	c := ldap.FindControl(req.Controls, ldap.ControlTypePaging)
+	if c == nil {
+		return nil, ldap.LDAPResultSuccess
+	}
+	control, ok := c.(*ldap.ControlPaging)
+	if !ok {
+		return nil, ldap.LDAPResultProtocolError
+	}
+	if req.SizeLimit > 0 && int(control.PagingSize) >= req.SizeLimit {
+		// the size limit is smaller than a page, RFC 2696 asks to ignore the control then
+		return nil, ldap.LDAPResultSuccess
+	}
+
+	p := &pagedResults{
+		size:        int(control.PagingSize),
+		fingerprint: searchFingerprint(bindDN, req),
+	}
+	if len(control.Cookie) == 0 {
+		return p, ldap.LDAPResultSuccess
+	}
+	parts := strings.SplitN(string(control.Cookie), ":", 2)
+	if len(parts) != 2 || parts[1] != p.fingerprint {
+		return nil, ldap.LDAPResultUnwillingToPerform
+	}
+	offset, err := strconv.Atoi(parts[0])
+	if err != nil || offset < 0 {
+		return nil, ldap.LDAPResultUnwillingToPerform
+	}
+	p.offset = offset
+	// a page size of 0 with a cookie abandons the paged search
+	p.abandon = p.size == 0
+	return p, ldap.LDAPResultSuccess
+}
+
+// page returns the entries of the requested page and the control to send with them. The entries are sorted by their
+// DN, so that the pages are stable.
+func (p *pagedResults) page(entries []*ldap.Entry) ([]*ldap.Entry, *ldap.ControlPaging) {
+	control := &ldap.ControlPaging{PagingSize: uint32(len(entries))}
+	if p.abandon {
+		return []*ldap.Entry{}, control
+	}
+	sort.SliceStable(entries, func(i, j int) bool {
+		return strings.ToLower(entries[i].DN) < strings.ToLower(entries[j].DN)
+	})
+
+	start := p.offset
+	if start > len(entries) {
+		start = len(entries)
+	}
+	end := len(entries)
+	if p.size > 0 && start+p.size < end {
+		end = start + p.size
+		control.Cookie = []byte(fmt.Sprintf("%d:%s", end, p.fingerprint))
+	}
+	return entries[start:end], control
+}
+
+// searchFingerprint identifies a search by the bound user and its parameters, except the controls and limits.
+func searchFingerprint(bindDN string, req ldap.SearchRequest) string {
+	h := fnv.New64a()
+	for _, v := range []string{strings.ToLower(bindDN), strings.ToLower(req.BaseDN), strconv.Itoa(req.Scope), req.Filter} {
+		_, _ = h.Write([]byte(v))
+		_, _ = h.Write([]byte{0})
+	}
+	return strconv.FormatUint(h.Sum64(), 16)
+}
@@ -1,8 +1,10 @@
 package glauth
 
 import (
+	"crypto/tls"
 	"errors"
 	"fmt"
+	"net"
 
 	"github.com/GeertJohan/yubigo"
 	"github.com/glauth/glauth/v2/pkg/config"
@@ -20,7 +22,7 @@ type LdapSvc struct {
 	backend  *config.Config
 	fallback *config.Config
 	yubiAuth *yubigo.YubiAuth
-	l        *ldap.Server
+	l        ldapServer
 }
 
 // Server initializes the ldap server.
@@ -47,7 +49,7 @@ func Server(opts ...Option) (*LdapSvc, error) {
 	}
 
 	// configure the backend
-	s.l = ldap.NewServer()
+	s.l = ldapServer{Server: ldap.NewServer(), log: s.log}
 	s.l.EnforceLDAP = true
 	var bh handler.Handler
 
@@ -79,6 +81,7 @@ func Server(opts ...Option) (*LdapSvc, error) {
 			NameFormat(s.backend.Backend.NameFormat),
 			GroupFormat(s.backend.Backend.GroupFormat),
 			RoleBundleUUID(options.RoleBundleUUID),
+			RoleManager(options.RoleManager),
 		)
 	default:
 		return nil, fmt.Errorf("unsupported backend %s - must be 'ldap', 'owncloud' or 'accounts'", s.backend.Backend.Datastore)
@@ -117,6 +120,7 @@ func Server(opts ...Option) (*LdapSvc, error) {
 				NameFormat(s.fallback.Backend.NameFormat),
 				GroupFormat(s.fallback.Backend.GroupFormat),
 				RoleBundleUUID(options.RoleBundleUUID),
+				RoleManager(options.RoleManager),
 			)
 		default:
 			return nil, fmt.Errorf("unsupported fallback %s - must be 'ldap', 'owncloud' or 'accounts'", s.fallback.Backend.Datastore)
@@ -129,6 +133,9 @@ func Server(opts ...Option) (*LdapSvc, error) {
 	s.l.BindFunc(s.backend.Backend.BaseDN, bh)
 	s.l.SearchFunc(s.backend.Backend.BaseDN, bh)
 	s.l.CloseFunc(s.backend.Backend.BaseDN, bh)
+	s.l.AddFunc(s.backend.Backend.BaseDN, bh)
+	s.l.ModifyFunc(s.backend.Backend.BaseDN, bh)
+	s.l.DeleteFunc(s.backend.Backend.BaseDN, bh)
 
 	return &s, nil
 }
@@ -136,17 +143,28 @@ func Server(opts ...Option) (*LdapSvc, error) {
 // ListenAndServe listens on the TCP network address s.c.LDAP.Listen
 func (s *LdapSvc) ListenAndServe() error {
 	s.log.V(3).Info("ldap server listening", "address", s.ldap.Listen)
-	return s.l.ListenAndServe(s.ldap.Listen)
+	ln, err := net.Listen("tcp", s.ldap.Listen)
+	if err != nil {
+		return err
+	}
+	return s.l.Serve(ln)
 }
 
 // ListenAndServeTLS listens on the TCP network address s.c.LDAPS.Listen
 func (s *LdapSvc) ListenAndServeTLS() error {
 	s.log.V(3).Info("ldaps server listening", "address", s.ldaps.Listen)
-	return s.l.ListenAndServeTLS(
-		s.ldaps.Listen,
-		s.ldaps.Cert,
-		s.ldaps.Key,
-	)
+	cert, err := tls.LoadX509KeyPair(s.ldaps.Cert, s.ldaps.Key)
+	if err != nil {
+		return err
+	}
+	ln, err := tls.Listen("tcp", s.ldaps.Listen, &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		ServerName:   "localhost",
+	})
+	if err != nil {
+		return err
+	}
+	return s.l.Serve(ln)
 }
 
 // Shutdown ends listeners by sending true to the ldap serves quit channel
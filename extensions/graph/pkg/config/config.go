@@ -21,13 +21,22 @@ type Config struct {
 	Reva         Reva         `yaml:"reva"`
 	TokenManager TokenManager `yaml:"token_manager"`
 
+	API      API      `yaml:"api"`
 	Spaces   Spaces   `yaml:"spaces"`
 	Identity Identity `yaml:"identity"`
+	Photos   Photos   `yaml:"photos"`
 	Events   Events   `yaml:"events"`
 
 	Context context.Context `yaml:"-"`
 }
 
+// API configures the behaviour of the graph api.
+type API struct {
+	MaxPageSize             int    `yaml:"max_page_size" env:"GRAPH_API_MAX_PAGE_SIZE" desc:"the maximum number of items returned per page of the users, groups and drives collections, the remaining items are linked with @odata.nextLink. 0 returns the whole collection unless a client requests a page with $top"`
+	UploadSessionExpiration int    `yaml:"upload_session_expiration" env:"GRAPH_API_UPLOAD_SESSION_EXPIRATION" desc:"the number of seconds an upload session can be used to upload the chunks of a file, it must not exceed the transfer expiration of the gateway"`
+	UploadSessionSecret     string `yaml:"upload_session_secret" env:"GRAPH_API_UPLOAD_SESSION_SECRET" desc:"the secret the upload sessions are signed and encrypted with, it has to be the same on all instances of the graph service. If it is empty the keys are derived from the jwt secret"`
+}
+
 type Spaces struct {
 	WebDavBase                      string `yaml:"webdav_base" env:"OCIS_URL;GRAPH_SPACES_WEBDAV_BASE"`
 	WebDavPath                      string `yaml:"webdav_path" env:"GRAPH_SPACES_WEBDAV_PATH"`
@@ -39,10 +48,14 @@ type Spaces struct {
 type LDAP struct {
 	URI           string `yaml:"uri" env:"LDAP_URI;GRAPH_LDAP_URI"`
 	Insecure      bool   `yaml:"insecure" env:"OCIS_INSECURE;GRAPH_LDAP_INSECURE"`
+	StartTLS      bool   `yaml:"start_tls" env:"GRAPH_LDAP_START_TLS" desc:"negotiate TLS with the StartTLS operation on ldap:// connections"`
 	BindDN        string `yaml:"bind_dn" env:"LDAP_BIND_DN;GRAPH_LDAP_BIND_DN"`
 	BindPassword  string `yaml:"bind_password" env:"LDAP_BIND_PASSWORD;GRAPH_LDAP_BIND_PASSWORD"`
 	UseServerUUID bool   `yaml:"use_server_uuid" env:"GRAPH_LDAP_SERVER_UUID"`
 	WriteEnabled  bool   `yaml:"write_enabled" env:"GRAPH_LDAP_SERVER_WRITE_ENABLED"`
+	PoolSize      int    `yaml:"pool_size" env:"GRAPH_LDAP_POOL_SIZE" desc:"the number of connections to the LDAP server, requests wait for an idle connection when all are in use"`
+	// IDMPasswordExpiry must not be set for other servers, they return unwillingToPerform for other failed binds too
+	IDMPasswordExpiry bool `yaml:"idm_password_expiry" env:"GRAPH_LDAP_IDM_PASSWORD_EXPIRY" desc:"the server is the bundled idm service, whose binds with expired passwords fail with unwillingToPerform, so users can still change expired passwords"`
 
 	UserBaseDN               string `yaml:"user_base_dn" env:"LDAP_USER_BASE_DN;GRAPH_LDAP_USER_BASE_DN"`
 	UserSearchScope          string `yaml:"user_search_scope" env:"LDAP_USER_SCOPE;GRAPH_LDAP_USER_SCOPE"`
@@ -53,17 +66,57 @@ type LDAP struct {
 	UserNameAttribute        string `yaml:"user_name_attribute" env:"LDAP_USER_SCHEMA_USERNAME;GRAPH_LDAP_USER_NAME_ATTRIBUTE"`
 	UserIDAttribute          string `yaml:"user_id_attribute" env:"LDAP_USER_SCHEMA_ID;GRAPH_LDAP_USER_UID_ATTRIBUTE"`
 
+	// the optional user attributes, the corresponding user properties are not supported if they are empty
+	UserSurnameAttribute           string `yaml:"user_surname_attribute" env:"GRAPH_LDAP_USER_SURNAME_ATTRIBUTE"`
+	UserGivenNameAttribute         string `yaml:"user_given_name_attribute" env:"GRAPH_LDAP_USER_GIVEN_NAME_ATTRIBUTE"`
+	UserPreferredLanguageAttribute string `yaml:"user_preferred_language_attribute" env:"GRAPH_LDAP_USER_PREFERRED_LANGUAGE_ATTRIBUTE"`
+	UserCreationTimeAttribute      string `yaml:"user_creation_time_attribute" env:"GRAPH_LDAP_USER_CREATION_TIME_ATTRIBUTE"`
+	// UserEnabledAttribute is a boolean attribute, users without the attribute are enabled
+	UserEnabledAttribute string `yaml:"user_enabled_attribute" env:"GRAPH_LDAP_USER_ENABLED_ATTRIBUTE"`
+
 	GroupBaseDN        string `yaml:"group_base_dn" env:"LDAP_GROUP_BASE_DN;GRAPH_LDAP_GROUP_BASE_DN"`
 	GroupSearchScope   string `yaml:"group_search_scope" env:"LDAP_GROUP_SCOPE;GRAPH_LDAP_GROUP_SEARCH_SCOPE"`
 	GroupFilter        string `yaml:"group_filter" env:"LDAP_GROUP_FILTER;GRAPH_LDAP_GROUP_FILTER"`
 	GroupObjectClass   string `yaml:"group_objectclass" env:"LDAP_GROUP_OBJECTCLASS;GRAPH_LDAP_GROUP_OBJECTCLASS"`
 	GroupNameAttribute string `yaml:"group_name_attribute" env:"LDAP_GROUP_SCHEMA_GROUPNAME;GRAPH_LDAP_GROUP_NAME_ATTRIBUTE"`
 	GroupIDAttribute   string `yaml:"group_id_attribute" env:"LDAP_GROUP_SCHEMA_ID;GRAPH_LDAP_GROUP_ID_ATTRIBUTE"`
+
+	// GroupNestingEnabled enables the resolution of groups which are members of other groups
+	GroupNestingEnabled  bool `yaml:"group_nesting_enabled" env:"GRAPH_LDAP_GROUP_NESTING_ENABLED"`
+	GroupNestingMaxDepth int  `yaml:"group_nesting_max_depth" env:"GRAPH_LDAP_GROUP_NESTING_MAX_DEPTH"`
+
+	EducationResourcesEnabled bool `yaml:"education_resources_enabled" env:"GRAPH_LDAP_EDUCATION_RESOURCES_ENABLED"`
+
+	SchoolBaseDN          string `yaml:"school_base_dn" env:"GRAPH_LDAP_SCHOOL_BASE_DN"`
+	SchoolSearchScope     string `yaml:"school_search_scope" env:"GRAPH_LDAP_SCHOOL_SEARCH_SCOPE"`
+	SchoolFilter          string `yaml:"school_filter" env:"GRAPH_LDAP_SCHOOL_FILTER"`
+	SchoolObjectClass     string `yaml:"school_objectclass" env:"GRAPH_LDAP_SCHOOL_OBJECTCLASS"`
+	SchoolNameAttribute   string `yaml:"school_name_attribute" env:"GRAPH_LDAP_SCHOOL_NAME_ATTRIBUTE"`
+	SchoolNumberAttribute string `yaml:"school_number_attribute" env:"GRAPH_LDAP_SCHOOL_NUMBER_ATTRIBUTE"`
+	SchoolIDAttribute     string `yaml:"school_id_attribute" env:"GRAPH_LDAP_SCHOOL_ID_ATTRIBUTE"`
+	// SchoolMemberAttribute holds the ids of the schools of users and classes
+	SchoolMemberAttribute string `yaml:"school_member_attribute" env:"GRAPH_LDAP_SCHOOL_MEMBER_ATTRIBUTE"`
+
+	ClassObjectClass          string `yaml:"class_objectclass" env:"GRAPH_LDAP_CLASS_OBJECTCLASS"`
+	ClassDescriptionAttribute string `yaml:"class_description_attribute" env:"GRAPH_LDAP_CLASS_DESCRIPTION_ATTRIBUTE"`
+	ClassExternalIDAttribute  string `yaml:"class_external_id_attribute" env:"GRAPH_LDAP_CLASS_EXTERNAL_ID_ATTRIBUTE"`
+
+	EducationUserObjectClass          string `yaml:"education_user_objectclass" env:"GRAPH_LDAP_EDUCATION_USER_OBJECTCLASS"`
+	EducationUserPrimaryRoleAttribute string `yaml:"education_user_primary_role_attribute" env:"GRAPH_LDAP_EDUCATION_USER_PRIMARY_ROLE_ATTRIBUTE"`
 }
 
 type Identity struct {
-	Backend string `yaml:"backend" env:"GRAPH_IDENTITY_BACKEND"`
-	LDAP    LDAP   `yaml:"ldap"`
+	Backend  string `yaml:"backend" env:"GRAPH_IDENTITY_BACKEND"`
+	CacheTTL int    `yaml:"cache_ttl" env:"GRAPH_IDENTITY_CACHE_TTL" desc:"the number of seconds users, groups and their memberships are cached, 0 disables the cache"`
+	LDAP     LDAP   `yaml:"ldap"`
+}
+
+// Photos configures the profile photos of the users.
+type Photos struct {
+	RootDirectory string `yaml:"root_directory" env:"GRAPH_PHOTOS_ROOT_DIRECTORY" desc:"the directory the profile photos of the users are stored in"`
+	Resolution    string `yaml:"resolution" env:"GRAPH_PHOTOS_RESOLUTION" desc:"the resolution uploaded profile photos are resized to, e.g. 240x240"`
+	MaxUploadSize int64  `yaml:"max_upload_size" env:"GRAPH_PHOTOS_MAX_UPLOAD_SIZE" desc:"the maximum size in bytes of an uploaded profile photo"`
+	MaxPixels     int64  `yaml:"max_pixels" env:"GRAPH_PHOTOS_MAX_PIXELS" desc:"the maximum number of pixels of an uploaded profile photo, larger images are rejected before they are decoded"`
 }
 
 // Events combines the configuration options for the event bus.
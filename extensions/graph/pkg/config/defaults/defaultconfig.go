@@ -1,9 +1,11 @@
 package defaults
 
 import (
+	"path"
 	"strings"
 
 	"github.com/owncloud/ocis/extensions/graph/pkg/config"
+	"github.com/owncloud/ocis/ocis-pkg/config/defaults"
 )
 
 func DefaultConfig() *config.Config {
@@ -26,6 +28,10 @@ func DefaultConfig() *config.Config {
 		TokenManager: config.TokenManager{
 			JWTSecret: "Pive-Fumkiu4",
 		},
+		API: config.API{
+			MaxPageSize:             1000,
+			UploadSessionExpiration: 12 * 60 * 60,
+		},
 		Spaces: config.Spaces{
 			WebDavBase:   "https://localhost:9200",
 			WebDavPath:   "/dav/spaces/",
@@ -33,7 +39,8 @@ func DefaultConfig() *config.Config {
 			Insecure:     false,
 		},
 		Identity: config.Identity{
-			Backend: "cs3",
+			Backend:  "cs3",
+			CacheTTL: 0,
 			LDAP: config.LDAP{
 				URI:                      "ldap://localhost:9125",
 				Insecure:                 false,
@@ -41,6 +48,7 @@ func DefaultConfig() *config.Config {
 				BindPassword:             "",
 				UseServerUUID:            false,
 				WriteEnabled:             false,
+				PoolSize:                 4,
 				UserBaseDN:               "ou=users,dc=ocis,dc=test",
 				UserSearchScope:          "sub",
 				UserFilter:               "",
@@ -50,15 +58,46 @@ func DefaultConfig() *config.Config {
 				UserNameAttribute:        "uid",
 				// FIXME: switch this to some more widely available attribute by default
 				//        ideally this needs to	be constant for the lifetime of a users
-				UserIDAttribute:    "owncloudUUID",
+				UserIDAttribute: "owncloudUUID",
+
+				UserSurnameAttribute:           "sn",
+				UserGivenNameAttribute:         "givenName",
+				UserPreferredLanguageAttribute: "preferredLanguage",
+				UserCreationTimeAttribute:      "createTimestamp",
+				UserEnabledAttribute:           "ownCloudUserEnabled",
+
 				GroupBaseDN:        "ou=groups,dc=ocis,dc=test",
 				GroupSearchScope:   "sub",
 				GroupFilter:        "",
 				GroupObjectClass:   "groupOfNames",
 				GroupNameAttribute: "cn",
 				GroupIDAttribute:   "owncloudUUID",
+
+				GroupNestingEnabled:  false,
+				GroupNestingMaxDepth: 10,
+
+				EducationResourcesEnabled:         false,
+				SchoolBaseDN:                      "ou=schools,dc=ocis,dc=test",
+				SchoolSearchScope:                 "sub",
+				SchoolFilter:                      "",
+				SchoolObjectClass:                 "ocEducationSchool",
+				SchoolNameAttribute:               "ou",
+				SchoolNumberAttribute:             "ocEducationSchoolNumber",
+				SchoolIDAttribute:                 "owncloudUUID",
+				SchoolMemberAttribute:             "ocMemberOfSchool",
+				ClassObjectClass:                  "ocEducationClass",
+				ClassDescriptionAttribute:         "description",
+				ClassExternalIDAttribute:          "ocEducationExternalId",
+				EducationUserObjectClass:          "ocEducationUser",
+				EducationUserPrimaryRoleAttribute: "ocEducationUserPrimaryRole",
 			},
 		},
+		Photos: config.Photos{
+			RootDirectory: path.Join(defaults.BaseDataPath(), "graph", "photos"),
+			Resolution:    "240x240",
+			MaxUploadSize: 10 * 1024 * 1024,
+			MaxPixels:     25 * 1000 * 1000,
+		},
 		Events: config.Events{
 			Endpoint: "127.0.0.1:9233",
 			Cluster:  "ocis-cluster",
@@ -2,10 +2,14 @@ package identity
 
 import (
 	"context"
+	"errors"
 	"net/url"
 
 	cs3 "github.com/cs3org/go-cs3apis/cs3/identity/user/v1beta1"
 	libregraph "github.com/owncloud/libre-graph-api-go"
+	"github.com/owncloud/ocis/extensions/graph/pkg/service/v0/errorcode"
+	"github.com/owncloud/ocis/extensions/graph/pkg/service/v0/filter"
+	"github.com/owncloud/ocis/extensions/graph/pkg/service/v0/paging"
 )
 
 type Backend interface {
@@ -16,19 +20,64 @@ type Backend interface {
 	// UpdateUser applies changes to given user, identified by username or id
 	UpdateUser(ctx context.Context, nameOrID string, user libregraph.User) (*libregraph.User, error)
 	GetUser(ctx context.Context, nameOrID string) (*libregraph.User, error)
-	GetUsers(ctx context.Context, queryParam url.Values) ([]*libregraph.User, error)
+	// GetUsers returns the users matching the $search and $filter of the query. The paging options of the query ($top, $skip, $skiptoken and
+	// $count) select the returned page, which is described by the returned paging.Info.
+	GetUsers(ctx context.Context, queryParam url.Values) ([]*libregraph.User, paging.Info, error)
+	// GetUserGroups returns the groups a user, identified by username or id, is a direct member of.
+	GetUserGroups(ctx context.Context, nameOrID string) ([]*libregraph.Group, error)
+	// GetUsersGroups returns the groups the users, identified by id, are direct members of, keyed by the user id. It
+	// reads the groups of a whole page of users at once.
+	GetUsersGroups(ctx context.Context, userIDs []string) (map[string][]*libregraph.Group, error)
+	// GetUserTransitiveGroups returns the groups a user, identified by username or id, is a direct or nested member of.
+	GetUserTransitiveGroups(ctx context.Context, nameOrID string) ([]*libregraph.Group, error)
 
 	// CreateGroup creates the supplied group in the identity backend.
 	CreateGroup(ctx context.Context, group libregraph.Group) (*libregraph.Group, error)
 	// DeleteGroup deletes a given group, identified by id
 	DeleteGroup(ctx context.Context, id string) error
 	GetGroup(ctx context.Context, nameOrID string) (*libregraph.Group, error)
-	GetGroups(ctx context.Context, queryParam url.Values) ([]*libregraph.Group, error)
+	// GetGroups returns the groups matching the query, it supports the same paging options as GetUsers.
+	GetGroups(ctx context.Context, queryParam url.Values) ([]*libregraph.Group, paging.Info, error)
 	GetGroupMembers(ctx context.Context, id string) ([]*libregraph.User, error)
+	// GetGroupTransitiveMembers returns the users which are direct members of a group or members of its nested groups.
+	GetGroupTransitiveMembers(ctx context.Context, id string) ([]*libregraph.User, error)
 	// AddMembersToGroup adds new members (reference by a slice of IDs) to supplied group in the identity backend.
 	AddMembersToGroup(ctx context.Context, groupID string, memberID []string) error
 	// RemoveMemberFromGroup removes a single member (by ID) from a group
 	RemoveMemberFromGroup(ctx context.Context, groupID string, memberID string) error
+
+	// Capabilities returns the operations the backend supports.
+	Capabilities(ctx context.Context) Capabilities
+}
+
+// PasswordVerifier is implemented by backends which can verify the password of a user themselves.
+type PasswordVerifier interface {
+	// VerifyPassword returns nil if the password of the user, identified by username or id, is correct, even if it is
+	// expired, so that expired passwords can still be changed. A wrong password is an errorcode.AccessDenied error.
+	VerifyPassword(ctx context.Context, nameOrID, password string) error
+}
+
+// Capabilities describes which of the optional operations of a Backend are supported. Reading users and groups is
+// always supported, the other operations return an errorcode.NotSupported error if they are not.
+type Capabilities struct {
+	CreateUser bool `json:"createUser"`
+	UpdateUser bool `json:"updateUser"`
+	DeleteUser bool `json:"deleteUser"`
+
+	CreateGroup bool `json:"createGroup"`
+	DeleteGroup bool `json:"deleteGroup"`
+	// ListGroupMembers covers GetGroupMembers and GetGroupTransitiveMembers
+	ListGroupMembers bool `json:"listGroupMembers"`
+	// UpdateGroupMembers covers AddMembersToGroup and RemoveMemberFromGroup
+	UpdateGroupMembers bool `json:"updateGroupMembers"`
+}
+
+// filterError converts the errors of parsing or compiling a $filter.
+func filterError(err error) error {
+	if errors.Is(err, filter.ErrUnsupported) {
+		return errorcode.New(errorcode.NotSupported, err.Error())
+	}
+	return errorcode.New(errorcode.InvalidRequest, err.Error())
 }
 
 func CreateUserModelFromCS3(u *cs3.User) *libregraph.User {
@@ -0,0 +1,277 @@
+package identity
+
+import (
+	"context"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/ReneKroon/ttlcache/v2"
+	libregraph "github.com/owncloud/libre-graph-api-go"
+	"github.com/owncloud/ocis/extensions/graph/pkg/service/v0/paging"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// CachedBackend is a Backend caching the users, groups and memberships read from another Backend. The cached entries
+// expire after a fixed TTL. All entries are purged whenever a user or a group is written through the CachedBackend,
+// writes bypassing it have to call Purge. The collections returned by GetUsers and GetGroups are not cached.
+type CachedBackend struct {
+	next    Backend
+	cache   *ttlcache.Cache
+	lookups *prometheus.CounterVec
+
+	// mu serializes purges with adding loaded entries
+	mu sync.Mutex
+	// generation is incremented by every purge, entries read before a purge are not added to the cache after it
+	generation uint64
+}
+
+// NewCachedBackend returns a CachedBackend caching the entries of next for the given ttl. The lookups in the cache
+// are counted by their result, "hit" or "miss", in the given counter, which may be nil.
+func NewCachedBackend(next Backend, ttl time.Duration, lookups *prometheus.CounterVec) *CachedBackend {
+	cache := ttlcache.NewCache()
+	_ = cache.SetTTL(ttl)
+	cache.SkipTTLExtensionOnHit(true)
+	return &CachedBackend{
+		next:    next,
+		cache:   cache,
+		lookups: lookups,
+	}
+}
+
+// Purge removes all entries from the cache.
+func (c *CachedBackend) Purge() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.generation++
+	_ = c.cache.Purge()
+}
+
+// get returns the cached value of the key, or loads and caches it.
+func (c *CachedBackend) get(key string, load func() (interface{}, error)) (interface{}, error) {
+	if v, err := c.cache.Get(key); err == nil {
+		c.count("hit")
+		return v, nil
+	}
+	c.count("miss")
+	c.mu.Lock()
+	generation := c.generation
+	c.mu.Unlock()
+	v, err := load()
+	if err != nil {
+		return nil, err
+	}
+	// the generation is checked under the same lock as the purge, so a purge can't happen between check and set
+	c.mu.Lock()
+	if c.generation == generation {
+		_ = c.cache.Set(key, v)
+	}
+	c.mu.Unlock()
+	return v, nil
+}
+
+func (c *CachedBackend) count(result string) {
+	if c.lookups != nil {
+		c.lookups.WithLabelValues(result).Inc()
+	}
+}
+
+// CreateUser implements the Backend interface.
+func (c *CachedBackend) CreateUser(ctx context.Context, user libregraph.User) (*libregraph.User, error) {
+	defer c.Purge()
+	return c.next.CreateUser(ctx, user)
+}
+
+// DeleteUser implements the Backend interface.
+func (c *CachedBackend) DeleteUser(ctx context.Context, nameOrID string) error {
+	defer c.Purge()
+	return c.next.DeleteUser(ctx, nameOrID)
+}
+
+// UpdateUser implements the Backend interface.
+func (c *CachedBackend) UpdateUser(ctx context.Context, nameOrID string, user libregraph.User) (*libregraph.User, error) {
+	defer c.Purge()
+	return c.next.UpdateUser(ctx, nameOrID, user)
+}
+
+// GetUser implements the Backend interface.
+func (c *CachedBackend) GetUser(ctx context.Context, nameOrID string) (*libregraph.User, error) {
+	v, err := c.get("user:"+nameOrID, func() (interface{}, error) {
+		return c.next.GetUser(ctx, nameOrID)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return copyUser(v.(*libregraph.User)), nil
+}
+
+// GetUsers implements the Backend interface, the users are always read from the underlying Backend.
+func (c *CachedBackend) GetUsers(ctx context.Context, queryParam url.Values) ([]*libregraph.User, paging.Info, error) {
+	return c.next.GetUsers(ctx, queryParam)
+}
+
+// GetUserGroups implements the Backend interface.
+func (c *CachedBackend) GetUserGroups(ctx context.Context, nameOrID string) ([]*libregraph.Group, error) {
+	v, err := c.get("usergroups:"+nameOrID, func() (interface{}, error) {
+		return c.next.GetUserGroups(ctx, nameOrID)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return copyGroups(v.([]*libregraph.Group)), nil
+}
+
+// GetUsersGroups implements the Backend interface. It shares the cached entries with GetUserGroups, only the groups
+// of the users missing in the cache are read from the underlying Backend.
+func (c *CachedBackend) GetUsersGroups(ctx context.Context, userIDs []string) (map[string][]*libregraph.Group, error) {
+	result := make(map[string][]*libregraph.Group, len(userIDs))
+	var missing []string
+	for _, id := range userIDs {
+		if v, err := c.cache.Get("usergroups:" + id); err == nil {
+			c.count("hit")
+			result[id] = copyGroups(v.([]*libregraph.Group))
+			continue
+		}
+		c.count("miss")
+		missing = append(missing, id)
+	}
+	if len(missing) == 0 {
+		return result, nil
+	}
+
+	c.mu.Lock()
+	generation := c.generation
+	c.mu.Unlock()
+	loaded, err := c.next.GetUsersGroups(ctx, missing)
+	if err != nil {
+		return nil, err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for id, groups := range loaded {
+		if c.generation == generation {
+			_ = c.cache.Set("usergroups:"+id, groups)
+		}
+		result[id] = copyGroups(groups)
+	}
+	return result, nil
+}
+
+// GetUserTransitiveGroups implements the Backend interface.
+func (c *CachedBackend) GetUserTransitiveGroups(ctx context.Context, nameOrID string) ([]*libregraph.Group, error) {
+	v, err := c.get("usertransitivegroups:"+nameOrID, func() (interface{}, error) {
+		return c.next.GetUserTransitiveGroups(ctx, nameOrID)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return copyGroups(v.([]*libregraph.Group)), nil
+}
+
+// CreateGroup implements the Backend interface.
+func (c *CachedBackend) CreateGroup(ctx context.Context, group libregraph.Group) (*libregraph.Group, error) {
+	defer c.Purge()
+	return c.next.CreateGroup(ctx, group)
+}
+
+// DeleteGroup implements the Backend interface.
+func (c *CachedBackend) DeleteGroup(ctx context.Context, id string) error {
+	defer c.Purge()
+	return c.next.DeleteGroup(ctx, id)
+}
+
+// GetGroup implements the Backend interface.
+func (c *CachedBackend) GetGroup(ctx context.Context, nameOrID string) (*libregraph.Group, error) {
+	v, err := c.get("group:"+nameOrID, func() (interface{}, error) {
+		return c.next.GetGroup(ctx, nameOrID)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return copyGroup(v.(*libregraph.Group)), nil
+}
+
+// GetGroups implements the Backend interface, the groups are always read from the underlying Backend.
+func (c *CachedBackend) GetGroups(ctx context.Context, queryParam url.Values) ([]*libregraph.Group, paging.Info, error) {
+	return c.next.GetGroups(ctx, queryParam)
+}
+
+// GetGroupMembers implements the Backend interface.
+func (c *CachedBackend) GetGroupMembers(ctx context.Context, id string) ([]*libregraph.User, error) {
+	v, err := c.get("groupmembers:"+id, func() (interface{}, error) {
+		return c.next.GetGroupMembers(ctx, id)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return copyUsers(v.([]*libregraph.User)), nil
+}
+
+// GetGroupTransitiveMembers implements the Backend interface.
+func (c *CachedBackend) GetGroupTransitiveMembers(ctx context.Context, id string) ([]*libregraph.User, error) {
+	v, err := c.get("grouptransitivemembers:"+id, func() (interface{}, error) {
+		return c.next.GetGroupTransitiveMembers(ctx, id)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return copyUsers(v.([]*libregraph.User)), nil
+}
+
+// AddMembersToGroup implements the Backend interface.
+func (c *CachedBackend) AddMembersToGroup(ctx context.Context, groupID string, memberID []string) error {
+	defer c.Purge()
+	return c.next.AddMembersToGroup(ctx, groupID, memberID)
+}
+
+// RemoveMemberFromGroup implements the Backend interface.
+func (c *CachedBackend) RemoveMemberFromGroup(ctx context.Context, groupID string, memberID string) error {
+	defer c.Purge()
+	return c.next.RemoveMemberFromGroup(ctx, groupID, memberID)
+}
+
+// Capabilities implements the Backend interface.
+func (c *CachedBackend) Capabilities(ctx context.Context) Capabilities {
+	return c.next.Capabilities(ctx)
+}
+
+// copyUser returns a shallow copy of a cached user, so that callers can set its properties without changing the
+// cached entry.
+func copyUser(u *libregraph.User) *libregraph.User {
+	if u == nil {
+		return nil
+	}
+	c := *u
+	return &c
+}
+
+func copyUsers(users []*libregraph.User) []*libregraph.User {
+	if users == nil {
+		return nil
+	}
+	c := make([]*libregraph.User, 0, len(users))
+	for _, u := range users {
+		c = append(c, copyUser(u))
+	}
+	return c
+}
+
+// copyGroup returns a shallow copy of a cached group, see copyUser.
+func copyGroup(g *libregraph.Group) *libregraph.Group {
+	if g == nil {
+		return nil
+	}
+	c := *g
+	return &c
+}
+
+func copyGroups(groups []*libregraph.Group) []*libregraph.Group {
+	if groups == nil {
+		return nil
+	}
+	c := make([]*libregraph.Group, 0, len(groups))
+	for _, g := range groups {
+		c = append(c, copyGroup(g))
+	}
+	return c
+}
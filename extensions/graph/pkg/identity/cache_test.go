@@ -0,0 +1,109 @@
+package identity_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	libregraph "github.com/owncloud/libre-graph-api-go"
+	"github.com/owncloud/ocis/extensions/graph/mocks"
+	"github.com/owncloud/ocis/extensions/graph/pkg/identity"
+	"github.com/owncloud/ocis/extensions/graph/pkg/service/v0/errorcode"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestCachedBackend(t *testing.T) {
+	next := &mocks.Backend{}
+	next.On("GetUser", mock.Anything, "einstein").Return(&libregraph.User{Id: libregraph.PtrString("einstein-id")}, nil)
+	next.On("GetUserGroups", mock.Anything, "einstein").Return([]*libregraph.Group{{Id: libregraph.PtrString("physics-id")}}, nil)
+	next.On("UpdateUser", mock.Anything, "einstein", mock.Anything).Return(&libregraph.User{}, nil)
+	lookups := prometheus.NewCounterVec(prometheus.CounterOpts{Name: "lookups"}, []string{"result"})
+	c := identity.NewCachedBackend(next, time.Minute, lookups)
+
+	u, err := c.GetUser(context.Background(), "einstein")
+	if err != nil {
+		t.Fatalf("Expected success, got '%s'", err.Error())
+	}
+	// changing the returned user must not change the cached one
+	u.DisplayName = libregraph.PtrString("changed")
+	u, _ = c.GetUser(context.Background(), "einstein")
+	if u.DisplayName != nil {
+		t.Error("Expected an unchanged copy of the cached user")
+	}
+	next.AssertNumberOfCalls(t, "GetUser", 1)
+
+	groups, _ := c.GetUserGroups(context.Background(), "einstein")
+	_, _ = c.GetUserGroups(context.Background(), "einstein")
+	if len(groups) != 1 || groups[0].GetId() != "physics-id" {
+		t.Errorf("Expected the groups of the user, got %v", groups)
+	}
+	next.AssertNumberOfCalls(t, "GetUserGroups", 1)
+
+	if hits := testutil.ToFloat64(lookups.WithLabelValues("hit")); hits != 2 {
+		t.Errorf("Expected 2 hits, got %v", hits)
+	}
+	if misses := testutil.ToFloat64(lookups.WithLabelValues("miss")); misses != 2 {
+		t.Errorf("Expected 2 misses, got %v", misses)
+	}
+
+	// writes purge the cache
+	_, _ = c.UpdateUser(context.Background(), "einstein", libregraph.User{})
+	_, _ = c.GetUser(context.Background(), "einstein")
+	next.AssertNumberOfCalls(t, "GetUser", 2)
+
+	c.Purge()
+	_, _ = c.GetUserGroups(context.Background(), "einstein")
+	next.AssertNumberOfCalls(t, "GetUserGroups", 2)
+}
+
+func TestCachedBackendUsersGroups(t *testing.T) {
+	next := &mocks.Backend{}
+	next.On("GetUserGroups", mock.Anything, "einstein").Return([]*libregraph.Group{{Id: libregraph.PtrString("physics-id")}}, nil)
+	next.On("GetUsersGroups", mock.Anything, []string{"marie"}).Return(map[string][]*libregraph.Group{
+		"marie": {{Id: libregraph.PtrString("chemistry-id")}},
+	}, nil)
+	c := identity.NewCachedBackend(next, time.Minute, nil)
+
+	_, _ = c.GetUserGroups(context.Background(), "einstein")
+	// only the groups missing in the cache are read
+	groups, err := c.GetUsersGroups(context.Background(), []string{"einstein", "marie"})
+	if err != nil {
+		t.Fatalf("Expected success, got '%s'", err.Error())
+	}
+	if len(groups["einstein"]) != 1 || len(groups["marie"]) != 1 || groups["marie"][0].GetId() != "chemistry-id" {
+		t.Errorf("Expected the groups of both users, got %v", groups)
+	}
+	_, _ = c.GetUserGroups(context.Background(), "marie")
+	next.AssertNumberOfCalls(t, "GetUserGroups", 1)
+	next.AssertNumberOfCalls(t, "GetUsersGroups", 1)
+}
+
+func TestCachedBackendErrors(t *testing.T) {
+	notFound := errorcode.New(errorcode.ItemNotFound, "not found")
+	next := &mocks.Backend{}
+	next.On("GetGroup", mock.Anything, "unknown").Return(nil, notFound)
+	c := identity.NewCachedBackend(next, time.Minute, nil)
+
+	for i := 0; i < 2; i++ {
+		if _, err := c.GetGroup(context.Background(), "unknown"); err != notFound {
+			t.Errorf("Expected the error of the backend, got %v", err)
+		}
+	}
+	// errors are not cached
+	next.AssertNumberOfCalls(t, "GetGroup", 2)
+}
+
+func TestCachedBackendPurgeDuringLoad(t *testing.T) {
+	next := &mocks.Backend{}
+	c := identity.NewCachedBackend(next, time.Minute, nil)
+	next.On("GetGroupMembers", mock.Anything, "physics").Run(func(mock.Arguments) {
+		// a write while the members are read
+		c.Purge()
+	}).Return([]*libregraph.User{}, nil)
+
+	_, _ = c.GetGroupMembers(context.Background(), "physics")
+	_, _ = c.GetGroupMembers(context.Background(), "physics")
+	next.AssertNumberOfCalls(t, "GetGroupMembers", 2)
+}
@@ -3,7 +3,9 @@ package identity
 import (
 	"context"
 	"net/url"
+	"sync"
 
+	gateway "github.com/cs3org/go-cs3apis/cs3/gateway/v1beta1"
 	cs3group "github.com/cs3org/go-cs3apis/cs3/identity/group/v1beta1"
 	cs3user "github.com/cs3org/go-cs3apis/cs3/identity/user/v1beta1"
 	cs3rpc "github.com/cs3org/go-cs3apis/cs3/rpc/v1beta1"
@@ -12,35 +14,54 @@ import (
 
 	"github.com/owncloud/ocis/extensions/graph/pkg/config"
 	"github.com/owncloud/ocis/extensions/graph/pkg/service/v0/errorcode"
+	"github.com/owncloud/ocis/extensions/graph/pkg/service/v0/filter"
+	"github.com/owncloud/ocis/extensions/graph/pkg/service/v0/paging"
 	"github.com/owncloud/ocis/ocis-pkg/log"
 )
 
 var (
-	errNotImplemented = errorcode.New(errorcode.NotSupported, "not implemented")
+	// errCS3ReadOnly is returned for all writes, the CS3 user and group provider APIs have no operations to create,
+	// update or delete users and groups or to change group memberships
+	errCS3ReadOnly = errorcode.New(errorcode.NotSupported, "the cs3 identity backend is read-only")
 )
 
 type CS3 struct {
 	Config *config.Reva
 	Logger *log.Logger
+
+	// gatewayClient is used instead of a client from the pool if set
+	gatewayClient gateway.GatewayAPIClient
+
+	// membersSupported caches whether the group provider can list the members of groups, see Capabilities
+	membersMu        sync.Mutex
+	membersSupported *bool
 }
 
-// CreateUser implements the Backend Interface. It's currently not supported for the CS3 backend
+// getGatewayClient returns a client for the gateway.
+func (i *CS3) getGatewayClient() (gateway.GatewayAPIClient, error) {
+	if i.gatewayClient != nil {
+		return i.gatewayClient, nil
+	}
+	return pool.GetGatewayServiceClient(i.Config.Address)
+}
+
+// CreateUser implements the Backend Interface. It's not supported for the CS3 backend
 func (i *CS3) CreateUser(ctx context.Context, user libregraph.User) (*libregraph.User, error) {
-	return nil, errNotImplemented
+	return nil, errCS3ReadOnly
 }
 
-// DeleteUser implements the Backend Interface. It's currently not supported for the CS3 backend
+// DeleteUser implements the Backend Interface. It's not supported for the CS3 backend
 func (i *CS3) DeleteUser(ctx context.Context, nameOrID string) error {
-	return errNotImplemented
+	return errCS3ReadOnly
 }
 
-// UpdateUser implements the Backend Interface. It's currently not suported for the CS3 backend
+// UpdateUser implements the Backend Interface. It's not supported for the CS3 backend
 func (i *CS3) UpdateUser(ctx context.Context, nameOrID string, user libregraph.User) (*libregraph.User, error) {
-	return nil, errNotImplemented
+	return nil, errCS3ReadOnly
 }
 
 func (i *CS3) GetUser(ctx context.Context, userID string) (*libregraph.User, error) {
-	client, err := pool.GetGatewayServiceClient(i.Config.Address)
+	client, err := i.getGatewayClient()
 	if err != nil {
 		i.Logger.Error().Err(err).Msg("could not get client")
 		return nil, errorcode.New(errorcode.ServiceNotAvailable, err.Error())
@@ -65,11 +86,23 @@ func (i *CS3) GetUser(ctx context.Context, userID string) (*libregraph.User, err
 	return CreateUserModelFromCS3(res.User), nil
 }
 
-func (i *CS3) GetUsers(ctx context.Context, queryParam url.Values) ([]*libregraph.User, error) {
-	client, err := pool.GetGatewayServiceClient(i.Config.Address)
+func (i *CS3) GetUsers(ctx context.Context, queryParam url.Values) ([]*libregraph.User, paging.Info, error) {
+	page, err := paging.Parse(queryParam, 0)
+	if err != nil {
+		return nil, paging.Info{}, errorcode.New(errorcode.InvalidRequest, err.Error())
+	}
+	f, err := filter.Parse(queryParam)
+	if err != nil {
+		return nil, paging.Info{}, filterError(err)
+	}
+	if f != nil && f.Contains(filter.OpAny) {
+		return nil, paging.Info{}, errorcode.New(errorcode.NotSupported, "lambda expressions are not supported by the cs3 backend")
+	}
+
+	client, err := i.getGatewayClient()
 	if err != nil {
 		i.Logger.Error().Err(err).Msg("could not get client")
-		return nil, errorcode.New(errorcode.ServiceNotAvailable, err.Error())
+		return nil, paging.Info{}, errorcode.New(errorcode.ServiceNotAvailable, err.Error())
 	}
 
 	search := queryParam.Get("search")
@@ -85,29 +118,50 @@ func (i *CS3) GetUsers(ctx context.Context, queryParam url.Values) ([]*libregrap
 	switch {
 	case err != nil:
 		i.Logger.Error().Err(err).Str("search", search).Msg("error sending find users grpc request")
-		return nil, errorcode.New(errorcode.ServiceNotAvailable, err.Error())
+		return nil, paging.Info{}, errorcode.New(errorcode.ServiceNotAvailable, err.Error())
 	case res.Status.Code != cs3rpc.Code_CODE_OK:
 		if res.Status.Code == cs3rpc.Code_CODE_NOT_FOUND {
-			return nil, errorcode.New(errorcode.ItemNotFound, res.Status.Message)
+			return nil, paging.Info{}, errorcode.New(errorcode.ItemNotFound, res.Status.Message)
 		}
 		i.Logger.Error().Err(err).Str("search", search).Msg("error sending find users grpc request")
-		return nil, errorcode.New(errorcode.GeneralException, res.Status.Message)
+		return nil, paging.Info{}, errorcode.New(errorcode.GeneralException, res.Status.Message)
 	}
 
 	users := make([]*libregraph.User, 0, len(res.Users))
 
 	for _, user := range res.Users {
-		users = append(users, CreateUserModelFromCS3(user))
+		// The CS3 api only supports searching, so the filter is applied here
+		if u := CreateUserModelFromCS3(user); f == nil || f.Match(u) {
+			users = append(users, u)
+		}
 	}
 
-	return users, nil
+	// The CS3 api has no paging, so the page is cut from the full result
+	start, end, info, err := paging.Slice(len(users), page)
+	if err != nil {
+		return nil, info, errorcode.New(errorcode.InvalidRequest, err.Error())
+	}
+
+	return users[start:end], info, nil
 }
 
-func (i *CS3) GetGroups(ctx context.Context, queryParam url.Values) ([]*libregraph.Group, error) {
-	client, err := pool.GetGatewayServiceClient(i.Config.Address)
+func (i *CS3) GetGroups(ctx context.Context, queryParam url.Values) ([]*libregraph.Group, paging.Info, error) {
+	page, err := paging.Parse(queryParam, 0)
+	if err != nil {
+		return nil, paging.Info{}, errorcode.New(errorcode.InvalidRequest, err.Error())
+	}
+	f, err := filter.Parse(queryParam)
+	if err != nil {
+		return nil, paging.Info{}, filterError(err)
+	}
+	if f != nil && f.Contains(filter.OpAny) {
+		return nil, paging.Info{}, errorcode.New(errorcode.NotSupported, "lambda expressions are not supported by the cs3 backend")
+	}
+
+	client, err := i.getGatewayClient()
 	if err != nil {
 		i.Logger.Error().Err(err).Msg("could not get client")
-		return nil, errorcode.New(errorcode.ServiceNotAvailable, err.Error())
+		return nil, paging.Info{}, errorcode.New(errorcode.ServiceNotAvailable, err.Error())
 	}
 
 	search := queryParam.Get("search")
@@ -124,31 +178,93 @@ func (i *CS3) GetGroups(ctx context.Context, queryParam url.Values) ([]*libregra
 	switch {
 	case err != nil:
 		i.Logger.Error().Err(err).Str("search", search).Msg("error sending find groups grpc request")
-		return nil, errorcode.New(errorcode.ServiceNotAvailable, err.Error())
+		return nil, paging.Info{}, errorcode.New(errorcode.ServiceNotAvailable, err.Error())
 	case res.Status.Code != cs3rpc.Code_CODE_OK:
 		if res.Status.Code == cs3rpc.Code_CODE_NOT_FOUND {
-			return nil, errorcode.New(errorcode.ItemNotFound, res.Status.Message)
+			return nil, paging.Info{}, errorcode.New(errorcode.ItemNotFound, res.Status.Message)
 		}
 		i.Logger.Error().Err(err).Str("search", search).Msg("error sending find groups grpc request")
-		return nil, errorcode.New(errorcode.GeneralException, res.Status.Message)
+		return nil, paging.Info{}, errorcode.New(errorcode.GeneralException, res.Status.Message)
 	}
 
 	groups := make([]*libregraph.Group, 0, len(res.Groups))
 
 	for _, group := range res.Groups {
-		groups = append(groups, createGroupModelFromCS3(group))
+		if g := createGroupModelFromCS3(group); f == nil || f.Match(g) {
+			groups = append(groups, g)
+		}
+	}
+
+	start, end, info, err := paging.Slice(len(groups), page)
+	if err != nil {
+		return nil, info, errorcode.New(errorcode.InvalidRequest, err.Error())
+	}
+
+	return groups[start:end], info, nil
+}
+
+// GetUserGroups implements the Backend Interface. The groups are looked up by the names the gateway returns
+// for the user.
+func (i *CS3) GetUserGroups(ctx context.Context, userID string) ([]*libregraph.Group, error) {
+	client, err := i.getGatewayClient()
+	if err != nil {
+		i.Logger.Error().Err(err).Msg("could not get client")
+		return nil, errorcode.New(errorcode.ServiceNotAvailable, err.Error())
+	}
+
+	res, err := client.GetUserByClaim(ctx, &cs3user.GetUserByClaimRequest{
+		Claim: "userid",
+		Value: userID,
+	})
+	switch {
+	case err != nil:
+		i.Logger.Error().Err(err).Str("userid", userID).Msg("error sending get user by claim id grpc request")
+		return nil, errorcode.New(errorcode.ServiceNotAvailable, err.Error())
+	case res.Status.Code != cs3rpc.Code_CODE_OK:
+		if res.Status.Code == cs3rpc.Code_CODE_NOT_FOUND {
+			return nil, errorcode.New(errorcode.ItemNotFound, res.Status.Message)
+		}
+		i.Logger.Error().Err(err).Str("userid", userID).Msg("error sending get user by claim id grpc request")
+		return nil, errorcode.New(errorcode.GeneralException, res.Status.Message)
 	}
 
+	groups := make([]*libregraph.Group, 0, len(res.User.Groups))
+	for _, name := range res.User.Groups {
+		gres, err := client.GetGroupByClaim(ctx, &cs3group.GetGroupByClaimRequest{
+			Claim: "group_name",
+			Value: name,
+		})
+		if err != nil || gres.Status.Code != cs3rpc.Code_CODE_OK {
+			// Ignore groups that can't be looked up, just log them and continue
+			i.Logger.Warn().Err(err).Str("group", name).Msg("error reading group of user")
+			continue
+		}
+		groups = append(groups, createGroupModelFromCS3(gres.Group))
+	}
 	return groups, nil
 }
 
-// CreateGroup implements the Backend Interface. It's currently not supported for the CS3 backend
+// GetUsersGroups implements the Backend Interface. The gateway has no batch lookup, so the groups are read user by
+// user.
+func (i *CS3) GetUsersGroups(ctx context.Context, userIDs []string) (map[string][]*libregraph.Group, error) {
+	result := make(map[string][]*libregraph.Group, len(userIDs))
+	for _, id := range userIDs {
+		groups, err := i.GetUserGroups(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		result[id] = groups
+	}
+	return result, nil
+}
+
+// CreateGroup implements the Backend Interface. It's not supported for the CS3 backend
 func (i *CS3) CreateGroup(ctx context.Context, group libregraph.Group) (*libregraph.Group, error) {
-	return nil, errorcode.New(errorcode.NotSupported, "not implemented")
+	return nil, errCS3ReadOnly
 }
 
 func (i *CS3) GetGroup(ctx context.Context, groupID string) (*libregraph.Group, error) {
-	client, err := pool.GetGatewayServiceClient(i.Config.Address)
+	client, err := i.getGatewayClient()
 	if err != nil {
 		i.Logger.Error().Err(err).Msg("could not get client")
 		return nil, errorcode.New(errorcode.ServiceNotAvailable, err.Error())
@@ -174,24 +290,110 @@ func (i *CS3) GetGroup(ctx context.Context, groupID string) (*libregraph.Group,
 	return createGroupModelFromCS3(res.Group), nil
 }
 
-// DeleteGroup implements the Backend Interface. It's currently not supported for the CS3 backend
+// DeleteGroup implements the Backend Interface. It's not supported for the CS3 backend
 func (i *CS3) DeleteGroup(ctx context.Context, id string) error {
-	return errorcode.New(errorcode.NotSupported, "not implemented")
+	return errCS3ReadOnly
 }
 
-// GetGroupMembers implements the Backend Interface. It's currently not supported for the CS3 backend
+// GetUserTransitiveGroups implements the Backend Interface. The gateway already returns all groups of a user, so
+// they are the same as the ones returned by GetUserGroups.
+func (i *CS3) GetUserTransitiveGroups(ctx context.Context, userID string) ([]*libregraph.Group, error) {
+	return i.GetUserGroups(ctx, userID)
+}
+
+// GetGroupTransitiveMembers implements the Backend Interface. The group providers don't distinguish direct and
+// nested members, so they are the same as the ones returned by GetGroupMembers.
+func (i *CS3) GetGroupTransitiveMembers(ctx context.Context, groupID string) ([]*libregraph.User, error) {
+	return i.GetGroupMembers(ctx, groupID)
+}
+
+// GetGroupMembers implements the Backend Interface. It's only supported if the group provider can list the members
+// of groups.
 func (i *CS3) GetGroupMembers(ctx context.Context, groupID string) ([]*libregraph.User, error) {
-	return nil, errorcode.New(errorcode.NotSupported, "not implemented")
+	client, err := i.getGatewayClient()
+	if err != nil {
+		i.Logger.Error().Err(err).Msg("could not get client")
+		return nil, errorcode.New(errorcode.ServiceNotAvailable, err.Error())
+	}
+
+	gres, err := client.GetGroupByClaim(ctx, &cs3group.GetGroupByClaimRequest{
+		Claim: "groupid",
+		Value: groupID,
+	})
+	switch {
+	case err != nil:
+		i.Logger.Error().Err(err).Str("groupid", groupID).Msg("error sending get group by claim id grpc request")
+		return nil, errorcode.New(errorcode.ServiceNotAvailable, err.Error())
+	case gres.Status.Code != cs3rpc.Code_CODE_OK:
+		if gres.Status.Code == cs3rpc.Code_CODE_NOT_FOUND {
+			return nil, errorcode.New(errorcode.ItemNotFound, gres.Status.Message)
+		}
+		i.Logger.Error().Err(err).Str("groupid", groupID).Msg("error sending get group by claim id grpc request")
+		return nil, errorcode.New(errorcode.GeneralException, gres.Status.Message)
+	}
+
+	res, err := client.GetMembers(ctx, &cs3group.GetMembersRequest{GroupId: gres.Group.Id})
+	switch {
+	case err != nil:
+		i.Logger.Error().Err(err).Str("groupid", groupID).Msg("error sending get members grpc request")
+		return nil, errorcode.New(errorcode.ServiceNotAvailable, err.Error())
+	case res.Status.Code != cs3rpc.Code_CODE_OK:
+		if res.Status.Code == cs3rpc.Code_CODE_UNIMPLEMENTED {
+			return nil, errorcode.New(errorcode.NotSupported, "the group provider can't list the members of groups")
+		}
+		i.Logger.Error().Err(err).Str("groupid", groupID).Msg("error sending get members grpc request")
+		return nil, errorcode.New(errorcode.GeneralException, res.Status.Message)
+	}
+
+	users := make([]*libregraph.User, 0, len(res.Members))
+	for _, id := range res.Members {
+		ures, err := client.GetUser(ctx, &cs3user.GetUserRequest{
+			UserId:                 id,
+			SkipFetchingUserGroups: true,
+		})
+		if err != nil || ures.Status.Code != cs3rpc.Code_CODE_OK {
+			// Ignore members that can't be looked up, just log them and continue
+			i.Logger.Warn().Err(err).Str("member", id.GetOpaqueId()).Msg("error reading group member")
+			continue
+		}
+		users = append(users, CreateUserModelFromCS3(ures.User))
+	}
+	return users, nil
 }
 
-// AddMembersToGroup implements the Backend Interface. It's currently not supported for the CS3 backend
+// AddMembersToGroup implements the Backend Interface. It's not supported for the CS3 backend
 func (i *CS3) AddMembersToGroup(ctx context.Context, groupID string, memberID []string) error {
-	return errorcode.New(errorcode.NotSupported, "not implemented")
+	return errCS3ReadOnly
 }
 
-// RemoveMemberFromGroup implements the Backend Interface. It's currently not supported for the CS3 backend
+// RemoveMemberFromGroup implements the Backend Interface. It's not supported for the CS3 backend
 func (i *CS3) RemoveMemberFromGroup(ctx context.Context, groupID string, memberID string) error {
-	return errorcode.New(errorcode.NotSupported, "not implemented")
+	return errCS3ReadOnly
+}
+
+// Capabilities implements the Backend Interface. The CS3 backend is read-only, whether the members of groups can be
+// listed is discovered by asking the group provider for the members of a group which doesn't exist. The result is
+// cached once the gateway answered.
+func (i *CS3) Capabilities(ctx context.Context) Capabilities {
+	i.membersMu.Lock()
+	defer i.membersMu.Unlock()
+	if i.membersSupported == nil {
+		client, err := i.getGatewayClient()
+		if err != nil {
+			i.Logger.Error().Err(err).Msg("could not get client")
+			return Capabilities{}
+		}
+		res, err := client.GetMembers(ctx, &cs3group.GetMembersRequest{GroupId: &cs3group.GroupId{}})
+		if err != nil {
+			i.Logger.Error().Err(err).Msg("error sending get members grpc request")
+			return Capabilities{}
+		}
+		supported := res.Status.Code != cs3rpc.Code_CODE_UNIMPLEMENTED
+		i.membersSupported = &supported
+	}
+	return Capabilities{
+		ListGroupMembers: *i.membersSupported,
+	}
 }
 
 func createGroupModelFromCS3(g *cs3group.Group) *libregraph.Group {
@@ -0,0 +1,85 @@
+package identity
+
+import (
+	"context"
+	"testing"
+
+	gateway "github.com/cs3org/go-cs3apis/cs3/gateway/v1beta1"
+	cs3group "github.com/cs3org/go-cs3apis/cs3/identity/group/v1beta1"
+	cs3user "github.com/cs3org/go-cs3apis/cs3/identity/user/v1beta1"
+	cs3rpc "github.com/cs3org/go-cs3apis/cs3/rpc/v1beta1"
+	"github.com/owncloud/ocis/extensions/graph/pkg/service/v0/errorcode"
+	"google.golang.org/grpc"
+)
+
+// gatewayMock implements the group and user lookups of the gateway.GatewayAPIClient used by the CS3 backend.
+type gatewayMock struct {
+	gateway.GatewayAPIClient
+	membersCode cs3rpc.Code
+	probes      *int
+}
+
+func (m gatewayMock) GetGroupByClaim(ctx context.Context, in *cs3group.GetGroupByClaimRequest, opts ...grpc.CallOption) (*cs3group.GetGroupByClaimResponse, error) {
+	return &cs3group.GetGroupByClaimResponse{
+		Status: &cs3rpc.Status{Code: cs3rpc.Code_CODE_OK},
+		Group:  &cs3group.Group{Id: &cs3group.GroupId{Idp: "idp", OpaqueId: in.Value}, GroupName: in.Value},
+	}, nil
+}
+
+func (m gatewayMock) GetMembers(ctx context.Context, in *cs3group.GetMembersRequest, opts ...grpc.CallOption) (*cs3group.GetMembersResponse, error) {
+	if in.GroupId.GetOpaqueId() == "" {
+		*m.probes++
+	}
+	return &cs3group.GetMembersResponse{
+		Status:  &cs3rpc.Status{Code: m.membersCode},
+		Members: []*cs3user.UserId{{OpaqueId: "einstein"}, {OpaqueId: "unknown"}},
+	}, nil
+}
+
+func (m gatewayMock) GetUser(ctx context.Context, in *cs3user.GetUserRequest, opts ...grpc.CallOption) (*cs3user.GetUserResponse, error) {
+	if in.UserId.OpaqueId == "unknown" {
+		return &cs3user.GetUserResponse{Status: &cs3rpc.Status{Code: cs3rpc.Code_CODE_NOT_FOUND}}, nil
+	}
+	return &cs3user.GetUserResponse{
+		Status: &cs3rpc.Status{Code: cs3rpc.Code_CODE_OK},
+		User:   &cs3user.User{Id: in.UserId, Username: in.UserId.OpaqueId},
+	}, nil
+}
+
+func TestCS3GetGroupMembers(t *testing.T) {
+	probes := 0
+	b := &CS3{Logger: &logger, gatewayClient: gatewayMock{membersCode: cs3rpc.Code_CODE_OK, probes: &probes}}
+
+	users, err := b.GetGroupMembers(context.Background(), "physics")
+	if err != nil {
+		t.Fatalf("Expected success, got '%s'", err.Error())
+	}
+	if len(users) != 1 || users[0].GetId() != "einstein" {
+		t.Errorf("Expected the members which could be looked up, got %v", users)
+	}
+
+	for i := 0; i < 2; i++ {
+		if c := b.Capabilities(context.Background()); !c.ListGroupMembers || c.CreateUser || c.UpdateGroupMembers {
+			t.Errorf("Expected only listing the members to be supported, got %+v", c)
+		}
+	}
+	if probes != 1 {
+		t.Errorf("Expected the capabilities to be discovered once, got %d probes", probes)
+	}
+}
+
+func TestCS3Unsupported(t *testing.T) {
+	probes := 0
+	b := &CS3{Logger: &logger, gatewayClient: gatewayMock{membersCode: cs3rpc.Code_CODE_UNIMPLEMENTED, probes: &probes}}
+
+	_, err := b.GetGroupMembers(context.Background(), "physics")
+	if err == nil || err.Error() != errorcode.NotSupported.String() {
+		t.Errorf("Expected a NotSupported error, got %v", err)
+	}
+	if c := b.Capabilities(context.Background()); c.ListGroupMembers {
+		t.Error("Expected listing the members to be unsupported")
+	}
+	if err := b.DeleteUser(context.Background(), "einstein"); err != errCS3ReadOnly {
+		t.Errorf("Expected the backend to be read-only, got %v", err)
+	}
+}
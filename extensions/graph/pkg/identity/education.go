@@ -0,0 +1,88 @@
+package identity
+
+import (
+	"context"
+
+	libregraph "github.com/owncloud/libre-graph-api-go"
+)
+
+// The primary roles of education users.
+const (
+	EducationRoleStudent = "student"
+	EducationRoleTeacher = "teacher"
+)
+
+// EducationSchool is a school of the education api.
+type EducationSchool struct {
+	Id           *string `json:"id,omitempty"`
+	DisplayName  *string `json:"displayName,omitempty"`
+	SchoolNumber *string `json:"schoolNumber,omitempty"`
+}
+
+// EducationClass is a class of the education api. Every class is a group, the members of the class are the members
+// of the group.
+type EducationClass struct {
+	Id          *string `json:"id,omitempty"`
+	DisplayName *string `json:"displayName,omitempty"`
+	Description *string `json:"description,omitempty"`
+	ExternalId  *string `json:"externalId,omitempty"`
+}
+
+// EducationUser is a user of the education api. Every education user is a user with a primary role.
+type EducationUser struct {
+	Id                       *string                     `json:"id,omitempty"`
+	DisplayName              *string                     `json:"displayName,omitempty"`
+	Mail                     *string                     `json:"mail,omitempty"`
+	OnPremisesSamAccountName *string                     `json:"onPremisesSamAccountName,omitempty"`
+	Surname                  *string                     `json:"surname,omitempty"`
+	PrimaryRole              *string                     `json:"primaryRole,omitempty"`
+	PasswordProfile          *libregraph.PasswordProfile `json:"passwordProfile,omitempty"`
+}
+
+// EducationBackend is implemented by identity backends which manage the resources of the education api.
+type EducationBackend interface {
+	// CreateEducationSchool creates the given school in the identity backend.
+	CreateEducationSchool(ctx context.Context, school EducationSchool) (*EducationSchool, error)
+	// DeleteEducationSchool deletes a school, identified by name or id, and removes it from its users and classes.
+	DeleteEducationSchool(ctx context.Context, nameOrID string) error
+	// UpdateEducationSchool applies changes to a school, identified by name or id.
+	UpdateEducationSchool(ctx context.Context, nameOrID string, school EducationSchool) (*EducationSchool, error)
+	GetEducationSchool(ctx context.Context, nameOrID string) (*EducationSchool, error)
+	GetEducationSchools(ctx context.Context) ([]*EducationSchool, error)
+	// GetEducationSchoolUsers returns the users of a school, identified by name or id.
+	GetEducationSchoolUsers(ctx context.Context, nameOrID string) ([]*EducationUser, error)
+	// AddUsersToEducationSchool adds the users, referenced by their ids, to a school.
+	AddUsersToEducationSchool(ctx context.Context, schoolID string, memberIDs []string) error
+	// RemoveUserFromEducationSchool removes a single user (by ID) from a school.
+	RemoveUserFromEducationSchool(ctx context.Context, schoolID string, memberID string) error
+	// GetEducationSchoolClasses returns the classes of a school, identified by name or id.
+	GetEducationSchoolClasses(ctx context.Context, nameOrID string) ([]*EducationClass, error)
+	// AddClassesToEducationSchool adds the classes, referenced by their ids, to a school.
+	AddClassesToEducationSchool(ctx context.Context, schoolID string, classIDs []string) error
+	// RemoveClassFromEducationSchool removes a single class (by ID) from a school.
+	RemoveClassFromEducationSchool(ctx context.Context, schoolID string, classID string) error
+
+	// CreateEducationClass creates the given class, and with it its group, in the identity backend.
+	CreateEducationClass(ctx context.Context, class EducationClass) (*EducationClass, error)
+	// DeleteEducationClass deletes a class and its group, identified by id.
+	DeleteEducationClass(ctx context.Context, id string) error
+	// UpdateEducationClass applies changes to a class, identified by id.
+	UpdateEducationClass(ctx context.Context, id string, class EducationClass) (*EducationClass, error)
+	GetEducationClass(ctx context.Context, nameOrID string) (*EducationClass, error)
+	GetEducationClasses(ctx context.Context) ([]*EducationClass, error)
+	// GetEducationClassMembers returns the users of a class, identified by id.
+	GetEducationClassMembers(ctx context.Context, id string) ([]*EducationUser, error)
+	// AddUsersToEducationClass adds the users, referenced by their ids, to a class.
+	AddUsersToEducationClass(ctx context.Context, classID string, memberIDs []string) error
+	// RemoveUserFromEducationClass removes a single user (by ID) from a class.
+	RemoveUserFromEducationClass(ctx context.Context, classID string, memberID string) error
+
+	// CreateEducationUser creates the given user in the identity backend.
+	CreateEducationUser(ctx context.Context, user EducationUser) (*EducationUser, error)
+	// DeleteEducationUser deletes a user, identified by name or id.
+	DeleteEducationUser(ctx context.Context, nameOrID string) error
+	// UpdateEducationUser applies changes to a user, identified by name or id.
+	UpdateEducationUser(ctx context.Context, nameOrID string, user EducationUser) (*EducationUser, error)
+	GetEducationUser(ctx context.Context, nameOrID string) (*EducationUser, error)
+	GetEducationUsers(ctx context.Context) ([]*EducationUser, error)
+}
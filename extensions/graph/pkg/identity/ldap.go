@@ -5,6 +5,9 @@ import (
 	"errors"
 	"fmt"
 	"net/url"
+	"regexp"
+	"strings"
+	"time"
 
 	"github.com/go-ldap/ldap/v3"
 	"github.com/gofrs/uuid"
@@ -13,9 +16,17 @@ import (
 
 	"github.com/owncloud/ocis/extensions/graph/pkg/config"
 	"github.com/owncloud/ocis/extensions/graph/pkg/service/v0/errorcode"
+	"github.com/owncloud/ocis/extensions/graph/pkg/service/v0/filter"
+	"github.com/owncloud/ocis/extensions/graph/pkg/service/v0/paging"
 	"github.com/owncloud/ocis/ocis-pkg/log"
 )
 
+// ldapPageSize is the page size used when reading all entries of a paged search
+const ldapPageSize = 500
+
+// dnBatchSize is the number of DNs read with a single search by getEntriesByDNs
+const dnBatchSize = 100
+
 var (
 	errReadOnly = errorcode.New(errorcode.NotAllowed, "server is configured read-only")
 	errNotFound = errorcode.New(errorcode.ItemNotFound, "not found")
@@ -24,6 +35,8 @@ var (
 type LDAP struct {
 	useServerUUID bool
 	writeEnabled  bool
+	// idmPasswordExpiry is set if binds failing with passwordExpiredResultCode had a correct but expired password
+	idmPasswordExpiry bool
 
 	userBaseDN       string
 	userFilter       string
@@ -36,6 +49,10 @@ type LDAP struct {
 	groupObjectClass  string
 	groupScope        int
 	groupAttributeMap groupAttributeMap
+	// groupNestingDepth is the number of levels of nested groups which are resolved, 1 if nesting is disabled
+	groupNestingDepth int
+
+	educationConfig educationConfig
 
 	logger *log.Logger
 	conn   ldap.Client
@@ -46,6 +63,13 @@ type userAttributeMap struct {
 	id          string
 	mail        string
 	userName    string
+
+	// the optional attributes, they are empty if they aren't mapped
+	surname           string
+	givenName         string
+	preferredLanguage string
+	creationTime      string
+	enabled           string
 }
 
 type groupAttributeMap struct {
@@ -65,6 +89,12 @@ func NewLDAPBackend(lc ldap.Client, config config.LDAP, logger *log.Logger) (*LD
 		id:          config.UserIDAttribute,
 		mail:        config.UserEmailAttribute,
 		userName:    config.UserNameAttribute,
+
+		surname:           config.UserSurnameAttribute,
+		givenName:         config.UserGivenNameAttribute,
+		preferredLanguage: config.UserPreferredLanguageAttribute,
+		creationTime:      config.UserCreationTimeAttribute,
+		enabled:           config.UserEnabledAttribute,
 	}
 
 	if config.GroupNameAttribute == "" || config.GroupIDAttribute == "" {
@@ -87,6 +117,21 @@ func NewLDAPBackend(lc ldap.Client, config config.LDAP, logger *log.Logger) (*LD
 		return nil, fmt.Errorf("error configuring group scope: %w", err)
 	}
 
+	groupNestingDepth := 1
+	if config.GroupNestingEnabled {
+		if config.GroupNestingMaxDepth < 1 {
+			return nil, errors.New("invalid group nesting depth, it must be at least 1")
+		}
+		groupNestingDepth = config.GroupNestingMaxDepth
+	}
+
+	var ec educationConfig
+	if config.EducationResourcesEnabled {
+		if ec, err = newEducationConfig(config); err != nil {
+			return nil, fmt.Errorf("error configuring education resources: %w", err)
+		}
+	}
+
 	return &LDAP{
 		useServerUUID:     config.UseServerUUID,
 		userBaseDN:        config.UserBaseDN,
@@ -99,9 +144,12 @@ func NewLDAPBackend(lc ldap.Client, config config.LDAP, logger *log.Logger) (*LD
 		groupObjectClass:  config.GroupObjectClass,
 		groupScope:        groupScope,
 		groupAttributeMap: gam,
+		groupNestingDepth: groupNestingDepth,
+		educationConfig:   ec,
 		logger:            logger,
 		conn:              lc,
 		writeEnabled:      config.WriteEnabled,
+		idmPasswordExpiry: config.IDMPasswordExpiry,
 	}, nil
 }
 
@@ -112,6 +160,29 @@ func (i *LDAP) CreateUser(ctx context.Context, user libregraph.User) (*libregrap
 	if !i.writeEnabled {
 		return nil, errReadOnly
 	}
+	if err := i.checkUserAttributesMapped(user); err != nil {
+		return nil, err
+	}
+	if err := checkPassword(user); err != nil {
+		return nil, err
+	}
+	ar := i.userToAddRequest(user)
+
+	if err := i.conn.Add(ar); err != nil {
+		return nil, passwordPolicyError(err, user)
+	}
+
+	// Read	back user from LDAP to get the generated UUID
+	e, err := i.getUserByDN(ar.DN)
+	if err != nil {
+		return nil, err
+	}
+	return i.createUserModelFromLDAP(e), nil
+}
+
+// userToAddRequest creates the LDAP Add request (not sending it) for the user entry of a libregraph.User. The entry
+// uses the inetOrgPerson objectClass and the given additional objectClasses.
+func (i *LDAP) userToAddRequest(user libregraph.User, objectClasses ...string) *ldap.AddRequest {
 	ar := ldap.AddRequest{
 		DN: fmt.Sprintf("uid=%s,%s", *user.OnPremisesSamAccountName, i.userBaseDN),
 		Attributes: []ldap.Attribute{
@@ -135,7 +206,7 @@ func (i *LDAP) CreateUser(ctx context.Context, user libregraph.User) (*libregrap
 		},
 	}
 
-	objectClasses := []string{"inetOrgPerson", "organizationalPerson", "person", "top"}
+	objectClasses = append([]string{"inetOrgPerson", "organizationalPerson", "person", "top"}, objectClasses...)
 
 	if user.PasswordProfile != nil && user.PasswordProfile.Password != nil {
 		// TODO? This relies to the LDAP server to properly hash the password.
@@ -159,17 +230,38 @@ func (i *LDAP) CreateUser(ctx context.Context, user libregraph.User) (*libregrap
 		sn = *user.OnPremisesSamAccountName
 	}
 	ar.Attribute("sn", []string{sn})
-
-	if err := i.conn.Add(&ar); err != nil {
-		return nil, err
+	if i.userAttributeMap.surname != "" && !strings.EqualFold(i.userAttributeMap.surname, "sn") && user.Surname != nil && *user.Surname != "" {
+		ar.Attribute(i.userAttributeMap.surname, []string{*user.Surname})
+	}
+	if user.GivenName != nil && *user.GivenName != "" {
+		ar.Attribute(i.userAttributeMap.givenName, []string{*user.GivenName})
+	}
+	if user.PreferredLanguage != nil && *user.PreferredLanguage != "" {
+		ar.Attribute(i.userAttributeMap.preferredLanguage, []string{*user.PreferredLanguage})
 	}
+	if user.AccountEnabled != nil {
+		ar.Attribute(i.userAttributeMap.enabled, []string{ldapBool(*user.AccountEnabled)})
+	}
+	return &ar
+}
 
-	// Read	back user from LDAP to get the generated UUID
-	e, err := i.getUserByDN(ar.DN)
-	if err != nil {
-		return nil, err
+// checkUserAttributesMapped returns an error if the user has a property whose optional LDAP attribute isn't mapped.
+func (i *LDAP) checkUserAttributesMapped(user libregraph.User) error {
+	for _, p := range []struct {
+		name   string
+		set    bool
+		mapped string
+	}{
+		{"surname", user.Surname != nil && *user.Surname != "", i.userAttributeMap.surname},
+		{"givenName", user.GivenName != nil, i.userAttributeMap.givenName},
+		{"preferredLanguage", user.PreferredLanguage != nil, i.userAttributeMap.preferredLanguage},
+		{"accountEnabled", user.AccountEnabled != nil, i.userAttributeMap.enabled},
+	} {
+		if p.set && p.mapped == "" {
+			return errorcode.New(errorcode.NotSupported, fmt.Sprintf("the %s property is not supported, no LDAP attribute is configured", p.name))
+		}
 	}
-	return i.createUserModelFromLDAP(e), nil
+	return nil
 }
 
 // DeleteUser implements the Backend Interface. It permanently deletes a User identified
@@ -215,7 +307,29 @@ func (i *LDAP) UpdateUser(ctx context.Context, nameOrID string, user libregraph.
 	if err != nil {
 		return nil, err
 	}
+	mr, err := i.userModifyRequest(e, user)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := i.conn.Modify(mr); err != nil {
+		return nil, passwordPolicyError(err, user)
+	}
+
+	// Read	back user from LDAP to get the generated UUID
+	e, err = i.getUserByDN(e.DN)
+	if err != nil {
+		return nil, err
+	}
+	return i.createUserModelFromLDAP(e), nil
+}
 
+// userModifyRequest creates the LDAP Modify request (not sending it) that applies the changes of the user to the
+// supplied user entry.
+func (i *LDAP) userModifyRequest(e *ldap.Entry, user libregraph.User) (*ldap.ModifyRequest, error) {
+	if err := checkPassword(user); err != nil {
+		return nil, err
+	}
 	// Don't allow updates of the ID
 	if user.Id != nil && *user.Id != "" {
 		if e.GetEqualFoldAttributeValue(i.userAttributeMap.id) != *user.Id {
@@ -231,6 +345,10 @@ func (i *LDAP) UpdateUser(ctx context.Context, nameOrID string, user libregraph.
 		}
 	}
 
+	if err := i.checkUserAttributesMapped(user); err != nil {
+		return nil, err
+	}
+
 	mr := ldap.ModifyRequest{DN: e.DN}
 	if user.DisplayName != nil && *user.DisplayName != "" {
 		if e.GetEqualFoldAttributeValue(i.userAttributeMap.displayName) != *user.DisplayName {
@@ -247,27 +365,91 @@ func (i *LDAP) UpdateUser(ctx context.Context, nameOrID string, user libregraph.
 		// is actually different from the old one.
 		mr.Replace("userPassword", []string{*user.PasswordProfile.Password})
 	}
+	if user.Surname != nil && *user.Surname != "" {
+		if e.GetEqualFoldAttributeValue(i.userAttributeMap.surname) != *user.Surname {
+			mr.Replace(i.userAttributeMap.surname, []string{*user.Surname})
+		}
+	}
+	modifyOptionalAttribute(&mr, e, i.userAttributeMap.givenName, user.GivenName)
+	modifyOptionalAttribute(&mr, e, i.userAttributeMap.preferredLanguage, user.PreferredLanguage)
+	if user.AccountEnabled != nil {
+		if v := e.GetEqualFoldAttributeValue(i.userAttributeMap.enabled); v == "" || parseLDAPBool(v) != *user.AccountEnabled {
+			mr.Replace(i.userAttributeMap.enabled, []string{ldapBool(*user.AccountEnabled)})
+		}
+	}
+	return &mr, nil
+}
 
-	if err := i.conn.Modify(&mr); err != nil {
-		return nil, err
+// hashSchemeRegexp matches the scheme prefix of a hashed password like {SSHA} or {ARGON2}
+var hashSchemeRegexp = regexp.MustCompile(`^\{[A-Za-z0-9._-]+\}`)
+
+// checkPassword rejects passwords which start with the scheme of a hashed password. The LDAP server would store them
+// as they are, which bypasses its password policy and allows setting a password hashed by the client.
+func checkPassword(user libregraph.User) error {
+	if user.PasswordProfile == nil || user.PasswordProfile.Password == nil {
+		return nil
 	}
+	if hashSchemeRegexp.MatchString(*user.PasswordProfile.Password) {
+		return errorcode.New(errorcode.InvalidRequest, "the password must not start with a hash scheme like {SSHA}")
+	}
+	return nil
+}
 
-	// Read	back user from LDAP to get the generated UUID
-	e, err = i.getUserByDN(e.DN)
-	if err != nil {
-		return nil, err
+// passwordPolicyError returns a graph error for a password rejected by the password policy of the LDAP server, which
+// is reported as a constraint violation. Other errors are returned unchanged.
+func passwordPolicyError(err error, user libregraph.User) error {
+	if user.PasswordProfile == nil || user.PasswordProfile.Password == nil {
+		return err
+	}
+	var lerr *ldap.Error
+	if !errors.As(err, &lerr) || lerr.ResultCode != ldap.LDAPResultConstraintViolation {
+		return err
+	}
+	msg := "the password does not satisfy the password policy"
+	if lerr.Err != nil && lerr.Err.Error() != "" {
+		msg = lerr.Err.Error()
+	}
+	return errorcode.New(errorcode.PasswordPolicyViolation, msg)
+}
+
+// modifyOptionalAttribute adds the change of an attribute which may be removed to the modify request. The attribute
+// is removed if the value is empty and replaced if it differs from the current one.
+func modifyOptionalAttribute(mr *ldap.ModifyRequest, e *ldap.Entry, attribute string, value *string) {
+	current := e.GetEqualFoldAttributeValue(attribute)
+	switch {
+	case value == nil || *value == current:
+	case *value == "":
+		mr.Delete(attribute, nil)
+	default:
+		mr.Replace(attribute, []string{*value})
 	}
-	return i.createUserModelFromLDAP(e), nil
 }
 
 func (i *LDAP) getUserByDN(dn string) (*ldap.Entry, error) {
+	return i.getEntryByDN(dn, i.userAttributes())
+}
+
+// userAttributes returns the attributes which are read from user entries, the optional attributes are only read if
+// they are mapped.
+func (i *LDAP) userAttributes() []string {
 	attrs := []string{
 		i.userAttributeMap.displayName,
 		i.userAttributeMap.id,
 		i.userAttributeMap.mail,
 		i.userAttributeMap.userName,
 	}
-	return i.getEntryByDN(dn, attrs)
+	for _, a := range []string{
+		i.userAttributeMap.surname,
+		i.userAttributeMap.givenName,
+		i.userAttributeMap.preferredLanguage,
+		i.userAttributeMap.creationTime,
+		i.userAttributeMap.enabled,
+	} {
+		if a != "" {
+			attrs = append(attrs, a)
+		}
+	}
+	return attrs
 }
 
 func (i *LDAP) getGroupByDN(dn string) (*ldap.Entry, error) {
@@ -300,6 +482,82 @@ func (i *LDAP) getEntryByDN(dn string, attrs []string) (*ldap.Entry, error) {
 	return res.Entries[0], nil
 }
 
+// getUsersByDNs reads the user entries with the given DNs, DNs of other entries are left out.
+func (i *LDAP) getUsersByDNs(dns []string) ([]*ldap.Entry, error) {
+	return i.getEntriesByDNs(dns, i.userBaseDN, i.userScope,
+		fmt.Sprintf("%s(objectClass=%s)", i.userFilter, i.userObjectClass), i.userAttributes())
+}
+
+// getEntriesByDNs reads the entries with the given DNs below baseDN which match the filter. Instead of reading every
+// entry on its own, the entries are searched by the first RDN of their DN, dnBatchSize DNs at a time. Entries which
+// have the same RDN but another DN are left out.
+func (i *LDAP) getEntriesByDNs(dns []string, baseDN string, scope int, filter string, attrs []string) ([]*ldap.Entry, error) {
+	wanted := make(map[string]bool, len(dns))
+	rdnFilters := make([]string, 0, len(dns))
+	for _, dn := range dns {
+		if dn == "" {
+			continue
+		}
+		n := normalizeDN(dn)
+		if wanted[n] {
+			continue
+		}
+		f, err := rdnFilter(dn)
+		if err != nil {
+			i.logger.Warn().Err(err).Str("dn", dn).Msg("Ignoring invalid DN")
+			continue
+		}
+		wanted[n] = true
+		rdnFilters = append(rdnFilters, f)
+	}
+
+	var result []*ldap.Entry
+	for start := 0; start < len(rdnFilters); start += dnBatchSize {
+		end := start + dnBatchSize
+		if end > len(rdnFilters) {
+			end = len(rdnFilters)
+		}
+		searchRequest := ldap.NewSearchRequest(
+			baseDN, scope, ldap.NeverDerefAliases, 0, 0, false,
+			fmt.Sprintf("(&%s(|%s))", filter, strings.Join(rdnFilters[start:end], "")),
+			attrs,
+			nil,
+		)
+		i.logger.Debug().Str("backend", "ldap").Int("dns", end-start).Msgf("Search entries by DN in %s", baseDN)
+		res, err := i.conn.Search(searchRequest)
+		if err != nil {
+			i.logger.Error().Err(err).Str("backend", "ldap").Msg("Search entries by DN failed")
+			return nil, errorcode.New(errorcode.ItemNotFound, err.Error())
+		}
+		for _, e := range res.Entries {
+			if wanted[normalizeDN(e.DN)] {
+				result = append(result, e)
+			}
+		}
+	}
+	return result, nil
+}
+
+// rdnFilter returns a filter matching the attribute values of the first RDN of a DN.
+func rdnFilter(dn string) (string, error) {
+	parsed, err := ldap.ParseDN(dn)
+	if err != nil {
+		return "", err
+	}
+	if len(parsed.RDNs) == 0 {
+		return "", errors.New("empty DN")
+	}
+	attrs := parsed.RDNs[0].Attributes
+	var b strings.Builder
+	for _, a := range attrs {
+		fmt.Fprintf(&b, "(%s=%s)", a.Type, ldap.EscapeFilter(a.Value))
+	}
+	if len(attrs) == 1 {
+		return b.String(), nil
+	}
+	return "(&" + b.String() + ")", nil
+}
+
 func (i *LDAP) getLDAPUserByID(id string) (*ldap.Entry, error) {
 	id = ldap.EscapeFilter(id)
 	filter := fmt.Sprintf("(%s=%s)", i.userAttributeMap.id, id)
@@ -316,12 +574,7 @@ func (i *LDAP) getLDAPUserByFilter(filter string) (*ldap.Entry, error) {
 	searchRequest := ldap.NewSearchRequest(
 		i.userBaseDN, i.userScope, ldap.NeverDerefAliases, 1, 0, false,
 		fmt.Sprintf("(&%s(objectClass=%s)%s)", i.userFilter, i.userObjectClass, filter),
-		[]string{
-			i.userAttributeMap.displayName,
-			i.userAttributeMap.id,
-			i.userAttributeMap.mail,
-			i.userAttributeMap.userName,
-		},
+		i.userAttributes(),
 		nil,
 	)
 	i.logger.Debug().Str("backend", "ldap").Msgf("Search %s", i.userBaseDN)
@@ -354,47 +607,198 @@ func (i *LDAP) GetUser(ctx context.Context, nameOrID string) (*libregraph.User,
 	return i.createUserModelFromLDAP(e), nil
 }
 
-func (i *LDAP) GetUsers(ctx context.Context, queryParam url.Values) ([]*libregraph.User, error) {
+func (i *LDAP) GetUsers(ctx context.Context, queryParam url.Values) ([]*libregraph.User, paging.Info, error) {
 	i.logger.Debug().Str("backend", "ldap").Msg("GetUsers")
 
+	page, err := paging.Parse(queryParam, 0)
+	if err != nil {
+		return nil, paging.Info{}, errorcode.New(errorcode.InvalidRequest, err.Error())
+	}
+
 	search := queryParam.Get("search")
 	if search == "" {
 		search = queryParam.Get("$search")
 	}
-	userFilter := fmt.Sprintf("%s(objectClass=%s)", i.userFilter, i.userObjectClass)
+	var searchFilter string
 	if search != "" {
 		search = ldap.EscapeFilter(search)
-		userFilter = fmt.Sprintf(
-			"(&(%s)(|(%s=%s*)(%s=%s*)(%s=%s*)))",
-			userFilter,
+		searchFilter = fmt.Sprintf(
+			"(|(%s=%s*)(%s=%s*)(%s=%s*))",
 			i.userAttributeMap.userName, search,
 			i.userAttributeMap.mail, search,
 			i.userAttributeMap.displayName, search,
 		)
 	}
+	queryFilter, err := compileLDAPFilter(queryParam, i.userFilterAttributes(), i.userLambdaFilter)
+	if err != nil {
+		return nil, paging.Info{}, err
+	}
+	userFilter := fmt.Sprintf("(&%s(objectClass=%s)%s%s)", i.userFilter, i.userObjectClass, searchFilter, queryFilter)
 	searchRequest := ldap.NewSearchRequest(
 		i.userBaseDN, i.userScope, ldap.NeverDerefAliases, 0, 0, false,
 		userFilter,
-		[]string{
-			i.userAttributeMap.displayName,
-			i.userAttributeMap.id,
-			i.userAttributeMap.mail,
-			i.userAttributeMap.userName,
-		},
+		i.userAttributes(),
 		nil,
 	)
 	i.logger.Debug().Str("backend", "ldap").Msgf("Search %s", i.userBaseDN)
-	res, err := i.conn.Search(searchRequest)
+	entries, info, err := i.searchPage(searchRequest, page)
 	if err != nil {
-		return nil, errorcode.New(errorcode.ItemNotFound, err.Error())
+		return nil, info, err
 	}
 
-	users := make([]*libregraph.User, 0, len(res.Entries))
+	users := make([]*libregraph.User, 0, len(entries))
 
-	for _, e := range res.Entries {
+	for _, e := range entries {
 		users = append(users, i.createUserModelFromLDAP(e))
 	}
-	return users, nil
+	return users, info, nil
+}
+
+// searchPage runs the search for the requested page of the results. Paged requests are read from the server using
+// the simple paged results control. The cookie of the control is only valid on the connection which started the
+// search, so the next token holds the offset of the next page and every page runs the search again, reading past the
+// entries of the previous pages.
+func (i *LDAP) searchPage(searchRequest *ldap.SearchRequest, page paging.Page) ([]*ldap.Entry, paging.Info, error) {
+	info := paging.Info{}
+	if !page.Paged() {
+		res, err := i.conn.Search(searchRequest)
+		if err != nil {
+			return nil, info, errorcode.New(errorcode.ItemNotFound, err.Error())
+		}
+		if page.Count {
+			count := len(res.Entries)
+			info.Count = &count
+		}
+		return res.Entries, info, nil
+	}
+
+	if page.Count {
+		count, err := i.countEntries(searchRequest)
+		if err != nil {
+			return nil, info, errorcode.New(errorcode.ItemNotFound, err.Error())
+		}
+		info.Count = &count
+	}
+
+	offset := page.Skip
+	if page.Token != "" {
+		t, err := paging.DecodeToken(page.Token)
+		if err != nil {
+			return nil, info, errorcode.New(errorcode.InvalidRequest, "invalid $skiptoken")
+		}
+		offset = t.Offset
+	}
+
+	var entries []*ldap.Entry
+	more := false
+	err := i.pinned(func(conn ldap.Client) error {
+		var err error
+		entries, more, err = readRange(conn, searchRequest, offset, page.Top)
+		return err
+	})
+	if err != nil {
+		return nil, info, errorcode.New(errorcode.ItemNotFound, err.Error())
+	}
+	if more {
+		info.NextToken = paging.Token{Offset: offset + len(entries)}.Encode()
+	}
+	return entries, info, nil
+}
+
+// pinned runs f with a single connection. The cookie of a paged search is only valid on the connection which started
+// the search, so all pages have to be requested on the same connection of a pool.
+func (i *LDAP) pinned(f func(conn ldap.Client) error) error {
+	if p, ok := i.conn.(interface {
+		Pinned(operation string, f func(ldap.Client) error) error
+	}); ok {
+		return p.Pinned("search", f)
+	}
+	return f(i.conn)
+}
+
+// readRange reads up to top entries after skipping offset entries of the search results, all entries if top is 0.
+// It reports if the server has more entries.
+func readRange(conn ldap.Client, searchRequest *ldap.SearchRequest, offset, top int) ([]*ldap.Entry, bool, error) {
+	entries := []*ldap.Entry{}
+	read := 0
+	var cookie []byte
+	for {
+		// read past the skipped entries in pages of at most ldapPageSize entries and then request the page itself
+		size := ldapPageSize
+		switch {
+		case read < offset && offset-read < size:
+			size = offset - read
+		case read >= offset && top > 0 && top-len(entries) < size:
+			size = top - len(entries)
+		}
+		res, next, err := searchPaged(conn, searchRequest, size, cookie)
+		if err != nil {
+			return nil, false, err
+		}
+		for _, e := range res {
+			if read >= offset && (top == 0 || len(entries) < top) {
+				entries = append(entries, e)
+			}
+			read++
+		}
+		cookie = next
+		if len(cookie) == 0 {
+			return entries, false, nil
+		}
+		if top > 0 && len(entries) == top {
+			abandonPaged(conn, searchRequest, cookie)
+			return entries, true, nil
+		}
+	}
+}
+
+// abandonPaged tells the server that the remaining pages of a paged search won't be requested, so it can release the
+// state of the search. The entries have already been read, so a failure only leaves the state to the server's timeout.
+func abandonPaged(conn ldap.Client, searchRequest *ldap.SearchRequest, cookie []byte) {
+	_, _, _ = searchPaged(conn, searchRequest, 0, cookie)
+}
+
+// searchPaged requests a single page of the search results. It returns the entries and the cookie to request the
+// next page, which is empty when all entries have been returned.
+func searchPaged(conn ldap.Client, searchRequest *ldap.SearchRequest, size int, cookie []byte) ([]*ldap.Entry, []byte, error) {
+	control := ldap.NewControlPaging(uint32(size))
+	control.SetCookie(cookie)
+
+	sr := *searchRequest
+	sr.Controls = append(append([]ldap.Control{}, searchRequest.Controls...), control)
+	res, err := conn.Search(&sr)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var next []byte
+	if c, ok := ldap.FindControl(res.Controls, ldap.ControlTypePaging).(*ldap.ControlPaging); ok {
+		next = c.Cookie
+	}
+	return res.Entries, next, nil
+}
+
+// countEntries counts the results of a search without reading any attributes.
+func (i *LDAP) countEntries(searchRequest *ldap.SearchRequest) (int, error) {
+	sr := *searchRequest
+	sr.Attributes = []string{"1.1"}
+
+	count := 0
+	err := i.pinned(func(conn ldap.Client) error {
+		var cookie []byte
+		for {
+			entries, next, err := searchPaged(conn, &sr, ldapPageSize, cookie)
+			if err != nil {
+				return err
+			}
+			count += len(entries)
+			if len(next) == 0 {
+				return nil
+			}
+			cookie = next
+		}
+	})
+	return count, err
 }
 
 func (i *LDAP) GetGroup(ctx context.Context, nameOrID string) (*libregraph.Group, error) {
@@ -508,23 +912,32 @@ func (i *LDAP) removeMemberFromGroupEntry(group *ldap.Entry, memberDN string) (*
 	return &mr, nil
 }
 
-func (i *LDAP) GetGroups(ctx context.Context, queryParam url.Values) ([]*libregraph.Group, error) {
+func (i *LDAP) GetGroups(ctx context.Context, queryParam url.Values) ([]*libregraph.Group, paging.Info, error) {
 	i.logger.Debug().Str("backend", "ldap").Msg("GetGroups")
 
+	page, err := paging.Parse(queryParam, 0)
+	if err != nil {
+		return nil, paging.Info{}, errorcode.New(errorcode.InvalidRequest, err.Error())
+	}
+
 	search := queryParam.Get("search")
 	if search == "" {
 		search = queryParam.Get("$search")
 	}
-	groupFilter := fmt.Sprintf("%s(objectClass=%s)", i.groupFilter, i.groupObjectClass)
+	var searchFilter string
 	if search != "" {
 		search = ldap.EscapeFilter(search)
-		groupFilter = fmt.Sprintf(
-			"(&(%s)(|(%s=%s*)(%s=%s*)))",
-			groupFilter,
+		searchFilter = fmt.Sprintf(
+			"(|(%s=%s*)(%s=%s*))",
 			i.groupAttributeMap.name, search,
 			i.groupAttributeMap.id, search,
 		)
 	}
+	queryFilter, err := compileLDAPFilter(queryParam, i.groupFilterAttributes(), i.groupLambdaFilter)
+	if err != nil {
+		return nil, paging.Info{}, err
+	}
+	groupFilter := fmt.Sprintf("(&%s(objectClass=%s)%s%s)", i.groupFilter, i.groupObjectClass, searchFilter, queryFilter)
 	searchRequest := ldap.NewSearchRequest(
 		i.groupBaseDN, i.groupScope, ldap.NeverDerefAliases, 0, 0, false,
 		groupFilter,
@@ -535,19 +948,92 @@ func (i *LDAP) GetGroups(ctx context.Context, queryParam url.Values) ([]*libregr
 		nil,
 	)
 	i.logger.Debug().Str("backend", "ldap").Str("Base", i.groupBaseDN).Str("filter", groupFilter).Msg("ldap search")
-	res, err := i.conn.Search(searchRequest)
+	entries, info, err := i.searchPage(searchRequest, page)
 	if err != nil {
-		return nil, errorcode.New(errorcode.ItemNotFound, err.Error())
+		return nil, info, err
 	}
 
-	groups := make([]*libregraph.Group, 0, len(res.Entries))
+	groups := make([]*libregraph.Group, 0, len(entries))
 
-	for _, e := range res.Entries {
+	for _, e := range entries {
 		groups = append(groups, i.createGroupModelFromLDAP(e))
 	}
+	return groups, info, nil
+}
+
+// GetUserGroups implements the Backend Interface for the LDAP Backend
+func (i *LDAP) GetUserGroups(ctx context.Context, nameOrID string) ([]*libregraph.Group, error) {
+	i.logger.Debug().Str("backend", "ldap").Msg("GetUserGroups")
+	e, err := i.getLDAPUserByNameOrID(nameOrID)
+	if err != nil {
+		return nil, err
+	}
+	groupEntries, err := i.getLDAPGroupsByFilter(fmt.Sprintf("(%s=%s)", i.groupAttributeMap.member, ldap.EscapeFilter(e.DN)), false, false)
+	if err != nil {
+		return nil, err
+	}
+	groups := make([]*libregraph.Group, 0, len(groupEntries))
+	for _, ge := range groupEntries {
+		groups = append(groups, i.createGroupModelFromLDAP(ge))
+	}
 	return groups, nil
 }
 
+// GetUsersGroups implements the Backend Interface for the LDAP Backend. The users and their groups are read with
+// one search each, no matter how many users are requested.
+func (i *LDAP) GetUsersGroups(ctx context.Context, userIDs []string) (map[string][]*libregraph.Group, error) {
+	i.logger.Debug().Str("backend", "ldap").Msg("GetUsersGroups")
+	result := make(map[string][]*libregraph.Group, len(userIDs))
+	if len(userIDs) == 0 {
+		return result, nil
+	}
+
+	var b strings.Builder
+	b.WriteString("(|")
+	for _, id := range userIDs {
+		fmt.Fprintf(&b, "(%s=%s)", i.userAttributeMap.id, ldap.EscapeFilter(id))
+	}
+	b.WriteString(")")
+	searchRequest := ldap.NewSearchRequest(
+		i.userBaseDN, i.userScope, ldap.NeverDerefAliases, 0, 0, false,
+		fmt.Sprintf("(&%s(objectClass=%s)%s)", i.userFilter, i.userObjectClass, b.String()),
+		[]string{i.userAttributeMap.id},
+		nil,
+	)
+	i.logger.Debug().Str("backend", "ldap").Msgf("Search %s", i.userBaseDN)
+	res, err := i.conn.Search(searchRequest)
+	if err != nil {
+		return nil, errorcode.New(errorcode.ItemNotFound, err.Error())
+	}
+
+	// the ids of the users by their normalized DN
+	users := make(map[string]string, len(res.Entries))
+	dns := make([]string, 0, len(res.Entries))
+	for _, e := range res.Entries {
+		id := e.GetEqualFoldAttributeValue(i.userAttributeMap.id)
+		users[normalizeDN(e.DN)] = id
+		dns = append(dns, e.DN)
+		result[id] = []*libregraph.Group{}
+	}
+	if len(dns) == 0 {
+		return result, nil
+	}
+
+	groupEntries, err := i.getLDAPGroupsByFilter(i.memberFilter(dns), true, false)
+	if err != nil {
+		return nil, err
+	}
+	for _, ge := range groupEntries {
+		group := i.createGroupModelFromLDAP(ge)
+		for _, memberDN := range ge.GetEqualFoldAttributeValues(i.groupAttributeMap.member) {
+			if id, ok := users[normalizeDN(memberDN)]; ok {
+				result[id] = append(result[id], group)
+			}
+		}
+	}
+	return result, nil
+}
+
 // GetGroupMembers implements the Backend Interface for the LDAP Backend
 func (i *LDAP) GetGroupMembers(ctx context.Context, groupID string) ([]*libregraph.User, error) {
 	e, err := i.getLDAPGroupByNameOrID(groupID, true)
@@ -720,16 +1206,204 @@ func (i *LDAP) RemoveMemberFromGroup(ctx context.Context, groupID string, member
 	return nil
 }
 
+// Capabilities implements the Backend Interface for the LDAP Backend. All writes depend on the write access to the
+// LDAP server.
+func (i *LDAP) Capabilities(ctx context.Context) Capabilities {
+	return Capabilities{
+		CreateUser:         i.writeEnabled,
+		UpdateUser:         i.writeEnabled,
+		DeleteUser:         i.writeEnabled,
+		CreateGroup:        i.writeEnabled,
+		DeleteGroup:        i.writeEnabled,
+		ListGroupMembers:   true,
+		UpdateGroupMembers: i.writeEnabled,
+	}
+}
+
+// noMatchFilter is an LDAP filter that doesn't match any entry
+const noMatchFilter = "(!(objectClass=*))"
+
+// compileLDAPFilter compiles the $filter of the query into an LDAP filter. The attributes map the properties of the
+// graph resource to the LDAP attributes, lambda compiles the any() expressions.
+func compileLDAPFilter(queryParam url.Values, attributes map[string]string, lambda func(*filter.Node) (string, error)) (string, error) {
+	n, err := filter.Parse(queryParam)
+	if err != nil {
+		return "", filterError(err)
+	}
+	if n == nil {
+		return "", nil
+	}
+	f, err := ldapFilter(n, attributes, lambda)
+	if err != nil {
+		return "", filterError(err)
+	}
+	return f, nil
+}
+
+func ldapFilter(n *filter.Node, attributes map[string]string, lambda func(*filter.Node) (string, error)) (string, error) {
+	switch n.Op {
+	case filter.OpAnd, filter.OpOr, filter.OpNot:
+		op := map[string]string{filter.OpAnd: "&", filter.OpOr: "|", filter.OpNot: "!"}[n.Op]
+		var operands strings.Builder
+		for _, c := range n.Children {
+			f, err := ldapFilter(c, attributes, lambda)
+			if err != nil {
+				return "", err
+			}
+			operands.WriteString(f)
+		}
+		return fmt.Sprintf("(%s%s)", op, operands.String()), nil
+	case filter.OpAny:
+		return lambda(n)
+	}
+
+	attr, ok := attributes[n.Property]
+	if !ok {
+		return "", fmt.Errorf("%w: filtering by '%s'", filter.ErrUnsupported, n.Property)
+	}
+	if n.Value == nil {
+		// comparing with null tests the presence of the attribute
+		if n.Op == filter.OpEq {
+			return fmt.Sprintf("(!(%s=*))", attr), nil
+		}
+		return fmt.Sprintf("(%s=*)", attr), nil
+	}
+	value := ldap.EscapeFilter(fmt.Sprint(n.Value))
+	switch n.Op {
+	case filter.OpEq:
+		return fmt.Sprintf("(%s=%s)", attr, value), nil
+	case filter.OpNe:
+		return fmt.Sprintf("(!(%s=%s))", attr, value), nil
+	case filter.OpStartsWith:
+		return fmt.Sprintf("(%s=%s*)", attr, value), nil
+	}
+	return "", fmt.Errorf("%w: operator '%s'", filter.ErrUnsupported, n.Op)
+}
+
+func (i *LDAP) userFilterAttributes() map[string]string {
+	attrs := map[string]string{
+		"id":                       i.userAttributeMap.id,
+		"displayName":              i.userAttributeMap.displayName,
+		"mail":                     i.userAttributeMap.mail,
+		"onPremisesSamAccountName": i.userAttributeMap.userName,
+	}
+	for property, attribute := range map[string]string{
+		"surname":           i.userAttributeMap.surname,
+		"givenName":         i.userAttributeMap.givenName,
+		"preferredLanguage": i.userAttributeMap.preferredLanguage,
+	} {
+		if attribute != "" {
+			attrs[property] = attribute
+		}
+	}
+	return attrs
+}
+
+func (i *LDAP) groupFilterAttributes() map[string]string {
+	return map[string]string{
+		"id":          i.groupAttributeMap.id,
+		"displayName": i.groupAttributeMap.name,
+	}
+}
+
+// lambdaEquality returns the attribute and value of the equality condition of an any() expression.
+func lambdaEquality(n *filter.Node, attributes map[string]string) (string, string, error) {
+	c := n.Children[0]
+	attr, ok := attributes[c.Property]
+	v, isString := c.Value.(string)
+	if c.Op != filter.OpEq || !ok || !isString {
+		return "", "", fmt.Errorf("%w: %s/any() only supports comparing the id or displayName with eq", filter.ErrUnsupported, n.Property)
+	}
+	return attr, v, nil
+}
+
+// userLambdaFilter compiles memberOf/any(g:g/id eq 'id') expressions. The members of the matching groups are read
+// and the filter matches their ids, so the LDAP server doesn't need to maintain a memberOf attribute.
+func (i *LDAP) userLambdaFilter(n *filter.Node) (string, error) {
+	if n.Property != "memberOf" {
+		return "", fmt.Errorf("%w: lambda expression on '%s'", filter.ErrUnsupported, n.Property)
+	}
+	attr, v, err := lambdaEquality(n, i.groupFilterAttributes())
+	if err != nil {
+		return "", err
+	}
+	groups, err := i.getLDAPGroupsByFilter(fmt.Sprintf("(%s=%s)", attr, ldap.EscapeFilter(v)), true, false)
+	if err != nil {
+		return "", err
+	}
+	var memberDNs []string
+	for _, g := range groups {
+		memberDNs = append(memberDNs, g.GetEqualFoldAttributeValues(i.groupAttributeMap.member)...)
+	}
+	members, err := i.getUsersByDNs(memberDNs)
+	if err != nil {
+		return "", err
+	}
+	var f strings.Builder
+	ids := 0
+	for _, m := range members {
+		if id := m.GetEqualFoldAttributeValue(i.userAttributeMap.id); id != "" {
+			fmt.Fprintf(&f, "(%s=%s)", i.userAttributeMap.id, ldap.EscapeFilter(id))
+			ids++
+		}
+	}
+	switch ids {
+	case 0:
+		return noMatchFilter, nil
+	case 1:
+		return f.String(), nil
+	}
+	return "(|" + f.String() + ")", nil
+}
+
+// groupLambdaFilter compiles members/any(m:m/id eq 'id') expressions.
+func (i *LDAP) groupLambdaFilter(n *filter.Node) (string, error) {
+	if n.Property != "members" {
+		return "", fmt.Errorf("%w: lambda expression on '%s'", filter.ErrUnsupported, n.Property)
+	}
+	attr, v, err := lambdaEquality(n, i.userFilterAttributes())
+	if err != nil {
+		return "", err
+	}
+	e, err := i.getLDAPUserByFilter(fmt.Sprintf("(%s=%s)", attr, ldap.EscapeFilter(v)))
+	switch {
+	case err == errNotFound:
+		return noMatchFilter, nil
+	case err != nil:
+		return "", err
+	}
+	return fmt.Sprintf("(%s=%s)", i.groupAttributeMap.member, ldap.EscapeFilter(e.DN)), nil
+}
+
 func (i *LDAP) createUserModelFromLDAP(e *ldap.Entry) *libregraph.User {
 	if e == nil {
 		return nil
 	}
-	return &libregraph.User{
+	u := &libregraph.User{
 		DisplayName:              pointerOrNil(e.GetEqualFoldAttributeValue(i.userAttributeMap.displayName)),
 		Mail:                     pointerOrNil(e.GetEqualFoldAttributeValue(i.userAttributeMap.mail)),
 		OnPremisesSamAccountName: pointerOrNil(e.GetEqualFoldAttributeValue(i.userAttributeMap.userName)),
 		Id:                       pointerOrNil(e.GetEqualFoldAttributeValue(i.userAttributeMap.id)),
+		Surname:                  pointerOrNil(e.GetEqualFoldAttributeValue(i.userAttributeMap.surname)),
+		GivenName:                pointerOrNil(e.GetEqualFoldAttributeValue(i.userAttributeMap.givenName)),
+		PreferredLanguage:        pointerOrNil(e.GetEqualFoldAttributeValue(i.userAttributeMap.preferredLanguage)),
+	}
+	if i.userAttributeMap.enabled != "" {
+		// users without the attribute are enabled
+		enabled := true
+		if v := e.GetEqualFoldAttributeValue(i.userAttributeMap.enabled); v != "" {
+			enabled = parseLDAPBool(v)
+		}
+		u.AccountEnabled = &enabled
 	}
+	if v := e.GetEqualFoldAttributeValue(i.userAttributeMap.creationTime); v != "" {
+		if t, err := parseGeneralizedTime(v); err == nil {
+			u.CreatedDateTime = &t
+		} else {
+			i.logger.Debug().Err(err).Str("dn", e.DN).Msg("could not parse the creation time of the user")
+		}
+	}
+	return u
 }
 
 func (i *LDAP) createGroupModelFromLDAP(e *ldap.Entry) *libregraph.Group {
@@ -739,6 +1413,24 @@ func (i *LDAP) createGroupModelFromLDAP(e *ldap.Entry) *libregraph.Group {
 	}
 }
 
+// ldapBool returns the LDAP representation of a boolean.
+func ldapBool(b bool) string {
+	if b {
+		return "TRUE"
+	}
+	return "FALSE"
+}
+
+func parseLDAPBool(s string) bool {
+	return strings.EqualFold(s, "TRUE")
+}
+
+// parseGeneralizedTime parses an LDAP GeneralizedTime value like "20220517093000Z", the fraction of the seconds is
+// optional.
+func parseGeneralizedTime(s string) (time.Time, error) {
+	return time.Parse("20060102150405Z0700", s)
+}
+
 func pointerOrNil(val string) *string {
 	if val == "" {
 		return nil
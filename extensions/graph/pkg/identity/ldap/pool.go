@@ -0,0 +1,204 @@
+package ldap
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/go-ldap/ldap/v3"
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/owncloud/ocis/ocis-pkg/log"
+)
+
+const (
+	// acquireTimeout is the time a request waits for an idle connection of the pool before it fails.
+	acquireTimeout = 30 * time.Second
+	// authenticateTimeout limits the time to wait for the result of a bind verifying a password.
+	authenticateTimeout = 10 * time.Second
+)
+
+var errPoolExhausted = errors.New("timeout waiting for an idle LDAP connection")
+
+// Pool is a fixed size pool of LDAP connections, every connection reconnects automatically.
+// It implements the ldap.Client interface. Each request uses an idle connection of the pool,
+// requests wait until a connection is idle again when all connections are in use, but no longer
+// than acquireTimeout.
+type Pool struct {
+	conns    chan ldap.Client
+	duration *prometheus.HistogramVec
+	timeout  time.Duration
+	// config is used to open the connections verifying passwords
+	config Config
+}
+
+// NewLDAPPool returns a pool of size connections to the LDAP server. A size below 1 creates a pool
+// with a single connection. The durations of the requests are observed by the given histogram,
+// which may be nil.
+func NewLDAPPool(logger *log.Logger, config Config, size int, duration *prometheus.HistogramVec) *Pool {
+	if size < 1 {
+		size = 1
+	}
+	conns := make([]ldap.Client, 0, size)
+	for i := 0; i < size; i++ {
+		conns = append(conns, NewLDAPWithReconnect(logger, config))
+	}
+	p := newPool(conns, duration)
+	p.config = config
+	return p
+}
+
+func newPool(conns []ldap.Client, duration *prometheus.HistogramVec) *Pool {
+	p := &Pool{
+		conns:    make(chan ldap.Client, len(conns)),
+		duration: duration,
+		timeout:  acquireTimeout,
+	}
+	for _, c := range conns {
+		p.conns <- c
+	}
+	return p
+}
+
+// acquire waits for an idle connection. The returned function puts the connection back into the
+// pool and records the duration of the operation. It fails if no connection became idle within
+// the timeout of the pool.
+func (p *Pool) acquire(operation string) (ldap.Client, func(), error) {
+	timer := time.NewTimer(p.timeout)
+	defer timer.Stop()
+	var c ldap.Client
+	select {
+	case c = <-p.conns:
+	case <-timer.C:
+		return nil, nil, ldap.NewError(ldap.LDAPResultBusy, errPoolExhausted)
+	}
+	start := time.Now()
+	return c, func() {
+		if p.duration != nil {
+			p.duration.WithLabelValues(operation).Observe(time.Since(start).Seconds())
+		}
+		p.conns <- c
+	}, nil
+}
+
+func (p *Pool) Search(sr *ldap.SearchRequest) (*ldap.SearchResult, error) {
+	c, release, err := p.acquire("search")
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	return c.Search(sr)
+}
+
+// Pinned runs f with a single connection of the pool, which is not used by other requests until f returns. Requests
+// depending on each other, like the pages of a paged search, have to be sent on the same connection.
+func (p *Pool) Pinned(operation string, f func(ldap.Client) error) error {
+	c, release, err := p.acquire(operation)
+	if err != nil {
+		return err
+	}
+	defer release()
+	return f(c)
+}
+
+// Authenticate verifies the password of an entry with a bind on a new connection, the connections of the pool stay
+// bound to the configured user. The connection is opened with the TLS settings of the pool.
+func (p *Pool) Authenticate(dn, password string) error {
+	l, err := dial(p.config)
+	if err != nil {
+		return err
+	}
+	defer l.Close()
+	l.SetTimeout(authenticateTimeout)
+	return l.Bind(dn, password)
+}
+
+func (p *Pool) Add(a *ldap.AddRequest) error {
+	c, release, err := p.acquire("add")
+	if err != nil {
+		return err
+	}
+	defer release()
+	return c.Add(a)
+}
+
+func (p *Pool) Del(d *ldap.DelRequest) error {
+	c, release, err := p.acquire("delete")
+	if err != nil {
+		return err
+	}
+	defer release()
+	return c.Del(d)
+}
+
+func (p *Pool) Modify(m *ldap.ModifyRequest) error {
+	c, release, err := p.acquire("modify")
+	if err != nil {
+		return err
+	}
+	defer release()
+	return c.Modify(m)
+}
+
+func (p *Pool) ModifyDN(m *ldap.ModifyDNRequest) error {
+	c, release, err := p.acquire("modify_dn")
+	if err != nil {
+		return err
+	}
+	defer release()
+	return c.ModifyDN(m)
+}
+
+func (p *Pool) ModifyWithResult(m *ldap.ModifyRequest) (*ldap.ModifyResult, error) {
+	c, release, err := p.acquire("modify")
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	return c.ModifyWithResult(m)
+}
+
+// Remaining methods to fulfill ldap.Client interface
+
+func (p *Pool) Start() {}
+
+func (p *Pool) StartTLS(*tls.Config) error {
+	return ldap.NewError(ldap.LDAPResultNotSupported, fmt.Errorf("not implemented"))
+}
+
+func (p *Pool) Close() {}
+
+func (p *Pool) IsClosing() bool {
+	return false
+}
+
+func (p *Pool) SetTimeout(time.Duration) {}
+
+func (p *Pool) Bind(username, password string) error {
+	return ldap.NewError(ldap.LDAPResultNotSupported, fmt.Errorf("not implemented"))
+}
+
+func (p *Pool) UnauthenticatedBind(username string) error {
+	return ldap.NewError(ldap.LDAPResultNotSupported, fmt.Errorf("not implemented"))
+}
+
+func (p *Pool) SimpleBind(*ldap.SimpleBindRequest) (*ldap.SimpleBindResult, error) {
+	return nil, ldap.NewError(ldap.LDAPResultNotSupported, fmt.Errorf("not implemented"))
+}
+
+func (p *Pool) ExternalBind() error {
+	return ldap.NewError(ldap.LDAPResultNotSupported, fmt.Errorf("not implemented"))
+}
+
+func (p *Pool) Compare(dn, attribute, value string) (bool, error) {
+	return false, ldap.NewError(ldap.LDAPResultNotSupported, fmt.Errorf("not implemented"))
+}
+
+func (p *Pool) PasswordModify(*ldap.PasswordModifyRequest) (*ldap.PasswordModifyResult, error) {
+	return nil, ldap.NewError(ldap.LDAPResultNotSupported, fmt.Errorf("not implemented"))
+}
+
+func (p *Pool) SearchWithPaging(searchRequest *ldap.SearchRequest, pagingSize uint32) (*ldap.SearchResult, error) {
+	return nil, ldap.NewError(ldap.LDAPResultNotSupported, fmt.Errorf("not implemented"))
+}
@@ -0,0 +1,130 @@
+package ldap
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-ldap/ldap/v3"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+// blockingClient is an ldap.Client whose searches block until the release channel is closed.
+type blockingClient struct {
+	ldap.Client
+	mu      *sync.Mutex
+	active  *int
+	max     *int
+	release chan struct{}
+}
+
+func (c blockingClient) Search(*ldap.SearchRequest) (*ldap.SearchResult, error) {
+	c.mu.Lock()
+	*c.active++
+	if *c.active > *c.max {
+		*c.max = *c.active
+	}
+	c.mu.Unlock()
+	<-c.release
+	c.mu.Lock()
+	*c.active--
+	c.mu.Unlock()
+	return &ldap.SearchResult{}, nil
+}
+
+func TestPool(t *testing.T) {
+	var mu sync.Mutex
+	active, max := 0, 0
+	release := make(chan struct{})
+	conns := make([]ldap.Client, 2)
+	for i := range conns {
+		conns[i] = blockingClient{mu: &mu, active: &active, max: &max, release: release}
+	}
+	duration := prometheus.NewHistogramVec(prometheus.HistogramOpts{Name: "duration"}, []string{"operation"})
+	p := newPool(conns, duration)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := p.Search(&ldap.SearchRequest{}); err != nil {
+				t.Errorf("Expected success, got '%s'", err.Error())
+			}
+		}()
+	}
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	if max != 2 {
+		t.Errorf("Expected 2 concurrent searches, got %d", max)
+	}
+	if n := testutil.CollectAndCount(duration); n != 1 {
+		t.Errorf("Expected the durations of the searches, got %d series", n)
+	}
+}
+
+func TestPoolTimeout(t *testing.T) {
+	var mu sync.Mutex
+	active, max := 0, 0
+	release := make(chan struct{})
+	p := newPool([]ldap.Client{blockingClient{mu: &mu, active: &active, max: &max, release: release}}, nil)
+	p.timeout = 50 * time.Millisecond
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		_, _ = p.Search(&ldap.SearchRequest{})
+	}()
+	time.Sleep(10 * time.Millisecond)
+
+	_, err := p.Search(&ldap.SearchRequest{})
+	if !ldap.IsErrorWithCode(err, ldap.LDAPResultBusy) {
+		t.Errorf("Expected a busy error while the connection is in use, got '%v'", err)
+	}
+	close(release)
+	<-done
+	if _, err := p.Search(&ldap.SearchRequest{}); err != nil {
+		t.Errorf("Expected success once the connection is idle, got '%s'", err.Error())
+	}
+}
+
+// namedClient is an ldap.Client recording its name in the searches it serves.
+type namedClient struct {
+	ldap.Client
+	name string
+}
+
+func (c namedClient) Search(sr *ldap.SearchRequest) (*ldap.SearchResult, error) {
+	return &ldap.SearchResult{Referrals: []string{c.name}}, nil
+}
+
+func TestPoolPinned(t *testing.T) {
+	p := newPool([]ldap.Client{namedClient{name: "a"}, namedClient{name: "b"}}, nil)
+
+	for i := 0; i < 3; i++ {
+		var names []string
+		err := p.Pinned("search", func(c ldap.Client) error {
+			for j := 0; j < 3; j++ {
+				// a request through the pool meanwhile has to use the other connection
+				if _, err := p.Search(&ldap.SearchRequest{}); err != nil {
+					return err
+				}
+				res, err := c.Search(&ldap.SearchRequest{})
+				if err != nil {
+					return err
+				}
+				names = append(names, res.Referrals[0])
+			}
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("Expected success, got '%s'", err.Error())
+		}
+		if names[0] != names[1] || names[1] != names[2] {
+			t.Errorf("Expected all searches on the same connection, got %v", names)
+		}
+	}
+}
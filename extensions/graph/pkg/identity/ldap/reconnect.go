@@ -7,6 +7,8 @@ import (
 	"crypto/tls"
 	"errors"
 	"fmt"
+	"net"
+	"net/url"
 	"time"
 
 	"github.com/go-ldap/ldap/v3"
@@ -18,6 +20,9 @@ var (
 	errMaxRetries = errors.New("max retries")
 )
 
+// dialTimeout limits the time to connect to the LDAP server.
+const dialTimeout = 10 * time.Second
+
 type ldapConnection struct {
 	Conn  *ldap.Conn
 	Error error
@@ -36,6 +41,8 @@ type Config struct {
 	BindDN       string
 	BindPassword string
 	TLSConfig    *tls.Config
+	// StartTLS negotiates TLS with the StartTLS operation on ldap:// connections
+	StartTLS bool
 }
 
 func NewLDAPWithReconnect(logger *log.Logger, config Config) ConnWithReconnect {
@@ -209,14 +216,7 @@ func (c ConnWithReconnect) ldapAutoConnect(config Config) {
 func (c ConnWithReconnect) ldapConnect(config Config) (*ldap.Conn, error) {
 	c.logger.Debug().Msgf("Connecting to %s", config.URI)
 
-	var err error
-	var l *ldap.Conn
-	if config.TLSConfig != nil {
-		l, err = ldap.DialURL(config.URI, ldap.DialWithTLSConfig(config.TLSConfig))
-	} else {
-		l, err = ldap.DialURL(config.URI)
-	}
-
+	l, err := dial(config)
 	if err != nil {
 		c.logger.Error().Err(err).Msg("could not get ldap Connection")
 	} else {
@@ -236,6 +236,37 @@ func (c ConnWithReconnect) ldapConnect(config Config) (*ldap.Conn, error) {
 	return l, err
 }
 
+// dial opens an unbound connection to the LDAP server of the config, it negotiates TLS with StartTLS if it's enabled.
+func dial(config Config) (*ldap.Conn, error) {
+	opts := []ldap.DialOpt{ldap.DialWithDialer(&net.Dialer{Timeout: dialTimeout})}
+	if config.TLSConfig != nil {
+		opts = append(opts, ldap.DialWithTLSConfig(config.TLSConfig))
+	}
+	l, err := ldap.DialURL(config.URI, opts...)
+	if err != nil {
+		return nil, err
+	}
+	if config.StartTLS {
+		u, err := url.Parse(config.URI)
+		if err != nil {
+			l.Close()
+			return nil, err
+		}
+		tlsConfig := &tls.Config{}
+		if config.TLSConfig != nil {
+			tlsConfig = config.TLSConfig.Clone()
+		}
+		if tlsConfig.ServerName == "" {
+			tlsConfig.ServerName = u.Hostname()
+		}
+		if err := l.StartTLS(tlsConfig); err != nil {
+			l.Close()
+			return nil, err
+		}
+	}
+	return l, nil
+}
+
 func (c ConnWithReconnect) reconnect(resetConn *ldap.Conn) (*ldap.Conn, error) {
 	c.logger.Debug().Msg("LDAP connection reset")
 	c.reset <- resetConn
@@ -0,0 +1,810 @@
+package identity
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/go-ldap/ldap/v3"
+	"github.com/gofrs/uuid"
+	libregraph "github.com/owncloud/libre-graph-api-go"
+
+	"github.com/owncloud/ocis/extensions/graph/pkg/config"
+	"github.com/owncloud/ocis/extensions/graph/pkg/service/v0/errorcode"
+)
+
+// educationConfig holds the LDAP mapping of the education resources. Schools are organizationalUnits below the
+// school base DN. Classes are groups with an additional objectClass, education users are users with an additional
+// objectClass. The schools of users and classes are stored in the school member attribute of their entries.
+type educationConfig struct {
+	schoolBaseDN       string
+	schoolFilter       string
+	schoolObjectClass  string
+	schoolScope        int
+	schoolAttributeMap schoolAttributeMap
+	memberOfSchool     string
+
+	classObjectClass  string
+	classAttributeMap classAttributeMap
+
+	userObjectClass string
+	userPrimaryRole string
+}
+
+type schoolAttributeMap struct {
+	displayName  string
+	schoolNumber string
+	id           string
+}
+
+type classAttributeMap struct {
+	description string
+	externalID  string
+}
+
+func newEducationConfig(config config.LDAP) (educationConfig, error) {
+	if config.SchoolObjectClass == "" || config.SchoolNameAttribute == "" || config.SchoolNumberAttribute == "" ||
+		config.SchoolIDAttribute == "" || config.SchoolMemberAttribute == "" {
+		return educationConfig{}, errors.New("invalid school attribute mappings")
+	}
+	if config.ClassObjectClass == "" || config.ClassDescriptionAttribute == "" || config.ClassExternalIDAttribute == "" {
+		return educationConfig{}, errors.New("invalid class attribute mappings")
+	}
+	if config.EducationUserObjectClass == "" || config.EducationUserPrimaryRoleAttribute == "" {
+		return educationConfig{}, errors.New("invalid education user attribute mappings")
+	}
+
+	schoolScope, err := stringToScope(config.SchoolSearchScope)
+	if err != nil {
+		return educationConfig{}, fmt.Errorf("error configuring school scope: %w", err)
+	}
+
+	return educationConfig{
+		schoolBaseDN:      config.SchoolBaseDN,
+		schoolFilter:      config.SchoolFilter,
+		schoolObjectClass: config.SchoolObjectClass,
+		schoolScope:       schoolScope,
+		schoolAttributeMap: schoolAttributeMap{
+			displayName:  config.SchoolNameAttribute,
+			schoolNumber: config.SchoolNumberAttribute,
+			id:           config.SchoolIDAttribute,
+		},
+		memberOfSchool:   config.SchoolMemberAttribute,
+		classObjectClass: config.ClassObjectClass,
+		classAttributeMap: classAttributeMap{
+			description: config.ClassDescriptionAttribute,
+			externalID:  config.ClassExternalIDAttribute,
+		},
+		userObjectClass: config.EducationUserObjectClass,
+		userPrimaryRole: config.EducationUserPrimaryRoleAttribute,
+	}, nil
+}
+
+// CreateEducationSchool implements the EducationBackend interface for the LDAP backend.
+func (i *LDAP) CreateEducationSchool(ctx context.Context, school EducationSchool) (*EducationSchool, error) {
+	if !i.writeEnabled {
+		return nil, errReadOnly
+	}
+	sam := i.educationConfig.schoolAttributeMap
+	ar := ldap.AddRequest{
+		DN: fmt.Sprintf("%s=%s,%s", sam.displayName, *school.DisplayName, i.educationConfig.schoolBaseDN),
+	}
+	ar.Attribute(sam.displayName, []string{*school.DisplayName})
+	if school.SchoolNumber != nil && *school.SchoolNumber != "" {
+		ar.Attribute(sam.schoolNumber, []string{*school.SchoolNumber})
+	}
+
+	objectClasses := []string{"organizationalUnit", i.educationConfig.schoolObjectClass, "top"}
+	if !i.useServerUUID {
+		ar.Attribute("owncloudUUID", []string{uuid.Must(uuid.NewV4()).String()})
+		objectClasses = append(objectClasses, "owncloud")
+	}
+	ar.Attribute("objectClass", objectClasses)
+
+	if err := i.conn.Add(&ar); err != nil {
+		return nil, err
+	}
+
+	// Read	back school from LDAP to get the generated UUID
+	e, err := i.getEntryByDN(ar.DN, i.schoolAttributes())
+	if err != nil {
+		return nil, err
+	}
+	return i.createSchoolModelFromLDAP(e), nil
+}
+
+// DeleteEducationSchool implements the EducationBackend interface for the LDAP backend.
+func (i *LDAP) DeleteEducationSchool(ctx context.Context, nameOrID string) error {
+	if !i.writeEnabled {
+		return errReadOnly
+	}
+	e, err := i.getSchoolByNameOrID(nameOrID)
+	if err != nil {
+		return err
+	}
+	dr := ldap.DelRequest{DN: e.DN}
+	if err = i.conn.Del(&dr); err != nil {
+		return err
+	}
+
+	// Find all the users and classes of the school and remove the school from them
+	schoolID := e.GetEqualFoldAttributeValue(i.educationConfig.schoolAttributeMap.id)
+	memberFilter := fmt.Sprintf("(%s=%s)", i.educationConfig.memberOfSchool, ldap.EscapeFilter(schoolID))
+	users, err := i.getEducationUserEntries(memberFilter, false)
+	if err != nil {
+		return err
+	}
+	classes, err := i.getClassEntries(memberFilter, false, false)
+	if err != nil {
+		return err
+	}
+	for _, member := range append(users, classes...) {
+		i.logger.Debug().Str("school", e.DN).Str("member", member.DN).Msg("Cleaning up school membership")
+		if err := i.removeFromSchool(member, schoolID); err != nil {
+			// Errors when deleting the memberships are only logged as warnings but not returned
+			// to the user as we already successfully deleted the school itself
+			i.logger.Warn().Str("school", e.DN).Str("member", member.DN).Err(err).Msg("failed to remove school member")
+		}
+	}
+	return nil
+}
+
+// UpdateEducationSchool implements the EducationBackend interface for the LDAP backend. As the name is the naming
+// attribute of the school entry, renaming a school renames its entry.
+func (i *LDAP) UpdateEducationSchool(ctx context.Context, nameOrID string, school EducationSchool) (*EducationSchool, error) {
+	if !i.writeEnabled {
+		return nil, errReadOnly
+	}
+	e, err := i.getSchoolByNameOrID(nameOrID)
+	if err != nil {
+		return nil, err
+	}
+	sam := i.educationConfig.schoolAttributeMap
+	schoolID := e.GetEqualFoldAttributeValue(sam.id)
+
+	// Don't allow updates of the ID
+	if school.Id != nil && *school.Id != "" && *school.Id != schoolID {
+		return nil, errorcode.New(errorcode.NotAllowed, "changing the school id is not allowed")
+	}
+
+	if school.DisplayName != nil && *school.DisplayName != "" && *school.DisplayName != e.GetEqualFoldAttributeValue(sam.displayName) {
+		mdr := ldap.NewModifyDNRequest(e.DN, fmt.Sprintf("%s=%s", sam.displayName, *school.DisplayName), true, "")
+		if err := i.conn.ModifyDN(mdr); err != nil {
+			return nil, err
+		}
+		if e, err = i.getSchoolByID(schoolID); err != nil {
+			return nil, err
+		}
+	}
+
+	if school.SchoolNumber != nil && *school.SchoolNumber != "" && *school.SchoolNumber != e.GetEqualFoldAttributeValue(sam.schoolNumber) {
+		mr := ldap.ModifyRequest{DN: e.DN}
+		mr.Replace(sam.schoolNumber, []string{*school.SchoolNumber})
+		if err := i.conn.Modify(&mr); err != nil {
+			return nil, err
+		}
+		if e, err = i.getEntryByDN(e.DN, i.schoolAttributes()); err != nil {
+			return nil, err
+		}
+	}
+	return i.createSchoolModelFromLDAP(e), nil
+}
+
+// GetEducationSchool implements the EducationBackend interface for the LDAP backend.
+func (i *LDAP) GetEducationSchool(ctx context.Context, nameOrID string) (*EducationSchool, error) {
+	i.logger.Debug().Str("backend", "ldap").Msg("GetEducationSchool")
+	e, err := i.getSchoolByNameOrID(nameOrID)
+	if err != nil {
+		return nil, err
+	}
+	return i.createSchoolModelFromLDAP(e), nil
+}
+
+// GetEducationSchools implements the EducationBackend interface for the LDAP backend.
+func (i *LDAP) GetEducationSchools(ctx context.Context) ([]*EducationSchool, error) {
+	i.logger.Debug().Str("backend", "ldap").Msg("GetEducationSchools")
+	entries, err := i.getSchoolEntries("", false)
+	if err != nil {
+		return nil, err
+	}
+	schools := make([]*EducationSchool, 0, len(entries))
+	for _, e := range entries {
+		schools = append(schools, i.createSchoolModelFromLDAP(e))
+	}
+	return schools, nil
+}
+
+// GetEducationSchoolUsers implements the EducationBackend interface for the LDAP backend.
+func (i *LDAP) GetEducationSchoolUsers(ctx context.Context, nameOrID string) ([]*EducationUser, error) {
+	e, err := i.getSchoolByNameOrID(nameOrID)
+	if err != nil {
+		return nil, err
+	}
+	entries, err := i.getEducationUserEntries(i.schoolMemberFilter(e), false)
+	if err != nil {
+		return nil, err
+	}
+	users := make([]*EducationUser, 0, len(entries))
+	for _, ue := range entries {
+		users = append(users, i.createEducationUserModelFromLDAP(ue))
+	}
+	return users, nil
+}
+
+// AddUsersToEducationSchool implements the EducationBackend interface for the LDAP backend. Users which are not
+// education users yet get the education user objectClass.
+func (i *LDAP) AddUsersToEducationSchool(ctx context.Context, schoolID string, memberIDs []string) error {
+	if !i.writeEnabled {
+		return errReadOnly
+	}
+	if _, err := i.getSchoolByID(schoolID); err != nil {
+		return err
+	}
+	entries := make([]*ldap.Entry, 0, len(memberIDs))
+	for _, memberID := range memberIDs {
+		ue, err := i.getEducationUserEntry(fmt.Sprintf("(%s=%s)", i.userAttributeMap.id, ldap.EscapeFilter(memberID)))
+		if err != nil {
+			return err
+		}
+		entries = append(entries, ue)
+	}
+	for _, ue := range entries {
+		if err := i.addToSchool(ue, schoolID, i.educationConfig.userObjectClass); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// RemoveUserFromEducationSchool implements the EducationBackend interface for the LDAP backend.
+func (i *LDAP) RemoveUserFromEducationSchool(ctx context.Context, schoolID string, memberID string) error {
+	if !i.writeEnabled {
+		return errReadOnly
+	}
+	if _, err := i.getSchoolByID(schoolID); err != nil {
+		return err
+	}
+	ue, err := i.getEducationUserEntry(fmt.Sprintf("(%s=%s)", i.userAttributeMap.id, ldap.EscapeFilter(memberID)))
+	if err != nil {
+		return err
+	}
+	return i.removeFromSchool(ue, schoolID)
+}
+
+// GetEducationSchoolClasses implements the EducationBackend interface for the LDAP backend.
+func (i *LDAP) GetEducationSchoolClasses(ctx context.Context, nameOrID string) ([]*EducationClass, error) {
+	e, err := i.getSchoolByNameOrID(nameOrID)
+	if err != nil {
+		return nil, err
+	}
+	entries, err := i.getClassEntries(i.schoolMemberFilter(e), false, false)
+	if err != nil {
+		return nil, err
+	}
+	classes := make([]*EducationClass, 0, len(entries))
+	for _, ce := range entries {
+		classes = append(classes, i.createClassModelFromLDAP(ce))
+	}
+	return classes, nil
+}
+
+// AddClassesToEducationSchool implements the EducationBackend interface for the LDAP backend.
+func (i *LDAP) AddClassesToEducationSchool(ctx context.Context, schoolID string, classIDs []string) error {
+	if !i.writeEnabled {
+		return errReadOnly
+	}
+	if _, err := i.getSchoolByID(schoolID); err != nil {
+		return err
+	}
+	entries := make([]*ldap.Entry, 0, len(classIDs))
+	for _, classID := range classIDs {
+		ce, err := i.getClassByID(classID, false)
+		if err != nil {
+			return err
+		}
+		entries = append(entries, ce)
+	}
+	for _, ce := range entries {
+		if err := i.addToSchool(ce, schoolID, i.educationConfig.classObjectClass); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// RemoveClassFromEducationSchool implements the EducationBackend interface for the LDAP backend.
+func (i *LDAP) RemoveClassFromEducationSchool(ctx context.Context, schoolID string, classID string) error {
+	if !i.writeEnabled {
+		return errReadOnly
+	}
+	if _, err := i.getSchoolByID(schoolID); err != nil {
+		return err
+	}
+	ce, err := i.getClassByID(classID, false)
+	if err != nil {
+		return err
+	}
+	return i.removeFromSchool(ce, schoolID)
+}
+
+// CreateEducationClass implements the EducationBackend interface for the LDAP backend. Like groups, classes without
+// members have the empty DN as their single member.
+func (i *LDAP) CreateEducationClass(ctx context.Context, class EducationClass) (*EducationClass, error) {
+	if !i.writeEnabled {
+		return nil, errReadOnly
+	}
+	cam := i.educationConfig.classAttributeMap
+	ar := ldap.AddRequest{
+		DN: fmt.Sprintf("cn=%s,%s", *class.DisplayName, i.groupBaseDN),
+	}
+	ar.Attribute(i.groupAttributeMap.name, []string{*class.DisplayName})
+	ar.Attribute(i.groupAttributeMap.member, []string{""})
+	if class.Description != nil && *class.Description != "" {
+		ar.Attribute(cam.description, []string{*class.Description})
+	}
+	if class.ExternalId != nil && *class.ExternalId != "" {
+		ar.Attribute(cam.externalID, []string{*class.ExternalId})
+	}
+
+	objectClasses := []string{"groupOfNames", i.educationConfig.classObjectClass, "top"}
+	if !i.useServerUUID {
+		ar.Attribute("owncloudUUID", []string{uuid.Must(uuid.NewV4()).String()})
+		objectClasses = append(objectClasses, "owncloud")
+	}
+	ar.Attribute("objectClass", objectClasses)
+
+	if err := i.conn.Add(&ar); err != nil {
+		return nil, err
+	}
+
+	// Read	back class from LDAP to get the generated UUID
+	e, err := i.getEntryByDN(ar.DN, i.classAttributes(false))
+	if err != nil {
+		return nil, err
+	}
+	return i.createClassModelFromLDAP(e), nil
+}
+
+// DeleteEducationClass implements the EducationBackend interface for the LDAP backend.
+func (i *LDAP) DeleteEducationClass(ctx context.Context, id string) error {
+	if !i.writeEnabled {
+		return errReadOnly
+	}
+	e, err := i.getClassByID(id, false)
+	if err != nil {
+		return err
+	}
+	dr := ldap.DelRequest{DN: e.DN}
+	return i.conn.Del(&dr)
+}
+
+// UpdateEducationClass implements the EducationBackend interface for the LDAP backend.
+func (i *LDAP) UpdateEducationClass(ctx context.Context, id string, class EducationClass) (*EducationClass, error) {
+	if !i.writeEnabled {
+		return nil, errReadOnly
+	}
+	e, err := i.getClassByID(id, false)
+	if err != nil {
+		return nil, err
+	}
+
+	// Don't allow updates of the ID
+	if class.Id != nil && *class.Id != "" && *class.Id != e.GetEqualFoldAttributeValue(i.groupAttributeMap.id) {
+		return nil, errorcode.New(errorcode.NotAllowed, "changing the class id is not allowed")
+	}
+	// Like the group name the class name is the naming attribute of the entry
+	if class.DisplayName != nil && *class.DisplayName != "" && *class.DisplayName != e.GetEqualFoldAttributeValue(i.groupAttributeMap.name) {
+		return nil, errorcode.New(errorcode.NotSupported, "changing the class name is currently not supported")
+	}
+
+	cam := i.educationConfig.classAttributeMap
+	mr := ldap.ModifyRequest{DN: e.DN}
+	if class.Description != nil && *class.Description != "" && *class.Description != e.GetEqualFoldAttributeValue(cam.description) {
+		mr.Replace(cam.description, []string{*class.Description})
+	}
+	if class.ExternalId != nil && *class.ExternalId != "" && *class.ExternalId != e.GetEqualFoldAttributeValue(cam.externalID) {
+		mr.Replace(cam.externalID, []string{*class.ExternalId})
+	}
+	if len(mr.Changes) > 0 {
+		if err := i.conn.Modify(&mr); err != nil {
+			return nil, err
+		}
+		if e, err = i.getEntryByDN(e.DN, i.classAttributes(false)); err != nil {
+			return nil, err
+		}
+	}
+	return i.createClassModelFromLDAP(e), nil
+}
+
+// GetEducationClass implements the EducationBackend interface for the LDAP backend.
+func (i *LDAP) GetEducationClass(ctx context.Context, nameOrID string) (*EducationClass, error) {
+	i.logger.Debug().Str("backend", "ldap").Msg("GetEducationClass")
+	nameOrID = ldap.EscapeFilter(nameOrID)
+	filter := fmt.Sprintf("(|(%s=%s)(%s=%s))", i.groupAttributeMap.name, nameOrID, i.groupAttributeMap.id, nameOrID)
+	e, err := i.getClassByFilter(filter, false)
+	if err != nil {
+		return nil, err
+	}
+	return i.createClassModelFromLDAP(e), nil
+}
+
+// GetEducationClasses implements the EducationBackend interface for the LDAP backend.
+func (i *LDAP) GetEducationClasses(ctx context.Context) ([]*EducationClass, error) {
+	i.logger.Debug().Str("backend", "ldap").Msg("GetEducationClasses")
+	entries, err := i.getClassEntries("", false, false)
+	if err != nil {
+		return nil, err
+	}
+	classes := make([]*EducationClass, 0, len(entries))
+	for _, e := range entries {
+		classes = append(classes, i.createClassModelFromLDAP(e))
+	}
+	return classes, nil
+}
+
+// GetEducationClassMembers implements the EducationBackend interface for the LDAP backend.
+func (i *LDAP) GetEducationClassMembers(ctx context.Context, id string) ([]*EducationUser, error) {
+	e, err := i.getClassByID(id, true)
+	if err != nil {
+		return nil, err
+	}
+
+	result := []*EducationUser{}
+	for _, memberDN := range e.GetEqualFoldAttributeValues(i.groupAttributeMap.member) {
+		if memberDN == "" {
+			continue
+		}
+		ue, err := i.getEntryByDN(memberDN, i.educationUserAttributes())
+		if err != nil {
+			// Ignore errors when reading a specific member fails, just log them and continue
+			i.logger.Warn().Err(err).Str("member", memberDN).Msg("error reading class member")
+			continue
+		}
+		result = append(result, i.createEducationUserModelFromLDAP(ue))
+	}
+	return result, nil
+}
+
+// AddUsersToEducationClass implements the EducationBackend interface for the LDAP backend. The users become members
+// of the group of the class.
+func (i *LDAP) AddUsersToEducationClass(ctx context.Context, classID string, memberIDs []string) error {
+	if !i.writeEnabled {
+		return errReadOnly
+	}
+	if _, err := i.getClassByID(classID, false); err != nil {
+		return err
+	}
+	return i.AddMembersToGroup(ctx, classID, memberIDs)
+}
+
+// RemoveUserFromEducationClass implements the EducationBackend interface for the LDAP backend.
+func (i *LDAP) RemoveUserFromEducationClass(ctx context.Context, classID string, memberID string) error {
+	if !i.writeEnabled {
+		return errReadOnly
+	}
+	if _, err := i.getClassByID(classID, false); err != nil {
+		return err
+	}
+	return i.RemoveMemberFromGroup(ctx, classID, memberID)
+}
+
+// CreateEducationUser implements the EducationBackend interface for the LDAP backend. The user entry is created like
+// the entries of CreateUser, with the additional education user objectClass.
+func (i *LDAP) CreateEducationUser(ctx context.Context, user EducationUser) (*EducationUser, error) {
+	if !i.writeEnabled {
+		return nil, errReadOnly
+	}
+	if err := checkPassword(educationUserToUser(user)); err != nil {
+		return nil, err
+	}
+	ar := i.userToAddRequest(educationUserToUser(user), i.educationConfig.userObjectClass)
+	if user.PrimaryRole != nil && *user.PrimaryRole != "" {
+		ar.Attribute(i.educationConfig.userPrimaryRole, []string{*user.PrimaryRole})
+	}
+
+	if err := i.conn.Add(ar); err != nil {
+		return nil, passwordPolicyError(err, educationUserToUser(user))
+	}
+
+	// Read	back user from LDAP to get the generated UUID
+	e, err := i.getEntryByDN(ar.DN, i.educationUserAttributes())
+	if err != nil {
+		return nil, err
+	}
+	return i.createEducationUserModelFromLDAP(e), nil
+}
+
+// DeleteEducationUser implements the EducationBackend interface for the LDAP backend.
+func (i *LDAP) DeleteEducationUser(ctx context.Context, nameOrID string) error {
+	if !i.writeEnabled {
+		return errReadOnly
+	}
+	e, err := i.getEducationUserByNameOrID(nameOrID)
+	if err != nil {
+		return err
+	}
+	return i.DeleteUser(ctx, e.GetEqualFoldAttributeValue(i.userAttributeMap.id))
+}
+
+// UpdateEducationUser implements the EducationBackend interface for the LDAP backend.
+func (i *LDAP) UpdateEducationUser(ctx context.Context, nameOrID string, user EducationUser) (*EducationUser, error) {
+	if !i.writeEnabled {
+		return nil, errReadOnly
+	}
+	e, err := i.getEducationUserByNameOrID(nameOrID)
+	if err != nil {
+		return nil, err
+	}
+	mr, err := i.userModifyRequest(e, educationUserToUser(user))
+	if err != nil {
+		return nil, err
+	}
+	if user.PrimaryRole != nil && *user.PrimaryRole != "" && *user.PrimaryRole != e.GetEqualFoldAttributeValue(i.educationConfig.userPrimaryRole) {
+		mr.Replace(i.educationConfig.userPrimaryRole, []string{*user.PrimaryRole})
+	}
+
+	if err := i.conn.Modify(mr); err != nil {
+		return nil, passwordPolicyError(err, educationUserToUser(user))
+	}
+
+	e, err = i.getEntryByDN(e.DN, i.educationUserAttributes())
+	if err != nil {
+		return nil, err
+	}
+	return i.createEducationUserModelFromLDAP(e), nil
+}
+
+// GetEducationUser implements the EducationBackend interface for the LDAP backend.
+func (i *LDAP) GetEducationUser(ctx context.Context, nameOrID string) (*EducationUser, error) {
+	i.logger.Debug().Str("backend", "ldap").Msg("GetEducationUser")
+	e, err := i.getEducationUserByNameOrID(nameOrID)
+	if err != nil {
+		return nil, err
+	}
+	return i.createEducationUserModelFromLDAP(e), nil
+}
+
+// GetEducationUsers implements the EducationBackend interface for the LDAP backend.
+func (i *LDAP) GetEducationUsers(ctx context.Context) ([]*EducationUser, error) {
+	i.logger.Debug().Str("backend", "ldap").Msg("GetEducationUsers")
+	entries, err := i.getEducationUserEntries(fmt.Sprintf("(objectClass=%s)", i.educationConfig.userObjectClass), false)
+	if err != nil {
+		return nil, err
+	}
+	users := make([]*EducationUser, 0, len(entries))
+	for _, e := range entries {
+		users = append(users, i.createEducationUserModelFromLDAP(e))
+	}
+	return users, nil
+}
+
+func (i *LDAP) schoolAttributes() []string {
+	sam := i.educationConfig.schoolAttributeMap
+	return []string{sam.displayName, sam.schoolNumber, sam.id}
+}
+
+func (i *LDAP) classAttributes(requestMembers bool) []string {
+	cam := i.educationConfig.classAttributeMap
+	attrs := []string{
+		i.groupAttributeMap.name,
+		i.groupAttributeMap.id,
+		cam.description,
+		cam.externalID,
+		i.educationConfig.memberOfSchool,
+	}
+	if requestMembers {
+		attrs = append(attrs, i.groupAttributeMap.member)
+	}
+	return attrs
+}
+
+func (i *LDAP) educationUserAttributes() []string {
+	return append(i.userAttributes(),
+		i.educationConfig.userPrimaryRole,
+		i.educationConfig.memberOfSchool,
+		"objectClass",
+	)
+}
+
+func (i *LDAP) getSchoolByID(id string) (*ldap.Entry, error) {
+	return i.getSchoolByFilter(fmt.Sprintf("(%s=%s)", i.educationConfig.schoolAttributeMap.id, ldap.EscapeFilter(id)))
+}
+
+func (i *LDAP) getSchoolByNameOrID(nameOrID string) (*ldap.Entry, error) {
+	sam := i.educationConfig.schoolAttributeMap
+	nameOrID = ldap.EscapeFilter(nameOrID)
+	return i.getSchoolByFilter(fmt.Sprintf("(|(%s=%s)(%s=%s))", sam.displayName, nameOrID, sam.id, nameOrID))
+}
+
+func (i *LDAP) getSchoolByFilter(filter string) (*ldap.Entry, error) {
+	entries, err := i.getSchoolEntries(filter, true)
+	if err != nil {
+		return nil, err
+	}
+	if len(entries) == 0 {
+		return nil, errNotFound
+	}
+	return entries[0], nil
+}
+
+// getSchoolEntries searches for the schools matching the filter, combined with the configured school filter and
+// objectClass.
+func (i *LDAP) getSchoolEntries(filter string, single bool) ([]*ldap.Entry, error) {
+	ec := i.educationConfig
+	return i.searchEntries(
+		ec.schoolBaseDN, ec.schoolScope,
+		fmt.Sprintf("(&%s(objectClass=%s)%s)", ec.schoolFilter, ec.schoolObjectClass, filter),
+		i.schoolAttributes(), single,
+	)
+}
+
+func (i *LDAP) getClassByID(id string, requestMembers bool) (*ldap.Entry, error) {
+	return i.getClassByFilter(fmt.Sprintf("(%s=%s)", i.groupAttributeMap.id, ldap.EscapeFilter(id)), requestMembers)
+}
+
+func (i *LDAP) getClassByFilter(filter string, requestMembers bool) (*ldap.Entry, error) {
+	entries, err := i.getClassEntries(filter, requestMembers, true)
+	if err != nil {
+		return nil, err
+	}
+	if len(entries) == 0 {
+		return nil, errNotFound
+	}
+	return entries[0], nil
+}
+
+// getClassEntries searches for the classes matching the filter. Classes are the groups with the class objectClass.
+func (i *LDAP) getClassEntries(filter string, requestMembers, single bool) ([]*ldap.Entry, error) {
+	return i.searchEntries(
+		i.groupBaseDN, i.groupScope,
+		fmt.Sprintf("(&%s(objectClass=%s)(objectClass=%s)%s)", i.groupFilter, i.groupObjectClass, i.educationConfig.classObjectClass, filter),
+		i.classAttributes(requestMembers), single,
+	)
+}
+
+func (i *LDAP) getEducationUserByNameOrID(nameOrID string) (*ldap.Entry, error) {
+	nameOrID = ldap.EscapeFilter(nameOrID)
+	return i.getEducationUserEntry(fmt.Sprintf("(objectClass=%s)(|(%s=%s)(%s=%s))",
+		i.educationConfig.userObjectClass,
+		i.userAttributeMap.userName, nameOrID,
+		i.userAttributeMap.id, nameOrID,
+	))
+}
+
+func (i *LDAP) getEducationUserEntry(filter string) (*ldap.Entry, error) {
+	entries, err := i.getEducationUserEntries(filter, true)
+	if err != nil {
+		return nil, err
+	}
+	if len(entries) == 0 {
+		return nil, errNotFound
+	}
+	return entries[0], nil
+}
+
+// getEducationUserEntries searches for the users matching the filter and reads their education attributes. Users
+// missing the education user objectClass are only excluded if the filter says so.
+func (i *LDAP) getEducationUserEntries(filter string, single bool) ([]*ldap.Entry, error) {
+	return i.searchEntries(
+		i.userBaseDN, i.userScope,
+		fmt.Sprintf("(&%s(objectClass=%s)%s)", i.userFilter, i.userObjectClass, filter),
+		i.educationUserAttributes(), single,
+	)
+}
+
+// searchEntries searches below the base DN for the entries matching the filter, if single is true at most one entry
+// is returned.
+func (i *LDAP) searchEntries(baseDN string, scope int, filter string, attrs []string, single bool) ([]*ldap.Entry, error) {
+	sizelimit := 0
+	if single {
+		sizelimit = 1
+	}
+	searchRequest := ldap.NewSearchRequest(
+		baseDN, scope, ldap.NeverDerefAliases, sizelimit, 0, false,
+		filter,
+		attrs,
+		nil,
+	)
+	i.logger.Debug().Str("backend", "ldap").Str("base", baseDN).Str("filter", filter).Msg("ldap search")
+	res, err := i.conn.Search(searchRequest)
+	if err != nil {
+		var errmsg string
+		if lerr, ok := err.(*ldap.Error); ok {
+			if lerr.ResultCode == ldap.LDAPResultSizeLimitExceeded {
+				errmsg = fmt.Sprintf("too many results searching for '%s'", filter)
+				i.logger.Debug().Str("backend", "ldap").Err(lerr).Msg(errmsg)
+			}
+		}
+		return nil, errorcode.New(errorcode.ItemNotFound, errmsg)
+	}
+	return res.Entries, nil
+}
+
+// schoolMemberFilter returns the LDAP filter matching the users and classes of the school entry.
+func (i *LDAP) schoolMemberFilter(school *ldap.Entry) string {
+	schoolID := school.GetEqualFoldAttributeValue(i.educationConfig.schoolAttributeMap.id)
+	return fmt.Sprintf("(%s=%s)", i.educationConfig.memberOfSchool, ldap.EscapeFilter(schoolID))
+}
+
+// addToSchool adds the school to the school memberships of the entry. The objectClass providing the school member
+// attribute is added to entries missing it.
+func (i *LDAP) addToSchool(e *ldap.Entry, schoolID string, objectClass string) error {
+	for _, id := range e.GetEqualFoldAttributeValues(i.educationConfig.memberOfSchool) {
+		if id == schoolID {
+			i.logger.Debug().Str("dn", e.DN).Str("school", schoolID).Msg("Member already present in school. Skipping")
+			return nil
+		}
+	}
+
+	mr := ldap.ModifyRequest{DN: e.DN}
+	if !hasObjectClass(e, objectClass) {
+		mr.Add("objectClass", []string{objectClass})
+	}
+	mr.Add(i.educationConfig.memberOfSchool, []string{schoolID})
+	return i.conn.Modify(&mr)
+}
+
+// removeFromSchool removes the school from the school memberships of the entry.
+func (i *LDAP) removeFromSchool(e *ldap.Entry, schoolID string) error {
+	for _, id := range e.GetEqualFoldAttributeValues(i.educationConfig.memberOfSchool) {
+		if id == schoolID {
+			mr := ldap.ModifyRequest{DN: e.DN}
+			mr.Delete(i.educationConfig.memberOfSchool, []string{schoolID})
+			return i.conn.Modify(&mr)
+		}
+	}
+	i.logger.Debug().Str("backend", "ldap").Str("dn", e.DN).Str("school", schoolID).
+		Msg("The target is not a member of the school")
+	return nil
+}
+
+func hasObjectClass(e *ldap.Entry, objectClass string) bool {
+	for _, oc := range e.GetEqualFoldAttributeValues("objectClass") {
+		if strings.EqualFold(oc, objectClass) {
+			return true
+		}
+	}
+	return false
+}
+
+// educationUserToUser returns the libregraph.User holding the user properties of an education user.
+func educationUserToUser(user EducationUser) libregraph.User {
+	return libregraph.User{
+		Id:                       user.Id,
+		DisplayName:              user.DisplayName,
+		Mail:                     user.Mail,
+		OnPremisesSamAccountName: user.OnPremisesSamAccountName,
+		Surname:                  user.Surname,
+		PasswordProfile:          user.PasswordProfile,
+	}
+}
+
+func (i *LDAP) createSchoolModelFromLDAP(e *ldap.Entry) *EducationSchool {
+	sam := i.educationConfig.schoolAttributeMap
+	return &EducationSchool{
+		Id:           pointerOrNil(e.GetEqualFoldAttributeValue(sam.id)),
+		DisplayName:  pointerOrNil(e.GetEqualFoldAttributeValue(sam.displayName)),
+		SchoolNumber: pointerOrNil(e.GetEqualFoldAttributeValue(sam.schoolNumber)),
+	}
+}
+
+func (i *LDAP) createClassModelFromLDAP(e *ldap.Entry) *EducationClass {
+	cam := i.educationConfig.classAttributeMap
+	return &EducationClass{
+		Id:          pointerOrNil(e.GetEqualFoldAttributeValue(i.groupAttributeMap.id)),
+		DisplayName: pointerOrNil(e.GetEqualFoldAttributeValue(i.groupAttributeMap.name)),
+		Description: pointerOrNil(e.GetEqualFoldAttributeValue(cam.description)),
+		ExternalId:  pointerOrNil(e.GetEqualFoldAttributeValue(cam.externalID)),
+	}
+}
+
+func (i *LDAP) createEducationUserModelFromLDAP(e *ldap.Entry) *EducationUser {
+	return &EducationUser{
+		Id:                       pointerOrNil(e.GetEqualFoldAttributeValue(i.userAttributeMap.id)),
+		DisplayName:              pointerOrNil(e.GetEqualFoldAttributeValue(i.userAttributeMap.displayName)),
+		Mail:                     pointerOrNil(e.GetEqualFoldAttributeValue(i.userAttributeMap.mail)),
+		OnPremisesSamAccountName: pointerOrNil(e.GetEqualFoldAttributeValue(i.userAttributeMap.userName)),
+		PrimaryRole:              pointerOrNil(e.GetEqualFoldAttributeValue(i.educationConfig.userPrimaryRole)),
+	}
+}
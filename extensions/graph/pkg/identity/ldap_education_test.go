@@ -0,0 +1,178 @@
+package identity
+
+import (
+	"context"
+	"testing"
+
+	"github.com/go-ldap/ldap/v3"
+	libregraph "github.com/owncloud/libre-graph-api-go"
+	"github.com/owncloud/ocis/extensions/graph/pkg/config"
+)
+
+var eduConfig = func() config.LDAP {
+	c := lconfig
+	c.WriteEnabled = true
+	c.UseServerUUID = true
+	c.EducationResourcesEnabled = true
+	c.SchoolBaseDN = "ou=schools,dc=test"
+	c.SchoolSearchScope = "sub"
+	c.SchoolObjectClass = "ocEducationSchool"
+	c.SchoolNameAttribute = "ou"
+	c.SchoolNumberAttribute = "ocEducationSchoolNumber"
+	c.SchoolIDAttribute = "entryUUID"
+	c.SchoolMemberAttribute = "ocMemberOfSchool"
+	c.ClassObjectClass = "ocEducationClass"
+	c.ClassDescriptionAttribute = "description"
+	c.ClassExternalIDAttribute = "ocEducationExternalId"
+	c.EducationUserObjectClass = "ocEducationUser"
+	c.EducationUserPrimaryRoleAttribute = "ocEducationUserPrimaryRole"
+	return c
+}()
+
+var schoolEntry = ldap.NewEntry("ou=Springfield,ou=schools,dc=test",
+	map[string][]string{
+		"ou":                      {"Springfield"},
+		"ocEducationSchoolNumber": {"0042"},
+		"entryuuid":               {"school-id"},
+	})
+
+// getMockedEducationBackend returns a backend serving the school entry for searches below the school base DN and
+// the given entry for all other searches.
+func getMockedEducationBackend(t *testing.T, e *ldap.Entry, af *addFunc, mf *modifyFunc) *LDAP {
+	var sf searchFunc = func(sr *ldap.SearchRequest) (*ldap.SearchResult, error) {
+		if sr.BaseDN == eduConfig.SchoolBaseDN || sr.BaseDN == schoolEntry.DN {
+			return &ldap.SearchResult{Entries: []*ldap.Entry{schoolEntry}}, nil
+		}
+		return &ldap.SearchResult{Entries: []*ldap.Entry{e}}, nil
+	}
+	b, err := NewLDAPBackend(ldapMock{SearchFunc: &sf, AddFunc: af, ModifyFunc: mf}, eduConfig, &logger)
+	if err != nil {
+		t.Fatalf("Expected success, got '%s'", err.Error())
+	}
+	return b
+}
+
+func TestNewLDAPBackendEducation(t *testing.T) {
+	l := ldapMock{}
+
+	tc := eduConfig
+	tc.SchoolSearchScope = ""
+	if _, err := NewLDAPBackend(l, tc, &logger); err == nil {
+		t.Error("Should fail with invalid school search scope")
+	}
+
+	tc = eduConfig
+	tc.ClassObjectClass = ""
+	if _, err := NewLDAPBackend(l, tc, &logger); err == nil {
+		t.Error("Should fail with incomplete class config")
+	}
+
+	// the education config is only validated if the education resources are enabled
+	tc.EducationResourcesEnabled = false
+	if _, err := NewLDAPBackend(l, tc, &logger); err != nil {
+		t.Errorf("Expected success, got '%s'", err.Error())
+	}
+}
+
+func TestCreateEducationSchool(t *testing.T) {
+	var added *ldap.AddRequest
+	var af addFunc = func(ar *ldap.AddRequest) error {
+		added = ar
+		return nil
+	}
+	b := getMockedEducationBackend(t, schoolEntry, &af, nil)
+
+	school, err := b.CreateEducationSchool(context.Background(), EducationSchool{
+		DisplayName:  libregraph.PtrString("Springfield"),
+		SchoolNumber: libregraph.PtrString("0042"),
+	})
+	if err != nil {
+		t.Fatalf("Expected success, got '%s'", err.Error())
+	}
+	if added.DN != "ou=Springfield,ou=schools,dc=test" {
+		t.Errorf("Unexpected DN of the school entry '%s'", added.DN)
+	}
+	if !hasObjectClass(ldap.NewEntry(added.DN, attributeMap(added.Attributes)), "ocEducationSchool") {
+		t.Error("Expected the school objectClass")
+	}
+	if *school.Id != "school-id" || *school.SchoolNumber != "0042" {
+		t.Errorf("Unexpected school %+v", school)
+	}
+}
+
+func TestAddUsersToEducationSchool(t *testing.T) {
+	var modified []*ldap.ModifyRequest
+	var mf modifyFunc = func(mr *ldap.ModifyRequest) error {
+		modified = append(modified, mr)
+		return nil
+	}
+	b := getMockedEducationBackend(t, userEntry, nil, &mf)
+
+	if err := b.AddUsersToEducationSchool(context.Background(), "school-id", []string{"abcd-defg"}); err != nil {
+		t.Fatalf("Expected success, got '%s'", err.Error())
+	}
+	if len(modified) != 1 || len(modified[0].Changes) != 2 {
+		t.Fatalf("Expected a single modification adding the objectClass and the school")
+	}
+	if c := modified[0].Changes[1]; c.Operation != ldap.AddAttribute || c.Modification.Type != "ocMemberOfSchool" ||
+		c.Modification.Vals[0] != "school-id" {
+		t.Errorf("Unexpected modification %+v", c)
+	}
+
+	// adding a member of the school again doesn't change it
+	member := ldap.NewEntry(userEntry.DN, map[string][]string{
+		"entryuuid":        {"abcd-defg"},
+		"objectClass":      {"inetOrgPerson", "ocEducationUser"},
+		"ocMemberOfSchool": {"school-id"},
+	})
+	modified = nil
+	b = getMockedEducationBackend(t, member, nil, &mf)
+	if err := b.AddUsersToEducationSchool(context.Background(), "school-id", []string{"abcd-defg"}); err != nil {
+		t.Fatalf("Expected success, got '%s'", err.Error())
+	}
+	if len(modified) != 0 {
+		t.Errorf("Expected no modifications, got %d", len(modified))
+	}
+}
+
+func TestCreateEducationUser(t *testing.T) {
+	var added *ldap.AddRequest
+	var af addFunc = func(ar *ldap.AddRequest) error {
+		added = ar
+		return nil
+	}
+	b := getMockedEducationBackend(t, userEntry, &af, nil)
+
+	_, err := b.CreateEducationUser(context.Background(), EducationUser{
+		DisplayName:              libregraph.PtrString("Bart"),
+		Mail:                     libregraph.PtrString("bart@example.org"),
+		OnPremisesSamAccountName: libregraph.PtrString("bart"),
+		PrimaryRole:              libregraph.PtrString(EducationRoleStudent),
+	})
+	if err != nil {
+		t.Fatalf("Expected success, got '%s'", err.Error())
+	}
+	e := ldap.NewEntry(added.DN, attributeMap(added.Attributes))
+	if !hasObjectClass(e, "inetOrgPerson") || !hasObjectClass(e, "ocEducationUser") {
+		t.Errorf("Unexpected objectClasses %v", e.GetAttributeValues("objectClass"))
+	}
+	if e.GetAttributeValue("ocEducationUserPrimaryRole") != EducationRoleStudent {
+		t.Errorf("Expected the primary role to be set")
+	}
+}
+
+func TestUpdateEducationClass(t *testing.T) {
+	b := getMockedEducationBackend(t, groupEntry, nil, nil)
+	_, err := b.UpdateEducationClass(context.Background(), "abcd-defg", EducationClass{DisplayName: libregraph.PtrString("renamed")})
+	if err == nil || err.Error() != "notSupported" {
+		t.Errorf("Expected 'notSupported' got '%v'", err)
+	}
+}
+
+func attributeMap(attrs []ldap.Attribute) map[string][]string {
+	m := make(map[string][]string, len(attrs))
+	for _, a := range attrs {
+		m[a.Type] = a.Vals
+	}
+	return m
+}
@@ -0,0 +1,150 @@
+package identity
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/go-ldap/ldap/v3"
+	ldapdn "github.com/libregraph/idm/pkg/ldapdn"
+	libregraph "github.com/owncloud/libre-graph-api-go"
+)
+
+// GetUserTransitiveGroups implements the Backend Interface for the LDAP Backend. Without group nesting it returns
+// the same groups as GetUserGroups.
+func (i *LDAP) GetUserTransitiveGroups(ctx context.Context, nameOrID string) ([]*libregraph.Group, error) {
+	i.logger.Debug().Str("backend", "ldap").Msg("GetUserTransitiveGroups")
+	e, err := i.getLDAPUserByNameOrID(nameOrID)
+	if err != nil {
+		return nil, err
+	}
+	groupEntries, err := i.getTransitiveGroupEntries(e.DN)
+	if err != nil {
+		return nil, err
+	}
+	groups := make([]*libregraph.Group, 0, len(groupEntries))
+	for _, ge := range groupEntries {
+		groups = append(groups, i.createGroupModelFromLDAP(ge))
+	}
+	return groups, nil
+}
+
+// GetGroupTransitiveMembers implements the Backend Interface for the LDAP Backend. Without group nesting it returns
+// the same users as GetGroupMembers.
+func (i *LDAP) GetGroupTransitiveMembers(ctx context.Context, groupID string) ([]*libregraph.User, error) {
+	i.logger.Debug().Str("backend", "ldap").Msg("GetGroupTransitiveMembers")
+	e, err := i.getLDAPGroupByNameOrID(groupID, true)
+	if err != nil {
+		return nil, err
+	}
+	userEntries, err := i.getTransitiveMemberEntries(e)
+	if err != nil {
+		return nil, err
+	}
+	users := make([]*libregraph.User, 0, len(userEntries))
+	for _, ue := range userEntries {
+		users = append(users, i.createUserModelFromLDAP(ue))
+	}
+	return users, nil
+}
+
+// getTransitiveGroupEntries returns the groups the entry with the given DN is a direct or nested member of. Every
+// level of nesting is resolved by a single search for the groups having one of the groups of the previous level as
+// member. Groups which were found before are skipped, which stops the resolution of cyclic memberships.
+func (i *LDAP) getTransitiveGroupEntries(dn string) ([]*ldap.Entry, error) {
+	var result []*ldap.Entry
+	seen := map[string]bool{normalizeDN(dn): true}
+	level := []string{dn}
+	for depth := 0; len(level) > 0; depth++ {
+		if depth == i.groupNestingDepth {
+			if i.groupNestingDepth > 1 {
+				i.logger.Warn().Str("backend", "ldap").Str("dn", dn).Int("depth", depth).
+					Msg("Maximum group nesting depth reached, ignoring deeper nested groups")
+			}
+			break
+		}
+		entries, err := i.getLDAPGroupsByFilter(i.memberFilter(level), false, false)
+		if err != nil {
+			return nil, err
+		}
+		level = nil
+		for _, e := range entries {
+			if n := normalizeDN(e.DN); !seen[n] {
+				seen[n] = true
+				result = append(result, e)
+				level = append(level, e.DN)
+			}
+		}
+	}
+	return result, nil
+}
+
+// getTransitiveMemberEntries returns the users which are members of the given group or of the groups nested into
+// it. Member entries which are groups are resolved up to the configured nesting depth, every group and user is only
+// visited once. The members of a level are read with one search for the users and one for the groups, in batches of
+// dnBatchSize members.
+func (i *LDAP) getTransitiveMemberEntries(group *ldap.Entry) ([]*ldap.Entry, error) {
+	var result []*ldap.Entry
+	seen := map[string]bool{normalizeDN(group.DN): true}
+	level := []*ldap.Entry{group}
+	for depth := 0; len(level) > 0; depth++ {
+		if depth == i.groupNestingDepth {
+			if i.groupNestingDepth > 1 {
+				i.logger.Warn().Str("backend", "ldap").Str("group", group.DN).Int("depth", depth).
+					Msg("Maximum group nesting depth reached, ignoring the members of deeper nested groups")
+			}
+			break
+		}
+		var memberDNs []string
+		for _, g := range level {
+			for _, memberDN := range g.GetEqualFoldAttributeValues(i.groupAttributeMap.member) {
+				if memberDN == "" {
+					continue
+				}
+				if n := normalizeDN(memberDN); !seen[n] {
+					seen[n] = true
+					memberDNs = append(memberDNs, memberDN)
+				}
+			}
+		}
+		if len(memberDNs) == 0 {
+			break
+		}
+
+		users, err := i.getUsersByDNs(memberDNs)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, users...)
+		if level, err = i.getEntriesByDNs(memberDNs, i.groupBaseDN, i.groupScope,
+			fmt.Sprintf("%s(objectClass=%s)", i.groupFilter, i.groupObjectClass),
+			[]string{i.groupAttributeMap.name, i.groupAttributeMap.id, i.groupAttributeMap.member}); err != nil {
+			return nil, err
+		}
+	}
+	return result, nil
+}
+
+// memberFilter returns a filter matching the groups having one of the given DNs as member.
+func (i *LDAP) memberFilter(dns []string) string {
+	if len(dns) == 1 {
+		return fmt.Sprintf("(%s=%s)", i.groupAttributeMap.member, ldap.EscapeFilter(dns[0]))
+	}
+	var b strings.Builder
+	b.WriteString("(|")
+	for _, dn := range dns {
+		fmt.Fprintf(&b, "(%s=%s)", i.groupAttributeMap.member, ldap.EscapeFilter(dn))
+	}
+	b.WriteString(")")
+	return b.String()
+}
+
+// normalizeDN returns the normalized form of a DN to compare it to other DNs, DNs which can't be parsed are returned
+// as they are.
+func normalizeDN(dn string) string {
+	n, err := ldapdn.ParseNormalize(dn)
+	if err != nil {
+		return dn
+	}
+	return n
+}
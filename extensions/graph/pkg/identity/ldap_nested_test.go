@@ -0,0 +1,186 @@
+package identity
+
+import (
+	"context"
+	"regexp"
+	"sort"
+	"testing"
+
+	"github.com/go-ldap/ldap/v3"
+	"github.com/owncloud/ocis/extensions/graph/pkg/config"
+)
+
+var nestedConfig = func() config.LDAP {
+	c := lconfig
+	c.UserObjectClass = "inetOrgPerson"
+	c.GroupObjectClass = "groupOfNames"
+	c.GroupNestingEnabled = true
+	c.GroupNestingMaxDepth = 10
+	return c
+}()
+
+// nestedEntries contain a cycle: "physics" is nested into "science", which is nested into "academia", which in turn
+// is nested into "physics".
+var nestedEntries = map[string]*ldap.Entry{
+	"uid=einstein": ldap.NewEntry("uid=einstein", map[string][]string{
+		"objectClass": {"inetOrgPerson"},
+		"uid":         {"einstein"},
+		"entryuuid":   {"einstein-id"},
+	}),
+	"uid=curie": ldap.NewEntry("uid=curie", map[string][]string{
+		"objectClass": {"inetOrgPerson"},
+		"uid":         {"curie"},
+		"entryuuid":   {"curie-id"},
+	}),
+	"cn=physics": ldap.NewEntry("cn=physics", map[string][]string{
+		"objectClass": {"groupOfNames"},
+		"cn":          {"physics"},
+		"entryuuid":   {"physics-id"},
+		"member":      {"uid=einstein", "cn=academia"},
+	}),
+	"cn=science": ldap.NewEntry("cn=science", map[string][]string{
+		"objectClass": {"groupOfNames"},
+		"cn":          {"science"},
+		"entryuuid":   {"science-id"},
+		"member":      {"cn=physics", "uid=curie"},
+	}),
+	"cn=academia": ldap.NewEntry("cn=academia", map[string][]string{
+		"objectClass": {"groupOfNames"},
+		"cn":          {"academia"},
+		"entryuuid":   {"academia-id"},
+		"member":      {"cn=science"},
+	}),
+}
+
+var memberFilterRegexp = regexp.MustCompile(`\(member=([^)]*)\)`)
+
+var (
+	objectClassRegexp = regexp.MustCompile(`\(objectClass=([^)]*)\)`)
+	rdnRegexp         = regexp.MustCompile(`\(((?:uid|cn)=[^)]*)\)`)
+)
+
+// getNestedBackend returns a backend serving the nestedEntries. Base searches return the entry of the base DN,
+// searches for members return the groups having one of the members, all other searches return einstein.
+func getNestedBackend(t *testing.T, c config.LDAP) (*LDAP, *int) {
+	searches := 0
+	var sf searchFunc = func(sr *ldap.SearchRequest) (*ldap.SearchResult, error) {
+		searches++
+		if sr.Scope == ldap.ScopeBaseObject {
+			return &ldap.SearchResult{Entries: []*ldap.Entry{nestedEntries[sr.BaseDN]}}, nil
+		}
+		matches := memberFilterRegexp.FindAllStringSubmatch(sr.Filter, -1)
+		if len(matches) == 0 {
+			return &ldap.SearchResult{Entries: []*ldap.Entry{nestedEntries["uid=einstein"]}}, nil
+		}
+		res := &ldap.SearchResult{}
+		for _, dn := range []string{"cn=academia", "cn=physics", "cn=science"} {
+		group:
+			for _, member := range nestedEntries[dn].GetAttributeValues("member") {
+				for _, m := range matches {
+					if m[1] == member {
+						res.Entries = append(res.Entries, nestedEntries[dn])
+						break group
+					}
+				}
+			}
+		}
+		return res, nil
+	}
+	b, err := NewLDAPBackend(ldapMock{SearchFunc: &sf}, c, &logger)
+	if err != nil {
+		t.Fatalf("Expected success, got '%s'", err.Error())
+	}
+	return b, &searches
+}
+
+func TestNewLDAPBackendGroupNesting(t *testing.T) {
+	tc := nestedConfig
+	tc.GroupNestingMaxDepth = 0
+	if _, err := NewLDAPBackend(ldapMock{}, tc, &logger); err == nil {
+		t.Error("Should fail with an invalid nesting depth")
+	}
+}
+
+func TestGetUserTransitiveGroups(t *testing.T) {
+	b, searches := getNestedBackend(t, nestedConfig)
+	groups, err := b.GetUserTransitiveGroups(context.Background(), "einstein")
+	if err != nil {
+		t.Fatalf("Expected success, got '%s'", err.Error())
+	}
+	names := make([]string, 0, len(groups))
+	for _, g := range groups {
+		names = append(names, g.GetDisplayName())
+	}
+	sort.Strings(names)
+	if len(names) != 3 || names[0] != "academia" || names[1] != "physics" || names[2] != "science" {
+		t.Errorf("Expected all groups of the cycle once, got %v", names)
+	}
+	// one search for the user and one per level of nesting, the last one finds no new groups
+	if *searches != 5 {
+		t.Errorf("Expected 5 searches, got %d", *searches)
+	}
+
+	tc := nestedConfig
+	tc.GroupNestingMaxDepth = 2
+	b, _ = getNestedBackend(t, tc)
+	if groups, _ = b.GetUserTransitiveGroups(context.Background(), "einstein"); len(groups) != 2 {
+		t.Errorf("Expected the groups up to the maximum depth, got %d", len(groups))
+	}
+
+	tc.GroupNestingEnabled = false
+	b, _ = getNestedBackend(t, tc)
+	if groups, _ = b.GetUserTransitiveGroups(context.Background(), "einstein"); len(groups) != 1 || groups[0].GetDisplayName() != "physics" {
+		t.Errorf("Expected only the direct group without nesting, got %v", groups)
+	}
+}
+
+func TestGetGroupTransitiveMembers(t *testing.T) {
+	b, _ := getNestedBackend(t, nestedConfig)
+	// the mock returns the entries of the searched objectClass with one of the RDNs of the filter
+	searches := 0
+	var sf searchFunc = func(sr *ldap.SearchRequest) (*ldap.SearchResult, error) {
+		searches++
+		objectClass := objectClassRegexp.FindStringSubmatch(sr.Filter)[1]
+		res := &ldap.SearchResult{}
+		for _, m := range rdnRegexp.FindAllStringSubmatch(sr.Filter, -1) {
+			if e, ok := nestedEntries[m[1]]; ok && hasObjectClass(e, objectClass) {
+				res.Entries = append(res.Entries, e)
+			}
+		}
+		return res, nil
+	}
+	b.conn = ldapMock{SearchFunc: &sf}
+
+	users, err := b.GetGroupTransitiveMembers(context.Background(), "academia")
+	if err != nil {
+		t.Fatalf("Expected success, got '%s'", err.Error())
+	}
+	ids := make([]string, 0, len(users))
+	for _, u := range users {
+		ids = append(ids, u.GetId())
+	}
+	sort.Strings(ids)
+	if len(ids) != 2 || ids[0] != "curie-id" || ids[1] != "einstein-id" {
+		t.Errorf("Expected the users of all nested groups once, got %v", ids)
+	}
+	// one search for the group and one for the users and the groups of every level of nesting
+	if searches != 7 {
+		t.Errorf("Expected 7 searches, got %d", searches)
+	}
+
+	b.groupNestingDepth = 1
+	if users, _ = b.GetGroupTransitiveMembers(context.Background(), "academia"); len(users) != 0 {
+		t.Errorf("Expected no direct users, got %d", len(users))
+	}
+}
+
+func TestGetUserGroupsIgnoresNesting(t *testing.T) {
+	b, _ := getNestedBackend(t, nestedConfig)
+	groups, err := b.GetUserGroups(context.Background(), "einstein")
+	if err != nil {
+		t.Fatalf("Expected success, got '%s'", err.Error())
+	}
+	if len(groups) != 1 || groups[0].GetDisplayName() != "physics" {
+		t.Errorf("Expected only the direct group, got %v", groups)
+	}
+}
@@ -0,0 +1,44 @@
+package identity
+
+import (
+	"context"
+	"errors"
+
+	"github.com/go-ldap/ldap/v3"
+
+	"github.com/owncloud/ocis/extensions/graph/pkg/service/v0/errorcode"
+)
+
+// passwordExpiredResultCode is the result of a bind with a correct but expired password in the idm service. It is only
+// trusted if the backend is configured for idm, other servers return it for other failed binds as well, e.g. binds
+// which require a secure connection.
+const passwordExpiredResultCode = ldap.LDAPResultUnwillingToPerform
+
+// VerifyPassword implements the PasswordVerifier interface for the LDAP Backend. The password is verified with a bind
+// as the user on a separate connection, so that the connections of the backend stay bound to the service user.
+func (i *LDAP) VerifyPassword(ctx context.Context, nameOrID, password string) error {
+	a, ok := i.conn.(interface {
+		Authenticate(dn, password string) error
+	})
+	if !ok {
+		return errorcode.New(errorcode.NotSupported, "the LDAP connection can't verify passwords")
+	}
+	e, err := i.getLDAPUserByNameOrID(nameOrID)
+	if err != nil {
+		return err
+	}
+
+	err = a.Authenticate(e.DN, password)
+	var lerr *ldap.Error
+	switch {
+	case err == nil:
+		return nil
+	case i.idmPasswordExpiry && errors.As(err, &lerr) && lerr.ResultCode == passwordExpiredResultCode:
+		i.logger.Debug().Str("backend", "ldap").Str("dn", e.DN).Msg("the password is expired")
+		return nil
+	case errors.As(err, &lerr) && lerr.ResultCode == ldap.LDAPResultInvalidCredentials:
+		return errorcode.New(errorcode.AccessDenied, "the current password is wrong")
+	default:
+		return err
+	}
+}
@@ -10,16 +10,22 @@ import (
 	"time"
 
 	"github.com/go-ldap/ldap/v3"
+	libregraph "github.com/owncloud/libre-graph-api-go"
 	"github.com/owncloud/ocis/extensions/graph/pkg/config"
+	"github.com/owncloud/ocis/extensions/graph/pkg/service/v0/paging"
 	"github.com/owncloud/ocis/ocis-pkg/log"
 )
 
 // ldapMock implements the ldap.Client interfac
 type ldapMock struct {
 	SearchFunc *searchFunc
+	AddFunc    *addFunc
+	ModifyFunc *modifyFunc
 }
 
 type searchFunc func(*ldap.SearchRequest) (*ldap.SearchResult, error)
+type addFunc func(*ldap.AddRequest) error
+type modifyFunc func(*ldap.ModifyRequest) error
 
 func getMockedBackend(sf *searchFunc, lc config.LDAP, logger *log.Logger) (*LDAP, error) {
 	// Mock a Sizelimit Error
@@ -160,7 +166,7 @@ func TestGetUsers(t *testing.T) {
 		return nil, ldap.NewError(ldap.LDAPResultOperationsError, errors.New("mock"))
 	}
 	b, _ := getMockedBackend(&sf, lconfig, &logger)
-	_, err := b.GetUsers(context.Background(), url.Values{})
+	_, _, err := b.GetUsers(context.Background(), url.Values{})
 	if err == nil || err.Error() != "itemNotFound" {
 		t.Errorf("Expected 'itemNotFound' got '%s'", err.Error())
 	}
@@ -169,7 +175,7 @@ func TestGetUsers(t *testing.T) {
 		return &ldap.SearchResult{}, nil
 	}
 	b, _ = getMockedBackend(&sf, lconfig, &logger)
-	g, err := b.GetUsers(context.Background(), url.Values{})
+	g, _, err := b.GetUsers(context.Background(), url.Values{})
 	if err != nil {
 		t.Errorf("Expected success, got '%s'", err.Error())
 	} else if g == nil || len(g) != 0 {
@@ -177,6 +183,84 @@ func TestGetUsers(t *testing.T) {
 	}
 }
 
+func TestGetUsersPaged(t *testing.T) {
+	entries := []*ldap.Entry{userEntry, userEntry, userEntry, userEntry, userEntry}
+
+	// serves the entries in pages, the cookie is the offset of the next page
+	abandoned := 0
+	var sf searchFunc = func(sr *ldap.SearchRequest) (*ldap.SearchResult, error) {
+		c, ok := ldap.FindControl(sr.Controls, ldap.ControlTypePaging).(*ldap.ControlPaging)
+		if !ok {
+			return &ldap.SearchResult{Entries: entries}, nil
+		}
+		if c.PagingSize == 0 {
+			abandoned++
+			return &ldap.SearchResult{}, nil
+		}
+		offset := 0
+		if len(c.Cookie) > 0 {
+			offset = int(c.Cookie[0])
+		}
+		end := offset + int(c.PagingSize)
+		res := &ldap.ControlPaging{}
+		if end < len(entries) {
+			res.SetCookie([]byte{byte(end)})
+		} else {
+			end = len(entries)
+		}
+		return &ldap.SearchResult{Entries: entries[offset:end], Controls: []ldap.Control{res}}, nil
+	}
+	b, _ := getMockedBackend(&sf, lconfig, &logger)
+
+	users, info, err := b.GetUsers(context.Background(), url.Values{"$top": {"2"}, "$count": {"true"}})
+	if err != nil {
+		t.Fatalf("Expected success, got '%s'", err.Error())
+	}
+	if len(users) != 2 || info.NextToken == "" {
+		t.Errorf("Expected a page of 2 users with a next token, got %d users", len(users))
+	}
+	if info.Count == nil || *info.Count != 5 {
+		t.Errorf("Expected a count of 5")
+	}
+
+	if t2, err := paging.DecodeToken(info.NextToken); err != nil || t2.Offset != 2 {
+		t.Errorf("Expected the token to hold the offset of the next page, got %v (%v)", t2, err)
+	}
+	if abandoned != 1 {
+		t.Errorf("Expected the paged search to be abandoned after the page, got %d abandon requests", abandoned)
+	}
+
+	// the token doesn't depend on the connection, a new backend continues the search
+	b, _ = getMockedBackend(&sf, lconfig, &logger)
+	users, info, err = b.GetUsers(context.Background(), url.Values{"$top": {"2"}, "$skiptoken": {info.NextToken}})
+	if err != nil || len(users) != 2 || info.NextToken == "" {
+		t.Errorf("Expected the second page of 2 users, got %d users (%v)", len(users), err)
+	}
+
+	users, info, err = b.GetUsers(context.Background(), url.Values{"$top": {"2"}, "$skiptoken": {info.NextToken}})
+	if err != nil || len(users) != 1 || info.NextToken != "" {
+		t.Errorf("Expected the last page with 1 user, got %d users (%v)", len(users), err)
+	}
+	if abandoned != 2 {
+		t.Errorf("Expected no abandon request after the last page, got %d abandon requests", abandoned)
+	}
+
+	users, info, err = b.GetUsers(context.Background(), url.Values{"$top": {"2"}, "$skip": {"4"}})
+	if err != nil || len(users) != 1 || info.NextToken != "" {
+		t.Errorf("Expected 1 user after skipping 4, got %d users (%v)", len(users), err)
+	}
+
+	users, _, err = b.GetUsers(context.Background(), url.Values{"$skip": {"1"}})
+	if err != nil || len(users) != 4 {
+		t.Errorf("Expected 4 users after skipping 1, got %d users (%v)", len(users), err)
+	}
+
+	_, _, err = b.GetUsers(context.Background(), url.Values{"$top": {"x"}})
+	if err == nil || err.Error() != "invalidRequest" {
+		t.Errorf("Expected 'invalidRequest' got '%v'", err)
+	}
+}
+
 func TestGetGroup(t *testing.T) {
 	// Mock a Sizelimit Error
 	var sf searchFunc = func(*ldap.SearchRequest) (*ldap.SearchResult, error) {
@@ -218,7 +302,7 @@ func TestGetGroups(t *testing.T) {
 		return nil, ldap.NewError(ldap.LDAPResultOperationsError, errors.New("mock"))
 	}
 	b, _ := getMockedBackend(&sf, lconfig, &logger)
-	_, err := b.GetGroups(context.Background(), url.Values{})
+	_, _, err := b.GetGroups(context.Background(), url.Values{})
 	if err == nil || err.Error() != "itemNotFound" {
 		t.Errorf("Expected 'itemNotFound' got '%s'", err.Error())
 	}
@@ -227,7 +311,7 @@ func TestGetGroups(t *testing.T) {
 		return &ldap.SearchResult{}, nil
 	}
 	b, _ = getMockedBackend(&sf, lconfig, &logger)
-	g, err := b.GetGroups(context.Background(), url.Values{})
+	g, _, err := b.GetGroups(context.Background(), url.Values{})
 	if err != nil {
 		t.Errorf("Expected success, got '%s'", err.Error())
 	} else if g == nil || len(g) != 0 {
@@ -267,7 +351,10 @@ func (c ldapMock) ExternalBind() error {
 	return ldap.NewError(ldap.LDAPResultNotSupported, fmt.Errorf("not implemented"))
 }
 
-func (c ldapMock) Add(*ldap.AddRequest) error {
+func (c ldapMock) Add(addRequest *ldap.AddRequest) error {
+	if c.AddFunc != nil {
+		return (*c.AddFunc)(addRequest)
+	}
 	return ldap.NewError(ldap.LDAPResultNotSupported, fmt.Errorf("not implemented"))
 }
 
@@ -275,7 +362,10 @@ func (c ldapMock) Del(*ldap.DelRequest) error {
 	return ldap.NewError(ldap.LDAPResultNotSupported, fmt.Errorf("not implemented"))
 }
 
-func (c ldapMock) Modify(*ldap.ModifyRequest) error {
+func (c ldapMock) Modify(modifyRequest *ldap.ModifyRequest) error {
+	if c.ModifyFunc != nil {
+		return (*c.ModifyFunc)(modifyRequest)
+	}
 	return ldap.NewError(ldap.LDAPResultNotSupported, fmt.Errorf("not implemented"))
 }
 
@@ -305,3 +395,347 @@ func (c ldapMock) Search(searchRequest *ldap.SearchRequest) (*ldap.SearchResult,
 func (c ldapMock) SearchWithPaging(searchRequest *ldap.SearchRequest, pagingSize uint32) (*ldap.SearchResult, error) {
 	return nil, ldap.NewError(ldap.LDAPResultNotSupported, fmt.Errorf("not implemented"))
 }
+
+func TestGetUsersFilter(t *testing.T) {
+	var filters []string
+	var sf searchFunc = func(sr *ldap.SearchRequest) (*ldap.SearchResult, error) {
+		filters = append(filters, sr.Filter)
+		switch sr.Filter {
+		case "(&filter(objectClass=)(cn=users))":
+			// the server doesn't maintain memberOf, the members are listed in the group only
+			return &ldap.SearchResult{Entries: []*ldap.Entry{ldap.NewEntry("cn=users",
+				map[string][]string{
+					"cn":        {"users"},
+					"entryuuid": {"efgh-ijkl"},
+					"member":    {"uid=user", "uid=other,ou=gone"},
+				})}}, nil
+		case "(&filter(objectClass=)(|(uid=user)(uid=other)))":
+			// an entry with the same RDN but another DN is not a member
+			return &ldap.SearchResult{Entries: []*ldap.Entry{userEntry, ldap.NewEntry("uid=other,ou=elsewhere",
+				map[string][]string{
+					"uid":       {"other"},
+					"entryuuid": {"mnop-qrst"},
+				})}}, nil
+		case "(&filter(objectClass=)(cn=nobody))":
+			return &ldap.SearchResult{Entries: []*ldap.Entry{ldap.NewEntry("cn=nobody",
+				map[string][]string{"cn": {"nobody"}, "entryuuid": {"uvwx-yz"}})}}, nil
+		}
+		return &ldap.SearchResult{Entries: []*ldap.Entry{userEntry}}, nil
+	}
+	b, _ := getMockedBackend(&sf, lconfig, &logger)
+
+	tests := []struct {
+		filter string
+		want   string
+	}{
+		{
+			filter: "displayName eq 'Albert Einstein'",
+			want:   "(&filter(objectClass=)(displayname=Albert Einstein))",
+		},
+		{
+			filter: "startswith(mail,'albert') and id ne 'a*b'",
+			want:   "(&filter(objectClass=)(&(mail=albert*)(!(entryUUID=a\\2ab))))",
+		},
+		{
+			filter: "onPremisesSamAccountName eq 'einstein' or mail eq null",
+			want:   "(&filter(objectClass=)(|(uid=einstein)(!(mail=*))))",
+		},
+		{
+			filter: "memberOf/any(g:g/displayName eq 'users')",
+			want:   "(&filter(objectClass=)(entryUUID=abcd-defg))",
+		},
+		{
+			filter: "memberOf/any(g:g/displayName eq 'nobody')",
+			want:   "(&filter(objectClass=)" + noMatchFilter + ")",
+		},
+	}
+	for _, tt := range tests {
+		filters = nil
+		if _, _, err := b.GetUsers(context.Background(), url.Values{"$filter": {tt.filter}}); err != nil {
+			t.Errorf("%s: expected success, got '%s'", tt.filter, err.Error())
+			continue
+		}
+		if got := filters[len(filters)-1]; got != tt.want {
+			t.Errorf("%s: expected LDAP filter '%s' got '%s'", tt.filter, tt.want, got)
+		}
+	}
+
+	// the members of a group are read with a single search
+	filters = nil
+	if _, _, err := b.GetUsers(context.Background(), url.Values{"$filter": {"memberOf/any(g:g/displayName eq 'users')"}}); err != nil {
+		t.Fatalf("Expected success, got '%s'", err.Error())
+	}
+	if len(filters) != 3 || filters[1] != "(&filter(objectClass=)(|(uid=user)(uid=other)))" {
+		t.Errorf("Expected the members to be read by RDN, got '%v'", filters)
+	}
+
+	for _, f := range []string{"contains(mail,'albert')", "surname eq 'Einstein'", "memberOf/all(g:g/id eq 'x')", "mail eq"} {
+		if _, _, err := b.GetUsers(context.Background(), url.Values{"$filter": {f}}); err == nil {
+			t.Errorf("%s: expected an error", f)
+		}
+	}
+}
+
+func TestGetGroupsFilter(t *testing.T) {
+	var filters []string
+	var sf searchFunc = func(sr *ldap.SearchRequest) (*ldap.SearchResult, error) {
+		filters = append(filters, sr.Filter)
+		if sr.Filter == "(&filter(objectClass=)(entryUUID=abcd-defg))" {
+			return &ldap.SearchResult{Entries: []*ldap.Entry{userEntry}}, nil
+		}
+		return &ldap.SearchResult{Entries: []*ldap.Entry{groupEntry}}, nil
+	}
+	b, _ := getMockedBackend(&sf, lconfig, &logger)
+
+	if _, _, err := b.GetGroups(context.Background(), url.Values{"$filter": {"members/any(m:m/id eq 'abcd-defg')"}}); err != nil {
+		t.Fatalf("Expected success, got '%s'", err.Error())
+	}
+	want := "(&filter(objectClass=)(member=uid=user))"
+	if got := filters[len(filters)-1]; got != want {
+		t.Errorf("Expected LDAP filter '%s' got '%s'", want, got)
+	}
+}
+
+func TestGetUserGroups(t *testing.T) {
+	var filters []string
+	var sf searchFunc = func(sr *ldap.SearchRequest) (*ldap.SearchResult, error) {
+		filters = append(filters, sr.Filter)
+		if sr.Filter == "(&filter(objectClass=)(member=uid=user))" {
+			return &ldap.SearchResult{Entries: []*ldap.Entry{groupEntry}}, nil
+		}
+		return &ldap.SearchResult{Entries: []*ldap.Entry{userEntry}}, nil
+	}
+	b, _ := getMockedBackend(&sf, lconfig, &logger)
+
+	groups, err := b.GetUserGroups(context.Background(), "user")
+	if err != nil {
+		t.Fatalf("Expected success, got '%s'", err.Error())
+	}
+	if len(groups) != 1 || groups[0].GetDisplayName() != "group" {
+		t.Errorf("Expected the group of the user, got %v (filters %v)", groups, filters)
+	}
+}
+
+func TestGetUsersGroups(t *testing.T) {
+	otherUser := ldap.NewEntry("uid=other", map[string][]string{"entryuuid": {"other-id"}})
+	memberGroup := ldap.NewEntry("cn=group", map[string][]string{
+		"cn":        {"group"},
+		"entryuuid": {"group-id"},
+		"member":    {"UID=user", "uid=nobody"},
+	})
+	var filters []string
+	var sf searchFunc = func(sr *ldap.SearchRequest) (*ldap.SearchResult, error) {
+		filters = append(filters, sr.Filter)
+		if sr.Filter == "(&filter(objectClass=)(|(member=uid=user)(member=uid=other)))" {
+			return &ldap.SearchResult{Entries: []*ldap.Entry{memberGroup}}, nil
+		}
+		return &ldap.SearchResult{Entries: []*ldap.Entry{userEntry, otherUser}}, nil
+	}
+	b, _ := getMockedBackend(&sf, lconfig, &logger)
+
+	groups, err := b.GetUsersGroups(context.Background(), []string{"abcd-defg", "other-id"})
+	if err != nil {
+		t.Fatalf("Expected success, got '%s'", err.Error())
+	}
+	if len(filters) != 2 {
+		t.Errorf("Expected one search for the users and one for the groups, got %v", filters)
+	}
+	if len(groups["abcd-defg"]) != 1 || groups["abcd-defg"][0].GetId() != "group-id" {
+		t.Errorf("Expected the group of the user, got %v (filters %v)", groups["abcd-defg"], filters)
+	}
+	if g, ok := groups["other-id"]; !ok || len(g) != 0 {
+		t.Errorf("Expected no groups for the other user, got %v", g)
+	}
+}
+
+var attributesConfig = func() config.LDAP {
+	c := lconfig
+	c.WriteEnabled = true
+	c.UserSurnameAttribute = "sn"
+	c.UserGivenNameAttribute = "givenName"
+	c.UserPreferredLanguageAttribute = "preferredLanguage"
+	c.UserCreationTimeAttribute = "createTimestamp"
+	c.UserEnabledAttribute = "ownCloudUserEnabled"
+	return c
+}()
+
+func TestCreateUserModelFromLDAPOptionalAttributes(t *testing.T) {
+	b, _ := NewLDAPBackend(ldapMock{}, attributesConfig, &logger)
+	user := b.createUserModelFromLDAP(ldap.NewEntry("uid=user", map[string][]string{
+		"uid":                 {"user"},
+		"sn":                  {"Curie"},
+		"givenName":           {"Marie"},
+		"preferredLanguage":   {"fr"},
+		"createTimestamp":     {"20220517093000Z"},
+		"ownCloudUserEnabled": {"FALSE"},
+	}))
+	if user.GetSurname() != "Curie" || user.GetGivenName() != "Marie" || user.GetPreferredLanguage() != "fr" {
+		t.Errorf("Unexpected user %+v", user)
+	}
+	if user.AccountEnabled == nil || *user.AccountEnabled {
+		t.Error("Expected a disabled account")
+	}
+	if want := time.Date(2022, 5, 17, 9, 30, 0, 0, time.UTC); !user.GetCreatedDateTime().Equal(want) {
+		t.Errorf("Expected creation time %v got %v", want, user.GetCreatedDateTime())
+	}
+
+	// users without the enabled attribute are enabled
+	if user = b.createUserModelFromLDAP(userEntry); user.AccountEnabled == nil || !*user.AccountEnabled {
+		t.Error("Expected an enabled account")
+	}
+
+	// unmapped attributes are not returned
+	b, _ = NewLDAPBackend(ldapMock{}, lconfig, &logger)
+	if user = b.createUserModelFromLDAP(userEntry); user.AccountEnabled != nil || user.Surname != nil {
+		t.Errorf("Unexpected user %+v", user)
+	}
+}
+
+func TestUserModifyRequestOptionalAttributes(t *testing.T) {
+	b, _ := NewLDAPBackend(ldapMock{}, attributesConfig, &logger)
+	e := ldap.NewEntry("uid=user", map[string][]string{
+		"uid":       {"user"},
+		"givenName": {"Marie"},
+	})
+	mr, err := b.userModifyRequest(e, libregraph.User{
+		AccountEnabled:    libregraph.PtrBool(false),
+		GivenName:         libregraph.PtrString(""),
+		PreferredLanguage: libregraph.PtrString("fr"),
+	})
+	if err != nil {
+		t.Fatalf("Expected success, got '%s'", err.Error())
+	}
+	changes := map[string]ldap.Change{}
+	for _, c := range mr.Changes {
+		changes[c.Modification.Type] = c
+	}
+	if c := changes["givenName"]; c.Operation != ldap.DeleteAttribute {
+		t.Errorf("Expected the given name to be deleted, got %+v", c)
+	}
+	if c := changes["preferredLanguage"]; c.Operation != ldap.ReplaceAttribute || c.Modification.Vals[0] != "fr" {
+		t.Errorf("Expected the preferred language to be replaced, got %+v", c)
+	}
+	if c := changes["ownCloudUserEnabled"]; c.Operation != ldap.ReplaceAttribute || c.Modification.Vals[0] != "FALSE" {
+		t.Errorf("Expected the account to be disabled, got %+v", c)
+	}
+
+	b, _ = NewLDAPBackend(ldapMock{}, lconfig, &logger)
+	_, err = b.userModifyRequest(e, libregraph.User{AccountEnabled: libregraph.PtrBool(false)})
+	if err == nil || err.Error() != "notSupported" {
+		t.Errorf("Expected 'notSupported' got '%v'", err)
+	}
+}
+
+func TestCreateUserPasswordPolicy(t *testing.T) {
+	var af addFunc = func(ar *ldap.AddRequest) error {
+		return ldap.NewError(ldap.LDAPResultConstraintViolation, errors.New("password policy violation: the password must have at least 8 characters"))
+	}
+	tc := lconfig
+	tc.WriteEnabled = true
+	b, _ := NewLDAPBackend(ldapMock{AddFunc: &af}, tc, &logger)
+
+	user := libregraph.User{
+		OnPremisesSamAccountName: libregraph.PtrString("user"),
+		Mail:                     libregraph.PtrString("user@example"),
+		DisplayName:              libregraph.PtrString("DisplayName"),
+		PasswordProfile:          &libregraph.PasswordProfile{Password: libregraph.PtrString("short")},
+	}
+	_, err := b.CreateUser(context.Background(), user)
+	if err == nil || err.Error() != "passwordPolicyViolation" {
+		t.Errorf("Expected 'passwordPolicyViolation' got '%v'", err)
+	}
+
+	// other constraint violations aren't caused by the password
+	user.PasswordProfile = nil
+	_, err = b.CreateUser(context.Background(), user)
+	var lerr *ldap.Error
+	if !errors.As(err, &lerr) {
+		t.Errorf("Expected the LDAP error got '%v'", err)
+	}
+}
+
+func TestHashedPasswordsAreRejected(t *testing.T) {
+	added := false
+	var af addFunc = func(ar *ldap.AddRequest) error {
+		added = true
+		return nil
+	}
+	tc := lconfig
+	tc.WriteEnabled = true
+	b, _ := NewLDAPBackend(ldapMock{AddFunc: &af}, tc, &logger)
+
+	user := libregraph.User{
+		OnPremisesSamAccountName: libregraph.PtrString("user"),
+		Mail:                     libregraph.PtrString("user@example"),
+		DisplayName:              libregraph.PtrString("DisplayName"),
+		PasswordProfile:          &libregraph.PasswordProfile{Password: libregraph.PtrString("{SSHA}c2VjcmV0")},
+	}
+	if _, err := b.CreateUser(context.Background(), user); err == nil || err.Error() != "invalidRequest" {
+		t.Errorf("Expected 'invalidRequest' got '%v'", err)
+	}
+	if added {
+		t.Error("Expected the user not to be added")
+	}
+	if _, err := b.userModifyRequest(userEntry, user); err == nil || err.Error() != "invalidRequest" {
+		t.Errorf("Expected 'invalidRequest' got '%v'", err)
+	}
+}
+
+// authenticatingMock is an ldapMock which verifies passwords with a separate bind
+type authenticatingMock struct {
+	ldapMock
+	authenticate func(dn, password string) error
+}
+
+func (m authenticatingMock) Authenticate(dn, password string) error {
+	return m.authenticate(dn, password)
+}
+
+func TestVerifyPassword(t *testing.T) {
+	var sf searchFunc = func(*ldap.SearchRequest) (*ldap.SearchResult, error) {
+		return &ldap.SearchResult{Entries: []*ldap.Entry{userEntry}}, nil
+	}
+	conn := authenticatingMock{ldapMock: ldapMock{SearchFunc: &sf}, authenticate: func(dn, password string) error {
+		switch password {
+		case "correct":
+			return nil
+		case "expired":
+			return ldap.NewError(ldap.LDAPResultUnwillingToPerform, errors.New("expired"))
+		default:
+			return ldap.NewError(ldap.LDAPResultInvalidCredentials, errors.New("invalid credentials"))
+		}
+	}}
+	idmConfig := lconfig
+	idmConfig.IDMPasswordExpiry = true
+	b, _ := NewLDAPBackend(conn, idmConfig, &logger)
+
+	if err := b.VerifyPassword(context.Background(), "user", "correct"); err != nil {
+		t.Errorf("Expected success got '%v'", err)
+	}
+	if err := b.VerifyPassword(context.Background(), "user", "expired"); err != nil {
+		t.Errorf("Expected expired passwords to be accepted got '%v'", err)
+	}
+	if err := b.VerifyPassword(context.Background(), "user", "wrong"); err == nil || err.Error() != "accessDenied" {
+		t.Errorf("Expected 'accessDenied' got '%v'", err)
+	}
+
+	// other servers return unwillingToPerform for wrong passwords too, e.g. if the bind requires a secure connection
+	generic := authenticatingMock{ldapMock: ldapMock{SearchFunc: &sf}, authenticate: func(dn, password string) error {
+		if password == "correct" {
+			return nil
+		}
+		return ldap.NewError(ldap.LDAPResultUnwillingToPerform, errors.New("confidentiality required"))
+	}}
+	b, _ = NewLDAPBackend(generic, lconfig, &logger)
+	if err := b.VerifyPassword(context.Background(), "user", "correct"); err != nil {
+		t.Errorf("Expected success got '%v'", err)
+	}
+	if err := b.VerifyPassword(context.Background(), "user", "wrong"); err == nil {
+		t.Errorf("Expected the wrong password to be rejected")
+	}
+
+	b, _ = NewLDAPBackend(ldapMock{SearchFunc: &sf}, lconfig, &logger)
+	if err := b.VerifyPassword(context.Background(), "user", "correct"); err == nil || err.Error() != "notSupported" {
+		t.Errorf("Expected 'notSupported' got '%v'", err)
+	}
+}
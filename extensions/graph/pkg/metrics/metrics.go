@@ -14,6 +14,10 @@ var (
 type Metrics struct {
 	// Counter  *prometheus.CounterVec
 	BuildInfo *prometheus.GaugeVec
+	// IdentityCacheLookups counts the lookups in the identity cache by their result, "hit" or "miss".
+	IdentityCacheLookups *prometheus.CounterVec
+	// LDAPRequestDuration observes the duration of the requests to the LDAP server by their operation.
+	LDAPRequestDuration *prometheus.HistogramVec
 }
 
 // New initializes the available metrics.
@@ -25,9 +29,23 @@ func New() *Metrics {
 			Name:      "build_info",
 			Help:      "Build information",
 		}, []string{"version"}),
+		IdentityCacheLookups: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: Namespace,
+			Subsystem: Subsystem,
+			Name:      "identity_cache_lookups_total",
+			Help:      "Lookups in the identity cache by result",
+		}, []string{"result"}),
+		LDAPRequestDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: Namespace,
+			Subsystem: Subsystem,
+			Name:      "ldap_request_duration_seconds",
+			Help:      "Duration of the requests to the LDAP server by operation",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"operation"}),
 	}
 
 	_ = prometheus.Register(m.BuildInfo)
-	// TODO: implement metrics
+	_ = prometheus.Register(m.IdentityCacheLookups)
+	_ = prometheus.Register(m.LDAPRequestDuration)
 	return m
 }
@@ -57,6 +57,8 @@ func Server(opts ...Option) (http.Service, error) {
 			),
 		),
 		svc.EventsPublisher(publisher),
+		svc.EventsConsumer(publisher),
+		svc.Metrics(options.Metrics),
 	)
 
 	{
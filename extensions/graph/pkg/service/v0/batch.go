@@ -0,0 +1,247 @@
+package svc
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/render"
+	"github.com/owncloud/ocis/extensions/graph/pkg/service/v0/errorcode"
+	"github.com/owncloud/ocis/extensions/graph/pkg/service/v0/net"
+)
+
+const (
+	// maxBatchRequests is the maximum number of requests in a batch, the same limit as in MS Graph.
+	maxBatchRequests = 20
+	// maxBatchSize is the maximum size of the body of a batch request in bytes.
+	maxBatchSize = 4 << 20
+)
+
+type batchRequest struct {
+	Requests []batchRequestItem `json:"requests"`
+}
+
+type batchRequestItem struct {
+	ID        string            `json:"id"`
+	Method    string            `json:"method"`
+	URL       string            `json:"url"`
+	Headers   map[string]string `json:"headers,omitempty"`
+	Body      json.RawMessage   `json:"body,omitempty"`
+	DependsOn []string          `json:"dependsOn,omitempty"`
+}
+
+type batchResponse struct {
+	Responses []batchResponseItem `json:"responses"`
+}
+
+type batchResponseItem struct {
+	ID      string            `json:"id"`
+	Status  int               `json:"status"`
+	Headers map[string]string `json:"headers,omitempty"`
+	Body    json.RawMessage   `json:"body,omitempty"`
+}
+
+// PostBatch executes the requests of a JSON batch and returns their responses in the order of the requests.
+//
+// The requests are dispatched to the router of the service with the headers and the authenticated user of the batch
+// request, so they don't have to pass the proxy again. They are executed one after another, a request listed in the
+// dependsOn of another one is executed first. Requests depending on a failed request fail with 424 Failed Dependency.
+func (g Graph) PostBatch(w http.ResponseWriter, r *http.Request) {
+	batch := batchRequest{}
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxBatchSize)).Decode(&batch); err != nil {
+		errorcode.InvalidRequest.Render(w, r, http.StatusBadRequest, fmt.Sprintf("invalid request body: %s", err.Error()))
+		return
+	}
+	order, err := batchOrder(batch.Requests)
+	if err != nil {
+		renderError(w, r, err)
+		return
+	}
+	g.logger.Info().Int("requests", len(batch.Requests)).Msg("Calling PostBatch")
+
+	responses := make(map[string]batchResponseItem, len(batch.Requests))
+	for _, i := range order {
+		req := batch.Requests[i]
+		failed := ""
+		for _, id := range req.DependsOn {
+			if responses[id].Status >= http.StatusBadRequest {
+				failed = id
+				break
+			}
+		}
+
+		// the route context of the batch request has to be removed, otherwise the router would not route the
+		// request. Errors are rendered for a copy of the batch request, rendering sets the status in its context.
+		br := r.WithContext(context.WithValue(r.Context(), chi.RouteCtxKey, (*chi.Context)(nil)))
+		rw := newBatchResponseWriter()
+		if failed != "" {
+			errorcode.GeneralException.Render(rw, br, http.StatusFailedDependency, fmt.Sprintf("request '%s' failed", failed))
+		} else {
+			g.dispatchBatchRequest(rw, br, req)
+		}
+		responses[req.ID] = rw.item(req.ID)
+	}
+
+	res := batchResponse{Responses: make([]batchResponseItem, 0, len(batch.Requests))}
+	for _, req := range batch.Requests {
+		res.Responses = append(res.Responses, responses[req.ID])
+	}
+	render.Status(r, http.StatusOK)
+	render.JSON(w, r, res)
+}
+
+// dispatchBatchRequest serves a request of a batch sent with the batch request r.
+func (g Graph) dispatchBatchRequest(w http.ResponseWriter, r *http.Request, req batchRequestItem) {
+	target, err := batchTarget(g.config.HTTP.Root, req)
+	if err != nil {
+		renderError(w, r, err)
+		return
+	}
+
+	sub, err := http.NewRequestWithContext(r.Context(), strings.ToUpper(req.Method), target, bytes.NewReader(req.Body))
+	if err != nil {
+		errorcode.InvalidRequest.Render(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+	sub.Header = r.Header.Clone()
+	sub.Header.Del("Content-Length")
+	sub.Header.Del(net.HeaderContentType)
+	for k, v := range req.Headers {
+		sub.Header.Set(k, v)
+	}
+	if len(req.Body) > 0 && sub.Header.Get(net.HeaderContentType) == "" {
+		sub.Header.Set(net.HeaderContentType, "application/json")
+	}
+	g.mux.ServeHTTP(w, sub)
+}
+
+// batchTarget returns the url of a request of a batch, which is relative to the /v1.0 path of the service.
+func batchTarget(root string, req batchRequestItem) (string, error) {
+	if req.Method == "" || req.URL == "" {
+		return "", errorcode.New(errorcode.InvalidRequest, "method and url are required")
+	}
+	u, err := url.Parse(req.URL)
+	if err != nil || u.IsAbs() || u.Host != "" {
+		return "", errorcode.New(errorcode.InvalidRequest, fmt.Sprintf("invalid url '%s'", req.URL))
+	}
+	p := path.Clean("/" + u.Path)
+	if p == "/$batch" {
+		return "", errorcode.New(errorcode.InvalidRequest, "batches can not be nested")
+	}
+	u.Path = strings.TrimSuffix(root, "/") + "/v1.0" + p
+	return u.String(), nil
+}
+
+// batchOrder validates the requests of a batch and returns the order they have to be executed in, every request
+// follows the requests it depends on.
+func batchOrder(requests []batchRequestItem) ([]int, error) {
+	switch {
+	case len(requests) == 0:
+		return nil, errorcode.New(errorcode.InvalidRequest, "the batch contains no requests")
+	case len(requests) > maxBatchRequests:
+		return nil, errorcode.New(errorcode.InvalidRequest, fmt.Sprintf("a batch can contain at most %d requests", maxBatchRequests))
+	}
+
+	index := make(map[string]int, len(requests))
+	for i, req := range requests {
+		if req.ID == "" {
+			return nil, errorcode.New(errorcode.InvalidRequest, "the id of a request is required")
+		}
+		if _, ok := index[req.ID]; ok {
+			return nil, errorcode.New(errorcode.InvalidRequest, fmt.Sprintf("duplicate request id '%s'", req.ID))
+		}
+		index[req.ID] = i
+	}
+
+	// the state of a request while ordering, zero means the request was not visited yet
+	const (
+		visiting = iota + 1
+		visited
+	)
+	state := make([]int, len(requests))
+	order := make([]int, 0, len(requests))
+	var visit func(i int) error
+	visit = func(i int) error {
+		switch state[i] {
+		case visited:
+			return nil
+		case visiting:
+			return errorcode.New(errorcode.InvalidRequest, fmt.Sprintf("circular dependency of request '%s'", requests[i].ID))
+		}
+		state[i] = visiting
+		for _, id := range requests[i].DependsOn {
+			j, ok := index[id]
+			if !ok {
+				return errorcode.New(errorcode.InvalidRequest, fmt.Sprintf("request '%s' depends on unknown request '%s'", requests[i].ID, id))
+			}
+			if err := visit(j); err != nil {
+				return err
+			}
+		}
+		state[i] = visited
+		order = append(order, i)
+		return nil
+	}
+	for i := range requests {
+		if err := visit(i); err != nil {
+			return nil, err
+		}
+	}
+	return order, nil
+}
+
+// batchResponseWriter records the response to a request of a batch.
+type batchResponseWriter struct {
+	header http.Header
+	status int
+	body   bytes.Buffer
+}
+
+func newBatchResponseWriter() *batchResponseWriter {
+	return &batchResponseWriter{header: http.Header{}}
+}
+
+func (b *batchResponseWriter) Header() http.Header {
+	return b.header
+}
+
+func (b *batchResponseWriter) Write(p []byte) (int, error) {
+	if b.status == 0 {
+		b.status = http.StatusOK
+	}
+	return b.body.Write(p)
+}
+
+func (b *batchResponseWriter) WriteHeader(status int) {
+	if b.status == 0 {
+		b.status = status
+	}
+}
+
+// item returns the recorded response. JSON bodies are embedded, other bodies are base64 encoded.
+func (b *batchResponseWriter) item(id string) batchResponseItem {
+	item := batchResponseItem{ID: id, Status: b.status}
+	if item.Status == 0 {
+		item.Status = http.StatusOK
+	}
+	if len(b.header) > 0 {
+		item.Headers = make(map[string]string, len(b.header))
+		for k := range b.header {
+			item.Headers[k] = b.header.Get(k)
+		}
+	}
+	if body := bytes.TrimSpace(b.body.Bytes()); len(body) > 0 {
+		if json.Valid(body) {
+			item.Body = body
+		} else {
+			item.Body, _ = json.Marshal(body)
+		}
+	}
+	return item
+}
@@ -0,0 +1,128 @@
+package svc_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+
+	userpb "github.com/cs3org/go-cs3apis/cs3/identity/user/v1beta1"
+	revactx "github.com/cs3org/reva/v2/pkg/ctx"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	libregraph "github.com/owncloud/libre-graph-api-go"
+	"github.com/owncloud/ocis/extensions/graph/mocks"
+	"github.com/owncloud/ocis/extensions/graph/pkg/config/defaults"
+	service "github.com/owncloud/ocis/extensions/graph/pkg/service/v0"
+	"github.com/owncloud/ocis/extensions/graph/pkg/service/v0/errorcode"
+	"github.com/stretchr/testify/mock"
+)
+
+var _ = Describe("Batch", func() {
+	var (
+		svc             service.Service
+		identityBackend *mocks.Backend
+
+		einstein = &userpb.User{Id: &userpb.UserId{OpaqueId: "einstein"}, Username: "einstein"}
+	)
+
+	type batchResponse struct {
+		Responses []struct {
+			ID      string
+			Status  int
+			Headers map[string]string
+			Body    json.RawMessage
+		}
+	}
+
+	// batch posts the batch to the service on behalf of einstein.
+	batch := func(body string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodPost, "/graph/v1.0/$batch", strings.NewReader(body))
+		r = r.WithContext(revactx.ContextSetUser(r.Context(), einstein))
+		rr := httptest.NewRecorder()
+		svc.ServeHTTP(rr, r)
+		return rr
+	}
+
+	JustBeforeEach(func() {
+		identityBackend = &mocks.Backend{}
+		svc = service.NewService(
+			service.Config(defaults.DefaultConfig()),
+			service.WithGatewayClient(&mocks.GatewayClient{}),
+			service.WithIdentityBackend(identityBackend),
+		)
+		identityBackend.On("GetUser", mock.Anything, "marie").Return(&libregraph.User{
+			Id:          libregraph.PtrString("marie"),
+			DisplayName: libregraph.PtrString("Marie Curie"),
+		}, nil)
+		identityBackend.On("GetUser", mock.Anything, mock.Anything).Return(nil, errorcode.New(errorcode.ItemNotFound, "not found"))
+	})
+
+	It("executes the requests of a batch", func() {
+		rr := batch(`{"requests":[
+			{"id":"1","method":"GET","url":"/me"},
+			{"id":"2","method":"GET","url":"users/marie?$select=displayName","dependsOn":["1"]},
+			{"id":"3","method":"GET","url":"/users/unknown"},
+			{"id":"4","method":"GET","url":"/me","dependsOn":["3"]},
+			{"id":"5","method":"GET","url":"/unknown"}
+		]}`)
+		Expect(rr.Code).To(Equal(http.StatusOK))
+
+		res := batchResponse{}
+		err := json.Unmarshal(rr.Body.Bytes(), &res)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(len(res.Responses)).To(Equal(5))
+
+		Expect(res.Responses[0].ID).To(Equal("1"))
+		Expect(res.Responses[0].Status).To(Equal(http.StatusOK))
+		Expect(res.Responses[0].Headers["Content-Type"]).To(HavePrefix("application/json"))
+		me := libregraph.User{}
+		err = json.Unmarshal(res.Responses[0].Body, &me)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(me.GetOnPremisesSamAccountName()).To(Equal("einstein"))
+
+		Expect(res.Responses[1].Status).To(Equal(http.StatusOK))
+		Expect(string(res.Responses[1].Body)).To(ContainSubstring(`"displayName":"Marie Curie"`))
+		Expect(res.Responses[2].Status).To(Equal(http.StatusNotFound))
+		Expect(res.Responses[3].Status).To(Equal(http.StatusFailedDependency))
+		Expect(res.Responses[4].Status).To(Equal(http.StatusNotFound))
+	})
+
+	It("executes the dependencies of a request first", func() {
+		rr := batch(`{"requests":[
+			{"id":"1","method":"GET","url":"/users/unknown","dependsOn":["2"]},
+			{"id":"2","method":"GET","url":"/users/unknown"}
+		]}`)
+		Expect(rr.Code).To(Equal(http.StatusOK))
+		res := batchResponse{}
+		err := json.Unmarshal(rr.Body.Bytes(), &res)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(res.Responses[0].Status).To(Equal(http.StatusFailedDependency))
+		Expect(res.Responses[1].Status).To(Equal(http.StatusNotFound))
+		identityBackend.AssertNumberOfCalls(GinkgoT(), "GetUser", 1)
+	})
+
+	It("rejects invalid batches", func() {
+		requests := make([]string, 21)
+		for i := range requests {
+			requests[i] = fmt.Sprintf(`{"id":"%d","method":"GET","url":"/me"}`, i)
+		}
+
+		for _, body := range []string{
+			`invalid`,
+			`{"requests":[]}`,
+			`{"requests":[` + strings.Join(requests, ",") + `]}`,
+			`{"requests":[{"id":"1","method":"GET","url":"/me"},{"id":"1","method":"GET","url":"/me"}]}`,
+			`{"requests":[{"id":"1","method":"GET","url":"/me","dependsOn":["2"]}]}`,
+			`{"requests":[{"id":"1","method":"GET","url":"/me","dependsOn":["2"]},{"id":"2","method":"GET","url":"/me","dependsOn":["1"]}]}`,
+			`{"requests":[{"id":"1","method":"POST","url":"/me","body":"` + strings.Repeat("a", 5<<20) + `"}]}`,
+		} {
+			Expect(batch(body).Code).To(Equal(http.StatusBadRequest), body)
+		}
+
+		rr := batch(`{"requests":[{"id":"1","method":"POST","url":"/$batch","body":{"requests":[]}}]}`)
+		Expect(rr.Code).To(Equal(http.StatusOK))
+		Expect(rr.Body.String()).To(ContainSubstring(`"status":400`))
+	})
+})
@@ -0,0 +1,25 @@
+package svc
+
+import (
+	"net/http"
+
+	"github.com/go-chi/render"
+	"github.com/owncloud/ocis/extensions/graph/pkg/identity"
+)
+
+// capabilities describes the operations of the graph API which depend on the identity backend.
+type capabilities struct {
+	Identity identity.Capabilities `json:"identity"`
+	// Education is true if the education resources are available
+	Education bool `json:"education"`
+}
+
+// GetCapabilities returns which operations of the users, groups and education endpoints are supported, so that
+// clients can hide the ones the identity backend doesn't support.
+func (g Graph) GetCapabilities(w http.ResponseWriter, r *http.Request) {
+	render.Status(r, http.StatusOK)
+	render.JSON(w, r, capabilities{
+		Identity:  g.identityBackend.Capabilities(r.Context()),
+		Education: g.identityEducationBackend != nil,
+	})
+}
@@ -0,0 +1,271 @@
+package svc
+
+import (
+	"context"
+	"hash/fnv"
+	"net/http"
+	"net/url"
+	"path"
+	"sort"
+	"strconv"
+
+	cs3rpc "github.com/cs3org/go-cs3apis/cs3/rpc/v1beta1"
+	storageprovider "github.com/cs3org/go-cs3apis/cs3/storage/provider/v1beta1"
+	types "github.com/cs3org/go-cs3apis/cs3/types/v1beta1"
+	"github.com/cs3org/reva/v2/pkg/utils/resourceid"
+	"github.com/go-chi/render"
+	libregraph "github.com/owncloud/libre-graph-api-go"
+	"github.com/owncloud/ocis/extensions/graph/pkg/service/v0/delta"
+	"github.com/owncloud/ocis/extensions/graph/pkg/service/v0/errorcode"
+)
+
+// removedDrive is returned by a delta query for a drive which no longer exists or is no longer accessible.
+type removedDrive struct {
+	Id      string       `json:"id"`
+	Removed removedFacet `json:"@removed"`
+}
+
+type removedFacet struct {
+	Reason string `json:"reason"`
+}
+
+// GetDriveItemsDelta returns the items of a drive which changed since the token of a previous delta query, or all
+// items of the drive without a token.
+//
+// The storage providers propagate the tree modification time up to the root, so only folders modified after the
+// token have to be listed. Moving or renaming an item only modifies its parent folders, that's why all children of
+// a modified folder are returned. Deleted items are read from the trash of the drive.
+func (g Graph) GetDriveItemsDelta(w http.ResponseWriter, r *http.Request) {
+	root, err := driveRoot(r)
+	if err != nil {
+		renderError(w, r, err)
+		return
+	}
+	token, err := delta.Parse(r.URL.Query())
+	if err != nil {
+		errorcode.InvalidRequest.Render(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+	g.logger.Info().Interface("root", root).Msg("Calling GetDriveItemsDelta")
+	ctx := r.Context()
+
+	info, err := g.statResource(ctx, &storageprovider.Reference{ResourceId: root})
+	if err != nil {
+		renderError(w, r, err)
+		return
+	}
+
+	items := []*libregraph.DriveItem{}
+	if modifiedSince(info, token.Since) {
+		if items, err = g.modifiedDriveItems(ctx, info, token.Since); err != nil {
+			renderError(w, r, err)
+			return
+		}
+	}
+	if token.Since > 0 {
+		deleted, err := g.deletedDriveItems(ctx, root, token.Since)
+		if err != nil {
+			renderError(w, r, err)
+			return
+		}
+		items = append(items, deleted...)
+	}
+
+	next := delta.Token{Since: token.Since}
+	if mtime := treeMtime(info); mtime > next.Since {
+		next.Since = mtime
+	}
+	render.Status(r, http.StatusOK)
+	render.JSON(w, r, &listResponse{Value: items, DeltaLink: g.deltaLink(r, next)})
+}
+
+// GetDrivesDelta returns the drives of the user which were created or changed since the token of a previous delta
+// query and the drives which were removed, or all drives without a token. Changes to the content of the drives are
+// not tracked, they are returned by the delta query of the items of a drive.
+//
+// The token holds a fingerprint of every drive returned by the previous query.
+func (g Graph) GetDrivesDelta(w http.ResponseWriter, r *http.Request) {
+	token, err := delta.Parse(r.URL.Query())
+	if err != nil {
+		errorcode.InvalidRequest.Render(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+	g.logger.Info().Msg("Calling GetDrivesDelta")
+	ctx := r.Context()
+
+	res, err := g.ListStorageSpacesWithFilters(ctx, nil)
+	switch {
+	case err != nil:
+		g.logger.Error().Err(err).Msg(ListStorageSpacesTransportErr)
+		errorcode.GeneralException.Render(w, r, http.StatusInternalServerError, err.Error())
+		return
+	case res.Status.Code != cs3rpc.Code_CODE_OK && res.Status.Code != cs3rpc.Code_CODE_NOT_FOUND:
+		g.logger.Error().Err(err).Msg(ListStorageSpacesReturnsErr)
+		errorcode.GeneralException.Render(w, r, http.StatusInternalServerError, res.Status.Message)
+		return
+	}
+
+	spaces := make(map[string]*storageprovider.StorageSpace, len(res.StorageSpaces))
+	fingerprints := make(map[string]uint32, len(res.StorageSpaces))
+	for _, space := range res.StorageSpaces {
+		if space.Root == nil {
+			continue
+		}
+		id := driveID(space)
+		spaces[id] = space
+		fingerprints[id] = spaceFingerprint(space)
+	}
+	changed, removed := delta.Diff(token.Entries, fingerprints)
+
+	wdu, err := url.Parse(g.config.Spaces.WebDavBase + g.config.Spaces.WebDavPath)
+	if err != nil {
+		g.logger.Error().Err(err).Msg("error parsing url")
+		errorcode.GeneralException.Render(w, r, http.StatusInternalServerError, err.Error())
+		return
+	}
+	changedSpaces := make([]*storageprovider.StorageSpace, 0, len(changed))
+	for _, id := range changed {
+		changedSpaces = append(changedSpaces, spaces[id])
+	}
+	drives, err := g.formatDrives(ctx, wdu, changedSpaces)
+	if err != nil {
+		g.logger.Error().Err(err).Msg("error encoding response as json")
+		errorcode.GeneralException.Render(w, r, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	value := make([]interface{}, 0, len(drives)+len(removed))
+	for _, drive := range drives {
+		value = append(value, drive)
+	}
+	for _, id := range removed {
+		value = append(value, removedDrive{Id: id, Removed: removedFacet{Reason: "deleted"}})
+	}
+	render.Status(r, http.StatusOK)
+	render.JSON(w, r, &listResponse{Value: value, DeltaLink: g.deltaLink(r, delta.Token{Entries: fingerprints})})
+}
+
+// modifiedDriveItems returns the root and all children of the folders modified after since.
+func (g Graph) modifiedDriveItems(ctx context.Context, root *storageprovider.ResourceInfo, since int64) ([]*libregraph.DriveItem, error) {
+	item, err := cs3ResourceToDriveItem(root)
+	if err != nil {
+		return nil, err
+	}
+	items := []*libregraph.DriveItem{item}
+
+	folders := []*storageprovider.ResourceInfo{root}
+	for len(folders) > 0 {
+		folder := folders[0]
+		folders = folders[1:]
+
+		res, err := g.GetGatewayClient().ListContainer(ctx, &storageprovider.ListContainerRequest{
+			Ref: &storageprovider.Reference{ResourceId: folder.Id},
+		})
+		switch {
+		case err != nil:
+			g.logger.Error().Err(err).Msg("error sending list container grpc request")
+			return nil, errorcode.New(errorcode.ServiceNotAvailable, err.Error())
+		case res.Status.Code == cs3rpc.Code_CODE_NOT_FOUND:
+			// the folder was deleted in the meantime, it is returned by the next query
+			continue
+		case res.Status.Code != cs3rpc.Code_CODE_OK:
+			return nil, cs3StatusError(res.Status)
+		}
+
+		children := res.Infos
+		sort.Slice(children, func(i, j int) bool {
+			return path.Base(children[i].Path) < path.Base(children[j].Path)
+		})
+		for _, child := range children {
+			item, err := cs3ResourceToDriveItem(child)
+			if err != nil {
+				return nil, err
+			}
+			item.ParentReference = parentReference(folder.Id)
+			items = append(items, item)
+
+			if child.Type == storageprovider.ResourceType_RESOURCE_TYPE_CONTAINER && modifiedSince(child, since) {
+				folders = append(folders, child)
+			}
+		}
+	}
+	return items, nil
+}
+
+// deletedDriveItems returns the items moved to the trash of the drive after since. Storage providers without a
+// trash don't report deleted items.
+func (g Graph) deletedDriveItems(ctx context.Context, root *storageprovider.ResourceId, since int64) ([]*libregraph.DriveItem, error) {
+	res, err := g.GetGatewayClient().ListRecycle(ctx, &storageprovider.ListRecycleRequest{
+		Ref:    &storageprovider.Reference{ResourceId: root, Path: "."},
+		FromTs: &types.Timestamp{Seconds: uint64(since / 1e9), Nanos: uint32(since % 1e9)},
+	})
+	switch {
+	case err != nil:
+		g.logger.Error().Err(err).Msg("error sending list recycle grpc request")
+		return nil, errorcode.New(errorcode.ServiceNotAvailable, err.Error())
+	case res.Status.Code == cs3rpc.Code_CODE_UNIMPLEMENTED:
+		return nil, nil
+	case res.Status.Code != cs3rpc.Code_CODE_OK:
+		return nil, cs3StatusError(res.Status)
+	}
+
+	items := make([]*libregraph.DriveItem, 0, len(res.RecycleItems))
+	for _, ri := range res.RecycleItems {
+		if ri.DeletionTime == nil || timestampNanos(ri.DeletionTime) <= since {
+			continue
+		}
+		// the key of a trashed item is the id it had in the drive
+		item := &libregraph.DriveItem{
+			Id:      libregraph.PtrString(resourceid.OwnCloudResourceIDWrap(&storageprovider.ResourceId{StorageId: root.StorageId, OpaqueId: ri.Key})),
+			Deleted: &libregraph.Deleted{State: libregraph.PtrString("deleted")},
+		}
+		if ri.Ref != nil {
+			item.Name = libregraph.PtrString(path.Base(ri.Ref.Path))
+		}
+		items = append(items, item)
+	}
+	return items, nil
+}
+
+// deltaLink returns the link continuing the delta query of the request from the token.
+func (g Graph) deltaLink(r *http.Request, token delta.Token) string {
+	// the graph service is only reachable through the proxy, so the link is based on the public ocis url
+	return delta.Link(g.config.Spaces.WebDavBase, r.URL.Path, r.URL.Query(), token.Encode())
+}
+
+// modifiedSince reports if the tree of the resource was modified after since.
+func modifiedSince(info *storageprovider.ResourceInfo, since int64) bool {
+	return since == 0 || treeMtime(info) > since
+}
+
+// treeMtime returns the tree modification time of the resource in nanoseconds since the epoch.
+func treeMtime(info *storageprovider.ResourceInfo) int64 {
+	if info.Mtime == nil {
+		return 0
+	}
+	return timestampNanos(info.Mtime)
+}
+
+func timestampNanos(t *types.Timestamp) int64 {
+	return cs3TimestampToTime(t).UnixNano()
+}
+
+// spaceFingerprint returns a hash of the properties of a space a delta query reports changes of.
+func spaceFingerprint(space *storageprovider.StorageSpace) uint32 {
+	h := fnv.New32a()
+	write := func(s string) {
+		_, _ = h.Write([]byte(s))
+		_, _ = h.Write([]byte{0})
+	}
+	write(space.Name)
+	write(space.SpaceType)
+	if space.Quota != nil {
+		write(strconv.FormatUint(space.Quota.QuotaMaxBytes, 10))
+	}
+	for _, key := range []string{"trashed", "description", "spaceAlias", SpaceImageSpecialFolderName, ReadmeSpecialFolderName} {
+		if space.Opaque != nil && space.Opaque.Map[key] != nil {
+			write(key + "=" + string(space.Opaque.Map[key].Value))
+		}
+	}
+	return h.Sum32()
+}
@@ -0,0 +1,105 @@
+// Package delta implements the tokens of the delta queries, which return the changes of a collection since a
+// previous query.
+package delta
+
+import (
+	"bytes"
+	"compress/flate"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/url"
+	"sort"
+)
+
+// TokenParam is the query option holding the token of a previous delta query.
+const TokenParam = "token"
+
+// maxTokenSize limits the size of a decompressed token.
+const maxTokenSize = 1 << 20
+
+var errInvalidToken = errors.New("invalid delta token")
+
+// Token is the decoded form of a delta token. Queries tracking a tree store the tree modification time of its root,
+// queries tracking a set of entries store a fingerprint of every entry. The state is kept in the token only, so a
+// query can be continued by any instance of the service.
+type Token struct {
+	// Since is the tree modification time in nanoseconds since the epoch.
+	Since int64 `json:"s,omitempty"`
+	// Entries maps the ids of the entries to their fingerprints.
+	Entries map[string]uint32 `json:"e,omitempty"`
+}
+
+// Encode returns the opaque string representation of the token. It is compressed, the ids of the entries mostly
+// share long prefixes.
+func (t Token) Encode() string {
+	b, _ := json.Marshal(t)
+	var buf bytes.Buffer
+	w, _ := flate.NewWriter(&buf, flate.BestCompression)
+	_, _ = w.Write(b)
+	_ = w.Close()
+	return base64.RawURLEncoding.EncodeToString(buf.Bytes())
+}
+
+// DecodeToken parses a token created by Token.Encode.
+func DecodeToken(s string) (Token, error) {
+	t := Token{}
+	compressed, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return t, errInvalidToken
+	}
+	b, err := io.ReadAll(io.LimitReader(flate.NewReader(bytes.NewReader(compressed)), maxTokenSize+1))
+	if err != nil || len(b) > maxTokenSize {
+		return t, errInvalidToken
+	}
+	if err := json.Unmarshal(b, &t); err != nil || t.Since < 0 {
+		return t, errInvalidToken
+	}
+	return t, nil
+}
+
+// Parse reads the token from the query. Without a token the zero value is returned, which makes a delta query
+// return the whole collection.
+func Parse(query url.Values) (Token, error) {
+	if v := query.Get(TokenParam); v != "" {
+		return DecodeToken(v)
+	}
+	return Token{}, nil
+}
+
+// Diff compares the previous entries with the current ones. The entries map ids to fingerprints. It returns the ids
+// of the entries which are new or have a different fingerprint and the ids of the entries which no longer exist, both
+// sorted.
+func Diff(previous, entries map[string]uint32) (changed []string, removed []string) {
+	for id, fp := range entries {
+		if old, ok := previous[id]; !ok || old != fp {
+			changed = append(changed, id)
+		}
+	}
+	for id := range previous {
+		if _, ok := entries[id]; !ok {
+			removed = append(removed, id)
+		}
+	}
+	sort.Strings(changed)
+	sort.Strings(removed)
+	return changed, removed
+}
+
+// Link returns the link continuing the delta query of the collection at base from the token.
+func Link(base string, path string, query url.Values, token string) string {
+	q := make(url.Values, len(query))
+	for k, v := range query {
+		if k != TokenParam {
+			q[k] = v
+		}
+	}
+	q.Set(TokenParam, token)
+
+	u := url.URL{Path: path, RawQuery: q.Encode()}
+	if b, err := url.Parse(base); err == nil {
+		u.Scheme, u.Host = b.Scheme, b.Host
+	}
+	return u.String()
+}
@@ -0,0 +1,89 @@
+package delta
+
+import (
+	"fmt"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	token := Token{Since: 42, Entries: map[string]uint32{"a": 1}}
+	tests := []struct {
+		query string
+		token Token
+		err   bool
+	}{
+		{query: "", token: Token{}},
+		{query: "token=" + token.Encode(), token: token},
+		{query: "token=invalid", err: true},
+		{query: "token=" + Token{Since: -1}.Encode(), err: true},
+	}
+
+	for _, tt := range tests {
+		q, _ := url.ParseQuery(tt.query)
+		tok, err := Parse(q)
+		if tt.err {
+			if err == nil {
+				t.Errorf("%s: expected an error", tt.query)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: unexpected error %v", tt.query, err)
+		}
+		if !reflect.DeepEqual(tok, tt.token) {
+			t.Errorf("%s: expected %+v got %+v", tt.query, tt.token, tok)
+		}
+	}
+}
+
+func TestDiff(t *testing.T) {
+	previous := map[string]uint32{"a": 1, "b": 2, "c": 3}
+	changed, removed := Diff(previous, map[string]uint32{"a": 1, "b": 5, "d": 4})
+	if !reflect.DeepEqual(changed, []string{"b", "d"}) {
+		t.Errorf("unexpected changed entries %v", changed)
+	}
+	if !reflect.DeepEqual(removed, []string{"c"}) {
+		t.Errorf("unexpected removed entries %v", removed)
+	}
+
+	changed, removed = Diff(nil, map[string]uint32{"a": 1})
+	if !reflect.DeepEqual(changed, []string{"a"}) || len(removed) != 0 {
+		t.Errorf("unexpected diff %v %v", changed, removed)
+	}
+}
+
+func TestTokenSize(t *testing.T) {
+	entries := make(map[string]uint32, 1000)
+	for i := 0; i < 1000; i++ {
+		entries[fmt.Sprintf("1284d238-aa92-42ce-bdc4-0b0000009157$%08d-aa92-42ce-bdc4-0b0000009157", i)] = uint32(i)
+	}
+	token := Token{Entries: entries}
+	encoded := token.Encode()
+	if len(encoded) > 20000 {
+		t.Errorf("expected a compressed token, got %d bytes", len(encoded))
+	}
+	decoded, err := DecodeToken(encoded)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(decoded, token) {
+		t.Error("expected the decoded token to equal the encoded one")
+	}
+
+	// tokens which decompress to more than maxTokenSize are rejected
+	large := Token{Entries: map[string]uint32{strings.Repeat("a", maxTokenSize): 1}}
+	if _, err := DecodeToken(large.Encode()); err == nil {
+		t.Error("expected an error for a large token")
+	}
+}
+
+func TestLink(t *testing.T) {
+	q, _ := url.ParseQuery("token=old&$select=name")
+	link := Link("https://localhost:9200", "/graph/v1.0/me/drives/delta", q, "new")
+	if link != "https://localhost:9200/graph/v1.0/me/drives/delta?%24select=name&token=new" {
+		t.Errorf("unexpected link %s", link)
+	}
+}
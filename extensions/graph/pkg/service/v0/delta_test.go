@@ -0,0 +1,210 @@
+package svc_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+
+	cs3rpc "github.com/cs3org/go-cs3apis/cs3/rpc/v1beta1"
+	provider "github.com/cs3org/go-cs3apis/cs3/storage/provider/v1beta1"
+	types "github.com/cs3org/go-cs3apis/cs3/types/v1beta1"
+	"github.com/cs3org/reva/v2/pkg/rgrpc/status"
+	"github.com/go-chi/chi/v5"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/owncloud/ocis/extensions/graph/mocks"
+	"github.com/owncloud/ocis/extensions/graph/pkg/config/defaults"
+	service "github.com/owncloud/ocis/extensions/graph/pkg/service/v0"
+	"github.com/owncloud/ocis/extensions/graph/pkg/service/v0/delta"
+	"github.com/stretchr/testify/mock"
+)
+
+var _ = Describe("Delta", func() {
+	var (
+		svc           service.Service
+		gatewayClient *mocks.GatewayClient
+		ctx           context.Context
+
+		root = &provider.ResourceInfo{
+			Id:    &provider.ResourceId{StorageId: "sID", OpaqueId: "sID"},
+			Type:  provider.ResourceType_RESOURCE_TYPE_CONTAINER,
+			Path:  ".",
+			Mtime: &types.Timestamp{Seconds: 300},
+		}
+		changed = &provider.ResourceInfo{
+			Id:    &provider.ResourceId{StorageId: "sID", OpaqueId: "changedID"},
+			Type:  provider.ResourceType_RESOURCE_TYPE_CONTAINER,
+			Path:  "changed",
+			Mtime: &types.Timestamp{Seconds: 300},
+		}
+		unchanged = &provider.ResourceInfo{
+			Id:    &provider.ResourceId{StorageId: "sID", OpaqueId: "unchangedID"},
+			Type:  provider.ResourceType_RESOURCE_TYPE_CONTAINER,
+			Path:  "unchanged",
+			Mtime: &types.Timestamp{Seconds: 100},
+		}
+		file = &provider.ResourceInfo{
+			Id:    &provider.ResourceId{StorageId: "sID", OpaqueId: "fileID"},
+			Type:  provider.ResourceType_RESOURCE_TYPE_FILE,
+			Path:  "file.txt",
+			Mtime: &types.Timestamp{Seconds: 300},
+		}
+	)
+
+	type deltaResponse struct {
+		Value []struct {
+			Id              string
+			Name            string
+			ParentReference *struct{ Id string }
+			Deleted         *struct{ State string }
+			Removed         *struct{ Reason string } `json:"@removed"`
+		}
+		DeltaLink string `json:"@odata.deltaLink"`
+	}
+
+	// get calls the handler with the token of the link and returns the response.
+	get := func(handler http.HandlerFunc, target string, link string) deltaResponse {
+		if link != "" {
+			u, err := url.Parse(link)
+			Expect(err).ToNot(HaveOccurred())
+			target += "?" + u.RawQuery
+		}
+		r := httptest.NewRequest(http.MethodGet, target, nil)
+		rctx := chi.NewRouteContext()
+		rctx.URLParams.Add("driveID", "sID")
+		r = r.WithContext(context.WithValue(r.Context(), chi.RouteCtxKey, rctx))
+
+		rr := httptest.NewRecorder()
+		handler(rr, r)
+		Expect(rr.Code).To(Equal(http.StatusOK))
+		res := deltaResponse{}
+		err := json.Unmarshal(rr.Body.Bytes(), &res)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(res.DeltaLink).To(HavePrefix("https://localhost:9200" + r.URL.Path + "?token="))
+		return res
+	}
+	listRef := func(id string) interface{} {
+		return mock.MatchedBy(func(req *provider.ListContainerRequest) bool { return req.Ref.ResourceId.OpaqueId == id })
+	}
+
+	JustBeforeEach(func() {
+		ctx = context.Background()
+		gatewayClient = &mocks.GatewayClient{}
+		svc = service.NewService(
+			service.Config(defaults.DefaultConfig()),
+			service.WithGatewayClient(gatewayClient),
+			service.WithIdentityBackend(&mocks.Backend{}),
+		)
+	})
+
+	It("returns the items changed since the last query", func() {
+		gatewayClient.On("Stat", mock.Anything, mock.Anything).Return(&provider.StatResponse{
+			Status: status.NewOK(ctx),
+			Info:   root,
+		}, nil)
+		gatewayClient.On("ListContainer", mock.Anything, listRef("sID")).Return(&provider.ListContainerResponse{
+			Status: status.NewOK(ctx),
+			Infos:  []*provider.ResourceInfo{unchanged, changed},
+		}, nil)
+		gatewayClient.On("ListContainer", mock.Anything, listRef("changedID")).Return(&provider.ListContainerResponse{
+			Status: status.NewOK(ctx),
+			Infos:  []*provider.ResourceInfo{file},
+		}, nil)
+		gatewayClient.On("ListContainer", mock.Anything, listRef("unchangedID")).Return(&provider.ListContainerResponse{
+			Status: status.NewOK(ctx),
+		}, nil)
+		gatewayClient.On("ListRecycle", mock.Anything, mock.Anything).Return(&provider.ListRecycleResponse{
+			Status: status.NewOK(ctx),
+			RecycleItems: []*provider.RecycleItem{
+				{Key: "oldID", Ref: &provider.Reference{Path: "old.txt"}, DeletionTime: &types.Timestamp{Seconds: 50}},
+				{Key: "deletedID", Ref: &provider.Reference{Path: "deleted.txt"}, DeletionTime: &types.Timestamp{Seconds: 250}},
+			},
+		}, nil)
+
+		// the first query returns all items
+		res := get(svc.(service.Graph).GetDriveItemsDelta, "/graph/v1.0/drives/sID/root/delta", "")
+		Expect(len(res.Value)).To(Equal(4))
+		Expect(res.Value[0].Id).To(Equal("sID!sID"))
+		Expect(res.Value[1].Name).To(Equal("changed"))
+		Expect(res.Value[2].Name).To(Equal("unchanged"))
+		Expect(res.Value[3].Name).To(Equal("file.txt"))
+		Expect(res.Value[3].ParentReference.Id).To(Equal("sID!changedID"))
+		gatewayClient.AssertNumberOfCalls(GinkgoT(), "ListContainer", 3)
+		gatewayClient.AssertNotCalled(GinkgoT(), "ListRecycle", mock.Anything, mock.Anything)
+
+		// nothing changed since the first query
+		res = get(svc.(service.Graph).GetDriveItemsDelta, "/graph/v1.0/drives/sID/root/delta", res.DeltaLink)
+		Expect(len(res.Value)).To(Equal(0))
+		gatewayClient.AssertNumberOfCalls(GinkgoT(), "ListContainer", 3)
+
+		// only folders modified since an older query are listed
+		link := "/graph/v1.0/drives/sID/root/delta?token=" + delta.Token{Since: 200 * 1e9}.Encode()
+		res = get(svc.(service.Graph).GetDriveItemsDelta, "/graph/v1.0/drives/sID/root/delta", link)
+		Expect(len(res.Value)).To(Equal(5))
+		Expect(res.Value[4].Id).To(Equal("sID!deletedID"))
+		Expect(res.Value[4].Deleted.State).To(Equal("deleted"))
+		gatewayClient.AssertNumberOfCalls(GinkgoT(), "ListContainer", 5)
+	})
+
+	It("rejects invalid tokens", func() {
+		r := httptest.NewRequest(http.MethodGet, "/graph/v1.0/me/drives/delta?token=invalid", nil)
+		rr := httptest.NewRecorder()
+		svc.(service.Graph).GetDrivesDelta(rr, r)
+		Expect(rr.Code).To(Equal(http.StatusBadRequest))
+	})
+
+	It("returns the drives changed since the last query", func() {
+		project := &provider.StorageSpace{
+			Id:        &provider.StorageSpaceId{OpaqueId: "pID"},
+			Root:      &provider.ResourceId{StorageId: "pID", OpaqueId: "pID"},
+			Name:      "Project",
+			SpaceType: "project",
+		}
+		personal := &provider.StorageSpace{
+			Id:        &provider.StorageSpaceId{OpaqueId: "sID"},
+			Root:      &provider.ResourceId{StorageId: "sID", OpaqueId: "sID"},
+			Name:      "Einstein",
+			SpaceType: "personal",
+		}
+		// the project is renamed and disabled after the second query, the personal space is gone
+		renamed := *project
+		renamed.Name = "Renamed"
+		renamed.Opaque = &types.Opaque{Map: map[string]*types.OpaqueEntry{
+			"trashed": {Decoder: "plain", Value: []byte("trashed")},
+		}}
+		gatewayClient.On("ListStorageSpaces", mock.Anything, mock.Anything).Return(&provider.ListStorageSpacesResponse{
+			Status:        status.NewOK(ctx),
+			StorageSpaces: []*provider.StorageSpace{personal, project},
+		}, nil).Twice()
+		gatewayClient.On("ListStorageSpaces", mock.Anything, mock.Anything).Return(&provider.ListStorageSpacesResponse{
+			Status:        status.NewOK(ctx),
+			StorageSpaces: []*provider.StorageSpace{&renamed},
+		}, nil)
+		gatewayClient.On("GetQuota", mock.Anything, mock.Anything).Return(&provider.GetQuotaResponse{
+			Status: &cs3rpc.Status{Code: cs3rpc.Code_CODE_UNIMPLEMENTED},
+		}, nil)
+
+		res := get(svc.(service.Graph).GetDrivesDelta, "/graph/v1.0/me/drives/delta", "")
+		Expect(len(res.Value)).To(Equal(2))
+		Expect(res.Value[0].Name).To(Equal("Project"))
+		Expect(res.Value[1].Name).To(Equal("Einstein"))
+
+		// the token holds the fingerprints of the drives, so any instance can continue the query
+		u, err := url.Parse(res.DeltaLink)
+		Expect(err).ToNot(HaveOccurred())
+		token, err := delta.DecodeToken(u.Query().Get("token"))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(token.Entries).To(HaveLen(2))
+
+		res = get(svc.(service.Graph).GetDrivesDelta, "/graph/v1.0/me/drives/delta", res.DeltaLink)
+		Expect(len(res.Value)).To(Equal(0))
+
+		res = get(svc.(service.Graph).GetDrivesDelta, "/graph/v1.0/me/drives/delta", res.DeltaLink)
+		Expect(len(res.Value)).To(Equal(2))
+		Expect(res.Value[0].Name).To(Equal("Renamed"))
+		Expect(res.Value[1].Id).To(Equal("sID"))
+		Expect(res.Value[1].Removed.Reason).To(Equal("deleted"))
+	})
+})
@@ -2,20 +2,28 @@ package svc
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"net/http"
 	"net/url"
 	"path"
+	"sort"
+	"strconv"
+	"strings"
 	"time"
 
 	cs3rpc "github.com/cs3org/go-cs3apis/cs3/rpc/v1beta1"
 	storageprovider "github.com/cs3org/go-cs3apis/cs3/storage/provider/v1beta1"
 	types "github.com/cs3org/go-cs3apis/cs3/types/v1beta1"
+	"github.com/cs3org/reva/v2/pkg/utils"
 	"github.com/cs3org/reva/v2/pkg/utils/resourceid"
+	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/render"
 	libregraph "github.com/owncloud/libre-graph-api-go"
 	"github.com/owncloud/ocis/extensions/graph/pkg/service/v0/errorcode"
+	"github.com/owncloud/ocis/extensions/graph/pkg/service/v0/net"
+	"github.com/owncloud/ocis/extensions/graph/pkg/service/v0/paging"
 )
 
 // GetRootDriveChildren implements the Service interface.
@@ -251,3 +259,551 @@ func (g Graph) getSpecialDriveItem(ctx context.Context, ID *storageprovider.Reso
 
 	return spaceItem
 }
+
+// GetDriveItem returns an item of a drive.
+func (g Graph) GetDriveItem(w http.ResponseWriter, r *http.Request) {
+	ref, err := driveItemRef(r)
+	if err != nil {
+		renderError(w, r, err)
+		return
+	}
+	g.logger.Info().Interface("ref", ref).Msg("Calling GetDriveItem")
+
+	item, err := g.statDriveItem(r.Context(), ref)
+	if err != nil {
+		renderError(w, r, err)
+		return
+	}
+	render.Status(r, http.StatusOK)
+	render.JSON(w, r, item)
+}
+
+// GetDriveItemChildren lists the children of a folder of a drive ordered by name.
+func (g Graph) GetDriveItemChildren(w http.ResponseWriter, r *http.Request) {
+	ref, err := driveItemRef(r)
+	if err != nil {
+		renderError(w, r, err)
+		return
+	}
+	page, err := g.parsePage(r)
+	if err != nil {
+		errorcode.InvalidRequest.Render(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+	g.logger.Info().Interface("ref", ref).Msg("Calling GetDriveItemChildren")
+
+	res, err := g.GetGatewayClient().ListContainer(r.Context(), &storageprovider.ListContainerRequest{Ref: ref})
+	switch {
+	case err != nil:
+		g.logger.Error().Err(err).Msg("error sending list container grpc request")
+		errorcode.ServiceNotAvailable.Render(w, r, http.StatusInternalServerError, err.Error())
+		return
+	case res.Status.Code != cs3rpc.Code_CODE_OK:
+		renderError(w, r, cs3StatusError(res.Status))
+		return
+	}
+
+	infos := res.Infos
+	sort.Slice(infos, func(i, j int) bool {
+		return path.Base(infos[i].Path) < path.Base(infos[j].Path)
+	})
+	start, end, info, err := paging.Slice(len(infos), page)
+	if err != nil {
+		errorcode.InvalidRequest.Render(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+	items, err := formatDriveItems(infos[start:end])
+	if err != nil {
+		g.logger.Error().Err(err).Msg("error encoding response as json")
+		errorcode.GeneralException.Render(w, r, http.StatusInternalServerError, err.Error())
+		return
+	}
+	g.renderPage(w, r, items, info)
+}
+
+// CreateDriveItem creates a folder in a folder of a drive.
+func (g Graph) CreateDriveItem(w http.ResponseWriter, r *http.Request) {
+	parent, err := driveItemRef(r)
+	if err != nil {
+		renderError(w, r, err)
+		return
+	}
+
+	item := libregraph.DriveItem{}
+	if err := json.NewDecoder(r.Body).Decode(&item); err != nil {
+		errorcode.InvalidRequest.Render(w, r, http.StatusBadRequest, fmt.Sprintf("invalid request body: %s", err.Error()))
+		return
+	}
+	if item.Folder == nil {
+		errorcode.NotSupported.Render(w, r, http.StatusNotImplemented, "only folders can be created, files have to be uploaded")
+		return
+	}
+	ref, err := childRef(parent, item.GetName())
+	if err != nil {
+		renderError(w, r, err)
+		return
+	}
+	g.logger.Info().Interface("ref", ref).Msg("Calling CreateDriveItem")
+
+	res, err := g.GetGatewayClient().CreateContainer(r.Context(), &storageprovider.CreateContainerRequest{Ref: ref})
+	switch {
+	case err != nil:
+		g.logger.Error().Err(err).Msg("error sending create container grpc request")
+		errorcode.ServiceNotAvailable.Render(w, r, http.StatusInternalServerError, err.Error())
+		return
+	case res.Status.Code != cs3rpc.Code_CODE_OK:
+		renderError(w, r, cs3StatusError(res.Status))
+		return
+	}
+
+	created, err := g.statDriveItem(r.Context(), ref)
+	if err != nil {
+		renderError(w, r, err)
+		return
+	}
+	render.Status(r, http.StatusCreated)
+	render.JSON(w, r, created)
+}
+
+// UpdateDriveItem renames an item or moves it to another folder of the same drive.
+func (g Graph) UpdateDriveItem(w http.ResponseWriter, r *http.Request) {
+	ref, err := driveItemRef(r)
+	if err != nil {
+		renderError(w, r, err)
+		return
+	}
+
+	patch := libregraph.DriveItem{}
+	if err := json.NewDecoder(r.Body).Decode(&patch); err != nil {
+		errorcode.InvalidRequest.Render(w, r, http.StatusBadRequest, fmt.Sprintf("invalid request body: %s", err.Error()))
+		return
+	}
+	g.logger.Info().Interface("ref", ref).Msg("Calling UpdateDriveItem")
+
+	ctx := r.Context()
+	info, err := g.statResource(ctx, ref)
+	if err != nil {
+		renderError(w, r, err)
+		return
+	}
+	if info.GetParentId() == nil {
+		errorcode.InvalidRequest.Render(w, r, http.StatusBadRequest, "the root of a drive can not be moved")
+		return
+	}
+
+	parent := &storageprovider.Reference{ResourceId: info.ParentId}
+	if patch.ParentReference != nil && patch.ParentReference.GetId() != "" {
+		if parent, err = itemRef(ref.ResourceId.StorageId, patch.ParentReference.GetId()); err != nil {
+			renderError(w, r, err)
+			return
+		}
+	}
+	name := path.Base(info.Path)
+	if patch.Name != nil {
+		name = patch.GetName()
+	}
+	dst, err := childRef(parent, name)
+	if err != nil {
+		renderError(w, r, err)
+		return
+	}
+
+	res, err := g.GetGatewayClient().Move(ctx, &storageprovider.MoveRequest{
+		Source:      &storageprovider.Reference{ResourceId: info.Id},
+		Destination: dst,
+	})
+	switch {
+	case err != nil:
+		g.logger.Error().Err(err).Msg("error sending move grpc request")
+		errorcode.ServiceNotAvailable.Render(w, r, http.StatusInternalServerError, err.Error())
+		return
+	case res.Status.Code != cs3rpc.Code_CODE_OK:
+		renderError(w, r, cs3StatusError(res.Status))
+		return
+	}
+
+	moved, err := g.statDriveItem(ctx, dst)
+	if err != nil {
+		renderError(w, r, err)
+		return
+	}
+	render.Status(r, http.StatusOK)
+	render.JSON(w, r, moved)
+}
+
+// DeleteDriveItem moves an item of a drive to the trash.
+func (g Graph) DeleteDriveItem(w http.ResponseWriter, r *http.Request) {
+	ref, err := driveItemRef(r)
+	if err != nil {
+		renderError(w, r, err)
+		return
+	}
+	if root, _ := driveRoot(r); utils.ResourceIDEqual(root, ref.ResourceId) {
+		errorcode.InvalidRequest.Render(w, r, http.StatusBadRequest, "the root of a drive can not be deleted, delete the drive instead")
+		return
+	}
+	g.logger.Info().Interface("ref", ref).Msg("Calling DeleteDriveItem")
+
+	res, err := g.GetGatewayClient().Delete(r.Context(), &storageprovider.DeleteRequest{Ref: ref})
+	switch {
+	case err != nil:
+		g.logger.Error().Err(err).Msg("error sending delete grpc request")
+		errorcode.ServiceNotAvailable.Render(w, r, http.StatusInternalServerError, err.Error())
+		return
+	case res.Status.Code != cs3rpc.Code_CODE_OK:
+		renderError(w, r, cs3StatusError(res.Status))
+		return
+	}
+	render.Status(r, http.StatusNoContent)
+	render.NoContent(w, r)
+}
+
+// CopyDriveItem copies an item, including all children of a folder, into a folder of the same drive. The copy is
+// done before the response is sent, so the response contains the new item.
+func (g Graph) CopyDriveItem(w http.ResponseWriter, r *http.Request) {
+	ref, err := driveItemRef(r)
+	if err != nil {
+		renderError(w, r, err)
+		return
+	}
+
+	target := libregraph.DriveItem{}
+	if err := json.NewDecoder(r.Body).Decode(&target); err != nil {
+		errorcode.InvalidRequest.Render(w, r, http.StatusBadRequest, fmt.Sprintf("invalid request body: %s", err.Error()))
+		return
+	}
+	g.logger.Info().Interface("ref", ref).Msg("Calling CopyDriveItem")
+
+	ctx := r.Context()
+	info, err := g.statResource(ctx, ref)
+	if err != nil {
+		renderError(w, r, err)
+		return
+	}
+
+	parent := &storageprovider.Reference{ResourceId: info.GetParentId()}
+	if target.ParentReference != nil && target.ParentReference.GetId() != "" {
+		if parent, err = itemRef(ref.ResourceId.StorageId, target.ParentReference.GetId()); err != nil {
+			renderError(w, r, err)
+			return
+		}
+	}
+	if parent.ResourceId == nil {
+		errorcode.InvalidRequest.Render(w, r, http.StatusBadRequest, "missing parentReference of the copy")
+		return
+	}
+	name := path.Base(info.Path)
+	if target.Name != nil {
+		name = target.GetName()
+	}
+	dst, err := childRef(parent, name)
+	if err != nil {
+		renderError(w, r, err)
+		return
+	}
+
+	if _, err := g.statResource(ctx, dst); err == nil {
+		errorcode.NameAlreadyExists.Render(w, r, http.StatusConflict, fmt.Sprintf("an item named '%s' already exists", name))
+		return
+	}
+	if info.Type == storageprovider.ResourceType_RESOURCE_TYPE_CONTAINER {
+		root, _ := driveRoot(r)
+		inside, err := g.isAncestor(ctx, info.Id, parent.ResourceId, root)
+		if err != nil {
+			renderError(w, r, err)
+			return
+		}
+		if inside {
+			errorcode.InvalidRequest.Render(w, r, http.StatusBadRequest, "a folder can not be copied into itself or one of its subfolders")
+			return
+		}
+	}
+	if err := g.copyResource(ctx, info, dst); err != nil {
+		renderError(w, r, err)
+		return
+	}
+
+	copied, err := g.statDriveItem(ctx, dst)
+	if err != nil {
+		renderError(w, r, err)
+		return
+	}
+	render.Status(r, http.StatusCreated)
+	render.JSON(w, r, copied)
+}
+
+// isAncestor reports if the resource with the id ancestor is the resource with the id or one of its parents. The
+// parents are walked up to the root of the drive.
+func (g Graph) isAncestor(ctx context.Context, ancestor, id, root *storageprovider.ResourceId) (bool, error) {
+	for id != nil {
+		if utils.ResourceIDEqual(id, ancestor) {
+			return true, nil
+		}
+		if utils.ResourceIDEqual(id, root) {
+			return false, nil
+		}
+		info, err := g.statResource(ctx, &storageprovider.Reference{ResourceId: id})
+		if err != nil {
+			return false, err
+		}
+		if utils.ResourceIDEqual(info.ParentId, id) {
+			return false, nil
+		}
+		id = info.ParentId
+	}
+	return false, nil
+}
+
+// copyResource copies a file or a folder with all its children to the destination. When copying the children of a
+// folder fails, the partially copied folder is deleted again.
+func (g Graph) copyResource(ctx context.Context, src *storageprovider.ResourceInfo, dst *storageprovider.Reference) error {
+	if src.Type != storageprovider.ResourceType_RESOURCE_TYPE_CONTAINER {
+		return g.copyFile(ctx, src, dst)
+	}
+	if err := g.createContainer(ctx, dst); err != nil {
+		return err
+	}
+	if err := g.copyChildren(ctx, src, dst); err != nil {
+		res, dErr := g.GetGatewayClient().Delete(ctx, &storageprovider.DeleteRequest{Ref: dst})
+		if dErr == nil && res.Status.Code != cs3rpc.Code_CODE_OK {
+			dErr = cs3StatusError(res.Status)
+		}
+		if dErr != nil {
+			g.logger.Error().Err(dErr).Interface("ref", dst).Msg("could not delete the partial copy")
+		}
+		return err
+	}
+	return nil
+}
+
+// createContainer creates the folder of a copy.
+func (g Graph) createContainer(ctx context.Context, dst *storageprovider.Reference) error {
+	res, err := g.GetGatewayClient().CreateContainer(ctx, &storageprovider.CreateContainerRequest{Ref: dst})
+	switch {
+	case err != nil:
+		return errorcode.New(errorcode.ServiceNotAvailable, err.Error())
+	case res.Status.Code != cs3rpc.Code_CODE_OK:
+		return cs3StatusError(res.Status)
+	}
+	return nil
+}
+
+// copyChildren copies all children of the folder src into the existing folder dst.
+func (g Graph) copyChildren(ctx context.Context, src *storageprovider.ResourceInfo, dst *storageprovider.Reference) error {
+	lRes, err := g.GetGatewayClient().ListContainer(ctx, &storageprovider.ListContainerRequest{
+		Ref: &storageprovider.Reference{ResourceId: src.Id},
+	})
+	switch {
+	case err != nil:
+		return errorcode.New(errorcode.ServiceNotAvailable, err.Error())
+	case lRes.Status.Code != cs3rpc.Code_CODE_OK:
+		return cs3StatusError(lRes.Status)
+	}
+	for _, child := range lRes.Infos {
+		childDst := &storageprovider.Reference{
+			ResourceId: dst.ResourceId,
+			Path:       utils.MakeRelativePath(path.Join(dst.Path, path.Base(child.Path))),
+		}
+		if child.Type != storageprovider.ResourceType_RESOURCE_TYPE_CONTAINER {
+			err = g.copyFile(ctx, child, childDst)
+		} else if err = g.createContainer(ctx, childDst); err == nil {
+			err = g.copyChildren(ctx, child, childDst)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// copyFile copies the content of a file to the destination.
+func (g Graph) copyFile(ctx context.Context, src *storageprovider.ResourceInfo, dst *storageprovider.Reference) error {
+	client := g.GetGatewayClient()
+
+	dRes, err := client.InitiateFileDownload(ctx, &storageprovider.InitiateFileDownloadRequest{
+		Ref: &storageprovider.Reference{ResourceId: src.Id},
+	})
+	switch {
+	case err != nil:
+		return errorcode.New(errorcode.ServiceNotAvailable, err.Error())
+	case dRes.Status.Code != cs3rpc.Code_CODE_OK:
+		return cs3StatusError(dRes.Status)
+	}
+	var downloadEndpoint, downloadToken string
+	for _, p := range dRes.Protocols {
+		if p.Protocol == "spaces" {
+			downloadEndpoint, downloadToken = p.DownloadEndpoint, p.Token
+		}
+	}
+
+	uRes, err := client.InitiateFileUpload(ctx, &storageprovider.InitiateFileUploadRequest{
+		Ref: dst,
+		Opaque: &types.Opaque{Map: map[string]*types.OpaqueEntry{
+			"Upload-Length": {Decoder: "plain", Value: []byte(strconv.FormatUint(src.Size, 10))},
+		}},
+	})
+	switch {
+	case err != nil:
+		return errorcode.New(errorcode.ServiceNotAvailable, err.Error())
+	case uRes.Status.Code != cs3rpc.Code_CODE_OK:
+		return cs3StatusError(uRes.Status)
+	}
+	var uploadEndpoint, uploadToken string
+	for _, p := range uRes.Protocols {
+		if p.Protocol == "simple" {
+			uploadEndpoint, uploadToken = p.UploadEndpoint, p.Token
+		}
+	}
+	if downloadEndpoint == "" || uploadEndpoint == "" {
+		return errorcode.New(errorcode.GeneralException, "the storage provider does not support copying the file")
+	}
+
+	dReq, err := http.NewRequestWithContext(ctx, http.MethodGet, downloadEndpoint, nil)
+	if err != nil {
+		return err
+	}
+	dReq.Header.Set(net.HeaderTokenTransport, downloadToken)
+	download, err := g.GetHTTPClient().Do(dReq)
+	if err != nil {
+		return errorcode.New(errorcode.ServiceNotAvailable, err.Error())
+	}
+	defer download.Body.Close()
+	if download.StatusCode != http.StatusOK {
+		return errorcode.New(errorcode.GeneralException, fmt.Sprintf("downloading the source failed with status %d", download.StatusCode))
+	}
+
+	uReq, err := http.NewRequestWithContext(ctx, http.MethodPut, uploadEndpoint, download.Body)
+	if err != nil {
+		return err
+	}
+	uReq.ContentLength = int64(src.Size)
+	uReq.Header.Set(net.HeaderTokenTransport, uploadToken)
+	upload, err := g.GetHTTPClient().Do(uReq)
+	if err != nil {
+		return errorcode.New(errorcode.ServiceNotAvailable, err.Error())
+	}
+	defer upload.Body.Close()
+	if upload.StatusCode != http.StatusOK && upload.StatusCode != http.StatusCreated && upload.StatusCode != http.StatusNoContent {
+		return errorcode.New(errorcode.GeneralException, fmt.Sprintf("uploading the copy failed with status %d", upload.StatusCode))
+	}
+	return nil
+}
+
+// statResource returns the resource info of a reference.
+func (g Graph) statResource(ctx context.Context, ref *storageprovider.Reference) (*storageprovider.ResourceInfo, error) {
+	res, err := g.GetGatewayClient().Stat(ctx, &storageprovider.StatRequest{Ref: ref})
+	switch {
+	case err != nil:
+		g.logger.Error().Err(err).Msg("error sending stat grpc request")
+		return nil, errorcode.New(errorcode.ServiceNotAvailable, err.Error())
+	case res.Status.Code != cs3rpc.Code_CODE_OK:
+		return nil, cs3StatusError(res.Status)
+	}
+	return res.Info, nil
+}
+
+// statDriveItem returns the drive item of a reference including the reference to its parent.
+func (g Graph) statDriveItem(ctx context.Context, ref *storageprovider.Reference) (*libregraph.DriveItem, error) {
+	info, err := g.statResource(ctx, ref)
+	if err != nil {
+		return nil, err
+	}
+	item, err := cs3ResourceToDriveItem(info)
+	if err != nil {
+		return nil, err
+	}
+	item.ParentReference = parentReference(info.ParentId)
+	return item, nil
+}
+
+// parentReference returns the reference to the parent with the id, it is nil for items without a parent.
+func parentReference(id *storageprovider.ResourceId) *libregraph.ItemReference {
+	if id == nil {
+		return nil
+	}
+	return &libregraph.ItemReference{
+		DriveId: libregraph.PtrString(id.StorageId),
+		Id:      libregraph.PtrString(resourceid.OwnCloudResourceIDWrap(id)),
+	}
+}
+
+// driveItemRef returns the reference of the item addressed by the driveID and itemID url parameters. Without an
+// itemID the root of the drive is addressed.
+func driveItemRef(r *http.Request) (*storageprovider.Reference, error) {
+	root, err := driveRoot(r)
+	if err != nil {
+		return nil, err
+	}
+
+	itemID, err := url.PathUnescape(chi.URLParam(r, "itemID"))
+	if err != nil {
+		return nil, errorcode.New(errorcode.InvalidRequest, "invalid item id")
+	}
+	if itemID == "" {
+		return &storageprovider.Reference{ResourceId: root}, nil
+	}
+	return itemRef(root.StorageId, itemID)
+}
+
+// driveRoot returns the id of the root of the drive addressed by the driveID url parameter.
+func driveRoot(r *http.Request) (*storageprovider.ResourceId, error) {
+	driveID, err := url.PathUnescape(chi.URLParam(r, "driveID"))
+	if err != nil || driveID == "" {
+		return nil, errorcode.New(errorcode.InvalidRequest, "invalid drive id")
+	}
+	if root := resourceid.OwnCloudResourceIDUnwrap(driveID); root != nil {
+		return root, nil
+	}
+	return &storageprovider.ResourceId{StorageId: driveID, OpaqueId: driveID}, nil
+}
+
+// itemRef returns the reference of an item which has to be part of the storage.
+func itemRef(storageID, itemID string) (*storageprovider.Reference, error) {
+	id := resourceid.OwnCloudResourceIDUnwrap(itemID)
+	if id == nil {
+		return nil, errorcode.New(errorcode.InvalidRequest, fmt.Sprintf("invalid item id '%s'", itemID))
+	}
+	if id.StorageId != storageID {
+		return nil, errorcode.New(errorcode.ItemNotFound, fmt.Sprintf("item '%s' not found in the drive", itemID))
+	}
+	return &storageprovider.Reference{ResourceId: id}, nil
+}
+
+// childRef returns the reference of the item with the name in the parent folder.
+func childRef(parent *storageprovider.Reference, name string) (*storageprovider.Reference, error) {
+	if name == "" || name == "." || name == ".." || strings.ContainsAny(name, "/\\") {
+		return nil, errorcode.New(errorcode.InvalidRequest, fmt.Sprintf("invalid name '%s'", name))
+	}
+	return &storageprovider.Reference{
+		ResourceId: parent.ResourceId,
+		Path:       utils.MakeRelativePath(path.Join(parent.Path, name)),
+	}, nil
+}
+
+// cs3StatusError converts a failed cs3 status into an error of the graph api.
+func cs3StatusError(status *cs3rpc.Status) errorcode.Error {
+	switch status.Code {
+	case cs3rpc.Code_CODE_NOT_FOUND:
+		return errorcode.New(errorcode.ItemNotFound, status.Message)
+	case cs3rpc.Code_CODE_PERMISSION_DENIED:
+		// TODO check if we should return 404 to not disclose existing items
+		return errorcode.New(errorcode.AccessDenied, status.Message)
+	case cs3rpc.Code_CODE_ALREADY_EXISTS:
+		return errorcode.New(errorcode.NameAlreadyExists, status.Message)
+	case cs3rpc.Code_CODE_INVALID_ARGUMENT, cs3rpc.Code_CODE_FAILED_PRECONDITION:
+		return errorcode.New(errorcode.InvalidRequest, status.Message)
+	case cs3rpc.Code_CODE_INSUFFICIENT_STORAGE:
+		return errorcode.New(errorcode.QuotaLimitReached, status.Message)
+	}
+	return errorcode.New(errorcode.GeneralException, status.Message)
+}
+
+// renderError renders an error of the graph api, other errors are rendered as general exception.
+func renderError(w http.ResponseWriter, r *http.Request, err error) {
+	var errcode errorcode.Error
+	if errors.As(err, &errcode) {
+		errcode.Render(w, r)
+	} else {
+		errorcode.GeneralException.Render(w, r, http.StatusInternalServerError, err.Error())
+	}
+}
@@ -0,0 +1,266 @@
+package svc_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+
+	gateway "github.com/cs3org/go-cs3apis/cs3/gateway/v1beta1"
+	provider "github.com/cs3org/go-cs3apis/cs3/storage/provider/v1beta1"
+	"github.com/cs3org/reva/v2/pkg/rgrpc/status"
+	"github.com/go-chi/chi/v5"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	libregraph "github.com/owncloud/libre-graph-api-go"
+	"github.com/owncloud/ocis/extensions/graph/mocks"
+	"github.com/owncloud/ocis/extensions/graph/pkg/config/defaults"
+	service "github.com/owncloud/ocis/extensions/graph/pkg/service/v0"
+	"github.com/stretchr/testify/mock"
+)
+
+var _ = Describe("DriveItems", func() {
+	var (
+		svc           service.Service
+		gatewayClient *mocks.GatewayClient
+		httpClient    *mocks.HTTPClient
+		ctx           context.Context
+
+		folder = &provider.ResourceInfo{
+			Id:       &provider.ResourceId{StorageId: "sID", OpaqueId: "folderID"},
+			ParentId: &provider.ResourceId{StorageId: "sID", OpaqueId: "sID"},
+			Type:     provider.ResourceType_RESOURCE_TYPE_CONTAINER,
+			Path:     "folder",
+		}
+		file = &provider.ResourceInfo{
+			Id:       &provider.ResourceId{StorageId: "sID", OpaqueId: "fileID"},
+			ParentId: &provider.ResourceId{StorageId: "sID", OpaqueId: "folderID"},
+			Type:     provider.ResourceType_RESOURCE_TYPE_FILE,
+			Path:     "file.txt",
+			Size:     4,
+		}
+	)
+
+	// request creates a request with the route parameters set.
+	request := func(method, target string, body io.Reader, params map[string]string) *http.Request {
+		r := httptest.NewRequest(method, target, body)
+		rctx := chi.NewRouteContext()
+		for k, v := range params {
+			rctx.URLParams.Add(k, v)
+		}
+		return r.WithContext(context.WithValue(r.Context(), chi.RouteCtxKey, rctx))
+	}
+	statRef := func(ref func(*provider.Reference) bool) interface{} {
+		return mock.MatchedBy(func(req *provider.StatRequest) bool { return ref(req.Ref) })
+	}
+
+	JustBeforeEach(func() {
+		ctx = context.Background()
+		gatewayClient = &mocks.GatewayClient{}
+		httpClient = &mocks.HTTPClient{}
+		svc = service.NewService(
+			service.Config(defaults.DefaultConfig()),
+			service.WithGatewayClient(gatewayClient),
+			service.WithHTTPClient(httpClient),
+			service.WithIdentityBackend(&mocks.Backend{}),
+		)
+	})
+
+	It("returns an item with its parent", func() {
+		gatewayClient.On("Stat", mock.Anything, mock.Anything).Return(&provider.StatResponse{
+			Status: status.NewOK(ctx),
+			Info:   file,
+		}, nil)
+
+		rr := httptest.NewRecorder()
+		svc.(service.Graph).GetDriveItem(rr, request(http.MethodGet, "/graph/v1.0/drives/sID/items/sID!fileID", nil,
+			map[string]string{"driveID": "sID", "itemID": "sID!fileID"}))
+		Expect(rr.Code).To(Equal(http.StatusOK))
+
+		item := libregraph.DriveItem{}
+		err := json.Unmarshal(rr.Body.Bytes(), &item)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(item.GetId()).To(Equal("sID!fileID"))
+		Expect(item.GetName()).To(Equal("file.txt"))
+		Expect(item.ParentReference.GetId()).To(Equal("sID!folderID"))
+	})
+
+	It("does not return items of other drives", func() {
+		rr := httptest.NewRecorder()
+		svc.(service.Graph).GetDriveItem(rr, request(http.MethodGet, "/graph/v1.0/drives/sID/items/other!fileID", nil,
+			map[string]string{"driveID": "sID", "itemID": "other!fileID"}))
+		Expect(rr.Code).To(Equal(http.StatusNotFound))
+	})
+
+	It("lists the children of a folder", func() {
+		gatewayClient.On("ListContainer", mock.Anything, mock.Anything).Return(&provider.ListContainerResponse{
+			Status: status.NewOK(ctx),
+			Infos:  []*provider.ResourceInfo{file, folder},
+		}, nil)
+
+		rr := httptest.NewRecorder()
+		svc.(service.Graph).GetDriveItemChildren(rr, request(http.MethodGet, "/graph/v1.0/drives/sID/root/children?$top=1", nil,
+			map[string]string{"driveID": "sID"}))
+		Expect(rr.Code).To(Equal(http.StatusOK))
+
+		res := struct {
+			Value    []libregraph.DriveItem
+			NextLink string `json:"@odata.nextLink"`
+		}{}
+		err := json.Unmarshal(rr.Body.Bytes(), &res)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(len(res.Value)).To(Equal(1))
+		Expect(res.Value[0].GetName()).To(Equal("file.txt"))
+		Expect(res.NextLink).ToNot(BeEmpty())
+	})
+
+	It("creates folders", func() {
+		gatewayClient.On("CreateContainer", mock.Anything, mock.MatchedBy(func(req *provider.CreateContainerRequest) bool {
+			return req.Ref.ResourceId.OpaqueId == "sID" && req.Ref.Path == "./folder"
+		})).Return(&provider.CreateContainerResponse{Status: status.NewOK(ctx)}, nil)
+		gatewayClient.On("Stat", mock.Anything, mock.Anything).Return(&provider.StatResponse{
+			Status: status.NewOK(ctx),
+			Info:   folder,
+		}, nil)
+
+		rr := httptest.NewRecorder()
+		body := strings.NewReader(`{"name":"folder","folder":{}}`)
+		svc.(service.Graph).CreateDriveItem(rr, request(http.MethodPost, "/graph/v1.0/drives/sID/root/children", body,
+			map[string]string{"driveID": "sID"}))
+		Expect(rr.Code).To(Equal(http.StatusCreated))
+
+		rr = httptest.NewRecorder()
+		body = strings.NewReader(`{"name":"file.txt","file":{}}`)
+		svc.(service.Graph).CreateDriveItem(rr, request(http.MethodPost, "/graph/v1.0/drives/sID/root/children", body,
+			map[string]string{"driveID": "sID"}))
+		Expect(rr.Code).To(Equal(http.StatusNotImplemented))
+
+		rr = httptest.NewRecorder()
+		body = strings.NewReader(`{"name":"../folder","folder":{}}`)
+		svc.(service.Graph).CreateDriveItem(rr, request(http.MethodPost, "/graph/v1.0/drives/sID/root/children", body,
+			map[string]string{"driveID": "sID"}))
+		Expect(rr.Code).To(Equal(http.StatusBadRequest))
+	})
+
+	It("renames items", func() {
+		gatewayClient.On("Stat", mock.Anything, mock.Anything).Return(&provider.StatResponse{
+			Status: status.NewOK(ctx),
+			Info:   file,
+		}, nil)
+		gatewayClient.On("Move", mock.Anything, mock.MatchedBy(func(req *provider.MoveRequest) bool {
+			return req.Source.ResourceId.OpaqueId == "fileID" &&
+				req.Destination.ResourceId.OpaqueId == "folderID" && req.Destination.Path == "./renamed.txt"
+		})).Return(&provider.MoveResponse{Status: status.NewOK(ctx)}, nil)
+
+		rr := httptest.NewRecorder()
+		body := strings.NewReader(`{"name":"renamed.txt"}`)
+		svc.(service.Graph).UpdateDriveItem(rr, request(http.MethodPatch, "/graph/v1.0/drives/sID/items/sID!fileID", body,
+			map[string]string{"driveID": "sID", "itemID": "sID!fileID"}))
+		Expect(rr.Code).To(Equal(http.StatusOK))
+		gatewayClient.AssertNumberOfCalls(GinkgoT(), "Move", 1)
+	})
+
+	It("deletes items but not the root", func() {
+		gatewayClient.On("Delete", mock.Anything, mock.Anything).Return(&provider.DeleteResponse{Status: status.NewOK(ctx)}, nil)
+
+		rr := httptest.NewRecorder()
+		svc.(service.Graph).DeleteDriveItem(rr, request(http.MethodDelete, "/graph/v1.0/drives/sID/items/sID!fileID", nil,
+			map[string]string{"driveID": "sID", "itemID": "sID!fileID"}))
+		Expect(rr.Code).To(Equal(http.StatusNoContent))
+
+		rr = httptest.NewRecorder()
+		svc.(service.Graph).DeleteDriveItem(rr, request(http.MethodDelete, "/graph/v1.0/drives/sID/items/sID!sID", nil,
+			map[string]string{"driveID": "sID", "itemID": "sID!sID"}))
+		Expect(rr.Code).To(Equal(http.StatusBadRequest))
+		gatewayClient.AssertNumberOfCalls(GinkgoT(), "Delete", 1)
+	})
+
+	It("copies files", func() {
+		gatewayClient.On("Stat", mock.Anything, statRef(func(ref *provider.Reference) bool {
+			return ref.Path == ""
+		})).Return(&provider.StatResponse{Status: status.NewOK(ctx), Info: file}, nil)
+		gatewayClient.On("Stat", mock.Anything, statRef(func(ref *provider.Reference) bool {
+			return ref.Path == "./copy.txt"
+		})).Return(&provider.StatResponse{Status: status.NewNotFound(ctx, "not found")}, nil).Once()
+		gatewayClient.On("Stat", mock.Anything, statRef(func(ref *provider.Reference) bool {
+			return ref.Path == "./copy.txt"
+		})).Return(&provider.StatResponse{Status: status.NewOK(ctx), Info: file}, nil)
+		gatewayClient.On("InitiateFileDownload", mock.Anything, mock.Anything).Return(&gateway.InitiateFileDownloadResponse{
+			Status:    status.NewOK(ctx),
+			Protocols: []*gateway.FileDownloadProtocol{{Protocol: "spaces", DownloadEndpoint: "https://localhost/data/download", Token: "dt"}},
+		}, nil)
+		gatewayClient.On("InitiateFileUpload", mock.Anything, mock.Anything).Return(&gateway.InitiateFileUploadResponse{
+			Status:    status.NewOK(ctx),
+			Protocols: []*gateway.FileUploadProtocol{{Protocol: "simple", UploadEndpoint: "https://localhost/data/upload", Token: "ut"}},
+		}, nil)
+		httpClient.On("Do", mock.MatchedBy(func(req *http.Request) bool {
+			return req.Method == http.MethodGet && req.Header.Get("X-Reva-Transfer") == "dt"
+		})).Return(&http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(bytes.NewBufferString("data"))}, nil)
+		httpClient.On("Do", mock.MatchedBy(func(req *http.Request) bool {
+			if req.Method != http.MethodPut || req.Header.Get("X-Reva-Transfer") != "ut" {
+				return false
+			}
+			b, _ := io.ReadAll(req.Body)
+			return string(b) == "data"
+		})).Return(&http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(&bytes.Buffer{})}, nil)
+
+		rr := httptest.NewRecorder()
+		body := strings.NewReader(`{"name":"copy.txt"}`)
+		svc.(service.Graph).CopyDriveItem(rr, request(http.MethodPost, "/graph/v1.0/drives/sID/items/sID!fileID/copy", body,
+			map[string]string{"driveID": "sID", "itemID": "sID!fileID"}))
+		Expect(rr.Code).To(Equal(http.StatusCreated))
+		httpClient.AssertNumberOfCalls(GinkgoT(), "Do", 2)
+	})
+
+	It("rejects copying a folder into itself", func() {
+		gatewayClient.On("Stat", mock.Anything, statRef(func(ref *provider.Reference) bool {
+			return ref.ResourceId.OpaqueId == "folderID" && ref.Path == ""
+		})).Return(&provider.StatResponse{Status: status.NewOK(ctx), Info: folder}, nil)
+		gatewayClient.On("Stat", mock.Anything, statRef(func(ref *provider.Reference) bool {
+			return ref.ResourceId.OpaqueId == "subID" && ref.Path == ""
+		})).Return(&provider.StatResponse{Status: status.NewOK(ctx), Info: &provider.ResourceInfo{
+			Id:       &provider.ResourceId{StorageId: "sID", OpaqueId: "subID"},
+			ParentId: folder.Id,
+			Type:     provider.ResourceType_RESOURCE_TYPE_CONTAINER,
+			Path:     "sub",
+		}}, nil)
+		gatewayClient.On("Stat", mock.Anything, mock.Anything).Return(&provider.StatResponse{Status: status.NewNotFound(ctx, "not found")}, nil)
+
+		for _, parent := range []string{"sID!folderID", "sID!subID"} {
+			rr := httptest.NewRecorder()
+			body := strings.NewReader(`{"parentReference":{"id":"` + parent + `"}}`)
+			svc.(service.Graph).CopyDriveItem(rr, request(http.MethodPost, "/graph/v1.0/drives/sID/items/sID!folderID/copy", body,
+				map[string]string{"driveID": "sID", "itemID": "sID!folderID"}))
+			Expect(rr.Code).To(Equal(http.StatusBadRequest))
+		}
+		gatewayClient.AssertNotCalled(GinkgoT(), "CreateContainer", mock.Anything, mock.Anything)
+	})
+
+	It("deletes the partial copy of a folder", func() {
+		gatewayClient.On("Stat", mock.Anything, statRef(func(ref *provider.Reference) bool {
+			return ref.Path == ""
+		})).Return(&provider.StatResponse{Status: status.NewOK(ctx), Info: folder}, nil)
+		gatewayClient.On("Stat", mock.Anything, mock.Anything).Return(&provider.StatResponse{Status: status.NewNotFound(ctx, "not found")}, nil)
+		gatewayClient.On("CreateContainer", mock.Anything, mock.Anything).Return(&provider.CreateContainerResponse{Status: status.NewOK(ctx)}, nil)
+		gatewayClient.On("ListContainer", mock.Anything, mock.Anything).Return(&provider.ListContainerResponse{
+			Status: status.NewOK(ctx),
+			Infos:  []*provider.ResourceInfo{file},
+		}, nil)
+		gatewayClient.On("InitiateFileDownload", mock.Anything, mock.Anything).Return(&gateway.InitiateFileDownloadResponse{
+			Status: status.NewInternal(ctx, "failed"),
+		}, nil)
+		gatewayClient.On("Delete", mock.Anything, mock.MatchedBy(func(req *provider.DeleteRequest) bool {
+			return req.Ref.Path == "./copy"
+		})).Return(&provider.DeleteResponse{Status: status.NewOK(ctx)}, nil)
+
+		rr := httptest.NewRecorder()
+		body := strings.NewReader(`{"name":"copy"}`)
+		svc.(service.Graph).CopyDriveItem(rr, request(http.MethodPost, "/graph/v1.0/drives/sID/items/sID!folderID/copy", body,
+			map[string]string{"driveID": "sID", "itemID": "sID!folderID"}))
+		Expect(rr.Code).To(Equal(http.StatusInternalServerError))
+		gatewayClient.AssertNumberOfCalls(GinkgoT(), "Delete", 1)
+	})
+})
@@ -3,6 +3,7 @@ package svc
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"math"
 	"net/http"
@@ -24,6 +25,9 @@ import (
 	"github.com/go-chi/render"
 	libregraph "github.com/owncloud/libre-graph-api-go"
 	"github.com/owncloud/ocis/extensions/graph/pkg/service/v0/errorcode"
+	"github.com/owncloud/ocis/extensions/graph/pkg/service/v0/filter"
+	"github.com/owncloud/ocis/extensions/graph/pkg/service/v0/paging"
+	"github.com/owncloud/ocis/extensions/graph/pkg/service/v0/projection"
 	settingsServiceExt "github.com/owncloud/ocis/extensions/settings/pkg/service/v0"
 	"github.com/owncloud/ocis/ocis-pkg/service/grpc"
 	v0 "github.com/owncloud/ocis/protogen/gen/ocis/messages/settings/v0"
@@ -35,21 +39,36 @@ import (
 func (g Graph) GetDrives(w http.ResponseWriter, r *http.Request) {
 	sanitizedPath := strings.TrimPrefix(r.URL.Path, "/graph/v1.0/")
 	// Parse the request with odata parser
-	odataReq, err := godata.ParseRequest(r.Context(), sanitizedPath, r.URL.Query())
+	odataReq, err := godata.ParseRequest(r.Context(), sanitizedPath, paging.WithoutSkipToken(r.URL.Query()))
 	if err != nil {
 		g.logger.Err(err).Interface("query", r.URL.Query()).Msg("query error")
 		errorcode.InvalidRequest.Render(w, r, http.StatusBadRequest, err.Error())
 		return
 	}
+	page, err := g.parsePage(r)
+	if err != nil {
+		errorcode.InvalidRequest.Render(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+	p, err := parseProjection(r, "root")
+	if err != nil {
+		renderError(w, r, err)
+		return
+	}
 	g.logger.Info().Interface("query", r.URL.Query()).Msg("Calling GetDrives")
 	ctx := r.Context()
 
-	filters, err := generateCs3Filters(odataReq)
+	f, err := filter.Parse(r.URL.Query())
 	if err != nil {
 		g.logger.Err(err).Interface("query", r.URL.Query()).Msg("query error")
-		errorcode.NotSupported.Render(w, r, http.StatusNotImplemented, err.Error())
+		if errors.Is(err, filter.ErrUnsupported) {
+			errorcode.NotSupported.Render(w, r, http.StatusNotImplemented, err.Error())
+		} else {
+			errorcode.InvalidRequest.Render(w, r, http.StatusBadRequest, err.Error())
+		}
 		return
 	}
+	filters, exact := generateCs3Filters(f)
 	res, err := g.ListStorageSpacesWithFilters(ctx, filters)
 	switch {
 	case err != nil:
@@ -74,22 +93,69 @@ func (g Graph) GetDrives(w http.ResponseWriter, r *http.Request) {
 		errorcode.GeneralException.Render(w, r, http.StatusInternalServerError, err.Error())
 		return
 	}
-	spaces, err := g.formatDrives(ctx, wdu, res.StorageSpaces)
+
+	// Spaces are only formatted for the requested page, unless they have to be sorted or filtered first.
+	inMemory := odataReq.Query.OrderBy != nil || !exact
+
+	storageSpaces := res.StorageSpaces
+	if page.Paged() {
+		// The order of the spaces returned by the gateway is not stable, so they are ordered by id.
+		sort.Slice(storageSpaces, func(i, j int) bool {
+			return storageSpaces[i].GetId().GetOpaqueId() < storageSpaces[j].GetId().GetOpaqueId()
+		})
+	}
+	var info paging.Info
+	if !inMemory {
+		var start, end int
+		if start, end, info, err = paging.Slice(len(storageSpaces), page); err != nil {
+			errorcode.InvalidRequest.Render(w, r, http.StatusBadRequest, err.Error())
+			return
+		}
+		storageSpaces = storageSpaces[start:end]
+	}
+
+	spaces, err := g.formatDrives(ctx, wdu, storageSpaces)
 	if err != nil {
 		g.logger.Error().Err(err).Msg("error encoding response as json")
 		errorcode.GeneralException.Render(w, r, http.StatusInternalServerError, err.Error())
 		return
 	}
 
-	spaces, err = sortSpaces(odataReq, spaces)
-	if err != nil {
-		g.logger.Error().Err(err).Msg("error sorting the spaces list")
-		errorcode.InvalidRequest.Render(w, r, http.StatusBadRequest, err.Error())
-		return
+	if inMemory {
+		if !exact {
+			filtered := make([]*libregraph.Drive, 0, len(spaces))
+			for _, space := range spaces {
+				if f.Match(space) {
+					filtered = append(filtered, space)
+				}
+			}
+			spaces = filtered
+		}
+		spaces, err = sortSpaces(odataReq, spaces)
+		if err != nil {
+			g.logger.Error().Err(err).Msg("error sorting the spaces list")
+			errorcode.InvalidRequest.Render(w, r, http.StatusBadRequest, err.Error())
+			return
+		}
+		var start, end int
+		if start, end, info, err = paging.Slice(len(spaces), page); err != nil {
+			errorcode.InvalidRequest.Render(w, r, http.StatusBadRequest, err.Error())
+			return
+		}
+		spaces = spaces[start:end]
 	}
 
-	render.Status(r, http.StatusOK)
-	render.JSON(w, r, &listResponse{Value: spaces})
+	values := make([]interface{}, 0, len(spaces))
+	for _, space := range spaces {
+		v, err := g.projectDrive(ctx, p, space)
+		if err != nil {
+			g.logger.Error().Err(err).Msg("error encoding response as json")
+			errorcode.GeneralException.Render(w, r, http.StatusInternalServerError, err.Error())
+			return
+		}
+		values = append(values, v)
+	}
+	g.renderPage(w, r, values, info)
 }
 
 // GetSingleDrive does a lookup of a single space by spaceId
@@ -102,6 +168,12 @@ func (g Graph) GetSingleDrive(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	p, err := parseProjection(r, "root")
+	if err != nil {
+		renderError(w, r, err)
+		return
+	}
+
 	g.logger.Info().Str("driveID", driveID).Msg("Calling GetSingleDrive")
 	ctx := r.Context()
 
@@ -143,8 +215,14 @@ func (g Graph) GetSingleDrive(w http.ResponseWriter, r *http.Request) {
 		errorcode.ItemNotFound.Render(w, r, http.StatusNotFound, fmt.Sprintf(NoSpaceFoundMessage, driveID))
 		return
 	case num == 1:
+		res, err := g.projectDrive(ctx, p, spaces[0])
+		if err != nil {
+			g.logger.Error().Err(err).Msg("error encoding response")
+			errorcode.GeneralException.Render(w, r, http.StatusInternalServerError, err.Error())
+			return
+		}
 		render.Status(r, http.StatusOK)
-		render.JSON(w, r, spaces[0])
+		render.JSON(w, r, res)
 	default:
 		g.logger.Error().Int("number", num).Msg("expected to find a single space but found more")
 		errorcode.GeneralException.Render(w, r, http.StatusInternalServerError, "expected to find a single space but found more")
@@ -397,6 +475,30 @@ func (g Graph) formatDrives(ctx context.Context, baseURL *url.URL, storageSpaces
 	return responses, nil
 }
 
+// projectDrive expands the root of the drive if requested and selects the requested properties.
+func (g Graph) projectDrive(ctx context.Context, p projection.Projection, drive *libregraph.Drive) (interface{}, error) {
+	expanded := map[string]interface{}{}
+	if p.Expands("root") && drive.Root != nil && drive.Root.Id != nil {
+		item, err := g.getDriveItem(ctx, resourceid.OwnCloudResourceIDUnwrap(*drive.Root.Id))
+		if err != nil {
+			// trashed spaces and mountpoints without a grant have no root that could be read
+			g.logger.Debug().Err(err).Str("driveID", drive.GetId()).Msg("could not expand the root of the drive")
+			item = drive.Root
+		} else {
+			item.Id = drive.Root.Id
+			item.Permissions = drive.Root.Permissions
+			item.WebDavUrl = drive.Root.WebDavUrl
+			item.Deleted = drive.Root.Deleted
+			item.RemoteItem = drive.Root.RemoteItem
+			if item.ETag == nil {
+				item.ETag = drive.Root.ETag
+			}
+		}
+		expanded["root"] = item
+	}
+	return p.Apply(drive, expanded)
+}
+
 // ListStorageSpacesWithFilters List Storage Spaces using filters
 func (g Graph) ListStorageSpacesWithFilters(ctx context.Context, filters []*storageprovider.ListStorageSpacesRequest_Filter) (*storageprovider.ListStorageSpacesResponse, error) {
 	client := g.GetGatewayClient()
@@ -436,65 +538,49 @@ func (g Graph) cs3StorageSpaceToDrive(ctx context.Context, baseURL *url.URL, spa
 	rootID := resourceid.OwnCloudResourceIDWrap(space.Root)
 
 	var permissions []libregraph.Permission
-	if space.Opaque != nil {
-		var m map[string]*storageprovider.ResourcePermissions
-		entry, ok := space.Opaque.Map["grants"]
-		if ok {
-			err := json.Unmarshal(entry.Value, &m)
-			if err != nil {
-				g.logger.Error().
-					Err(err).
-					Str("space", space.Root.OpaqueId).
-					Msg("failed to read spaces grants")
+	if m := g.spaceGrants(space); len(m) != 0 {
+		managerIdentities := []libregraph.IdentitySet{}
+		editorIdentities := []libregraph.IdentitySet{}
+		viewerIdentities := []libregraph.IdentitySet{}
+
+		for id, perm := range m {
+			// This temporary variable is necessary since we need to pass a pointer to the
+			// libregraph.Identity and if we pass the pointer from the loop every identity
+			// will have the same id.
+			tmp := id
+			identity := libregraph.IdentitySet{User: &libregraph.Identity{Id: &tmp}}
+			switch spaceRole(perm) {
+			case SpaceRoleManager:
+				managerIdentities = append(managerIdentities, identity)
+			case SpaceRoleEditor:
+				editorIdentities = append(editorIdentities, identity)
+			case SpaceRoleViewer:
+				viewerIdentities = append(viewerIdentities, identity)
 			}
 		}
-		if len(m) != 0 {
-			managerIdentities := []libregraph.IdentitySet{}
-			editorIdentities := []libregraph.IdentitySet{}
-			viewerIdentities := []libregraph.IdentitySet{}
-
-			for id, perm := range m {
-				// This temporary variable is necessary since we need to pass a pointer to the
-				// libregraph.Identity and if we pass the pointer from the loop every identity
-				// will have the same id.
-				tmp := id
-				identity := libregraph.IdentitySet{User: &libregraph.Identity{Id: &tmp}}
-				switch {
-				case perm.AddGrant:
-					managerIdentities = append(managerIdentities, identity)
-				case perm.InitiateFileUpload:
-					editorIdentities = append(editorIdentities, identity)
-				case perm.Stat:
-					viewerIdentities = append(viewerIdentities, identity)
-				}
-			}
 
-			permissions = make([]libregraph.Permission, 0, 3)
-			if len(managerIdentities) != 0 {
-				permissions = append(permissions, libregraph.Permission{
-					GrantedTo: managerIdentities,
-					Roles:     []string{"manager"},
-				})
-			}
-			if len(editorIdentities) != 0 {
-				permissions = append(permissions, libregraph.Permission{
-					GrantedTo: editorIdentities,
-					Roles:     []string{"editor"},
-				})
-			}
-			if len(viewerIdentities) != 0 {
-				permissions = append(permissions, libregraph.Permission{
-					GrantedTo: viewerIdentities,
-					Roles:     []string{"viewer"},
-				})
-			}
+		permissions = make([]libregraph.Permission, 0, 3)
+		if len(managerIdentities) != 0 {
+			permissions = append(permissions, libregraph.Permission{
+				GrantedTo: managerIdentities,
+				Roles:     []string{SpaceRoleManager},
+			})
+		}
+		if len(editorIdentities) != 0 {
+			permissions = append(permissions, libregraph.Permission{
+				GrantedTo: editorIdentities,
+				Roles:     []string{SpaceRoleEditor},
+			})
+		}
+		if len(viewerIdentities) != 0 {
+			permissions = append(permissions, libregraph.Permission{
+				GrantedTo: viewerIdentities,
+				Roles:     []string{SpaceRoleViewer},
+			})
 		}
 	}
 
-	spaceID := space.Root.StorageId
-	if space.Root.OpaqueId != space.Root.StorageId {
-		spaceID = rootID
-	}
+	spaceID := driveID(space)
 	drive := &libregraph.Drive{
 		Id:   &spaceID,
 		Name: &space.Name,
@@ -581,6 +667,15 @@ func (g Graph) cs3StorageSpaceToDrive(ctx context.Context, baseURL *url.URL, spa
 	return drive, nil
 }
 
+// driveID returns the id of the drive of a space. Spaces with a root other than the storage are addressed by the
+// id of their root.
+func driveID(space *storageprovider.StorageSpace) string {
+	if space.Root.OpaqueId != space.Root.StorageId {
+		return resourceid.OwnCloudResourceIDWrap(space.Root)
+	}
+	return space.Root.StorageId
+}
+
 func (g Graph) getDriveQuota(ctx context.Context, space *storageprovider.StorageSpace) (*libregraph.Quota, error) {
 	client := g.GetGatewayClient()
 
@@ -682,22 +777,70 @@ func canSetSpaceQuota(ctx context.Context, user *userv1beta1.User) (bool, error)
 	return true, nil
 }
 
-func generateCs3Filters(request *godata.GoDataRequest) ([]*storageprovider.ListStorageSpacesRequest_Filter, error) {
+// generateCs3Filters converts the conditions of the filter, which all spaces in the result have to meet, into filters
+// for listing the storage spaces. It reports if the filters are equivalent to the whole filter, otherwise the listed
+// spaces have to be filtered again.
+func generateCs3Filters(f *filter.Node) ([]*storageprovider.ListStorageSpacesRequest_Filter, bool) {
+	if f == nil {
+		return nil, true
+	}
+
+	var conditions []*filter.Node
+	var collect func(n *filter.Node)
+	collect = func(n *filter.Node) {
+		if n.Op == filter.OpAnd {
+			collect(n.Children[0])
+			collect(n.Children[1])
+			return
+		}
+		conditions = append(conditions, n)
+	}
+	collect(f)
+
+	// The storage providers combine filters of the same type with 'or', so only one condition per type is passed on.
 	var filters []*storageprovider.ListStorageSpacesRequest_Filter
-	if request.Query.Filter != nil {
-		if request.Query.Filter.Tree.Token.Value == "eq" {
-			switch request.Query.Filter.Tree.Children[0].Token.Value {
-			case "driveType":
-				filters = append(filters, listStorageSpacesTypeFilter(strings.Trim(request.Query.Filter.Tree.Children[1].Token.Value, "'")))
-			case "id":
-				filters = append(filters, listStorageSpacesIDFilter(strings.Trim(request.Query.Filter.Tree.Children[1].Token.Value, "'")))
-			}
-		} else {
-			err := fmt.Errorf("unsupported filter operand: %s", request.Query.Filter.Tree.Token.Value)
-			return nil, err
+	exact := true
+	used := map[storageprovider.ListStorageSpacesRequest_Filter_Type]bool{}
+	for _, c := range conditions {
+		cf, cExact := cs3Filters(c)
+		if len(cf) == 0 || used[cf[0].Type] {
+			exact = false
+			continue
 		}
+		used[cf[0].Type] = true
+		exact = exact && cExact
+		filters = append(filters, cf...)
 	}
-	return filters, nil
+	return filters, exact
+}
+
+// cs3Filters converts a condition into filters of a single type.
+func cs3Filters(n *filter.Node) ([]*storageprovider.ListStorageSpacesRequest_Filter, bool) {
+	switch n.Op {
+	case filter.OpOr:
+		left, leftExact := cs3Filters(n.Children[0])
+		right, rightExact := cs3Filters(n.Children[1])
+		if len(left) == 0 || len(right) == 0 || left[0].Type != right[0].Type ||
+			left[0].Type != storageprovider.ListStorageSpacesRequest_Filter_TYPE_SPACE_TYPE {
+			return nil, false
+		}
+		return append(left, right...), leftExact && rightExact
+	case filter.OpEq:
+		v, ok := n.Value.(string)
+		if !ok {
+			return nil, false
+		}
+		switch n.Property {
+		case "driveType":
+			return []*storageprovider.ListStorageSpacesRequest_Filter{listStorageSpacesTypeFilter(v)}, true
+		case "id":
+			return []*storageprovider.ListStorageSpacesRequest_Filter{listStorageSpacesIDFilter(v)}, true
+		case "owner/user/id":
+			// not every storage provider implements the owner filter
+			return []*storageprovider.ListStorageSpacesRequest_Filter{listStorageSpacesOwnerFilter(v)}, false
+		}
+	}
+	return nil, false
 }
 
 func listStorageSpacesIDFilter(id string) *storageprovider.ListStorageSpacesRequest_Filter {
@@ -711,6 +854,17 @@ func listStorageSpacesIDFilter(id string) *storageprovider.ListStorageSpacesRequ
 	}
 }
 
+func listStorageSpacesOwnerFilter(userID string) *storageprovider.ListStorageSpacesRequest_Filter {
+	return &storageprovider.ListStorageSpacesRequest_Filter{
+		Type: storageprovider.ListStorageSpacesRequest_Filter_TYPE_OWNER,
+		Term: &storageprovider.ListStorageSpacesRequest_Filter_Owner{
+			Owner: &userv1beta1.UserId{
+				OpaqueId: userID,
+			},
+		},
+	}
+}
+
 func listStorageSpacesTypeFilter(spaceType string) *storageprovider.ListStorageSpacesRequest_Filter {
 	return &storageprovider.ListStorageSpacesRequest_Filter{
 		Type: storageprovider.ListStorageSpacesRequest_Filter_TYPE_SPACE_TYPE,
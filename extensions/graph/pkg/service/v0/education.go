@@ -0,0 +1,759 @@
+package svc
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+
+	"github.com/cs3org/reva/v2/pkg/events"
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/render"
+	libregraph "github.com/owncloud/libre-graph-api-go"
+	"github.com/owncloud/ocis/extensions/graph/pkg/identity"
+	"github.com/owncloud/ocis/extensions/graph/pkg/service/v0/errorcode"
+	"github.com/owncloud/ocis/extensions/graph/pkg/service/v0/paging"
+	settingssvc "github.com/owncloud/ocis/extensions/settings/pkg/service/v0"
+	settings "github.com/owncloud/ocis/protogen/gen/ocis/services/settings/v0"
+)
+
+// GetEducationSchools lists the schools.
+func (g Graph) GetEducationSchools(w http.ResponseWriter, r *http.Request) {
+	eb, ok := g.educationBackend(w, r)
+	if !ok {
+		return
+	}
+	page, err := g.parsePage(r)
+	if err != nil {
+		errorcode.InvalidRequest.Render(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+	g.logger.Info().Msg("Calling GetEducationSchools")
+
+	schools, err := eb.GetEducationSchools(r.Context())
+	if err != nil {
+		renderError(w, r, err)
+		return
+	}
+	start, end, info, err := paging.Slice(len(schools), page)
+	if err != nil {
+		errorcode.InvalidRequest.Render(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+	g.renderPage(w, r, schools[start:end], info)
+}
+
+// PostEducationSchool creates a school.
+func (g Graph) PostEducationSchool(w http.ResponseWriter, r *http.Request) {
+	eb, ok := g.educationBackend(w, r)
+	if !ok {
+		return
+	}
+	school := identity.EducationSchool{}
+	if err := json.NewDecoder(r.Body).Decode(&school); err != nil {
+		errorcode.InvalidRequest.Render(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+	if school.DisplayName == nil || *school.DisplayName == "" {
+		errorcode.InvalidRequest.Render(w, r, http.StatusBadRequest, "missing required Attribute: 'displayName'")
+		return
+	}
+	if school.Id != nil {
+		errorcode.InvalidRequest.Render(w, r, http.StatusBadRequest, "school id is a read-only attribute")
+		return
+	}
+	g.logger.Info().Str("school", *school.DisplayName).Msg("Calling PostEducationSchool")
+
+	created, err := eb.CreateEducationSchool(r.Context(), school)
+	if err != nil {
+		renderError(w, r, err)
+		return
+	}
+	render.Status(r, http.StatusCreated)
+	render.JSON(w, r, created)
+}
+
+// GetEducationSchool returns a school identified by name or id.
+func (g Graph) GetEducationSchool(w http.ResponseWriter, r *http.Request) {
+	eb, ok := g.educationBackend(w, r)
+	if !ok {
+		return
+	}
+	schoolID, err := pathParam(r, "schoolID", "school")
+	if err != nil {
+		renderError(w, r, err)
+		return
+	}
+	g.logger.Info().Str("school", schoolID).Msg("Calling GetEducationSchool")
+
+	school, err := eb.GetEducationSchool(r.Context(), schoolID)
+	if err != nil {
+		renderError(w, r, err)
+		return
+	}
+	render.Status(r, http.StatusOK)
+	render.JSON(w, r, school)
+}
+
+// PatchEducationSchool updates the name or number of a school.
+func (g Graph) PatchEducationSchool(w http.ResponseWriter, r *http.Request) {
+	eb, ok := g.educationBackend(w, r)
+	if !ok {
+		return
+	}
+	schoolID, err := pathParam(r, "schoolID", "school")
+	if err != nil {
+		renderError(w, r, err)
+		return
+	}
+	changes := identity.EducationSchool{}
+	if err := json.NewDecoder(r.Body).Decode(&changes); err != nil {
+		errorcode.InvalidRequest.Render(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+	g.logger.Info().Str("school", schoolID).Msg("Calling PatchEducationSchool")
+
+	school, err := eb.UpdateEducationSchool(r.Context(), schoolID, changes)
+	if err != nil {
+		renderError(w, r, err)
+		return
+	}
+	render.Status(r, http.StatusOK)
+	render.JSON(w, r, school)
+}
+
+// DeleteEducationSchool deletes a school. Its users and classes are kept.
+func (g Graph) DeleteEducationSchool(w http.ResponseWriter, r *http.Request) {
+	eb, ok := g.educationBackend(w, r)
+	if !ok {
+		return
+	}
+	schoolID, err := pathParam(r, "schoolID", "school")
+	if err != nil {
+		renderError(w, r, err)
+		return
+	}
+	g.logger.Info().Str("school", schoolID).Msg("Calling DeleteEducationSchool")
+
+	if err := eb.DeleteEducationSchool(r.Context(), schoolID); err != nil {
+		renderError(w, r, err)
+		return
+	}
+	render.Status(r, http.StatusNoContent)
+	render.NoContent(w, r)
+}
+
+// GetEducationSchoolUsers lists the users of a school.
+func (g Graph) GetEducationSchoolUsers(w http.ResponseWriter, r *http.Request) {
+	eb, ok := g.educationBackend(w, r)
+	if !ok {
+		return
+	}
+	schoolID, err := pathParam(r, "schoolID", "school")
+	if err != nil {
+		renderError(w, r, err)
+		return
+	}
+	page, err := g.parsePage(r)
+	if err != nil {
+		errorcode.InvalidRequest.Render(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+	g.logger.Info().Str("school", schoolID).Msg("Calling GetEducationSchoolUsers")
+
+	users, err := eb.GetEducationSchoolUsers(r.Context(), schoolID)
+	if err != nil {
+		renderError(w, r, err)
+		return
+	}
+	start, end, info, err := paging.Slice(len(users), page)
+	if err != nil {
+		errorcode.InvalidRequest.Render(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+	g.renderPage(w, r, users[start:end], info)
+}
+
+// PostEducationSchoolUser adds the user referenced by the @odata.id of the request to a school.
+func (g Graph) PostEducationSchoolUser(w http.ResponseWriter, r *http.Request) {
+	eb, ok := g.educationBackend(w, r)
+	if !ok {
+		return
+	}
+	schoolID, err := pathParam(r, "schoolID", "school")
+	if err != nil {
+		renderError(w, r, err)
+		return
+	}
+	userID, err := g.parseEducationRef(r, "users")
+	if err != nil {
+		renderError(w, r, err)
+		return
+	}
+	g.logger.Info().Str("school", schoolID).Str("user", userID).Msg("Calling PostEducationSchoolUser")
+
+	if err := eb.AddUsersToEducationSchool(r.Context(), schoolID, []string{userID}); err != nil {
+		renderError(w, r, err)
+		return
+	}
+	render.Status(r, http.StatusNoContent)
+	render.NoContent(w, r)
+}
+
+// DeleteEducationSchoolUser removes a user from a school.
+func (g Graph) DeleteEducationSchoolUser(w http.ResponseWriter, r *http.Request) {
+	eb, ok := g.educationBackend(w, r)
+	if !ok {
+		return
+	}
+	schoolID, err := pathParam(r, "schoolID", "school")
+	if err != nil {
+		renderError(w, r, err)
+		return
+	}
+	userID, err := pathParam(r, "userID", "user")
+	if err != nil {
+		renderError(w, r, err)
+		return
+	}
+	g.logger.Info().Str("school", schoolID).Str("user", userID).Msg("Calling DeleteEducationSchoolUser")
+
+	if err := eb.RemoveUserFromEducationSchool(r.Context(), schoolID, userID); err != nil {
+		renderError(w, r, err)
+		return
+	}
+	render.Status(r, http.StatusNoContent)
+	render.NoContent(w, r)
+}
+
+// GetEducationSchoolClasses lists the classes of a school.
+func (g Graph) GetEducationSchoolClasses(w http.ResponseWriter, r *http.Request) {
+	eb, ok := g.educationBackend(w, r)
+	if !ok {
+		return
+	}
+	schoolID, err := pathParam(r, "schoolID", "school")
+	if err != nil {
+		renderError(w, r, err)
+		return
+	}
+	page, err := g.parsePage(r)
+	if err != nil {
+		errorcode.InvalidRequest.Render(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+	g.logger.Info().Str("school", schoolID).Msg("Calling GetEducationSchoolClasses")
+
+	classes, err := eb.GetEducationSchoolClasses(r.Context(), schoolID)
+	if err != nil {
+		renderError(w, r, err)
+		return
+	}
+	start, end, info, err := paging.Slice(len(classes), page)
+	if err != nil {
+		errorcode.InvalidRequest.Render(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+	g.renderPage(w, r, classes[start:end], info)
+}
+
+// PostEducationSchoolClass adds the class referenced by the @odata.id of the request to a school.
+func (g Graph) PostEducationSchoolClass(w http.ResponseWriter, r *http.Request) {
+	eb, ok := g.educationBackend(w, r)
+	if !ok {
+		return
+	}
+	schoolID, err := pathParam(r, "schoolID", "school")
+	if err != nil {
+		renderError(w, r, err)
+		return
+	}
+	classID, err := g.parseEducationRef(r, "classes")
+	if err != nil {
+		renderError(w, r, err)
+		return
+	}
+	g.logger.Info().Str("school", schoolID).Str("class", classID).Msg("Calling PostEducationSchoolClass")
+
+	if err := eb.AddClassesToEducationSchool(r.Context(), schoolID, []string{classID}); err != nil {
+		renderError(w, r, err)
+		return
+	}
+	render.Status(r, http.StatusNoContent)
+	render.NoContent(w, r)
+}
+
+// DeleteEducationSchoolClass removes a class from a school.
+func (g Graph) DeleteEducationSchoolClass(w http.ResponseWriter, r *http.Request) {
+	eb, ok := g.educationBackend(w, r)
+	if !ok {
+		return
+	}
+	schoolID, err := pathParam(r, "schoolID", "school")
+	if err != nil {
+		renderError(w, r, err)
+		return
+	}
+	classID, err := pathParam(r, "classID", "class")
+	if err != nil {
+		renderError(w, r, err)
+		return
+	}
+	g.logger.Info().Str("school", schoolID).Str("class", classID).Msg("Calling DeleteEducationSchoolClass")
+
+	if err := eb.RemoveClassFromEducationSchool(r.Context(), schoolID, classID); err != nil {
+		renderError(w, r, err)
+		return
+	}
+	render.Status(r, http.StatusNoContent)
+	render.NoContent(w, r)
+}
+
+// GetEducationClasses lists the classes.
+func (g Graph) GetEducationClasses(w http.ResponseWriter, r *http.Request) {
+	eb, ok := g.educationBackend(w, r)
+	if !ok {
+		return
+	}
+	page, err := g.parsePage(r)
+	if err != nil {
+		errorcode.InvalidRequest.Render(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+	g.logger.Info().Msg("Calling GetEducationClasses")
+
+	classes, err := eb.GetEducationClasses(r.Context())
+	if err != nil {
+		renderError(w, r, err)
+		return
+	}
+	start, end, info, err := paging.Slice(len(classes), page)
+	if err != nil {
+		errorcode.InvalidRequest.Render(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+	g.renderPage(w, r, classes[start:end], info)
+}
+
+// PostEducationClass creates a class and the group holding its members.
+func (g Graph) PostEducationClass(w http.ResponseWriter, r *http.Request) {
+	eb, ok := g.educationBackend(w, r)
+	if !ok {
+		return
+	}
+	class := identity.EducationClass{}
+	if err := json.NewDecoder(r.Body).Decode(&class); err != nil {
+		errorcode.InvalidRequest.Render(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+	if class.DisplayName == nil || *class.DisplayName == "" {
+		errorcode.InvalidRequest.Render(w, r, http.StatusBadRequest, "missing required Attribute: 'displayName'")
+		return
+	}
+	if class.Id != nil {
+		errorcode.InvalidRequest.Render(w, r, http.StatusBadRequest, "class id is a read-only attribute")
+		return
+	}
+	g.logger.Info().Str("class", *class.DisplayName).Msg("Calling PostEducationClass")
+
+	created, err := eb.CreateEducationClass(r.Context(), class)
+	if err != nil {
+		renderError(w, r, err)
+		return
+	}
+	if created.Id != nil {
+		g.publishEvent(events.GroupCreated{GroupID: *created.Id})
+	}
+	render.Status(r, http.StatusCreated)
+	render.JSON(w, r, created)
+}
+
+// GetEducationClass returns a class identified by name or id.
+func (g Graph) GetEducationClass(w http.ResponseWriter, r *http.Request) {
+	eb, ok := g.educationBackend(w, r)
+	if !ok {
+		return
+	}
+	classID, err := pathParam(r, "classID", "class")
+	if err != nil {
+		renderError(w, r, err)
+		return
+	}
+	g.logger.Info().Str("class", classID).Msg("Calling GetEducationClass")
+
+	class, err := eb.GetEducationClass(r.Context(), classID)
+	if err != nil {
+		renderError(w, r, err)
+		return
+	}
+	render.Status(r, http.StatusOK)
+	render.JSON(w, r, class)
+}
+
+// PatchEducationClass updates the description or external id of a class.
+func (g Graph) PatchEducationClass(w http.ResponseWriter, r *http.Request) {
+	eb, ok := g.educationBackend(w, r)
+	if !ok {
+		return
+	}
+	classID, err := pathParam(r, "classID", "class")
+	if err != nil {
+		renderError(w, r, err)
+		return
+	}
+	changes := identity.EducationClass{}
+	if err := json.NewDecoder(r.Body).Decode(&changes); err != nil {
+		errorcode.InvalidRequest.Render(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+	g.logger.Info().Str("class", classID).Msg("Calling PatchEducationClass")
+
+	class, err := eb.UpdateEducationClass(r.Context(), classID, changes)
+	if err != nil {
+		renderError(w, r, err)
+		return
+	}
+	render.Status(r, http.StatusOK)
+	render.JSON(w, r, class)
+}
+
+// DeleteEducationClass deletes a class and its group.
+func (g Graph) DeleteEducationClass(w http.ResponseWriter, r *http.Request) {
+	eb, ok := g.educationBackend(w, r)
+	if !ok {
+		return
+	}
+	classID, err := pathParam(r, "classID", "class")
+	if err != nil {
+		renderError(w, r, err)
+		return
+	}
+	g.logger.Info().Str("class", classID).Msg("Calling DeleteEducationClass")
+
+	if err := eb.DeleteEducationClass(r.Context(), classID); err != nil {
+		renderError(w, r, err)
+		return
+	}
+	g.publishEvent(events.GroupDeleted{GroupID: classID})
+	render.Status(r, http.StatusNoContent)
+	render.NoContent(w, r)
+}
+
+// GetEducationClassMembers lists the users of a class.
+func (g Graph) GetEducationClassMembers(w http.ResponseWriter, r *http.Request) {
+	eb, ok := g.educationBackend(w, r)
+	if !ok {
+		return
+	}
+	classID, err := pathParam(r, "classID", "class")
+	if err != nil {
+		renderError(w, r, err)
+		return
+	}
+	page, err := g.parsePage(r)
+	if err != nil {
+		errorcode.InvalidRequest.Render(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+	g.logger.Info().Str("class", classID).Msg("Calling GetEducationClassMembers")
+
+	members, err := eb.GetEducationClassMembers(r.Context(), classID)
+	if err != nil {
+		renderError(w, r, err)
+		return
+	}
+	start, end, info, err := paging.Slice(len(members), page)
+	if err != nil {
+		errorcode.InvalidRequest.Render(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+	g.renderPage(w, r, members[start:end], info)
+}
+
+// PostEducationClassMember adds the user referenced by the @odata.id of the request to a class.
+func (g Graph) PostEducationClassMember(w http.ResponseWriter, r *http.Request) {
+	eb, ok := g.educationBackend(w, r)
+	if !ok {
+		return
+	}
+	classID, err := pathParam(r, "classID", "class")
+	if err != nil {
+		renderError(w, r, err)
+		return
+	}
+	userID, err := g.parseEducationRef(r, "users")
+	if err != nil {
+		renderError(w, r, err)
+		return
+	}
+	g.logger.Info().Str("class", classID).Str("user", userID).Msg("Calling PostEducationClassMember")
+
+	if err := eb.AddUsersToEducationClass(r.Context(), classID, []string{userID}); err != nil {
+		renderError(w, r, err)
+		return
+	}
+	g.publishEvent(events.GroupMemberAdded{GroupID: classID, UserID: userID})
+	render.Status(r, http.StatusNoContent)
+	render.NoContent(w, r)
+}
+
+// DeleteEducationClassMember removes a user from a class.
+func (g Graph) DeleteEducationClassMember(w http.ResponseWriter, r *http.Request) {
+	eb, ok := g.educationBackend(w, r)
+	if !ok {
+		return
+	}
+	classID, err := pathParam(r, "classID", "class")
+	if err != nil {
+		renderError(w, r, err)
+		return
+	}
+	userID, err := pathParam(r, "memberID", "member")
+	if err != nil {
+		renderError(w, r, err)
+		return
+	}
+	g.logger.Info().Str("class", classID).Str("user", userID).Msg("Calling DeleteEducationClassMember")
+
+	if err := eb.RemoveUserFromEducationClass(r.Context(), classID, userID); err != nil {
+		renderError(w, r, err)
+		return
+	}
+	g.publishEvent(events.GroupMemberRemoved{GroupID: classID, UserID: userID})
+	render.Status(r, http.StatusNoContent)
+	render.NoContent(w, r)
+}
+
+// GetEducationUsers lists the education users.
+func (g Graph) GetEducationUsers(w http.ResponseWriter, r *http.Request) {
+	eb, ok := g.educationBackend(w, r)
+	if !ok {
+		return
+	}
+	page, err := g.parsePage(r)
+	if err != nil {
+		errorcode.InvalidRequest.Render(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+	g.logger.Info().Msg("Calling GetEducationUsers")
+
+	users, err := eb.GetEducationUsers(r.Context())
+	if err != nil {
+		renderError(w, r, err)
+		return
+	}
+	start, end, info, err := paging.Slice(len(users), page)
+	if err != nil {
+		errorcode.InvalidRequest.Render(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+	g.renderPage(w, r, users[start:end], info)
+}
+
+// PostEducationUser creates an education user. Like users created with PostUser, the user gets the user role.
+func (g Graph) PostEducationUser(w http.ResponseWriter, r *http.Request) {
+	eb, ok := g.educationBackend(w, r)
+	if !ok {
+		return
+	}
+	u := identity.EducationUser{}
+	if err := json.NewDecoder(r.Body).Decode(&u); err != nil {
+		errorcode.InvalidRequest.Render(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	switch {
+	case u.DisplayName == nil:
+		errorcode.InvalidRequest.Render(w, r, http.StatusBadRequest, "missing required Attribute: 'displayName'")
+		return
+	case u.OnPremisesSamAccountName == nil:
+		errorcode.InvalidRequest.Render(w, r, http.StatusBadRequest, "missing required Attribute: 'onPremisesSamAccountName'")
+		return
+	case !isValidUsername(*u.OnPremisesSamAccountName):
+		errorcode.InvalidRequest.Render(w, r, http.StatusBadRequest,
+			fmt.Sprintf("username '%s' must be at least the local part of an email", *u.OnPremisesSamAccountName))
+		return
+	case u.Mail == nil:
+		errorcode.InvalidRequest.Render(w, r, http.StatusBadRequest, "missing required Attribute: 'mail'")
+		return
+	case !isValidEmail(*u.Mail):
+		errorcode.InvalidRequest.Render(w, r, http.StatusBadRequest, fmt.Sprintf("'%s' is not a valid email address", *u.Mail))
+		return
+	case u.PrimaryRole == nil:
+		errorcode.InvalidRequest.Render(w, r, http.StatusBadRequest, "missing required Attribute: 'primaryRole'")
+		return
+	case u.Id != nil:
+		// like with PostUser the id is generated by the backend
+		errorcode.InvalidRequest.Render(w, r, http.StatusBadRequest, "user id is a read-only attribute")
+		return
+	}
+	if err := validatePrimaryRole(*u.PrimaryRole); err != nil {
+		renderError(w, r, err)
+		return
+	}
+	g.logger.Info().Str("user", *u.OnPremisesSamAccountName).Msg("Calling PostEducationUser")
+
+	created, err := eb.CreateEducationUser(r.Context(), u)
+	if err != nil {
+		renderError(w, r, err)
+		return
+	}
+
+	if g.roleService == nil {
+		errorcode.GeneralException.Render(w, r, http.StatusInternalServerError, "could not assign role to account: roleService not configured")
+		return
+	}
+	if _, err = g.roleService.AssignRoleToUser(r.Context(), &settings.AssignRoleToUserRequest{
+		AccountUuid: *created.Id,
+		RoleId:      settingssvc.BundleUUIDRoleUser,
+	}); err != nil {
+		errorcode.GeneralException.Render(w, r, http.StatusInternalServerError, fmt.Sprintf("could not assign role to account %s", err.Error()))
+		return
+	}
+
+	g.publishEvent(events.UserCreated{UserID: *created.Id})
+	render.Status(r, http.StatusCreated)
+	render.JSON(w, r, created)
+}
+
+// GetEducationUser returns an education user identified by name or id.
+func (g Graph) GetEducationUser(w http.ResponseWriter, r *http.Request) {
+	eb, ok := g.educationBackend(w, r)
+	if !ok {
+		return
+	}
+	userID, err := pathParam(r, "userID", "user")
+	if err != nil {
+		renderError(w, r, err)
+		return
+	}
+	g.logger.Info().Str("user", userID).Msg("Calling GetEducationUser")
+
+	user, err := eb.GetEducationUser(r.Context(), userID)
+	if err != nil {
+		renderError(w, r, err)
+		return
+	}
+	render.Status(r, http.StatusOK)
+	render.JSON(w, r, user)
+}
+
+// PatchEducationUser updates an education user.
+func (g Graph) PatchEducationUser(w http.ResponseWriter, r *http.Request) {
+	eb, ok := g.educationBackend(w, r)
+	if !ok {
+		return
+	}
+	userID, err := pathParam(r, "userID", "user")
+	if err != nil {
+		renderError(w, r, err)
+		return
+	}
+	changes := identity.EducationUser{}
+	if err := json.NewDecoder(r.Body).Decode(&changes); err != nil {
+		errorcode.InvalidRequest.Render(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+	if changes.Mail != nil && !isValidEmail(*changes.Mail) {
+		errorcode.InvalidRequest.Render(w, r, http.StatusBadRequest, fmt.Sprintf("'%s' is not a valid email address", *changes.Mail))
+		return
+	}
+	if changes.PrimaryRole != nil {
+		if err := validatePrimaryRole(*changes.PrimaryRole); err != nil {
+			renderError(w, r, err)
+			return
+		}
+	}
+	g.logger.Info().Str("user", userID).Msg("Calling PatchEducationUser")
+
+	user, err := eb.UpdateEducationUser(r.Context(), userID, changes)
+	if err != nil {
+		renderError(w, r, err)
+		return
+	}
+
+	var features []events.UserFeature
+	if changes.Mail != nil {
+		features = append(features, events.UserFeature{Name: "email", Value: *changes.Mail})
+	}
+	if changes.DisplayName != nil {
+		features = append(features, events.UserFeature{Name: "displayname", Value: *changes.DisplayName})
+	}
+	g.publishEvent(events.UserFeatureChanged{UserID: userID, Features: features})
+	render.Status(r, http.StatusOK)
+	render.JSON(w, r, user)
+}
+
+// DeleteEducationUser deletes an education user.
+func (g Graph) DeleteEducationUser(w http.ResponseWriter, r *http.Request) {
+	eb, ok := g.educationBackend(w, r)
+	if !ok {
+		return
+	}
+	userID, err := pathParam(r, "userID", "user")
+	if err != nil {
+		renderError(w, r, err)
+		return
+	}
+	g.logger.Info().Str("user", userID).Msg("Calling DeleteEducationUser")
+
+	if err := eb.DeleteEducationUser(r.Context(), userID); err != nil {
+		renderError(w, r, err)
+		return
+	}
+	g.publishEvent(events.UserDeleted{UserID: userID})
+	render.Status(r, http.StatusNoContent)
+	render.NoContent(w, r)
+}
+
+// educationBackend returns the backend of the education resources, an error is rendered if the identity backend
+// doesn't provide them.
+func (g Graph) educationBackend(w http.ResponseWriter, r *http.Request) (identity.EducationBackend, bool) {
+	if g.identityEducationBackend == nil {
+		errorcode.NotSupported.Render(w, r, http.StatusNotImplemented, "the identity backend does not support education resources")
+		return nil, false
+	}
+	return g.identityEducationBackend, true
+}
+
+// parseEducationRef returns the id of the resource referenced by the @odata.id of the request body, which has to
+// reference a resource of the given type.
+func (g Graph) parseEducationRef(r *http.Request, resourceType string) (string, error) {
+	ref := libregraph.NewMemberReference()
+	if err := json.NewDecoder(r.Body).Decode(ref); err != nil {
+		return "", errorcode.New(errorcode.InvalidRequest, err.Error())
+	}
+	refURL, ok := ref.GetOdataIdOk()
+	if !ok {
+		return "", errorcode.New(errorcode.InvalidRequest, "@odata.id reference is missing")
+	}
+	refType, id, err := g.parseMemberRef(*refURL)
+	if err != nil || id == "" {
+		return "", errorcode.New(errorcode.InvalidRequest, "error parsing @odata.id url")
+	}
+	if refType != resourceType {
+		return "", errorcode.New(errorcode.InvalidRequest, fmt.Sprintf("only %s can be referenced", resourceType))
+	}
+	return id, nil
+}
+
+// pathParam returns the unescaped URL parameter of the request, name describes the parameter in errors.
+func pathParam(r *http.Request, key string, name string) (string, error) {
+	value, err := url.PathUnescape(chi.URLParam(r, key))
+	if err != nil {
+		return "", errorcode.New(errorcode.InvalidRequest, fmt.Sprintf("unescaping %s id failed", name))
+	}
+	if value == "" {
+		return "", errorcode.New(errorcode.InvalidRequest, fmt.Sprintf("missing %s id", name))
+	}
+	return value, nil
+}
+
+func validatePrimaryRole(role string) error {
+	switch role {
+	case identity.EducationRoleStudent, identity.EducationRoleTeacher:
+		return nil
+	}
+	return errorcode.New(errorcode.InvalidRequest, fmt.Sprintf("invalid primaryRole '%s', must be '%s' or '%s'",
+		role, identity.EducationRoleStudent, identity.EducationRoleTeacher))
+}
@@ -0,0 +1,201 @@
+package svc_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+
+	"github.com/cs3org/reva/v2/pkg/events"
+	"github.com/go-chi/chi/v5"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	libregraph "github.com/owncloud/libre-graph-api-go"
+	"github.com/owncloud/ocis/extensions/graph/mocks"
+	"github.com/owncloud/ocis/extensions/graph/pkg/config/defaults"
+	"github.com/owncloud/ocis/extensions/graph/pkg/identity"
+	service "github.com/owncloud/ocis/extensions/graph/pkg/service/v0"
+	"github.com/owncloud/ocis/extensions/graph/pkg/service/v0/errorcode"
+	"github.com/stretchr/testify/mock"
+)
+
+var _ = Describe("Education", func() {
+	var (
+		svc              service.Service
+		educationBackend *mocks.EducationBackend
+		eventsPublisher  *mocks.Publisher
+		rr               *httptest.ResponseRecorder
+
+		school = &identity.EducationSchool{
+			Id:           libregraph.PtrString("schoolID"),
+			DisplayName:  libregraph.PtrString("Springfield Elementary"),
+			SchoolNumber: libregraph.PtrString("0042"),
+		}
+	)
+
+	// request creates a request with the url parameters in its route context.
+	request := func(method string, target string, body string, params map[string]string) *http.Request {
+		r := httptest.NewRequest(method, target, strings.NewReader(body))
+		rctx := chi.NewRouteContext()
+		for k, v := range params {
+			rctx.URLParams.Add(k, v)
+		}
+		return r.WithContext(context.WithValue(r.Context(), chi.RouteCtxKey, rctx))
+	}
+
+	BeforeEach(func() {
+		rr = httptest.NewRecorder()
+		educationBackend = &mocks.EducationBackend{}
+		eventsPublisher = &mocks.Publisher{}
+	})
+
+	JustBeforeEach(func() {
+		svc = service.NewService(
+			service.Config(defaults.DefaultConfig()),
+			service.WithGatewayClient(&mocks.GatewayClient{}),
+			service.WithIdentityBackend(&mocks.Backend{}),
+			service.WithIdentityEducationBackend(educationBackend),
+			service.EventsPublisher(eventsPublisher),
+		)
+	})
+
+	It("is not supported without an education backend", func() {
+		svc = service.NewService(
+			service.Config(defaults.DefaultConfig()),
+			service.WithGatewayClient(&mocks.GatewayClient{}),
+			service.WithIdentityBackend(&mocks.Backend{}),
+		)
+		r := request(http.MethodGet, "/graph/v1.0/education/schools", "", nil)
+		svc.(service.Graph).GetEducationSchools(rr, r)
+		Expect(rr.Code).To(Equal(http.StatusNotImplemented))
+	})
+
+	Describe("schools", func() {
+		It("lists the schools", func() {
+			other := &identity.EducationSchool{Id: libregraph.PtrString("otherID"), DisplayName: libregraph.PtrString("Shelbyville Elementary")}
+			educationBackend.On("GetEducationSchools", mock.Anything).Return([]*identity.EducationSchool{school, other}, nil)
+
+			r := request(http.MethodGet, "/graph/v1.0/education/schools?$top=1&$count=true", "", nil)
+			svc.(service.Graph).GetEducationSchools(rr, r)
+			Expect(rr.Code).To(Equal(http.StatusOK))
+
+			res := struct {
+				Value    []identity.EducationSchool
+				Count    int    `json:"@odata.count"`
+				NextLink string `json:"@odata.nextLink"`
+			}{}
+			err := json.Unmarshal(rr.Body.Bytes(), &res)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(len(res.Value)).To(Equal(1))
+			Expect(*res.Value[0].SchoolNumber).To(Equal("0042"))
+			Expect(res.Count).To(Equal(2))
+			Expect(res.NextLink).ToNot(BeEmpty())
+		})
+
+		It("creates a school", func() {
+			educationBackend.On("CreateEducationSchool", mock.Anything, mock.MatchedBy(func(s identity.EducationSchool) bool {
+				return *s.DisplayName == "Springfield Elementary"
+			})).Return(school, nil)
+
+			r := request(http.MethodPost, "/graph/v1.0/education/schools", `{"displayName":"Springfield Elementary","schoolNumber":"0042"}`, nil)
+			svc.(service.Graph).PostEducationSchool(rr, r)
+			Expect(rr.Code).To(Equal(http.StatusCreated))
+			Expect(rr.Body.String()).To(ContainSubstring(`"id":"schoolID"`))
+		})
+
+		It("rejects invalid schools", func() {
+			for _, body := range []string{`invalid`, `{}`, `{"id":"schoolID","displayName":"Springfield Elementary"}`} {
+				rr = httptest.NewRecorder()
+				r := request(http.MethodPost, "/graph/v1.0/education/schools", body, nil)
+				svc.(service.Graph).PostEducationSchool(rr, r)
+				Expect(rr.Code).To(Equal(http.StatusBadRequest), body)
+			}
+			educationBackend.AssertNotCalled(GinkgoT(), "CreateEducationSchool", mock.Anything, mock.Anything)
+		})
+
+		It("returns not found for unknown schools", func() {
+			educationBackend.On("GetEducationSchool", mock.Anything, "unknown").Return(nil, errorcode.New(errorcode.ItemNotFound, "not found"))
+
+			r := request(http.MethodGet, "/graph/v1.0/education/schools/unknown", "", map[string]string{"schoolID": "unknown"})
+			svc.(service.Graph).GetEducationSchool(rr, r)
+			Expect(rr.Code).To(Equal(http.StatusNotFound))
+		})
+
+		It("adds users to a school", func() {
+			educationBackend.On("AddUsersToEducationSchool", mock.Anything, "schoolID", []string{"userID"}).Return(nil)
+
+			r := request(http.MethodPost, "/graph/v1.0/education/schools/schoolID/users/$ref",
+				`{"@odata.id":"https://localhost:9200/graph/v1.0/education/users/userID"}`, map[string]string{"schoolID": "schoolID"})
+			svc.(service.Graph).PostEducationSchoolUser(rr, r)
+			Expect(rr.Code).To(Equal(http.StatusNoContent))
+			educationBackend.AssertExpectations(GinkgoT())
+		})
+
+		It("only adds classes as classes of a school", func() {
+			r := request(http.MethodPost, "/graph/v1.0/education/schools/schoolID/classes/$ref",
+				`{"@odata.id":"https://localhost:9200/graph/v1.0/education/users/userID"}`, map[string]string{"schoolID": "schoolID"})
+			svc.(service.Graph).PostEducationSchoolClass(rr, r)
+			Expect(rr.Code).To(Equal(http.StatusBadRequest))
+			educationBackend.AssertNotCalled(GinkgoT(), "AddClassesToEducationSchool", mock.Anything, mock.Anything, mock.Anything)
+		})
+	})
+
+	Describe("classes", func() {
+		It("removes members from a class", func() {
+			educationBackend.On("RemoveUserFromEducationClass", mock.Anything, "classID", "userID").Return(nil)
+			eventsPublisher.On("Publish", mock.Anything, mock.MatchedBy(func(ev events.GroupMemberRemoved) bool {
+				return ev.GroupID == "classID" && ev.UserID == "userID"
+			}), mock.Anything).Return(nil)
+
+			r := request(http.MethodDelete, "/graph/v1.0/education/classes/classID/members/userID/$ref", "",
+				map[string]string{"classID": "classID", "memberID": "userID"})
+			svc.(service.Graph).DeleteEducationClassMember(rr, r)
+			Expect(rr.Code).To(Equal(http.StatusNoContent))
+			educationBackend.AssertExpectations(GinkgoT())
+			eventsPublisher.AssertNumberOfCalls(GinkgoT(), "Publish", 1)
+		})
+
+		It("passes on errors of the backend", func() {
+			educationBackend.On("UpdateEducationClass", mock.Anything, "classID", mock.Anything).
+				Return(nil, errorcode.New(errorcode.NotSupported, "changing the class name is currently not supported"))
+
+			r := request(http.MethodPatch, "/graph/v1.0/education/classes/classID", `{"displayName":"7b"}`, map[string]string{"classID": "classID"})
+			svc.(service.Graph).PatchEducationClass(rr, r)
+			Expect(rr.Code).To(Equal(http.StatusNotImplemented))
+		})
+	})
+
+	Describe("users", func() {
+		It("validates the primary role", func() {
+			for _, body := range []string{
+				`{"displayName":"Bart","onPremisesSamAccountName":"bart","mail":"bart@example.org"}`,
+				`{"displayName":"Bart","onPremisesSamAccountName":"bart","mail":"bart@example.org","primaryRole":"principal"}`,
+			} {
+				rr = httptest.NewRecorder()
+				r := request(http.MethodPost, "/graph/v1.0/education/users", body, nil)
+				svc.(service.Graph).PostEducationUser(rr, r)
+				Expect(rr.Code).To(Equal(http.StatusBadRequest), body)
+			}
+
+			rr = httptest.NewRecorder()
+			r := request(http.MethodPatch, "/graph/v1.0/education/users/bart", `{"primaryRole":"principal"}`, map[string]string{"userID": "bart"})
+			svc.(service.Graph).PatchEducationUser(rr, r)
+			Expect(rr.Code).To(Equal(http.StatusBadRequest))
+			educationBackend.AssertNotCalled(GinkgoT(), "UpdateEducationUser", mock.Anything, mock.Anything, mock.Anything)
+		})
+
+		It("returns an education user", func() {
+			educationBackend.On("GetEducationUser", mock.Anything, "bart").Return(&identity.EducationUser{
+				Id:                       libregraph.PtrString("bartID"),
+				OnPremisesSamAccountName: libregraph.PtrString("bart"),
+				PrimaryRole:              libregraph.PtrString(identity.EducationRoleStudent),
+			}, nil)
+
+			r := request(http.MethodGet, "/graph/v1.0/education/users/bart", "", map[string]string{"userID": "bart"})
+			svc.(service.Graph).GetEducationUser(rr, r)
+			Expect(rr.Code).To(Equal(http.StatusOK))
+			Expect(rr.Body.String()).To(ContainSubstring(`"primaryRole":"student"`))
+		})
+	})
+})
@@ -50,6 +50,8 @@ const (
 	QuotaLimitReached
 	// Unauthenticated the caller is not authenticated.
 	Unauthenticated
+	// PasswordPolicyViolation defines the error if a new password doesn't satisfy the password policy.
+	PasswordPolicyViolation
 )
 
 var errorCodes = [...]string{
@@ -69,6 +71,7 @@ var errorCodes = [...]string{
 	"serviceNotAvailable",
 	"quotaLimitReached",
 	"unauthenticated",
+	"passwordPolicyViolation",
 }
 
 func New(e ErrorCode, msg string) Error {
@@ -99,8 +102,21 @@ func (e ErrorCode) Render(w http.ResponseWriter, r *http.Request, status int, ms
 
 func (e Error) Render(w http.ResponseWriter, r *http.Request) {
 	status := http.StatusInternalServerError
-	if e.errorCode == ItemNotFound {
+	switch e.errorCode {
+	case AccessDenied:
+		status = http.StatusForbidden
+	case ItemNotFound:
 		status = http.StatusNotFound
+	case NameAlreadyExists:
+		status = http.StatusConflict
+	case QuotaLimitReached:
+		status = http.StatusInsufficientStorage
+	case InvalidRange:
+		status = http.StatusRequestedRangeNotSatisfiable
+	case InvalidRequest, PasswordPolicyViolation:
+		status = http.StatusBadRequest
+	case NotSupported:
+		status = http.StatusNotImplemented
 	}
 	e.errorCode.Render(w, r, status, e.msg)
 }
@@ -0,0 +1,245 @@
+// Package filter implements the subset of the OData $filter query option supported by the graph service. A filter
+// is parsed into a tree of Nodes, which the identity backends compile into their own query language and which can
+// be evaluated against any object with the JSON representation of a graph resource.
+package filter
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/CiscoM31/godata"
+)
+
+// The supported operators.
+const (
+	OpEq         = "eq"
+	OpNe         = "ne"
+	OpStartsWith = "startswith"
+	OpAnd        = "and"
+	OpOr         = "or"
+	OpNot        = "not"
+	OpAny        = "any"
+)
+
+// Param is the query parameter holding the filter.
+const Param = "$filter"
+
+// ErrUnsupported is returned for valid OData filters using features the graph service does not implement.
+var ErrUnsupported = errors.New("unsupported filter")
+
+// Node is a node of a parsed filter.
+type Node struct {
+	// Op is one of the supported operators.
+	Op string
+	// Property is the path of the compared property, e.g. "owner/user/id". Inside of a lambda expression it is
+	// relative to the lambda variable, the empty path refers to the item itself. For any it is the path of the
+	// collection.
+	Property string
+	// Value is the compared value, a string, bool, int64 or nil.
+	Value interface{}
+	// Children are the operands of and, or and not, or the condition of any.
+	Children []*Node
+}
+
+// Parse reads the $filter of the query. It returns nil if the query has no filter.
+func Parse(query url.Values) (*Node, error) {
+	f := query.Get(Param)
+	if f == "" {
+		return nil, nil
+	}
+	q, err := godata.ParseFilterString(context.Background(), f)
+	if err != nil {
+		return nil, err
+	}
+	return convert(q.Tree, "")
+}
+
+// Contains reports if the filter uses the operator.
+func (n *Node) Contains(op string) bool {
+	if n.Op == op {
+		return true
+	}
+	for _, c := range n.Children {
+		if c.Contains(op) {
+			return true
+		}
+	}
+	return false
+}
+
+func unsupported(format string, a ...interface{}) error {
+	return fmt.Errorf("%w: %s", ErrUnsupported, fmt.Sprintf(format, a...))
+}
+
+func convert(n *godata.ParseNode, lambdaVar string) (*Node, error) {
+	switch n.Token.Type {
+	case godata.ExpressionTokenLogical:
+		switch n.Token.Value {
+		case OpAnd, OpOr:
+			left, err := convert(n.Children[0], lambdaVar)
+			if err != nil {
+				return nil, err
+			}
+			right, err := convert(n.Children[1], lambdaVar)
+			if err != nil {
+				return nil, err
+			}
+			return &Node{Op: n.Token.Value, Children: []*Node{left, right}}, nil
+		case OpNot:
+			child, err := convert(n.Children[0], lambdaVar)
+			if err != nil {
+				return nil, err
+			}
+			return &Node{Op: OpNot, Children: []*Node{child}}, nil
+		case OpEq, OpNe:
+			return comparison(n.Token.Value, n.Children[0], n.Children[1], lambdaVar)
+		}
+		return nil, unsupported("operator '%s'", n.Token.Value)
+	case godata.ExpressionTokenFunc:
+		if n.Token.Value == OpStartsWith && len(n.Children) == 2 {
+			c, err := comparison(OpStartsWith, n.Children[0], n.Children[1], lambdaVar)
+			if err != nil {
+				return nil, err
+			}
+			if _, ok := c.Value.(string); !ok {
+				return nil, unsupported("startswith requires a string")
+			}
+			return c, nil
+		}
+		return nil, unsupported("function '%s'", n.Token.Value)
+	case godata.ExpressionTokenLambdaNav:
+		lambda := n.Children[1]
+		if lambda.Token.Value != OpAny || len(lambda.Children) != 2 {
+			return nil, unsupported("lambda operator '%s'", lambda.Token.Value)
+		}
+		collection, err := property(n.Children[0], lambdaVar)
+		if err != nil {
+			return nil, err
+		}
+		condition, err := convert(lambda.Children[1], lambda.Children[0].Token.Value)
+		if err != nil {
+			return nil, err
+		}
+		return &Node{Op: OpAny, Property: collection, Children: []*Node{condition}}, nil
+	}
+	return nil, unsupported("expression '%s'", n.Token.Value)
+}
+
+func comparison(op string, left, right *godata.ParseNode, lambdaVar string) (*Node, error) {
+	p, err := property(left, lambdaVar)
+	if err != nil {
+		return nil, err
+	}
+	v, err := value(right)
+	if err != nil {
+		return nil, err
+	}
+	return &Node{Op: op, Property: p, Value: v}, nil
+}
+
+// property returns the path of a property, relative to the lambda variable if one is given.
+func property(n *godata.ParseNode, lambdaVar string) (string, error) {
+	var segments []string
+	for n.Token.Type == godata.ExpressionTokenNav {
+		if n.Children[1].Token.Type != godata.ExpressionTokenLiteral {
+			return "", unsupported("property '%s'", n.Children[1].Token.Value)
+		}
+		segments = append([]string{n.Children[1].Token.Value}, segments...)
+		n = n.Children[0]
+	}
+	if n.Token.Type != godata.ExpressionTokenLiteral {
+		return "", unsupported("operand '%s', the left operand must be a property", n.Token.Value)
+	}
+	switch {
+	case lambdaVar == "":
+		segments = append([]string{n.Token.Value}, segments...)
+	case n.Token.Value != lambdaVar:
+		return "", unsupported("property '%s' inside of a lambda expression", n.Token.Value)
+	}
+	return strings.Join(segments, "/"), nil
+}
+
+func value(n *godata.ParseNode) (interface{}, error) {
+	switch n.Token.Type {
+	case godata.ExpressionTokenString:
+		v := strings.TrimSuffix(strings.TrimPrefix(n.Token.Value, "'"), "'")
+		return strings.ReplaceAll(v, "''", "'"), nil
+	case godata.ExpressionTokenBoolean:
+		return n.Token.Value == "true", nil
+	case godata.ExpressionTokenInteger:
+		return strconv.ParseInt(n.Token.Value, 10, 64)
+	case godata.ExpressionTokenNull:
+		return nil, nil
+	}
+	return nil, unsupported("value '%s'", n.Token.Value)
+}
+
+// Match evaluates the filter against the JSON representation of v. String comparisons ignore the case.
+func (n *Node) Match(v interface{}) bool {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return false
+	}
+	var item interface{}
+	if err := json.Unmarshal(b, &item); err != nil {
+		return false
+	}
+	return n.match(item)
+}
+
+func (n *Node) match(item interface{}) bool {
+	switch n.Op {
+	case OpAnd:
+		return n.Children[0].match(item) && n.Children[1].match(item)
+	case OpOr:
+		return n.Children[0].match(item) || n.Children[1].match(item)
+	case OpNot:
+		return !n.Children[0].match(item)
+	case OpEq:
+		return equal(lookup(item, n.Property), n.Value)
+	case OpNe:
+		return !equal(lookup(item, n.Property), n.Value)
+	case OpStartsWith:
+		s, ok := lookup(item, n.Property).(string)
+		return ok && strings.HasPrefix(strings.ToLower(s), strings.ToLower(n.Value.(string)))
+	case OpAny:
+		collection, _ := lookup(item, n.Property).([]interface{})
+		for _, element := range collection {
+			if n.Children[0].match(element) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func lookup(item interface{}, path string) interface{} {
+	if path == "" {
+		return item
+	}
+	for _, segment := range strings.Split(path, "/") {
+		m, ok := item.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		item = m[segment]
+	}
+	return item
+}
+
+func equal(actual, expected interface{}) bool {
+	switch e := expected.(type) {
+	case string:
+		a, ok := actual.(string)
+		return ok && strings.EqualFold(a, e)
+	case int64:
+		a, ok := actual.(float64)
+		return ok && a == float64(e)
+	}
+	return actual == expected
+}
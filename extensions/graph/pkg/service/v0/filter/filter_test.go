@@ -0,0 +1,106 @@
+package filter
+
+import (
+	"errors"
+	"net/url"
+	"testing"
+)
+
+func parse(t *testing.T, f string) (*Node, error) {
+	t.Helper()
+	return Parse(url.Values{Param: {f}})
+}
+
+func TestParse(t *testing.T) {
+	n, err := parse(t, "driveType eq 'project' and not(startswith(name,'it''s'))")
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if n.Op != OpAnd || len(n.Children) != 2 {
+		t.Fatalf("expected an and node got %+v", n)
+	}
+	if c := n.Children[0]; c.Op != OpEq || c.Property != "driveType" || c.Value != "project" {
+		t.Errorf("unexpected comparison %+v", c)
+	}
+	if c := n.Children[1]; c.Op != OpNot || c.Children[0].Op != OpStartsWith || c.Children[0].Value != "it's" {
+		t.Errorf("unexpected negation %+v", c.Children[0])
+	}
+
+	n, err = parse(t, "memberOf/any(g:g/id eq 'a') or owner/user/id ne null")
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if c := n.Children[0]; c.Op != OpAny || c.Property != "memberOf" || c.Children[0].Property != "id" {
+		t.Errorf("unexpected lambda %+v", c)
+	}
+	if c := n.Children[1]; c.Op != OpNe || c.Property != "owner/user/id" || c.Value != nil {
+		t.Errorf("unexpected comparison %+v", c)
+	}
+	if !n.Contains(OpAny) || n.Contains(OpStartsWith) {
+		t.Error("unexpected result of Contains")
+	}
+
+	if n, err := Parse(url.Values{}); n != nil || err != nil {
+		t.Errorf("expected no filter got %v %v", n, err)
+	}
+}
+
+func TestParseUnsupported(t *testing.T) {
+	for _, f := range []string{
+		"contains(name,'a')",
+		"quota/used gt 10",
+		"members/all(m:m/id eq 'a')",
+		"members/any(m:other/id eq 'a')",
+		"'a' eq name",
+		"startswith(name,1)",
+	} {
+		if _, err := parse(t, f); !errors.Is(err, ErrUnsupported) {
+			t.Errorf("%s: expected ErrUnsupported got %v", f, err)
+		}
+	}
+
+	if _, err := parse(t, "name eq"); err == nil || errors.Is(err, ErrUnsupported) {
+		t.Errorf("expected a syntax error got %v", err)
+	}
+}
+
+func TestMatch(t *testing.T) {
+	drive := map[string]interface{}{
+		"name":      "Marketing",
+		"driveType": "project",
+		"quota":     map[string]interface{}{"total": 10},
+		"root": map[string]interface{}{
+			"permissions": []interface{}{
+				map[string]interface{}{
+					"roles":     []interface{}{"manager"},
+					"grantedTo": []interface{}{map[string]interface{}{"user": map[string]interface{}{"id": "einstein"}}},
+				},
+			},
+		},
+	}
+
+	tests := []struct {
+		filter string
+		match  bool
+	}{
+		{"name eq 'marketing'", true},
+		{"name ne 'Marketing'", false},
+		{"startswith(name,'mark') and driveType eq 'project'", true},
+		{"driveType eq 'personal' or quota/total eq 10", true},
+		{"not(quota/total eq 10)", false},
+		{"description eq null", true},
+		{"root/permissions/any(p:p/grantedTo/any(i:i/user/id eq 'einstein'))", true},
+		{"root/permissions/any(p:p/grantedTo/any(i:i/user/id eq 'marie'))", false},
+		{"root/permissions/any(p:p/roles/any(r:r eq 'manager'))", true},
+	}
+	for _, tt := range tests {
+		n, err := parse(t, tt.filter)
+		if err != nil {
+			t.Errorf("%s: unexpected error %v", tt.filter, err)
+			continue
+		}
+		if got := n.Match(drive); got != tt.match {
+			t.Errorf("%s: expected %v got %v", tt.filter, tt.match, got)
+		}
+	}
+}
@@ -2,15 +2,23 @@ package svc
 
 import (
 	"context"
+	"errors"
+	"image"
 	"net/http"
 
 	"github.com/ReneKroon/ttlcache/v2"
 	gateway "github.com/cs3org/go-cs3apis/cs3/gateway/v1beta1"
+	collaboration "github.com/cs3org/go-cs3apis/cs3/sharing/collaboration/v1beta1"
 	provider "github.com/cs3org/go-cs3apis/cs3/storage/provider/v1beta1"
 	"github.com/cs3org/reva/v2/pkg/events"
 	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/render"
 	"github.com/owncloud/ocis/extensions/graph/pkg/config"
 	"github.com/owncloud/ocis/extensions/graph/pkg/identity"
+	"github.com/owncloud/ocis/extensions/graph/pkg/service/v0/errorcode"
+	"github.com/owncloud/ocis/extensions/graph/pkg/service/v0/paging"
+	"github.com/owncloud/ocis/extensions/graph/pkg/service/v0/photo"
+	"github.com/owncloud/ocis/extensions/graph/pkg/service/v0/projection"
 	"github.com/owncloud/ocis/ocis-pkg/log"
 	settingssvc "github.com/owncloud/ocis/protogen/gen/ocis/services/settings/v0"
 	mevents "go-micro.dev/v4/events"
@@ -23,6 +31,9 @@ import (
 type GatewayClient interface {
 	//gateway.GatewayAPIClient
 
+	// Authenticate authenticates a client.
+	Authenticate(ctx context.Context, in *gateway.AuthenticateRequest, opts ...grpc.CallOption) (*gateway.AuthenticateResponse, error)
+
 	// Returns the home path for the given authenticated user.
 	// When a user has access to multiple storage providers, one of them is the home.
 	GetHome(ctx context.Context, in *provider.GetHomeRequest, opts ...grpc.CallOption) (*provider.GetHomeResponse, error)
@@ -38,6 +49,26 @@ type GatewayClient interface {
 	// Initiates the download of a file using an
 	// out-of-band data transfer mechanism.
 	InitiateFileDownload(ctx context.Context, in *provider.InitiateFileDownloadRequest, opts ...grpc.CallOption) (*gateway.InitiateFileDownloadResponse, error)
+	// Initiates the upload of a file using an
+	// out-of-band data transfer mechanism.
+	InitiateFileUpload(ctx context.Context, in *provider.InitiateFileUploadRequest, opts ...grpc.CallOption) (*gateway.InitiateFileUploadResponse, error)
+	// Creates a new resource of type container.
+	// MUST return CODE_PRECONDITION_FAILED if the container
+	// cannot be created at the specified reference.
+	CreateContainer(ctx context.Context, in *provider.CreateContainerRequest, opts ...grpc.CallOption) (*provider.CreateContainerResponse, error)
+	// Deletes a resource.
+	// If a resource specifies the non-empty container (directory, folder),
+	// it will be deleted recursively.
+	// MUST return CODE_NOT_FOUND if the reference does not exist.
+	Delete(ctx context.Context, in *provider.DeleteRequest, opts ...grpc.CallOption) (*provider.DeleteResponse, error)
+	// Moves a resource from one reference to another.
+	// MUST return CODE_NOT_FOUND if any of the references do not exist.
+	// MUST return CODE_PRECONDITION_FAILED if the source reference
+	// cannot be moved to the destination reference.
+	Move(ctx context.Context, in *provider.MoveRequest, opts ...grpc.CallOption) (*provider.MoveResponse, error)
+	// Returns a list of recycle items available
+	// in the recycle bin of the provided reference.
+	ListRecycle(ctx context.Context, in *provider.ListRecycleRequest, opts ...grpc.CallOption) (*provider.ListRecycleResponse, error)
 	// Creates a storage space.
 	CreateStorageSpace(ctx context.Context, in *provider.CreateStorageSpaceRequest, opts ...grpc.CallOption) (*provider.CreateStorageSpaceResponse, error)
 	// Lists storage spaces.
@@ -51,6 +82,15 @@ type GatewayClient interface {
 	// MUST return CODE_NOT_FOUND if the reference does not exist
 	// MUST return CODE_RESOURCE_EXHAUSTED on exceeded quota limits.
 	GetQuota(ctx context.Context, in *gateway.GetQuotaRequest, opts ...grpc.CallOption) (*provider.GetQuotaResponse, error)
+	// Creates a new share.
+	// MUST return CODE_NOT_FOUND if the resource reference does not exist.
+	// MUST return CODE_ALREADY_EXISTS if the share already exists for the 4-tuple consisting of
+	// (owner, shared_resource, grantee).
+	// New shares MUST be created in the state SHARE_STATE_PENDING.
+	CreateShare(ctx context.Context, in *collaboration.CreateShareRequest, opts ...grpc.CallOption) (*collaboration.CreateShareResponse, error)
+	// Removes a share.
+	// MUST return CODE_NOT_FOUND if the share reference does not exist.
+	RemoveShare(ctx context.Context, in *collaboration.RemoveShareRequest, opts ...grpc.CallOption) (*collaboration.RemoveShareResponse, error)
 }
 
 // Publisher is the interface for events publisher
@@ -77,6 +117,18 @@ type Graph struct {
 	roleService          settingssvc.RoleService
 	spacePropertiesCache *ttlcache.Cache
 	eventsPublisher      events.Publisher
+
+	// identityEducationBackend is nil if the identity backend doesn't provide the education resources
+	identityEducationBackend identity.EducationBackend
+	// passwordVerifier is nil if the identity backend can't verify passwords, they are verified by the gateway then
+	passwordVerifier identity.PasswordVerifier
+
+	// photoStore holds the profile photos of the users, which are resized to photoSize
+	photoStore photo.Store
+	photoSize  image.Rectangle
+
+	// uploadSessionKeys sign the upload sessions and encrypt their transfer tokens
+	uploadSessionKeys uploadSessionKeys
 }
 
 // ServeHTTP implements the Service interface.
@@ -103,7 +155,39 @@ func (g Graph) publishEvent(ev interface{}) {
 }
 
 type listResponse struct {
-	Value interface{} `json:"value,omitempty"`
+	Value     interface{} `json:"value,omitempty"`
+	Count     *int        `json:"@odata.count,omitempty"`
+	NextLink  string      `json:"@odata.nextLink,omitempty"`
+	DeltaLink string      `json:"@odata.deltaLink,omitempty"`
+}
+
+// parsePage reads the paging options of a collection request, limited by the configured max page size.
+func (g Graph) parsePage(r *http.Request) (paging.Page, error) {
+	return paging.Parse(r.URL.Query(), g.config.API.MaxPageSize)
+}
+
+// renderPage renders a page of a collection annotated with the total count and the link to the next page.
+func (g Graph) renderPage(w http.ResponseWriter, r *http.Request, value interface{}, info paging.Info) {
+	res := &listResponse{Value: value, Count: info.Count}
+	if info.NextToken != "" {
+		// the graph service is only reachable through the proxy, so the link is based on the public ocis url
+		res.NextLink = paging.NextLink(g.config.Spaces.WebDavBase, r.URL.Path, r.URL.Query(), info.NextToken)
+	}
+	render.Status(r, http.StatusOK)
+	render.JSON(w, r, res)
+}
+
+// parseProjection reads the $select and $expand options of a request, only the given navigation properties can be
+// expanded.
+func parseProjection(r *http.Request, expandable ...string) (projection.Projection, error) {
+	p, err := projection.Parse(r.URL.Query(), expandable...)
+	switch {
+	case errors.Is(err, projection.ErrUnsupported):
+		return p, errorcode.New(errorcode.NotSupported, err.Error())
+	case err != nil:
+		return p, errorcode.New(errorcode.InvalidRequest, err.Error())
+	}
+	return p, nil
 }
 
 const (
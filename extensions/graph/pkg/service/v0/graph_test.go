@@ -7,19 +7,24 @@ import (
 	"io"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
+	"strings"
 	"time"
 
 	gateway "github.com/cs3org/go-cs3apis/cs3/gateway/v1beta1"
 	provider "github.com/cs3org/go-cs3apis/cs3/storage/provider/v1beta1"
 	typesv1beta1 "github.com/cs3org/go-cs3apis/cs3/types/v1beta1"
 	"github.com/cs3org/reva/v2/pkg/rgrpc/status"
+	"github.com/go-chi/chi/v5"
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
 	libregraph "github.com/owncloud/libre-graph-api-go"
 	"github.com/owncloud/ocis/extensions/graph/mocks"
 	"github.com/owncloud/ocis/extensions/graph/pkg/config/defaults"
+	"github.com/owncloud/ocis/extensions/graph/pkg/identity"
 	service "github.com/owncloud/ocis/extensions/graph/pkg/service/v0"
 	"github.com/owncloud/ocis/extensions/graph/pkg/service/v0/errorcode"
+	"github.com/owncloud/ocis/extensions/graph/pkg/service/v0/paging"
 	"github.com/stretchr/testify/mock"
 )
 
@@ -29,6 +34,7 @@ var _ = Describe("Graph", func() {
 		gatewayClient   *mocks.GatewayClient
 		httpClient      *mocks.HTTPClient
 		eventsPublisher mocks.Publisher
+		identityBackend *mocks.Backend
 		ctx             context.Context
 	)
 
@@ -37,11 +43,13 @@ var _ = Describe("Graph", func() {
 		gatewayClient = &mocks.GatewayClient{}
 		httpClient = &mocks.HTTPClient{}
 		eventsPublisher = mocks.Publisher{}
+		identityBackend = &mocks.Backend{}
 		svc = service.NewService(
 			service.Config(defaults.DefaultConfig()),
 			service.WithGatewayClient(gatewayClient),
 			service.WithHTTPClient(httpClient),
 			service.EventsPublisher(&eventsPublisher),
+			service.WithIdentityBackend(identityBackend),
 		)
 	})
 
@@ -188,6 +196,115 @@ var _ = Describe("Graph", func() {
 			}
 			`))
 		})
+		It("can page through the spaces", func() {
+			spaces := []*provider.StorageSpace{}
+			for _, id := range []string{"cID", "aID", "bID"} {
+				spaces = append(spaces, &provider.StorageSpace{
+					Id:        &provider.StorageSpaceId{OpaqueId: id},
+					SpaceType: "project",
+					Root:      &provider.ResourceId{StorageId: id, OpaqueId: id},
+					Name:      id,
+				})
+			}
+			gatewayClient.On("ListStorageSpaces", mock.Anything, mock.Anything).Return(&provider.ListStorageSpacesResponse{
+				Status:        status.NewOK(ctx),
+				StorageSpaces: spaces,
+			}, nil)
+			gatewayClient.On("InitiateFileDownload", mock.Anything, mock.Anything).Return(&gateway.InitiateFileDownloadResponse{
+				Status: status.NewNotFound(ctx, "not found"),
+			}, nil)
+			gatewayClient.On("GetQuota", mock.Anything, mock.Anything).Return(&provider.GetQuotaResponse{
+				Status: status.NewUnimplemented(ctx, fmt.Errorf("not supported"), "not supported"),
+			}, nil)
+
+			r := httptest.NewRequest(http.MethodGet, "/graph/v1.0/me/drives?$top=2&$count=true", nil)
+			rr := httptest.NewRecorder()
+			svc.GetDrives(rr, r)
+			Expect(rr.Code).To(Equal(http.StatusOK))
+
+			page := struct {
+				Value    []*libregraph.Drive
+				Count    int    `json:"@odata.count"`
+				NextLink string `json:"@odata.nextLink"`
+			}{}
+			err := json.Unmarshal(rr.Body.Bytes(), &page)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(page.Count).To(Equal(3))
+			Expect(len(page.Value)).To(Equal(2))
+			Expect(*page.Value[0].Id).To(Equal("aID"))
+			Expect(*page.Value[1].Id).To(Equal("bID"))
+			Expect(page.NextLink).To(HavePrefix("https://localhost:9200/graph/v1.0/me/drives?"))
+
+			r = httptest.NewRequest(http.MethodGet, strings.TrimPrefix(page.NextLink, "https://localhost:9200"), nil)
+			rr = httptest.NewRecorder()
+			svc.GetDrives(rr, r)
+			Expect(rr.Code).To(Equal(http.StatusOK))
+
+			page.NextLink = ""
+			err = json.Unmarshal(rr.Body.Bytes(), &page)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(len(page.Value)).To(Equal(1))
+			Expect(*page.Value[0].Id).To(Equal("cID"))
+			Expect(page.NextLink).To(BeEmpty())
+		})
+		It("can filter the spaces", func() {
+			spaces := []*provider.StorageSpace{}
+			for _, id := range []string{"alpha", "beta", "gamma"} {
+				spaces = append(spaces, &provider.StorageSpace{
+					Id:        &provider.StorageSpaceId{OpaqueId: id},
+					SpaceType: "project",
+					Root:      &provider.ResourceId{StorageId: id, OpaqueId: id},
+					Name:      id,
+				})
+			}
+			gatewayClient.On("ListStorageSpaces", mock.Anything, mock.MatchedBy(func(req *provider.ListStorageSpacesRequest) bool {
+				return len(req.Filters) == 1 &&
+					req.Filters[0].Type == provider.ListStorageSpacesRequest_Filter_TYPE_SPACE_TYPE &&
+					req.Filters[0].GetSpaceType() == "project"
+			})).Return(&provider.ListStorageSpacesResponse{
+				Status:        status.NewOK(ctx),
+				StorageSpaces: spaces,
+			}, nil)
+			gatewayClient.On("InitiateFileDownload", mock.Anything, mock.Anything).Return(&gateway.InitiateFileDownloadResponse{
+				Status: status.NewNotFound(ctx, "not found"),
+			}, nil)
+			gatewayClient.On("GetQuota", mock.Anything, mock.Anything).Return(&provider.GetQuotaResponse{
+				Status: status.NewUnimplemented(ctx, fmt.Errorf("not supported"), "not supported"),
+			}, nil)
+
+			q := url.Values{"$filter": {"driveType eq 'project' and (startswith(name,'b') or name eq 'Gamma')"}}
+			r := httptest.NewRequest(http.MethodGet, "/graph/v1.0/me/drives?"+q.Encode(), nil)
+			rr := httptest.NewRecorder()
+			svc.GetDrives(rr, r)
+			Expect(rr.Code).To(Equal(http.StatusOK))
+
+			res := struct {
+				Value []*libregraph.Drive
+			}{}
+			err := json.Unmarshal(rr.Body.Bytes(), &res)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(len(res.Value)).To(Equal(2))
+			Expect(*res.Value[0].Id).To(Equal("beta"))
+			Expect(*res.Value[1].Id).To(Equal("gamma"))
+		})
+		It("rejects unsupported filters", func() {
+			q := url.Values{"$filter": {"contains(name,'a')"}}
+			r := httptest.NewRequest(http.MethodGet, "/graph/v1.0/me/drives?"+q.Encode(), nil)
+			rr := httptest.NewRecorder()
+			svc.GetDrives(rr, r)
+			Expect(rr.Code).To(Equal(http.StatusNotImplemented))
+		})
+		It("rejects an invalid skip token", func() {
+			gatewayClient.On("ListStorageSpaces", mock.Anything, mock.Anything).Return(&provider.ListStorageSpacesResponse{
+				Status:        status.NewOK(ctx),
+				StorageSpaces: []*provider.StorageSpace{},
+			}, nil)
+
+			r := httptest.NewRequest(http.MethodGet, "/graph/v1.0/me/drives?$skiptoken=invalid", nil)
+			rr := httptest.NewRecorder()
+			svc.GetDrives(rr, r)
+			Expect(rr.Code).To(Equal(http.StatusBadRequest))
+		})
 		It("can list a spaces type mountpoint", func() {
 			gatewayClient.On("ListStorageSpaces", mock.Anything, mock.Anything).Return(&provider.ListStorageSpacesResponse{
 				Status: status.NewOK(ctx),
@@ -277,6 +394,55 @@ var _ = Describe("Graph", func() {
 			Expect(libreError.Error.Message).To(Equal("we do not support <owner> as a order parameter"))
 			Expect(libreError.Error.Code).To(Equal(errorcode.InvalidRequest.String()))
 		})
+		It("can expand the root of the drives", func() {
+			gatewayClient.On("ListStorageSpaces", mock.Anything, mock.Anything).Return(&provider.ListStorageSpacesResponse{
+				Status: status.NewOK(ctx),
+				StorageSpaces: []*provider.StorageSpace{
+					{
+						Id:        &provider.StorageSpaceId{OpaqueId: "aID"},
+						SpaceType: "project",
+						Root:      &provider.ResourceId{StorageId: "aID", OpaqueId: "aID"},
+						Name:      "aspacename",
+					},
+				},
+			}, nil)
+			gatewayClient.On("InitiateFileDownload", mock.Anything, mock.Anything).Return(&gateway.InitiateFileDownloadResponse{
+				Status: status.NewNotFound(ctx, "not found"),
+			}, nil)
+			gatewayClient.On("GetQuota", mock.Anything, mock.Anything).Return(&provider.GetQuotaResponse{
+				Status: status.NewUnimplemented(ctx, fmt.Errorf("not supported"), "not supported"),
+			}, nil)
+			gatewayClient.On("Stat", mock.Anything, mock.Anything).Return(&provider.StatResponse{
+				Status: status.NewOK(ctx),
+				Info: &provider.ResourceInfo{
+					Id:    &provider.ResourceId{StorageId: "aID", OpaqueId: "aID"},
+					Type:  provider.ResourceType_RESOURCE_TYPE_CONTAINER,
+					Path:  "/",
+					Etag:  "101112131415",
+					Size:  42,
+					Mtime: &typesv1beta1.Timestamp{Seconds: 1648327606, Nanos: 0},
+				},
+			}, nil)
+
+			r := httptest.NewRequest(http.MethodGet, "/graph/v1.0/me/drives?$select=name&$expand=root", nil)
+			rr := httptest.NewRecorder()
+			svc.GetDrives(rr, r)
+			Expect(rr.Code).To(Equal(http.StatusOK))
+
+			res := struct {
+				Value []map[string]interface{}
+			}{}
+			err := json.Unmarshal(rr.Body.Bytes(), &res)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(len(res.Value)).To(Equal(1))
+			Expect(res.Value[0]).To(HaveLen(3))
+			Expect(res.Value[0]["id"]).To(Equal("aID"))
+			Expect(res.Value[0]["name"]).To(Equal("aspacename"))
+			root := res.Value[0]["root"].(map[string]interface{})
+			Expect(root["size"]).To(BeEquivalentTo(42))
+			Expect(root["eTag"]).To(Equal("101112131415"))
+			Expect(root["webDavUrl"]).To(Equal("https://localhost:9200/dav/spaces/aID"))
+		})
 		It("can list a spaces with invalid query parameter", func() {
 			gatewayClient.On("ListStorageSpaces", mock.Anything, mock.Anything).Return(&provider.ListStorageSpacesResponse{
 				Status:        status.NewOK(ctx),
@@ -301,4 +467,144 @@ var _ = Describe("Graph", func() {
 			Expect(libreError.Error.Code).To(Equal(errorcode.InvalidRequest.String()))
 		})
 	})
+
+	Describe("users", func() {
+		It("can expand the groups of the users", func() {
+			identityBackend.On("GetUsers", mock.Anything, mock.Anything).Return([]*libregraph.User{
+				{
+					Id:          libregraph.PtrString("einstein"),
+					DisplayName: libregraph.PtrString("Albert Einstein"),
+					Mail:        libregraph.PtrString("einstein@example.org"),
+				},
+			}, paging.Info{}, nil)
+			identityBackend.On("GetUsersGroups", mock.Anything, []string{"einstein"}).Return(map[string][]*libregraph.Group{
+				"einstein": {{Id: libregraph.PtrString("physics"), DisplayName: libregraph.PtrString("Physics")}},
+			}, nil).Once()
+
+			r := httptest.NewRequest(http.MethodGet, "/graph/v1.0/users?$select=displayName&$expand=memberOf", nil)
+			rr := httptest.NewRecorder()
+			svc.GetUsers(rr, r)
+			Expect(rr.Code).To(Equal(http.StatusOK))
+
+			res := struct {
+				Value []struct {
+					ID          string             `json:"id"`
+					DisplayName string             `json:"displayName"`
+					Mail        string             `json:"mail"`
+					MemberOf    []libregraph.Group `json:"memberOf"`
+				}
+			}{}
+			err := json.Unmarshal(rr.Body.Bytes(), &res)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(len(res.Value)).To(Equal(1))
+			Expect(res.Value[0].ID).To(Equal("einstein"))
+			Expect(res.Value[0].DisplayName).To(Equal("Albert Einstein"))
+			Expect(res.Value[0].Mail).To(BeEmpty())
+			Expect(len(res.Value[0].MemberOf)).To(Equal(1))
+			Expect(res.Value[0].MemberOf[0].GetDisplayName()).To(Equal("Physics"))
+		})
+		It("rejects expanding unknown properties", func() {
+			r := httptest.NewRequest(http.MethodGet, "/graph/v1.0/users?$expand=drives", nil)
+			rr := httptest.NewRecorder()
+			svc.GetUsers(rr, r)
+			Expect(rr.Code).To(Equal(http.StatusBadRequest))
+		})
+		It("lists the transitive groups of a user", func() {
+			identityBackend.On("GetUserTransitiveGroups", mock.Anything, "einstein").Return([]*libregraph.Group{
+				{Id: libregraph.PtrString("physics"), DisplayName: libregraph.PtrString("Physics")},
+				{Id: libregraph.PtrString("science"), DisplayName: libregraph.PtrString("Science")},
+			}, nil)
+
+			r := httptest.NewRequest(http.MethodGet, "/graph/v1.0/users/einstein/transitiveMemberOf?$count=true", nil)
+			rctx := chi.NewRouteContext()
+			rctx.URLParams.Add("userID", "einstein")
+			r = r.WithContext(context.WithValue(r.Context(), chi.RouteCtxKey, rctx))
+			rr := httptest.NewRecorder()
+			svc.(service.Graph).GetUserTransitiveMemberOf(rr, r)
+			Expect(rr.Code).To(Equal(http.StatusOK))
+
+			res := struct {
+				Value []libregraph.Group
+				Count int `json:"@odata.count"`
+			}{}
+			err := json.Unmarshal(rr.Body.Bytes(), &res)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(res.Count).To(Equal(2))
+			Expect(res.Value[1].GetDisplayName()).To(Equal("Science"))
+		})
+	})
+
+	Describe("groups", func() {
+		It("can expand the members of the groups", func() {
+			identityBackend.On("GetGroups", mock.Anything, mock.Anything).Return([]*libregraph.Group{
+				{Id: libregraph.PtrString("physics"), DisplayName: libregraph.PtrString("Physics")},
+			}, paging.Info{}, nil)
+			identityBackend.On("GetGroupMembers", mock.Anything, "physics").Return([]*libregraph.User{
+				{Id: libregraph.PtrString("einstein"), DisplayName: libregraph.PtrString("Albert Einstein")},
+			}, nil)
+
+			r := httptest.NewRequest(http.MethodGet, "/graph/v1.0/groups?$expand=members", nil)
+			rr := httptest.NewRecorder()
+			svc.GetGroups(rr, r)
+			Expect(rr.Code).To(Equal(http.StatusOK))
+
+			res := struct {
+				Value []struct {
+					DisplayName string            `json:"displayName"`
+					Members     []libregraph.User `json:"members"`
+				}
+			}{}
+			err := json.Unmarshal(rr.Body.Bytes(), &res)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(len(res.Value)).To(Equal(1))
+			Expect(res.Value[0].DisplayName).To(Equal("Physics"))
+			Expect(len(res.Value[0].Members)).To(Equal(1))
+			Expect(res.Value[0].Members[0].GetId()).To(Equal("einstein"))
+		})
+		It("lists the transitive members of a group", func() {
+			identityBackend.On("GetGroupTransitiveMembers", mock.Anything, "science").Return([]*libregraph.User{
+				{Id: libregraph.PtrString("einstein"), DisplayName: libregraph.PtrString("Albert Einstein")},
+				{Id: libregraph.PtrString("curie"), DisplayName: libregraph.PtrString("Marie Curie")},
+			}, nil)
+
+			r := httptest.NewRequest(http.MethodGet, "/graph/v1.0/groups/science/transitiveMembers?$top=1", nil)
+			rctx := chi.NewRouteContext()
+			rctx.URLParams.Add("groupID", "science")
+			r = r.WithContext(context.WithValue(r.Context(), chi.RouteCtxKey, rctx))
+			rr := httptest.NewRecorder()
+			svc.(service.Graph).GetGroupTransitiveMembers(rr, r)
+			Expect(rr.Code).To(Equal(http.StatusOK))
+
+			res := struct {
+				Value    []libregraph.User
+				NextLink string `json:"@odata.nextLink"`
+			}{}
+			err := json.Unmarshal(rr.Body.Bytes(), &res)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(len(res.Value)).To(Equal(1))
+			Expect(res.Value[0].GetId()).To(Equal("einstein"))
+			Expect(res.NextLink).ToNot(BeEmpty())
+		})
+	})
+
+	Describe("capabilities", func() {
+		It("reports the capabilities of the identity backend", func() {
+			identityBackend.On("Capabilities", mock.Anything).Return(identity.Capabilities{ListGroupMembers: true})
+
+			r := httptest.NewRequest(http.MethodGet, "/graph/v1.0/capabilities", nil)
+			rr := httptest.NewRecorder()
+			svc.(service.Graph).GetCapabilities(rr, r)
+			Expect(rr.Code).To(Equal(http.StatusOK))
+
+			res := struct {
+				Identity  identity.Capabilities
+				Education bool
+			}{}
+			err := json.Unmarshal(rr.Body.Bytes(), &res)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(res.Identity.ListGroupMembers).To(BeTrue())
+			Expect(res.Identity.CreateUser).To(BeFalse())
+			Expect(res.Education).To(BeFalse())
+		})
+	})
 })
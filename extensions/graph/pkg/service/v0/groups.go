@@ -1,6 +1,7 @@
 package svc
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -12,6 +13,8 @@ import (
 	"github.com/CiscoM31/godata"
 	libregraph "github.com/owncloud/libre-graph-api-go"
 	"github.com/owncloud/ocis/extensions/graph/pkg/service/v0/errorcode"
+	"github.com/owncloud/ocis/extensions/graph/pkg/service/v0/paging"
+	"github.com/owncloud/ocis/extensions/graph/pkg/service/v0/projection"
 
 	"github.com/cs3org/reva/v2/pkg/events"
 	"github.com/go-chi/chi/v5"
@@ -23,36 +26,73 @@ const memberRefsLimit = 20
 // GetGroups implements the Service interface.
 func (g Graph) GetGroups(w http.ResponseWriter, r *http.Request) {
 	sanitizedPath := strings.TrimPrefix(r.URL.Path, "/graph/v1.0/")
-	odataReq, err := godata.ParseRequest(r.Context(), sanitizedPath, r.URL.Query())
+	odataReq, err := godata.ParseRequest(r.Context(), sanitizedPath, paging.WithoutSkipToken(r.URL.Query()))
 	if err != nil {
 		g.logger.Err(err).Interface("query", r.URL.Query()).Msg("query error")
 		errorcode.InvalidRequest.Render(w, r, http.StatusBadRequest, err.Error())
 		return
 	}
+	page, err := g.parsePage(r)
+	if err != nil {
+		errorcode.InvalidRequest.Render(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+	p, err := parseProjection(r, "members")
+	if err != nil {
+		renderError(w, r, err)
+		return
+	}
 
-	groups, err := g.identityBackend.GetGroups(r.Context(), r.URL.Query())
-
+	var groups []*libregraph.Group
+	var info paging.Info
+	if odataReq.Query.OrderBy == nil {
+		groups, info, err = g.identityBackend.GetGroups(r.Context(), page.Apply(r.URL.Query()))
+	} else {
+		// the backends can't sort, so sorted collections are paged after reading all groups
+		groups, _, err = g.identityBackend.GetGroups(r.Context(), paging.Strip(r.URL.Query()))
+	}
 	if err != nil {
-		var errcode errorcode.Error
-		if errors.As(err, &errcode) {
-			errcode.Render(w, r)
-		} else {
-			errorcode.GeneralException.Render(w, r, http.StatusInternalServerError, err.Error())
+		renderError(w, r, err)
+		return
+	}
+
+	if odataReq.Query.OrderBy != nil {
+		groups, err = sortGroups(odataReq, groups)
+		if err != nil {
+			renderError(w, r, err)
+			return
+		}
+		var start, end int
+		if start, end, info, err = paging.Slice(len(groups), page); err != nil {
+			errorcode.InvalidRequest.Render(w, r, http.StatusBadRequest, err.Error())
+			return
 		}
+		groups = groups[start:end]
 	}
 
-	groups, err = sortGroups(odataReq, groups)
-	if err != nil {
-		var errcode errorcode.Error
-		if errors.As(err, &errcode) {
-			errcode.Render(w, r)
-		} else {
-			errorcode.GeneralException.Render(w, r, http.StatusInternalServerError, err.Error())
+	values := make([]interface{}, 0, len(groups))
+	for _, group := range groups {
+		v, err := g.projectGroup(r.Context(), p, group)
+		if err != nil {
+			renderError(w, r, err)
+			return
 		}
-		return
+		values = append(values, v)
 	}
-	render.Status(r, http.StatusOK)
-	render.JSON(w, r, &listResponse{Value: groups})
+	g.renderPage(w, r, values, info)
+}
+
+// projectGroup expands the members property of the group if requested and selects the requested properties.
+func (g Graph) projectGroup(ctx context.Context, p projection.Projection, group *libregraph.Group) (interface{}, error) {
+	expanded := map[string]interface{}{}
+	if p.Expands("members") {
+		members, err := g.identityBackend.GetGroupMembers(ctx, group.GetId())
+		if err != nil {
+			return nil, err
+		}
+		expanded["members"] = members
+	}
+	return p.Apply(group, expanded)
 }
 
 // PostGroup implements the Service interface.
@@ -137,12 +177,7 @@ func (g Graph) PatchGroup(w http.ResponseWriter, r *http.Request) {
 	}
 
 	if err != nil {
-		var errcode errorcode.Error
-		if errors.As(err, &errcode) {
-			errcode.Render(w, r)
-		} else {
-			errorcode.GeneralException.Render(w, r, http.StatusInternalServerError, err.Error())
-		}
+		renderError(w, r, err)
 		return
 	}
 	render.Status(r, http.StatusNoContent)
@@ -162,18 +197,26 @@ func (g Graph) GetGroup(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	p, err := parseProjection(r, "members")
+	if err != nil {
+		renderError(w, r, err)
+		return
+	}
+
 	group, err := g.identityBackend.GetGroup(r.Context(), groupID)
 	if err != nil {
-		var errcode errorcode.Error
-		if errors.As(err, &errcode) {
-			errcode.Render(w, r)
-		} else {
-			errorcode.GeneralException.Render(w, r, http.StatusInternalServerError, err.Error())
-		}
+		renderError(w, r, err)
+		return
+	}
+
+	res, err := g.projectGroup(r.Context(), p, group)
+	if err != nil {
+		renderError(w, r, err)
+		return
 	}
 
 	render.Status(r, http.StatusOK)
-	render.JSON(w, r, group)
+	render.JSON(w, r, res)
 }
 
 // DeleteGroup implements the Service interface.
@@ -193,12 +236,7 @@ func (g Graph) DeleteGroup(w http.ResponseWriter, r *http.Request) {
 	err = g.identityBackend.DeleteGroup(r.Context(), groupID)
 
 	if err != nil {
-		var errcode errorcode.Error
-		if errors.As(err, &errcode) {
-			errcode.Render(w, r)
-		} else {
-			errorcode.GeneralException.Render(w, r, http.StatusInternalServerError, err.Error())
-		}
+		renderError(w, r, err)
 		return
 	}
 
@@ -222,12 +260,7 @@ func (g Graph) GetGroupMembers(w http.ResponseWriter, r *http.Request) {
 
 	members, err := g.identityBackend.GetGroupMembers(r.Context(), groupID)
 	if err != nil {
-		var errcode errorcode.Error
-		if errors.As(err, &errcode) {
-			errcode.Render(w, r)
-		} else {
-			errorcode.GeneralException.Render(w, r, http.StatusInternalServerError, err.Error())
-		}
+		renderError(w, r, err)
 		return
 	}
 
@@ -235,6 +268,33 @@ func (g Graph) GetGroupMembers(w http.ResponseWriter, r *http.Request) {
 	render.JSON(w, r, members)
 }
 
+// GetGroupTransitiveMembers returns the users which are members of a group or of the groups nested into it.
+func (g Graph) GetGroupTransitiveMembers(w http.ResponseWriter, r *http.Request) {
+	groupID, err := pathParam(r, "groupID", "group")
+	if err != nil {
+		renderError(w, r, err)
+		return
+	}
+	page, err := g.parsePage(r)
+	if err != nil {
+		errorcode.InvalidRequest.Render(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+	g.logger.Info().Str("group", groupID).Msg("Calling GetGroupTransitiveMembers")
+
+	members, err := g.identityBackend.GetGroupTransitiveMembers(r.Context(), groupID)
+	if err != nil {
+		renderError(w, r, err)
+		return
+	}
+	start, end, info, err := paging.Slice(len(members), page)
+	if err != nil {
+		errorcode.InvalidRequest.Render(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+	g.renderPage(w, r, members[start:end], info)
+}
+
 // PostGroupMember implements the Service interface.
 func (g Graph) PostGroupMember(w http.ResponseWriter, r *http.Request) {
 	g.logger.Info().Msg("Calling PostGroupMember")
@@ -277,12 +337,7 @@ func (g Graph) PostGroupMember(w http.ResponseWriter, r *http.Request) {
 	err = g.identityBackend.AddMembersToGroup(r.Context(), groupID, []string{id})
 
 	if err != nil {
-		var errcode errorcode.Error
-		if errors.As(err, &errcode) {
-			errcode.Render(w, r)
-		} else {
-			errorcode.GeneralException.Render(w, r, http.StatusInternalServerError, err.Error())
-		}
+		renderError(w, r, err)
 		return
 	}
 
@@ -322,12 +377,7 @@ func (g Graph) DeleteGroupMember(w http.ResponseWriter, r *http.Request) {
 	err = g.identityBackend.RemoveMemberFromGroup(r.Context(), groupID, memberID)
 
 	if err != nil {
-		var errcode errorcode.Error
-		if errors.As(err, &errcode) {
-			errcode.Render(w, r)
-		} else {
-			errorcode.GeneralException.Render(w, r, http.StatusInternalServerError, err.Error())
-		}
+		renderError(w, r, err)
 		return
 	}
 	g.publishEvent(events.GroupMemberRemoved{GroupID: groupID, UserID: memberID})
@@ -0,0 +1,48 @@
+package svc
+
+import (
+	"net/http"
+
+	"github.com/cs3org/reva/v2/pkg/events"
+	"github.com/gofrs/uuid"
+	"github.com/owncloud/ocis/extensions/graph/pkg/identity"
+)
+
+// identityEvents are the events which invalidate the identity cache.
+var identityEvents = []events.Unmarshaller{
+	events.UserCreated{},
+	events.UserDeleted{},
+	events.UserFeatureChanged{},
+	events.GroupCreated{},
+	events.GroupDeleted{},
+	events.GroupMemberAdded{},
+	events.GroupMemberRemoved{},
+}
+
+// consumeIdentityEvents purges the identity cache whenever a user or a group was changed, e.g. by another instance
+// of the graph service. Every instance consumes the events in its own consumer group to receive all of them.
+func consumeIdentityEvents(consumer events.Consumer, cache *identity.CachedBackend) error {
+	ch, err := events.Consume(consumer, "graph-identity-cache-"+uuid.Must(uuid.NewV4()).String(), identityEvents...)
+	if err != nil {
+		return err
+	}
+	go func() {
+		for range ch {
+			cache.Purge()
+		}
+	}()
+	return nil
+}
+
+// purgeIdentityCache returns a middleware purging the identity cache after every request which is not a GET request.
+// It is used for the routes writing users and groups without going through the cached backend.
+func purgeIdentityCache(cache *identity.CachedBackend) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			next.ServeHTTP(w, r)
+			if r.Method != http.MethodGet {
+				cache.Purge()
+			}
+		})
+	}
+}
@@ -0,0 +1,161 @@
+package svc
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/http"
+
+	gateway "github.com/cs3org/go-cs3apis/cs3/gateway/v1beta1"
+	userpb "github.com/cs3org/go-cs3apis/cs3/identity/user/v1beta1"
+	cs3rpc "github.com/cs3org/go-cs3apis/cs3/rpc/v1beta1"
+	revactx "github.com/cs3org/reva/v2/pkg/ctx"
+	libregraph "github.com/owncloud/libre-graph-api-go"
+	"github.com/owncloud/ocis/extensions/graph/pkg/service/v0/errorcode"
+	"github.com/owncloud/ocis/extensions/graph/pkg/service/v0/photo"
+)
+
+// passwordChange is the request body of a password change.
+type passwordChange struct {
+	CurrentPassword string `json:"currentPassword"`
+	NewPassword     string `json:"newPassword"`
+}
+
+// ChangeOwnPassword changes the password of the current user after verifying the current password.
+func (g Graph) ChangeOwnPassword(w http.ResponseWriter, r *http.Request) {
+	u, ok := revactx.ContextGetUser(r.Context())
+	if !ok {
+		g.logger.Error().Msg("user not in context")
+		errorcode.ServiceNotAvailable.Render(w, r, http.StatusInternalServerError, "user not in context")
+		return
+	}
+
+	change := passwordChange{}
+	if err := json.NewDecoder(r.Body).Decode(&change); err != nil {
+		errorcode.InvalidRequest.Render(w, r, http.StatusBadRequest, "invalid request body: "+err.Error())
+		return
+	}
+	if change.CurrentPassword == "" || change.NewPassword == "" {
+		errorcode.InvalidRequest.Render(w, r, http.StatusBadRequest, "currentPassword and newPassword must not be empty")
+		return
+	}
+	if change.CurrentPassword == change.NewPassword {
+		errorcode.InvalidRequest.Render(w, r, http.StatusBadRequest, "the new password must differ from the current password")
+		return
+	}
+
+	// the current password is only verified if the identity backend can store the new one, backends like cs3
+	// don't offer a way to change passwords
+	if !g.identityBackend.Capabilities(r.Context()).UpdateUser {
+		errorcode.NotSupported.Render(w, r, http.StatusNotImplemented, "the identity backend does not support changing passwords")
+		return
+	}
+
+	if !g.verifyPassword(w, r, u, change.CurrentPassword) {
+		return
+	}
+
+	update := libregraph.User{PasswordProfile: &libregraph.PasswordProfile{Password: &change.NewPassword}}
+	if _, err := g.identityBackend.UpdateUser(r.Context(), u.Id.OpaqueId, update); err != nil {
+		g.logger.Debug().Err(err).Str("userid", u.Id.OpaqueId).Msg("could not change the password")
+		renderError(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// verifyPassword verifies the current password of the user and renders the error if it is wrong or can't be verified.
+// Identity backends which can verify passwords themselves also accept expired passwords, so that users can change
+// them. Otherwise the password is verified by the auth provider, which checks the credentials against the same
+// identity backend, so that the connection of the backend isn't rebound to the user.
+func (g Graph) verifyPassword(w http.ResponseWriter, r *http.Request, u *userpb.User, password string) bool {
+	if g.passwordVerifier != nil {
+		if err := g.passwordVerifier.VerifyPassword(r.Context(), u.Id.OpaqueId, password); err != nil {
+			g.logger.Debug().Err(err).Str("userid", u.Id.OpaqueId).Msg("could not verify the current password")
+			renderError(w, r, err)
+			return false
+		}
+		return true
+	}
+
+	res, err := g.gatewayClient.Authenticate(r.Context(), &gateway.AuthenticateRequest{
+		Type:         "basic",
+		ClientId:     u.Username,
+		ClientSecret: password,
+	})
+	switch {
+	case err != nil:
+		g.logger.Error().Err(err).Msg("could not verify the current password: transport error")
+		errorcode.GeneralException.Render(w, r, http.StatusInternalServerError, "could not verify the current password")
+		return false
+	case res.Status.Code == cs3rpc.Code_CODE_UNAUTHENTICATED, res.Status.Code == cs3rpc.Code_CODE_PERMISSION_DENIED:
+		errorcode.AccessDenied.Render(w, r, http.StatusForbidden, "the current password is wrong")
+		return false
+	case res.Status.Code != cs3rpc.Code_CODE_OK:
+		g.logger.Error().Str("code", res.Status.Code.String()).Str("message", res.Status.Message).Msg("could not verify the current password")
+		errorcode.GeneralException.Render(w, r, http.StatusInternalServerError, "could not verify the current password")
+		return false
+	}
+	return true
+}
+
+// GetMePhoto returns the profile photo of the current user.
+func (g Graph) GetMePhoto(w http.ResponseWriter, r *http.Request) {
+	u, ok := revactx.ContextGetUser(r.Context())
+	if !ok {
+		g.logger.Error().Msg("user not in context")
+		errorcode.ServiceNotAvailable.Render(w, r, http.StatusInternalServerError, "user not in context")
+		return
+	}
+
+	data, err := g.photoStore.Get(u.Id.OpaqueId)
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+		errorcode.ItemNotFound.Render(w, r, http.StatusNotFound, "the user has no profile photo")
+		return
+	case err != nil:
+		g.logger.Error().Err(err).Str("userid", u.Id.OpaqueId).Msg("could not read the profile photo")
+		errorcode.GeneralException.Render(w, r, http.StatusInternalServerError, "could not read the profile photo")
+		return
+	}
+
+	w.Header().Set("Content-Type", http.DetectContentType(data))
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write(data)
+}
+
+// PutMePhoto replaces the profile photo of the current user, the photo is resized to the configured resolution.
+func (g Graph) PutMePhoto(w http.ResponseWriter, r *http.Request) {
+	u, ok := revactx.ContextGetUser(r.Context())
+	if !ok {
+		g.logger.Error().Msg("user not in context")
+		errorcode.ServiceNotAvailable.Render(w, r, http.StatusInternalServerError, "user not in context")
+		return
+	}
+
+	body := r.Body
+	if g.config.Photos.MaxUploadSize > 0 {
+		body = http.MaxBytesReader(w, r.Body, g.config.Photos.MaxUploadSize)
+	}
+	data, err := photo.Resize(body, g.photoSize, g.config.Photos.MaxPixels)
+	switch {
+	case errors.Is(err, photo.ErrInvalidImage):
+		errorcode.InvalidRequest.Render(w, r, http.StatusBadRequest, "the photo must be a png, jpeg or gif image not exceeding the maximum size")
+		return
+	case errors.Is(err, photo.ErrTooManyPixels):
+		errorcode.InvalidRequest.Render(w, r, http.StatusBadRequest, fmt.Sprintf("the photo must not have more than %d pixels", g.config.Photos.MaxPixels))
+		return
+	case err != nil:
+		g.logger.Error().Err(err).Str("userid", u.Id.OpaqueId).Msg("could not resize the profile photo")
+		errorcode.GeneralException.Render(w, r, http.StatusInternalServerError, "could not resize the profile photo")
+		return
+	}
+
+	if err := g.photoStore.Put(u.Id.OpaqueId, data); err != nil {
+		g.logger.Error().Err(err).Str("userid", u.Id.OpaqueId).Msg("could not store the profile photo")
+		errorcode.GeneralException.Render(w, r, http.StatusInternalServerError, "could not store the profile photo")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,200 @@
+package svc_test
+
+import (
+	"bytes"
+	"context"
+	"image"
+	"image/png"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+
+	gateway "github.com/cs3org/go-cs3apis/cs3/gateway/v1beta1"
+	userpb "github.com/cs3org/go-cs3apis/cs3/identity/user/v1beta1"
+	cs3rpc "github.com/cs3org/go-cs3apis/cs3/rpc/v1beta1"
+	revactx "github.com/cs3org/reva/v2/pkg/ctx"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	libregraph "github.com/owncloud/libre-graph-api-go"
+	"github.com/owncloud/ocis/extensions/graph/mocks"
+	"github.com/owncloud/ocis/extensions/graph/pkg/config"
+	"github.com/owncloud/ocis/extensions/graph/pkg/config/defaults"
+	"github.com/owncloud/ocis/extensions/graph/pkg/identity"
+	service "github.com/owncloud/ocis/extensions/graph/pkg/service/v0"
+	"github.com/owncloud/ocis/extensions/graph/pkg/service/v0/errorcode"
+	"github.com/stretchr/testify/mock"
+)
+
+// verifyingBackend is an identity backend which verifies passwords itself
+type verifyingBackend struct {
+	*mocks.Backend
+	password string
+}
+
+func (b *verifyingBackend) VerifyPassword(ctx context.Context, nameOrID, password string) error {
+	if password != b.password {
+		return errorcode.New(errorcode.AccessDenied, "the current password is wrong")
+	}
+	return nil
+}
+
+var _ = Describe("Me", func() {
+	var (
+		svc             service.Service
+		gatewayClient   *mocks.GatewayClient
+		identityBackend *mocks.Backend
+		cfg             *config.Config
+		photoDir        string
+		rr              *httptest.ResponseRecorder
+
+		einstein = &userpb.User{Id: &userpb.UserId{OpaqueId: "einstein-id"}, Username: "einstein"}
+	)
+
+	// request creates a request on behalf of einstein.
+	request := func(method string, target string, body []byte) *http.Request {
+		r := httptest.NewRequest(method, target, bytes.NewReader(body))
+		return r.WithContext(revactx.ContextSetUser(context.Background(), einstein))
+	}
+
+	BeforeEach(func() {
+		rr = httptest.NewRecorder()
+		gatewayClient = &mocks.GatewayClient{}
+		identityBackend = &mocks.Backend{}
+
+		var err error
+		photoDir, err = os.MkdirTemp("", "graph-photos")
+		Expect(err).ToNot(HaveOccurred())
+
+		cfg = defaults.DefaultConfig()
+		cfg.Photos.RootDirectory = photoDir
+		cfg.Photos.Resolution = "32x32"
+		cfg.Photos.MaxUploadSize = 64 * 1024
+	})
+
+	AfterEach(func() {
+		os.RemoveAll(photoDir)
+	})
+
+	JustBeforeEach(func() {
+		svc = service.NewService(
+			service.Config(cfg),
+			service.WithGatewayClient(gatewayClient),
+			service.WithIdentityBackend(identityBackend),
+		)
+	})
+
+	Describe("changePassword", func() {
+		authenticate := func(password string, code cs3rpc.Code) {
+			gatewayClient.On("Authenticate", mock.Anything, mock.MatchedBy(func(req *gateway.AuthenticateRequest) bool {
+				return req.Type == "basic" && req.ClientId == "einstein" && req.ClientSecret == password
+			})).Return(&gateway.AuthenticateResponse{Status: &cs3rpc.Status{Code: code}}, nil)
+		}
+
+		BeforeEach(func() {
+			identityBackend.On("Capabilities", mock.Anything).Return(identity.Capabilities{UpdateUser: true})
+		})
+
+		It("changes the password", func() {
+			authenticate("relativity", cs3rpc.Code_CODE_OK)
+			identityBackend.On("UpdateUser", mock.Anything, "einstein-id", mock.MatchedBy(func(u libregraph.User) bool {
+				return u.PasswordProfile != nil && *u.PasswordProfile.Password == "quantum"
+			})).Return(&libregraph.User{}, nil)
+
+			r := request(http.MethodPost, "/graph/v1.0/me/changePassword", []byte(`{"currentPassword":"relativity","newPassword":"quantum"}`))
+			svc.(service.Graph).ChangeOwnPassword(rr, r)
+			Expect(rr.Code).To(Equal(http.StatusNoContent))
+			identityBackend.AssertExpectations(GinkgoT())
+		})
+
+		It("rejects a wrong current password", func() {
+			authenticate("wrong", cs3rpc.Code_CODE_UNAUTHENTICATED)
+
+			r := request(http.MethodPost, "/graph/v1.0/me/changePassword", []byte(`{"currentPassword":"wrong","newPassword":"quantum"}`))
+			svc.(service.Graph).ChangeOwnPassword(rr, r)
+			Expect(rr.Code).To(Equal(http.StatusForbidden))
+			identityBackend.AssertNotCalled(GinkgoT(), "UpdateUser", mock.Anything, mock.Anything, mock.Anything)
+		})
+
+		It("verifies the password with identity backends which can verify passwords", func() {
+			identityBackend.On("UpdateUser", mock.Anything, "einstein-id", mock.Anything).Return(&libregraph.User{}, nil)
+			backend := &verifyingBackend{Backend: identityBackend, password: "relativity"}
+			svc = service.NewService(
+				service.Config(cfg),
+				service.WithGatewayClient(gatewayClient),
+				service.WithIdentityBackend(backend),
+			)
+
+			r := request(http.MethodPost, "/graph/v1.0/me/changePassword", []byte(`{"currentPassword":"wrong","newPassword":"quantum"}`))
+			svc.(service.Graph).ChangeOwnPassword(rr, r)
+			Expect(rr.Code).To(Equal(http.StatusForbidden))
+
+			rr = httptest.NewRecorder()
+			r = request(http.MethodPost, "/graph/v1.0/me/changePassword", []byte(`{"currentPassword":"relativity","newPassword":"quantum"}`))
+			svc.(service.Graph).ChangeOwnPassword(rr, r)
+			Expect(rr.Code).To(Equal(http.StatusNoContent))
+			gatewayClient.AssertNotCalled(GinkgoT(), "Authenticate", mock.Anything, mock.Anything)
+		})
+
+		It("is not supported by read-only identity backends", func() {
+			identityBackend = &mocks.Backend{}
+			identityBackend.On("Capabilities", mock.Anything).Return(identity.Capabilities{})
+
+			r := request(http.MethodPost, "/graph/v1.0/me/changePassword", []byte(`{"currentPassword":"relativity","newPassword":"quantum"}`))
+			service.NewService(
+				service.Config(cfg),
+				service.WithGatewayClient(gatewayClient),
+				service.WithIdentityBackend(identityBackend),
+			).(service.Graph).ChangeOwnPassword(rr, r)
+			Expect(rr.Code).To(Equal(http.StatusNotImplemented))
+			gatewayClient.AssertNotCalled(GinkgoT(), "Authenticate", mock.Anything, mock.Anything)
+		})
+
+		It("rejects invalid requests", func() {
+			for _, body := range []string{`invalid`, `{"currentPassword":"relativity"}`, `{"currentPassword":"same","newPassword":"same"}`} {
+				rr = httptest.NewRecorder()
+				r := request(http.MethodPost, "/graph/v1.0/me/changePassword", []byte(body))
+				svc.(service.Graph).ChangeOwnPassword(rr, r)
+				Expect(rr.Code).To(Equal(http.StatusBadRequest), body)
+			}
+			gatewayClient.AssertNotCalled(GinkgoT(), "Authenticate", mock.Anything, mock.Anything)
+		})
+	})
+
+	Describe("photo", func() {
+		It("returns not found without a photo", func() {
+			r := request(http.MethodGet, "/graph/v1.0/me/photo/$value", nil)
+			svc.(service.Graph).GetMePhoto(rr, r)
+			Expect(rr.Code).To(Equal(http.StatusNotFound))
+		})
+
+		It("stores a resized photo", func() {
+			buf := &bytes.Buffer{}
+			Expect(png.Encode(buf, image.NewRGBA(image.Rect(0, 0, 100, 50)))).To(Succeed())
+
+			r := request(http.MethodPut, "/graph/v1.0/me/photo/$value", buf.Bytes())
+			svc.(service.Graph).PutMePhoto(rr, r)
+			Expect(rr.Code).To(Equal(http.StatusNoContent))
+
+			rr = httptest.NewRecorder()
+			r = request(http.MethodGet, "/graph/v1.0/me/photo/$value", nil)
+			svc.(service.Graph).GetMePhoto(rr, r)
+			Expect(rr.Code).To(Equal(http.StatusOK))
+			Expect(rr.Header().Get("Content-Type")).To(Equal("image/png"))
+			img, err := png.Decode(rr.Body)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(img.Bounds().Dx()).To(Equal(32))
+		})
+
+		It("rejects invalid photos", func() {
+			r := request(http.MethodPut, "/graph/v1.0/me/photo/$value", []byte("not an image"))
+			svc.(service.Graph).PutMePhoto(rr, r)
+			Expect(rr.Code).To(Equal(http.StatusBadRequest))
+
+			rr = httptest.NewRecorder()
+			r = request(http.MethodPut, "/graph/v1.0/me/photo/$value", []byte(strings.Repeat("x", 128*1024)))
+			svc.(service.Graph).PutMePhoto(rr, r)
+			Expect(rr.Code).To(Equal(http.StatusBadRequest))
+		})
+	})
+})
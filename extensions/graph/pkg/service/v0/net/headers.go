@@ -6,4 +6,12 @@ const (
 	HeaderTokenTransport = "X-Reva-Transfer"
 	// HeaderIfModifiedSince is used to mimic/pass on caching headers when using grpc
 	HeaderIfModifiedSince = "If-Modified-Since"
+	// HeaderContentType holds the media type of a request or response body
+	HeaderContentType = "Content-Type"
+	// HeaderContentRange is the header of a chunk holding its byte range
+	HeaderContentRange = "Content-Range"
+	// HeaderTusResumable holds the version of the tus protocol used to upload to the data gateway
+	HeaderTusResumable = "Tus-Resumable"
+	// HeaderUploadOffset holds the number of bytes of a tus upload received by the data gateway
+	HeaderUploadOffset = "Upload-Offset"
 )
@@ -5,6 +5,8 @@ import (
 
 	"github.com/cs3org/reva/v2/pkg/events"
 	"github.com/owncloud/ocis/extensions/graph/pkg/config"
+	"github.com/owncloud/ocis/extensions/graph/pkg/identity"
+	"github.com/owncloud/ocis/extensions/graph/pkg/metrics"
 	"github.com/owncloud/ocis/ocis-pkg/log"
 	"github.com/owncloud/ocis/ocis-pkg/roles"
 	settingssvc "github.com/owncloud/ocis/protogen/gen/ocis/services/settings/v0"
@@ -23,6 +25,11 @@ type Options struct {
 	RoleService     settingssvc.RoleService
 	RoleManager     *roles.Manager
 	EventsPublisher events.Publisher
+	EventsConsumer  events.Consumer
+	IdentityBackend identity.Backend
+	Metrics         *metrics.Metrics
+
+	IdentityEducationBackend identity.EducationBackend
 }
 
 // newOptions initializes the available default options.
@@ -91,3 +98,31 @@ func EventsPublisher(val events.Publisher) Option {
 		o.EventsPublisher = val
 	}
 }
+
+// EventsConsumer provides a function to set the EventsConsumer option.
+func EventsConsumer(val events.Consumer) Option {
+	return func(o *Options) {
+		o.EventsConsumer = val
+	}
+}
+
+// Metrics provides a function to set the Metrics option.
+func Metrics(val *metrics.Metrics) Option {
+	return func(o *Options) {
+		o.Metrics = val
+	}
+}
+
+// WithIdentityBackend provides a function to set the IdentityBackend option.
+func WithIdentityBackend(val identity.Backend) Option {
+	return func(o *Options) {
+		o.IdentityBackend = val
+	}
+}
+
+// WithIdentityEducationBackend provides a function to set the IdentityEducationBackend option.
+func WithIdentityEducationBackend(val identity.EducationBackend) Option {
+	return func(o *Options) {
+		o.IdentityEducationBackend = val
+	}
+}
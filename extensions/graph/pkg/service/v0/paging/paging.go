@@ -0,0 +1,188 @@
+// Package paging implements the server side paging of graph collections using the OData $top, $skip, $skiptoken
+// and $count query options.
+package paging
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+)
+
+// The query options used for paging.
+const (
+	TopParam       = "$top"
+	SkipParam      = "$skip"
+	SkipTokenParam = "$skiptoken"
+	CountParam     = "$count"
+)
+
+var errInvalidToken = errors.New("invalid $skiptoken")
+
+// Page selects a part of a collection. The zero value selects the whole collection.
+type Page struct {
+	// Top is the maximum number of items of the page, 0 means no limit.
+	Top int
+	// Skip is the number of items to skip. It is ignored when a Token is set.
+	Skip int
+	// Token continues a query, it is the NextToken of the previous page.
+	Token string
+	// Count requests the total number of items in the collection.
+	Count bool
+}
+
+// Paged reports if only a part of the collection is requested.
+func (p Page) Paged() bool {
+	return p.Top > 0 || p.Skip > 0 || p.Token != ""
+}
+
+// Info describes a page returned for a Page.
+type Info struct {
+	// NextToken continues the query with the next page, it is empty on the last page.
+	NextToken string
+	// Count is the total number of items in the collection, it is only set when requested.
+	Count *int
+}
+
+// Token is the decoded form of a $skiptoken. It holds the offset of the next page, so that the query can be run again
+// on any instance of the service and any connection to the backend.
+type Token struct {
+	Offset int `json:"o,omitempty"`
+}
+
+// Encode returns the opaque string representation of the token.
+func (t Token) Encode() string {
+	b, _ := json.Marshal(t)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// DecodeToken parses a token created by Token.Encode.
+func DecodeToken(s string) (Token, error) {
+	t := Token{}
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return t, errInvalidToken
+	}
+	if err := json.Unmarshal(b, &t); err != nil || t.Offset < 0 {
+		return t, errInvalidToken
+	}
+	return t, nil
+}
+
+// Parse reads the paging options from the query. When maxSize is greater than 0 it limits the size of every page,
+// even when no $top is requested.
+func Parse(query url.Values, maxSize int) (Page, error) {
+	p := Page{Token: query.Get(SkipTokenParam)}
+
+	var err error
+	if v := query.Get(TopParam); v != "" {
+		if p.Top, err = strconv.Atoi(v); err != nil || p.Top < 0 {
+			return p, fmt.Errorf("invalid %s value '%s'", TopParam, v)
+		}
+	}
+	if v := query.Get(SkipParam); v != "" {
+		if p.Skip, err = strconv.Atoi(v); err != nil || p.Skip < 0 {
+			return p, fmt.Errorf("invalid %s value '%s'", SkipParam, v)
+		}
+	}
+	if v := query.Get(CountParam); v != "" {
+		if p.Count, err = strconv.ParseBool(v); err != nil {
+			return p, fmt.Errorf("invalid %s value '%s'", CountParam, v)
+		}
+	}
+	if p.Token != "" {
+		if _, err := DecodeToken(p.Token); err != nil {
+			return p, err
+		}
+	}
+	if maxSize > 0 && (p.Top == 0 || p.Top > maxSize) {
+		p.Top = maxSize
+	}
+	return p, nil
+}
+
+// Apply returns a copy of the query with the paging options replaced by the ones of the page.
+func (p Page) Apply(query url.Values) url.Values {
+	q := Strip(query)
+	if p.Top > 0 {
+		q.Set(TopParam, strconv.Itoa(p.Top))
+	}
+	if p.Skip > 0 {
+		q.Set(SkipParam, strconv.Itoa(p.Skip))
+	}
+	if p.Token != "" {
+		q.Set(SkipTokenParam, p.Token)
+	}
+	if p.Count {
+		q.Set(CountParam, "true")
+	}
+	return q
+}
+
+// Strip returns a copy of the query without the paging options.
+func Strip(query url.Values) url.Values {
+	q := make(url.Values, len(query))
+	for k, v := range query {
+		switch k {
+		case TopParam, SkipParam, SkipTokenParam, CountParam:
+		default:
+			q[k] = append([]string(nil), v...)
+		}
+	}
+	return q
+}
+
+// WithoutSkipToken returns a copy of the query without the $skiptoken, which is unknown to the OData parser.
+func WithoutSkipToken(query url.Values) url.Values {
+	q := make(url.Values, len(query))
+	for k, v := range query {
+		if k != SkipTokenParam {
+			q[k] = v
+		}
+	}
+	return q
+}
+
+// Slice returns the bounds of the page in a collection of n items held in memory.
+func Slice(n int, p Page) (start, end int, info Info, err error) {
+	start = p.Skip
+	if p.Token != "" {
+		t, err := DecodeToken(p.Token)
+		if err != nil {
+			return 0, 0, info, err
+		}
+		start = t.Offset
+	}
+	if p.Count {
+		count := n
+		info.Count = &count
+	}
+	if start > n {
+		start = n
+	}
+	end = n
+	if p.Top > 0 && start+p.Top < n {
+		end = start + p.Top
+		info.NextToken = Token{Offset: end}.Encode()
+	}
+	return start, end, info, nil
+}
+
+// NextLink returns the link to the next page of the collection at base with the query.
+func NextLink(base string, path string, query url.Values, token string) string {
+	q := make(url.Values, len(query))
+	for k, v := range query {
+		if k != SkipParam && k != SkipTokenParam {
+			q[k] = v
+		}
+	}
+	q.Set(SkipTokenParam, token)
+
+	u := url.URL{Path: path, RawQuery: q.Encode()}
+	if b, err := url.Parse(base); err == nil {
+		u.Scheme, u.Host = b.Scheme, b.Host
+	}
+	return u.String()
+}
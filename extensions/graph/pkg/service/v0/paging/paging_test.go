@@ -0,0 +1,106 @@
+package paging
+
+import (
+	"net/url"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	token := Token{Offset: 10}.Encode()
+	tests := []struct {
+		query   string
+		maxSize int
+		page    Page
+		err     bool
+	}{
+		{query: "", page: Page{}},
+		{query: "$top=10&$skip=5&$count=true", page: Page{Top: 10, Skip: 5, Count: true}},
+		{query: "$skiptoken=" + token, page: Page{Token: token}},
+		{query: "", maxSize: 100, page: Page{Top: 100}},
+		{query: "$top=1000", maxSize: 100, page: Page{Top: 100}},
+		{query: "$top=10", maxSize: 100, page: Page{Top: 10}},
+		{query: "$top=-1", err: true},
+		{query: "$skip=a", err: true},
+		{query: "$count=maybe", err: true},
+		{query: "$skiptoken=invalid", err: true},
+	}
+
+	for _, tt := range tests {
+		q, _ := url.ParseQuery(tt.query)
+		p, err := Parse(q, tt.maxSize)
+		if tt.err {
+			if err == nil {
+				t.Errorf("%s: expected an error", tt.query)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: unexpected error %v", tt.query, err)
+		}
+		if p != tt.page {
+			t.Errorf("%s: expected %+v got %+v", tt.query, tt.page, p)
+		}
+	}
+}
+
+func TestSlice(t *testing.T) {
+	start, end, info, err := Slice(25, Page{Top: 10, Count: true})
+	if err != nil || start != 0 || end != 10 {
+		t.Fatalf("unexpected page %d-%d %v", start, end, err)
+	}
+	if info.Count == nil || *info.Count != 25 {
+		t.Error("expected the total count")
+	}
+
+	start, end, info, err = Slice(25, Page{Top: 10, Token: info.NextToken})
+	if err != nil || start != 10 || end != 20 || info.Count != nil {
+		t.Fatalf("unexpected page %d-%d %v", start, end, err)
+	}
+
+	start, end, info, err = Slice(25, Page{Top: 10, Token: info.NextToken})
+	if err != nil || start != 20 || end != 25 {
+		t.Fatalf("unexpected page %d-%d %v", start, end, err)
+	}
+	if info.NextToken != "" {
+		t.Error("expected no next token on the last page")
+	}
+
+	start, end, _, _ = Slice(5, Page{Skip: 10})
+	if start != 5 || end != 5 {
+		t.Errorf("expected an empty page got %d-%d", start, end)
+	}
+}
+
+func TestApply(t *testing.T) {
+	q, _ := url.ParseQuery("$top=1000&$search=ei&$skip=3")
+	got := Page{Top: 100}.Apply(q)
+	if got.Get(TopParam) != "100" || got.Get(SkipParam) != "" || got.Get("$search") != "ei" {
+		t.Errorf("unexpected query %s", got.Encode())
+	}
+	if q.Get(TopParam) != "1000" {
+		t.Error("the original query must not be changed")
+	}
+}
+
+func TestNextLink(t *testing.T) {
+	q, _ := url.ParseQuery("$top=10&$skip=10&$count=true")
+	got := NextLink("https://cloud.example.com", "/graph/v1.0/users", q, "abc")
+	want := "https://cloud.example.com/graph/v1.0/users?%24count=true&%24skiptoken=abc&%24top=10"
+	if got != want {
+		t.Errorf("expected %s got %s", want, got)
+	}
+}
+
+func TestDecodeToken(t *testing.T) {
+	want := Token{Offset: 3}
+	got, err := DecodeToken(want.Encode())
+	if err != nil || got.Offset != want.Offset {
+		t.Errorf("expected %v got %v (%v)", want, got, err)
+	}
+	if _, err := DecodeToken("e30"); err != nil {
+		t.Errorf("expected the empty token to be valid, got %v", err)
+	}
+	if _, err := DecodeToken("!"); err == nil {
+		t.Error("expected an error")
+	}
+}
@@ -0,0 +1,553 @@
+package svc
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+
+	cs3group "github.com/cs3org/go-cs3apis/cs3/identity/group/v1beta1"
+	cs3user "github.com/cs3org/go-cs3apis/cs3/identity/user/v1beta1"
+	cs3rpc "github.com/cs3org/go-cs3apis/cs3/rpc/v1beta1"
+	collaboration "github.com/cs3org/go-cs3apis/cs3/sharing/collaboration/v1beta1"
+	storageprovider "github.com/cs3org/go-cs3apis/cs3/storage/provider/v1beta1"
+	revactx "github.com/cs3org/reva/v2/pkg/ctx"
+	"github.com/cs3org/reva/v2/pkg/events"
+	"github.com/cs3org/reva/v2/pkg/utils"
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/render"
+	libregraph "github.com/owncloud/libre-graph-api-go"
+	"github.com/owncloud/ocis/extensions/graph/pkg/service/v0/errorcode"
+)
+
+// The roles of the members of a space.
+const (
+	SpaceRoleViewer  = "viewer"
+	SpaceRoleEditor  = "editor"
+	SpaceRoleManager = "manager"
+)
+
+// The prefixes of the permission ids, which tell users and groups apart.
+const (
+	userPermissionPrefix  = "u:"
+	groupPermissionPrefix = "g:"
+)
+
+// identitySet is the identity a permission is granted to, either a user or a group.
+type identitySet struct {
+	User  *libregraph.Identity `json:"user,omitempty"`
+	Group *libregraph.Identity `json:"group,omitempty"`
+}
+
+// spacePermission is the membership of a user or a group in a space.
+type spacePermission struct {
+	Id        string        `json:"id,omitempty"`
+	Roles     []string      `json:"roles"`
+	GrantedTo []identitySet `json:"grantedTo"`
+}
+
+// driveRecipient is a user or group invited to a space.
+type driveRecipient struct {
+	ObjectId string `json:"objectId"`
+}
+
+type inviteRequest struct {
+	Recipients []driveRecipient `json:"recipients"`
+	Roles      []string         `json:"roles"`
+}
+
+// spaceRolePermissions returns the cs3 permissions granted by a role of a space member. All members of a space can
+// list its grants.
+func spaceRolePermissions(role string) (*storageprovider.ResourcePermissions, bool) {
+	p := &storageprovider.ResourcePermissions{
+		GetPath:              true,
+		GetQuota:             true,
+		InitiateFileDownload: true,
+		ListGrants:           true,
+		ListContainer:        true,
+		ListFileVersions:     true,
+		ListRecycle:          true,
+		Stat:                 true,
+	}
+	switch role {
+	case SpaceRoleViewer:
+		return p, true
+	case SpaceRoleManager:
+		p.AddGrant = true
+		p.RemoveGrant = true
+		p.UpdateGrant = true
+		fallthrough
+	case SpaceRoleEditor:
+		p.InitiateFileUpload = true
+		p.RestoreFileVersion = true
+		p.RestoreRecycleItem = true
+		p.CreateContainer = true
+		p.Delete = true
+		p.Move = true
+		p.PurgeRecycle = true
+		return p, true
+	}
+	return nil, false
+}
+
+// spaceRole returns the role matching the cs3 permissions of a space member.
+func spaceRole(p *storageprovider.ResourcePermissions) string {
+	switch {
+	case p.AddGrant:
+		return SpaceRoleManager
+	case p.InitiateFileUpload:
+		return SpaceRoleEditor
+	case p.Stat:
+		return SpaceRoleViewer
+	}
+	return ""
+}
+
+// ListDrivePermissions lists the members of a space.
+func (g Graph) ListDrivePermissions(w http.ResponseWriter, r *http.Request) {
+	g.logger.Info().Msg("Calling ListDrivePermissions")
+	ctx := r.Context()
+
+	space, err := g.getSpace(ctx, chi.URLParam(r, "driveID"))
+	if err != nil {
+		renderError(w, r, err)
+		return
+	}
+	grants := g.spaceGrants(space)
+	ids := make([]string, 0, len(grants))
+	for id := range grants {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	permissions := make([]spacePermission, 0, len(ids))
+	for _, id := range ids {
+		role := spaceRole(grants[id])
+		if role == "" {
+			continue
+		}
+		grantee, identity := g.resolveGrantee(ctx, id)
+		permissions = append(permissions, spacePermission{
+			Id:        permissionID(grantee),
+			Roles:     []string{role},
+			GrantedTo: []identitySet{identity},
+		})
+	}
+	render.Status(r, http.StatusOK)
+	render.JSON(w, r, &listResponse{Value: permissions})
+}
+
+// CreateDrivePermission adds a user or a group to a space.
+func (g Graph) CreateDrivePermission(w http.ResponseWriter, r *http.Request) {
+	permission := spacePermission{}
+	if err := json.NewDecoder(r.Body).Decode(&permission); err != nil {
+		errorcode.InvalidRequest.Render(w, r, http.StatusBadRequest, fmt.Sprintf("invalid request body: %s", err.Error()))
+		return
+	}
+	if len(permission.GrantedTo) != 1 {
+		errorcode.InvalidRequest.Render(w, r, http.StatusBadRequest, "a permission has to be granted to exactly one user or group")
+		return
+	}
+	role, err := permissionRole(permission.Roles)
+	if err != nil {
+		renderError(w, r, err)
+		return
+	}
+	g.logger.Info().Msg("Calling CreateDrivePermission")
+
+	ctx := r.Context()
+	space, err := g.getSpace(ctx, chi.URLParam(r, "driveID"))
+	if err != nil {
+		renderError(w, r, err)
+		return
+	}
+	grantee, identity, err := g.lookupGrantee(ctx, permission.GrantedTo[0])
+	if err != nil {
+		renderError(w, r, err)
+		return
+	}
+	if _, ok := g.spaceGrants(space)[granteeID(grantee)]; ok {
+		errorcode.NameAlreadyExists.Render(w, r, http.StatusConflict, "the space is already shared with the grantee, update the permission instead")
+		return
+	}
+	if err := g.addSpaceMember(ctx, space, grantee, role); err != nil {
+		renderError(w, r, err)
+		return
+	}
+
+	render.Status(r, http.StatusCreated)
+	render.JSON(w, r, spacePermission{
+		Id:        permissionID(grantee),
+		Roles:     []string{role},
+		GrantedTo: []identitySet{identity},
+	})
+}
+
+// InviteDriveMembers adds the recipients with the role to a space. Recipients which are members already get the
+// new role.
+func (g Graph) InviteDriveMembers(w http.ResponseWriter, r *http.Request) {
+	invite := inviteRequest{}
+	if err := json.NewDecoder(r.Body).Decode(&invite); err != nil {
+		errorcode.InvalidRequest.Render(w, r, http.StatusBadRequest, fmt.Sprintf("invalid request body: %s", err.Error()))
+		return
+	}
+	if len(invite.Recipients) == 0 {
+		errorcode.InvalidRequest.Render(w, r, http.StatusBadRequest, "missing recipients")
+		return
+	}
+	role, err := permissionRole(invite.Roles)
+	if err != nil {
+		renderError(w, r, err)
+		return
+	}
+	g.logger.Info().Msg("Calling InviteDriveMembers")
+
+	ctx := r.Context()
+	space, err := g.getSpace(ctx, chi.URLParam(r, "driveID"))
+	if err != nil {
+		renderError(w, r, err)
+		return
+	}
+
+	// resolve all recipients first, so an unknown recipient does not lead to a partial invite
+	type recipient struct {
+		grantee  *storageprovider.Grantee
+		identity identitySet
+	}
+	recipients := make([]recipient, 0, len(invite.Recipients))
+	for _, rcpt := range invite.Recipients {
+		grantee, identity, err := g.lookupRecipient(ctx, rcpt.ObjectId)
+		if err != nil {
+			renderError(w, r, err)
+			return
+		}
+		recipients = append(recipients, recipient{grantee: grantee, identity: identity})
+	}
+
+	grants := g.spaceGrants(space)
+	permissions := make([]spacePermission, 0, len(recipients))
+	for _, rcpt := range recipients {
+		if current, ok := grants[granteeID(rcpt.grantee)]; ok {
+			err = g.updateSpaceMember(ctx, space, rcpt.grantee, spaceRole(current), role)
+		} else {
+			err = g.addSpaceMember(ctx, space, rcpt.grantee, role)
+		}
+		if err != nil {
+			renderError(w, r, err)
+			return
+		}
+		permissions = append(permissions, spacePermission{
+			Id:        permissionID(rcpt.grantee),
+			Roles:     []string{role},
+			GrantedTo: []identitySet{rcpt.identity},
+		})
+	}
+	render.Status(r, http.StatusOK)
+	render.JSON(w, r, &listResponse{Value: permissions})
+}
+
+// UpdateDrivePermission changes the role of a member of a space.
+func (g Graph) UpdateDrivePermission(w http.ResponseWriter, r *http.Request) {
+	grantee, err := permissionGrantee(chi.URLParam(r, "permissionID"))
+	if err != nil {
+		renderError(w, r, err)
+		return
+	}
+	patch := spacePermission{}
+	if err := json.NewDecoder(r.Body).Decode(&patch); err != nil {
+		errorcode.InvalidRequest.Render(w, r, http.StatusBadRequest, fmt.Sprintf("invalid request body: %s", err.Error()))
+		return
+	}
+	role, err := permissionRole(patch.Roles)
+	if err != nil {
+		renderError(w, r, err)
+		return
+	}
+	g.logger.Info().Str("permission", permissionID(grantee)).Msg("Calling UpdateDrivePermission")
+
+	ctx := r.Context()
+	space, err := g.getSpace(ctx, chi.URLParam(r, "driveID"))
+	if err != nil {
+		renderError(w, r, err)
+		return
+	}
+	current, ok := g.spaceGrants(space)[granteeID(grantee)]
+	if !ok {
+		errorcode.ItemNotFound.Render(w, r, http.StatusNotFound, "permission not found")
+		return
+	}
+	if err := g.updateSpaceMember(ctx, space, grantee, spaceRole(current), role); err != nil {
+		renderError(w, r, err)
+		return
+	}
+
+	_, identity := g.resolveGrantee(ctx, granteeID(grantee))
+	render.Status(r, http.StatusOK)
+	render.JSON(w, r, spacePermission{
+		Id:        permissionID(grantee),
+		Roles:     []string{role},
+		GrantedTo: []identitySet{identity},
+	})
+}
+
+// DeleteDrivePermission removes a user or a group from a space. The last manager of a space can not be removed.
+func (g Graph) DeleteDrivePermission(w http.ResponseWriter, r *http.Request) {
+	grantee, err := permissionGrantee(chi.URLParam(r, "permissionID"))
+	if err != nil {
+		renderError(w, r, err)
+		return
+	}
+	g.logger.Info().Str("permission", permissionID(grantee)).Msg("Calling DeleteDrivePermission")
+
+	ctx := r.Context()
+	space, err := g.getSpace(ctx, chi.URLParam(r, "driveID"))
+	if err != nil {
+		renderError(w, r, err)
+		return
+	}
+	grants := g.spaceGrants(space)
+	current, ok := grants[granteeID(grantee)]
+	if !ok {
+		errorcode.ItemNotFound.Render(w, r, http.StatusNotFound, "permission not found")
+		return
+	}
+	if spaceRole(current) == SpaceRoleManager && !otherManagerRemaining(grants, granteeID(grantee)) {
+		errorcode.AccessDenied.Render(w, r, http.StatusForbidden, "the last manager of a space can not be removed")
+		return
+	}
+
+	key := &collaboration.ShareKey{ResourceId: space.Root, Grantee: grantee}
+	res, err := g.GetGatewayClient().RemoveShare(ctx, &collaboration.RemoveShareRequest{
+		Ref: &collaboration.ShareReference{Spec: &collaboration.ShareReference_Key{Key: key}},
+	})
+	switch {
+	case err != nil:
+		g.logger.Error().Err(err).Msg("error sending remove share grpc request")
+		errorcode.ServiceNotAvailable.Render(w, r, http.StatusInternalServerError, err.Error())
+		return
+	case res.Status.Code != cs3rpc.Code_CODE_OK:
+		renderError(w, r, cs3StatusError(res.Status))
+		return
+	}
+	g.publishEvent(events.ShareRemoved{ShareKey: key})
+
+	render.Status(r, http.StatusNoContent)
+	render.NoContent(w, r)
+}
+
+// getSpace returns the space with the id.
+func (g Graph) getSpace(ctx context.Context, driveID string) (*storageprovider.StorageSpace, error) {
+	driveID, err := url.PathUnescape(driveID)
+	if err != nil || driveID == "" {
+		return nil, errorcode.New(errorcode.InvalidRequest, "invalid drive id")
+	}
+	res, err := g.ListStorageSpacesWithFilters(ctx, []*storageprovider.ListStorageSpacesRequest_Filter{listStorageSpacesIDFilter(driveID)})
+	switch {
+	case err != nil:
+		g.logger.Error().Err(err).Msg(ListStorageSpacesTransportErr)
+		return nil, errorcode.New(errorcode.ServiceNotAvailable, err.Error())
+	case res.Status.Code == cs3rpc.Code_CODE_NOT_FOUND:
+		return nil, errorcode.New(errorcode.ItemNotFound, fmt.Sprintf(NoSpaceFoundMessage, driveID))
+	case res.Status.Code != cs3rpc.Code_CODE_OK:
+		return nil, cs3StatusError(res.Status)
+	}
+	if len(res.StorageSpaces) != 1 || res.StorageSpaces[0].Root == nil {
+		return nil, errorcode.New(errorcode.ItemNotFound, fmt.Sprintf(NoSpaceFoundMessage, driveID))
+	}
+	return res.StorageSpaces[0], nil
+}
+
+// spaceGrants returns the permissions of the members of a space by the id of the user or group.
+func (g Graph) spaceGrants(space *storageprovider.StorageSpace) map[string]*storageprovider.ResourcePermissions {
+	var m map[string]*storageprovider.ResourcePermissions
+	if entry, ok := space.GetOpaque().GetMap()["grants"]; ok {
+		if err := json.Unmarshal(entry.Value, &m); err != nil {
+			g.logger.Error().
+				Err(err).
+				Str("space", space.GetRoot().GetOpaqueId()).
+				Msg("failed to read spaces grants")
+		}
+	}
+	return m
+}
+
+// addSpaceMember grants the role in the space to a user or a group.
+func (g Graph) addSpaceMember(ctx context.Context, space *storageprovider.StorageSpace, grantee *storageprovider.Grantee, role string) error {
+	permissions, err := g.grantSpaceRole(ctx, space, grantee, role)
+	if err != nil {
+		return err
+	}
+	u, _ := revactx.ContextGetUser(ctx)
+	g.publishEvent(events.ShareCreated{
+		Sharer:         u.GetId(),
+		GranteeUserID:  grantee.GetUserId(),
+		GranteeGroupID: grantee.GetGroupId(),
+		Sharee:         grantee,
+		ItemID:         space.Root,
+		Permissions:    permissions,
+		CTime:          utils.TSNow(),
+	})
+	return nil
+}
+
+// updateSpaceMember changes the role of a user or a group in the space. The last manager of a space keeps the role.
+func (g Graph) updateSpaceMember(ctx context.Context, space *storageprovider.StorageSpace, grantee *storageprovider.Grantee, current, role string) error {
+	if current == role {
+		return nil
+	}
+	if current == SpaceRoleManager && !otherManagerRemaining(g.spaceGrants(space), granteeID(grantee)) {
+		return errorcode.New(errorcode.AccessDenied, "the role of the last manager of a space can not be changed")
+	}
+	permissions, err := g.grantSpaceRole(ctx, space, grantee, role)
+	if err != nil {
+		return err
+	}
+	u, _ := revactx.ContextGetUser(ctx)
+	g.publishEvent(events.ShareUpdated{
+		ItemID:         space.Root,
+		Permissions:    permissions,
+		GranteeUserID:  grantee.GetUserId(),
+		GranteeGroupID: grantee.GetGroupId(),
+		Sharer:         u.GetId(),
+		MTime:          utils.TSNow(),
+		Updated:        "permissions",
+	})
+	return nil
+}
+
+// grantSpaceRole sets the grant of a user or a group on the root of the space. An existing grant is replaced.
+func (g Graph) grantSpaceRole(ctx context.Context, space *storageprovider.StorageSpace, grantee *storageprovider.Grantee, role string) (*collaboration.SharePermissions, error) {
+	rp, ok := spaceRolePermissions(role)
+	if !ok {
+		return nil, errorcode.New(errorcode.InvalidRequest, fmt.Sprintf("invalid role '%s'", role))
+	}
+	permissions := &collaboration.SharePermissions{Permissions: rp}
+	res, err := g.GetGatewayClient().CreateShare(ctx, &collaboration.CreateShareRequest{
+		// the gateway adds a grant to the space instead of creating a share for the root of a space
+		ResourceInfo: &storageprovider.ResourceInfo{Id: space.Root},
+		Grant: &collaboration.ShareGrant{
+			Grantee:     grantee,
+			Permissions: permissions,
+		},
+	})
+	switch {
+	case err != nil:
+		g.logger.Error().Err(err).Msg("error sending create share grpc request")
+		return nil, errorcode.New(errorcode.ServiceNotAvailable, err.Error())
+	case res.Status.Code != cs3rpc.Code_CODE_OK:
+		return nil, cs3StatusError(res.Status)
+	}
+	return permissions, nil
+}
+
+// resolveGrantee returns the grantee and identity of the id of a space grant. The grants of a space do not tell users
+// and groups apart, ids which are neither a known user nor a group are returned as user.
+func (g Graph) resolveGrantee(ctx context.Context, id string) (*storageprovider.Grantee, identitySet) {
+	if u, err := g.identityBackend.GetUser(ctx, id); err == nil && u.GetId() == id {
+		return userGrantee(id), identitySet{User: &libregraph.Identity{Id: u.Id, DisplayName: u.DisplayName}}
+	}
+	if grp, err := g.identityBackend.GetGroup(ctx, id); err == nil && grp.GetId() == id {
+		return groupGrantee(id), identitySet{Group: &libregraph.Identity{Id: grp.Id, DisplayName: grp.DisplayName}}
+	}
+	return userGrantee(id), identitySet{User: &libregraph.Identity{Id: libregraph.PtrString(id)}}
+}
+
+// lookupRecipient returns the grantee and identity of the user or group with the id.
+func (g Graph) lookupRecipient(ctx context.Context, id string) (*storageprovider.Grantee, identitySet, error) {
+	if id == "" {
+		return nil, identitySet{}, errorcode.New(errorcode.InvalidRequest, "missing objectId of the recipient")
+	}
+	if u, err := g.identityBackend.GetUser(ctx, id); err == nil {
+		return userGrantee(u.GetId()), identitySet{User: &libregraph.Identity{Id: u.Id, DisplayName: u.DisplayName}}, nil
+	}
+	if grp, err := g.identityBackend.GetGroup(ctx, id); err == nil {
+		return groupGrantee(grp.GetId()), identitySet{Group: &libregraph.Identity{Id: grp.Id, DisplayName: grp.DisplayName}}, nil
+	}
+	return nil, identitySet{}, errorcode.New(errorcode.InvalidRequest, fmt.Sprintf("recipient '%s' not found", id))
+}
+
+// lookupGrantee returns the grantee and identity of the user or group of an identity set.
+func (g Graph) lookupGrantee(ctx context.Context, set identitySet) (*storageprovider.Grantee, identitySet, error) {
+	switch {
+	case set.User != nil && set.Group == nil:
+		u, err := g.identityBackend.GetUser(ctx, set.User.GetId())
+		if err != nil {
+			return nil, identitySet{}, errorcode.New(errorcode.InvalidRequest, fmt.Sprintf("user '%s' not found", set.User.GetId()))
+		}
+		return userGrantee(u.GetId()), identitySet{User: &libregraph.Identity{Id: u.Id, DisplayName: u.DisplayName}}, nil
+	case set.Group != nil && set.User == nil:
+		grp, err := g.identityBackend.GetGroup(ctx, set.Group.GetId())
+		if err != nil {
+			return nil, identitySet{}, errorcode.New(errorcode.InvalidRequest, fmt.Sprintf("group '%s' not found", set.Group.GetId()))
+		}
+		return groupGrantee(grp.GetId()), identitySet{Group: &libregraph.Identity{Id: grp.Id, DisplayName: grp.DisplayName}}, nil
+	}
+	return nil, identitySet{}, errorcode.New(errorcode.InvalidRequest, "a permission has to be granted to either a user or a group")
+}
+
+// permissionRole returns the single role of a permission.
+func permissionRole(roles []string) (string, error) {
+	if len(roles) != 1 {
+		return "", errorcode.New(errorcode.InvalidRequest, "a permission requires exactly one role")
+	}
+	if _, ok := spaceRolePermissions(roles[0]); !ok {
+		return "", errorcode.New(errorcode.InvalidRequest, fmt.Sprintf("invalid role '%s', valid roles are %s, %s and %s", roles[0], SpaceRoleViewer, SpaceRoleEditor, SpaceRoleManager))
+	}
+	return roles[0], nil
+}
+
+// otherManagerRemaining reports if a space has a manager besides the grantee with the id.
+func otherManagerRemaining(grants map[string]*storageprovider.ResourcePermissions, id string) bool {
+	for granteeID, p := range grants {
+		if granteeID != id && spaceRole(p) == SpaceRoleManager {
+			return true
+		}
+	}
+	return false
+}
+
+func userGrantee(id string) *storageprovider.Grantee {
+	return &storageprovider.Grantee{
+		Type: storageprovider.GranteeType_GRANTEE_TYPE_USER,
+		Id:   &storageprovider.Grantee_UserId{UserId: &cs3user.UserId{OpaqueId: id}},
+	}
+}
+
+func groupGrantee(id string) *storageprovider.Grantee {
+	return &storageprovider.Grantee{
+		Type: storageprovider.GranteeType_GRANTEE_TYPE_GROUP,
+		Id:   &storageprovider.Grantee_GroupId{GroupId: &cs3group.GroupId{OpaqueId: id}},
+	}
+}
+
+// granteeID returns the id of the user or group of a grantee.
+func granteeID(grantee *storageprovider.Grantee) string {
+	if grantee.Type == storageprovider.GranteeType_GRANTEE_TYPE_GROUP {
+		return grantee.GetGroupId().GetOpaqueId()
+	}
+	return grantee.GetUserId().GetOpaqueId()
+}
+
+// permissionID returns the id of the permission of a grantee.
+func permissionID(grantee *storageprovider.Grantee) string {
+	if grantee.Type == storageprovider.GranteeType_GRANTEE_TYPE_GROUP {
+		return groupPermissionPrefix + granteeID(grantee)
+	}
+	return userPermissionPrefix + granteeID(grantee)
+}
+
+// permissionGrantee returns the grantee of a permission id.
+func permissionGrantee(permissionID string) (*storageprovider.Grantee, error) {
+	id, err := url.PathUnescape(permissionID)
+	switch {
+	case err != nil:
+	case strings.HasPrefix(id, userPermissionPrefix) && len(id) > len(userPermissionPrefix):
+		return userGrantee(strings.TrimPrefix(id, userPermissionPrefix)), nil
+	case strings.HasPrefix(id, groupPermissionPrefix) && len(id) > len(groupPermissionPrefix):
+		return groupGrantee(strings.TrimPrefix(id, groupPermissionPrefix)), nil
+	}
+	return nil, errorcode.New(errorcode.ItemNotFound, fmt.Sprintf("permission '%s' not found", permissionID))
+}
@@ -0,0 +1,199 @@
+package svc_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+
+	userpb "github.com/cs3org/go-cs3apis/cs3/identity/user/v1beta1"
+	collaboration "github.com/cs3org/go-cs3apis/cs3/sharing/collaboration/v1beta1"
+	provider "github.com/cs3org/go-cs3apis/cs3/storage/provider/v1beta1"
+	types "github.com/cs3org/go-cs3apis/cs3/types/v1beta1"
+	revactx "github.com/cs3org/reva/v2/pkg/ctx"
+	"github.com/cs3org/reva/v2/pkg/events"
+	"github.com/cs3org/reva/v2/pkg/rgrpc/status"
+	"github.com/go-chi/chi/v5"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	libregraph "github.com/owncloud/libre-graph-api-go"
+	"github.com/owncloud/ocis/extensions/graph/mocks"
+	"github.com/owncloud/ocis/extensions/graph/pkg/config/defaults"
+	service "github.com/owncloud/ocis/extensions/graph/pkg/service/v0"
+	"github.com/stretchr/testify/mock"
+)
+
+var _ = Describe("Permissions", func() {
+	var (
+		svc             service.Service
+		gatewayClient   *mocks.GatewayClient
+		eventsPublisher *mocks.Publisher
+		identityBackend *mocks.Backend
+		ctx             context.Context
+
+		einstein = &userpb.User{Id: &userpb.UserId{OpaqueId: "einstein"}}
+	)
+
+	// request creates a request of einstein with the route parameters set.
+	request := func(method, target string, body io.Reader, params map[string]string) *http.Request {
+		r := httptest.NewRequest(method, target, body)
+		rctx := chi.NewRouteContext()
+		for k, v := range params {
+			rctx.URLParams.Add(k, v)
+		}
+		c := context.WithValue(revactx.ContextSetUser(r.Context(), einstein), chi.RouteCtxKey, rctx)
+		return r.WithContext(c)
+	}
+
+	JustBeforeEach(func() {
+		ctx = context.Background()
+		gatewayClient = &mocks.GatewayClient{}
+		eventsPublisher = &mocks.Publisher{}
+		identityBackend = &mocks.Backend{}
+		svc = service.NewService(
+			service.Config(defaults.DefaultConfig()),
+			service.WithGatewayClient(gatewayClient),
+			service.EventsPublisher(eventsPublisher),
+			service.WithIdentityBackend(identityBackend),
+		)
+
+		grants, _ := json.Marshal(map[string]*provider.ResourcePermissions{
+			"einstein": {AddGrant: true, InitiateFileUpload: true, Stat: true},
+			"physics":  {Stat: true},
+		})
+		gatewayClient.On("ListStorageSpaces", mock.Anything, mock.Anything).Return(&provider.ListStorageSpacesResponse{
+			Status: status.NewOK(ctx),
+			StorageSpaces: []*provider.StorageSpace{{
+				Id:        &provider.StorageSpaceId{OpaqueId: "sID"},
+				Root:      &provider.ResourceId{StorageId: "sID", OpaqueId: "sID"},
+				SpaceType: "project",
+				Opaque: &types.Opaque{Map: map[string]*types.OpaqueEntry{
+					"grants": {Decoder: "json", Value: grants},
+				}},
+			}},
+		}, nil)
+		identityBackend.On("GetUser", mock.Anything, "einstein").Return(&libregraph.User{
+			Id:          libregraph.PtrString("einstein"),
+			DisplayName: libregraph.PtrString("Albert Einstein"),
+		}, nil)
+		identityBackend.On("GetUser", mock.Anything, "marie").Return(&libregraph.User{
+			Id:          libregraph.PtrString("marie"),
+			DisplayName: libregraph.PtrString("Marie Curie"),
+		}, nil)
+		identityBackend.On("GetUser", mock.Anything, mock.Anything).Return(nil, errors.New("not found"))
+		identityBackend.On("GetGroup", mock.Anything, "physics").Return(&libregraph.Group{
+			Id:          libregraph.PtrString("physics"),
+			DisplayName: libregraph.PtrString("Physics"),
+		}, nil)
+		identityBackend.On("GetGroup", mock.Anything, mock.Anything).Return(nil, errors.New("not found"))
+	})
+
+	It("lists the members of a space", func() {
+		rr := httptest.NewRecorder()
+		svc.(service.Graph).ListDrivePermissions(rr, request(http.MethodGet, "/graph/v1.0/drives/sID/root/permissions", nil,
+			map[string]string{"driveID": "sID"}))
+		Expect(rr.Code).To(Equal(http.StatusOK))
+
+		res := struct {
+			Value []struct {
+				Id        string
+				Roles     []string
+				GrantedTo []struct {
+					User  *libregraph.Identity
+					Group *libregraph.Identity
+				}
+			}
+		}{}
+		err := json.Unmarshal(rr.Body.Bytes(), &res)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(len(res.Value)).To(Equal(2))
+		Expect(res.Value[0].Id).To(Equal("u:einstein"))
+		Expect(res.Value[0].Roles).To(Equal([]string{"manager"}))
+		Expect(res.Value[0].GrantedTo[0].User.GetDisplayName()).To(Equal("Albert Einstein"))
+		Expect(res.Value[1].Id).To(Equal("g:physics"))
+		Expect(res.Value[1].Roles).To(Equal([]string{"viewer"}))
+		Expect(res.Value[1].GrantedTo[0].Group.GetId()).To(Equal("physics"))
+	})
+
+	It("invites users and groups", func() {
+		gatewayClient.On("CreateShare", mock.Anything, mock.MatchedBy(func(req *collaboration.CreateShareRequest) bool {
+			return req.ResourceInfo.Id.OpaqueId == "sID" &&
+				req.Grant.Grantee.GetUserId().GetOpaqueId() == "marie" &&
+				req.Grant.Permissions.Permissions.InitiateFileUpload && !req.Grant.Permissions.Permissions.AddGrant
+		})).Return(&collaboration.CreateShareResponse{Status: status.NewOK(ctx)}, nil)
+		eventsPublisher.On("Publish", mock.Anything, mock.MatchedBy(func(ev events.ShareCreated) bool {
+			return ev.GranteeUserID.GetOpaqueId() == "marie" && ev.Sharer.GetOpaqueId() == "einstein"
+		}), mock.Anything).Return(nil)
+
+		rr := httptest.NewRecorder()
+		body := strings.NewReader(`{"recipients":[{"objectId":"marie"}],"roles":["editor"]}`)
+		svc.(service.Graph).InviteDriveMembers(rr, request(http.MethodPost, "/graph/v1.0/drives/sID/root/invite", body,
+			map[string]string{"driveID": "sID"}))
+		Expect(rr.Code).To(Equal(http.StatusOK))
+		Expect(rr.Body.String()).To(ContainSubstring(`"id":"u:marie"`))
+		gatewayClient.AssertNumberOfCalls(GinkgoT(), "CreateShare", 1)
+		eventsPublisher.AssertNumberOfCalls(GinkgoT(), "Publish", 1)
+
+		rr = httptest.NewRecorder()
+		body = strings.NewReader(`{"recipients":[{"objectId":"unknown"}],"roles":["editor"]}`)
+		svc.(service.Graph).InviteDriveMembers(rr, request(http.MethodPost, "/graph/v1.0/drives/sID/root/invite", body,
+			map[string]string{"driveID": "sID"}))
+		Expect(rr.Code).To(Equal(http.StatusBadRequest))
+
+		rr = httptest.NewRecorder()
+		body = strings.NewReader(`{"recipients":[{"objectId":"marie"}],"roles":["owner"]}`)
+		svc.(service.Graph).InviteDriveMembers(rr, request(http.MethodPost, "/graph/v1.0/drives/sID/root/invite", body,
+			map[string]string{"driveID": "sID"}))
+		Expect(rr.Code).To(Equal(http.StatusBadRequest))
+	})
+
+	It("changes the role of members", func() {
+		gatewayClient.On("CreateShare", mock.Anything, mock.MatchedBy(func(req *collaboration.CreateShareRequest) bool {
+			return req.Grant.Grantee.GetGroupId().GetOpaqueId() == "physics" && req.Grant.Permissions.Permissions.AddGrant
+		})).Return(&collaboration.CreateShareResponse{Status: status.NewOK(ctx)}, nil)
+		eventsPublisher.On("Publish", mock.Anything, mock.MatchedBy(func(ev events.ShareUpdated) bool {
+			return ev.GranteeGroupID.GetOpaqueId() == "physics" && ev.Updated == "permissions"
+		}), mock.Anything).Return(nil)
+
+		rr := httptest.NewRecorder()
+		body := strings.NewReader(`{"roles":["manager"]}`)
+		svc.(service.Graph).UpdateDrivePermission(rr, request(http.MethodPatch, "/graph/v1.0/drives/sID/root/permissions/g:physics", body,
+			map[string]string{"driveID": "sID", "permissionID": "g:physics"}))
+		Expect(rr.Code).To(Equal(http.StatusOK))
+		eventsPublisher.AssertNumberOfCalls(GinkgoT(), "Publish", 1)
+
+		rr = httptest.NewRecorder()
+		body = strings.NewReader(`{"roles":["viewer"]}`)
+		svc.(service.Graph).UpdateDrivePermission(rr, request(http.MethodPatch, "/graph/v1.0/drives/sID/root/permissions/u:einstein", body,
+			map[string]string{"driveID": "sID", "permissionID": "u:einstein"}))
+		Expect(rr.Code).To(Equal(http.StatusForbidden))
+
+		rr = httptest.NewRecorder()
+		body = strings.NewReader(`{"roles":["viewer"]}`)
+		svc.(service.Graph).UpdateDrivePermission(rr, request(http.MethodPatch, "/graph/v1.0/drives/sID/root/permissions/u:marie", body,
+			map[string]string{"driveID": "sID", "permissionID": "u:marie"}))
+		Expect(rr.Code).To(Equal(http.StatusNotFound))
+	})
+
+	It("removes members but not the last manager", func() {
+		gatewayClient.On("RemoveShare", mock.Anything, mock.MatchedBy(func(req *collaboration.RemoveShareRequest) bool {
+			return req.Ref.GetKey().GetGrantee().GetGroupId().GetOpaqueId() == "physics"
+		})).Return(&collaboration.RemoveShareResponse{Status: status.NewOK(ctx)}, nil)
+		eventsPublisher.On("Publish", mock.Anything, mock.AnythingOfType("events.ShareRemoved"), mock.Anything).Return(nil)
+
+		rr := httptest.NewRecorder()
+		svc.(service.Graph).DeleteDrivePermission(rr, request(http.MethodDelete, "/graph/v1.0/drives/sID/root/permissions/g:physics", nil,
+			map[string]string{"driveID": "sID", "permissionID": "g:physics"}))
+		Expect(rr.Code).To(Equal(http.StatusNoContent))
+		eventsPublisher.AssertNumberOfCalls(GinkgoT(), "Publish", 1)
+
+		rr = httptest.NewRecorder()
+		svc.(service.Graph).DeleteDrivePermission(rr, request(http.MethodDelete, "/graph/v1.0/drives/sID/root/permissions/u:einstein", nil,
+			map[string]string{"driveID": "sID", "permissionID": "u:einstein"}))
+		Expect(rr.Code).To(Equal(http.StatusForbidden))
+		gatewayClient.AssertNumberOfCalls(GinkgoT(), "RemoveShare", 1)
+	})
+})
@@ -0,0 +1,106 @@
+// Package photo implements the storage of the profile photos of the users.
+package photo
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"image"
+	"io"
+	"os"
+	"path/filepath"
+
+	// register the decoders of the supported formats
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+
+	"github.com/owncloud/ocis/extensions/thumbnails/pkg/thumbnail"
+)
+
+// ErrInvalidImage is returned by Resize if the data isn't an image of a supported format.
+var ErrInvalidImage = errors.New("invalid image")
+
+// ErrTooManyPixels is returned by Resize if the image has more pixels than allowed.
+var ErrTooManyPixels = errors.New("the image has too many pixels")
+
+// Store stores a single photo per user in a directory.
+type Store struct {
+	root string
+}
+
+// NewStore returns a store keeping the photos in the given directory, which is created on the first write.
+func NewStore(root string) Store {
+	return Store{root: root}
+}
+
+// Get returns the photo of a user. It returns an error matching fs.ErrNotExist if the user has no photo.
+func (s Store) Get(userID string) ([]byte, error) {
+	return os.ReadFile(s.path(userID))
+}
+
+// Put replaces the photo of a user. The photo is written to a temporary file first, so that concurrent reads never
+// see a partially written photo.
+func (s Store) Put(userID string, data []byte) error {
+	if err := os.MkdirAll(s.root, 0700); err != nil {
+		return err
+	}
+	f, err := os.CreateTemp(s.root, ".upload-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), s.path(userID))
+}
+
+// path returns the file of the photo of a user, the id is encoded to be safe to use as a file name.
+func (s Store) path(userID string) string {
+	return filepath.Join(s.root, base64.RawURLEncoding.EncodeToString([]byte(userID)))
+}
+
+// Resize decodes an image and scales it down to fit the given size. The result is encoded in the format of the
+// original image, animated gifs are flattened and stored as png. Images with more than maxPixels pixels are rejected
+// before they are decoded, a small file can hold an image which needs gigabytes of memory when decoded. A maxPixels
+// of 0 allows images of any size.
+func Resize(r io.Reader, size image.Rectangle, maxPixels int64) ([]byte, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		// the reader is limited to the maximum upload size, so reading fails for too large images
+		return nil, ErrInvalidImage
+	}
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, ErrInvalidImage
+	}
+	if maxPixels > 0 && int64(cfg.Width)*int64(cfg.Height) > maxPixels {
+		return nil, ErrTooManyPixels
+	}
+	img, format, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, ErrInvalidImage
+	}
+	thumb, err := thumbnail.SimpleGenerator{}.GenerateThumbnail(size, img)
+	if err != nil {
+		return nil, err
+	}
+
+	var enc thumbnail.Encoder = thumbnail.PngEncoder{}
+	if format != "gif" {
+		if enc, err = thumbnail.EncoderForType(format); err != nil {
+			return nil, err
+		}
+	}
+	buf := &bytes.Buffer{}
+	if err := enc.Encode(buf, thumb); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,54 @@
+package photo
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/jpeg"
+	"io/fs"
+	"testing"
+)
+
+func TestStore(t *testing.T) {
+	s := NewStore(t.TempDir())
+
+	if _, err := s.Get("../user"); !errors.Is(err, fs.ErrNotExist) {
+		t.Fatalf("Expected fs.ErrNotExist, got '%v'", err)
+	}
+	if err := s.Put("../user", []byte("photo")); err != nil {
+		t.Fatalf("Expected success, got '%s'", err.Error())
+	}
+	data, err := s.Get("../user")
+	if err != nil {
+		t.Fatalf("Expected success, got '%s'", err.Error())
+	}
+	if string(data) != "photo" {
+		t.Errorf("Unexpected photo '%s'", data)
+	}
+}
+
+func TestResize(t *testing.T) {
+	buf := &bytes.Buffer{}
+	if err := jpeg.Encode(buf, image.NewRGBA(image.Rect(0, 0, 400, 200)), nil); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := Resize(bytes.NewReader(buf.Bytes()), image.Rect(0, 0, 100, 100), 400*200)
+	if err != nil {
+		t.Fatalf("Expected success, got '%s'", err.Error())
+	}
+	img, format, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("Expected success, got '%s'", err.Error())
+	}
+	if format != "jpeg" || img.Bounds().Dx() != 100 {
+		t.Errorf("Unexpected %s image of size %v", format, img.Bounds())
+	}
+
+	if _, err := Resize(bytes.NewReader([]byte("invalid")), image.Rect(0, 0, 100, 100), 0); !errors.Is(err, ErrInvalidImage) {
+		t.Errorf("Expected ErrInvalidImage, got '%v'", err)
+	}
+	if _, err := Resize(bytes.NewReader(buf.Bytes()), image.Rect(0, 0, 100, 100), 400*200-1); !errors.Is(err, ErrTooManyPixels) {
+		t.Errorf("Expected ErrTooManyPixels, got '%v'", err)
+	}
+}
@@ -0,0 +1,110 @@
+// Package projection implements the OData $select and $expand query options of the graph service. The handlers
+// resolve the requested expansions and use a Projection to shape the returned resources.
+package projection
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// The query options handled by a Projection.
+const (
+	SelectParam = "$select"
+	ExpandParam = "$expand"
+)
+
+// ErrUnsupported is returned for valid $select or $expand options using features the graph service does not
+// implement.
+var ErrUnsupported = errors.New("unsupported projection")
+
+// Projection describes the shape of the returned resources. The zero value returns the resources unchanged.
+type Projection struct {
+	// Select are the names of the returned properties, all properties are returned if it is empty.
+	Select []string
+	// Expand are the names of the expanded navigation properties.
+	Expand []string
+}
+
+// Parse reads the $select and $expand options of the query. Only the given navigation properties can be expanded.
+func Parse(query url.Values, expandable ...string) (Projection, error) {
+	p := Projection{}
+	for _, s := range split(query.Get(SelectParam)) {
+		if strings.ContainsAny(s, "/(") {
+			return p, fmt.Errorf("%w: %s '%s'", ErrUnsupported, SelectParam, s)
+		}
+		if s != "*" {
+			p.Select = append(p.Select, s)
+		}
+	}
+
+	for _, e := range split(query.Get(ExpandParam)) {
+		if strings.ContainsAny(e, "/(") {
+			return p, fmt.Errorf("%w: %s '%s', nested options are not supported", ErrUnsupported, ExpandParam, e)
+		}
+		if !contains(expandable, e) {
+			return p, fmt.Errorf("property '%s' can not be expanded", e)
+		}
+		if !contains(p.Expand, e) {
+			p.Expand = append(p.Expand, e)
+		}
+	}
+	return p, nil
+}
+
+// Expands reports if the navigation property is expanded.
+func (p Projection) Expands(property string) bool {
+	return contains(p.Expand, property)
+}
+
+// Apply returns the JSON representation of v with the expanded navigation properties added and only the selected
+// properties kept. The id of a resource is always returned. If there is nothing to change v is returned as it is.
+func (p Projection) Apply(v interface{}, expanded map[string]interface{}) (interface{}, error) {
+	if len(p.Select) == 0 && len(expanded) == 0 {
+		return v, nil
+	}
+
+	b, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	m := map[string]interface{}{}
+	if err := json.Unmarshal(b, &m); err != nil {
+		return nil, err
+	}
+	for k, e := range expanded {
+		m[k] = e
+	}
+	if len(p.Select) == 0 {
+		return m, nil
+	}
+
+	selected := make(map[string]interface{}, len(p.Select)+len(expanded)+1)
+	for k, e := range m {
+		if _, ok := expanded[k]; ok || k == "id" || contains(p.Select, k) {
+			selected[k] = e
+		}
+	}
+	return selected, nil
+}
+
+func split(v string) []string {
+	var items []string
+	for _, item := range strings.Split(v, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+func contains(items []string, item string) bool {
+	for _, i := range items {
+		if i == item {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,61 @@
+package projection
+
+import (
+	"errors"
+	"net/url"
+	"reflect"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	p, err := Parse(url.Values{SelectParam: {"displayName, mail"}, ExpandParam: {"memberOf,memberOf"}}, "memberOf")
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if !reflect.DeepEqual(p.Select, []string{"displayName", "mail"}) || !reflect.DeepEqual(p.Expand, []string{"memberOf"}) {
+		t.Errorf("unexpected projection %+v", p)
+	}
+	if !p.Expands("memberOf") || p.Expands("members") {
+		t.Error("unexpected result of Expands")
+	}
+
+	if _, err := Parse(url.Values{ExpandParam: {"drive"}}, "memberOf"); err == nil || errors.Is(err, ErrUnsupported) {
+		t.Errorf("expected an invalid request got %v", err)
+	}
+	for _, q := range []url.Values{
+		{ExpandParam: {"memberOf($select=id)"}},
+		{SelectParam: {"owner/user"}},
+	} {
+		if _, err := Parse(q, "memberOf"); !errors.Is(err, ErrUnsupported) {
+			t.Errorf("%v: expected ErrUnsupported got %v", q, err)
+		}
+	}
+}
+
+func TestApply(t *testing.T) {
+	type user struct {
+		ID   string `json:"id"`
+		Name string `json:"name"`
+		Mail string `json:"mail"`
+	}
+	u := user{ID: "einstein", Name: "Albert Einstein", Mail: "einstein@example.org"}
+
+	v, err := Projection{}.Apply(u, nil)
+	if err != nil || v != u {
+		t.Errorf("expected the value to be returned unchanged, got %v %v", v, err)
+	}
+
+	v, err = Projection{Select: []string{"name"}}.Apply(u, map[string]interface{}{"memberOf": []string{"physics"}})
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	want := map[string]interface{}{"id": "einstein", "name": "Albert Einstein", "memberOf": []string{"physics"}}
+	if !reflect.DeepEqual(v, want) {
+		t.Errorf("expected %v got %v", want, v)
+	}
+
+	v, _ = Projection{Expand: []string{"memberOf"}}.Apply(u, map[string]interface{}{"memberOf": []string{}})
+	if m := v.(map[string]interface{}); len(m) != 4 {
+		t.Errorf("expected all properties and the expansion got %v", m)
+	}
+}
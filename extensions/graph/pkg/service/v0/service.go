@@ -10,10 +10,13 @@ import (
 	"github.com/cs3org/reva/v2/pkg/rgrpc/todo/pool"
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
+	"github.com/prometheus/client_golang/prometheus"
 
 	"github.com/owncloud/ocis/extensions/graph/pkg/identity"
 	"github.com/owncloud/ocis/extensions/graph/pkg/identity/ldap"
 	graphm "github.com/owncloud/ocis/extensions/graph/pkg/middleware"
+	"github.com/owncloud/ocis/extensions/graph/pkg/service/v0/photo"
+	"github.com/owncloud/ocis/extensions/thumbnails/pkg/thumbnail"
 	"github.com/owncloud/ocis/ocis-pkg/account"
 	opkgm "github.com/owncloud/ocis/ocis-pkg/middleware"
 	"github.com/owncloud/ocis/ocis-pkg/roles"
@@ -56,15 +59,16 @@ func NewService(opts ...Option) Service {
 	m.Use(options.Middleware...)
 
 	var backend identity.Backend
-	switch options.Config.Identity.Backend {
-	case "cs3":
+	educationBackend := options.IdentityEducationBackend
+	switch {
+	case options.IdentityBackend != nil:
+		backend = options.IdentityBackend
+	case options.Config.Identity.Backend == "cs3":
 		backend = &identity.CS3{
 			Config: &options.Config.Reva,
 			Logger: &options.Logger,
 		}
-	case "ldap":
-		var err error
-
+	case options.Config.Identity.Backend == "ldap":
 		var tlsConf *tls.Config
 		if options.Config.Identity.LDAP.Insecure {
 			tlsConf = &tls.Config{
@@ -73,23 +77,65 @@ func NewService(opts ...Option) Service {
 			}
 		}
 
-		conn := ldap.NewLDAPWithReconnect(&options.Logger,
+		var ldapDuration *prometheus.HistogramVec
+		if options.Metrics != nil {
+			ldapDuration = options.Metrics.LDAPRequestDuration
+		}
+		conn := ldap.NewLDAPPool(&options.Logger,
 			ldap.Config{
 				URI:          options.Config.Identity.LDAP.URI,
 				BindDN:       options.Config.Identity.LDAP.BindDN,
 				BindPassword: options.Config.Identity.LDAP.BindPassword,
 				TLSConfig:    tlsConf,
+				StartTLS:     options.Config.Identity.LDAP.StartTLS,
 			},
+			options.Config.Identity.LDAP.PoolSize,
+			ldapDuration,
 		)
-		if backend, err = identity.NewLDAPBackend(conn, options.Config.Identity.LDAP, &options.Logger); err != nil {
+		lb, err := identity.NewLDAPBackend(conn, options.Config.Identity.LDAP, &options.Logger)
+		if err != nil {
 			options.Logger.Error().Msgf("Error initializing LDAP Backend: '%s'", err)
 			return nil
 		}
+		backend = lb
+		if options.Config.Identity.LDAP.EducationResourcesEnabled {
+			educationBackend = lb
+		}
 	default:
 		options.Logger.Error().Msgf("Unknown Identity Backend: '%s'", options.Config.Identity.Backend)
 		return nil
 	}
 
+	passwordVerifier, _ := backend.(identity.PasswordVerifier)
+
+	var identityCache *identity.CachedBackend
+	if options.IdentityBackend == nil && options.Config.Identity.CacheTTL > 0 {
+		var lookups *prometheus.CounterVec
+		if options.Metrics != nil {
+			lookups = options.Metrics.IdentityCacheLookups
+		}
+		identityCache = identity.NewCachedBackend(backend, time.Duration(options.Config.Identity.CacheTTL)*time.Second, lookups)
+		backend = identityCache
+		if options.EventsConsumer != nil {
+			if err := consumeIdentityEvents(options.EventsConsumer, identityCache); err != nil {
+				options.Logger.Error().Err(err).Msg("Could not consume the identity events, changes of other instances are only visible after the cache TTL")
+			}
+		}
+	}
+
+	photoSize, err := thumbnail.ParseResolution(options.Config.Photos.Resolution)
+	if err != nil {
+		options.Logger.Error().Err(err).Msg("Invalid profile photo resolution")
+		return nil
+	}
+
+	// the keys are derived from the secret with labels of their own, so they differ from the jwt signing key even if
+	// the jwt secret is used
+	uploadSessionSecret := options.Config.API.UploadSessionSecret
+	if uploadSessionSecret == "" {
+		uploadSessionSecret = options.Config.TokenManager.JWTSecret
+	}
+
 	svc := Graph{
 		config:               options.Config,
 		mux:                  m,
@@ -97,6 +143,14 @@ func NewService(opts ...Option) Service {
 		identityBackend:      backend,
 		spacePropertiesCache: ttlcache.NewCache(),
 		eventsPublisher:      options.EventsPublisher,
+
+		identityEducationBackend: educationBackend,
+		passwordVerifier:         passwordVerifier,
+
+		photoStore: photo.NewStore(options.Config.Photos.RootDirectory),
+		photoSize:  photoSize,
+
+		uploadSessionKeys: newUploadSessionKeys([]byte(uploadSessionSecret)),
 	}
 	if options.GatewayClient == nil {
 		var err error
@@ -139,10 +193,16 @@ func NewService(opts ...Option) Service {
 	m.Route(options.Config.HTTP.Root, func(r chi.Router) {
 		r.Use(middleware.StripSlashes)
 		r.Route("/v1.0", func(r chi.Router) {
+			r.Post("/$batch", svc.PostBatch)
+			r.Get("/capabilities", svc.GetCapabilities)
 			r.Route("/me", func(r chi.Router) {
 				r.Get("/", svc.GetMe)
 				r.Get("/drives", svc.GetDrives)
+				r.Get("/drives/delta", svc.GetDrivesDelta)
 				r.Get("/drive/root/children", svc.GetRootDriveChildren)
+				r.Post("/changePassword", svc.ChangeOwnPassword)
+				r.Get("/photo/$value", svc.GetMePhoto)
+				r.Put("/photo/$value", svc.PutMePhoto)
 			})
 			r.Route("/users", func(r chi.Router) {
 				r.With(requireAdmin).Get("/", svc.GetUsers)
@@ -151,6 +211,55 @@ func NewService(opts ...Option) Service {
 					r.Get("/", svc.GetUser)
 					r.With(requireAdmin).Delete("/", svc.DeleteUser)
 					r.With(requireAdmin).Patch("/", svc.PatchUser)
+					r.With(requireAdmin).Get("/transitiveMemberOf", svc.GetUserTransitiveMemberOf)
+				})
+			})
+			r.Route("/education", func(r chi.Router) {
+				if identityCache != nil {
+					// the education resources are written without the cached backend
+					r.Use(purgeIdentityCache(identityCache))
+				}
+				r.Route("/schools", func(r chi.Router) {
+					r.With(requireAdmin).Get("/", svc.GetEducationSchools)
+					r.With(requireAdmin).Post("/", svc.PostEducationSchool)
+					r.Route("/{schoolID}", func(r chi.Router) {
+						r.Get("/", svc.GetEducationSchool)
+						r.With(requireAdmin).Delete("/", svc.DeleteEducationSchool)
+						r.With(requireAdmin).Patch("/", svc.PatchEducationSchool)
+						r.Route("/users", func(r chi.Router) {
+							r.With(requireAdmin).Get("/", svc.GetEducationSchoolUsers)
+							r.With(requireAdmin).Post("/$ref", svc.PostEducationSchoolUser)
+							r.With(requireAdmin).Delete("/{userID}/$ref", svc.DeleteEducationSchoolUser)
+						})
+						r.Route("/classes", func(r chi.Router) {
+							r.With(requireAdmin).Get("/", svc.GetEducationSchoolClasses)
+							r.With(requireAdmin).Post("/$ref", svc.PostEducationSchoolClass)
+							r.With(requireAdmin).Delete("/{classID}/$ref", svc.DeleteEducationSchoolClass)
+						})
+					})
+				})
+				r.Route("/classes", func(r chi.Router) {
+					r.With(requireAdmin).Get("/", svc.GetEducationClasses)
+					r.With(requireAdmin).Post("/", svc.PostEducationClass)
+					r.Route("/{classID}", func(r chi.Router) {
+						r.Get("/", svc.GetEducationClass)
+						r.With(requireAdmin).Delete("/", svc.DeleteEducationClass)
+						r.With(requireAdmin).Patch("/", svc.PatchEducationClass)
+						r.Route("/members", func(r chi.Router) {
+							r.With(requireAdmin).Get("/", svc.GetEducationClassMembers)
+							r.With(requireAdmin).Post("/$ref", svc.PostEducationClassMember)
+							r.With(requireAdmin).Delete("/{memberID}/$ref", svc.DeleteEducationClassMember)
+						})
+					})
+				})
+				r.Route("/users", func(r chi.Router) {
+					r.With(requireAdmin).Get("/", svc.GetEducationUsers)
+					r.With(requireAdmin).Post("/", svc.PostEducationUser)
+					r.Route("/{userID}", func(r chi.Router) {
+						r.Get("/", svc.GetEducationUser)
+						r.With(requireAdmin).Delete("/", svc.DeleteEducationUser)
+						r.With(requireAdmin).Patch("/", svc.PatchEducationUser)
+					})
 				})
 			})
 			r.Route("/groups", func(r chi.Router) {
@@ -165,6 +274,7 @@ func NewService(opts ...Option) Service {
 						r.With(requireAdmin).Post("/$ref", svc.PostGroupMember)
 						r.With(requireAdmin).Delete("/{memberID}/$ref", svc.DeleteGroupMember)
 					})
+					r.With(requireAdmin).Get("/transitiveMembers", svc.GetGroupTransitiveMembers)
 				})
 			})
 			r.Group(func(r chi.Router) {
@@ -179,8 +289,35 @@ func NewService(opts ...Option) Service {
 						r.Patch("/", svc.UpdateDrive)
 						r.Get("/", svc.GetSingleDrive)
 						r.Delete("/", svc.DeleteDrive)
+						r.Route("/root", func(r chi.Router) {
+							r.Get("/", svc.GetDriveItem)
+							r.Get("/children", svc.GetDriveItemChildren)
+							r.Post("/children", svc.CreateDriveItem)
+							r.Get("/delta", svc.GetDriveItemsDelta)
+							r.Post("/createUploadSession", svc.CreateUploadSession)
+							r.Post("/invite", svc.InviteDriveMembers)
+							r.Route("/permissions", func(r chi.Router) {
+								r.Get("/", svc.ListDrivePermissions)
+								r.Post("/", svc.CreateDrivePermission)
+								r.Patch("/{permissionID}", svc.UpdateDrivePermission)
+								r.Delete("/{permissionID}", svc.DeleteDrivePermission)
+							})
+						})
+						r.Route("/items/{itemID}", func(r chi.Router) {
+							r.Get("/", svc.GetDriveItem)
+							r.Patch("/", svc.UpdateDriveItem)
+							r.Delete("/", svc.DeleteDriveItem)
+							r.Get("/children", svc.GetDriveItemChildren)
+							r.Post("/children", svc.CreateDriveItem)
+							r.Post("/copy", svc.CopyDriveItem)
+							r.Post("/createUploadSession", svc.CreateUploadSession)
+						})
 					})
 				})
+				r.Route("/uploadSessions/{sessionID}", func(r chi.Router) {
+					r.Get("/", svc.GetUploadSession)
+					r.Put("/", svc.UploadChunk)
+				})
 			})
 		})
 	})
@@ -0,0 +1,473 @@
+package svc
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	cs3rpc "github.com/cs3org/go-cs3apis/cs3/rpc/v1beta1"
+	storageprovider "github.com/cs3org/go-cs3apis/cs3/storage/provider/v1beta1"
+	types "github.com/cs3org/go-cs3apis/cs3/types/v1beta1"
+	revactx "github.com/cs3org/reva/v2/pkg/ctx"
+	"github.com/cs3org/reva/v2/pkg/utils/resourceid"
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/render"
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/owncloud/ocis/extensions/graph/pkg/service/v0/errorcode"
+	"github.com/owncloud/ocis/extensions/graph/pkg/service/v0/net"
+)
+
+// The conflict behaviors of an upload session.
+const (
+	conflictBehaviorFail    = "fail"
+	conflictBehaviorReplace = "replace"
+	conflictBehaviorRename  = "rename"
+)
+
+const (
+	tusVersion = "1.0.0"
+	// maxRenameAttempts limits the number of names tried to find a free name for the conflict behavior rename.
+	maxRenameAttempts = 100
+)
+
+// uploadableProperties are the properties of the file uploaded with an upload session.
+type uploadableProperties struct {
+	Name             string `json:"name,omitempty"`
+	FileSize         *int64 `json:"fileSize,omitempty"`
+	ConflictBehavior string `json:"@microsoft.graph.conflictBehavior,omitempty"`
+}
+
+type createUploadSessionRequest struct {
+	Item uploadableProperties `json:"item"`
+}
+
+// uploadSession describes the progress of an upload session.
+type uploadSession struct {
+	UploadURL          string    `json:"uploadUrl,omitempty"`
+	ExpirationDateTime time.Time `json:"expirationDateTime"`
+	NextExpectedRanges []string  `json:"nextExpectedRanges"`
+}
+
+// uploadSessionAudience is the audience of the upload session tokens, so that no other token is accepted as session.
+const uploadSessionAudience = "ocis-graph-upload-session"
+
+// uploadSessionClaims hold the state of an upload session. They are signed and used as the id of the session, so the
+// chunks can be sent to any instance of the graph service. The progress of the upload is tracked by the data gateway.
+type uploadSessionClaims struct {
+	jwt.RegisteredClaims
+	// Transfer is the encrypted tus endpoint of the upload at the data gateway, which includes the transfer token.
+	Transfer string `json:"transfer"`
+	// Size is the size of the uploaded file.
+	Size int64 `json:"size"`
+	// Item is the id of the uploaded file or of the folder it is uploaded to.
+	Item string `json:"item"`
+	// Path is the path of the uploaded file relative to the item.
+	Path string `json:"path,omitempty"`
+
+	// endpoint is the decrypted Transfer
+	endpoint string
+}
+
+// uploadSessionKeys are derived from the upload session secret. The sessions are signed with one key, the transfer
+// endpoints are encrypted with the other one, so that clients can't read the transfer tokens.
+type uploadSessionKeys struct {
+	sign    []byte
+	encrypt []byte
+}
+
+func newUploadSessionKeys(secret []byte) uploadSessionKeys {
+	derive := func(label string) []byte {
+		mac := hmac.New(sha256.New, secret)
+		mac.Write([]byte(label))
+		return mac.Sum(nil)
+	}
+	return uploadSessionKeys{
+		sign:    derive("graph upload session signature"),
+		encrypt: derive("graph upload session encryption"),
+	}
+}
+
+// seal encrypts the endpoint of the session of a user.
+func (k uploadSessionKeys) seal(endpoint, subject string) (string, error) {
+	aead, err := k.aead()
+	if err != nil {
+		return "", err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(aead.Seal(nonce, nonce, []byte(endpoint), []byte(subject))), nil
+}
+
+// open decrypts an endpoint encrypted by seal for the same user.
+func (k uploadSessionKeys) open(transfer, subject string) (string, error) {
+	aead, err := k.aead()
+	if err != nil {
+		return "", err
+	}
+	b, err := base64.RawURLEncoding.DecodeString(transfer)
+	if err != nil || len(b) < aead.NonceSize() {
+		return "", errors.New("invalid transfer")
+	}
+	endpoint, err := aead.Open(nil, b[:aead.NonceSize()], b[aead.NonceSize():], []byte(subject))
+	if err != nil {
+		return "", err
+	}
+	return string(endpoint), nil
+}
+
+func (k uploadSessionKeys) aead() (cipher.AEAD, error) {
+	block, err := aes.NewCipher(k.encrypt)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// CreateUploadSession starts the upload of a file in chunks. The file either replaces the addressed item or, if the
+// item properties contain a name, is created in the addressed folder.
+func (g Graph) CreateUploadSession(w http.ResponseWriter, r *http.Request) {
+	ref, err := driveItemRef(r)
+	if err != nil {
+		renderError(w, r, err)
+		return
+	}
+	u, ok := revactx.ContextGetUser(r.Context())
+	if !ok {
+		errorcode.GeneralException.Render(w, r, http.StatusUnauthorized, "invalid user")
+		return
+	}
+
+	req := createUploadSessionRequest{}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		errorcode.InvalidRequest.Render(w, r, http.StatusBadRequest, fmt.Sprintf("invalid request body: %s", err.Error()))
+		return
+	}
+	if req.Item.FileSize == nil || *req.Item.FileSize <= 0 {
+		errorcode.InvalidRequest.Render(w, r, http.StatusBadRequest, "the item requires the fileSize of the uploaded file, empty files can not be uploaded in chunks")
+		return
+	}
+	if req.Item.Name != "" {
+		if ref, err = childRef(ref, req.Item.Name); err != nil {
+			renderError(w, r, err)
+			return
+		}
+	}
+	g.logger.Info().Interface("ref", ref).Msg("Calling CreateUploadSession")
+
+	ctx := r.Context()
+	switch req.Item.ConflictBehavior {
+	case "", conflictBehaviorReplace:
+	case conflictBehaviorFail:
+		if _, err := g.statResource(ctx, ref); err == nil {
+			errorcode.NameAlreadyExists.Render(w, r, http.StatusConflict, "the item already exists")
+			return
+		}
+	case conflictBehaviorRename:
+		if ref, err = g.availableRef(ctx, ref); err != nil {
+			renderError(w, r, err)
+			return
+		}
+	default:
+		errorcode.InvalidRequest.Render(w, r, http.StatusBadRequest, fmt.Sprintf("invalid conflict behavior '%s'", req.Item.ConflictBehavior))
+		return
+	}
+
+	res, err := g.GetGatewayClient().InitiateFileUpload(ctx, &storageprovider.InitiateFileUploadRequest{
+		Ref: ref,
+		Opaque: &types.Opaque{Map: map[string]*types.OpaqueEntry{
+			"Upload-Length": {Decoder: "plain", Value: []byte(strconv.FormatInt(*req.Item.FileSize, 10))},
+		}},
+	})
+	switch {
+	case err != nil:
+		g.logger.Error().Err(err).Msg("error sending initiate file upload grpc request")
+		errorcode.ServiceNotAvailable.Render(w, r, http.StatusInternalServerError, err.Error())
+		return
+	case res.Status.Code != cs3rpc.Code_CODE_OK:
+		renderError(w, r, cs3StatusError(res.Status))
+		return
+	}
+	var endpoint string
+	for _, p := range res.Protocols {
+		if p.Protocol == "tus" {
+			// like tus clients we can not send the transfer token as header, the data gateway reads it from the url
+			endpoint = strings.TrimSuffix(p.UploadEndpoint, "/") + "/" + p.Token
+		}
+	}
+	if endpoint == "" {
+		errorcode.NotSupported.Render(w, r, http.StatusNotImplemented, "the storage provider does not support resumable uploads")
+		return
+	}
+
+	subject := u.GetId().GetOpaqueId()
+	transfer, err := g.uploadSessionKeys.seal(endpoint, subject)
+	if err != nil {
+		g.logger.Error().Err(err).Msg("could not encrypt the transfer of the upload session")
+		errorcode.GeneralException.Render(w, r, http.StatusInternalServerError, err.Error())
+		return
+	}
+	now := time.Now()
+	expires := now.Add(time.Duration(g.config.API.UploadSessionExpiration) * time.Second)
+	claims := uploadSessionClaims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			Subject:   subject,
+			Audience:  jwt.ClaimStrings{uploadSessionAudience},
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(expires),
+		},
+		Transfer: transfer,
+		Size:     *req.Item.FileSize,
+		Item:     resourceid.OwnCloudResourceIDWrap(ref.ResourceId),
+		Path:     ref.Path,
+	}
+	sessionID, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(g.uploadSessionKeys.sign)
+	if err != nil {
+		g.logger.Error().Err(err).Msg("could not sign the upload session")
+		errorcode.GeneralException.Render(w, r, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	render.Status(r, http.StatusOK)
+	render.JSON(w, r, uploadSession{
+		// the graph service is only reachable through the proxy, so the url is based on the public ocis url
+		UploadURL:          strings.TrimSuffix(g.config.Spaces.WebDavBase, "/") + path.Join(g.config.HTTP.Root, "v1.0/uploadSessions", sessionID),
+		ExpirationDateTime: claims.ExpiresAt.Time,
+		NextExpectedRanges: []string{"0-"},
+	})
+}
+
+// GetUploadSession returns the progress of an upload session, which allows clients to resume an interrupted upload.
+func (g Graph) GetUploadSession(w http.ResponseWriter, r *http.Request) {
+	claims, err := g.uploadSessionClaims(r)
+	if err != nil {
+		renderError(w, r, err)
+		return
+	}
+	offset, err := g.uploadOffset(r.Context(), claims.endpoint)
+	if err != nil {
+		renderError(w, r, err)
+		return
+	}
+	render.Status(r, http.StatusOK)
+	render.JSON(w, r, claims.progress(offset))
+}
+
+// UploadChunk uploads the byte range given by the Content-Range header of the request. Chunks have to be sent in
+// order, the bytes of a chunk which have already been received are skipped, so a chunk can be sent again after a
+// network failure. The response to the last chunk contains the uploaded item.
+func (g Graph) UploadChunk(w http.ResponseWriter, r *http.Request) {
+	claims, err := g.uploadSessionClaims(r)
+	if err != nil {
+		renderError(w, r, err)
+		return
+	}
+	start, end, total, err := parseContentRange(r.Header.Get(net.HeaderContentRange))
+	if err != nil {
+		errorcode.InvalidRequest.Render(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+	if total != claims.Size {
+		errorcode.InvalidRequest.Render(w, r, http.StatusBadRequest, fmt.Sprintf("the total size %d does not match the size %d of the upload session", total, claims.Size))
+		return
+	}
+	if r.ContentLength != end-start+1 {
+		errorcode.InvalidRequest.Render(w, r, http.StatusBadRequest, "the Content-Length does not match the Content-Range of the chunk")
+		return
+	}
+
+	ctx := r.Context()
+	offset, err := g.uploadOffset(ctx, claims.endpoint)
+	if err != nil {
+		renderError(w, r, err)
+		return
+	}
+	switch {
+	case start > offset:
+		errorcode.InvalidRange.Render(w, r, http.StatusRequestedRangeNotSatisfiable, fmt.Sprintf("the next expected range starts at byte %d", offset))
+		return
+	case end >= offset:
+		if _, err := io.CopyN(io.Discard, r.Body, offset-start); err != nil {
+			errorcode.InvalidRequest.Render(w, r, http.StatusBadRequest, err.Error())
+			return
+		}
+		if offset, err = g.uploadBytes(ctx, claims.endpoint, offset, end+1-offset, r.Body); err != nil {
+			renderError(w, r, err)
+			return
+		}
+	}
+
+	if offset < claims.Size {
+		render.Status(r, http.StatusAccepted)
+		render.JSON(w, r, claims.progress(offset))
+		return
+	}
+	id := resourceid.OwnCloudResourceIDUnwrap(claims.Item)
+	item, err := g.statDriveItem(ctx, &storageprovider.Reference{ResourceId: id, Path: claims.Path})
+	if err != nil {
+		renderError(w, r, err)
+		return
+	}
+	render.Status(r, http.StatusCreated)
+	render.JSON(w, r, item)
+}
+
+// uploadSessionClaims returns the verified state of the upload session addressed by the sessionID url parameter.
+// Upload sessions can only be used by the user who created them.
+func (g Graph) uploadSessionClaims(r *http.Request) (*uploadSessionClaims, error) {
+	claims := &uploadSessionClaims{}
+	_, err := jwt.ParseWithClaims(chi.URLParam(r, "sessionID"), claims, func(token *jwt.Token) (interface{}, error) {
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+		}
+		return g.uploadSessionKeys.sign, nil
+	})
+	notFound := errorcode.New(errorcode.ItemNotFound, "the upload session does not exist or has expired")
+	u, ok := revactx.ContextGetUser(r.Context())
+	if err != nil || !ok || claims.Subject != u.GetId().GetOpaqueId() || !claims.VerifyAudience(uploadSessionAudience, true) {
+		return nil, notFound
+	}
+	if claims.endpoint, err = g.uploadSessionKeys.open(claims.Transfer, claims.Subject); err != nil {
+		return nil, notFound
+	}
+	return claims, nil
+}
+
+// progress returns the state of the upload session after offset bytes have been received.
+func (c *uploadSessionClaims) progress(offset int64) uploadSession {
+	s := uploadSession{
+		ExpirationDateTime: c.ExpiresAt.Time,
+		NextExpectedRanges: []string{},
+	}
+	if offset < c.Size {
+		s.NextExpectedRanges = append(s.NextExpectedRanges, fmt.Sprintf("%d-", offset))
+	}
+	return s
+}
+
+// uploadOffset returns the number of bytes of an upload received by the data gateway.
+func (g Graph) uploadOffset(ctx context.Context, endpoint string) (int64, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, endpoint, nil)
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set(net.HeaderTusResumable, tusVersion)
+	res, err := g.GetHTTPClient().Do(req)
+	if err != nil {
+		return 0, errorcode.New(errorcode.ServiceNotAvailable, err.Error())
+	}
+	defer res.Body.Close()
+	switch res.StatusCode {
+	case http.StatusOK, http.StatusNoContent:
+	case http.StatusNotFound, http.StatusGone:
+		return 0, errorcode.New(errorcode.ItemNotFound, "the upload session does not exist or has expired")
+	default:
+		return 0, errorcode.New(errorcode.GeneralException, fmt.Sprintf("reading the upload offset failed with status %d", res.StatusCode))
+	}
+	offset, err := strconv.ParseInt(res.Header.Get(net.HeaderUploadOffset), 10, 64)
+	if err != nil {
+		return 0, errorcode.New(errorcode.GeneralException, "invalid upload offset")
+	}
+	return offset, nil
+}
+
+// uploadBytes sends length bytes of the body to the data gateway and returns the new offset of the upload.
+func (g Graph) uploadBytes(ctx context.Context, endpoint string, offset, length int64, body io.Reader) (int64, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPatch, endpoint, body)
+	if err != nil {
+		return 0, err
+	}
+	req.ContentLength = length
+	req.Header.Set("Content-Type", "application/offset+octet-stream")
+	req.Header.Set(net.HeaderTusResumable, tusVersion)
+	req.Header.Set(net.HeaderUploadOffset, strconv.FormatInt(offset, 10))
+	res, err := g.GetHTTPClient().Do(req)
+	if err != nil {
+		return 0, errorcode.New(errorcode.ServiceNotAvailable, err.Error())
+	}
+	defer res.Body.Close()
+	switch res.StatusCode {
+	case http.StatusOK, http.StatusNoContent:
+	case http.StatusNotFound, http.StatusGone:
+		return 0, errorcode.New(errorcode.ItemNotFound, "the upload session does not exist or has expired")
+	case http.StatusInsufficientStorage:
+		return 0, errorcode.New(errorcode.QuotaLimitReached, "insufficient storage")
+	default:
+		return 0, errorcode.New(errorcode.GeneralException, fmt.Sprintf("uploading the chunk failed with status %d", res.StatusCode))
+	}
+	next, err := strconv.ParseInt(res.Header.Get(net.HeaderUploadOffset), 10, 64)
+	if err != nil {
+		return 0, errorcode.New(errorcode.GeneralException, "invalid upload offset")
+	}
+	return next, nil
+}
+
+// availableRef returns ref, or if an item already exists at ref the reference of the first free name made by adding
+// a counter to the name.
+func (g Graph) availableRef(ctx context.Context, ref *storageprovider.Reference) (*storageprovider.Reference, error) {
+	if ref.Path == "" {
+		return nil, errorcode.New(errorcode.InvalidRequest, "the conflict behavior rename requires the name of the item")
+	}
+	ext := path.Ext(ref.Path)
+	base := strings.TrimSuffix(ref.Path, ext)
+	candidate := ref
+	for i := 1; i <= maxRenameAttempts; i++ {
+		res, err := g.GetGatewayClient().Stat(ctx, &storageprovider.StatRequest{Ref: candidate})
+		switch {
+		case err != nil:
+			return nil, errorcode.New(errorcode.ServiceNotAvailable, err.Error())
+		case res.Status.Code == cs3rpc.Code_CODE_NOT_FOUND:
+			return candidate, nil
+		case res.Status.Code != cs3rpc.Code_CODE_OK:
+			return nil, cs3StatusError(res.Status)
+		}
+		candidate = &storageprovider.Reference{
+			ResourceId: ref.ResourceId,
+			Path:       fmt.Sprintf("%s (%d)%s", base, i, ext),
+		}
+	}
+	return nil, errorcode.New(errorcode.NameAlreadyExists, "could not find a free name for the item")
+}
+
+// parseContentRange parses a Content-Range header of the form "bytes 0-1023/4096".
+func parseContentRange(v string) (start, end, total int64, err error) {
+	invalid := fmt.Errorf("invalid Content-Range '%s'", v)
+	if !strings.HasPrefix(v, "bytes ") {
+		return 0, 0, 0, invalid
+	}
+	parts := strings.SplitN(strings.TrimPrefix(v, "bytes "), "/", 2)
+	if len(parts) != 2 {
+		return 0, 0, 0, invalid
+	}
+	bounds := strings.SplitN(parts[0], "-", 2)
+	if len(bounds) != 2 {
+		return 0, 0, 0, invalid
+	}
+	if start, err = strconv.ParseInt(bounds[0], 10, 64); err != nil {
+		return 0, 0, 0, invalid
+	}
+	if end, err = strconv.ParseInt(bounds[1], 10, 64); err != nil {
+		return 0, 0, 0, invalid
+	}
+	if total, err = strconv.ParseInt(parts[1], 10, 64); err != nil {
+		return 0, 0, 0, invalid
+	}
+	if start < 0 || end < start || end >= total {
+		return 0, 0, 0, invalid
+	}
+	return start, end, total, nil
+}
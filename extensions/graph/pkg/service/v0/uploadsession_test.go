@@ -0,0 +1,212 @@
+package svc_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path"
+	"strings"
+
+	gateway "github.com/cs3org/go-cs3apis/cs3/gateway/v1beta1"
+	userpb "github.com/cs3org/go-cs3apis/cs3/identity/user/v1beta1"
+	provider "github.com/cs3org/go-cs3apis/cs3/storage/provider/v1beta1"
+	revactx "github.com/cs3org/reva/v2/pkg/ctx"
+	"github.com/cs3org/reva/v2/pkg/rgrpc/status"
+	"github.com/go-chi/chi/v5"
+	"github.com/golang-jwt/jwt/v4"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	libregraph "github.com/owncloud/libre-graph-api-go"
+	"github.com/owncloud/ocis/extensions/graph/mocks"
+	"github.com/owncloud/ocis/extensions/graph/pkg/config/defaults"
+	service "github.com/owncloud/ocis/extensions/graph/pkg/service/v0"
+	"github.com/stretchr/testify/mock"
+)
+
+var _ = Describe("UploadSessions", func() {
+	var (
+		svc           service.Service
+		gatewayClient *mocks.GatewayClient
+		httpClient    *mocks.HTTPClient
+		ctx           context.Context
+		offset        string
+
+		user = &userpb.User{Id: &userpb.UserId{OpaqueId: "einstein"}}
+	)
+
+	// request creates a request of the user with the route parameters set.
+	request := func(u *userpb.User, method, target string, body io.Reader, params map[string]string) *http.Request {
+		r := httptest.NewRequest(method, target, body)
+		rctx := chi.NewRouteContext()
+		for k, v := range params {
+			rctx.URLParams.Add(k, v)
+		}
+		c := context.WithValue(revactx.ContextSetUser(r.Context(), u), chi.RouteCtxKey, rctx)
+		return r.WithContext(c)
+	}
+	// createSession creates an upload session for a file of 10 bytes and returns its id.
+	createSession := func() string {
+		rr := httptest.NewRecorder()
+		body := strings.NewReader(`{"item":{"name":"file.txt","fileSize":10}}`)
+		svc.(service.Graph).CreateUploadSession(rr, request(user, http.MethodPost, "/graph/v1.0/drives/sID/root/createUploadSession", body,
+			map[string]string{"driveID": "sID"}))
+		Expect(rr.Code).To(Equal(http.StatusOK))
+
+		session := map[string]interface{}{}
+		err := json.Unmarshal(rr.Body.Bytes(), &session)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(session["nextExpectedRanges"]).To(Equal([]interface{}{"0-"}))
+		Expect(session["uploadUrl"]).To(HavePrefix("https://localhost:9200/graph/v1.0/uploadSessions/"))
+		return path.Base(session["uploadUrl"].(string))
+	}
+	chunk := func(sessionID, contentRange, data string) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		r := request(user, http.MethodPut, "/graph/v1.0/uploadSessions/"+sessionID, strings.NewReader(data),
+			map[string]string{"sessionID": sessionID})
+		r.Header.Set("Content-Range", contentRange)
+		svc.(service.Graph).UploadChunk(rr, r)
+		return rr
+	}
+
+	JustBeforeEach(func() {
+		ctx = context.Background()
+		offset = "0"
+		gatewayClient = &mocks.GatewayClient{}
+		httpClient = &mocks.HTTPClient{}
+		svc = service.NewService(
+			service.Config(defaults.DefaultConfig()),
+			service.WithGatewayClient(gatewayClient),
+			service.WithHTTPClient(httpClient),
+			service.WithIdentityBackend(&mocks.Backend{}),
+		)
+
+		gatewayClient.On("InitiateFileUpload", mock.Anything, mock.MatchedBy(func(req *provider.InitiateFileUploadRequest) bool {
+			return req.Ref.Path == "./file.txt" && string(req.Opaque.Map["Upload-Length"].Value) == "10"
+		})).Return(&gateway.InitiateFileUploadResponse{
+			Status:    status.NewOK(ctx),
+			Protocols: []*gateway.FileUploadProtocol{{Protocol: "tus", UploadEndpoint: "https://localhost/data/tus", Token: "ut"}},
+		}, nil)
+		httpClient.On("Do", mock.MatchedBy(func(req *http.Request) bool {
+			return req.Method == http.MethodHead && req.URL.String() == "https://localhost/data/tus/ut"
+		})).Return(func(*http.Request) *http.Response {
+			return &http.Response{
+				StatusCode: http.StatusOK,
+				Header:     http.Header{"Upload-Offset": {offset}},
+				Body:       io.NopCloser(&bytes.Buffer{}),
+			}
+		}, nil)
+		httpClient.On("Do", mock.MatchedBy(func(req *http.Request) bool {
+			return req.Method == http.MethodPatch && req.URL.String() == "https://localhost/data/tus/ut"
+		})).Return(func(req *http.Request) *http.Response {
+			b, _ := io.ReadAll(req.Body)
+			if req.Header.Get("Upload-Offset") == "0" && string(b) == "01234" {
+				offset = "5"
+			}
+			if req.Header.Get("Upload-Offset") == "5" && string(b) == "56789" {
+				offset = "10"
+			}
+			return &http.Response{
+				StatusCode: http.StatusNoContent,
+				Header:     http.Header{"Upload-Offset": {offset}},
+				Body:       io.NopCloser(&bytes.Buffer{}),
+			}
+		}, nil)
+		gatewayClient.On("Stat", mock.Anything, mock.Anything).Return(&provider.StatResponse{
+			Status: status.NewOK(ctx),
+			Info: &provider.ResourceInfo{
+				Id:   &provider.ResourceId{StorageId: "sID", OpaqueId: "fileID"},
+				Type: provider.ResourceType_RESOURCE_TYPE_FILE,
+				Path: "file.txt",
+				Size: 10,
+			},
+		}, nil)
+	})
+
+	It("requires the size of the file", func() {
+		rr := httptest.NewRecorder()
+		body := strings.NewReader(`{"item":{"name":"file.txt"}}`)
+		svc.(service.Graph).CreateUploadSession(rr, request(user, http.MethodPost, "/graph/v1.0/drives/sID/root/createUploadSession", body,
+			map[string]string{"driveID": "sID"}))
+		Expect(rr.Code).To(Equal(http.StatusBadRequest))
+	})
+
+	It("uploads a file in chunks", func() {
+		sessionID := createSession()
+
+		rr := chunk(sessionID, "bytes 0-4/10", "01234")
+		Expect(rr.Code).To(Equal(http.StatusAccepted))
+		session := map[string]interface{}{}
+		err := json.Unmarshal(rr.Body.Bytes(), &session)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(session["nextExpectedRanges"]).To(Equal([]interface{}{"5-"}))
+
+		rr = chunk(sessionID, "bytes 5-9/10", "56789")
+		Expect(rr.Code).To(Equal(http.StatusCreated))
+		item := libregraph.DriveItem{}
+		err = json.Unmarshal(rr.Body.Bytes(), &item)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(item.GetId()).To(Equal("sID!fileID"))
+	})
+
+	It("resumes interrupted uploads", func() {
+		sessionID := createSession()
+		offset = "5"
+
+		rr := httptest.NewRecorder()
+		svc.(service.Graph).GetUploadSession(rr, request(user, http.MethodGet, "/graph/v1.0/uploadSessions/"+sessionID, nil,
+			map[string]string{"sessionID": sessionID}))
+		Expect(rr.Code).To(Equal(http.StatusOK))
+		Expect(rr.Body.String()).To(ContainSubstring(`"nextExpectedRanges":["5-"]`))
+
+		rr = chunk(sessionID, "bytes 8-9/10", "89")
+		Expect(rr.Code).To(Equal(http.StatusRequestedRangeNotSatisfiable))
+
+		// the first bytes of a chunk which was sent again are skipped
+		rr = chunk(sessionID, "bytes 3-9/10", "3456789")
+		Expect(rr.Code).To(Equal(http.StatusCreated))
+	})
+
+	It("rejects invalid chunks", func() {
+		sessionID := createSession()
+
+		Expect(chunk(sessionID, "bytes 0-4/20", "01234").Code).To(Equal(http.StatusBadRequest))
+		Expect(chunk(sessionID, "bytes 0-5/10", "01234").Code).To(Equal(http.StatusBadRequest))
+		Expect(chunk(sessionID, "bytes 5-4/10", "").Code).To(Equal(http.StatusBadRequest))
+	})
+
+	It("does not share sessions", func() {
+		sessionID := createSession()
+
+		rr := httptest.NewRecorder()
+		marie := &userpb.User{Id: &userpb.UserId{OpaqueId: "marie"}}
+		svc.(service.Graph).GetUploadSession(rr, request(marie, http.MethodGet, "/graph/v1.0/uploadSessions/"+sessionID, nil,
+			map[string]string{"sessionID": sessionID}))
+		Expect(rr.Code).To(Equal(http.StatusNotFound))
+
+		rr = chunk("invalid", "bytes 0-4/10", "01234")
+		Expect(rr.Code).To(Equal(http.StatusNotFound))
+	})
+
+	It("does not expose the transfer token", func() {
+		sessionID := createSession()
+
+		parts := strings.Split(sessionID, ".")
+		Expect(len(parts)).To(Equal(3))
+		payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+		Expect(err).ToNot(HaveOccurred())
+		claims := map[string]interface{}{}
+		Expect(json.Unmarshal(payload, &claims)).To(Succeed())
+		Expect(claims["aud"]).To(Equal([]interface{}{"ocis-graph-upload-session"}))
+		Expect(string(payload)).ToNot(ContainSubstring("data/tus"))
+
+		// tokens signed with the jwt secret are no upload sessions
+		forged, err := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims(claims)).SignedString([]byte(defaults.DefaultConfig().TokenManager.JWTSecret))
+		Expect(err).ToNot(HaveOccurred())
+		rr := chunk(forged, "bytes 0-4/10", "01234")
+		Expect(rr.Code).To(Equal(http.StatusNotFound))
+	})
+})
@@ -1,8 +1,8 @@
 package svc
 
 import (
+	"context"
 	"encoding/json"
-	"errors"
 	"fmt"
 	"net/http"
 	"net/url"
@@ -18,6 +18,8 @@ import (
 	libregraph "github.com/owncloud/libre-graph-api-go"
 	"github.com/owncloud/ocis/extensions/graph/pkg/identity"
 	"github.com/owncloud/ocis/extensions/graph/pkg/service/v0/errorcode"
+	"github.com/owncloud/ocis/extensions/graph/pkg/service/v0/paging"
+	"github.com/owncloud/ocis/extensions/graph/pkg/service/v0/projection"
 	settingssvc "github.com/owncloud/ocis/extensions/settings/pkg/service/v0"
 	settings "github.com/owncloud/ocis/protogen/gen/ocis/services/settings/v0"
 )
@@ -36,42 +38,120 @@ func (g Graph) GetMe(w http.ResponseWriter, r *http.Request) {
 
 	me := identity.CreateUserModelFromCS3(u)
 
+	p, err := parseProjection(r, "memberOf")
+	if err != nil {
+		renderError(w, r, err)
+		return
+	}
+	res, err := g.projectUser(r.Context(), p, me)
+	if err != nil {
+		renderError(w, r, err)
+		return
+	}
+
 	render.Status(r, http.StatusOK)
-	render.JSON(w, r, me)
+	render.JSON(w, r, res)
 }
 
 // GetUsers implements the Service interface.
 func (g Graph) GetUsers(w http.ResponseWriter, r *http.Request) {
 	sanitizedPath := strings.TrimPrefix(r.URL.Path, "/graph/v1.0/")
-	odataReq, err := godata.ParseRequest(r.Context(), sanitizedPath, r.URL.Query())
+	odataReq, err := godata.ParseRequest(r.Context(), sanitizedPath, paging.WithoutSkipToken(r.URL.Query()))
 	if err != nil {
 		g.logger.Err(err).Interface("query", r.URL.Query()).Msg("query error")
 		errorcode.InvalidRequest.Render(w, r, http.StatusBadRequest, err.Error())
 		return
 	}
-	users, err := g.identityBackend.GetUsers(r.Context(), r.URL.Query())
+	page, err := g.parsePage(r)
 	if err != nil {
-		var errcode errorcode.Error
-		if errors.As(err, &errcode) {
-			errcode.Render(w, r)
-		} else {
-			errorcode.GeneralException.Render(w, r, http.StatusInternalServerError, err.Error())
-		}
+		errorcode.InvalidRequest.Render(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+	p, err := parseProjection(r, "memberOf")
+	if err != nil {
+		renderError(w, r, err)
 		return
 	}
 
-	users, err = sortUsers(odataReq, users)
+	var users []*libregraph.User
+	var info paging.Info
+	if odataReq.Query.OrderBy == nil {
+		users, info, err = g.identityBackend.GetUsers(r.Context(), page.Apply(r.URL.Query()))
+	} else {
+		// the backends can't sort, so sorted collections are paged after reading all users
+		users, _, err = g.identityBackend.GetUsers(r.Context(), paging.Strip(r.URL.Query()))
+	}
 	if err != nil {
-		var errcode errorcode.Error
-		if errors.As(err, &errcode) {
-			errcode.Render(w, r)
-		} else {
-			errorcode.GeneralException.Render(w, r, http.StatusInternalServerError, err.Error())
+		renderError(w, r, err)
+		return
+	}
+
+	if odataReq.Query.OrderBy != nil {
+		users, err = sortUsers(odataReq, users)
+		if err != nil {
+			renderError(w, r, err)
+			return
+		}
+		var start, end int
+		if start, end, info, err = paging.Slice(len(users), page); err != nil {
+			errorcode.InvalidRequest.Render(w, r, http.StatusBadRequest, err.Error())
+			return
 		}
+		users = users[start:end]
+	}
+
+	values, err := g.projectUsers(r.Context(), p, users)
+	if err != nil {
+		renderError(w, r, err)
 		return
 	}
-	render.Status(r, http.StatusOK)
-	render.JSON(w, r, &listResponse{Value: users})
+	g.renderPage(w, r, values, info)
+}
+
+// projectUsers projects a page of users like projectUser, the groups of all users are read at once.
+func (g Graph) projectUsers(ctx context.Context, p projection.Projection, users []*libregraph.User) ([]interface{}, error) {
+	var groups map[string][]*libregraph.Group
+	if p.Expands("memberOf") && len(users) > 0 {
+		ids := make([]string, 0, len(users))
+		for _, u := range users {
+			ids = append(ids, u.GetId())
+		}
+		var err error
+		if groups, err = g.identityBackend.GetUsersGroups(ctx, ids); err != nil {
+			return nil, err
+		}
+	}
+
+	values := make([]interface{}, 0, len(users))
+	for _, u := range users {
+		expanded := map[string]interface{}{}
+		if groups != nil {
+			memberOf := groups[u.GetId()]
+			if memberOf == nil {
+				memberOf = []*libregraph.Group{}
+			}
+			expanded["memberOf"] = memberOf
+		}
+		v, err := p.Apply(u, expanded)
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, v)
+	}
+	return values, nil
+}
+
+// projectUser expands the memberOf property of the user if requested and selects the requested properties.
+func (g Graph) projectUser(ctx context.Context, p projection.Projection, u *libregraph.User) (interface{}, error) {
+	expanded := map[string]interface{}{}
+	if p.Expands("memberOf") {
+		groups, err := g.identityBackend.GetUserGroups(ctx, u.GetId())
+		if err != nil {
+			return nil, err
+		}
+		expanded["memberOf"] = groups
+	}
+	return p.Apply(u, expanded)
 }
 
 func (g Graph) PostUser(w http.ResponseWriter, r *http.Request) {
@@ -117,7 +197,7 @@ func (g Graph) PostUser(w http.ResponseWriter, r *http.Request) {
 	}
 
 	if u, err = g.identityBackend.CreateUser(r.Context(), *u); err != nil {
-		errorcode.GeneralException.Render(w, r, http.StatusInternalServerError, err.Error())
+		renderError(w, r, err)
 		return
 	}
 
@@ -154,19 +234,26 @@ func (g Graph) GetUser(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	p, err := parseProjection(r, "memberOf")
+	if err != nil {
+		renderError(w, r, err)
+		return
+	}
+
 	user, err := g.identityBackend.GetUser(r.Context(), userID)
+	if err != nil {
+		renderError(w, r, err)
+		return
+	}
 
+	res, err := g.projectUser(r.Context(), p, user)
 	if err != nil {
-		var errcode errorcode.Error
-		if errors.As(err, &errcode) {
-			errcode.Render(w, r)
-		} else {
-			errorcode.GeneralException.Render(w, r, http.StatusInternalServerError, err.Error())
-		}
+		renderError(w, r, err)
+		return
 	}
 
 	render.Status(r, http.StatusOK)
-	render.JSON(w, r, user)
+	render.JSON(w, r, res)
 }
 
 func (g Graph) DeleteUser(w http.ResponseWriter, r *http.Request) {
@@ -184,12 +271,7 @@ func (g Graph) DeleteUser(w http.ResponseWriter, r *http.Request) {
 	err = g.identityBackend.DeleteUser(r.Context(), userID)
 
 	if err != nil {
-		var errcode errorcode.Error
-		if errors.As(err, &errcode) {
-			errcode.Render(w, r)
-		} else {
-			errorcode.GeneralException.Render(w, r, http.StatusInternalServerError, err.Error())
-		}
+		renderError(w, r, err)
 	}
 
 	g.publishEvent(events.UserDeleted{UserID: userID})
@@ -234,12 +316,7 @@ func (g Graph) PatchUser(w http.ResponseWriter, r *http.Request) {
 
 	u, err := g.identityBackend.UpdateUser(r.Context(), nameOrID, *changes)
 	if err != nil {
-		var errcode errorcode.Error
-		if errors.As(err, &errcode) {
-			errcode.Render(w, r)
-		} else {
-			errorcode.GeneralException.Render(w, r, http.StatusInternalServerError, err.Error())
-		}
+		renderError(w, r, err)
 	}
 
 	g.publishEvent(
@@ -258,6 +335,33 @@ func (g Graph) PatchUser(w http.ResponseWriter, r *http.Request) {
 // names should not start with numbers
 var usernameRegex = regexp.MustCompile("^[a-zA-Z_][a-zA-Z0-9.!#$%&'*+/=?^_`{|}~-]*(@[a-zA-Z0-9](?:[a-zA-Z0-9-]{0,61}[a-zA-Z0-9])?(?:\\.[a-zA-Z0-9](?:[a-zA-Z0-9-]{0,61}[a-zA-Z0-9])?)*)*$")
 
+// GetUserTransitiveMemberOf returns the groups a user is a direct or nested member of.
+func (g Graph) GetUserTransitiveMemberOf(w http.ResponseWriter, r *http.Request) {
+	userID, err := pathParam(r, "userID", "user")
+	if err != nil {
+		renderError(w, r, err)
+		return
+	}
+	page, err := g.parsePage(r)
+	if err != nil {
+		errorcode.InvalidRequest.Render(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+	g.logger.Info().Str("user", userID).Msg("Calling GetUserTransitiveMemberOf")
+
+	groups, err := g.identityBackend.GetUserTransitiveGroups(r.Context(), userID)
+	if err != nil {
+		renderError(w, r, err)
+		return
+	}
+	start, end, info, err := paging.Slice(len(groups), page)
+	if err != nil {
+		errorcode.InvalidRequest.Render(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+	g.renderPage(w, r, groups[start:end], info)
+}
+
 func isValidUsername(e string) bool {
 	if len(e) < 1 && len(e) > 254 {
 		return false
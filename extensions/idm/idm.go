@@ -9,3 +9,6 @@ var BaseLDIF string
 
 //go:embed ldif/demousers.ldif
 var DemoUsersLDIF string
+
+//go:embed passwords/common.txt
+var CommonPasswords string
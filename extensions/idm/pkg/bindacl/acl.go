@@ -0,0 +1,104 @@
+// Package bindacl restricts the entries which can bind to the idm service depending on the network of the client.
+package bindacl
+
+import (
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/libregraph/idm/pkg/ldapdn"
+)
+
+// rule allows the entries at and below a DN to bind from a network.
+type rule struct {
+	// network is nil if the rule applies to all networks
+	network *net.IPNet
+	dn      string
+}
+
+// ACL is a list of rules. A bind is allowed if any of the rules allows it.
+type ACL struct {
+	rules []rule
+}
+
+// Parse returns the ACL of rules of the form "<network> <dn>". The network is an IP address, a CIDR or "*" for all
+// networks.
+func Parse(rules []string) (*ACL, error) {
+	a := &ACL{}
+	for _, r := range rules {
+		r = strings.TrimSpace(r)
+		if r == "" {
+			continue
+		}
+		parts := strings.SplitN(r, " ", 2)
+		if len(parts) != 2 || strings.TrimSpace(parts[1]) == "" {
+			return nil, fmt.Errorf("invalid bind ACL rule '%s', expected '<network> <dn>'", r)
+		}
+
+		var network *net.IPNet
+		switch n := parts[0]; {
+		case n == "*":
+		case strings.Contains(n, "/"):
+			_, ipnet, err := net.ParseCIDR(n)
+			if err != nil {
+				return nil, fmt.Errorf("invalid network in bind ACL rule '%s': %w", r, err)
+			}
+			network = ipnet
+		default:
+			ip := net.ParseIP(n)
+			if ip == nil {
+				return nil, fmt.Errorf("invalid network in bind ACL rule '%s'", r)
+			}
+			bits := 8 * net.IPv6len
+			if ip.To4() != nil {
+				ip, bits = ip.To4(), 8*net.IPv4len
+			}
+			network = &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)}
+		}
+
+		dn, err := ldapdn.ParseNormalize(strings.TrimSpace(parts[1]))
+		if err != nil {
+			return nil, fmt.Errorf("invalid DN in bind ACL rule '%s': %w", r, err)
+		}
+		a.rules = append(a.rules, rule{network: network, dn: dn})
+	}
+	return a, nil
+}
+
+// Allowed returns true if the entry with the DN may bind from the address. Everything is allowed by an ACL without
+// rules.
+func (a *ACL) Allowed(dn string, addr net.Addr) bool {
+	if len(a.rules) == 0 {
+		return true
+	}
+	ndn, err := ldapdn.ParseNormalize(dn)
+	if err != nil || ndn == "" {
+		return false
+	}
+	ip := addressIP(addr)
+	for _, r := range a.rules {
+		if ndn != r.dn && !strings.HasSuffix(ndn, ","+r.dn) {
+			continue
+		}
+		if r.network == nil || ip != nil && r.network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// addressIP returns the IP address of a network address or nil if it has none.
+func addressIP(addr net.Addr) net.IP {
+	switch a := addr.(type) {
+	case nil:
+		return nil
+	case *net.TCPAddr:
+		return a.IP
+	default:
+		host, _, err := net.SplitHostPort(addr.String())
+		if err != nil {
+			return nil
+		}
+		return net.ParseIP(host)
+	}
+}
@@ -0,0 +1,57 @@
+package bindacl
+
+import (
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParse(t *testing.T) {
+	for _, rules := range [][]string{
+		{"uid=reva,ou=sysusers,o=libregraph-idm"},
+		{"10.0.0.0/33 ou=users,o=libregraph-idm"},
+		{"localhost ou=users,o=libregraph-idm"},
+		{"* not-a-dn"},
+	} {
+		_, err := Parse(rules)
+		assert.Error(t, err, rules)
+	}
+}
+
+func TestAllowed(t *testing.T) {
+	local := &net.TCPAddr{IP: net.ParseIP("127.0.0.1"), Port: 40000}
+	lan := &net.TCPAddr{IP: net.ParseIP("10.1.2.3"), Port: 40000}
+	remote := &net.TCPAddr{IP: net.ParseIP("2001:db8::1"), Port: 40000}
+
+	a, err := Parse(nil)
+	require.NoError(t, err)
+	assert.True(t, a.Allowed("uid=einstein,ou=users,o=libregraph-idm", remote))
+
+	a, err = Parse([]string{
+		"127.0.0.1 ou=sysusers,o=libregraph-idm",
+		"10.0.0.0/8 uid=reva, ou=sysusers, o=libregraph-idm",
+		"* ou=users,o=libregraph-idm",
+		"",
+	})
+	require.NoError(t, err)
+
+	for _, tt := range []struct {
+		dn      string
+		addr    net.Addr
+		allowed bool
+	}{
+		{"uid=libregraph,ou=sysusers,o=libregraph-idm", local, true},
+		{"UID=libregraph,OU=sysusers,O=libregraph-idm", local, true},
+		{"uid=libregraph,ou=sysusers,o=libregraph-idm", lan, false},
+		{"uid=reva,ou=sysusers,o=libregraph-idm", lan, true},
+		{"uid=reva,ou=sysusers,o=libregraph-idm", remote, false},
+		{"uid=einstein,ou=users,o=libregraph-idm", remote, true},
+		{"uid=einstein,ou=users,o=libregraph-idm", nil, true},
+		{"uid=einstein,ou=otherusers,o=libregraph-idm", local, false},
+		{"", local, false},
+	} {
+		assert.Equal(t, tt.allowed, a.Allowed(tt.dn, tt.addr), "%s from %v", tt.dn, tt.addr)
+	}
+}
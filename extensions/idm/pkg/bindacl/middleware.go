@@ -0,0 +1,85 @@
+package bindacl
+
+import (
+	"context"
+	"net"
+
+	"github.com/go-ldap/ldap/v3"
+	"github.com/libregraph/idm/pkg/ldapserver"
+	"github.com/libregraph/idm/server/handler"
+	"github.com/owncloud/ocis/ocis-pkg/log"
+)
+
+// middleware rejects the binds not allowed by the ACL before they are passed to the next handler.
+type middleware struct {
+	next   handler.Handler
+	acl    *ACL
+	logger log.Logger
+}
+
+// Middleware returns the LDAP middleware enforcing the ACL.
+func (a *ACL) Middleware(logger log.Logger) handler.Middleware {
+	return &middleware{acl: a, logger: logger}
+}
+
+// WithHandler implements the handler.Middleware interface.
+func (m *middleware) WithHandler(next handler.Handler) handler.Handler {
+	m2 := *m
+	m2.next = next
+	return &m2
+}
+
+// WithContext implements the handler.Handler interface.
+func (m *middleware) WithContext(ctx context.Context) handler.Handler {
+	m2 := *m
+	m2.next = m.next.WithContext(ctx)
+	return &m2
+}
+
+// Reload implements the handler.Handler interface.
+func (m *middleware) Reload(ctx context.Context) error {
+	return m.next.Reload(ctx)
+}
+
+// Bind implements the ldapserver.Binder interface. A denied bind fails like one with a wrong password, without
+// checking the password.
+func (m *middleware) Bind(bindDN, bindSimplePw string, conn net.Conn) (ldapserver.LDAPResultCode, error) {
+	var addr net.Addr
+	if conn != nil {
+		addr = conn.RemoteAddr()
+	}
+	if !m.acl.Allowed(bindDN, addr) {
+		remote := ""
+		if addr != nil {
+			remote = addr.String()
+		}
+		m.logger.Info().Str("dn", bindDN).Str("remote_addr", remote).Msg("bind denied by the bind ACL")
+		return ldap.LDAPResultInvalidCredentials, nil
+	}
+	return m.next.Bind(bindDN, bindSimplePw, conn)
+}
+
+// Add implements the ldapserver.Adder interface.
+func (m *middleware) Add(boundDN string, req *ldap.AddRequest, conn net.Conn) (ldapserver.LDAPResultCode, error) {
+	return m.next.Add(boundDN, req, conn)
+}
+
+// Modify implements the ldapserver.Modifier interface.
+func (m *middleware) Modify(boundDN string, req *ldap.ModifyRequest, conn net.Conn) (ldapserver.LDAPResultCode, error) {
+	return m.next.Modify(boundDN, req, conn)
+}
+
+// Delete implements the ldapserver.Deleter interface.
+func (m *middleware) Delete(boundDN string, req *ldap.DelRequest, conn net.Conn) (ldapserver.LDAPResultCode, error) {
+	return m.next.Delete(boundDN, req, conn)
+}
+
+// Search implements the ldapserver.Searcher interface.
+func (m *middleware) Search(boundDN string, req *ldap.SearchRequest, conn net.Conn) (ldapserver.ServerSearchResult, error) {
+	return m.next.Search(boundDN, req, conn)
+}
+
+// Close implements the ldapserver.Closer interface.
+func (m *middleware) Close(boundDN string, conn net.Conn) error {
+	return m.next.Close(boundDN, conn)
+}
@@ -0,0 +1,111 @@
+package command
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+
+	"github.com/go-ldap/ldap/v3"
+	"github.com/owncloud/ocis/extensions/idm/pkg/config"
+	"github.com/owncloud/ocis/extensions/idm/pkg/config/parser"
+	"github.com/owncloud/ocis/extensions/idm/pkg/logging"
+	"github.com/urfave/cli/v2"
+)
+
+// Backup is the entrypoint for the backup command.
+func Backup(cfg *config.Config) *cli.Command {
+	var (
+		output   string
+		password string
+	)
+	return &cli.Command{
+		Name:     "backup",
+		Usage:    "write a backup of the running idm service to an LDIF file",
+		Category: "maintenance",
+		Flags: []cli.Flag{
+			&cli.StringFlag{
+				Name:        "output",
+				Aliases:     []string{"o"},
+				Usage:       "LDIF file to write, \"-\" writes to stdout",
+				Required:    true,
+				Destination: &output,
+			},
+			&cli.StringFlag{
+				Name:        "bind-password",
+				Usage:       "password of the idm service user, defaults to the configured password",
+				EnvVars:     []string{"IDM_BACKUP_BIND_PASSWORD"},
+				Destination: &password,
+			},
+		},
+		Before: func(c *cli.Context) error {
+			return parser.ParseConfig(cfg)
+		},
+		Action: func(c *cli.Context) error {
+			logger := logging.Configure(cfg.Service.Name, cfg.Log)
+			if password == "" {
+				password = cfg.ServiceUserPasswords.Idm
+			}
+
+			conn, err := dialIDM(cfg)
+			if err != nil {
+				return err
+			}
+			defer conn.Close()
+
+			if err := conn.Bind(adminDN, password); err != nil {
+				return fmt.Errorf("could not bind as '%s': %w", adminDN, err)
+			}
+			// the idm service reads all entries of a search in a single transaction of the database, so a subtree
+			// search of the base DN is a consistent snapshot with the parents before their children.
+			res, err := conn.Search(ldap.NewSearchRequest(
+				baseDN, ldap.ScopeWholeSubtree, ldap.NeverDerefAliases, 0, 0, false,
+				"(objectClass=*)", nil, nil,
+			))
+			if err != nil {
+				return err
+			}
+			if err := writeLDIF(output, res.Entries); err != nil {
+				return err
+			}
+
+			logger.Info().
+				Int("entries", len(res.Entries)).
+				Str("output", output).
+				Msg("Wrote backup of the idm database")
+			return nil
+		},
+	}
+}
+
+// dialIDM connects to the ldaps listener of the idm service. The configured certificate is trusted, so that the
+// generated self-signed certificate can be verified.
+func dialIDM(cfg *config.Config) (*ldap.Conn, error) {
+	if cfg.IDM.LDAPSAddr == "" {
+		return nil, errors.New("the ldaps listener of the idm service is disabled")
+	}
+	host, port, err := net.SplitHostPort(cfg.IDM.LDAPSAddr)
+	if err != nil {
+		return nil, err
+	}
+	if ip := net.ParseIP(host); host == "" || ip != nil && ip.IsUnspecified() {
+		// the service listens on all interfaces
+		host = "127.0.0.1"
+	}
+
+	pool, err := x509.SystemCertPool()
+	if err != nil {
+		pool = x509.NewCertPool()
+	}
+	if pem, err := os.ReadFile(cfg.IDM.Cert); err == nil {
+		pool.AppendCertsFromPEM(pem)
+	}
+
+	return ldap.DialURL("ldaps://"+net.JoinHostPort(host, port), ldap.DialWithTLSConfig(&tls.Config{
+		MinVersion: tls.VersionTLS12,
+		RootCAs:    pool,
+		ServerName: host,
+	}))
+}
@@ -0,0 +1,106 @@
+package command
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/go-ldap/ldap/v3"
+	"github.com/go-ldap/ldif"
+	"github.com/libregraph/idm/pkg/ldbbolt"
+	"github.com/owncloud/ocis/ocis-pkg/log"
+	bolt "go.etcd.io/bbolt"
+)
+
+// openDatabase opens the idm database. The idm service locks the database while it is running, opening it fails
+// after a second then.
+func openDatabase(logger log.Logger, path string, readOnly bool) (*ldbbolt.LdbBolt, error) {
+	if readOnly {
+		if _, err := os.Stat(path); err != nil {
+			return nil, err
+		}
+	}
+
+	bdb := &ldbbolt.LdbBolt{}
+	opts := &bolt.Options{ReadOnly: readOnly, Timeout: time.Second}
+	if err := bdb.Configure(log.LogrusWrap(logger.Logger), baseDN, path, opts); err != nil {
+		if errors.Is(err, bolt.ErrTimeout) {
+			return nil, fmt.Errorf("database '%s' is in use, stop the idm service first: %w", path, err)
+		}
+		return nil, err
+	}
+	if err := bdb.Initialize(); err != nil {
+		bdb.Close()
+		return nil, err
+	}
+	return bdb, nil
+}
+
+// putEntries adds the entries to the database. Parent entries must be added before their children. Existing entries
+// are skipped if skipExisting is set, otherwise they are an error. It returns the number of added entries.
+func putEntries(logger log.Logger, bdb *ldbbolt.LdbBolt, entries []*ldap.Entry, skipExisting bool) (int, error) {
+	added := 0
+	for _, entry := range entries {
+		// the database doesn't validate the DN
+		if dn, err := ldap.ParseDN(entry.DN); err != nil || len(dn.RDNs) == 0 {
+			return added, fmt.Errorf("invalid DN '%s'", entry.DN)
+		}
+
+		logger.Debug().Str("dn", entry.DN).Msg("Adding entry")
+		err := bdb.EntryPut(entry)
+		switch {
+		case err == nil:
+			added++
+		case skipExisting && errors.Is(err, ldbbolt.ErrEntryAlreadyExists):
+			logger.Debug().Str("dn", entry.DN).Msg("Skipping existing entry")
+		default:
+			return added, fmt.Errorf("error adding Entry '%s': %w", entry.DN, err)
+		}
+	}
+	return added, nil
+}
+
+// readLDIF returns the entries of an LDIF file, "-" reads from stdin.
+func readLDIF(path string) ([]*ldap.Entry, error) {
+	var r io.Reader = os.Stdin
+	if path != "-" {
+		f, err := os.Open(path)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		r = f
+	}
+
+	lf := &ldif.LDIF{}
+	if err := ldif.Unmarshal(r, lf); err != nil {
+		return nil, err
+	}
+	return lf.AllEntries(), nil
+}
+
+// writeLDIF writes the entries to an LDIF file, "-" writes to stdout. The file is replaced once all entries are
+// written, so that a failed export doesn't leave an incomplete file behind.
+func writeLDIF(path string, entries []*ldap.Entry) error {
+	if path == "-" {
+		return ldif.Dump(os.Stdout, 0, entries)
+	}
+
+	f, err := os.CreateTemp(filepath.Dir(path), ".idm-export-*.ldif")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+
+	if err := ldif.Dump(f, 0, entries); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), path)
+}
@@ -0,0 +1,54 @@
+package command
+
+import (
+	"github.com/go-ldap/ldap/v3"
+	"github.com/owncloud/ocis/extensions/idm/pkg/config"
+	"github.com/owncloud/ocis/extensions/idm/pkg/config/parser"
+	"github.com/owncloud/ocis/extensions/idm/pkg/logging"
+	"github.com/urfave/cli/v2"
+)
+
+// Export is the entrypoint for the export command.
+func Export(cfg *config.Config) *cli.Command {
+	var output string
+	return &cli.Command{
+		Name:     "export",
+		Usage:    "export the idm database to an LDIF file, the idm service must be stopped",
+		Category: "maintenance",
+		Flags: []cli.Flag{
+			&cli.StringFlag{
+				Name:        "output",
+				Aliases:     []string{"o"},
+				Value:       "-",
+				Usage:       "LDIF file to write, \"-\" writes to stdout",
+				Destination: &output,
+			},
+		},
+		Before: func(c *cli.Context) error {
+			return parser.ParseConfig(cfg)
+		},
+		Action: func(c *cli.Context) error {
+			logger := logging.Configure(cfg.Service.Name, cfg.Log)
+
+			bdb, err := openDatabase(logger, cfg.IDM.DatabasePath, true)
+			if err != nil {
+				return err
+			}
+			defer bdb.Close()
+
+			entries, err := bdb.Search(baseDN, ldap.ScopeWholeSubtree)
+			if err != nil {
+				return err
+			}
+			if err := writeLDIF(output, entries); err != nil {
+				return err
+			}
+
+			logger.Info().
+				Int("entries", len(entries)).
+				Str("output", output).
+				Msg("Exported idm database")
+			return nil
+		},
+	}
+}
@@ -0,0 +1,61 @@
+package command
+
+import (
+	"github.com/owncloud/ocis/extensions/idm/pkg/config"
+	"github.com/owncloud/ocis/extensions/idm/pkg/config/parser"
+	"github.com/owncloud/ocis/extensions/idm/pkg/logging"
+	"github.com/urfave/cli/v2"
+)
+
+// Import is the entrypoint for the import command.
+func Import(cfg *config.Config) *cli.Command {
+	var (
+		input        string
+		skipExisting bool
+	)
+	return &cli.Command{
+		Name:     "import",
+		Usage:    "import the entries of an LDIF file into the idm database, the idm service must be stopped",
+		Category: "maintenance",
+		Flags: []cli.Flag{
+			&cli.StringFlag{
+				Name:        "input",
+				Aliases:     []string{"i"},
+				Usage:       "LDIF file to read, \"-\" reads from stdin",
+				Required:    true,
+				Destination: &input,
+			},
+			&cli.BoolFlag{
+				Name:        "skip-existing",
+				Usage:       "skip entries which already exist instead of failing",
+				Destination: &skipExisting,
+			},
+		},
+		Before: func(c *cli.Context) error {
+			return parser.ParseConfig(cfg)
+		},
+		Action: func(c *cli.Context) error {
+			logger := logging.Configure(cfg.Service.Name, cfg.Log)
+
+			entries, err := readLDIF(input)
+			if err != nil {
+				return err
+			}
+
+			// a missing database is created, restoring an export doesn't need a bootstrapped database
+			bdb, err := openDatabase(logger, cfg.IDM.DatabasePath, false)
+			if err != nil {
+				return err
+			}
+			defer bdb.Close()
+
+			added, err := putEntries(logger, bdb, entries, skipExisting)
+			logger.Info().
+				Int("added", added).
+				Int("entries", len(entries)).
+				Str("input", input).
+				Msg("Imported LDIF file")
+			return err
+		},
+	}
+}
@@ -18,6 +18,9 @@ func GetCommands(cfg *config.Config) cli.Commands {
 		Server(cfg),
 
 		// interaction with this service
+		Export(cfg),
+		Import(cfg),
+		Backup(cfg),
 
 		// infos about this service
 		Health(cfg),
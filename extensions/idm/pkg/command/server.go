@@ -2,26 +2,38 @@ package command
 
 import (
 	"context"
+	"crypto/tls"
 	"encoding/base64"
 	"errors"
 	"fmt"
 	"html/template"
+	"net"
 	"os"
 	"strings"
 
 	"github.com/go-ldap/ldif"
 	"github.com/libregraph/idm/pkg/ldappassword"
-	"github.com/libregraph/idm/pkg/ldbbolt"
 	"github.com/libregraph/idm/server"
 	"github.com/owncloud/ocis/extensions/idm"
+	"github.com/owncloud/ocis/extensions/idm/pkg/bindacl"
 	"github.com/owncloud/ocis/extensions/idm/pkg/config"
 	"github.com/owncloud/ocis/extensions/idm/pkg/config/parser"
 	"github.com/owncloud/ocis/extensions/idm/pkg/logging"
+	"github.com/owncloud/ocis/extensions/idm/pkg/passwordpolicy"
+	"github.com/owncloud/ocis/extensions/idm/pkg/replication"
+	"github.com/owncloud/ocis/extensions/idm/pkg/starttls"
 	pkgcrypto "github.com/owncloud/ocis/ocis-pkg/crypto"
 	"github.com/owncloud/ocis/ocis-pkg/log"
 	"github.com/urfave/cli/v2"
 )
 
+const (
+	// baseDN is the DN of the root entry of the idm database
+	baseDN = "o=libregraph-idm"
+	// adminDN is the DN of the user allowed to write to the idm database
+	adminDN = "uid=libregraph,ou=sysusers," + baseDN
+)
+
 // Server is the entrypoint for the server command.
 func Server(cfg *config.Config) *cli.Command {
 	return &cli.Command{
@@ -53,18 +65,32 @@ func start(ctx context.Context, logger log.Logger, cfg *config.Config) error {
 		LDAPSListenAddr: cfg.IDM.LDAPSAddr,
 		TLSCertFile:     cfg.IDM.Cert,
 		TLSKeyFile:      cfg.IDM.Key,
-		LDAPBaseDN:      "o=libregraph-idm",
-		LDAPAdminDN:     "uid=libregraph,ou=sysusers,o=libregraph-idm",
+		LDAPBaseDN:      baseDN,
+		LDAPAdminDN:     adminDN,
 
 		BoltDBFile: cfg.IDM.DatabasePath,
 	}
 
-	if cfg.IDM.LDAPSAddr != "" {
+	if cfg.IDM.LDAPSAddr != "" || cfg.IDM.LDAPAddr != "" {
 		// Generate a self-signing cert if no certificate is present
 		if err := pkgcrypto.GenCert(cfg.IDM.Cert, cfg.IDM.Key, logger); err != nil {
 			logger.Fatal().Err(err).Msgf("Could not generate test-certificate")
 		}
 	}
+	if cfg.IDM.LDAPAddr != "" {
+		// The plain listener is served by us instead of the idm server, which has no StartTLS support
+		ln, err := listenStartTLS(logger, cfg)
+		if err != nil {
+			return err
+		}
+		defer ln.Close()
+		servercfg.OnReady = func(s *server.Server) {
+			logger.Info().Str("addr", cfg.IDM.LDAPAddr).Msg("Starting ldap listener, StartTLS is required")
+			if err := s.LDAPServer.Serve(ln); err != nil {
+				logger.Error().Err(err).Msg("ldap listener failed")
+			}
+		}
+	}
 	if _, err := os.Stat(servercfg.BoltDBFile); errors.Is(err, os.ErrNotExist) {
 		logger.Debug().Msg("Bootstrapping IDM database")
 		if err = bootstrap(logger, cfg, servercfg); err != nil {
@@ -76,13 +102,55 @@ func start(ctx context.Context, logger log.Logger, cfg *config.Config) error {
 	if err != nil {
 		return err
 	}
+
+	policy, err := passwordpolicy.New(cfg.PasswordPolicy)
+	if err != nil {
+		return err
+	}
+	database := svc.LDAPHandler
+	svc.LDAPHandler = policy.Middleware(logger, adminDN).WithHandler(svc.LDAPHandler)
+
+	if cfg.Replication.PrimaryURI != "" {
+		password := cfg.Replication.BindPassword
+		if password == "" {
+			password = cfg.ServiceUserPasswords.Idm
+		}
+		follower, err := replication.New(cfg.Replication, baseDN, adminDN, password, logger)
+		if err != nil {
+			return err
+		}
+		logger.Info().Str("primary", cfg.Replication.PrimaryURI).Msg("Starting as a read-only replica")
+		svc.LDAPHandler = follower.Middleware().WithHandler(svc.LDAPHandler)
+		go follower.Run(ctx, database.WithContext(ctx))
+	}
+
+	acl, err := bindacl.Parse(cfg.IDM.BindACL)
+	if err != nil {
+		return err
+	}
+	svc.LDAPHandler = acl.Middleware(logger).WithHandler(svc.LDAPHandler)
+
 	return svc.Serve(ctx)
 }
 
-func bootstrap(logger log.Logger, cfg *config.Config, srvcfg server.Config) error {
-	// Hash password if the config does not supply a hash already
-	var err error
+// listenStartTLS returns the plain listener which only serves clients negotiating TLS with StartTLS.
+func listenStartTLS(logger log.Logger, cfg *config.Config) (net.Listener, error) {
+	cert, err := tls.LoadX509KeyPair(cfg.IDM.Cert, cfg.IDM.Key)
+	if err != nil {
+		return nil, err
+	}
+	ln, err := net.Listen("tcp", cfg.IDM.LDAPAddr)
+	if err != nil {
+		return nil, err
+	}
+	tlsConfig := &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		MinVersion:   tls.VersionTLS12,
+	}
+	return starttls.NewListener(ln, tlsConfig, logger), nil
+}
 
+func bootstrap(logger log.Logger, cfg *config.Config, srvcfg server.Config) error {
 	type svcUser struct {
 		Name     string
 		Password string
@@ -107,17 +175,12 @@ func bootstrap(logger log.Logger, cfg *config.Config, srvcfg server.Config) erro
 		},
 	}
 
-	bdb := &ldbbolt.LdbBolt{}
-
-	if err := bdb.Configure(srvcfg.Logger, srvcfg.LDAPBaseDN, srvcfg.BoltDBFile, nil); err != nil {
+	bdb, err := openDatabase(logger, srvcfg.BoltDBFile, false)
+	if err != nil {
 		return err
 	}
 	defer bdb.Close()
 
-	if err := bdb.Initialize(); err != nil {
-		return err
-	}
-
 	// Prepare the initial Data from template. To be able to set the
 	// supplied service user passwords
 	tmpl, err := template.New("baseldif").Parse(idm.BaseLDIF)
@@ -125,6 +188,7 @@ func bootstrap(logger log.Logger, cfg *config.Config, srvcfg server.Config) erro
 		return err
 	}
 
+	// Hash password if the config does not supply a hash already
 	for i := range serviceUsers {
 		if strings.HasPrefix(serviceUsers[i].Password, "$argon2id$") {
 			// password is alread hashed
@@ -148,20 +212,20 @@ func bootstrap(logger log.Logger, cfg *config.Config, srvcfg server.Config) erro
 	if cfg.CreateDemoUsers {
 		bootstrapData = bootstrapData + "\n" + idm.DemoUsersLDIF
 	}
+	if cfg.IDM.BootstrapLDIF != "" {
+		data, err := os.ReadFile(cfg.IDM.BootstrapLDIF)
+		if err != nil {
+			return err
+		}
+		bootstrapData = bootstrapData + "\n" + string(data)
+	}
 
-	s := strings.NewReader(bootstrapData)
 	lf := &ldif.LDIF{}
-	err = ldif.Unmarshal(s, lf)
+	err = ldif.Unmarshal(strings.NewReader(bootstrapData), lf)
 	if err != nil {
 		return err
 	}
 
-	for _, entry := range lf.AllEntries() {
-		logger.Debug().Str("dn", entry.DN).Msg("Adding entry")
-		if err := bdb.EntryPut(entry); err != nil {
-			return fmt.Errorf("error adding Entry '%s': %w", entry.DN, err)
-		}
-	}
-
-	return nil
+	_, err = putEntries(logger, bdb, lf.AllEntries(), false)
+	return err
 }
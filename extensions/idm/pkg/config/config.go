@@ -2,6 +2,7 @@ package config
 
 import (
 	"context"
+	"time"
 
 	"github.com/owncloud/ocis/ocis-pkg/shared"
 )
@@ -20,15 +21,20 @@ type Config struct {
 	CreateDemoUsers bool     `yaml:"create_demo_users" env:"IDM_CREATE_DEMO_USERS;ACCOUNTS_DEMO_USERS_AND_GROUPS" desc:"Flag to enabe/disable the creation of the demo users"`
 
 	ServiceUserPasswords ServiceUserPasswords `yaml:"service_user_passwords"`
+	PasswordPolicy       PasswordPolicy       `yaml:"password_policy"`
+	Replication          Replication          `yaml:"replication"`
 
 	Context context.Context `yaml:"-"`
 }
 
 type Settings struct {
-	LDAPSAddr    string `yaml:"ldaps_addr" env:"IDM_LDAPS_ADDR" desc:"Listen address for the ldaps listener (ip-addr:port)"`
-	Cert         string `yaml:"cert" env:"IDM_LDAPS_CERT" desc:"File name of the TLS server certificate for the ldaps listener"`
-	Key          string `yaml:"key" env:"IDM_LDAPS_KEY" desc:"File name for the TLS certificate key for the server certificate"`
-	DatabasePath string `yaml:"database" env:"IDM_DATABASE_PATH" desc:"Full path to the idm backend database"`
+	LDAPSAddr     string   `yaml:"ldaps_addr" env:"IDM_LDAPS_ADDR" desc:"Listen address for the ldaps listener (ip-addr:port)"`
+	LDAPAddr      string   `yaml:"ldap_addr" env:"IDM_LDAP_ADDR" desc:"Listen address for the ldap listener (ip-addr:port), disabled if empty. Clients have to negotiate TLS with StartTLS using the certificate of the ldaps listener before any other request"`
+	Cert          string   `yaml:"cert" env:"IDM_LDAPS_CERT" desc:"File name of the TLS server certificate for the ldaps listener and StartTLS on the ldap listener"`
+	Key           string   `yaml:"key" env:"IDM_LDAPS_KEY" desc:"File name for the TLS certificate key for the server certificate"`
+	DatabasePath  string   `yaml:"database" env:"IDM_DATABASE_PATH" desc:"Full path to the idm backend database"`
+	BootstrapLDIF string   `yaml:"bootstrap_ldif" env:"IDM_BOOTSTRAP_LDIF" desc:"Path to an LDIF file with additional entries, e.g. users and groups, to add when the idm database is created"`
+	BindACL       []string `yaml:"bind_acl" env:"IDM_BIND_ACL" desc:"Semicolon separated rules of the form \"<network> <dn>\" allowing the entries at and below the DN to bind from the network, e.g. \"127.0.0.1/32 ou=sysusers,o=libregraph-idm\". The network can be \"*\" for all networks. All binds are allowed if empty"`
 }
 
 type ServiceUserPasswords struct {
@@ -37,3 +43,27 @@ type ServiceUserPasswords struct {
 	Reva      string `yaml:"reva_password" env:"IDM_REVASVC_PASSWORD" desc:"Password to set for the \"reva\" service user. Either cleartext or an argon2id hash"`
 	Idp       string `yaml:"idp_password" env:"IDM_IDPSVC_PASSWORD" desc:"Password to set for the \"idp\" service user. Either cleartext or an argon2id hash"`
 }
+
+// PasswordPolicy is enforced for the passwords set through LDAP, e.g. by the graph service. The passwords of the
+// service users and of bootstrapped or imported entries are not checked.
+type PasswordPolicy struct {
+	MinLength    int           `yaml:"min_length" env:"IDM_PASSWORD_MIN_LENGTH" desc:"Minimum number of characters of a password"`
+	MinLowercase int           `yaml:"min_lowercase" env:"IDM_PASSWORD_MIN_LOWERCASE" desc:"Minimum number of lowercase letters of a password"`
+	MinUppercase int           `yaml:"min_uppercase" env:"IDM_PASSWORD_MIN_UPPERCASE" desc:"Minimum number of uppercase letters of a password"`
+	MinDigits    int           `yaml:"min_digits" env:"IDM_PASSWORD_MIN_DIGITS" desc:"Minimum number of digits of a password"`
+	MinSpecial   int           `yaml:"min_special" env:"IDM_PASSWORD_MIN_SPECIAL" desc:"Minimum number of characters of a password which are neither letters nor digits"`
+	DenyCommon   bool          `yaml:"deny_common" env:"IDM_PASSWORD_DENY_COMMON" desc:"Reject the passwords of the built-in list of common passwords"`
+	DenyListFile string        `yaml:"deny_list_file" env:"IDM_PASSWORD_DENY_LIST_FILE" desc:"Path to a file with additional passwords to reject, one per line"`
+	History      int           `yaml:"history" env:"IDM_PASSWORD_HISTORY" desc:"Number of the latest passwords of a user, including the current one, which can't be reused"`
+	MaxAge       time.Duration `yaml:"max_age" env:"IDM_PASSWORD_MAX_AGE" desc:"Time after which a password expires and can't be used to log in anymore, 0 disables the expiry"`
+}
+
+// Replication configures the read-only replica mode, in which the idm service follows the entries of a primary idm
+// service instead of accepting changes itself.
+type Replication struct {
+	PrimaryURI   string        `yaml:"primary_uri" env:"IDM_REPLICATION_PRIMARY_URI" desc:"ldaps URI of the primary idm service, e.g. \"ldaps://idm.example.com:9235\". Enables the read-only replica mode if set"`
+	BindPassword string        `yaml:"bind_password" env:"IDM_REPLICATION_BIND_PASSWORD" desc:"Password of the \"idm\" service user of the primary, defaults to the password of the local \"idm\" service user"`
+	CACert       string        `yaml:"ca_cert" env:"IDM_REPLICATION_CA_CERT" desc:"File name of an additional CA certificate to trust for the primary, e.g. the certificate of its ldaps listener"`
+	Insecure     bool          `yaml:"insecure" env:"OCIS_INSECURE;IDM_REPLICATION_INSECURE" desc:"Don't verify the certificate of the primary"`
+	Interval     time.Duration `yaml:"interval" env:"IDM_REPLICATION_INTERVAL" desc:"Interval in which the entries of the primary are copied"`
+}
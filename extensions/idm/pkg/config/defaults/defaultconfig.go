@@ -2,6 +2,7 @@ package defaults
 
 import (
 	"path"
+	"time"
 
 	"github.com/owncloud/ocis/extensions/idm/pkg/config"
 	"github.com/owncloud/ocis/ocis-pkg/config/defaults"
@@ -34,6 +35,9 @@ func DefaultConfig() *config.Config {
 			Key:          path.Join(defaults.BaseDataPath(), "idm", "ldap.key"),
 			DatabasePath: path.Join(defaults.BaseDataPath(), "idm", "ocis.boltdb"),
 		},
+		Replication: config.Replication{
+			Interval: time.Minute,
+		},
 	}
 }
 
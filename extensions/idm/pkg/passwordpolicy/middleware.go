@@ -0,0 +1,239 @@
+package passwordpolicy
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/go-ldap/ldap/v3"
+	"github.com/libregraph/idm/pkg/ldappassword"
+	"github.com/libregraph/idm/pkg/ldapserver"
+	"github.com/libregraph/idm/server/handler"
+	"github.com/owncloud/ocis/ocis-pkg/log"
+)
+
+const (
+	passwordAttribute = "userPassword"
+	// changedAttribute and historyAttribute are named after the attributes of the LDAP password policy draft. The
+	// history only holds the hashes of the previous passwords.
+	changedAttribute = "pwdChangedTime"
+	historyAttribute = "pwdHistory"
+
+	generalizedTime = "20060102150405Z"
+	hashAlgorithm   = "{ARGON2}"
+
+	// expiredResultCode is the result of a bind with the correct but expired password. Binds with a wrong password
+	// fail with invalidCredentials, the server can't send the password policy response control to tell them apart.
+	expiredResultCode = ldap.LDAPResultUnwillingToPerform
+)
+
+// middleware checks the passwords of add and modify requests before they are passed to the next handler. Accepted
+// passwords are stored hashed together with the time of the change and the password history.
+type middleware struct {
+	next    handler.Handler
+	policy  *Policy
+	logger  log.Logger
+	now     func() time.Time
+	adminDN string
+}
+
+// Middleware returns the LDAP middleware enforcing the policy. Passwords which are hashed already are only accepted
+// from the adminDN.
+func (p *Policy) Middleware(logger log.Logger, adminDN string) handler.Middleware {
+	return &middleware{policy: p, logger: logger, now: time.Now, adminDN: adminDN}
+}
+
+// WithHandler implements the handler.Middleware interface.
+func (m *middleware) WithHandler(next handler.Handler) handler.Handler {
+	m2 := *m
+	m2.next = next
+	return &m2
+}
+
+// WithContext implements the handler.Handler interface.
+func (m *middleware) WithContext(ctx context.Context) handler.Handler {
+	m2 := *m
+	m2.next = m.next.WithContext(ctx)
+	return &m2
+}
+
+// Reload implements the handler.Handler interface.
+func (m *middleware) Reload(ctx context.Context) error {
+	return m.next.Reload(ctx)
+}
+
+// Add implements the ldapserver.Adder interface.
+func (m *middleware) Add(boundDN string, req *ldap.AddRequest, conn net.Conn) (ldapserver.LDAPResultCode, error) {
+	attributes := make([]ldap.Attribute, 0, len(req.Attributes)+1)
+	changed := false
+	for _, a := range req.Attributes {
+		if !strings.EqualFold(a.Type, passwordAttribute) {
+			attributes = append(attributes, a)
+			continue
+		}
+		hash, code, err := m.hashPassword(boundDN, req.DN, a.Vals, nil)
+		if err != nil {
+			return code, err
+		}
+		if hash != "" {
+			attributes = append(attributes,
+				ldap.Attribute{Type: a.Type, Vals: []string{hash}},
+				ldap.Attribute{Type: changedAttribute, Vals: []string{m.now().UTC().Format(generalizedTime)}},
+			)
+			changed = true
+		} else {
+			attributes = append(attributes, a)
+		}
+	}
+	if !changed {
+		return m.next.Add(boundDN, req, conn)
+	}
+	return m.next.Add(boundDN, &ldap.AddRequest{DN: req.DN, Attributes: attributes, Controls: req.Controls}, conn)
+}
+
+// Modify implements the ldapserver.Modifier interface.
+func (m *middleware) Modify(boundDN string, req *ldap.ModifyRequest, conn net.Conn) (ldapserver.LDAPResultCode, error) {
+	var values []string
+	setsPassword := false
+	changes := make([]ldap.Change, 0, len(req.Changes)+3)
+	for _, c := range req.Changes {
+		if strings.EqualFold(c.Modification.Type, passwordAttribute) && c.Operation != ldap.DeleteAttribute && len(c.Modification.Vals) > 0 {
+			values = append(values, c.Modification.Vals...)
+			setsPassword = true
+			continue
+		}
+		changes = append(changes, c)
+	}
+	if !setsPassword {
+		return m.next.Modify(boundDN, req, conn)
+	}
+
+	e, err := m.entry(boundDN, req.DN, conn)
+	if err != nil {
+		// let the next handler report the missing entry
+		return m.next.Modify(boundDN, req, conn)
+	}
+	current := e.GetEqualFoldAttributeValues(passwordAttribute)
+	previous := e.GetEqualFoldAttributeValues(historyAttribute)
+
+	hash, code, err := m.hashPassword(boundDN, req.DN, values, append(append([]string{}, current...), previous...))
+	if err != nil {
+		return code, err
+	}
+	if hash == "" {
+		return m.next.Modify(boundDN, req, conn)
+	}
+
+	var last string
+	if len(current) > 0 {
+		last = current[0]
+	}
+	mr := &ldap.ModifyRequest{DN: req.DN, Changes: changes, Controls: req.Controls}
+	mr.Replace(passwordAttribute, []string{hash})
+	mr.Replace(changedAttribute, []string{m.now().UTC().Format(generalizedTime)})
+	mr.Replace(historyAttribute, m.policy.History(last, previous))
+	return m.next.Modify(boundDN, mr, conn)
+}
+
+// hashPassword checks the new password and returns its hash. It returns no hash if the password is hashed already,
+// those can't be checked and are only accepted from the administrator.
+func (m *middleware) hashPassword(boundDN, dn string, values []string, previous []string) (string, ldapserver.LDAPResultCode, error) {
+	if len(values) != 1 {
+		return "", ldap.LDAPResultConstraintViolation, errors.New("exactly one password must be set")
+	}
+	password := values[0]
+	if isHashed(password) {
+		if !strings.EqualFold(boundDN, m.adminDN) {
+			m.logger.Info().Str("dn", dn).Str("binddn", boundDN).Msg("rejected hashed password")
+			return "", ldap.LDAPResultConstraintViolation, errors.New("hashed passwords can only be set by the administrator")
+		}
+		return "", ldap.LDAPResultSuccess, nil
+	}
+
+	err := m.policy.Check(password)
+	if err == nil {
+		err = m.policy.CheckReuse(password, previous)
+	}
+	if err != nil {
+		m.logger.Info().Str("dn", dn).Err(err).Msg("rejected password")
+		return "", ldap.LDAPResultConstraintViolation, err
+	}
+
+	hash, err := ldappassword.Hash(password, hashAlgorithm)
+	if err != nil {
+		return "", ldap.LDAPResultOperationsError, err
+	}
+	return hash, ldap.LDAPResultSuccess, nil
+}
+
+// Bind implements the ldapserver.Binder interface. A bind with an expired password fails with expiredResultCode, it
+// is only checked after the password was verified. Entries without the time of the last password change, e.g. the
+// service users, never expire.
+func (m *middleware) Bind(bindDN, bindSimplePw string, conn net.Conn) (ldapserver.LDAPResultCode, error) {
+	code, err := m.next.Bind(bindDN, bindSimplePw, conn)
+	if code != ldap.LDAPResultSuccess || m.policy.cfg.MaxAge <= 0 {
+		return code, err
+	}
+
+	e, err := m.entry(bindDN, bindDN, conn)
+	if err != nil {
+		return ldap.LDAPResultOperationsError, err
+	}
+	v := e.GetEqualFoldAttributeValue(changedAttribute)
+	if v == "" {
+		return code, nil
+	}
+	changed, err := time.Parse(generalizedTime, v)
+	if err != nil {
+		m.logger.Error().Str("dn", bindDN).Err(err).Msg("invalid time of the last password change")
+		return ldap.LDAPResultInvalidCredentials, nil
+	}
+	if m.policy.Expired(changed, m.now()) {
+		m.logger.Info().Str("dn", bindDN).Msg("password expired")
+		return expiredResultCode, nil
+	}
+	return code, nil
+}
+
+// Delete implements the ldapserver.Deleter interface.
+func (m *middleware) Delete(boundDN string, req *ldap.DelRequest, conn net.Conn) (ldapserver.LDAPResultCode, error) {
+	return m.next.Delete(boundDN, req, conn)
+}
+
+// Search implements the ldapserver.Searcher interface.
+func (m *middleware) Search(boundDN string, req *ldap.SearchRequest, conn net.Conn) (ldapserver.ServerSearchResult, error) {
+	return m.next.Search(boundDN, req, conn)
+}
+
+// Close implements the ldapserver.Closer interface.
+func (m *middleware) Close(boundDN string, conn net.Conn) error {
+	return m.next.Close(boundDN, conn)
+}
+
+// entry reads an entry from the next handler.
+func (m *middleware) entry(boundDN, dn string, conn net.Conn) (*ldap.Entry, error) {
+	res, err := m.next.Search(boundDN, &ldap.SearchRequest{
+		BaseDN: dn,
+		Scope:  ldap.ScopeBaseObject,
+		Filter: "(objectClass=*)",
+	}, conn)
+	if err != nil {
+		return nil, err
+	}
+	if len(res.Entries) != 1 {
+		return nil, ldap.NewError(ldap.LDAPResultNoSuchObject, errors.New("entry not found"))
+	}
+	return res.Entries[0], nil
+}
+
+// isHashed returns true if the password starts with the scheme of a supported hash algorithm.
+func isHashed(password string) bool {
+	for _, scheme := range []string{"{ARGON2}", "{CRYPT}", "{SSHA}"} {
+		if len(password) > len(scheme) && strings.EqualFold(password[:len(scheme)], scheme) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,155 @@
+package passwordpolicy
+
+import (
+	"context"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-ldap/ldap/v3"
+	"github.com/libregraph/idm/pkg/ldapentry"
+	"github.com/libregraph/idm/pkg/ldappassword"
+	"github.com/libregraph/idm/pkg/ldapserver"
+	"github.com/libregraph/idm/server/handler"
+	"github.com/owncloud/ocis/extensions/idm/pkg/config"
+	"github.com/owncloud/ocis/ocis-pkg/log"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	userDN  = "uid=einstein,ou=users,o=libregraph-idm"
+	adminDN = "uid=libregraph,ou=sysusers,o=libregraph-idm"
+)
+
+// entries is an in-memory handler.
+type entries map[string]*ldap.Entry
+
+func (h entries) Add(boundDN string, req *ldap.AddRequest, conn net.Conn) (ldapserver.LDAPResultCode, error) {
+	h[strings.ToLower(req.DN)] = ldapentry.EntryFromAddRequest(req)
+	return ldap.LDAPResultSuccess, nil
+}
+
+func (h entries) Bind(bindDN, bindSimplePw string, conn net.Conn) (ldapserver.LDAPResultCode, error) {
+	e, ok := h[strings.ToLower(bindDN)]
+	if !ok {
+		return ldap.LDAPResultInvalidCredentials, nil
+	}
+	if ok, _ := ldappassword.Validate(bindSimplePw, e.GetEqualFoldAttributeValue("userPassword")); !ok {
+		return ldap.LDAPResultInvalidCredentials, nil
+	}
+	return ldap.LDAPResultSuccess, nil
+}
+
+func (h entries) Delete(boundDN string, req *ldap.DelRequest, conn net.Conn) (ldapserver.LDAPResultCode, error) {
+	delete(h, strings.ToLower(req.DN))
+	return ldap.LDAPResultSuccess, nil
+}
+
+func (h entries) Modify(boundDN string, req *ldap.ModifyRequest, conn net.Conn) (ldapserver.LDAPResultCode, error) {
+	e, ok := h[strings.ToLower(req.DN)]
+	if !ok {
+		return ldap.LDAPResultNoSuchObject, nil
+	}
+	e, err := ldapentry.ApplyModify(e, req)
+	if err != nil {
+		return ldap.LDAPResultOperationsError, err
+	}
+	h[strings.ToLower(req.DN)] = e
+	return ldap.LDAPResultSuccess, nil
+}
+
+func (h entries) Search(boundDN string, req *ldap.SearchRequest, conn net.Conn) (ldapserver.ServerSearchResult, error) {
+	res := ldapserver.ServerSearchResult{}
+	if e, ok := h[strings.ToLower(req.BaseDN)]; ok {
+		res.Entries = append(res.Entries, e)
+	}
+	return res, nil
+}
+
+func (h entries) Close(boundDN string, conn net.Conn) error {
+	return nil
+}
+
+func (h entries) WithContext(context.Context) handler.Handler {
+	return h
+}
+
+func (h entries) Reload(context.Context) error {
+	return nil
+}
+
+func newHandler(t *testing.T, cfg config.PasswordPolicy, now *time.Time) (handler.Handler, entries) {
+	p, err := New(cfg)
+	require.NoError(t, err)
+	m := p.Middleware(log.NewLogger(log.Level("error")), adminDN).(*middleware)
+	m.now = func() time.Time { return *now }
+	next := entries{}
+	return m.WithHandler(next), next
+}
+
+func setPassword(h handler.Handler, password string) (ldapserver.LDAPResultCode, error) {
+	return setPasswordAs(h, "", password)
+}
+
+func setPasswordAs(h handler.Handler, boundDN, password string) (ldapserver.LDAPResultCode, error) {
+	mr := &ldap.ModifyRequest{DN: userDN}
+	mr.Replace("userPassword", []string{password})
+	return h.Modify(boundDN, mr, nil)
+}
+
+func TestMiddleware(t *testing.T) {
+	now := time.Date(2022, 4, 1, 0, 0, 0, 0, time.UTC)
+	h, next := newHandler(t, config.PasswordPolicy{MinLength: 8, History: 2, MaxAge: time.Hour}, &now)
+
+	code, err := h.Add("", &ldap.AddRequest{DN: userDN, Attributes: []ldap.Attribute{
+		{Type: "uid", Vals: []string{"einstein"}},
+		{Type: "userPassword", Vals: []string{"short"}},
+	}}, nil)
+	assert.Equal(t, ldapserver.LDAPResultCode(ldap.LDAPResultConstraintViolation), code)
+	assert.Error(t, err)
+	assert.Empty(t, next)
+
+	code, err = h.Add("", &ldap.AddRequest{DN: userDN, Attributes: []ldap.Attribute{
+		{Type: "uid", Vals: []string{"einstein"}},
+		{Type: "userPassword", Vals: []string{"relativity"}},
+	}}, nil)
+	require.NoError(t, err)
+	require.Equal(t, ldapserver.LDAPResultCode(ldap.LDAPResultSuccess), code)
+	e := next[userDN]
+	assert.True(t, strings.HasPrefix(e.GetEqualFoldAttributeValue("userPassword"), "{ARGON2}"))
+	assert.Equal(t, "20220401000000Z", e.GetEqualFoldAttributeValue("pwdChangedTime"))
+
+	code, _ = h.Bind(userDN, "relativity", nil)
+	assert.Equal(t, ldapserver.LDAPResultCode(ldap.LDAPResultSuccess), code)
+
+	// the current and the previous password can't be reused
+	code, _ = setPassword(h, "relativity")
+	assert.Equal(t, ldapserver.LDAPResultCode(ldap.LDAPResultConstraintViolation), code)
+	code, _ = setPassword(h, "gravitation")
+	require.Equal(t, ldapserver.LDAPResultCode(ldap.LDAPResultSuccess), code)
+	assert.Len(t, next[userDN].GetEqualFoldAttributeValues("pwdHistory"), 1)
+	code, _ = setPassword(h, "relativity")
+	assert.Equal(t, ldapserver.LDAPResultCode(ldap.LDAPResultConstraintViolation), code)
+	code, _ = setPassword(h, "photoelectric")
+	require.Equal(t, ldapserver.LDAPResultCode(ldap.LDAPResultSuccess), code)
+	code, _ = setPassword(h, "relativity")
+	assert.Equal(t, ldapserver.LDAPResultCode(ldap.LDAPResultSuccess), code)
+
+	// hashed passwords are only accepted from the administrator and not checked
+	hash, err := ldappassword.Hash("x", "{ARGON2}")
+	require.NoError(t, err)
+	code, _ = setPassword(h, hash)
+	assert.Equal(t, ldapserver.LDAPResultCode(ldap.LDAPResultConstraintViolation), code)
+	code, _ = setPasswordAs(h, adminDN, hash)
+	assert.Equal(t, ldapserver.LDAPResultCode(ldap.LDAPResultSuccess), code)
+	assert.Equal(t, hash, next[userDN].GetEqualFoldAttributeValue("userPassword"))
+
+	// expired passwords are told apart from wrong ones
+	now = now.Add(2 * time.Hour)
+	code, _ = h.Bind(userDN, "x", nil)
+	assert.Equal(t, ldapserver.LDAPResultCode(expiredResultCode), code)
+	code, _ = h.Bind(userDN, "wrong", nil)
+	assert.Equal(t, ldapserver.LDAPResultCode(ldap.LDAPResultInvalidCredentials), code)
+}
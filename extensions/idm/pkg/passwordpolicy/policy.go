@@ -0,0 +1,146 @@
+// Package passwordpolicy enforces the password policy of the idm service on the passwords set through LDAP.
+package passwordpolicy
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/libregraph/idm/pkg/ldappassword"
+	"github.com/owncloud/ocis/extensions/idm"
+	"github.com/owncloud/ocis/extensions/idm/pkg/config"
+)
+
+// ErrViolation is wrapped by the errors returned for passwords which violate the policy.
+var ErrViolation = errors.New("password policy violation")
+
+// Policy checks new passwords.
+type Policy struct {
+	cfg    config.PasswordPolicy
+	denied map[string]struct{}
+}
+
+// New returns the policy of the configuration.
+func New(cfg config.PasswordPolicy) (*Policy, error) {
+	p := &Policy{
+		cfg:    cfg,
+		denied: map[string]struct{}{},
+	}
+	if cfg.DenyCommon {
+		if err := p.deny(strings.NewReader(idm.CommonPasswords)); err != nil {
+			return nil, err
+		}
+	}
+	if cfg.DenyListFile != "" {
+		f, err := os.Open(cfg.DenyListFile)
+		if err != nil {
+			return nil, fmt.Errorf("could not read the password deny list: %w", err)
+		}
+		defer f.Close()
+		if err := p.deny(f); err != nil {
+			return nil, fmt.Errorf("could not read the password deny list: %w", err)
+		}
+	}
+	return p, nil
+}
+
+// deny adds the passwords of a list with one password per line. Empty lines and lines starting with # are skipped.
+func (p *Policy) deny(r io.Reader) error {
+	s := bufio.NewScanner(r)
+	for s.Scan() {
+		line := strings.TrimSpace(s.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		p.denied[strings.ToLower(line)] = struct{}{}
+	}
+	return s.Err()
+}
+
+// Check returns an error wrapping ErrViolation if the password doesn't satisfy the policy.
+func (p *Policy) Check(password string) error {
+	if n := utf8.RuneCountInString(password); n < p.cfg.MinLength {
+		return fmt.Errorf("%w: the password must have at least %d characters", ErrViolation, p.cfg.MinLength)
+	}
+
+	var lower, upper, digits, special int
+	for _, r := range password {
+		switch {
+		case unicode.IsLower(r):
+			lower++
+		case unicode.IsUpper(r):
+			upper++
+		case unicode.IsDigit(r):
+			digits++
+		case !unicode.IsLetter(r):
+			special++
+		}
+	}
+	for _, c := range []struct {
+		count int
+		min   int
+		name  string
+	}{
+		{lower, p.cfg.MinLowercase, "lowercase letters"},
+		{upper, p.cfg.MinUppercase, "uppercase letters"},
+		{digits, p.cfg.MinDigits, "digits"},
+		{special, p.cfg.MinSpecial, "special characters"},
+	} {
+		if c.count < c.min {
+			return fmt.Errorf("%w: the password must have at least %d %s", ErrViolation, c.min, c.name)
+		}
+	}
+
+	if _, ok := p.denied[strings.ToLower(password)]; ok {
+		return fmt.Errorf("%w: the password is on the list of denied passwords", ErrViolation)
+	}
+	return nil
+}
+
+// CheckReuse returns an error wrapping ErrViolation if the password matches one of the hashes of the current and
+// previous passwords, while the policy keeps a password history.
+func (p *Policy) CheckReuse(password string, hashes []string) error {
+	if p.cfg.History <= 0 {
+		return nil
+	}
+	for _, h := range hashes {
+		if h == "" {
+			continue
+		}
+		if ok, _ := ldappassword.Validate(password, h); ok {
+			return fmt.Errorf("%w: the password was used before", ErrViolation)
+		}
+	}
+	return nil
+}
+
+// History returns the previous passwords to keep after a password change. The hashes are ordered from the newest to
+// the oldest one, current is the hash of the password being replaced.
+func (p *Policy) History(current string, previous []string) []string {
+	// the current password is checked separately, the history keeps the ones before it
+	if p.cfg.History <= 1 {
+		return nil
+	}
+	history := make([]string, 0, p.cfg.History-1)
+	if current != "" {
+		history = append(history, current)
+	}
+	for _, h := range previous {
+		if len(history) == p.cfg.History-1 {
+			break
+		}
+		history = append(history, h)
+	}
+	return history
+}
+
+// Expired returns true if a password changed at the given time is expired.
+func (p *Policy) Expired(changed time.Time, now time.Time) bool {
+	return p.cfg.MaxAge > 0 && now.After(changed.Add(p.cfg.MaxAge))
+}
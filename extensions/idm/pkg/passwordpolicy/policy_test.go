@@ -0,0 +1,90 @@
+package passwordpolicy
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/libregraph/idm/pkg/ldappassword"
+	"github.com/owncloud/ocis/extensions/idm/pkg/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheck(t *testing.T) {
+	denyList := filepath.Join(t.TempDir(), "deny.txt")
+	require.NoError(t, os.WriteFile(denyList, []byte("# company names\nOwnCloud-2022!\n"), 0600))
+
+	p, err := New(config.PasswordPolicy{
+		MinLength:    8,
+		MinLowercase: 1,
+		MinUppercase: 1,
+		MinDigits:    1,
+		MinSpecial:   1,
+		DenyCommon:   true,
+		DenyListFile: denyList,
+	})
+	require.NoError(t, err)
+
+	for password, valid := range map[string]bool{
+		"Secr3t!":        false,
+		"secr3t!pass":    false,
+		"SECR3T!PASS":    false,
+		"Secret!Pass":    false,
+		"Secr3tPass":     false,
+		"Secr3t!Pass":    true,
+		"Äpfel-und-B1rn": true,
+		"ownCloud-2022!": false,
+	} {
+		err := p.Check(password)
+		if valid {
+			assert.NoError(t, err, password)
+		} else {
+			assert.True(t, errors.Is(err, ErrViolation), password)
+		}
+	}
+
+	p, err = New(config.PasswordPolicy{DenyCommon: true})
+	require.NoError(t, err)
+	assert.Error(t, p.Check("Password1"))
+	assert.NoError(t, p.Check("correct horse battery staple"))
+
+	_, err = New(config.PasswordPolicy{DenyListFile: filepath.Join(t.TempDir(), "missing.txt")})
+	assert.Error(t, err)
+}
+
+func TestHistory(t *testing.T) {
+	hash := func(password string) string {
+		h, err := ldappassword.Hash(password, hashAlgorithm)
+		require.NoError(t, err)
+		return h
+	}
+
+	p, err := New(config.PasswordPolicy{})
+	require.NoError(t, err)
+	assert.NoError(t, p.CheckReuse("a", []string{hash("a")}))
+	assert.Empty(t, p.History(hash("a"), nil))
+
+	p, err = New(config.PasswordPolicy{History: 3})
+	require.NoError(t, err)
+	assert.Error(t, p.CheckReuse("a", []string{hash("b"), hash("a")}))
+	assert.Error(t, p.CheckReuse("a", []string{"a"}))
+	assert.NoError(t, p.CheckReuse("c", []string{hash("b"), hash("a"), ""}))
+	assert.Equal(t, []string{"c", "b"}, p.History("c", []string{"b", "a"}))
+	assert.Equal(t, []string{"b"}, p.History("", []string{"b"}))
+}
+
+func TestExpired(t *testing.T) {
+	now := time.Date(2022, 4, 1, 0, 0, 0, 0, time.UTC)
+
+	p, err := New(config.PasswordPolicy{})
+	require.NoError(t, err)
+	assert.False(t, p.Expired(now.AddDate(-10, 0, 0), now))
+
+	p, err = New(config.PasswordPolicy{MaxAge: 90 * 24 * time.Hour})
+	require.NoError(t, err)
+	assert.False(t, p.Expired(now.AddDate(0, 0, -89), now))
+	assert.True(t, p.Expired(now.AddDate(0, 0, -91), now))
+}
@@ -0,0 +1,75 @@
+package replication
+
+import (
+	"context"
+	"fmt"
+	"net"
+
+	"github.com/go-ldap/ldap/v3"
+	"github.com/libregraph/idm/pkg/ldapserver"
+	"github.com/libregraph/idm/server/handler"
+)
+
+// middleware rejects all changes of the replica, they have to be made on the primary.
+type middleware struct {
+	next    handler.Handler
+	primary string
+}
+
+// Middleware returns the LDAP middleware making the replica read-only.
+func (f *Follower) Middleware() handler.Middleware {
+	return &middleware{primary: f.cfg.PrimaryURI}
+}
+
+// WithHandler implements the handler.Middleware interface.
+func (m *middleware) WithHandler(next handler.Handler) handler.Handler {
+	m2 := *m
+	m2.next = next
+	return &m2
+}
+
+// WithContext implements the handler.Handler interface.
+func (m *middleware) WithContext(ctx context.Context) handler.Handler {
+	m2 := *m
+	m2.next = m.next.WithContext(ctx)
+	return &m2
+}
+
+// Reload implements the handler.Handler interface.
+func (m *middleware) Reload(ctx context.Context) error {
+	return m.next.Reload(ctx)
+}
+
+// Add implements the ldapserver.Adder interface.
+func (m *middleware) Add(boundDN string, req *ldap.AddRequest, conn net.Conn) (ldapserver.LDAPResultCode, error) {
+	return m.readOnly()
+}
+
+// Modify implements the ldapserver.Modifier interface.
+func (m *middleware) Modify(boundDN string, req *ldap.ModifyRequest, conn net.Conn) (ldapserver.LDAPResultCode, error) {
+	return m.readOnly()
+}
+
+// Delete implements the ldapserver.Deleter interface.
+func (m *middleware) Delete(boundDN string, req *ldap.DelRequest, conn net.Conn) (ldapserver.LDAPResultCode, error) {
+	return m.readOnly()
+}
+
+// Bind implements the ldapserver.Binder interface.
+func (m *middleware) Bind(bindDN, bindSimplePw string, conn net.Conn) (ldapserver.LDAPResultCode, error) {
+	return m.next.Bind(bindDN, bindSimplePw, conn)
+}
+
+// Search implements the ldapserver.Searcher interface.
+func (m *middleware) Search(boundDN string, req *ldap.SearchRequest, conn net.Conn) (ldapserver.ServerSearchResult, error) {
+	return m.next.Search(boundDN, req, conn)
+}
+
+// Close implements the ldapserver.Closer interface.
+func (m *middleware) Close(boundDN string, conn net.Conn) error {
+	return m.next.Close(boundDN, conn)
+}
+
+func (m *middleware) readOnly() (ldapserver.LDAPResultCode, error) {
+	return ldap.LDAPResultUnwillingToPerform, fmt.Errorf("this idm service is a read-only replica, changes have to be made on the primary %s", m.primary)
+}
@@ -0,0 +1,259 @@
+// Package replication lets an idm service follow the entries of a primary idm service as a read-only replica. The
+// replica keeps serving its copy of the entries, e.g. for binds, while the primary can't be reached.
+package replication
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/go-ldap/ldap/v3"
+	"github.com/libregraph/idm/pkg/ldapdn"
+	"github.com/libregraph/idm/pkg/ldapserver"
+	"github.com/libregraph/idm/server/handler"
+	"github.com/owncloud/ocis/extensions/idm/pkg/config"
+	"github.com/owncloud/ocis/ocis-pkg/log"
+)
+
+// Follower copies the entries of the primary to the local handler.
+type Follower struct {
+	cfg      config.Replication
+	baseDN   string
+	bindDN   string
+	password string
+	logger   log.Logger
+}
+
+// Result counts the changes of a synchronization.
+type Result struct {
+	Added    int
+	Modified int
+	Deleted  int
+}
+
+// New returns a follower of the configured primary. It binds to the primary and writes to the local handler as
+// bindDN, the DN allowed to write to the database.
+func New(cfg config.Replication, baseDN, bindDN, password string, logger log.Logger) (*Follower, error) {
+	u, err := url.Parse(cfg.PrimaryURI)
+	if err != nil {
+		return nil, fmt.Errorf("invalid URI of the primary idm service: %w", err)
+	}
+	if u.Scheme != "ldaps" {
+		// the follower doesn't negotiate StartTLS, so the passwords would be copied unencrypted otherwise
+		return nil, fmt.Errorf("the primary idm service must be reached with ldaps, got '%s'", cfg.PrimaryURI)
+	}
+	if cfg.Interval <= 0 {
+		return nil, errors.New("the replication interval must be positive")
+	}
+	return &Follower{
+		cfg:      cfg,
+		baseDN:   baseDN,
+		bindDN:   bindDN,
+		password: password,
+		logger:   logger,
+	}, nil
+}
+
+// Run synchronizes the handler with the primary in the configured interval until the context is done. Failed
+// synchronizations are logged and retried in the next interval.
+func (f *Follower) Run(ctx context.Context, h handler.Handler) {
+	t := time.NewTicker(f.cfg.Interval)
+	defer t.Stop()
+	for {
+		res, err := f.Sync(h)
+		if err != nil {
+			f.logger.Warn().Err(err).Str("primary", f.cfg.PrimaryURI).Msg("could not synchronize with the primary idm service")
+		} else {
+			f.logger.Debug().
+				Int("added", res.Added).
+				Int("modified", res.Modified).
+				Int("deleted", res.Deleted).
+				Msg("synchronized with the primary idm service")
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+		}
+	}
+}
+
+// Sync copies the current entries of the primary to the handler.
+func (f *Follower) Sync(h handler.Handler) (Result, error) {
+	entries, err := f.fetch()
+	if err != nil {
+		return Result{}, err
+	}
+	return Apply(h, f.bindDN, f.baseDN, entries)
+}
+
+// fetch reads all entries of the primary. The idm service reads all entries of a search in a single transaction of
+// the database, so the entries are a consistent snapshot with the parents before their children.
+func (f *Follower) fetch() ([]*ldap.Entry, error) {
+	u, err := url.Parse(f.cfg.PrimaryURI)
+	if err != nil {
+		return nil, err
+	}
+	pool, err := x509.SystemCertPool()
+	if err != nil {
+		pool = x509.NewCertPool()
+	}
+	if f.cfg.CACert != "" {
+		pem, err := os.ReadFile(f.cfg.CACert)
+		if err != nil {
+			return nil, fmt.Errorf("could not read the CA certificate of the primary: %w", err)
+		}
+		pool.AppendCertsFromPEM(pem)
+	}
+
+	conn, err := ldap.DialURL(f.cfg.PrimaryURI, ldap.DialWithTLSConfig(&tls.Config{
+		MinVersion:         tls.VersionTLS12,
+		RootCAs:            pool,
+		ServerName:         u.Hostname(),
+		InsecureSkipVerify: f.cfg.Insecure, //nolint:gosec
+	}))
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	if err := conn.Bind(f.bindDN, f.password); err != nil {
+		return nil, fmt.Errorf("could not bind as '%s': %w", f.bindDN, err)
+	}
+	res, err := conn.Search(ldap.NewSearchRequest(
+		f.baseDN, ldap.ScopeWholeSubtree, ldap.NeverDerefAliases, 0, 0, false,
+		"(objectClass=*)", nil, nil,
+	))
+	if err != nil {
+		return nil, err
+	}
+	if len(res.Entries) == 0 {
+		// an empty primary is rather a misconfiguration than a reason to delete all entries
+		return nil, fmt.Errorf("the primary idm service has no entries below '%s'", f.baseDN)
+	}
+	return res.Entries, nil
+}
+
+// Apply changes the entries below baseDN of the handler to the given ones, which must be ordered with the parents
+// before their children. It writes as boundDN.
+func Apply(h handler.Handler, boundDN, baseDN string, entries []*ldap.Entry) (Result, error) {
+	var res Result
+
+	// the handlers log the remote address of the connection
+	local, remote := net.Pipe()
+	defer local.Close()
+	defer remote.Close()
+
+	current, err := h.Search(boundDN, &ldap.SearchRequest{
+		BaseDN: baseDN,
+		Scope:  ldap.ScopeWholeSubtree,
+		Filter: "(objectClass=*)",
+	}, local)
+	if err != nil {
+		return res, err
+	}
+	existing := make(map[string]*ldap.Entry, len(current.Entries))
+	for _, e := range current.Entries {
+		ndn, err := ldapdn.ParseNormalize(e.DN)
+		if err != nil {
+			return res, err
+		}
+		existing[ndn] = e
+	}
+
+	keep := make(map[string]struct{}, len(entries))
+	for _, e := range entries {
+		ndn, err := ldapdn.ParseNormalize(e.DN)
+		if err != nil {
+			return res, fmt.Errorf("invalid DN '%s' of the primary: %w", e.DN, err)
+		}
+		keep[ndn] = struct{}{}
+
+		old, ok := existing[ndn]
+		if !ok {
+			ar := &ldap.AddRequest{DN: e.DN}
+			for _, a := range e.Attributes {
+				ar.Attribute(a.Name, a.Values)
+			}
+			if code, err := h.Add(boundDN, ar, local); code != ldap.LDAPResultSuccess {
+				return res, resultError("add", e.DN, code, err)
+			}
+			res.Added++
+			continue
+		}
+		if mr := modifyRequest(old, e); mr != nil {
+			if code, err := h.Modify(boundDN, mr, local); code != ldap.LDAPResultSuccess {
+				return res, resultError("modify", e.DN, code, err)
+			}
+			res.Modified++
+		}
+	}
+
+	// delete the children before their parents
+	for i := len(current.Entries) - 1; i >= 0; i-- {
+		e := current.Entries[i]
+		ndn, _ := ldapdn.ParseNormalize(e.DN)
+		if _, ok := keep[ndn]; ok {
+			continue
+		}
+		if code, err := h.Delete(boundDN, &ldap.DelRequest{DN: e.DN}, local); code != ldap.LDAPResultSuccess {
+			return res, resultError("delete", e.DN, code, err)
+		}
+		res.Deleted++
+	}
+	return res, nil
+}
+
+// modifyRequest returns the request changing the attributes of the old entry to the ones of the new entry, or nil if
+// they are equal.
+func modifyRequest(old, e *ldap.Entry) *ldap.ModifyRequest {
+	mr := ldap.NewModifyRequest(old.DN, nil)
+	for _, a := range e.Attributes {
+		if !equalValues(old.GetEqualFoldAttributeValues(a.Name), a.Values) {
+			mr.Replace(a.Name, a.Values)
+		}
+	}
+	for _, a := range old.Attributes {
+		if len(e.GetEqualFoldAttributeValues(a.Name)) == 0 {
+			mr.Delete(a.Name, nil)
+		}
+	}
+	if len(mr.Changes) == 0 {
+		return nil
+	}
+	return mr
+}
+
+// equalValues returns true if both lists have the same values in any order.
+func equalValues(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	a = append([]string{}, a...)
+	b = append([]string{}, b...)
+	sort.Strings(a)
+	sort.Strings(b)
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// resultError returns the error of a failed handler operation.
+func resultError(op, dn string, code ldapserver.LDAPResultCode, err error) error {
+	msg := ldap.LDAPResultCodeMap[uint16(code)]
+	if err != nil {
+		msg += ": " + err.Error()
+	}
+	return fmt.Errorf("could not %s '%s': %s", op, dn, msg)
+}
@@ -0,0 +1,164 @@
+package replication
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-ldap/ldap/v3"
+	"github.com/libregraph/idm/pkg/ldapentry"
+	"github.com/libregraph/idm/pkg/ldapserver"
+	"github.com/libregraph/idm/server/handler"
+	"github.com/owncloud/ocis/extensions/idm/pkg/config"
+	"github.com/owncloud/ocis/ocis-pkg/log"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	baseDN  = "o=libregraph-idm"
+	adminDN = "uid=libregraph,ou=sysusers,o=libregraph-idm"
+)
+
+// database is an in-memory handler returning the entries in the order they were added, like the boltdb handler it
+// refuses to delete entries with children.
+type database struct {
+	entries []*ldap.Entry
+}
+
+func (d *database) index(dn string) int {
+	for i, e := range d.entries {
+		if strings.EqualFold(e.DN, dn) {
+			return i
+		}
+	}
+	return -1
+}
+
+func (d *database) Add(boundDN string, req *ldap.AddRequest, conn net.Conn) (ldapserver.LDAPResultCode, error) {
+	if d.index(req.DN) >= 0 {
+		return ldap.LDAPResultEntryAlreadyExists, nil
+	}
+	d.entries = append(d.entries, ldapentry.EntryFromAddRequest(req))
+	return ldap.LDAPResultSuccess, nil
+}
+
+func (d *database) Bind(bindDN, bindSimplePw string, conn net.Conn) (ldapserver.LDAPResultCode, error) {
+	return ldap.LDAPResultSuccess, nil
+}
+
+func (d *database) Delete(boundDN string, req *ldap.DelRequest, conn net.Conn) (ldapserver.LDAPResultCode, error) {
+	i := d.index(req.DN)
+	if i < 0 {
+		return ldap.LDAPResultNoSuchObject, nil
+	}
+	for _, e := range d.entries {
+		if strings.HasSuffix(strings.ToLower(e.DN), ","+strings.ToLower(req.DN)) {
+			return ldap.LDAPResultUnwillingToPerform, errors.New("non leaf entry")
+		}
+	}
+	d.entries = append(d.entries[:i], d.entries[i+1:]...)
+	return ldap.LDAPResultSuccess, nil
+}
+
+func (d *database) Modify(boundDN string, req *ldap.ModifyRequest, conn net.Conn) (ldapserver.LDAPResultCode, error) {
+	i := d.index(req.DN)
+	if i < 0 {
+		return ldap.LDAPResultNoSuchObject, nil
+	}
+	e, err := ldapentry.ApplyModify(d.entries[i], req)
+	if err != nil {
+		return ldap.LDAPResultOperationsError, err
+	}
+	d.entries[i] = e
+	return ldap.LDAPResultSuccess, nil
+}
+
+func (d *database) Search(boundDN string, req *ldap.SearchRequest, conn net.Conn) (ldapserver.ServerSearchResult, error) {
+	return ldapserver.ServerSearchResult{Entries: append([]*ldap.Entry{}, d.entries...)}, nil
+}
+
+func (d *database) Close(boundDN string, conn net.Conn) error {
+	return nil
+}
+
+func (d *database) WithContext(context.Context) handler.Handler {
+	return d
+}
+
+func (d *database) Reload(context.Context) error {
+	return nil
+}
+
+func TestApply(t *testing.T) {
+	db := &database{}
+	primary := []*ldap.Entry{
+		ldap.NewEntry(baseDN, map[string][]string{"o": {"libregraph-idm"}}),
+		ldap.NewEntry("ou=users,"+baseDN, map[string][]string{"ou": {"users"}}),
+		ldap.NewEntry("uid=einstein,ou=users,"+baseDN, map[string][]string{"uid": {"einstein"}, "mail": {"einstein@example.org"}}),
+		ldap.NewEntry("uid=marie,ou=users,"+baseDN, map[string][]string{"uid": {"marie"}, "description": {"physicist", "chemist"}}),
+	}
+
+	res, err := Apply(db, adminDN, baseDN, primary)
+	require.NoError(t, err)
+	assert.Equal(t, Result{Added: 4}, res)
+
+	// unchanged entries aren't written again
+	res, err = Apply(db, adminDN, baseDN, primary)
+	require.NoError(t, err)
+	assert.Equal(t, Result{}, res)
+
+	primary = []*ldap.Entry{
+		primary[0],
+		primary[1],
+		ldap.NewEntry("uid=einstein,ou=users,"+baseDN, map[string][]string{"uid": {"einstein"}, "sn": {"Einstein"}}),
+		ldap.NewEntry("uid=marie,ou=users,"+baseDN, map[string][]string{"uid": {"marie"}, "description": {"chemist", "physicist"}}),
+		ldap.NewEntry("ou=groups,"+baseDN, map[string][]string{"ou": {"groups"}}),
+		ldap.NewEntry("cn=physics,ou=groups,"+baseDN, map[string][]string{"cn": {"physics"}}),
+	}
+	res, err = Apply(db, adminDN, baseDN, primary)
+	require.NoError(t, err)
+	assert.Equal(t, Result{Added: 2, Modified: 1}, res)
+	einstein := db.entries[db.index("uid=einstein,ou=users,"+baseDN)]
+	assert.Equal(t, "Einstein", einstein.GetEqualFoldAttributeValue("sn"))
+	assert.Empty(t, einstein.GetEqualFoldAttributeValues("mail"))
+
+	// the children are deleted before their parents
+	res, err = Apply(db, adminDN, baseDN, primary[:2])
+	require.NoError(t, err)
+	assert.Equal(t, Result{Deleted: 4}, res)
+	assert.Len(t, db.entries, 2)
+}
+
+func TestMiddleware(t *testing.T) {
+	f, err := New(config.Replication{PrimaryURI: "ldaps://primary:9235", Interval: time.Minute}, baseDN, adminDN, "idm", log.NewLogger())
+	require.NoError(t, err)
+	db := &database{}
+	h := f.Middleware().WithHandler(db)
+
+	code, err := h.Add(adminDN, &ldap.AddRequest{DN: baseDN}, nil)
+	assert.Equal(t, ldapserver.LDAPResultCode(ldap.LDAPResultUnwillingToPerform), code)
+	assert.Error(t, err)
+	code, _ = h.Modify(adminDN, ldap.NewModifyRequest(baseDN, nil), nil)
+	assert.Equal(t, ldapserver.LDAPResultCode(ldap.LDAPResultUnwillingToPerform), code)
+	code, _ = h.Delete(adminDN, &ldap.DelRequest{DN: baseDN}, nil)
+	assert.Equal(t, ldapserver.LDAPResultCode(ldap.LDAPResultUnwillingToPerform), code)
+	assert.Empty(t, db.entries)
+
+	code, _ = h.Bind(adminDN, "idm", nil)
+	assert.Equal(t, ldapserver.LDAPResultCode(ldap.LDAPResultSuccess), code)
+}
+
+func TestNew(t *testing.T) {
+	for _, cfg := range []config.Replication{
+		{PrimaryURI: "ldap://primary:389", Interval: time.Minute},
+		{PrimaryURI: "ldaps://primary:9235"},
+		{PrimaryURI: "://primary"},
+	} {
+		_, err := New(cfg, baseDN, adminDN, "idm", log.NewLogger())
+		assert.Error(t, err, cfg.PrimaryURI)
+	}
+}
@@ -0,0 +1,175 @@
+// Package starttls serves LDAP on a plain listener only to clients which negotiate TLS with the StartTLS extended
+// operation before sending any other request.
+package starttls
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"time"
+
+	ber "github.com/go-asn1-ber/asn1-ber"
+	"github.com/go-ldap/ldap/v3"
+	"github.com/owncloud/ocis/ocis-pkg/log"
+)
+
+const (
+	// startTLSOID is the name of the StartTLS extended operation
+	startTLSOID = "1.3.6.1.4.1.1466.20037"
+	// negotiationTimeout is the time a client has to send the StartTLS request and finish the TLS handshake
+	negotiationTimeout = 10 * time.Second
+)
+
+// responses maps the request operations to the operations of their responses.
+var responses = map[ber.Tag]ber.Tag{
+	ldap.ApplicationBindRequest:     ldap.ApplicationBindResponse,
+	ldap.ApplicationSearchRequest:   ldap.ApplicationSearchResultDone,
+	ldap.ApplicationModifyRequest:   ldap.ApplicationModifyResponse,
+	ldap.ApplicationAddRequest:      ldap.ApplicationAddResponse,
+	ldap.ApplicationDelRequest:      ldap.ApplicationDelResponse,
+	ldap.ApplicationModifyDNRequest: ldap.ApplicationModifyDNResponse,
+	ldap.ApplicationCompareRequest:  ldap.ApplicationCompareResponse,
+	ldap.ApplicationExtendedRequest: ldap.ApplicationExtendedResponse,
+}
+
+// errNotStartTLS is returned for a first request which is not a StartTLS request.
+var errNotStartTLS = errors.New("the first request is not a StartTLS request")
+
+// listener returns the connections of the wrapped listener once TLS has been negotiated on them.
+type listener struct {
+	net.Listener
+	config *tls.Config
+	logger log.Logger
+
+	conns chan net.Conn
+	done  chan struct{}
+	// err is the error returned by the wrapped listener, it is set before done is closed
+	err error
+}
+
+// NewListener returns a listener accepting the connections of ln on which the client negotiated TLS with the config.
+// Clients sending any other request first get a confidentialityRequired result and are disconnected.
+func NewListener(ln net.Listener, config *tls.Config, logger log.Logger) net.Listener {
+	l := &listener{
+		Listener: ln,
+		config:   config,
+		logger:   logger,
+		conns:    make(chan net.Conn),
+		done:     make(chan struct{}),
+	}
+	go l.serve()
+	return l
+}
+
+// Accept implements the net.Listener interface.
+func (l *listener) Accept() (net.Conn, error) {
+	select {
+	case c := <-l.conns:
+		return c, nil
+	case <-l.done:
+		return nil, l.err
+	}
+}
+
+// serve accepts the connections of the wrapped listener and negotiates TLS on each of them concurrently, so slow
+// clients don't hold up the others.
+func (l *listener) serve() {
+	for {
+		c, err := l.Listener.Accept()
+		if err != nil {
+			l.err = err
+			close(l.done)
+			return
+		}
+		go l.negotiate(c)
+	}
+}
+
+// negotiate waits for the StartTLS request of the client and hands the connection to Accept after the TLS handshake.
+func (l *listener) negotiate(c net.Conn) {
+	_ = c.SetDeadline(time.Now().Add(negotiationTimeout))
+	tc, err := l.startTLS(c)
+	if err != nil {
+		l.logger.Debug().Err(err).Str("remote", c.RemoteAddr().String()).Msg("Closing connection without TLS")
+		c.Close()
+		return
+	}
+	_ = c.SetDeadline(time.Time{})
+
+	select {
+	case l.conns <- tc:
+	case <-l.done:
+		tc.Close()
+	}
+}
+
+// startTLS reads the first request of the client. It answers a StartTLS request and returns the connection after the
+// TLS handshake, other requests are rejected.
+func (l *listener) startTLS(c net.Conn) (*tls.Conn, error) {
+	packet, err := ber.ReadPacket(c)
+	if err != nil {
+		return nil, err
+	}
+	if len(packet.Children) < 2 {
+		return nil, errors.New("invalid LDAP message")
+	}
+	messageID, ok := packet.Children[0].Value.(int64)
+	if !ok {
+		return nil, errors.New("invalid LDAP message ID")
+	}
+	op := packet.Children[1]
+	if op.ClassType != ber.ClassApplication {
+		return nil, errors.New("invalid LDAP operation")
+	}
+
+	if !isStartTLS(op) {
+		if response, ok := responses[op.Tag]; ok {
+			_ = writePacket(c, encodeResponse(messageID, response, ldap.LDAPResultConfidentialityRequired,
+				"StartTLS is required"))
+		}
+		return nil, fmt.Errorf("%w: %s", errNotStartTLS, ldap.ApplicationMap[uint8(op.Tag)])
+	}
+
+	if err := writePacket(c, encodeResponse(messageID, ldap.ApplicationExtendedResponse, ldap.LDAPResultSuccess,
+		"")); err != nil {
+		return nil, err
+	}
+	tc := tls.Server(c, l.config)
+	if err := tc.Handshake(); err != nil {
+		return nil, err
+	}
+	return tc, nil
+}
+
+// isStartTLS returns true if the operation is a StartTLS extended request.
+func isStartTLS(op *ber.Packet) bool {
+	if op.Tag != ldap.ApplicationExtendedRequest || len(op.Children) == 0 {
+		return false
+	}
+	name := op.Children[0]
+	return name.ClassType == ber.ClassContext && name.Tag == 0 && name.Data.String() == startTLSOID
+}
+
+// encodeResponse returns the LDAP result message of an operation. A successful extended response carries the StartTLS
+// name.
+func encodeResponse(messageID int64, op ber.Tag, resultCode uint16, message string) *ber.Packet {
+	packet := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "LDAP Response")
+	packet.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagInteger, messageID, "Message ID"))
+	response := ber.Encode(ber.ClassApplication, ber.TypeConstructed, op, nil, ldap.ApplicationMap[uint8(op)])
+	response.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagEnumerated, int64(resultCode),
+		"resultCode"))
+	response.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, "", "matchedDN"))
+	response.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, message,
+		"diagnosticMessage"))
+	if op == ldap.ApplicationExtendedResponse && resultCode == ldap.LDAPResultSuccess {
+		response.AppendChild(ber.NewString(ber.ClassContext, ber.TypePrimitive, 10, startTLSOID, "responseName"))
+	}
+	packet.AppendChild(response)
+	return packet
+}
+
+func writePacket(c net.Conn, packet *ber.Packet) error {
+	_, err := c.Write(packet.Bytes())
+	return err
+}
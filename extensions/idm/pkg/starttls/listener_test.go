@@ -0,0 +1,72 @@
+package starttls
+
+import (
+	"crypto/tls"
+	"net"
+	"path/filepath"
+	"testing"
+
+	"github.com/go-ldap/ldap/v3"
+	"github.com/libregraph/idm/pkg/ldapserver"
+	pkgcrypto "github.com/owncloud/ocis/ocis-pkg/crypto"
+	"github.com/owncloud/ocis/ocis-pkg/log"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// binder accepts every bind and records whether it was received over TLS.
+type binder struct {
+	tls chan bool
+}
+
+func (b binder) Bind(bindDN, bindSimplePw string, conn net.Conn) (ldapserver.LDAPResultCode, error) {
+	_, ok := conn.(*tls.Conn)
+	b.tls <- ok
+	return ldap.LDAPResultSuccess, nil
+}
+
+func startServer(t *testing.T) (string, chan bool) {
+	dir := t.TempDir()
+	certFile, keyFile := filepath.Join(dir, "ldap.crt"), filepath.Join(dir, "ldap.key")
+	require.NoError(t, pkgcrypto.GenCert(certFile, keyFile, log.NewLogger()))
+	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	require.NoError(t, err)
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	b := binder{tls: make(chan bool, 1)}
+	s := ldapserver.NewServer()
+	s.BindFunc("", b)
+	go func() {
+		_ = s.Serve(NewListener(ln, &tls.Config{Certificates: []tls.Certificate{cert}}, log.NewLogger()))
+	}()
+	t.Cleanup(func() { close(s.Quit) })
+	return ln.Addr().String(), b.tls
+}
+
+func TestStartTLS(t *testing.T) {
+	addr, bound := startServer(t)
+
+	conn, err := ldap.Dial("tcp", addr)
+	require.NoError(t, err)
+	defer conn.Close()
+	require.NoError(t, conn.StartTLS(&tls.Config{InsecureSkipVerify: true}))
+	require.NoError(t, conn.Bind("uid=einstein,ou=users,o=libregraph-idm", "relativity"))
+	assert.True(t, <-bound)
+}
+
+func TestPlainRequestsAreRejected(t *testing.T) {
+	addr, bound := startServer(t)
+
+	conn, err := ldap.Dial("tcp", addr)
+	require.NoError(t, err)
+	defer conn.Close()
+	err = conn.Bind("uid=einstein,ou=users,o=libregraph-idm", "relativity")
+	require.Error(t, err)
+	assert.True(t, ldap.IsErrorWithCode(err, ldap.LDAPResultConfidentialityRequired), err)
+	assert.Empty(t, bound)
+
+	// the connection is closed after the rejected request
+	assert.Error(t, conn.StartTLS(&tls.Config{InsecureSkipVerify: true}))
+}
@@ -1547,6 +1547,50 @@ func TestGetUserSigningKey(t *testing.T) {
 	}
 }
 
+func TestListUserScopedSigningKeys(t *testing.T) {
+	user := User{
+		Enabled:     "true",
+		ID:          "rutherford",
+		Email:       "rutherford@example.com",
+		Displayname: "Ernest RutherFord",
+		Password:    "password",
+	}
+
+	for _, ocsVersion := range ocsVersions {
+		for _, format := range formats {
+			err := createUser(user)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			formatpart := getFormatString(format)
+			res, err := sendRequest(
+				"GET",
+				fmt.Sprintf("/%v/cloud/user/signing-keys%v", ocsVersion, formatpart),
+				"",
+				&User{ID: user.ID},
+				[]string{ssvc.BundleUUIDRoleUser},
+			)
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			response := assertEmptyResponse(t, format, res)
+
+			assertStatusCode(t, 500, res, ocsVersion)
+			assert.False(t, response.Ocs.Meta.Success(ocsVersion), "The response was expected to be a failure but was not")
+			assertResponseMeta(t, Meta{
+				Status:     "error",
+				StatusCode: 996,
+				Message:    "error reading from store", // because the store service is not started
+			}, response.Ocs.Meta)
+			assert.Empty(t, response.Ocs.Data)
+			cleanUp(t)
+		}
+	}
+}
+
 func AddUserToGroup(userid, groupid string) error {
 	res, err := sendRequest(
 		"POST",
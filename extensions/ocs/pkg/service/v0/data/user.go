@@ -31,3 +31,17 @@ type SigningKey struct {
 	User       string `json:"user" xml:"user"`
 	SigningKey string `json:"signing-key" xml:"signing-key"`
 }
+
+// ScopedSigningKey holds the payload for a scoped signing key. The signing key itself is only returned on creation.
+type ScopedSigningKey struct {
+	ID         string   `json:"id" xml:"id"`
+	SigningKey string   `json:"signing-key,omitempty" xml:"signing-key,omitempty"`
+	Path       string   `json:"path" xml:"path"`
+	Methods    []string `json:"methods" xml:"methods>element"`
+	Created    string   `json:"created" xml:"created"`
+}
+
+// ScopedSigningKeys holds the payload for a ListSigningKeys response
+type ScopedSigningKeys struct {
+	Keys []*ScopedSigningKey `json:"keys" xml:"keys>element"`
+}
@@ -95,6 +95,11 @@ func NewService(opts ...Option) Service {
 				r.Route("/user", func(r chi.Router) {
 					r.With(requireSelfOrAdmin).Get("/", svc.GetSelf)
 					r.Get("/signing-key", svc.GetSigningKey)
+					r.Route("/signing-keys", func(r chi.Router) {
+						r.With(requireUser).Get("/", svc.ListSigningKeys)
+						r.With(requireUser).Post("/", svc.CreateSigningKey)
+						r.With(requireUser).Delete("/{keyid}", svc.DeleteSigningKey)
+					})
 				})
 
 				// for /users endpoints see https://github.com/owncloud/core/blob/master/apps/provisioning_api/appinfo/routes.php#L44-L56
@@ -168,7 +173,7 @@ func (o Ocs) getCS3Backend() backend.UserBackend {
 	if err != nil {
 		o.logger.Fatal().Msgf("could not get reva client at address %s", o.config.Reva.Address)
 	}
-	return backend.NewCS3UserBackend(nil, revaClient, o.config.MachineAuthAPIKey, o.logger)
+	return backend.NewCS3UserBackend(nil, revaClient, o.config.MachineAuthAPIKey, nil, o.logger)
 }
 
 func (o Ocs) getGroupsService() accountssvc.GroupsService {
@@ -0,0 +1,187 @@
+package svc
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	revactx "github.com/cs3org/reva/v2/pkg/ctx"
+	"github.com/go-chi/chi/v5"
+	"github.com/go-micro/plugins/v4/client/grpc"
+	"github.com/owncloud/ocis/extensions/ocs/pkg/service/v0/data"
+	"github.com/owncloud/ocis/extensions/ocs/pkg/service/v0/response"
+	"github.com/owncloud/ocis/ocis-pkg/signingkey"
+	storemsg "github.com/owncloud/ocis/protogen/gen/ocis/messages/store/v0"
+	storesvc "github.com/owncloud/ocis/protogen/gen/ocis/services/store/v0"
+	merrors "go-micro.dev/v4/errors"
+)
+
+// signingKeysPageSize is the number of signing keys read from the store at once
+const signingKeysPageSize = 100
+
+// ListSigningKeys lists the scoped signing keys of the current user, without their secrets
+func (o Ocs) ListSigningKeys(w http.ResponseWriter, r *http.Request) {
+	u, ok := revactx.ContextGetUser(r.Context())
+	if !ok {
+		o.mustRender(w, r, response.ErrRender(data.MetaBadRequest.StatusCode, "missing user in context"))
+		return
+	}
+
+	c := storesvc.NewStoreService("com.owncloud.api.store", grpc.NewClient())
+	keys := []*data.ScopedSigningKey{}
+	for offset := uint64(0); ; offset += signingKeysPageSize {
+		res, err := c.Read(r.Context(), &storesvc.ReadRequest{
+			Options: &storemsg.ReadOptions{
+				Database: signingkey.Database,
+				Table:    signingkey.Table,
+				Limit:    signingKeysPageSize,
+				Offset:   offset,
+				Where: map[string]*storemsg.Field{
+					signingkey.UserMetadata: {Type: "string", Value: u.Id.OpaqueId},
+				},
+			},
+		})
+		if err != nil {
+			o.logger.Error().Err(err).Str("userid", u.Id.OpaqueId).Msg("could not list signing keys")
+			o.mustRender(w, r, response.ErrRender(data.MetaServerError.StatusCode, "error reading from store"))
+			return
+		}
+
+		for _, rec := range res.Records {
+			k, err := signingkey.Unmarshal(rec.Value)
+			if err != nil {
+				o.logger.Error().Err(err).Str("key", rec.Key).Msg("could not decode signing key")
+				continue
+			}
+			keys = append(keys, scopedSigningKeyResponse(k, false))
+		}
+		if len(res.Records) < signingKeysPageSize {
+			break
+		}
+	}
+	o.mustRender(w, r, response.DataRender(&data.ScopedSigningKeys{Keys: keys}))
+}
+
+// CreateSigningKey creates a scoped signing key for the current user. The key is restricted to the path prefix
+// given in the path parameter and to the comma separated http methods given in the methods parameter.
+// The secret of the key is only returned once, in the response of this request.
+func (o Ocs) CreateSigningKey(w http.ResponseWriter, r *http.Request) {
+	u, ok := revactx.ContextGetUser(r.Context())
+	if !ok {
+		o.mustRender(w, r, response.ErrRender(data.MetaBadRequest.StatusCode, "missing user in context"))
+		return
+	}
+
+	var methods []string
+	for _, m := range strings.Split(r.PostFormValue("methods"), ",") {
+		if m = strings.TrimSpace(m); m != "" {
+			methods = append(methods, m)
+		}
+	}
+	if len(methods) == 0 {
+		methods = []string{http.MethodGet}
+	}
+	path := r.PostFormValue("path")
+	if path == "" {
+		path = "/"
+	}
+
+	k, err := signingkey.New(u.Id.OpaqueId, u.Username, path, methods)
+	if err != nil {
+		o.mustRender(w, r, response.ErrRender(data.MetaBadRequest.StatusCode, err.Error()))
+		return
+	}
+	value, err := k.Marshal()
+	if err != nil {
+		o.mustRender(w, r, response.ErrRender(data.MetaServerError.StatusCode, "could not encode signing key"))
+		return
+	}
+
+	c := storesvc.NewStoreService("com.owncloud.api.store", grpc.NewClient())
+	_, err = c.Write(r.Context(), &storesvc.WriteRequest{
+		Options: &storemsg.WriteOptions{
+			Database: signingkey.Database,
+			Table:    signingkey.Table,
+		},
+		Record: &storemsg.Record{
+			Key:   k.ID,
+			Value: value,
+			Metadata: map[string]*storemsg.Field{
+				signingkey.UserMetadata: {Type: "string", Value: u.Id.OpaqueId},
+			},
+		},
+	})
+	if err != nil {
+		o.logger.Error().Err(err).Str("userid", u.Id.OpaqueId).Msg("could not persist signing key")
+		o.mustRender(w, r, response.ErrRender(data.MetaServerError.StatusCode, "could not persist signing key"))
+		return
+	}
+
+	o.mustRender(w, r, response.DataRender(scopedSigningKeyResponse(k, true)))
+}
+
+// DeleteSigningKey revokes a scoped signing key of the current user
+func (o Ocs) DeleteSigningKey(w http.ResponseWriter, r *http.Request) {
+	u, ok := revactx.ContextGetUser(r.Context())
+	if !ok {
+		o.mustRender(w, r, response.ErrRender(data.MetaBadRequest.StatusCode, "missing user in context"))
+		return
+	}
+	keyID := chi.URLParam(r, "keyid")
+
+	c := storesvc.NewStoreService("com.owncloud.api.store", grpc.NewClient())
+	res, err := c.Read(r.Context(), &storesvc.ReadRequest{
+		Options: &storemsg.ReadOptions{
+			Database: signingkey.Database,
+			Table:    signingkey.Table,
+		},
+		Key: keyID,
+	})
+	if err != nil {
+		if merrors.FromError(err).Code == http.StatusNotFound {
+			o.mustRender(w, r, response.ErrRender(data.MetaNotFound.StatusCode, "The requested signing key could not be found"))
+			return
+		}
+		o.mustRender(w, r, response.ErrRender(data.MetaServerError.StatusCode, "error reading from store"))
+		return
+	}
+	if len(res.Records) < 1 {
+		o.mustRender(w, r, response.ErrRender(data.MetaNotFound.StatusCode, "The requested signing key could not be found"))
+		return
+	}
+	k, err := signingkey.Unmarshal(res.Records[0].Value)
+	if err != nil || k.UserID != u.Id.OpaqueId {
+		// do not reveal the existence of keys of other users
+		o.mustRender(w, r, response.ErrRender(data.MetaNotFound.StatusCode, "The requested signing key could not be found"))
+		return
+	}
+
+	_, err = c.Delete(r.Context(), &storesvc.DeleteRequest{
+		Options: &storemsg.DeleteOptions{
+			Database: signingkey.Database,
+			Table:    signingkey.Table,
+		},
+		Key: keyID,
+	})
+	if err != nil {
+		o.logger.Error().Err(err).Str("key", keyID).Msg("could not delete signing key")
+		o.mustRender(w, r, response.ErrRender(data.MetaServerError.StatusCode, "could not delete signing key"))
+		return
+	}
+
+	o.logger.Debug().Str("key", keyID).Str("userid", u.Id.OpaqueId).Msg("revoked signing key")
+	o.mustRender(w, r, response.DataRender(struct{}{}))
+}
+
+func scopedSigningKeyResponse(k *signingkey.Key, withSecret bool) *data.ScopedSigningKey {
+	res := &data.ScopedSigningKey{
+		ID:      k.ID,
+		Path:    k.PathPrefix,
+		Methods: k.Methods,
+		Created: k.Created.Format(time.RFC3339),
+	}
+	if withSecret {
+		res.SigningKey = k.Secret
+	}
+	return res
+}
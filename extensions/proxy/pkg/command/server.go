@@ -71,6 +71,8 @@ func Server(cfg *config.Config) *cli.Command {
 			rp := proxy.NewMultiHostReverseProxy(
 				proxy.Logger(logger),
 				proxy.Config(cfg),
+				proxy.Metrics(m),
+				proxy.Context(ctx),
 			)
 
 			{
@@ -79,7 +81,7 @@ func Server(cfg *config.Config) *cli.Command {
 					proxyHTTP.Logger(logger),
 					proxyHTTP.Context(ctx),
 					proxyHTTP.Config(cfg),
-					proxyHTTP.Metrics(metrics.New()),
+					proxyHTTP.Metrics(m),
 					proxyHTTP.Middlewares(loadMiddlewares(ctx, logger, cfg)),
 				)
 
@@ -148,7 +150,18 @@ func loadMiddlewares(ctx context.Context, logger log.Logger, cfg *config.Config)
 			logger,
 		)
 	case "cs3":
-		userProvider = backend.NewCS3UserBackend(rolesClient, revaClient, cfg.MachineAuthAPIKey, logger)
+		var accountChecker backend.AccountChecker
+		if cfg.AccountCheck.GraphEndpoint != "" {
+			accountChecker = backend.NewGraphAccountChecker(
+				cfg.AccountCheck.GraphEndpoint,
+				&http.Client{Timeout: 10 * time.Second},
+				time.Duration(cfg.AccountCheck.CacheTTL)*time.Second,
+				cfg.AccountCheck.DenyUnknown,
+				cfg.AccountCheck.FailClosed,
+				logger,
+			)
+		}
+		userProvider = backend.NewCS3UserBackend(rolesClient, revaClient, cfg.MachineAuthAPIKey, accountChecker, logger)
 	default:
 		logger.Fatal().Msgf("Invalid accounts backend type '%s'", cfg.AccountBackend)
 	}
@@ -173,9 +186,13 @@ func loadMiddlewares(ctx context.Context, logger log.Logger, cfg *config.Config)
 	return alice.New(
 		// first make sure we log all requests and redirect to https if necessary
 		pkgmiddleware.TraceContext,
+		middleware.PeerAddress,
 		chimiddleware.RealIP,
 		chimiddleware.RequestID,
-		middleware.AccessLog(logger),
+		middleware.AccessLog(
+			middleware.Logger(logger),
+			middleware.AccessLogConfig(cfg.AccessLog),
+		),
 		middleware.HTTPSRedirect,
 
 		// now that we established the basics, on with authentication middleware
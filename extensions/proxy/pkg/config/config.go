@@ -16,6 +16,8 @@ type Config struct {
 	Log     *Log     `yaml:"log"`
 	Debug   Debug    `yaml:"debug"`
 
+	AccessLog AccessLog `yaml:"access_log"`
+
 	HTTP HTTP `yaml:"http"`
 
 	Reva Reva `yaml:"reva"`
@@ -33,10 +35,33 @@ type Config struct {
 	EnableBasicAuth       bool            `yaml:"enable_basic_auth" env:"PROXY_ENABLE_BASIC_AUTH"`
 	InsecureBackends      bool            `yaml:"insecure_backends" env:"PROXY_INSECURE_BACKENDS"`
 	AuthMiddleware        AuthMiddleware  `yaml:"auth_middleware"`
+	ResponseCache         ResponseCache   `yaml:"response_cache"`
+	AccountCheck          AccountCheck    `yaml:"account_check"`
 
 	Context context.Context `yaml:"-"`
 }
 
+// AccessLogFormat defines how the access log lines are written
+type AccessLogFormat string
+
+const (
+	// DefaultAccessLogFormat logs every request at info level to the service log
+	DefaultAccessLogFormat AccessLogFormat = "default"
+	// JSONAccessLogFormat writes one json object per request, regardless of the log level
+	JSONAccessLogFormat AccessLogFormat = "json"
+	// CommonAccessLogFormat writes the Apache common log format, regardless of the log level
+	CommonAccessLogFormat AccessLogFormat = "common"
+	// CombinedAccessLogFormat writes the Apache combined log format, regardless of the log level
+	CombinedAccessLogFormat AccessLogFormat = "combined"
+)
+
+// AccessLog defines the access log configuration.
+type AccessLog struct {
+	Format AccessLogFormat `yaml:"format" env:"PROXY_ACCESS_LOG_FORMAT"`
+	// File to append the access log to, stdout is used if unset. Ignored by the default format.
+	File string `yaml:"file" env:"PROXY_ACCESS_LOG_FILE"`
+}
+
 // Policy enables us to use multiple directors.
 type Policy struct {
 	Name   string  `yaml:"name"`
@@ -49,9 +74,15 @@ type Route struct {
 	Endpoint string    `yaml:"endpoint"`
 	// Backend is a static URL to forward the request to
 	Backend string `yaml:"backend"`
+	// Backends is a list of static URLs the requests are balanced across
+	Backends []string `yaml:"backends"`
 	// Service name to look up in the registry
 	Service     string `yaml:"service"`
 	ApacheVHost bool   `yaml:"apache-vhost"`
+	// LoadBalancer configures how requests are distributed across Backends
+	LoadBalancer LoadBalancer `yaml:"load_balancer"`
+	// Cache enables the response cache for the route
+	Cache bool `yaml:"cache"`
 }
 
 // RouteType defines the type of a route
@@ -73,6 +104,68 @@ var (
 	RouteTypes = []RouteType{QueryRoute, RegexRoute, PrefixRoute}
 )
 
+// BalancerStrategy defines how a backend is picked from the list of Backends of a route
+type BalancerStrategy string
+
+const (
+	// RoundRobinStrategy picks the available backends in turn
+	RoundRobinStrategy BalancerStrategy = "round_robin"
+	// LeastConnectionsStrategy picks the available backend with the fewest requests in flight
+	LeastConnectionsStrategy BalancerStrategy = "least_connections"
+	// DefaultBalancerStrategy is the RoundRobinStrategy
+	DefaultBalancerStrategy BalancerStrategy = RoundRobinStrategy
+)
+
+// LoadBalancer is the config for routes with multiple backends.
+type LoadBalancer struct {
+	Strategy       BalancerStrategy `yaml:"strategy"`
+	HealthCheck    HealthCheck      `yaml:"health_check"`
+	CircuitBreaker CircuitBreaker   `yaml:"circuit_breaker"`
+}
+
+// HealthCheck configures the active health checks of the backends. Health checks are disabled if no path is set.
+type HealthCheck struct {
+	// Path is requested on every backend, a status code below 400 marks the backend healthy
+	Path string `yaml:"path"`
+	// Interval between two checks in seconds
+	Interval int `yaml:"interval"`
+	// Timeout of a single check in seconds
+	Timeout int `yaml:"timeout"`
+}
+
+// CircuitBreaker configures the passive ejection of backends which fail to serve requests.
+type CircuitBreaker struct {
+	// MaxFailures is the number of consecutive 5xx responses or transport errors after which a backend is ejected
+	MaxFailures int `yaml:"max_failures"`
+	// OpenTimeout is the time in seconds an ejected backend is skipped before a single trial request is let through
+	OpenTimeout int `yaml:"open_timeout"`
+}
+
+// AccountCheck configures the check whether the accounts of the users of the cs3 account backend are enabled.
+type AccountCheck struct {
+	// GraphEndpoint is the graph service the accounts are looked up in, the check is disabled if it's empty
+	GraphEndpoint string `yaml:"graph_endpoint" env:"PROXY_ACCOUNT_CHECK_GRAPH_ENDPOINT"`
+	// CacheTTL is the number of seconds the state of an account is cached
+	CacheTTL int `yaml:"cache_ttl" env:"PROXY_ACCOUNT_CHECK_CACHE_TTL"`
+	// DenyUnknown rejects the users the graph service doesn't know, by default they are allowed
+	DenyUnknown bool `yaml:"deny_unknown" env:"PROXY_ACCOUNT_CHECK_DENY_UNKNOWN"`
+	// FailClosed rejects the users if the graph service can't be reached, by default they are allowed
+	FailClosed bool `yaml:"fail_closed" env:"PROXY_ACCOUNT_CHECK_FAIL_CLOSED"`
+}
+
+// ResponseCache configures the cache used by the routes with caching enabled.
+type ResponseCache struct {
+	// Store is either "memory" or "disk"
+	Store string `yaml:"store" env:"PROXY_RESPONSE_CACHE_STORE"`
+	// Path is the directory of the disk store, the entries are kept in its proxy-response-cache subdirectory which is
+	// wiped on startup
+	Path string `yaml:"path" env:"PROXY_RESPONSE_CACHE_PATH"`
+	// MaxSize is the maximum size of all cached responses in bytes
+	MaxSize int64 `yaml:"max_size" env:"PROXY_RESPONSE_CACHE_MAX_SIZE"`
+	// MaxEntrySize is the maximum size of a single cached response body in bytes
+	MaxEntrySize int64 `yaml:"max_entry_size" env:"PROXY_RESPONSE_CACHE_MAX_ENTRY_SIZE"`
+}
+
 // AuthMiddleware configures the proxy http auth middleware.
 type AuthMiddleware struct {
 	CredentialsByUserAgent map[string]string `yaml:"credentials_by_user_agent"`
@@ -94,10 +187,11 @@ type UserinfoCache struct {
 
 // PolicySelector is the toplevel-configuration for different selectors
 type PolicySelector struct {
-	Static    *StaticSelectorConf    `yaml:"static"`
-	Migration *MigrationSelectorConf `yaml:"migration"`
-	Claims    *ClaimsSelectorConf    `yaml:"claims"`
-	Regex     *RegexSelectorConf     `yaml:"regex"`
+	Static     *StaticSelectorConf     `yaml:"static"`
+	Migration  *MigrationSelectorConf  `yaml:"migration"`
+	Claims     *ClaimsSelectorConf     `yaml:"claims"`
+	Regex      *RegexSelectorConf      `yaml:"regex"`
+	Expression *ExpressionSelectorConf `yaml:"expression"`
 }
 
 // StaticSelectorConf is the config for the static-policy-selector
@@ -144,3 +238,16 @@ type RegexRuleConf struct {
 	Match    string `yaml:"match"`
 	Policy   string `yaml:"policy"`
 }
+
+// ExpressionSelectorConf is the config for the expression-selector
+type ExpressionSelectorConf struct {
+	DefaultPolicy  string               `yaml:"default_policy"`
+	Rules          []ExpressionRuleConf `yaml:"rules"`
+	TrustedProxies []string             `yaml:"trusted_proxies"`
+}
+
+// ExpressionRuleConf selects the policy if the expression evaluates to true
+type ExpressionRuleConf struct {
+	Expression string `yaml:"expression"`
+	Policy     string `yaml:"policy"`
+}
@@ -25,6 +25,9 @@ func DefaultConfig() *config.Config {
 		Service: config.Service{
 			Name: "proxy",
 		},
+		AccessLog: config.AccessLog{
+			Format: config.DefaultAccessLogFormat,
+		},
 		OIDC: config.OIDC{
 			Issuer:   "https://localhost:9200",
 			Insecure: true,
@@ -45,6 +48,16 @@ func DefaultConfig() *config.Config {
 			AllowedHTTPMethods: []string{"GET"},
 			Enabled:            true,
 		},
+		ResponseCache: config.ResponseCache{
+			Store:        "memory",
+			Path:         path.Join(defaults.BaseDataPath(), "proxy", "cache"),
+			MaxSize:      64 * 1024 * 1024,
+			MaxEntrySize: 4 * 1024 * 1024,
+		},
+		AccountCheck: config.AccountCheck{
+			GraphEndpoint: "",
+			CacheTTL:      30,
+		},
 		AccountBackend:        "accounts",
 		UserOIDCClaim:         "email",
 		UserCS3Claim:          "mail",
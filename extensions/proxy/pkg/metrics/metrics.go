@@ -18,8 +18,15 @@ type Metrics struct {
 	Latency   *prometheus.SummaryVec
 	Duration  *prometheus.HistogramVec
 	BuildInfo *prometheus.GaugeVec
+	// Requests counts the proxied requests by policy, route endpoint, method and status class
+	Requests *prometheus.CounterVec
+	// RequestDuration observes the proxied request durations by policy, route endpoint, method and status class
+	RequestDuration *prometheus.HistogramVec
 }
 
+// RouteLabels are the labels of the per route metrics.
+var RouteLabels = []string{"policy", "route", "method", "status"}
+
 // New initializes the available metrics.
 func New() *Metrics {
 	m := &Metrics{
@@ -47,11 +54,26 @@ func New() *Metrics {
 			Name:      "build_info",
 			Help:      "Build Information",
 		}, []string{"versions"}),
+		Requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: Namespace,
+			Subsystem: Subsystem,
+			Name:      "route_requests_total",
+			Help:      "How many requests were proxied per policy and route",
+		}, RouteLabels),
+		RequestDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: Namespace,
+			Subsystem: Subsystem,
+			Name:      "route_request_duration_seconds",
+			Help:      "Proxied request time in seconds per policy and route",
+			Buckets:   prometheus.DefBuckets,
+		}, RouteLabels),
 	}
 
 	_ = prometheus.Register(m.Counter)
 	_ = prometheus.Register(m.Latency)
 	_ = prometheus.Register(m.Duration)
 	_ = prometheus.Register(m.BuildInfo)
+	_ = prometheus.Register(m.Requests)
+	_ = prometheus.Register(m.RequestDuration)
 	return m
 }
@@ -1,32 +1,141 @@
 package middleware
 
 import (
+	"fmt"
+	"io"
+	"net"
 	"net/http"
+	"os"
+	"strconv"
+	"sync"
 	"time"
 
 	"github.com/go-chi/chi/v5/middleware"
 	chimiddleware "github.com/go-chi/chi/v5/middleware"
-	"github.com/owncloud/ocis/ocis-pkg/log"
+	"github.com/owncloud/ocis/extensions/proxy/pkg/config"
+	"github.com/rs/zerolog"
 )
 
-// AccessLog is a middleware to log http requests at info level logging.
-func AccessLog(logger log.Logger) func(http.Handler) http.Handler {
-	return func(next http.Handler) http.Handler {
-		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			start := time.Now()
-			wrap := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
-			next.ServeHTTP(wrap, r)
+// apacheTimeFormat is the time format used by the Apache common and combined log formats.
+const apacheTimeFormat = "02/Jan/2006:15:04:05 -0700"
 
+// AccessLog is a middleware to log http requests. With the default format every request is logged at info level
+// to the service log, all other formats are written to the configured access log file regardless of the log level.
+func AccessLog(optionSetters ...Option) func(http.Handler) http.Handler {
+	options := newOptions(optionSetters...)
+	logger := options.Logger
+	format := options.AccessLogConfig.Format
+
+	var w io.Writer = os.Stdout
+	if format != "" && format != config.DefaultAccessLogFormat && options.AccessLogConfig.File != "" {
+		f, err := os.OpenFile(options.AccessLogConfig.File, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+		if err != nil {
+			logger.Fatal().Err(err).Str("file", options.AccessLogConfig.File).Msg("could not open access log")
+		}
+		w = f
+	}
+
+	var write func(r *http.Request, rec accessLogRecord)
+	switch format {
+	case "", config.DefaultAccessLogFormat:
+		write = func(r *http.Request, rec accessLogRecord) {
 			logger.Info().
 				Str("proto", r.Proto).
 				Str("request", chimiddleware.GetReqID(r.Context())).
 				Str("remote-addr", r.RemoteAddr).
 				Str("method", r.Method).
-				Int("status", wrap.Status()).
+				Int("status", rec.status).
 				Str("path", r.URL.Path).
-				Dur("duration", time.Since(start)).
-				Int("bytes", wrap.BytesWritten()).
+				Dur("duration", rec.duration).
+				Int("bytes", rec.bytes).
 				Msg("access-log")
+		}
+	case config.JSONAccessLogFormat:
+		jsonLogger := zerolog.New(w).With().Timestamp().Logger()
+		write = func(r *http.Request, rec accessLogRecord) {
+			// events without a level are not affected by the global log level
+			jsonLogger.Log().
+				Str("proto", r.Proto).
+				Str("request", chimiddleware.GetReqID(r.Context())).
+				Str("remote-addr", r.RemoteAddr).
+				Str("method", r.Method).
+				Int("status", rec.status).
+				Str("path", r.URL.Path).
+				Str("query", r.URL.RawQuery).
+				Str("referer", r.Referer()).
+				Str("user-agent", r.UserAgent()).
+				Dur("duration", rec.duration).
+				Int("bytes", rec.bytes).
+				Msg("access-log")
+		}
+	case config.CommonAccessLogFormat, config.CombinedAccessLogFormat:
+		var mu sync.Mutex
+		combined := format == config.CombinedAccessLogFormat
+		write = func(r *http.Request, rec accessLogRecord) {
+			line := apacheLogLine(r, rec, combined)
+			mu.Lock()
+			defer mu.Unlock()
+			_, _ = io.WriteString(w, line)
+		}
+	default:
+		logger.Fatal().Str("format", string(format)).Msg("unknown access log format")
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+			wrap := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+			next.ServeHTTP(wrap, r)
+
+			write(r, accessLogRecord{
+				start:    start,
+				status:   wrap.Status(),
+				bytes:    wrap.BytesWritten(),
+				duration: time.Since(start),
+			})
 		})
 	}
 }
+
+type accessLogRecord struct {
+	start    time.Time
+	status   int
+	bytes    int
+	duration time.Duration
+}
+
+// apacheLogLine formats a request in the Apache common or combined log format.
+func apacheLogLine(r *http.Request, rec accessLogRecord, combined bool) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	user := "-"
+	if u, _, ok := r.BasicAuth(); ok && u != "" {
+		user = u
+	}
+	size := "-"
+	if rec.bytes > 0 {
+		size = strconv.Itoa(rec.bytes)
+	}
+
+	line := fmt.Sprintf("%s - %s [%s] %s %d %s",
+		host,
+		user,
+		rec.start.Format(apacheTimeFormat),
+		strconv.Quote(r.Method+" "+r.RequestURI+" "+r.Proto),
+		rec.status,
+		size,
+	)
+	if combined {
+		line += fmt.Sprintf(" %s %s", quoteOrDash(r.Referer()), quoteOrDash(r.UserAgent()))
+	}
+	return line + "\n"
+}
+
+func quoteOrDash(s string) string {
+	if s == "" {
+		return `"-"`
+	}
+	return strconv.Quote(s)
+}
@@ -0,0 +1,56 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestAccessLog__apacheLogLine(t *testing.T) {
+	start := time.Date(2022, 4, 20, 13, 55, 36, 0, time.UTC)
+	tests := []struct {
+		name     string
+		combined bool
+		request  func() *http.Request
+		record   accessLogRecord
+		expected string
+	}{
+		{
+			name: "common",
+			request: func() *http.Request {
+				return httptest.NewRequest(http.MethodGet, "/remote.php/dav/files/einstein?foo=bar", nil)
+			},
+			record:   accessLogRecord{start: start, status: 207, bytes: 2326},
+			expected: `192.0.2.1 - - [20/Apr/2022:13:55:36 +0000] "GET /remote.php/dav/files/einstein?foo=bar HTTP/1.1" 207 2326` + "\n",
+		},
+		{
+			name: "common with basic auth and empty body",
+			request: func() *http.Request {
+				r := httptest.NewRequest(http.MethodDelete, "/remote.php/webdav/file.txt", nil)
+				r.SetBasicAuth("einstein", "relativity")
+				return r
+			},
+			record:   accessLogRecord{start: start, status: 204},
+			expected: `192.0.2.1 - einstein [20/Apr/2022:13:55:36 +0000] "DELETE /remote.php/webdav/file.txt HTTP/1.1" 204 -` + "\n",
+		},
+		{
+			name:     "combined",
+			combined: true,
+			request: func() *http.Request {
+				r := httptest.NewRequest(http.MethodGet, "/config.json", nil)
+				r.Header.Set("User-Agent", "Mozilla/5.0")
+				return r
+			},
+			record:   accessLogRecord{start: start, status: 200, bytes: 10},
+			expected: `192.0.2.1 - - [20/Apr/2022:13:55:36 +0000] "GET /config.json HTTP/1.1" 200 10 "-" "Mozilla/5.0"` + "\n",
+		},
+	}
+
+	for _, tt := range tests {
+		got := apacheLogLine(tt.request(), tt.record, tt.combined)
+		if got != tt.expected {
+			t.Errorf("%s: expected %q got %q", tt.name, tt.expected, got)
+		}
+	}
+}
@@ -58,6 +58,8 @@ type Options struct {
 	UserinfoCacheTTL time.Duration
 	// CredentialsByUserAgent sets the auth challenges on a per user-agent basis
 	CredentialsByUserAgent map[string]string
+	// AccessLogConfig to configure the access log middleware
+	AccessLogConfig config.AccessLog
 }
 
 // newOptions initializes the available default options.
@@ -155,6 +157,13 @@ func PreSignedURLConfig(cfg config.PreSignedURL) Option {
 	}
 }
 
+// AccessLogConfig provides a function to set the AccessLogConfig option.
+func AccessLogConfig(cfg config.AccessLog) Option {
+	return func(o *Options) {
+		o.AccessLogConfig = cfg
+	}
+}
+
 // UserOIDCClaim provides a function to set the UserClaim config
 func UserOIDCClaim(val string) Option {
 	return func(o *Options) {
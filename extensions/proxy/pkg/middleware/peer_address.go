@@ -0,0 +1,15 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/owncloud/ocis/extensions/proxy/pkg/proxy/policy"
+)
+
+// PeerAddress keeps the address of the socket peer for the policy selectors. It has to run before RealIP replaces the
+// RemoteAddr of the request with the client address sent in the forwarding headers.
+func PeerAddress(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		next.ServeHTTP(w, r.WithContext(policy.ContextWithPeerAddr(r.Context(), r.RemoteAddr)))
+	})
+}
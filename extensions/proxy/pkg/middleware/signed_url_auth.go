@@ -15,6 +15,7 @@ import (
 	"github.com/owncloud/ocis/extensions/proxy/pkg/config"
 	"github.com/owncloud/ocis/extensions/proxy/pkg/user/backend"
 	"github.com/owncloud/ocis/ocis-pkg/log"
+	"github.com/owncloud/ocis/ocis-pkg/signingkey"
 	storemsg "github.com/owncloud/ocis/protogen/gen/ocis/messages/store/v0"
 	storesvc "github.com/owncloud/ocis/protogen/gen/ocis/services/store/v0"
 	"golang.org/x/crypto/pbkdf2"
@@ -49,10 +50,16 @@ func (m signedURLAuth) ServeHTTP(w http.ResponseWriter, req *http.Request) {
 		return
 	}
 
+	if req.URL.Query().Get("OC-KeyID") != "" {
+		m.serveScoped(w, req)
+		return
+	}
+
 	user, _, err := m.userProvider.GetUserByClaims(req.Context(), "username", req.URL.Query().Get("OC-Credential"), true)
 	if err != nil {
 		m.logger.Error().Err(err).Msg("Could not get user by claim")
 		w.WriteHeader(http.StatusInternalServerError)
+		return
 	}
 
 	ctx := revactx.ContextSetUser(req.Context(), user)
@@ -67,6 +74,103 @@ func (m signedURLAuth) ServeHTTP(w http.ResponseWriter, req *http.Request) {
 	m.next.ServeHTTP(w, req)
 }
 
+// serveScoped handles urls signed with a scoped signing key, identified by the OC-KeyID parameter. Unlike the legacy
+// scheme every failure, including a failed key or user lookup, rejects the request.
+func (m signedURLAuth) serveScoped(w http.ResponseWriter, req *http.Request) {
+	key, err := m.validateScoped(req)
+	if err != nil {
+		m.logger.Debug().Err(err).Str("key", req.URL.Query().Get("OC-KeyID")).Msg("invalid scoped url signature")
+		http.Error(w, "Invalid url signature", http.StatusUnauthorized)
+		return
+	}
+
+	user, _, err := m.userProvider.GetUserByClaims(req.Context(), "username", key.Username, true)
+	if err != nil {
+		m.logger.Error().Err(err).Str("key", key.ID).Msg("Could not get user of signing key")
+		http.Error(w, "Invalid url signature", http.StatusUnauthorized)
+		return
+	}
+	if user.GetId().GetOpaqueId() != key.UserID {
+		// the username has been handed to another account since the key was created
+		m.logger.Error().Str("key", key.ID).Msg("signing key does not belong to the user")
+		http.Error(w, "Invalid url signature", http.StatusUnauthorized)
+		return
+	}
+
+	m.next.ServeHTTP(w, req.WithContext(revactx.ContextSetUser(req.Context(), user)))
+}
+
+func (m signedURLAuth) validateScoped(req *http.Request) (*signingkey.Key, error) {
+	query := req.URL.Query()
+
+	for _, p := range []string{
+		"OC-Signature",
+		"OC-KeyID",
+		"OC-Date",
+		"OC-Expires",
+		"OC-Verb",
+	} {
+		if query.Get(p) == "" {
+			return nil, fmt.Errorf("required %s parameter not found", p)
+		}
+	}
+
+	if ok, err := m.requestMethodMatches(req.Method, query); !ok {
+		return nil, err
+	}
+
+	if ok, err := m.requestMethodIsAllowed(req.Method); !ok {
+		return nil, err
+	}
+
+	if expired, err := m.urlIsExpired(query, time.Now); expired {
+		if err == nil {
+			err = errors.New("url is expired")
+		}
+		return nil, err
+	}
+
+	key, err := m.getScopedSigningKey(req.Context(), query.Get("OC-KeyID"))
+	if err != nil {
+		return nil, err
+	}
+
+	if !key.Allows(req.Method, req.URL.Path) {
+		return nil, errors.New("signing key is not valid for the requested path or method")
+	}
+
+	signature := query.Get("OC-Signature")
+	query.Del("OC-Signature")
+	u := *req.URL
+	u.RawQuery = query.Encode()
+	signedURL := u.String()
+	if !u.IsAbs() {
+		signedURL = "https://" + req.Host + signedURL
+	}
+
+	if !key.Verify(signedURL, signature) {
+		return nil, errors.New("signature mismatch")
+	}
+	return key, nil
+}
+
+func (m signedURLAuth) getScopedSigningKey(ctx context.Context, keyID string) (*signingkey.Key, error) {
+	res, err := m.store.Read(ctx, &storesvc.ReadRequest{
+		Options: &storemsg.ReadOptions{
+			Database: signingkey.Database,
+			Table:    signingkey.Table,
+		},
+		Key: keyID,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(res.Records) < 1 {
+		return nil, errors.New("signing key not found")
+	}
+	return signingkey.Unmarshal(res.Records[0].Value)
+}
+
 func (m signedURLAuth) shouldServe(req *http.Request) bool {
 	if !m.preSignedURLConfig.Enabled {
 		return false
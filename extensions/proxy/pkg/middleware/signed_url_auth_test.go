@@ -1,9 +1,22 @@
 package middleware
 
 import (
+	"context"
+	"errors"
+	"net/http"
 	"net/http/httptest"
+	"net/url"
 	"testing"
 	"time"
+
+	userv1beta1 "github.com/cs3org/go-cs3apis/cs3/identity/user/v1beta1"
+	revactx "github.com/cs3org/reva/v2/pkg/ctx"
+	"github.com/owncloud/ocis/extensions/proxy/pkg/config"
+	"github.com/owncloud/ocis/extensions/proxy/pkg/user/backend/test"
+	"github.com/owncloud/ocis/ocis-pkg/signingkey"
+	storemsg "github.com/owncloud/ocis/protogen/gen/ocis/messages/store/v0"
+	storesvc "github.com/owncloud/ocis/protogen/gen/ocis/services/store/v0"
+	"go-micro.dev/v4/client"
 )
 
 func TestSignedURLAuth_shouldServe(t *testing.T) {
@@ -134,3 +147,80 @@ func TestSignedURLAuth_createSignature(t *testing.T) {
 		t.Fail()
 	}
 }
+
+type signingKeyStore struct {
+	storesvc.StoreService
+	keys map[string]*signingkey.Key
+}
+
+func (s signingKeyStore) Read(ctx context.Context, in *storesvc.ReadRequest, opts ...client.CallOption) (*storesvc.ReadResponse, error) {
+	k, ok := s.keys[in.Key]
+	if !ok {
+		return nil, errors.New("not found")
+	}
+	data, err := k.Marshal()
+	if err != nil {
+		return nil, err
+	}
+	return &storesvc.ReadResponse{Records: []*storemsg.Record{{Key: in.Key, Value: data}}}, nil
+}
+
+func TestSignedURLAuth_scopedKey(t *testing.T) {
+	key, err := signingkey.New("einstein-id", "einstein", "/remote.php/dav/files/einstein", []string{"GET"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sign := func(method, path string, k *signingkey.Key) string {
+		q := url.Values{}
+		q.Set("OC-KeyID", k.ID)
+		q.Set("OC-Date", time.Now().Add(-time.Minute).UTC().Format(time.RFC3339))
+		q.Set("OC-Expires", "600")
+		q.Set("OC-Verb", method)
+		u := "https://example.com" + path + "?" + q.Encode()
+		return u + "&OC-Signature=" + k.Sign(u)
+	}
+	userLookup := func(ctx context.Context, claim string, value string, withRoles bool) (*userv1beta1.User, string, error) {
+		if claim != "username" || value != "einstein" {
+			return nil, "", errors.New("not found")
+		}
+		return &userv1beta1.User{Id: &userv1beta1.UserId{OpaqueId: "einstein-id"}, Username: "einstein"}, "", nil
+	}
+
+	revoked, _ := signingkey.New("einstein-id", "einstein", "/", []string{"GET"})
+	foreign, _ := signingkey.New("other-id", "einstein", "/", []string{"GET"})
+
+	tests := []struct {
+		name     string
+		url      string
+		lookup   func(ctx context.Context, claim string, value string, withRoles bool) (*userv1beta1.User, string, error)
+		expected int
+	}{
+		{"valid", sign("GET", "/remote.php/dav/files/einstein/photo.jpg", key), userLookup, http.StatusOK},
+		{"outside of path prefix", sign("GET", "/remote.php/dav/files/marie/photo.jpg", key), userLookup, http.StatusUnauthorized},
+		{"tampered", sign("GET", "/remote.php/dav/files/einstein/photo.jpg", key) + "0", userLookup, http.StatusUnauthorized},
+		{"revoked key", sign("GET", "/remote.php/dav/files/einstein/photo.jpg", revoked), userLookup, http.StatusUnauthorized},
+		{"key of another user", sign("GET", "/remote.php/dav/files/einstein/photo.jpg", foreign), userLookup, http.StatusUnauthorized},
+		{"user lookup fails", sign("GET", "/remote.php/dav/files/einstein/photo.jpg", key), func(ctx context.Context, claim string, value string, withRoles bool) (*userv1beta1.User, string, error) {
+			return nil, "", errors.New("unavailable")
+		}, http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		handler := SignedURLAuth(
+			PreSignedURLConfig(config.PreSignedURL{Enabled: true, AllowedHTTPMethods: []string{"GET"}}),
+			Store(signingKeyStore{keys: map[string]*signingkey.Key{key.ID: key, foreign.ID: foreign}}),
+			UserProvider(&test.UserBackendMock{GetUserByClaimsFunc: tt.lookup}),
+		)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if u, ok := revactx.ContextGetUser(r.Context()); !ok || u.Username != "einstein" {
+				t.Errorf("%s: expected einstein in context", tt.name)
+			}
+		}))
+
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, tt.url, nil))
+		if rr.Code != tt.expected {
+			t.Errorf("%s: expected status %d got %d", tt.name, tt.expected, rr.Code)
+		}
+	}
+}
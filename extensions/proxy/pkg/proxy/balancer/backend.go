@@ -0,0 +1,130 @@
+package balancer
+
+import (
+	"net/url"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// circuit breaker states of a backend
+const (
+	stateClosed = iota
+	stateOpen
+	stateHalfOpen
+)
+
+// Backend is a single upstream of a balanced route. It keeps track of the requests in flight, the result of the
+// active health checks and the circuit breaker state derived from the responses it served.
+type Backend struct {
+	URL *url.URL
+
+	active int64
+
+	mu          sync.Mutex
+	unhealthy   bool
+	state       int
+	failures    int
+	openedAt    time.Time
+	maxFailures int
+	openTimeout time.Duration
+}
+
+func newBackend(u *url.URL, cb circuitBreaker) *Backend {
+	return &Backend{
+		URL:         u,
+		maxFailures: cb.maxFailures,
+		openTimeout: cb.openTimeout,
+	}
+}
+
+// Active returns the number of requests currently in flight.
+func (b *Backend) Active() int64 {
+	return atomic.LoadInt64(&b.active)
+}
+
+// Available reports if the backend may receive a request. Unhealthy backends and backends with an open circuit are
+// not available. Once the open timeout has passed a single trial request is admitted.
+func (b *Backend) Available() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.available(time.Now())
+}
+
+func (b *Backend) available(now time.Time) bool {
+	if b.unhealthy {
+		return false
+	}
+	switch b.state {
+	case stateOpen:
+		return now.Sub(b.openedAt) >= b.openTimeout
+	case stateHalfOpen:
+		// the trial request is still in flight
+		return false
+	}
+	return true
+}
+
+// acquire reserves the backend for a request if it is available. If the open timeout of the circuit has passed, the
+// request becomes the trial request of the half open circuit. The check and the transition happen under the same
+// lock, so only a single trial request is admitted.
+func (b *Backend) acquire() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if !b.available(time.Now()) {
+		return false
+	}
+	if b.state == stateOpen {
+		b.state = stateHalfOpen
+	}
+	return true
+}
+
+// begin marks the start of a request to the backend.
+func (b *Backend) begin() {
+	atomic.AddInt64(&b.active, 1)
+}
+
+// end marks the end of a request to the backend.
+func (b *Backend) end() {
+	atomic.AddInt64(&b.active, -1)
+}
+
+// abort gives up a trial request without a verdict, e.g. because the client went away. The next request becomes the
+// trial request.
+func (b *Backend) abort() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.state == stateHalfOpen {
+		b.state = stateOpen
+	}
+}
+
+// success closes the circuit of the backend.
+func (b *Backend) success() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.state = stateClosed
+	b.failures = 0
+}
+
+// failure counts a failed request and opens the circuit once the threshold is reached. A failed trial request
+// reopens the circuit immediately.
+func (b *Backend) failure() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.failures++
+	if b.state == stateHalfOpen || b.failures >= b.maxFailures {
+		b.state = stateOpen
+		b.openedAt = time.Now()
+	}
+}
+
+// setHealthy records the result of an active health check.
+func (b *Backend) setHealthy(healthy bool) (changed bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	changed = b.unhealthy == healthy
+	b.unhealthy = !healthy
+	return changed
+}
@@ -0,0 +1,258 @@
+// Package balancer distributes the requests of a route across multiple backends. Backends are taken out of rotation
+// by active health checks and by a circuit breaker that trips on 5xx responses and transport errors.
+package balancer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/owncloud/ocis/extensions/proxy/pkg/config"
+	"github.com/owncloud/ocis/ocis-pkg/log"
+)
+
+var (
+	// ErrNoBackendAvailable is returned when all backends of a pool are unhealthy or ejected.
+	ErrNoBackendAvailable = errors.New("no backend available")
+	// ErrUnknownStrategy is returned for an unsupported balancer strategy.
+	ErrUnknownStrategy = errors.New("unknown balancer strategy")
+	// ErrContextNotCancellable is returned when the health checks are started with a context that is never done.
+	ErrContextNotCancellable = errors.New("the health checks need a cancellable context")
+)
+
+const (
+	defaultHealthCheckInterval = 10 * time.Second
+	defaultHealthCheckTimeout  = 2 * time.Second
+	defaultMaxFailures         = 5
+	defaultOpenTimeout         = 30 * time.Second
+)
+
+type circuitBreaker struct {
+	maxFailures int
+	openTimeout time.Duration
+}
+
+type contextKey struct{}
+
+// WithBackend returns a context carrying the backend picked for a request. The Transport records the outcome of
+// the request for the backend.
+func WithBackend(ctx context.Context, b *Backend) context.Context {
+	return context.WithValue(ctx, contextKey{}, b)
+}
+
+// Transport is a http.RoundTripper which records the outcome of the requests to balanced backends. Requests
+// without a backend in their context are passed through unchanged.
+type Transport struct {
+	base   http.RoundTripper
+	logger log.Logger
+}
+
+// NewTransport wraps the given http.RoundTripper.
+func NewTransport(base http.RoundTripper, logger log.Logger) *Transport {
+	return &Transport{
+		base:   base,
+		logger: logger,
+	}
+}
+
+// NewPool creates a pool for the given backend urls. Every pool has its own backends, even if an upstream is used
+// by several routes, so that each keeps the circuit breaker and health check configuration of its route.
+func (t *Transport) NewPool(backends []string, cfg config.LoadBalancer) (*Pool, error) {
+	strategy := cfg.Strategy
+	if strategy == "" {
+		strategy = config.DefaultBalancerStrategy
+	}
+	if strategy != config.RoundRobinStrategy && strategy != config.LeastConnectionsStrategy {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownStrategy, strategy)
+	}
+
+	cb := circuitBreaker{
+		maxFailures: cfg.CircuitBreaker.MaxFailures,
+		openTimeout: time.Duration(cfg.CircuitBreaker.OpenTimeout) * time.Second,
+	}
+	if cb.maxFailures <= 0 {
+		cb.maxFailures = defaultMaxFailures
+	}
+	if cb.openTimeout <= 0 {
+		cb.openTimeout = defaultOpenTimeout
+	}
+
+	p := &Pool{
+		strategy:    strategy,
+		healthCheck: cfg.HealthCheck,
+		logger:      t.logger,
+	}
+
+	for _, raw := range backends {
+		u, err := url.Parse(raw)
+		if err != nil {
+			return nil, err
+		}
+		if u.Host == "" {
+			return nil, fmt.Errorf("backend %q has no host", raw)
+		}
+		p.backends = append(p.backends, newBackend(u, cb))
+	}
+	return p, nil
+}
+
+// RoundTrip implements the http.RoundTripper interface.
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	b, ok := req.Context().Value(contextKey{}).(*Backend)
+	if !ok {
+		return t.base.RoundTrip(req)
+	}
+
+	b.begin()
+	res, err := t.base.RoundTrip(req)
+	switch {
+	case err != nil && req.Context().Err() != nil:
+		// the client went away, this says nothing about the backend
+		b.abort()
+	case err != nil:
+		b.failure()
+	case res.StatusCode >= http.StatusInternalServerError:
+		b.failure()
+	default:
+		b.success()
+	}
+	if err != nil {
+		b.end()
+		t.logger.Debug().Err(err).Str("backend", b.URL.String()).Msg("request to backend failed")
+		return nil, err
+	}
+
+	if res.StatusCode == http.StatusSwitchingProtocols {
+		// the upgraded connection must stay writable, it is not tracked any further
+		b.end()
+		return res, nil
+	}
+	res.Body = &trackingBody{ReadCloser: res.Body, backend: b}
+	return res, nil
+}
+
+// Pool is the set of backends of a single route.
+type Pool struct {
+	backends    []*Backend
+	strategy    config.BalancerStrategy
+	healthCheck config.HealthCheck
+	logger      log.Logger
+
+	next uint64
+}
+
+// Next picks the backend for the next request according to the configured strategy. The request must be sent with
+// the backend in its context, see WithBackend.
+func (p *Pool) Next() (*Backend, error) {
+	n := len(p.backends)
+	offset := int(atomic.AddUint64(&p.next, 1) - 1)
+
+	// a backend can become unavailable between picking and acquiring it, e.g. when another request became the trial
+	// request of its circuit, then the next one is picked
+	skipped := make([]bool, n)
+	for attempt := 0; attempt < n; attempt++ {
+		picked := -1
+		for i := 0; i < n; i++ {
+			j := (offset + i) % n
+			b := p.backends[j]
+			if skipped[j] || !b.Available() {
+				continue
+			}
+			if p.strategy == config.RoundRobinStrategy {
+				picked = j
+				break
+			}
+			if picked < 0 || b.Active() < p.backends[picked].Active() {
+				picked = j
+			}
+		}
+		if picked < 0 {
+			break
+		}
+		if p.backends[picked].acquire() {
+			return p.backends[picked], nil
+		}
+		skipped[picked] = true
+	}
+	return nil, ErrNoBackendAvailable
+}
+
+// Start runs the active health checks of the pool until the context is cancelled. It is a noop if no health check
+// path is configured. The context must be cancellable, so that the health checks are stopped.
+func (p *Pool) Start(ctx context.Context, client *http.Client) error {
+	if p.healthCheck.Path == "" {
+		return nil
+	}
+	if ctx == nil || ctx.Done() == nil {
+		return ErrContextNotCancellable
+	}
+	interval := time.Duration(p.healthCheck.Interval) * time.Second
+	if interval <= 0 {
+		interval = defaultHealthCheckInterval
+	}
+	timeout := time.Duration(p.healthCheck.Timeout) * time.Second
+	if timeout <= 0 {
+		timeout = defaultHealthCheckTimeout
+	}
+
+	for _, b := range p.backends {
+		go p.check(ctx, client, b, interval, timeout)
+	}
+	return nil
+}
+
+func (p *Pool) check(ctx context.Context, client *http.Client, b *Backend, interval, timeout time.Duration) {
+	target := *b.URL
+	target.Path = singleJoiningSlash(target.Path, p.healthCheck.Path)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		healthy := probe(ctx, client, target.String(), timeout)
+		if b.setHealthy(healthy) {
+			p.logger.Info().
+				Str("backend", b.URL.String()).
+				Bool("healthy", healthy).
+				Msg("backend health changed")
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func probe(ctx context.Context, client *http.Client, target string, timeout time.Duration) bool {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, target, nil)
+	if err != nil {
+		return false
+	}
+	res, err := client.Do(req)
+	if err != nil {
+		return false
+	}
+	defer res.Body.Close()
+	return res.StatusCode < http.StatusBadRequest
+}
+
+func singleJoiningSlash(a, b string) string {
+	aslash := strings.HasSuffix(a, "/")
+	bslash := strings.HasPrefix(b, "/")
+	switch {
+	case aslash && bslash:
+		return a + b[1:]
+	case !aslash && !bslash:
+		return a + "/" + b
+	}
+	return a + b
+}
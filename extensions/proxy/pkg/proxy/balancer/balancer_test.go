@@ -0,0 +1,257 @@
+package balancer
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/owncloud/ocis/extensions/proxy/pkg/config"
+	"github.com/owncloud/ocis/ocis-pkg/log"
+)
+
+type roundTripFunc func(req *http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+func respond(status int) roundTripFunc {
+	return func(req *http.Request) (*http.Response, error) {
+		return &http.Response{
+			StatusCode: status,
+			Body:       ioutil.NopCloser(bytes.NewBufferString("")),
+			Header:     make(http.Header),
+		}, nil
+	}
+}
+
+func request(b *Backend) *http.Request {
+	req := httptest.NewRequest(http.MethodGet, b.URL.String()+"/", nil)
+	return req.WithContext(WithBackend(req.Context(), b))
+}
+
+func do(t *testing.T, tr http.RoundTripper, b *Backend) {
+	res, err := tr.RoundTrip(request(b))
+	if err == nil {
+		res.Body.Close()
+	}
+}
+
+func hosts(t *testing.T, p *Pool, n int) []string {
+	var got []string
+	for i := 0; i < n; i++ {
+		b, err := p.Next()
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		got = append(got, b.URL.Host)
+	}
+	return got
+}
+
+func TestRoundRobin(t *testing.T) {
+	tr := NewTransport(respond(http.StatusOK), log.NewLogger())
+	p, err := tr.NewPool([]string{"http://a", "http://b", "http://c"}, config.LoadBalancer{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got := hosts(t, p, 4)
+	want := []string{"a", "b", "c", "a"}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("expected %v got %v", want, got)
+		}
+	}
+}
+
+func TestLeastConnections(t *testing.T) {
+	tr := NewTransport(respond(http.StatusOK), log.NewLogger())
+	p, err := tr.NewPool([]string{"http://a", "http://b"}, config.LoadBalancer{
+		Strategy: config.LeastConnectionsStrategy,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// keep a request to a in flight
+	res, err := tr.RoundTrip(request(p.backends[0]))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, h := range hosts(t, p, 3) {
+		if h != "b" {
+			t.Fatalf("expected b got %s", h)
+		}
+	}
+
+	res.Body.Close()
+	if active := p.backends[0].Active(); active != 0 {
+		t.Fatalf("expected no active requests got %d", active)
+	}
+}
+
+func TestUnknownStrategy(t *testing.T) {
+	tr := NewTransport(respond(http.StatusOK), log.NewLogger())
+	_, err := tr.NewPool([]string{"http://a"}, config.LoadBalancer{Strategy: "random"})
+	if !errors.Is(err, ErrUnknownStrategy) {
+		t.Fatalf("expected ErrUnknownStrategy got %v", err)
+	}
+}
+
+func TestCircuitBreaker(t *testing.T) {
+	status := http.StatusInternalServerError
+	tr := NewTransport(roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		return respond(status)(req)
+	}), log.NewLogger())
+	p, err := tr.NewPool([]string{"http://a", "http://b"}, config.LoadBalancer{
+		CircuitBreaker: config.CircuitBreaker{MaxFailures: 2},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	a := p.backends[0]
+
+	do(t, tr, a)
+	if !a.Available() {
+		t.Fatal("backend must not be ejected before reaching max failures")
+	}
+	do(t, tr, a)
+	if a.Available() {
+		t.Fatal("backend must be ejected after max failures")
+	}
+	for _, h := range hosts(t, p, 3) {
+		if h != "b" {
+			t.Fatalf("expected b got %s", h)
+		}
+	}
+
+	// let the open timeout pass, a single trial request is admitted
+	a.openedAt = time.Now().Add(-defaultOpenTimeout)
+	if !a.Available() {
+		t.Fatal("backend must be available for a trial request")
+	}
+	var wg sync.WaitGroup
+	var trials int32
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if a.acquire() {
+				atomic.AddInt32(&trials, 1)
+			}
+		}()
+	}
+	wg.Wait()
+	if trials != 1 {
+		t.Fatalf("only a single trial request must be admitted, got %d", trials)
+	}
+	if a.Available() {
+		t.Fatal("no request must be admitted while the trial request is in flight")
+	}
+	a.failure()
+	if a.Available() {
+		t.Fatal("failed trial request must reopen the circuit")
+	}
+
+	a.openedAt = time.Now().Add(-defaultOpenTimeout)
+	status = http.StatusOK
+	do(t, tr, a)
+	if !a.Available() {
+		t.Fatal("successful trial request must close the circuit")
+	}
+}
+
+func TestPoolsOwnBackends(t *testing.T) {
+	tr := NewTransport(respond(http.StatusInternalServerError), log.NewLogger())
+	strict, err := tr.NewPool([]string{"http://a"}, config.LoadBalancer{
+		CircuitBreaker: config.CircuitBreaker{MaxFailures: 1},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	lenient, err := tr.NewPool([]string{"http://a"}, config.LoadBalancer{
+		CircuitBreaker: config.CircuitBreaker{MaxFailures: 3},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	do(t, tr, strict.backends[0])
+	if strict.backends[0].Available() {
+		t.Fatal("backend must be ejected after max failures of its pool")
+	}
+	do(t, tr, lenient.backends[0])
+	if !lenient.backends[0].Available() {
+		t.Fatal("the same upstream in another pool must keep the circuit breaker configuration of that pool")
+	}
+}
+
+func TestNoBackendAvailable(t *testing.T) {
+	tr := NewTransport(roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		return nil, errors.New("connection refused")
+	}), log.NewLogger())
+	p, err := tr.NewPool([]string{"http://a"}, config.LoadBalancer{
+		CircuitBreaker: config.CircuitBreaker{MaxFailures: 1},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	do(t, tr, p.backends[0])
+	if _, err := p.Next(); !errors.Is(err, ErrNoBackendAvailable) {
+		t.Fatalf("expected ErrNoBackendAvailable got %v", err)
+	}
+}
+
+func TestHealthCheck(t *testing.T) {
+	healthy := make(chan bool, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/app/healthz" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.WriteHeader(http.StatusServiceUnavailable)
+		healthy <- false
+	}))
+	defer srv.Close()
+
+	tr := NewTransport(http.DefaultTransport, log.NewLogger())
+	p, err := tr.NewPool([]string{srv.URL + "/app"}, config.LoadBalancer{
+		HealthCheck: config.HealthCheck{Path: "/healthz"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := p.Start(context.Background(), srv.Client()); !errors.Is(err, ErrContextNotCancellable) {
+		t.Fatalf("expected ErrContextNotCancellable got %v", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	if err := p.Start(ctx, srv.Client()); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case <-healthy:
+	case <-time.After(5 * time.Second):
+		t.Fatal("health check was not performed")
+	}
+	// wait for the result to be recorded
+	deadline := time.Now().Add(5 * time.Second)
+	for p.backends[0].Available() {
+		if time.Now().After(deadline) {
+			t.Fatal("backend failing the health check must not be available")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
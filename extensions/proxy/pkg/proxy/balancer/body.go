@@ -0,0 +1,19 @@
+package balancer
+
+import (
+	"io"
+	"sync"
+)
+
+// trackingBody keeps a request counted as in flight until the response body has been consumed.
+type trackingBody struct {
+	io.ReadCloser
+	backend *Backend
+	once    sync.Once
+}
+
+func (t *trackingBody) Close() error {
+	err := t.ReadCloser.Close()
+	t.once.Do(t.backend.end)
+	return err
+}
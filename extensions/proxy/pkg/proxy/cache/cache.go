@@ -0,0 +1,343 @@
+// Package cache implements a http response cache for proxy routes serving read-mostly content. It honors the
+// Cache-Control, Expires, ETag, Last-Modified and Vary headers of the backend responses. Responses to authenticated
+// requests are cached per user unless the backend marks them as public.
+package cache
+
+import (
+	"bytes"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/owncloud/ocis/extensions/proxy/pkg/config"
+)
+
+// Cache serves cacheable requests from a Store.
+type Cache struct {
+	store        Store
+	maxEntrySize int64
+	now          func() time.Time
+}
+
+// New creates a cache according to the configuration.
+func New(cfg config.ResponseCache) (*Cache, error) {
+	var store Store
+	switch cfg.Store {
+	case "disk":
+		s, err := NewDiskStore(cfg.Path, cfg.MaxSize)
+		if err != nil {
+			return nil, err
+		}
+		store = s
+	default:
+		store = NewMemoryStore(cfg.MaxSize)
+	}
+	return NewWithStore(store, cfg.MaxEntrySize), nil
+}
+
+// NewWithStore creates a cache using the given store.
+func NewWithStore(store Store, maxEntrySize int64) *Cache {
+	return &Cache{
+		store:        store,
+		maxEntrySize: maxEntrySize,
+		now:          time.Now,
+	}
+}
+
+// ServeHTTP serves the request from the cache or passes it on to next and caches the response. The scope separates
+// the entries of different routes, so that the same URL proxied to different backends is cached independently. The
+// user is the id of the authenticated user or empty for anonymous requests.
+func (c *Cache) ServeHTTP(w http.ResponseWriter, r *http.Request, scope, user string, next http.Handler) {
+	reqCC := parseCacheControl(r.Header.Get("Cache-Control"))
+	if r.Method != http.MethodGet || reqCC.has("no-store") || r.Header.Get("Range") != "" || r.Header.Get("Upgrade") != "" {
+		next.ServeHTTP(w, r)
+		return
+	}
+	if user == "" && r.Header.Get("Authorization") != "" {
+		// authenticated by a mechanism we cannot attribute to a user
+		next.ServeHTTP(w, r)
+		return
+	}
+
+	sharedKey := scope + " " + r.Host + r.URL.RequestURI()
+	userKey := sharedKey
+	if user != "" {
+		userKey = user + "@" + sharedKey
+	}
+
+	key, e := c.lookup(r, userKey, sharedKey)
+	now := c.now()
+	if e != nil && !reqCC.has("no-cache") && now.Before(e.Expires) {
+		c.serve(w, r, e, now)
+		return
+	}
+
+	revalidating := ""
+	if e != nil && r.Header.Get("If-None-Match") == "" && r.Header.Get("If-Modified-Since") == "" {
+		// revalidate the stale entry, conditional requests of the client are passed through unchanged
+		if etag := e.Header.Get("ETag"); etag != "" {
+			revalidating = "If-None-Match"
+			r.Header.Set(revalidating, etag)
+		} else if lm := e.Header.Get("Last-Modified"); lm != "" {
+			revalidating = "If-Modified-Since"
+			r.Header.Set(revalidating, lm)
+		}
+	}
+
+	rec := &recorder{ResponseWriter: w, revalidating: revalidating != "", maxSize: c.maxEntrySize}
+	next.ServeHTTP(rec, r)
+	if revalidating != "" {
+		r.Header.Del(revalidating)
+	}
+
+	if rec.notModified {
+		// the stale entry is still valid, refresh it and serve it
+		refreshed := *e
+		refreshed.Header = e.Header.Clone()
+		for _, h := range []string{"Cache-Control", "Expires", "Date", "ETag", "Last-Modified"} {
+			if v := rec.header.Get(h); v != "" {
+				refreshed.Header.Set(h, v)
+			}
+		}
+		refreshed.Stored = now
+		refreshed.Expires = now.Add(freshness(refreshed.Header, now))
+		c.store.Set(key, &refreshed)
+		c.serve(w, r, &refreshed, now)
+		return
+	}
+
+	if rec.skip || rec.overflow {
+		if e != nil {
+			c.store.Delete(key)
+		}
+		return
+	}
+
+	resCC := parseCacheControl(rec.Header().Get("Cache-Control"))
+	if !cacheable(rec.status, rec.Header(), resCC) || (user == "" && resCC.has("private")) {
+		return
+	}
+	key = sharedKey
+	if user != "" && !resCC.has("public") && !resCC.has("s-maxage") {
+		key = userKey
+	}
+	c.store.Set(key, &Entry{
+		Status:  rec.status,
+		Header:  rec.Header().Clone(),
+		Body:    rec.body.Bytes(),
+		Stored:  now,
+		Expires: now.Add(freshness(rec.Header(), now)),
+		Vary:    varyValues(rec.Header(), r),
+	})
+}
+
+// lookup finds the entry for the request, preferring the per user entry.
+func (c *Cache) lookup(r *http.Request, keys ...string) (string, *Entry) {
+	for _, key := range keys {
+		e, ok := c.store.Get(key)
+		if !ok {
+			continue
+		}
+		if !varyMatches(e, r) {
+			continue
+		}
+		return key, e
+	}
+	return keys[0], nil
+}
+
+// serve writes a cached entry, answering conditional requests with 304.
+func (c *Cache) serve(w http.ResponseWriter, r *http.Request, e *Entry, now time.Time) {
+	h := w.Header()
+	for k, v := range e.Header {
+		h[k] = v
+	}
+	h.Set("Age", strconv.Itoa(int(now.Sub(e.Stored).Seconds())))
+
+	if inm := r.Header.Get("If-None-Match"); inm != "" && etagMatches(inm, e.Header.Get("ETag")) {
+		h.Del("Content-Length")
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	h.Set("Content-Length", strconv.Itoa(len(e.Body)))
+	w.WriteHeader(e.Status)
+	_, _ = w.Write(e.Body)
+}
+
+// recorder passes the response through to the client while keeping a copy of the body. When revalidating a 304 is
+// swallowed, so that the cached entry can be served instead.
+type recorder struct {
+	http.ResponseWriter
+	revalidating bool
+	maxSize      int64
+
+	status      int
+	header      http.Header
+	body        bytes.Buffer
+	wroteHeader bool
+	notModified bool
+	skip        bool
+	overflow    bool
+}
+
+func (r *recorder) Header() http.Header {
+	if r.notModified {
+		return r.header
+	}
+	return r.ResponseWriter.Header()
+}
+
+func (r *recorder) WriteHeader(status int) {
+	if r.wroteHeader {
+		return
+	}
+	r.wroteHeader = true
+	r.status = status
+	if r.revalidating && status == http.StatusNotModified {
+		r.notModified = true
+		r.header = r.ResponseWriter.Header().Clone()
+		for k := range r.ResponseWriter.Header() {
+			r.ResponseWriter.Header().Del(k)
+		}
+		return
+	}
+	if status != http.StatusOK && status != http.StatusNonAuthoritativeInfo && status != http.StatusMovedPermanently &&
+		status != http.StatusNotFound && status != http.StatusGone {
+		r.skip = true
+	}
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *recorder) Write(b []byte) (int, error) {
+	if !r.wroteHeader {
+		r.WriteHeader(http.StatusOK)
+	}
+	if r.notModified {
+		return len(b), nil
+	}
+	if !r.skip && !r.overflow {
+		if int64(r.body.Len()+len(b)) > r.maxSize {
+			r.overflow = true
+			r.body = bytes.Buffer{}
+		} else {
+			r.body.Write(b)
+		}
+	}
+	return r.ResponseWriter.Write(b)
+}
+
+func (r *recorder) Flush() {
+	if f, ok := r.ResponseWriter.(http.Flusher); ok && !r.notModified {
+		f.Flush()
+	}
+}
+
+// cacheable reports if a response may be stored.
+func cacheable(status int, h http.Header, cc cacheControl) bool {
+	if cc.has("no-store") || h.Get("Vary") == "*" || h.Get("Set-Cookie") != "" {
+		return false
+	}
+	if cc.has("max-age") || cc.has("s-maxage") || h.Get("Expires") != "" {
+		return true
+	}
+	// without explicit freshness the response is only useful for revalidation
+	return status == http.StatusOK && (h.Get("ETag") != "" || h.Get("Last-Modified") != "")
+}
+
+// freshness returns how long a response is fresh. Responses with no-cache are stored but always revalidated.
+func freshness(h http.Header, now time.Time) time.Duration {
+	cc := parseCacheControl(h.Get("Cache-Control"))
+	if cc.has("no-cache") {
+		return 0
+	}
+	if v, ok := cc["s-maxage"]; ok {
+		return seconds(v)
+	}
+	if v, ok := cc["max-age"]; ok {
+		return seconds(v)
+	}
+	if expires := h.Get("Expires"); expires != "" {
+		t, err := http.ParseTime(expires)
+		if err != nil {
+			return 0
+		}
+		date := now
+		if d, err := http.ParseTime(h.Get("Date")); err == nil {
+			date = d
+		}
+		if t.After(date) {
+			return t.Sub(date)
+		}
+	}
+	return 0
+}
+
+func seconds(v string) time.Duration {
+	n, err := strconv.ParseInt(v, 10, 64)
+	if err != nil || n < 0 {
+		return 0
+	}
+	return time.Duration(n) * time.Second
+}
+
+func varyValues(h http.Header, r *http.Request) map[string]string {
+	values := map[string]string{}
+	for _, v := range h.Values("Vary") {
+		for _, name := range strings.Split(v, ",") {
+			name = http.CanonicalHeaderKey(strings.TrimSpace(name))
+			if name != "" {
+				values[name] = r.Header.Get(name)
+			}
+		}
+	}
+	return values
+}
+
+func varyMatches(e *Entry, r *http.Request) bool {
+	for name, value := range e.Vary {
+		if r.Header.Get(name) != value {
+			return false
+		}
+	}
+	return true
+}
+
+func etagMatches(header, etag string) bool {
+	if etag == "" {
+		return false
+	}
+	if strings.TrimSpace(header) == "*" {
+		return true
+	}
+	weak := strings.TrimPrefix(etag, "W/")
+	for _, candidate := range strings.Split(header, ",") {
+		if strings.TrimPrefix(strings.TrimSpace(candidate), "W/") == weak {
+			return true
+		}
+	}
+	return false
+}
+
+type cacheControl map[string]string
+
+func parseCacheControl(v string) cacheControl {
+	cc := cacheControl{}
+	for _, directive := range strings.Split(v, ",") {
+		directive = strings.TrimSpace(directive)
+		if directive == "" {
+			continue
+		}
+		name, value := directive, ""
+		if i := strings.Index(directive, "="); i >= 0 {
+			name, value = directive[:i], strings.Trim(directive[i+1:], `"`)
+		}
+		cc[strings.ToLower(name)] = value
+	}
+	return cc
+}
+
+func (cc cacheControl) has(directive string) bool {
+	_, ok := cc[directive]
+	return ok
+}
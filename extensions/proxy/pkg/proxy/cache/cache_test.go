@@ -0,0 +1,259 @@
+package cache
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// backend counts the requests and answers with the configured headers.
+type backend struct {
+	calls  int
+	header http.Header
+	body   string
+	etag   string
+}
+
+func (b *backend) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	b.calls++
+	for k, v := range b.header {
+		w.Header()[k] = v
+	}
+	if b.etag != "" {
+		w.Header().Set("ETag", b.etag)
+		if r.Header.Get("If-None-Match") == b.etag {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+	}
+	_, _ = w.Write([]byte(b.body))
+}
+
+func get(c *Cache, b http.Handler, user string, header map[string]string) *httptest.ResponseRecorder {
+	return getScoped(c, b, "default /", user, header)
+}
+
+func getScoped(c *Cache, b http.Handler, scope, user string, header map[string]string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(http.MethodGet, "https://cloud.example.com/config.json", nil)
+	for k, v := range header {
+		r.Header.Set(k, v)
+	}
+	w := httptest.NewRecorder()
+	c.ServeHTTP(w, r, scope, user, b)
+	return w
+}
+
+func TestFreshResponseIsServedFromCache(t *testing.T) {
+	c := NewWithStore(NewMemoryStore(1024), 1024)
+	b := &backend{header: http.Header{"Cache-Control": {"max-age=60"}}, body: "config"}
+
+	get(c, b, "", nil)
+	w := get(c, b, "", nil)
+	if b.calls != 1 {
+		t.Errorf("expected 1 backend call got %d", b.calls)
+	}
+	if w.Body.String() != "config" || w.Code != http.StatusOK {
+		t.Errorf("unexpected cached response %d %s", w.Code, w.Body.String())
+	}
+	if w.Header().Get("Age") == "" {
+		t.Error("expected an Age header on cached responses")
+	}
+
+	// the client may bypass the cache
+	get(c, b, "", map[string]string{"Cache-Control": "no-cache"})
+	if b.calls != 2 {
+		t.Errorf("expected 2 backend calls got %d", b.calls)
+	}
+}
+
+func TestStaleResponseIsRevalidated(t *testing.T) {
+	c := NewWithStore(NewMemoryStore(1024), 1024)
+	now := time.Now()
+	c.now = func() time.Time { return now }
+	b := &backend{header: http.Header{"Cache-Control": {"max-age=10"}}, body: "thumbnail", etag: `"v1"`}
+
+	get(c, b, "", nil)
+	now = now.Add(time.Minute)
+
+	w := get(c, b, "", nil)
+	if b.calls != 2 {
+		t.Errorf("expected 2 backend calls got %d", b.calls)
+	}
+	if w.Code != http.StatusOK || w.Body.String() != "thumbnail" {
+		t.Errorf("expected the revalidated entry to be served, got %d %s", w.Code, w.Body.String())
+	}
+
+	// the revalidation refreshed the entry
+	get(c, b, "", nil)
+	if b.calls != 2 {
+		t.Errorf("expected 2 backend calls got %d", b.calls)
+	}
+
+	// conditional requests of clients are answered from the cache
+	w = get(c, b, "", map[string]string{"If-None-Match": `"v1"`})
+	if w.Code != http.StatusNotModified {
+		t.Errorf("expected 304 got %d", w.Code)
+	}
+}
+
+func TestResponsesArePerUser(t *testing.T) {
+	c := NewWithStore(NewMemoryStore(1024), 1024)
+	b := &backend{header: http.Header{"Cache-Control": {"private, max-age=60"}}, body: "capabilities"}
+
+	get(c, b, "einstein", nil)
+	get(c, b, "einstein", nil)
+	if b.calls != 1 {
+		t.Errorf("expected 1 backend call got %d", b.calls)
+	}
+	get(c, b, "marie", nil)
+	if b.calls != 2 {
+		t.Errorf("expected the entry of another user not to be served, got %d backend calls", b.calls)
+	}
+
+	// private responses to anonymous requests are not stored
+	get(c, b, "", nil)
+	get(c, b, "", nil)
+	if b.calls != 4 {
+		t.Errorf("expected 4 backend calls got %d", b.calls)
+	}
+
+	// public responses are shared
+	b.header.Set("Cache-Control", "public, max-age=60")
+	c = NewWithStore(NewMemoryStore(1024), 1024)
+	get(c, b, "einstein", nil)
+	get(c, b, "marie", nil)
+	if b.calls != 5 {
+		t.Errorf("expected 5 backend calls got %d", b.calls)
+	}
+}
+
+func TestResponsesArePerScope(t *testing.T) {
+	c := NewWithStore(NewMemoryStore(1024), 1024)
+	a := &backend{header: http.Header{"Cache-Control": {"public, max-age=60"}}, body: "a"}
+	b := &backend{header: http.Header{"Cache-Control": {"public, max-age=60"}}, body: "b"}
+
+	getScoped(c, a, "default /a", "", nil)
+	w := getScoped(c, b, "default /b", "", nil)
+	if b.calls != 1 || w.Body.String() != "b" {
+		t.Errorf("expected the route of b not to be served from the cache of a got %d calls %s", b.calls, w.Body.String())
+	}
+	w = getScoped(c, a, "oidc /a", "", nil)
+	if a.calls != 2 || w.Body.String() != "a" {
+		t.Errorf("expected the policies not to share entries got %d calls", a.calls)
+	}
+}
+
+func TestUncacheableResponses(t *testing.T) {
+	tests := []struct {
+		name   string
+		header http.Header
+		req    map[string]string
+	}{
+		{"no-store", http.Header{"Cache-Control": {"no-store, max-age=60"}}, nil},
+		{"no freshness", http.Header{}, nil},
+		{"vary all", http.Header{"Cache-Control": {"max-age=60"}, "Vary": {"*"}}, nil},
+		{"cookie", http.Header{"Cache-Control": {"max-age=60"}, "Set-Cookie": {"a=b"}}, nil},
+		{"request no-store", http.Header{"Cache-Control": {"max-age=60"}}, map[string]string{"Cache-Control": "no-store"}},
+		{"unattributed authorization", http.Header{"Cache-Control": {"max-age=60"}}, map[string]string{"Authorization": "Basic Zm9vOmJhcg=="}},
+	}
+
+	for _, tt := range tests {
+		c := NewWithStore(NewMemoryStore(1024), 1024)
+		b := &backend{header: tt.header, body: "body"}
+		get(c, b, "", tt.req)
+		get(c, b, "", tt.req)
+		if b.calls != 2 {
+			t.Errorf("%s: expected 2 backend calls got %d", tt.name, b.calls)
+		}
+	}
+}
+
+func TestVary(t *testing.T) {
+	c := NewWithStore(NewMemoryStore(1024), 1024)
+	b := &backend{header: http.Header{"Cache-Control": {"max-age=60"}, "Vary": {"Accept-Language"}}, body: "index"}
+
+	get(c, b, "", map[string]string{"Accept-Language": "de"})
+	get(c, b, "", map[string]string{"Accept-Language": "de"})
+	if b.calls != 1 {
+		t.Errorf("expected 1 backend call got %d", b.calls)
+	}
+	get(c, b, "", map[string]string{"Accept-Language": "en"})
+	if b.calls != 2 {
+		t.Errorf("expected 2 backend calls got %d", b.calls)
+	}
+}
+
+func TestMaxEntrySize(t *testing.T) {
+	c := NewWithStore(NewMemoryStore(1024), 4)
+	b := &backend{header: http.Header{"Cache-Control": {"max-age=60"}}, body: "too large"}
+
+	w := get(c, b, "", nil)
+	get(c, b, "", nil)
+	if b.calls != 2 {
+		t.Errorf("expected 2 backend calls got %d", b.calls)
+	}
+	if w.Body.String() != "too large" {
+		t.Errorf("expected the full body to be passed through got %s", w.Body.String())
+	}
+}
+
+func TestDiskStoreKeepsForeignFiles(t *testing.T) {
+	dir := t.TempDir()
+	foreign := filepath.Join(dir, "keep")
+	if err := os.WriteFile(foreign, []byte("keep"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	s, err := NewDiskStore(dir, 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.Set("a", &Entry{Status: 200, Body: make([]byte, 10)})
+
+	// a restart removes the entries of the previous run only
+	if _, err := NewDiskStore(dir, 100); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(foreign); err != nil {
+		t.Errorf("expected files not created by the store to be kept: %v", err)
+	}
+	entries, err := os.ReadDir(filepath.Join(dir, diskStoreDir))
+	if err != nil || len(entries) != 0 {
+		t.Errorf("expected the entries of the previous run to be removed got %v %v", entries, err)
+	}
+}
+
+func TestStores(t *testing.T) {
+	disk, err := NewDiskStore(t.TempDir(), 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stores := map[string]Store{
+		"memory": NewMemoryStore(100),
+		"disk":   disk,
+	}
+
+	for name, s := range stores {
+		s.Set("a", &Entry{Status: 200, Body: make([]byte, 40)})
+		s.Set("b", &Entry{Status: 200, Body: make([]byte, 40)})
+		if _, ok := s.Get("a"); !ok {
+			t.Errorf("%s: expected a to be stored", name)
+		}
+		// a was used recently, so b is evicted
+		s.Set("c", &Entry{Status: 200, Body: make([]byte, 40)})
+		if _, ok := s.Get("b"); ok {
+			t.Errorf("%s: expected b to be evicted", name)
+		}
+		e, ok := s.Get("a")
+		if !ok || e.Status != 200 || len(e.Body) != 40 {
+			t.Errorf("%s: expected a to be kept", name)
+		}
+		s.Delete("a")
+		if _, ok := s.Get("a"); ok {
+			t.Errorf("%s: expected a to be deleted", name)
+		}
+	}
+}
@@ -0,0 +1,102 @@
+package cache
+
+import (
+	"crypto/sha256"
+	"encoding/gob"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// DiskStore keeps the cached responses in files. The index is only held in memory, so the files of previous runs are
+// removed when the store is created.
+type DiskStore struct {
+	dir string
+
+	mu  sync.Mutex
+	lru *lru
+}
+
+// diskStoreDir is the subdirectory of the configured directory holding the files of the store. Only this
+// subdirectory is removed on startup, everything else in the configured directory is left alone.
+const diskStoreDir = "proxy-response-cache"
+
+// NewDiskStore returns a disk store below dir holding up to maxSize bytes.
+func NewDiskStore(dir string, maxSize int64) (*DiskStore, error) {
+	dir = filepath.Join(dir, diskStoreDir)
+	if err := os.RemoveAll(dir); err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, err
+	}
+	s := &DiskStore{dir: dir}
+	s.lru = newLRU(maxSize, func(key string) {
+		_ = os.Remove(s.file(key))
+	})
+	return s, nil
+}
+
+func (s *DiskStore) file(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return filepath.Join(s.dir, hex.EncodeToString(sum[:]))
+}
+
+// Get implements the Store interface.
+func (s *DiskStore) Get(key string) (*Entry, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.lru.touch(key) {
+		return nil, false
+	}
+
+	f, err := os.Open(s.file(key))
+	if err != nil {
+		s.lru.remove(key)
+		return nil, false
+	}
+	defer f.Close()
+
+	e := &Entry{}
+	if err := gob.NewDecoder(f).Decode(e); err != nil {
+		s.lru.remove(key)
+		_ = os.Remove(s.file(key))
+		return nil, false
+	}
+	return e, true
+}
+
+// Set implements the Store interface.
+func (s *DiskStore) Set(key string, e *Entry) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// write to a temporary file first so that readers never see a partial entry
+	tmp, err := os.CreateTemp(s.dir, "tmp-")
+	if err != nil {
+		return
+	}
+	if err := gob.NewEncoder(tmp).Encode(e); err != nil {
+		tmp.Close()
+		_ = os.Remove(tmp.Name())
+		return
+	}
+	if err := tmp.Close(); err != nil {
+		_ = os.Remove(tmp.Name())
+		return
+	}
+	if err := os.Rename(tmp.Name(), s.file(key)); err != nil {
+		_ = os.Remove(tmp.Name())
+		return
+	}
+	s.lru.add(key, e.size())
+}
+
+// Delete implements the Store interface.
+func (s *DiskStore) Delete(key string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.lru.remove(key)
+	_ = os.Remove(s.file(key))
+}
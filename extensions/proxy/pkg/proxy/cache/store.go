@@ -0,0 +1,134 @@
+package cache
+
+import (
+	"container/list"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Entry is a cached response.
+type Entry struct {
+	Status int
+	Header http.Header
+	Body   []byte
+	// Stored is the time the response was received or last revalidated
+	Stored time.Time
+	// Expires is the time until the response is fresh
+	Expires time.Time
+	// Vary holds the values of the request headers listed in the Vary response header
+	Vary map[string]string
+}
+
+func (e *Entry) size() int64 {
+	size := int64(len(e.Body))
+	for k, values := range e.Header {
+		size += int64(len(k))
+		for _, v := range values {
+			size += int64(len(v))
+		}
+	}
+	return size
+}
+
+// Store persists cached responses.
+type Store interface {
+	Get(key string) (*Entry, bool)
+	Set(key string, e *Entry)
+	Delete(key string)
+}
+
+// lru keeps track of the entry sizes and evicts the least recently used keys once the max size is exceeded.
+type lru struct {
+	maxSize int64
+	size    int64
+	order   *list.List
+	items   map[string]*list.Element
+	onEvict func(key string)
+}
+
+type lruItem struct {
+	key  string
+	size int64
+}
+
+func newLRU(maxSize int64, onEvict func(key string)) *lru {
+	return &lru{
+		maxSize: maxSize,
+		order:   list.New(),
+		items:   make(map[string]*list.Element),
+		onEvict: onEvict,
+	}
+}
+
+func (l *lru) touch(key string) bool {
+	el, ok := l.items[key]
+	if ok {
+		l.order.MoveToFront(el)
+	}
+	return ok
+}
+
+func (l *lru) add(key string, size int64) {
+	l.remove(key)
+	l.items[key] = l.order.PushFront(&lruItem{key: key, size: size})
+	l.size += size
+	for l.size > l.maxSize && l.order.Len() > 0 {
+		oldest := l.order.Back().Value.(*lruItem)
+		l.remove(oldest.key)
+		l.onEvict(oldest.key)
+	}
+}
+
+func (l *lru) remove(key string) {
+	if el, ok := l.items[key]; ok {
+		l.size -= el.Value.(*lruItem).size
+		l.order.Remove(el)
+		delete(l.items, key)
+	}
+}
+
+// MemoryStore keeps the cached responses in memory.
+type MemoryStore struct {
+	mu      sync.Mutex
+	entries map[string]*Entry
+	lru     *lru
+}
+
+// NewMemoryStore returns a memory store holding up to maxSize bytes.
+func NewMemoryStore(maxSize int64) *MemoryStore {
+	s := &MemoryStore{
+		entries: make(map[string]*Entry),
+	}
+	s.lru = newLRU(maxSize, func(key string) {
+		delete(s.entries, key)
+	})
+	return s
+}
+
+// Get implements the Store interface.
+func (s *MemoryStore) Get(key string) (*Entry, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	e, ok := s.entries[key]
+	if ok {
+		s.lru.touch(key)
+	}
+	return e, ok
+}
+
+// Set implements the Store interface.
+func (s *MemoryStore) Set(key string, e *Entry) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.entries[key] = e
+	s.lru.add(key, e.size())
+}
+
+// Delete implements the Store interface.
+func (s *MemoryStore) Delete(key string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.entries, key)
+	s.lru.remove(key)
+}
@@ -1,7 +1,10 @@
 package proxy
 
 import (
+	"context"
+
 	"github.com/owncloud/ocis/extensions/proxy/pkg/config"
+	"github.com/owncloud/ocis/extensions/proxy/pkg/metrics"
 	"github.com/owncloud/ocis/ocis-pkg/log"
 )
 
@@ -10,8 +13,11 @@ type Option func(o *Options)
 
 // Options defines the available options for this package.
 type Options struct {
-	Logger log.Logger
-	Config *config.Config
+	Logger  log.Logger
+	Config  *config.Config
+	Metrics *metrics.Metrics
+	// Context stops the background tasks of the proxy, e.g. the health checks of balanced routes, when it is done
+	Context context.Context
 }
 
 // newOptions initializes the available default options.
@@ -38,3 +44,17 @@ func Config(val *config.Config) Option {
 		o.Config = val
 	}
 }
+
+// Metrics provides a function to set the metrics option.
+func Metrics(val *metrics.Metrics) Option {
+	return func(o *Options) {
+		o.Metrics = val
+	}
+}
+
+// Context provides a function to set the context option.
+func Context(val context.Context) Option {
+	return func(o *Options) {
+		o.Context = val
+	}
+}
@@ -0,0 +1,273 @@
+package policy
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/google/cel-go/cel"
+	"github.com/google/cel-go/checker/decls"
+	"github.com/google/cel-go/common/types"
+	"github.com/google/cel-go/common/types/ref"
+	"github.com/google/cel-go/common/types/traits"
+	"github.com/google/cel-go/interpreter/functions"
+	"github.com/owncloud/ocis/ocis-pkg/oidc"
+	exprpb "google.golang.org/genproto/googleapis/api/expr/v1alpha1"
+	"google.golang.org/protobuf/proto"
+)
+
+// The expressions of the expression selector are written in the Common Expression Language (CEL) and have to evaluate
+// to true or false. They have access to the following variables:
+//
+//   path            the request path
+//   method          the request method
+//   host            the requested host
+//   ip              the client ip, see clientIP
+//   header          the first value of the request headers by canonical name, e.g. header["X-Forwarded-Proto"]
+//   claims          the oidc claims, e.g. claims.groups or claims["preferred_username"]
+//   authenticated   true if the request carries oidc claims
+//
+// Besides the CEL operators and functions like in, startsWith, endsWith, contains and matches, the function
+// inNetwork(ip, cidr) or inNetwork(ip, [cidr, ...]) tests whether the ip is in one of the networks. An expression
+// which fails to evaluate, e.g. because it reads a missing claim, is false.
+//
+// Example:
+//   "ocis-admins" in claims.groups && inNetwork(ip, "10.0.0.0/8") && !path.startsWith("/remote.php")
+
+// newExpressionEnv returns the environment declaring the variables and functions of the expressions.
+func newExpressionEnv() (*cel.Env, error) {
+	return cel.NewEnv(
+		cel.Declarations(
+			decls.NewVar("path", decls.String),
+			decls.NewVar("method", decls.String),
+			decls.NewVar("host", decls.String),
+			decls.NewVar("ip", decls.String),
+			decls.NewVar("header", decls.NewMapType(decls.String, decls.String)),
+			decls.NewVar("claims", decls.NewMapType(decls.String, decls.Dyn)),
+			decls.NewVar("authenticated", decls.Bool),
+			decls.NewFunction("inNetwork",
+				decls.NewOverload("inNetwork_string_string", []*exprpb.Type{decls.String, decls.String}, decls.Bool),
+				decls.NewOverload("inNetwork_string_list_string",
+					[]*exprpb.Type{decls.String, decls.NewListType(decls.String)}, decls.Bool),
+			),
+		),
+	)
+}
+
+// expression is a compiled expression.
+type expression struct {
+	program cel.Program
+}
+
+// compileExpression parses and type checks an expression. The networks passed to inNetwork are checked as well.
+func compileExpression(env *cel.Env, src string) (*expression, error) {
+	ast, issues := env.Compile(src)
+	if issues != nil && issues.Err() != nil {
+		return nil, issues.Err()
+	}
+	if !proto.Equal(ast.ResultType(), decls.Bool) {
+		return nil, fmt.Errorf("the expression evaluates to %v instead of a bool", ast.ResultType())
+	}
+	if err := checkNetworks(ast.Expr()); err != nil {
+		return nil, err
+	}
+	// OptOptimize also compiles constant regular expressions, so invalid ones are rejected here
+	program, err := env.Program(ast, cel.Functions(
+		&functions.Overload{Operator: "inNetwork_string_string", Binary: inNetwork},
+		&functions.Overload{Operator: "inNetwork_string_list_string", Binary: inNetwork},
+	), cel.EvalOptions(cel.OptOptimize))
+	if err != nil {
+		return nil, err
+	}
+	return &expression{program: program}, nil
+}
+
+// eval evaluates the expression against the request, anything but true is false.
+func (e *expression) eval(r *http.Request, trustedProxies []*net.IPNet) bool {
+	claims := oidc.FromContext(r.Context())
+	authenticated := claims != nil
+	if claims == nil {
+		claims = map[string]interface{}{}
+	}
+	header := make(map[string]string, len(r.Header))
+	for k, v := range r.Header {
+		if len(v) > 0 {
+			header[k] = v[0]
+		}
+	}
+	vars := map[string]interface{}{
+		"path":          r.URL.Path,
+		"method":        r.Method,
+		"host":          r.Host,
+		"ip":            clientIP(r, trustedProxies),
+		"header":        header,
+		"claims":        claims,
+		"authenticated": authenticated,
+	}
+	out, _, err := e.program.Eval(vars)
+	if err != nil {
+		return false
+	}
+	b, _ := out.Value().(bool)
+	return b
+}
+
+// inNetwork returns true if the ip is in the network or in one of the list of networks.
+func inNetwork(ipVal, networks ref.Val) ref.Val {
+	s, ok := ipVal.(types.String)
+	if !ok {
+		return types.MaybeNoSuchOverloadErr(ipVal)
+	}
+	ip := net.ParseIP(string(s))
+	if ip == nil {
+		return types.False
+	}
+
+	var cidrs []ref.Val
+	switch n := networks.(type) {
+	case types.String:
+		cidrs = append(cidrs, n)
+	case traits.Lister:
+		for it := n.Iterator(); it.HasNext() == types.True; {
+			cidrs = append(cidrs, it.Next())
+		}
+	default:
+		return types.MaybeNoSuchOverloadErr(networks)
+	}
+	for _, c := range cidrs {
+		cidr, ok := c.(types.String)
+		if !ok {
+			return types.MaybeNoSuchOverloadErr(c)
+		}
+		_, network, err := net.ParseCIDR(string(cidr))
+		if err != nil {
+			return types.NewErr("invalid network %q: %v", string(cidr), err)
+		}
+		if network.Contains(ip) {
+			return types.True
+		}
+	}
+	return types.False
+}
+
+// checkNetworks returns an error if a constant network passed to inNetwork isn't a valid CIDR.
+func checkNetworks(e *exprpb.Expr) error {
+	if e == nil {
+		return nil
+	}
+	var children []*exprpb.Expr
+	switch k := e.ExprKind.(type) {
+	case *exprpb.Expr_CallExpr:
+		if k.CallExpr.Function == "inNetwork" && len(k.CallExpr.Args) == 2 {
+			networks := []*exprpb.Expr{k.CallExpr.Args[1]}
+			if l := k.CallExpr.Args[1].GetListExpr(); l != nil {
+				networks = l.Elements
+			}
+			for _, n := range networks {
+				if c := n.GetConstExpr(); c != nil {
+					if _, _, err := net.ParseCIDR(c.GetStringValue()); err != nil {
+						return fmt.Errorf("invalid network passed to inNetwork: %w", err)
+					}
+				}
+			}
+		}
+		children = append(children, k.CallExpr.Target)
+		children = append(children, k.CallExpr.Args...)
+	case *exprpb.Expr_SelectExpr:
+		children = append(children, k.SelectExpr.Operand)
+	case *exprpb.Expr_ListExpr:
+		children = append(children, k.ListExpr.Elements...)
+	case *exprpb.Expr_StructExpr:
+		for _, entry := range k.StructExpr.Entries {
+			children = append(children, entry.GetMapKey(), entry.Value)
+		}
+	case *exprpb.Expr_ComprehensionExpr:
+		c := k.ComprehensionExpr
+		children = append(children, c.IterRange, c.AccuInit, c.LoopCondition, c.LoopStep, c.Result)
+	}
+	for _, child := range children {
+		if err := checkNetworks(child); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type peerAddrKey struct{}
+
+// ContextWithPeerAddr returns a context holding the address of the socket peer of a request. It has to be set before
+// the RemoteAddr of the request is replaced with an address taken from the forwarding headers.
+func ContextWithPeerAddr(ctx context.Context, addr string) context.Context {
+	return context.WithValue(ctx, peerAddrKey{}, addr)
+}
+
+// clientIP returns the ip of the client of a request. This is the ip of the socket peer, unless the peer is one of the
+// trusted proxies. Then the X-Forwarded-For header is read from the right and the first ip which is not a trusted proxy
+// is returned.
+func clientIP(r *http.Request, trustedProxies []*net.IPNet) string {
+	addr, ok := r.Context().Value(peerAddrKey{}).(string)
+	if !ok {
+		addr = r.RemoteAddr
+	}
+	ip, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		ip = addr
+	}
+	if !isTrusted(ip, trustedProxies) {
+		return ip
+	}
+
+	forwarded := strings.Split(strings.Join(r.Header.Values("X-Forwarded-For"), ","), ",")
+	for i := len(forwarded) - 1; i >= 0; i-- {
+		f := strings.TrimSpace(forwarded[i])
+		if net.ParseIP(f) == nil {
+			// an invalid entry can't be trusted, so neither can the entries before it
+			break
+		}
+		ip = f
+		if !isTrusted(f, trustedProxies) {
+			break
+		}
+	}
+	return ip
+}
+
+func isTrusted(ip string, trustedProxies []*net.IPNet) bool {
+	parsed := net.ParseIP(ip)
+	if parsed == nil {
+		return false
+	}
+	for _, n := range trustedProxies {
+		if n.Contains(parsed) {
+			return true
+		}
+	}
+	return false
+}
+
+// parseNetworks parses a list of CIDRs or single ips.
+func parseNetworks(cidrs []string) ([]*net.IPNet, error) {
+	networks := make([]*net.IPNet, 0, len(cidrs))
+	for _, c := range cidrs {
+		if !strings.Contains(c, "/") {
+			ip := net.ParseIP(c)
+			if ip == nil {
+				return nil, fmt.Errorf("invalid ip %q", c)
+			}
+			bits := 8 * net.IPv6len
+			if ip.To4() != nil {
+				ip, bits = ip.To4(), 8*net.IPv4len
+			}
+			networks = append(networks, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		_, n, err := net.ParseCIDR(c)
+		if err != nil {
+			return nil, err
+		}
+		networks = append(networks, n)
+	}
+	return networks, nil
+}
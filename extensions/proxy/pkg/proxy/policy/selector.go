@@ -16,9 +16,9 @@ import (
 
 var (
 	// ErrMultipleSelectors in case there is more then one selector configured.
-	ErrMultipleSelectors = fmt.Errorf("only one type of policy-selector (static, migration, claim or regex) can be configured")
+	ErrMultipleSelectors = fmt.Errorf("only one type of policy-selector (static, migration, claim, regex or expression) can be configured")
 	// ErrSelectorConfigIncomplete if policy_selector conf is missing
-	ErrSelectorConfigIncomplete = fmt.Errorf("missing either \"static\", \"migration\", \"claim\" or \"regex\" configuration in policy_selector config ")
+	ErrSelectorConfigIncomplete = fmt.Errorf("missing either \"static\", \"migration\", \"claim\", \"regex\" or \"expression\" configuration in policy_selector config ")
 	// ErrUnexpectedConfigError unexpected config error
 	ErrUnexpectedConfigError = fmt.Errorf("could not initialize policy-selector for given config")
 )
@@ -70,11 +70,14 @@ func LoadSelector(cfg *config.PolicySelector) (Selector, error) {
 	if cfg.Regex != nil {
 		selCount++
 	}
+	if cfg.Expression != nil {
+		selCount++
+	}
 	if selCount > 1 {
 		return nil, ErrMultipleSelectors
 	}
 
-	if cfg.Migration == nil && cfg.Static == nil && cfg.Claims == nil && cfg.Regex == nil {
+	if selCount == 0 {
 		return nil, ErrSelectorConfigIncomplete
 	}
 
@@ -102,6 +105,10 @@ func LoadSelector(cfg *config.PolicySelector) (Selector, error) {
 		return NewRegexSelector(cfg.Regex), nil
 	}
 
+	if cfg.Expression != nil {
+		return NewExpressionSelector(cfg.Expression)
+	}
+
 	return nil, ErrUnexpectedConfigError
 }
 
@@ -260,6 +267,54 @@ func NewRegexSelector(cfg *config.RegexSelectorConf) Selector {
 	}
 }
 
+// NewExpressionSelector selects the policy of the first rule whose CEL expression evaluates to true. The expressions
+// have access to the request path, method, host, headers, the client ip and the oidc claims, see expression.go for
+// the details. The client ip is only taken from the X-Forwarded-For header if the request comes from one of the
+// trusted proxies.
+// "policy_selector": {
+//    "expression": {
+//      "rules": [
+//        {"expression": "'canary' in claims.groups && inNetwork(ip, '10.0.0.0/8')", "policy": "canary"},
+//        {"expression": "path.startsWith('/legacy/')", "policy": "oc10"}
+//      ],
+//      "default_policy": "ocis",
+//      "trusted_proxies": ["10.0.0.1"]
+//    }
+//  },
+//
+// An error is returned if one of the expressions or trusted proxies cannot be parsed.
+func NewExpressionSelector(cfg *config.ExpressionSelectorConf) (Selector, error) {
+	trustedProxies, err := parseNetworks(cfg.TrustedProxies)
+	if err != nil {
+		return nil, fmt.Errorf("invalid trusted proxies: %w", err)
+	}
+	env, err := newExpressionEnv()
+	if err != nil {
+		return nil, err
+	}
+	rules := make([]expressionRule, 0, len(cfg.Rules))
+	for _, r := range cfg.Rules {
+		expr, err := compileExpression(env, r.Expression)
+		if err != nil {
+			return nil, fmt.Errorf("invalid expression %q for policy %s: %w", r.Expression, r.Policy, err)
+		}
+		rules = append(rules, expressionRule{expr: expr, policy: r.Policy})
+	}
+	return func(r *http.Request) (string, error) {
+		for _, rule := range rules {
+			if rule.expr.eval(r, trustedProxies) {
+				return rule.policy, nil
+			}
+		}
+		return cfg.DefaultPolicy, nil
+	}, nil
+}
+
+type expressionRule struct {
+	expr   *expression
+	policy string
+}
+
 type regexRule struct {
 	property string
 	rule     *regexp.Regexp
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 
 	userv1beta1 "github.com/cs3org/go-cs3apis/cs3/identity/user/v1beta1"
@@ -29,6 +30,7 @@ func TestLoadSelector(t *testing.T) {
 	}
 	ccfg := &config.ClaimsSelectorConf{}
 	rcfg := &config.RegexSelectorConf{}
+	ecfg := &config.ExpressionSelectorConf{}
 
 	table := []test{
 		{cfg: &config.PolicySelector{Static: sCfg, Migration: mcfg}, expectedErr: ErrMultipleSelectors},
@@ -38,6 +40,8 @@ func TestLoadSelector(t *testing.T) {
 		{cfg: &config.PolicySelector{Migration: mcfg}, expectedErr: nil},
 		{cfg: &config.PolicySelector{Claims: ccfg}, expectedErr: nil},
 		{cfg: &config.PolicySelector{Regex: rcfg}, expectedErr: nil},
+		{cfg: &config.PolicySelector{Regex: rcfg, Expression: ecfg}, expectedErr: ErrMultipleSelectors},
+		{cfg: &config.PolicySelector{Expression: ecfg}, expectedErr: nil},
 	}
 
 	for _, test := range table {
@@ -208,3 +212,93 @@ func TestRegexSelector(t *testing.T) {
 		})
 	}
 }
+
+func TestExpressionSelector(t *testing.T) {
+	sel, err := NewExpressionSelector(&config.ExpressionSelectorConf{
+		DefaultPolicy: "default",
+		Rules: []config.ExpressionRuleConf{
+			{Expression: `"canary" in claims.groups && inNetwork(ip, ["10.0.0.0/8", "192.168.0.0/16"])`, Policy: "canary"},
+			{Expression: `claims.preferred_username.matches("^(einstein|marie)$") || header["X-Ocis-Policy"] == 'ocis'`, Policy: "ocis"},
+			{Expression: `!authenticated && (path.startsWith("/legacy/") || method == "PROPFIND")`, Policy: "oc10"},
+		},
+		TrustedProxies: []string{"192.168.1.1", "172.16.0.0/12"},
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	canary := map[string]interface{}{oidc.Groups: []interface{}{"users", "canary"}}
+	var tests = []struct {
+		Name     string
+		Method   string
+		Target   string
+		Remote   string
+		Header   map[string]string
+		Claims   map[string]interface{}
+		Expected string
+	}{
+		{"canary-internal", "GET", "/", "10.1.2.3:1234", nil, canary, "canary"},
+		{"canary-external", "GET", "/", "203.0.113.1:1234", nil, canary, "default"},
+		{"canary-spoofed-forwarded-for", "GET", "/", "203.0.113.1:1234", map[string]string{"X-Forwarded-For": "10.1.2.3"}, canary, "default"},
+		{"canary-trusted-proxy", "GET", "/", "192.168.1.1:1234", map[string]string{"X-Forwarded-For": "10.1.2.3, 172.16.0.5"}, canary, "canary"},
+		{"canary-trusted-proxy-spoofed", "GET", "/", "192.168.1.1:1234", map[string]string{"X-Forwarded-For": "10.1.2.3, 203.0.113.1"}, canary, "default"},
+		{"username", "GET", "/", "203.0.113.1:1234", nil, map[string]interface{}{oidc.PreferredUsername: "marie"}, "ocis"},
+		{"username-no-match", "GET", "/", "203.0.113.1:1234", nil, map[string]interface{}{oidc.PreferredUsername: "mariella"}, "default"},
+		{"header", "GET", "/", "203.0.113.1:1234", map[string]string{"X-Ocis-Policy": "ocis"}, nil, "ocis"},
+		{"unauthenticated-path", "GET", "/legacy/index.php", "203.0.113.1:1234", nil, nil, "oc10"},
+		{"unauthenticated-method", "PROPFIND", "/remote.php/webdav", "203.0.113.1:1234", nil, nil, "oc10"},
+		{"authenticated-path", "GET", "/legacy/index.php", "203.0.113.1:1234", nil, map[string]interface{}{}, "default"},
+	}
+
+	for _, tc := range tests {
+		tc := tc // capture range variable
+		t.Run(tc.Name, func(t *testing.T) {
+			r := httptest.NewRequest(tc.Method, "https://example.com"+tc.Target, nil)
+			r.RemoteAddr = tc.Remote
+			for k, v := range tc.Header {
+				r.Header.Set(k, v)
+			}
+			if tc.Claims != nil {
+				r = r.WithContext(oidc.NewContext(r.Context(), tc.Claims))
+			}
+			// the RealIP middleware replaces the RemoteAddr with the forwarded address after the peer address is kept
+			r = r.WithContext(ContextWithPeerAddr(r.Context(), r.RemoteAddr))
+			if f := r.Header.Get("X-Forwarded-For"); f != "" {
+				r.RemoteAddr = strings.TrimSpace(strings.Split(f, ",")[0])
+			}
+			got, err := sel(r)
+			if err != nil {
+				t.Errorf("Unexpected error: %v", err)
+			}
+
+			if got != tc.Expected {
+				t.Errorf("Expected Policy %v got %v", tc.Expected, got)
+			}
+		})
+	}
+}
+
+func TestExpressionSelectorInvalidExpression(t *testing.T) {
+	var tests = []string{
+		`path ==`,
+		`path.startsWith()`,
+		`inNetwork(ip, "10.0.0.0/33")`,
+		`inNetwork(ip, ["10.0.0.0/8", "10.0.0.0/33"])`,
+		`user.name == "einstein"`,
+		`path.matches("(")`,
+		`(path == "/"`,
+		`path == "/" foo`,
+		`header == "foo"`,
+		`"unterminated`,
+		`path`,
+	}
+
+	for _, expr := range tests {
+		_, err := NewExpressionSelector(&config.ExpressionSelectorConf{
+			Rules: []config.ExpressionRuleConf{{Expression: expr, Policy: "ocis"}},
+		})
+		if err == nil {
+			t.Errorf("Expected an error for expression %s", expr)
+		}
+	}
+}
@@ -1,6 +1,7 @@
 package proxy
 
 import (
+	"context"
 	"crypto/tls"
 	"fmt"
 	"net"
@@ -8,15 +9,21 @@ import (
 	"net/http/httputil"
 	"net/url"
 	"regexp"
+	"strconv"
 	"strings"
 	"time"
 
+	revactx "github.com/cs3org/reva/v2/pkg/ctx"
 	chimiddleware "github.com/go-chi/chi/v5/middleware"
+	"github.com/prometheus/client_golang/prometheus"
 	"go-micro.dev/v4/selector"
 
 	"go.opentelemetry.io/otel/attribute"
 
 	"github.com/owncloud/ocis/extensions/proxy/pkg/config"
+	"github.com/owncloud/ocis/extensions/proxy/pkg/metrics"
+	"github.com/owncloud/ocis/extensions/proxy/pkg/proxy/balancer"
+	"github.com/owncloud/ocis/extensions/proxy/pkg/proxy/cache"
 	"github.com/owncloud/ocis/extensions/proxy/pkg/proxy/policy"
 	proxytracing "github.com/owncloud/ocis/extensions/proxy/pkg/tracing"
 	"github.com/owncloud/ocis/ocis-pkg/log"
@@ -33,6 +40,12 @@ type MultiHostReverseProxy struct {
 	PolicySelector policy.Selector
 	logger         log.Logger
 	config         *config.Config
+	metrics        *metrics.Metrics
+	ctx            context.Context
+	upstreams      *balancer.Transport
+	cache          *cache.Cache
+	// cachedRoutes holds the endpoints with caching enabled per policy
+	cachedRoutes map[string]map[string]bool
 }
 
 // NewMultiHostReverseProxy creates a new MultiHostReverseProxy
@@ -40,14 +53,17 @@ func NewMultiHostReverseProxy(opts ...Option) *MultiHostReverseProxy {
 	options := newOptions(opts...)
 
 	rp := &MultiHostReverseProxy{
-		Directors: make(map[string]map[config.RouteType]map[string]func(req *http.Request)),
-		logger:    options.Logger,
-		config:    options.Config,
+		Directors:    make(map[string]map[config.RouteType]map[string]func(req *http.Request)),
+		cachedRoutes: make(map[string]map[string]bool),
+		logger:       options.Logger,
+		config:       options.Config,
+		ctx:          options.Context,
+		metrics:      options.Metrics,
 	}
 	rp.Director = rp.directorSelectionDirector
 
 	// equals http.DefaultTransport except TLSClientConfig
-	rp.Transport = &http.Transport{
+	transport := &http.Transport{
 		Proxy: http.ProxyFromEnvironment,
 		DialContext: (&net.Dialer{
 			Timeout:   30 * time.Second,
@@ -63,6 +79,8 @@ func NewMultiHostReverseProxy(opts ...Option) *MultiHostReverseProxy {
 			InsecureSkipVerify: options.Config.InsecureBackends, //nolint:gosec
 		},
 	}
+	rp.upstreams = balancer.NewTransport(transport, options.Logger)
+	rp.Transport = rp.upstreams
 
 	if options.Config.PolicySelector == nil {
 		firstPolicy := options.Config.Policies[0].Name
@@ -89,8 +107,8 @@ func NewMultiHostReverseProxy(opts ...Option) *MultiHostReverseProxy {
 		for _, route := range pol.Routes {
 			rp.logger.Debug().Str("fwd: ", route.Endpoint)
 
-			if route.Backend == "" && route.Service == "" {
-				rp.logger.Fatal().Interface("route", route).Msg("neither Backend nor Service is set")
+			if route.Backend == "" && len(route.Backends) == 0 && route.Service == "" {
+				rp.logger.Fatal().Interface("route", route).Msg("neither Backend, Backends nor Service is set")
 			}
 			uri, err2 := url.Parse(route.Backend)
 			if err2 != nil {
@@ -109,11 +127,33 @@ func NewMultiHostReverseProxy(opts ...Option) *MultiHostReverseProxy {
 	return rp
 }
 
+type contextKey int
+
+const routeKey contextKey = iota
+
+// route is the policy and route endpoint a request was matched to.
+type route struct {
+	policy   string
+	endpoint string
+	director func(req *http.Request)
+}
+
 func (p *MultiHostReverseProxy) directorSelectionDirector(r *http.Request) {
+	rt, ok := r.Context().Value(routeKey).(route)
+	if !ok {
+		rt, ok = p.matchRoute(r)
+	}
+	if ok {
+		rt.director(r)
+	}
+}
+
+// matchRoute selects the policy for the request and finds the matching director.
+func (p *MultiHostReverseProxy) matchRoute(r *http.Request) (route, bool) {
 	pol, err := p.PolicySelector(r)
 	if err != nil {
 		p.logger.Error().Err(err).Msg("Error while selecting pol")
-		return
+		return route{}, false
 	}
 
 	if _, ok := p.Directors[pol]; !ok {
@@ -121,7 +161,7 @@ func (p *MultiHostReverseProxy) directorSelectionDirector(r *http.Request) {
 			Error().
 			Str("policy", pol).
 			Msg("policy is not configured")
-		return
+		return route{policy: pol}, false
 	}
 
 	// find matching director
@@ -147,16 +187,14 @@ func (p *MultiHostReverseProxy) directorSelectionDirector(r *http.Request) {
 					Str("routeType", string(rt)).
 					Msg("director found")
 
-				p.Directors[pol][rt][endpoint](r)
-				return
+				return route{policy: pol, endpoint: endpoint, director: p.Directors[pol][rt][endpoint]}, true
 			}
 		}
 	}
 
 	// override default director with root. If any
 	if p.Directors[pol][config.PrefixRoute]["/"] != nil {
-		p.Directors[pol][config.PrefixRoute]["/"](r)
-		return
+		return route{policy: pol, endpoint: "/", director: p.Directors[pol][config.PrefixRoute]["/"]}, true
 	}
 
 	p.logger.
@@ -164,6 +202,7 @@ func (p *MultiHostReverseProxy) directorSelectionDirector(r *http.Request) {
 		Str("policy", pol).
 		Str("path", r.URL.Path).
 		Msg("no director found")
+	return route{policy: pol}, false
 }
 
 func singleJoiningSlash(a, b string) string {
@@ -180,7 +219,6 @@ func singleJoiningSlash(a, b string) string {
 
 // AddHost undocumented
 func (p *MultiHostReverseProxy) AddHost(policy string, target *url.URL, rt config.Route) {
-	targetQuery := target.RawQuery
 	if p.Directors[policy] == nil {
 		p.Directors[policy] = make(map[config.RouteType]map[string]func(req *http.Request))
 	}
@@ -195,8 +233,46 @@ func (p *MultiHostReverseProxy) AddHost(policy string, target *url.URL, rt confi
 	reg := registry.GetRegistry()
 	sel := selector.NewSelector(selector.Registry(reg))
 
+	if rt.Cache {
+		p.enableCache(policy, rt.Endpoint)
+	}
+
+	var pool *balancer.Pool
+	if len(rt.Backends) > 0 {
+		var err error
+		pool, err = p.upstreams.NewPool(rt.Backends, rt.LoadBalancer)
+		if err != nil {
+			p.logger.
+				Fatal(). // fail early on misconfiguration
+				Err(err).
+				Strs("backends", rt.Backends).
+				Msg("invalid load balancer configuration")
+		}
+		if err := pool.Start(p.ctx, p.healthCheckClient()); err != nil {
+			p.logger.
+				Fatal().
+				Err(err).
+				Strs("backends", rt.Backends).
+				Msg("could not start the health checks")
+		}
+	}
+
 	p.Directors[policy][routeType][rt.Endpoint] = func(req *http.Request) {
-		if rt.Service != "" {
+		target := target
+		switch {
+		case pool != nil:
+			backend, err := pool.Next()
+			if err != nil {
+				// leaving the request untouched makes the transport fail, which is answered with a 502
+				p.logger.Error().Err(err).Str("endpoint", rt.Endpoint).Msg("could not select backend")
+				return
+			}
+			target = backend.URL
+			// the transport records the outcome of the request for the backend
+			*req = *req.WithContext(balancer.WithBackend(req.Context(), backend))
+			req.URL.Host = target.Host
+			req.URL.Scheme = target.Scheme
+		case rt.Service != "":
 			// select next node
 			next, err := sel.Select(rt.Service)
 			if err != nil {
@@ -210,8 +286,7 @@ func (p *MultiHostReverseProxy) AddHost(policy string, target *url.URL, rt confi
 			}
 			req.URL.Host = node.Address
 			req.URL.Scheme = node.Metadata["protocol"] // TODO check property exists?
-
-		} else {
+		default:
 			req.URL.Host = target.Host
 			req.URL.Scheme = target.Scheme
 		}
@@ -222,6 +297,7 @@ func (p *MultiHostReverseProxy) AddHost(policy string, target *url.URL, rt confi
 			req.Host = target.Host
 		}
 
+		targetQuery := target.RawQuery
 		req.URL.Path = singleJoiningSlash(target.Path, req.URL.Path)
 		if targetQuery == "" || req.URL.RawQuery == "" {
 			req.URL.RawQuery = targetQuery + req.URL.RawQuery
@@ -235,6 +311,37 @@ func (p *MultiHostReverseProxy) AddHost(policy string, target *url.URL, rt confi
 	}
 }
 
+// enableCache turns on the response cache for an endpoint, the cache is created on first use.
+func (p *MultiHostReverseProxy) enableCache(policy, endpoint string) {
+	if p.cache == nil {
+		c, err := cache.New(p.config.ResponseCache)
+		if err != nil {
+			p.logger.
+				Fatal(). // fail early on misconfiguration
+				Err(err).
+				Str("store", p.config.ResponseCache.Store).
+				Msg("could not create response cache")
+		}
+		p.cache = c
+	}
+	if p.cachedRoutes[policy] == nil {
+		p.cachedRoutes[policy] = make(map[string]bool)
+	}
+	p.cachedRoutes[policy][endpoint] = true
+}
+
+// healthCheckClient returns the client probing the health endpoints of balanced backends.
+func (p *MultiHostReverseProxy) healthCheckClient() *http.Client {
+	return &http.Client{
+		Transport: &http.Transport{
+			TLSClientConfig: &tls.Config{
+				InsecureSkipVerify: p.config.InsecureBackends, //nolint:gosec
+			},
+			DisableKeepAlives: true,
+		},
+	}
+}
+
 func (p *MultiHostReverseProxy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	var (
 		ctx  = r.Context()
@@ -253,7 +360,59 @@ func (p *MultiHostReverseProxy) ServeHTTP(w http.ResponseWriter, r *http.Request
 
 	pkgtrace.Propagator.Inject(ctx, propagation.HeaderCarrier(r.Header))
 
-	p.ReverseProxy.ServeHTTP(w, r.WithContext(ctx))
+	// the route is matched up front so that the metrics can be labeled with it
+	rt, ok := p.matchRoute(r)
+	if ok {
+		ctx = context.WithValue(ctx, routeKey, rt)
+	}
+
+	var handler http.Handler = &p.ReverseProxy
+	if ok && p.cachedRoutes[rt.policy][rt.endpoint] {
+		handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			var userID string
+			if u, ok := revactx.ContextGetUser(r.Context()); ok {
+				userID = u.GetId().GetOpaqueId()
+			}
+			p.cache.ServeHTTP(w, r, rt.policy+" "+rt.endpoint, userID, &p.ReverseProxy)
+		})
+	}
+
+	if p.metrics == nil {
+		handler.ServeHTTP(w, r.WithContext(ctx))
+		return
+	}
+
+	start := time.Now()
+	ww := chimiddleware.NewWrapResponseWriter(w, r.ProtoMajor)
+	handler.ServeHTTP(ww, r.WithContext(ctx))
+
+	labels := prometheus.Labels{
+		"policy": rt.policy,
+		"route":  rt.endpoint,
+		"method": methodLabel(r.Method),
+		"status": statusClass(ww.Status()),
+	}
+	p.metrics.Requests.With(labels).Inc()
+	p.metrics.RequestDuration.With(labels).Observe(time.Since(start).Seconds())
+}
+
+// methodLabel limits the method label to the known http and webdav methods to keep the cardinality bounded.
+func methodLabel(method string) string {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete,
+		http.MethodConnect, http.MethodOptions, http.MethodTrace,
+		"PROPFIND", "PROPPATCH", "MKCOL", "COPY", "MOVE", "LOCK", "UNLOCK", "REPORT", "SEARCH":
+		return method
+	}
+	return "OTHER"
+}
+
+// statusClass returns the class of a status code, e.g. 2xx.
+func statusClass(status int) string {
+	if status < 100 || status > 599 {
+		return "unknown"
+	}
+	return strconv.Itoa(status/100) + "xx"
 }
 
 func (p MultiHostReverseProxy) queryRouteMatcher(endpoint string, target url.URL) bool {
@@ -108,6 +108,14 @@ func TestProxyIntegration(t *testing.T) {
 			},
 		})).withRequest("GET", "https://example.com/user/1234", nil).
 			expectProxyTo("http://users.example.com/user/1234"),
+
+		// Balanced prefix route
+		test("balanced_prefix", withPolicy("ocis", withRoutes{{
+			Type:     config.PrefixRoute,
+			Endpoint: "/api",
+			Backends: []string{"http://api1.example.com/service1/", "http://api2.example.com/service1/"}},
+		})).withRequest("GET", "https://example.com/api?format=json", nil).
+			expectProxyTo("http://api1.example.com/service1/api?format=json"),
 	}
 
 	for k := range tests {
@@ -99,3 +99,34 @@ func TestSingleJoiningSlash(t *testing.T) {
 		}
 	}
 }
+
+func TestStatusClass(t *testing.T) {
+	table := map[int]string{
+		200: "2xx",
+		207: "2xx",
+		304: "3xx",
+		404: "4xx",
+		502: "5xx",
+		0:   "unknown",
+	}
+
+	for status, class := range table {
+		if got := statusClass(status); got != class {
+			t.Errorf("statusClass got %s expected %s for %d", got, class, status)
+		}
+	}
+}
+
+func TestMethodLabel(t *testing.T) {
+	table := map[string]string{
+		"GET":      "GET",
+		"PROPFIND": "PROPFIND",
+		"BREW":     "OTHER",
+	}
+
+	for method, label := range table {
+		if got := methodLabel(method); got != label {
+			t.Errorf("methodLabel got %s expected %s for %s", got, label, method)
+		}
+	}
+}
@@ -17,15 +17,19 @@ type cs3backend struct {
 	settingsRoleService settingssvc.RoleService
 	authProvider        RevaAuthenticator
 	machineAuthAPIKey   string
-	logger              log.Logger
+	// accountChecker is nil if the accounts are not checked
+	accountChecker AccountChecker
+	logger         log.Logger
 }
 
-// NewCS3UserBackend creates a user-provider which fetches users from a CS3 UserBackend
-func NewCS3UserBackend(rs settingssvc.RoleService, ap RevaAuthenticator, machineAuthAPIKey string, logger log.Logger) UserBackend {
+// NewCS3UserBackend creates a user-provider which fetches users from a CS3 UserBackend. If an AccountChecker is
+// given, users with a disabled account are rejected.
+func NewCS3UserBackend(rs settingssvc.RoleService, ap RevaAuthenticator, machineAuthAPIKey string, ac AccountChecker, logger log.Logger) UserBackend {
 	return &cs3backend{
 		settingsRoleService: rs,
 		authProvider:        ap,
 		machineAuthAPIKey:   machineAuthAPIKey,
+		accountChecker:      ac,
 		logger:              logger,
 	}
 }
@@ -48,6 +52,9 @@ func (c *cs3backend) GetUserByClaims(ctx context.Context, claim, value string, w
 	}
 
 	user := res.User
+	if err := c.checkAccount(ctx, user, res.Token); err != nil {
+		return nil, "", err
+	}
 
 	if !withRoles {
 		return user, res.Token, nil
@@ -109,10 +116,28 @@ func (c *cs3backend) Authenticate(ctx context.Context, username string, password
 	case res.Status.Code != rpcv1beta1.Code_CODE_OK:
 		return nil, "", fmt.Errorf("could not authenticate with username and password user: %s, got code: %d", username, res.Status.Code)
 	}
+	if err := c.checkAccount(ctx, res.User, res.Token); err != nil {
+		return nil, "", err
+	}
 
 	return res.User, res.Token, nil
 }
 
+// checkAccount returns ErrAccountDisabled if the account of a primary user is disabled.
+func (c *cs3backend) checkAccount(ctx context.Context, user *cs3.User, token string) error {
+	if c.accountChecker == nil || user.GetId().GetType() != cs3.UserType_USER_TYPE_PRIMARY {
+		return nil
+	}
+	enabled, err := c.accountChecker.AccountEnabled(ctx, user, token)
+	if err != nil {
+		return fmt.Errorf("could not check the account of user %s: %w", user.GetId().GetOpaqueId(), err)
+	}
+	if !enabled {
+		return ErrAccountDisabled
+	}
+	return nil
+}
+
 func (c *cs3backend) CreateUserFromClaims(ctx context.Context, claims map[string]interface{}) (*cs3.User, error) {
 	return nil, fmt.Errorf("CS3 Backend does not support creating users from claims")
 }
@@ -0,0 +1,94 @@
+package backend
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	gateway "github.com/cs3org/go-cs3apis/cs3/gateway/v1beta1"
+	userv1beta1 "github.com/cs3org/go-cs3apis/cs3/identity/user/v1beta1"
+	rpcv1beta1 "github.com/cs3org/go-cs3apis/cs3/rpc/v1beta1"
+	"github.com/owncloud/ocis/ocis-pkg/log"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+)
+
+type authenticatorMock struct {
+	user *userv1beta1.User
+}
+
+func (m authenticatorMock) Authenticate(ctx context.Context, in *gateway.AuthenticateRequest, opts ...grpc.CallOption) (*gateway.AuthenticateResponse, error) {
+	return &gateway.AuthenticateResponse{
+		Status: &rpcv1beta1.Status{Code: rpcv1beta1.Code_CODE_OK},
+		User:   m.user,
+		Token:  "token",
+	}, nil
+}
+
+func TestGraphAccountChecker(t *testing.T) {
+	requests := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		assert.Equal(t, "token", r.Header.Get("x-access-token"))
+		switch r.URL.Path {
+		case "/graph/v1.0/users/disabled":
+			_, _ = w.Write([]byte(`{"id":"disabled","accountEnabled":false}`))
+		case "/graph/v1.0/users/enabled":
+			_, _ = w.Write([]byte(`{"id":"enabled"}`))
+		case "/graph/v1.0/users/broken":
+			w.WriteHeader(http.StatusInternalServerError)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer srv.Close()
+
+	c := NewGraphAccountChecker(srv.URL+"/graph/", srv.Client(), time.Minute, false, true, log.NewLogger())
+	user := func(id string) *userv1beta1.User {
+		return &userv1beta1.User{Id: &userv1beta1.UserId{OpaqueId: id, Type: userv1beta1.UserType_USER_TYPE_PRIMARY}}
+	}
+
+	for id, want := range map[string]bool{"disabled": false, "enabled": true, "unknown": true} {
+		enabled, err := c.AccountEnabled(context.Background(), user(id), "token")
+		assert.NoError(t, err, id)
+		assert.Equal(t, want, enabled, id)
+	}
+	_, err := c.AccountEnabled(context.Background(), user("broken"), "token")
+	assert.Error(t, err)
+
+	// the state is cached
+	_, _ = c.AccountEnabled(context.Background(), user("disabled"), "token")
+	assert.Equal(t, 4, requests)
+
+	c = NewGraphAccountChecker(srv.URL+"/graph/", srv.Client(), time.Minute, true, true, log.NewLogger())
+	enabled, err := c.AccountEnabled(context.Background(), user("unknown"), "token")
+	assert.NoError(t, err)
+	assert.False(t, enabled)
+
+	// by default the users are allowed if the graph service fails, the failures are not cached
+	open := NewGraphAccountChecker(srv.URL+"/graph/", srv.Client(), time.Minute, true, false, log.NewLogger())
+	requests = 0
+	for i := 0; i < 2; i++ {
+		enabled, err = open.AccountEnabled(context.Background(), user("broken"), "token")
+		assert.NoError(t, err)
+		assert.True(t, enabled)
+	}
+	assert.Equal(t, 2, requests)
+	unreachable := NewGraphAccountChecker("http://127.0.0.1:0/graph", srv.Client(), time.Minute, true, false, log.NewLogger())
+	enabled, err = unreachable.AccountEnabled(context.Background(), user("enabled"), "token")
+	assert.NoError(t, err)
+	assert.True(t, enabled)
+
+	b := NewCS3UserBackend(nil, authenticatorMock{user: user("disabled")}, "", c, log.NewLogger())
+	_, _, err = b.GetUserByClaims(context.Background(), "username", "disabled", false)
+	assert.Equal(t, ErrAccountDisabled, err)
+	_, _, err = b.Authenticate(context.Background(), "disabled", "secret")
+	assert.Equal(t, ErrAccountDisabled, err)
+
+	b = NewCS3UserBackend(nil, authenticatorMock{user: user("enabled")}, "", c, log.NewLogger())
+	u, _, err := b.GetUserByClaims(context.Background(), "username", "enabled", false)
+	assert.NoError(t, err)
+	assert.Equal(t, "enabled", u.Id.OpaqueId)
+}
@@ -0,0 +1,111 @@
+package backend
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/ReneKroon/ttlcache/v2"
+	cs3 "github.com/cs3org/go-cs3apis/cs3/identity/user/v1beta1"
+	revactx "github.com/cs3org/reva/v2/pkg/ctx"
+	"github.com/owncloud/ocis/ocis-pkg/log"
+)
+
+// AccountChecker checks whether the account of a user is enabled.
+type AccountChecker interface {
+	AccountEnabled(ctx context.Context, user *cs3.User, token string) (bool, error)
+}
+
+type graphAccountChecker struct {
+	endpoint    string
+	client      *http.Client
+	cache       *ttlcache.Cache
+	denyUnknown bool
+	failClosed  bool
+	logger      log.Logger
+}
+
+// NewGraphAccountChecker returns an AccountChecker which looks up the accountEnabled property of the users in the
+// graph service at the given endpoint, e.g. "http://127.0.0.1:9120/graph". The state of an account is cached for the
+// given duration, a duration of 0 disables the cache. Users the graph service doesn't know are treated as disabled if
+// denyUnknown is set and as enabled otherwise, e.g. for users of a CS3 user provider which isn't the identity backend
+// of the graph service. If the graph service can't be reached the users are rejected if failClosed is set and allowed
+// otherwise, both is logged.
+func NewGraphAccountChecker(endpoint string, client *http.Client, ttl time.Duration, denyUnknown, failClosed bool, logger log.Logger) AccountChecker {
+	var cache *ttlcache.Cache
+	if ttl > 0 {
+		cache = ttlcache.NewCache()
+		_ = cache.SetTTL(ttl)
+		cache.SkipTTLExtensionOnHit(true)
+	}
+	return &graphAccountChecker{
+		endpoint:    strings.TrimSuffix(endpoint, "/"),
+		client:      client,
+		cache:       cache,
+		denyUnknown: denyUnknown,
+		failClosed:  failClosed,
+		logger:      logger,
+	}
+}
+
+// AccountEnabled implements the AccountChecker interface. The user is looked up with the token of the user. Users
+// without the accountEnabled property are enabled.
+func (c *graphAccountChecker) AccountEnabled(ctx context.Context, user *cs3.User, token string) (bool, error) {
+	id := user.GetId().GetOpaqueId()
+	if c.cache != nil {
+		if v, err := c.cache.Get(id); err == nil {
+			return v.(bool), nil
+		}
+	}
+
+	enabled, err := c.lookup(ctx, id, token)
+	if err != nil {
+		if c.failClosed {
+			c.logger.Error().Err(err).Str("user", id).Msg("Could not check the account, rejecting the user")
+			return false, err
+		}
+		c.logger.Warn().Err(err).Str("user", id).Msg("Could not check the account, allowing the user")
+		return true, nil
+	}
+	if c.cache != nil {
+		_ = c.cache.Set(id, enabled)
+	}
+	return enabled, nil
+}
+
+// lookup reads the state of the account of the user from the graph service.
+func (c *graphAccountChecker) lookup(ctx context.Context, id, token string) (bool, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.endpoint+"/v1.0/users/"+url.PathEscape(id), nil)
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set(revactx.TokenHeader, token)
+	res, err := c.client.Do(req)
+	if err != nil {
+		return false, err
+	}
+	defer res.Body.Close()
+
+	enabled := true
+	switch res.StatusCode {
+	case http.StatusOK:
+		u := struct {
+			AccountEnabled *bool `json:"accountEnabled"`
+		}{}
+		if err := json.NewDecoder(res.Body).Decode(&u); err != nil {
+			return false, err
+		}
+		if u.AccountEnabled != nil {
+			enabled = *u.AccountEnabled
+		}
+	case http.StatusNotFound:
+		enabled = !c.denyUnknown
+	default:
+		return false, fmt.Errorf("unexpected status %d looking up user %s", res.StatusCode, id)
+	}
+	return enabled, nil
+}
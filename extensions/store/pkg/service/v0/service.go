@@ -115,7 +115,18 @@ func (s *Service) Read(c context.Context, rreq *storesvc.ReadRequest, rres *stor
 			query.AddQuery(ntq)
 		}
 
-		searchRequest := bleve.NewSearchRequest(query)
+		// bleve returns 10 hits by default, without a limit all matching records are returned
+		size := int(rreq.Options.Limit)
+		if size == 0 {
+			count, err := s.index.DocCount()
+			if err != nil {
+				return merrors.InternalServerError(s.id, "could not count indexed records: %v", err.Error())
+			}
+			size = int(count)
+		}
+		searchRequest := bleve.NewSearchRequestOptions(query, size, int(rreq.Options.Offset), false)
+		// sort by id so that the records can be paged with limit and offset
+		searchRequest.SortBy([]string{"_id"})
 		var searchResult *bleve.SearchResult
 		searchResult, err := s.index.Search(searchRequest)
 		if err != nil {
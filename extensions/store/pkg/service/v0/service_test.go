@@ -0,0 +1,68 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/owncloud/ocis/extensions/store/pkg/config"
+	"github.com/owncloud/ocis/ocis-pkg/log"
+	"github.com/owncloud/ocis/ocis-pkg/signingkey"
+	storemsg "github.com/owncloud/ocis/protogen/gen/ocis/messages/store/v0"
+	storesvc "github.com/owncloud/ocis/protogen/gen/ocis/services/store/v0"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writeSigningKeys(t *testing.T, s *Service, userID string, n int) {
+	for i := 0; i < n; i++ {
+		k, err := signingkey.New(userID, userID, fmt.Sprintf("/path-%d", i), []string{"GET"})
+		require.NoError(t, err)
+		value, err := k.Marshal()
+		require.NoError(t, err)
+		err = s.Write(context.Background(), &storesvc.WriteRequest{
+			Options: &storemsg.WriteOptions{Database: signingkey.Database, Table: signingkey.Table},
+			Record: &storemsg.Record{
+				Key:      k.ID,
+				Value:    value,
+				Metadata: map[string]*storemsg.Field{signingkey.UserMetadata: {Type: "string", Value: userID}},
+			},
+		}, &storesvc.WriteResponse{})
+		require.NoError(t, err)
+	}
+}
+
+func readSigningKeys(t *testing.T, s *Service, userID string, limit, offset uint64) []*storemsg.Record {
+	res := &storesvc.ReadResponse{}
+	err := s.Read(context.Background(), &storesvc.ReadRequest{
+		Options: &storemsg.ReadOptions{
+			Database: signingkey.Database,
+			Table:    signingkey.Table,
+			Limit:    limit,
+			Offset:   offset,
+			Where:    map[string]*storemsg.Field{signingkey.UserMetadata: {Type: "string", Value: userID}},
+		},
+	}, res)
+	require.NoError(t, err)
+	return res.Records
+}
+
+func TestReadWhere(t *testing.T) {
+	s, err := New(Logger(log.NewLogger()), Config(&config.Config{Datapath: t.TempDir()}))
+	require.NoError(t, err)
+	writeSigningKeys(t, s, "einstein", 25)
+	writeSigningKeys(t, s, "marie", 3)
+
+	assert.Len(t, readSigningKeys(t, s, "einstein", 0, 0), 25)
+	assert.Len(t, readSigningKeys(t, s, "marie", 0, 0), 3)
+
+	seen := map[string]bool{}
+	for offset := uint64(0); offset < 30; offset += 10 {
+		for _, rec := range readSigningKeys(t, s, "einstein", 10, offset) {
+			assert.False(t, seen[rec.Key], "record %s returned twice", rec.Key)
+			seen[rec.Key] = true
+		}
+	}
+	assert.Len(t, seen, 25)
+	assert.Len(t, readSigningKeys(t, s, "einstein", 10, 20), 5)
+}
@@ -0,0 +1,95 @@
+package signingkey
+
+import (
+	"testing"
+)
+
+func TestNew(t *testing.T) {
+	if _, err := New("uid", "einstein", "relative/path", []string{"GET"}); err != ErrInvalidPathPrefix {
+		t.Errorf("expected ErrInvalidPathPrefix got %v", err)
+	}
+	if _, err := New("uid", "einstein", "/", nil); err != ErrNoMethods {
+		t.Errorf("expected ErrNoMethods got %v", err)
+	}
+
+	k1, err := New("uid", "einstein", "/remote.php/dav/files/einstein/", []string{"get", " PUT"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	k2, err := New("uid", "einstein", "/", []string{"GET"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if k1.ID == k2.ID || k1.Secret == k2.Secret {
+		t.Error("keys must have distinct ids and secrets")
+	}
+	if k1.PathPrefix != "/remote.php/dav/files/einstein" {
+		t.Errorf("expected cleaned path prefix got %s", k1.PathPrefix)
+	}
+	if k1.Methods[0] != "GET" || k1.Methods[1] != "PUT" {
+		t.Errorf("expected normalized methods got %v", k1.Methods)
+	}
+}
+
+func TestAllows(t *testing.T) {
+	k := &Key{PathPrefix: "/remote.php/dav/files/einstein", Methods: []string{"GET", "PUT"}}
+	root := &Key{PathPrefix: "/", Methods: []string{"GET"}}
+	tests := []struct {
+		key      *Key
+		method   string
+		path     string
+		expected bool
+	}{
+		{k, "GET", "/remote.php/dav/files/einstein", true},
+		{k, "get", "/remote.php/dav/files/einstein/photo.jpg", true},
+		{k, "PUT", "/remote.php/dav/files/einstein/a/b.txt", true},
+		{k, "DELETE", "/remote.php/dav/files/einstein/a/b.txt", false},
+		{k, "GET", "/remote.php/dav/files/einsteinium/photo.jpg", false},
+		{k, "GET", "/remote.php/dav/files/einstein/../marie/photo.jpg", false},
+		{k, "GET", "/remote.php/dav/files/marie", false},
+		{root, "GET", "/anything", true},
+		{root, "PUT", "/anything", false},
+	}
+
+	for _, tt := range tests {
+		if got := tt.key.Allows(tt.method, tt.path); got != tt.expected {
+			t.Errorf("%s %s with prefix %s expected %t got %t", tt.method, tt.path, tt.key.PathPrefix, tt.expected, got)
+		}
+	}
+}
+
+func TestSignAndVerify(t *testing.T) {
+	k, err := New("uid", "einstein", "/", []string{"GET"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	url := "https://cloud.example.com/remote.php/dav/files/einstein/photo.jpg?OC-Date=2022-04-20T10:00:00Z&OC-Expires=60"
+	sig := k.Sign(url)
+
+	if !k.Verify(url, sig) {
+		t.Error("signature must be valid")
+	}
+	if k.Verify(url+"0", sig) {
+		t.Error("signature of a modified url must be invalid")
+	}
+	if k.Verify(url, "not-hex") {
+		t.Error("malformed signature must be invalid")
+	}
+
+	other, _ := New("uid", "einstein", "/", []string{"GET"})
+	if other.Verify(url, sig) {
+		t.Error("signature of another key must be invalid")
+	}
+
+	data, err := k.Marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+	decoded, err := Unmarshal(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !decoded.Verify(url, sig) {
+		t.Error("decoded key must verify the signature")
+	}
+}